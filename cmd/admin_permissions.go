@@ -0,0 +1,539 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newAdminPermissionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin-permissions",
+		Short: "Manage fine-grained admin permissions on users, clients and groups",
+		Long: `Manage fine-grained admin permissions: scoped admin access to the users of a
+realm, a single client or the members of a group, granted to users, groups or
+roles through policies of the realm-management client. The server must run
+with the admin-fine-grained-authz feature enabled.`,
+	}
+	cmd.AddCommand(newAdminPermissionsEnableCmd())
+	cmd.AddCommand(newAdminPermissionsDisableCmd())
+	cmd.AddCommand(newAdminPermissionsShowCmd())
+	cmd.AddCommand(newAdminPermissionsGrantCmd())
+	cmd.AddCommand(newAdminPermissionsRevokeCmd())
+	return cmd
+}
+
+// permissionTargetFlags selects what admin permissions apply to: --users,
+// --client or --group.
+type permissionTargetFlags struct {
+	users  bool
+	client string
+	group  string
+}
+
+func (t *permissionTargetFlags) add(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&t.users, "users", false, "target the users of the realm")
+	cmd.Flags().StringVar(&t.client, "client", "", "target a client, by client-id")
+	cmd.Flags().StringVar(&t.group, "group", "", "target a group and its members, by path, e.g. /support/tier1")
+}
+
+func (t *permissionTargetFlags) validate() error {
+	n := 0
+	for _, set := range []bool{t.users, t.client != "", t.group != ""} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("choose exactly one target: --users, --client or --group")
+	}
+	return nil
+}
+
+// resolve looks up the client or group the flags name.
+func (t *permissionTargetFlags) resolve(ctx context.Context, gc keycloak.API, token, realm string) (keycloak.PermissionTarget, error) {
+	switch {
+	case t.client != "":
+		c, err := getClientByClientID(ctx, gc, token, realm, t.client)
+		if err != nil {
+			return keycloak.PermissionTarget{}, fmt.Errorf("failed looking up client %q in realm %s: %w", t.client, realm, err)
+		}
+		return keycloak.PermissionTarget{Kind: "client", ID: gocloak.PString(c.ID), Name: t.client}, nil
+	case t.group != "":
+		g, err := gc.GetGroupByPath(ctx, token, realm, t.group)
+		if err != nil {
+			return keycloak.PermissionTarget{}, fmt.Errorf("failed looking up group %q in realm %s: %w", t.group, realm, err)
+		}
+		return keycloak.PermissionTarget{Kind: "group", ID: gocloak.PString(g.ID), Name: gocloak.PString(g.Path)}, nil
+	}
+	return keycloak.PermissionTarget{Kind: "users"}, nil
+}
+
+// scopeNames returns the scopes of enabled admin permissions, sorted.
+func scopeNames(perms *gocloak.ManagementPermissionRepresentation) []string {
+	if perms.ScopePermissions == nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(*perms.ScopePermissions))
+}
+
+func permissionsEnabled(perms *gocloak.ManagementPermissionRepresentation) bool {
+	return perms != nil && gocloak.PBool(perms.Enabled)
+}
+
+// adminPermissionsToggleOptions holds the flags of
+// `kc admin-permissions enable` and `kc admin-permissions disable`.
+type adminPermissionsToggleOptions struct {
+	realm  string
+	target permissionTargetFlags
+}
+
+func newAdminPermissionsEnableCmd() *cobra.Command {
+	o := &adminPermissionsToggleOptions{}
+	cmd := &cobra.Command{
+		Use:   "enable",
+		Short: "Enable admin permissions on the users, a client or a group",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, true)
+		}),
+	}
+	mutating(cmd, "manage-realm", "manage-authorization")
+	o.target.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func newAdminPermissionsDisableCmd() *cobra.Command {
+	o := &adminPermissionsToggleOptions{}
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Disable admin permissions on the users, a client or a group",
+		Long:  "Disable admin permissions on the users, a client or a group. Keycloak deletes the scope permissions, so every grant on the target is lost; the policies stay.",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, false)
+		}),
+	}
+	mutating(cmd, "manage-realm", "manage-authorization")
+	o.target.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *adminPermissionsToggleOptions) run(cmd *cobra.Command, enable bool) error {
+	if err := o.target.validate(); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := o.target.resolve(ctx, gc, token, realm)
+	if err != nil {
+		return err
+	}
+	current, err := keycloak.GetManagementPermissions(ctx, gc, token, realm, target)
+	if err != nil {
+		return fmt.Errorf("failed reading admin permissions of %s in realm %s: %w", target, realm, err)
+	}
+
+	rep := newReport(cmd)
+	item := audit.ItemResult{Kind: "adminPermission", Realm: realm, Name: target.String(), ID: target.ID}
+	state := map[bool]string{true: "enabled", false: "disabled"}
+	if permissionsEnabled(current) == enable {
+		rep.skip(item, "already "+state[enable], fmt.Sprintf("Admin permissions are already %s on %s in realm %q. Skipped.", state[enable], target, realm))
+		return rep.print(cmd, realm, "Done. Updated: 0, Skipped: 1.")
+	}
+	updated, err := keycloak.SetManagementPermissions(ctx, gc, token, realm, target, enable)
+	if err != nil {
+		return fmt.Errorf("failed updating admin permissions of %s in realm %s: %w", target, realm, err)
+	}
+	was := !enable
+	recordChange(cmd, realm, target.String(), target.ID, appendFieldChange(nil, "adminPermissions", &was, &enable)...)
+	if enable {
+		rep.add(kcops.Updated, item, fmt.Sprintf("Enabled admin permissions on %s in realm %q.", target, realm))
+		rep.note("  scopes: " + strings.Join(scopeNames(updated), ", "))
+	} else {
+		rep.add(kcops.Updated, item, fmt.Sprintf("Disabled admin permissions on %s in realm %q; its grants were deleted.", target, realm))
+	}
+	return rep.print(cmd, realm, "Done. Updated: 1, Skipped: 0.")
+}
+
+// adminPermissionsShowOptions holds the flags of `kc admin-permissions show`.
+type adminPermissionsShowOptions struct {
+	realm     string
+	target    permissionTargetFlags
+	normalize bool
+}
+
+// adminPermissionsOutput is the --output json form of
+// `kc admin-permissions show`: the policy names granted each scope.
+type adminPermissionsOutput struct {
+	Target  string              `json:"target"`
+	Enabled bool                `json:"enabled"`
+	Scopes  map[string][]string `json:"scopes,omitempty"`
+}
+
+func newAdminPermissionsShowCmd() *cobra.Command {
+	o := &adminPermissionsShowOptions{}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the scopes of admin permissions and the policies granted each",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	o.target.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
+}
+
+func (o *adminPermissionsShowOptions) run(cmd *cobra.Command) error {
+	if err := o.target.validate(); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := o.target.resolve(ctx, gc, token, realm)
+	if err != nil {
+		return err
+	}
+	perms, err := keycloak.GetManagementPermissions(ctx, gc, token, realm, target)
+	if err != nil {
+		return fmt.Errorf("failed reading admin permissions of %s in realm %s: %w", target, realm, err)
+	}
+	out := adminPermissionsOutput{Target: target.String(), Enabled: permissionsEnabled(perms)}
+	if out.Enabled {
+		rmID, err := keycloak.RealmManagementID(ctx, gc, token, realm)
+		if err != nil {
+			return err
+		}
+		out.Scopes = map[string][]string{}
+		for scope, permID := range *perms.ScopePermissions {
+			policies, err := gc.GetAuthorizationPolicyAssociatedPolicies(ctx, token, realm, rmID, permID)
+			if err != nil {
+				return fmt.Errorf("failed reading the policies of %s on %s in realm %s: %w", scope, target, realm, err)
+			}
+			names := []string{}
+			for _, p := range policies {
+				names = append(names, gocloak.PString(p.Name))
+			}
+			if o.normalize {
+				slices.Sort(names)
+			}
+			out.Scopes[scope] = names
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, out)
+	}
+	if !out.Enabled {
+		printBox(cmd, []string{fmt.Sprintf("Admin permissions are disabled on %s.", target)}, realm)
+		return nil
+	}
+	lines := []string{fmt.Sprintf("Admin permissions on %s:", target)}
+	for _, scope := range scopeNames(perms) {
+		granted := "-"
+		if names := out.Scopes[scope]; len(names) > 0 {
+			granted = strings.Join(names, ", ")
+		}
+		lines = append(lines, fmt.Sprintf("  %s: %s", scope, granted))
+	}
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// adminPermissionsGrantOptions holds the flags of `kc admin-permissions grant`
+// and `kc admin-permissions revoke`.
+type adminPermissionsGrantOptions struct {
+	realm   string
+	target  permissionTargetFlags
+	scopes  []string
+	toUser  string
+	toGroup string
+	toRole  string
+}
+
+func newAdminPermissionsGrantCmd() *cobra.Command {
+	o := &adminPermissionsGrantOptions{}
+	cmd := &cobra.Command{
+		Use:   "grant",
+		Short: "Grant scopes of admin permissions to a user, group or role",
+		Long: `Grant scopes of admin permissions on the users, a client or a group to a user,
+group or realm role. Admin permissions are enabled on the target when needed.
+The grant goes through a policy of realm-management named kc-user-<username>,
+kc-group-<path> or kc-role-<role>, created on first use and shared by every
+grant to the same principal.
+
+For example, to let the helpdesk group manage the users of group /customers
+only:
+
+  kc admin-permissions grant --group /customers --scope view-members \
+    --scope manage-members --to-group /helpdesk --realm myrealm
+
+The grantees also need the query-users and query-groups roles of
+realm-management to find those users in the admin console.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, true)
+		}),
+	}
+	mutating(cmd, "manage-realm", "manage-authorization")
+	o.addFlags(cmd)
+	return cmd
+}
+
+func newAdminPermissionsRevokeCmd() *cobra.Command {
+	o := &adminPermissionsGrantOptions{}
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke scopes of admin permissions from a user, group or role",
+		Long:  "Revoke scopes of admin permissions from a user, group or realm role granted them with `kc admin-permissions grant`. The policy of the principal is kept for its other grants.",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, false)
+		}),
+	}
+	mutating(cmd, "manage-realm", "manage-authorization")
+	o.addFlags(cmd)
+	return cmd
+}
+
+func (o *adminPermissionsGrantOptions) addFlags(cmd *cobra.Command) {
+	o.target.add(cmd)
+	cmd.Flags().StringSliceVar(&o.scopes, "scope", nil, "scope(s), e.g. view, manage or manage-members. Repeatable; required.")
+	cmd.Flags().StringVar(&o.toUser, "to-user", "", "principal: a user, by username")
+	cmd.Flags().StringVar(&o.toGroup, "to-group", "", "principal: the members of a group, by path")
+	cmd.Flags().StringVar(&o.toRole, "to-role", "", "principal: the holders of a realm role")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+}
+
+// principal returns who the flags grant to.
+func (o *adminPermissionsGrantOptions) principal() adminPrincipal {
+	switch {
+	case o.toUser != "":
+		return adminPrincipal{kind: "user", name: o.toUser}
+	case o.toGroup != "":
+		return adminPrincipal{kind: "group", name: o.toGroup}
+	}
+	return adminPrincipal{kind: "role", name: o.toRole}
+}
+
+func (o *adminPermissionsGrantOptions) run(cmd *cobra.Command, grant bool) error {
+	if err := o.target.validate(); err != nil {
+		return err
+	}
+	if len(o.scopes) == 0 {
+		return errors.New("missing --scope: provide at least one --scope")
+	}
+	n := 0
+	for _, v := range []string{o.toUser, o.toGroup, o.toRole} {
+		if v != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("choose exactly one principal: --to-user, --to-group or --to-role")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	target, err := o.target.resolve(ctx, gc, token, realm)
+	if err != nil {
+		return err
+	}
+	rep := newReport(cmd)
+	changed, err := grantAdminScopes(ctx, cmd, gc, token, realm, rep, target, o.scopes, o.principal(), grant)
+	if err != nil {
+		return err
+	}
+	verb := "Granted"
+	if !grant {
+		verb = "Revoked"
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. %s: %d, Skipped: %d.", verb, changed, len(rep.result.Skipped)))
+}
+
+// adminPrincipal is who scopes of admin permissions are granted to.
+type adminPrincipal struct {
+	// kind is user, group or role.
+	kind string
+	// name is the username, group path or realm role name.
+	name string
+}
+
+func (p adminPrincipal) String() string {
+	return p.kind + " " + p.name
+}
+
+// policyName is the name of the realm-management policy that grants to p.
+func (p adminPrincipal) policyName() string {
+	return "kc-" + p.kind + "-" + p.name
+}
+
+// newPolicy looks up the principal and returns the policy granting to it.
+func (p adminPrincipal) newPolicy(ctx context.Context, gc keycloak.API, token, realm string) (gocloak.PolicyRepresentation, error) {
+	policy := gocloak.PolicyRepresentation{
+		Name:        gocloak.StringP(p.policyName()),
+		Description: gocloak.StringP("Created by kc"),
+		Logic:       gocloak.POSITIVE,
+		Type:        gocloak.StringP(p.kind),
+	}
+	switch p.kind {
+	case "user":
+		id, err := principalUserID(ctx, gc, token, realm, p.name)
+		if err != nil {
+			return policy, err
+		}
+		policy.Users = &[]string{id}
+	case "group":
+		g, err := gc.GetGroupByPath(ctx, token, realm, p.name)
+		if err != nil {
+			return policy, fmt.Errorf("failed looking up group %q in realm %s: %w", p.name, realm, err)
+		}
+		policy.Groups = &[]gocloak.GroupDefinition{{ID: g.ID, ExtendChildren: gocloak.BoolP(false)}}
+	default:
+		role, err := gc.GetRealmRole(ctx, token, realm, p.name)
+		if err != nil {
+			return policy, fmt.Errorf("failed looking up role %q in realm %s: %w", p.name, realm, err)
+		}
+		policy.Roles = &[]gocloak.RoleDefinition{{ID: role.ID, Required: gocloak.BoolP(false)}}
+	}
+	return policy, nil
+}
+
+// principalUserID returns the ID of the user called username.
+func principalUserID(ctx context.Context, gc keycloak.API, token, realm, username string) (string, error) {
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &username, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return "", fmt.Errorf("failed looking up user %q in realm %s: %w", username, realm, err)
+	}
+	if len(users) == 0 || users[0].ID == nil {
+		return "", fmt.Errorf("user %q not found in realm %s", username, realm)
+	}
+	return *users[0].ID, nil
+}
+
+// grantAdminScopes grants, or revokes, scopes of the admin permissions on
+// target to p through the policy of p, enabling the permissions and creating
+// the policy when granting. It returns how many scopes changed.
+func grantAdminScopes(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token, realm string, rep *report, target keycloak.PermissionTarget, scopes []string, p adminPrincipal, grant bool) (int, error) {
+	perms, err := keycloak.GetManagementPermissions(ctx, gc, token, realm, target)
+	if err != nil {
+		return 0, fmt.Errorf("failed reading admin permissions of %s in realm %s: %w", target, realm, err)
+	}
+	if !permissionsEnabled(perms) {
+		if !grant {
+			return 0, fmt.Errorf("admin permissions are disabled on %s in realm %s; nothing to revoke", target, realm)
+		}
+		if perms, err = keycloak.SetManagementPermissions(ctx, gc, token, realm, target, true); err != nil {
+			return 0, fmt.Errorf("failed enabling admin permissions on %s in realm %s: %w", target, realm, err)
+		}
+		was, now := false, true
+		recordChange(cmd, realm, target.String(), target.ID, appendFieldChange(nil, "adminPermissions", &was, &now)...)
+		rep.add(kcops.Updated, audit.ItemResult{Kind: "adminPermission", Realm: realm, Name: target.String(), ID: target.ID},
+			fmt.Sprintf("Enabled admin permissions on %s in realm %q.", target, realm))
+	}
+	valid := scopeNames(perms)
+	for _, scope := range scopes {
+		if !slices.Contains(valid, scope) {
+			return 0, fmt.Errorf("invalid --scope %q for %s: use %s", scope, target, strings.Join(valid, ", "))
+		}
+	}
+	rmID, err := keycloak.RealmManagementID(ctx, gc, token, realm)
+	if err != nil {
+		return 0, err
+	}
+
+	name := p.policyName()
+	policy, err := keycloak.FindPolicy(ctx, gc, token, realm, rmID, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed looking up policy %q in realm %s: %w", name, realm, err)
+	}
+	if policy == nil && grant {
+		rp, err := p.newPolicy(ctx, gc, token, realm)
+		if err != nil {
+			return 0, err
+		}
+		if policy, err = gc.CreatePolicy(ctx, token, realm, rmID, rp); err != nil {
+			return 0, fmt.Errorf("failed creating policy %q in realm %s: %w", name, realm, err)
+		}
+		recordChange(cmd, realm, name, gocloak.PString(policy.ID), appendFieldChange(nil, "type", nil, policy.Type)...)
+		rep.add(kcops.Created, audit.ItemResult{Kind: "adminPolicy", Realm: realm, Name: name, ID: gocloak.PString(policy.ID)},
+			fmt.Sprintf("Created policy %q for %s in realm %q.", name, p, realm))
+	}
+
+	changed := 0
+	for _, scope := range scopes {
+		permID := (*perms.ScopePermissions)[scope]
+		item := audit.ItemResult{Kind: "adminPermission", Realm: realm, Name: target.String() + "/" + scope, ID: permID}
+		var ids []string
+		if policy != nil {
+			if ids, err = keycloak.PermissionPolicyIDs(ctx, gc, token, realm, rmID, permID); err != nil {
+				return changed, fmt.Errorf("failed reading the policies of %s on %s in realm %s: %w", scope, target, realm, err)
+			}
+		}
+		has := policy != nil && slices.Contains(ids, gocloak.PString(policy.ID))
+		switch {
+		case grant && has:
+			rep.skip(item, "already granted", fmt.Sprintf("%s on %s is already granted to %s. Skipped.", scope, target, p))
+			continue
+		case !grant && !has:
+			rep.skip(item, "not granted", fmt.Sprintf("%s on %s is not granted to %s. Skipped.", scope, target, p))
+			continue
+		}
+		var field []audit.FieldChange
+		if grant {
+			ids = append(ids, gocloak.PString(policy.ID))
+			field = appendFieldChange(nil, "grantedTo", nil, &name)
+		} else {
+			ids = slices.DeleteFunc(ids, func(id string) bool { return id == gocloak.PString(policy.ID) })
+			field = appendFieldChange(nil, "grantedTo", &name, nil)
+		}
+		if err := keycloak.SetPermissionPolicies(ctx, gc, token, realm, rmID, permID, ids); err != nil {
+			return changed, fmt.Errorf("failed updating the policies of %s on %s in realm %s: %w", scope, target, realm, err)
+		}
+		recordChange(cmd, realm, item.Name, permID, field...)
+		changed++
+		if grant {
+			rep.add(kcops.Updated, item, fmt.Sprintf("Granted %s on %s to %s.", scope, target, p))
+		} else {
+			rep.add(kcops.Updated, item, fmt.Sprintf("Revoked %s on %s from %s.", scope, target, p))
+		}
+	}
+	return changed, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newAdminPermissionsCmd())
+}
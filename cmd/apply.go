@@ -0,0 +1,515 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// applyOptions holds the flags of `kc apply`.
+type applyOptions struct {
+	file            string
+	prune           bool
+	dryRun          bool
+	realms          []string
+	continueOnError bool
+}
+
+func newApplyCmd() *cobra.Command {
+	o := &applyOptions{}
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Converge realms to the desired state declared in a manifest file",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-clients", "manage-realm")
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "desired-state manifest (YAML or JSON). Required.")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "delete managed entities that are not declared in the manifest")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "print the planned actions without changing anything")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "only apply the given manifest realm(s)")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *applyOptions) run(cmd *cobra.Command) error {
+	if o.file == "" {
+		return errors.New("missing --file: provide the desired-state manifest")
+	}
+	state, err := manifest.Load(o.file)
+	if err != nil {
+		return fmt.Errorf("invalid manifest %s: %w", o.file, err)
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	actions, err := planManifest(ctx, gc, token, state, o.realms, o.prune)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	if o.dryRun {
+		if outputFormat == "json" {
+			return printJSON(cmd, actions)
+		}
+		for _, a := range actions {
+			rep.note(a.String())
+		}
+		return rep.print(cmd, manifestRealmLabel(state, o.realms), fmt.Sprintf("Dry run: %d action(s) planned, nothing changed.", len(actions)))
+	}
+	var realms []string
+	for _, a := range actions {
+		if !slices.Contains(realms, a.Realm) {
+			realms = append(realms, a.Realm)
+		}
+	}
+	if err := preflight(ctx, cmd, gc, token, realms); err != nil {
+		return err
+	}
+
+	if err := applyActions(ctx, gc, token, state, actions, rep, o.continueOnError); err != nil {
+		return err
+	}
+	return rep.print(cmd, manifestRealmLabel(state, o.realms), fmt.Sprintf("Done. Created: %d, Updated: %d, Deleted: %d.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Deleted)))
+}
+
+// applyActions performs the planned actions in order, recording each change
+// and adding it to rep.
+func applyActions(ctx context.Context, gc keycloak.API, token string, state *manifest.State, actions []manifest.Action, rep *report, continueOnError bool) error {
+	for _, a := range actions {
+		if err := applyAction(ctx, gc, token, a, state.Find(a.Realm)); err != nil {
+			err = fmt.Errorf("failed to %s %s %q in realm %s: %w", a.Op, a.Kind, a.Name, a.Realm, err)
+			item := audit.ItemResult{Kind: string(a.Kind), Realm: a.Realm, Name: a.Name}
+			if err := rep.failOrStop(continueOnError, item, err); err != nil {
+				return err
+			}
+			continue
+		}
+		fields := make([]audit.FieldChange, 0, len(a.Fields))
+		for _, f := range a.Fields {
+			o, n := f.Values()
+			fields = append(fields, audit.FieldChange{Field: f.Field, Old: o, New: n})
+		}
+		recordChangeAs(ctx, string(a.Kind)+"_"+string(a.Op), a.Realm, a.Name, "", fields...)
+		rep.addAction(a)
+	}
+	return nil
+}
+
+// planManifest computes the actions for every declared realm, optionally
+// restricted to the realms named in only.
+func planManifest(ctx context.Context, gc keycloak.API, token string, state *manifest.State, only []string, prune bool) ([]manifest.Action, error) {
+	var actions []manifest.Action
+	for i := range state.Realms {
+		desired := &state.Realms[i]
+		if len(only) > 0 && !slices.Contains(only, desired.Name) {
+			continue
+		}
+		actual, err := fetchRealmState(ctx, gc, token, desired.Name, stateOptionsFor(desired, prune))
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, manifest.Diff(desired, actual, manifest.Options{Prune: prune})...)
+	}
+	return actions, nil
+}
+
+func manifestRealmLabel(state *manifest.State, only []string) string {
+	if len(only) == 1 {
+		return only[0]
+	}
+	if len(state.Realms) == 1 {
+		return state.Realms[0].Name
+	}
+	return "manifest realms"
+}
+
+// applyAction performs a single planned action against the server.
+func applyAction(ctx context.Context, gc keycloak.API, token string, a manifest.Action, desired *manifest.Realm) error {
+	realm := a.Realm
+	defer opsClient(gc, token).Invalidate(realm)
+	switch a.Kind {
+	case manifest.KindRealm:
+		if a.Op == manifest.OpCreate {
+			rep := gocloak.RealmRepresentation{Realm: &realm, Enabled: desired.Enabled}
+			if desired.DisplayName != "" {
+				rep.DisplayName = &desired.DisplayName
+			}
+			_, err := gc.CreateRealm(ctx, token, rep)
+			return err
+		}
+		rep, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return err
+		}
+		if desired.Enabled != nil {
+			rep.Enabled = desired.Enabled
+		}
+		if desired.DisplayName != "" {
+			rep.DisplayName = &desired.DisplayName
+		}
+		return gc.UpdateRealm(ctx, token, *rep)
+
+	case manifest.KindRole:
+		switch a.Op {
+		case manifest.OpDelete:
+			return gc.DeleteRealmRole(ctx, token, realm, a.Name)
+		case manifest.OpCreate:
+			r := findDesired(desired.Roles, func(r manifest.Role) bool { return r.Name == a.Name })
+			_, err := gc.CreateRealmRole(ctx, token, realm, gocloak.Role{Name: &r.Name, Description: &r.Description})
+			return err
+		default:
+			r := findDesired(desired.Roles, func(r manifest.Role) bool { return r.Name == a.Name })
+			role, err := gc.GetRealmRole(ctx, token, realm, a.Name)
+			if err != nil {
+				return err
+			}
+			role.Description = &r.Description
+			return gc.UpdateRealmRole(ctx, token, realm, a.Name, *role)
+		}
+
+	case manifest.KindClientScope:
+		if a.Op == manifest.OpCreate {
+			s := findDesired(desired.ClientScopes, func(s manifest.ClientScope) bool { return s.Name == a.Name })
+			protocol := s.Protocol
+			if protocol == "" {
+				protocol = "openid-connect"
+			}
+			_, err := gc.CreateClientScope(ctx, token, realm, gocloak.ClientScope{Name: &s.Name, Description: &s.Description, Protocol: &protocol})
+			return err
+		}
+		scope, err := findClientScopeByName(ctx, gc, token, realm, a.Name)
+		if err != nil {
+			return err
+		}
+		if a.Op == manifest.OpDelete {
+			return gc.DeleteClientScope(ctx, token, realm, *scope.ID)
+		}
+		s := findDesired(desired.ClientScopes, func(s manifest.ClientScope) bool { return s.Name == a.Name })
+		if s.Description != "" {
+			scope.Description = &s.Description
+		}
+		if s.Protocol != "" {
+			scope.Protocol = &s.Protocol
+		}
+		return gc.UpdateClientScope(ctx, token, realm, *scope)
+
+	case manifest.KindClient:
+		if a.Op == manifest.OpDelete {
+			c, err := getClientByClientID(ctx, gc, token, realm, a.Name)
+			if err != nil {
+				return err
+			}
+			return gc.DeleteClient(ctx, token, realm, *c.ID)
+		}
+		d := findDesired(desired.Clients, func(c manifest.Client) bool { return c.ClientID == a.Name })
+		var c *gocloak.Client
+		if a.Op == manifest.OpCreate {
+			id, err := gc.CreateClient(ctx, token, realm, gocloak.Client{ClientID: &d.ClientID})
+			if err != nil {
+				return err
+			}
+			c, err = gc.GetClient(ctx, token, realm, id)
+			if err != nil {
+				return err
+			}
+		} else {
+			var err error
+			if c, err = getClientByClientID(ctx, gc, token, realm, a.Name); err != nil {
+				return err
+			}
+		}
+		applyClientFields(c, d)
+		if err := gc.UpdateClient(ctx, token, realm, *c); err != nil {
+			return err
+		}
+		return syncClientScopes(ctx, gc, token, realm, *c.ID, a)
+
+	case manifest.KindGroup:
+		if a.Op == manifest.OpDelete {
+			g, err := gc.GetGroupByPath(ctx, token, realm, a.Name)
+			if err != nil {
+				return err
+			}
+			return gc.DeleteGroup(ctx, token, realm, *g.ID)
+		}
+		var groupID string
+		if a.Op == manifest.OpCreate {
+			parent, name := splitGroupPath(a.Name)
+			var err error
+			if parent == "" {
+				groupID, err = gc.CreateGroup(ctx, token, realm, gocloak.Group{Name: &name})
+			} else {
+				p, perr := gc.GetGroupByPath(ctx, token, realm, parent)
+				if perr != nil {
+					return fmt.Errorf("parent group %s: %w", parent, perr)
+				}
+				groupID, err = gc.CreateChildGroup(ctx, token, realm, *p.ID, gocloak.Group{Name: &name})
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			g, err := gc.GetGroupByPath(ctx, token, realm, a.Name)
+			if err != nil {
+				return err
+			}
+			groupID = *g.ID
+		}
+		if f, ok := a.Field("realmRoles"); ok {
+			add, remove := setDelta(manifest.StringSlice(f.Old), manifest.StringSlice(f.New))
+			if err := changeRealmRoles(ctx, gc, token, realm, add, func(roles []gocloak.Role) error {
+				return gc.AddRealmRoleToGroup(ctx, token, realm, groupID, roles)
+			}); err != nil {
+				return err
+			}
+			if err := changeRealmRoles(ctx, gc, token, realm, remove, func(roles []gocloak.Role) error {
+				return gc.DeleteRealmRoleFromGroup(ctx, token, realm, groupID, roles)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case manifest.KindUser:
+		var userID string
+		if a.Op != manifest.OpCreate {
+			users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &a.Name, Exact: gocloak.BoolP(true)})
+			if err != nil {
+				return err
+			}
+			if len(users) == 0 {
+				return fmt.Errorf("user %q not found", a.Name)
+			}
+			userID = *users[0].ID
+			if a.Op == manifest.OpDelete {
+				return gc.DeleteUser(ctx, token, realm, userID)
+			}
+		}
+		d := findDesired(desired.Users, func(u manifest.User) bool { return u.Username == a.Name })
+		u := gocloak.User{Username: &d.Username, Enabled: d.Enabled}
+		if d.Email != "" {
+			u.Email = &d.Email
+			u.EmailVerified = gocloak.BoolP(true)
+		}
+		if d.FirstName != "" {
+			u.FirstName = &d.FirstName
+		}
+		if d.LastName != "" {
+			u.LastName = &d.LastName
+		}
+		if a.Op == manifest.OpCreate {
+			if d.Enabled == nil {
+				u.Enabled = gocloak.BoolP(true)
+			}
+			if d.Password != "" {
+				creds := []gocloak.CredentialRepresentation{{Type: gocloak.StringP("password"), Value: gocloak.StringP(d.Password), Temporary: gocloak.BoolP(false)}}
+				u.Credentials = &creds
+			}
+			id, err := gc.CreateUser(ctx, token, realm, u)
+			if err != nil {
+				return err
+			}
+			userID = id
+		} else {
+			u.ID = &userID
+			if err := gc.UpdateUser(ctx, token, realm, u); err != nil {
+				return err
+			}
+		}
+		if f, ok := a.Field("realmRoles"); ok {
+			add, remove := setDelta(manifest.StringSlice(f.Old), manifest.StringSlice(f.New))
+			if err := changeRealmRoles(ctx, gc, token, realm, add, func(roles []gocloak.Role) error {
+				return gc.AddRealmRoleToUser(ctx, token, realm, userID, roles)
+			}); err != nil {
+				return err
+			}
+			if err := changeRealmRoles(ctx, gc, token, realm, remove, func(roles []gocloak.Role) error {
+				return gc.DeleteRealmRoleFromUser(ctx, token, realm, userID, roles)
+			}); err != nil {
+				return err
+			}
+		}
+		if f, ok := a.Field("groups"); ok {
+			add, remove := setDelta(manifest.StringSlice(f.Old), manifest.StringSlice(f.New))
+			for _, p := range add {
+				g, err := gc.GetGroupByPath(ctx, token, realm, p)
+				if err != nil {
+					return fmt.Errorf("group %s: %w", p, err)
+				}
+				if err := gc.AddUserToGroup(ctx, token, realm, userID, *g.ID); err != nil {
+					return err
+				}
+			}
+			for _, p := range remove {
+				g, err := gc.GetGroupByPath(ctx, token, realm, p)
+				if err != nil {
+					return fmt.Errorf("group %s: %w", p, err)
+				}
+				if err := gc.DeleteUserFromGroup(ctx, token, realm, userID, *g.ID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported action kind %q", a.Kind)
+}
+
+func findDesired[T any](items []T, match func(T) bool) T {
+	for _, it := range items {
+		if match(it) {
+			return it
+		}
+	}
+	var zero T
+	return zero
+}
+
+func applyClientFields(c *gocloak.Client, d manifest.Client) {
+	if d.Name != "" {
+		c.Name = &d.Name
+	}
+	if d.Enabled != nil {
+		c.Enabled = d.Enabled
+	}
+	if d.PublicClient != nil {
+		c.PublicClient = d.PublicClient
+	}
+	if d.Protocol != "" {
+		c.Protocol = &d.Protocol
+	}
+	if d.RootURL != "" {
+		c.RootURL = &d.RootURL
+	}
+	if d.BaseURL != "" {
+		c.BaseURL = &d.BaseURL
+	}
+	if d.RedirectURIs != nil {
+		c.RedirectURIs = &d.RedirectURIs
+	}
+	if d.WebOrigins != nil {
+		c.WebOrigins = &d.WebOrigins
+	}
+	if d.StandardFlowEnabled != nil {
+		c.StandardFlowEnabled = d.StandardFlowEnabled
+	}
+	if d.DirectAccessGrantsEnabled != nil {
+		c.DirectAccessGrantsEnabled = d.DirectAccessGrantsEnabled
+	}
+	if d.ImplicitFlowEnabled != nil {
+		c.ImplicitFlowEnabled = d.ImplicitFlowEnabled
+	}
+	if d.ServiceAccountsEnabled != nil {
+		c.ServiceAccountsEnabled = d.ServiceAccountsEnabled
+	}
+}
+
+// syncClientScopes applies the default/optional scope changes of a client action.
+func syncClientScopes(ctx context.Context, gc keycloak.API, token, realm, idOfClient string, a manifest.Action) error {
+	def, hasDef := a.Field("defaultClientScopes")
+	opt, hasOpt := a.Field("optionalClientScopes")
+	if !hasDef && !hasOpt {
+		return nil
+	}
+	scopes, err := gc.GetClientScopes(ctx, token, realm)
+	if err != nil {
+		return err
+	}
+	ids := map[string]string{}
+	for _, s := range scopes {
+		if s.Name != nil && s.ID != nil {
+			ids[*s.Name] = *s.ID
+		}
+	}
+	apply := func(f manifest.FieldDiff, add, remove func(ctx context.Context, token, realm, idOfClient, scopeID string) error) error {
+		toAdd, toRemove := setDelta(manifest.StringSlice(f.Old), manifest.StringSlice(f.New))
+		for _, n := range toRemove {
+			if id, ok := ids[n]; ok {
+				if err := remove(ctx, token, realm, idOfClient, id); err != nil {
+					return fmt.Errorf("removing scope %s: %w", n, err)
+				}
+			}
+		}
+		for _, n := range toAdd {
+			id, ok := ids[n]
+			if !ok {
+				return fmt.Errorf("client scope %q not found", n)
+			}
+			if err := add(ctx, token, realm, idOfClient, id); err != nil && !strings.Contains(err.Error(), "409") {
+				return fmt.Errorf("adding scope %s: %w", n, err)
+			}
+		}
+		return nil
+	}
+	if hasDef {
+		if err := apply(def, gc.AddDefaultScopeToClient, gc.RemoveDefaultScopeFromClient); err != nil {
+			return err
+		}
+	}
+	if hasOpt {
+		if err := apply(opt, gc.AddOptionalScopeToClient, gc.RemoveOptionalScopeFromClient); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func changeRealmRoles(ctx context.Context, gc keycloak.API, token, realm string, names []string, fn func([]gocloak.Role) error) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var roles []gocloak.Role
+	for _, n := range names {
+		r, err := gc.GetRealmRole(ctx, token, realm, n)
+		if err != nil {
+			return fmt.Errorf("realm role %q: %w", n, err)
+		}
+		roles = append(roles, *r)
+	}
+	return fn(roles)
+}
+
+// setDelta returns the members to add and remove to go from old to target.
+func setDelta(old, target []string) (add, remove []string) {
+	for _, v := range target {
+		if !slices.Contains(old, v) {
+			add = append(add, v)
+		}
+	}
+	for _, v := range old {
+		if !slices.Contains(target, v) {
+			remove = append(remove, v)
+		}
+	}
+	return add, remove
+}
+
+// splitGroupPath splits "/a/b/c" into parent "/a/b" and name "c".
+func splitGroupPath(path string) (string, string) {
+	path = strings.TrimSuffix(path, "/")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "", strings.TrimPrefix(path, "/")
+	}
+	return path[:i], path[i+1:]
+}
+
+func init() {
+	rootCmd.AddCommand(newApplyCmd())
+}
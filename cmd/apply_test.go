@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+const applyManifest = `realms:
+  - name: shop
+    displayName: Shop
+    roles:
+      - name: viewer
+        description: Reads
+    clients:
+      - clientId: web
+        publicClient: true
+        redirectUris: ["https://shop.example.org/*"]
+    groups:
+      - path: /staff
+        realmRoles: [viewer]
+    users:
+      - username: alice
+        email: alice@example.org
+        groups: [/staff]
+`
+
+// applyManifestChanged changes the role and the user of applyManifest and
+// drops its client.
+const applyManifestChanged = `realms:
+  - name: shop
+    displayName: Shop
+    roles:
+      - name: viewer
+        description: Reads everything
+    clients: []
+    groups:
+      - path: /staff
+        realmRoles: [viewer]
+    users:
+      - username: alice
+        email: alice@shop.example.org
+        groups: [/staff]
+`
+
+func TestApply(t *testing.T) {
+	// the steps run in order against the same server
+	steps := []struct {
+		name     string
+		manifest string
+		args     []string
+		fail     []string
+		wantErr  string
+		want     wantResult
+		// wantKinds are the audit change kinds by realm/name.
+		wantKinds map[string]string
+		check     func(t *testing.T, api keycloak.API)
+	}{
+		{
+			name:     "dry run changes nothing",
+			manifest: applyManifest,
+			args:     []string{"--dry-run"},
+			check: func(t *testing.T, api keycloak.API) {
+				if _, err := api.GetRealm(context.Background(), "", "shop"); err == nil {
+					t.Error("realm shop created by a dry run")
+				}
+			},
+		},
+		{
+			name:     "reports a failed create with --continue-on-error",
+			manifest: applyManifest,
+			args:     []string{"--continue-on-error"},
+			fail:     []string{"CreateClient"},
+			wantErr:  "1 item(s) failed",
+			want: wantResult{
+				created: []string{"shop/shop", "shop/viewer", "shop//staff", "shop/alice"},
+				failed:  []string{"shop/web"},
+			},
+		},
+		{
+			name:      "creates what is missing",
+			manifest:  applyManifest,
+			want:      wantResult{created: []string{"shop/web"}},
+			wantKinds: map[string]string{"shop/web": "client_create"},
+			check: func(t *testing.T, api keycloak.API) {
+				clients, err := api.GetClients(context.Background(), "", "shop", gocloak.GetClientsParams{ClientID: gocloak.StringP("web")})
+				if err != nil || len(clients) != 1 || !gocloak.PBool(clients[0].PublicClient) {
+					t.Errorf("client web = %v (%v)", clients, err)
+				}
+			},
+		},
+		{
+			name:     "converged state changes nothing",
+			manifest: applyManifest,
+		},
+		{
+			name:     "updates and prunes",
+			manifest: applyManifestChanged,
+			args:     []string{"--prune"},
+			want: wantResult{
+				updated: []string{"shop/viewer", "shop/alice"},
+				deleted: []string{"shop/web"},
+			},
+			wantKinds: map[string]string{"shop/viewer": "role_update", "shop/alice": "user_update", "shop/web": "client_delete"},
+			check: func(t *testing.T, api keycloak.API) {
+				ctx := context.Background()
+				users, err := api.GetUsers(ctx, "", "shop", gocloak.GetUsersParams{Username: gocloak.StringP("alice")})
+				if err != nil || len(users) != 1 || gocloak.PString(users[0].Email) != "alice@shop.example.org" {
+					t.Errorf("user alice = %v (%v)", users, err)
+				}
+				if clients, _ := api.GetClients(ctx, "", "shop", gocloak.GetClientsParams{ClientID: gocloak.StringP("web")}); len(clients) != 0 {
+					t.Errorf("client web not pruned")
+				}
+			},
+		},
+		{
+			name:     "unchanged after the update",
+			manifest: applyManifestChanged,
+			args:     []string{"--prune"},
+		},
+		{
+			name:     "rejects an invalid manifest",
+			manifest: "realms:\n  - displayName: no name\n",
+			wantErr:  "invalid manifest",
+		},
+	}
+	fake := newTestAPI(t)
+	api := &failingAPI{API: fake}
+	for _, st := range steps {
+		if !t.Run(st.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "manifest.yaml")
+			if err := os.WriteFile(path, []byte(st.manifest), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			api.fail = map[string]bool{}
+			for _, name := range st.fail {
+				api.fail[name] = true
+			}
+			_, entry, err := run(t, api, newApplyCmd, append([]string{"apply", "-f", path}, st.args...)...)
+			checkErr(t, err, st.wantErr)
+			status := "ok"
+			if st.wantErr != "" {
+				status = "error"
+			}
+			checkEntry(t, entry, status, st.want)
+			wantChanges := len(st.want.created) + len(st.want.updated) + len(st.want.deleted)
+			if got := len(entry.Details.Changes); got != wantChanges {
+				t.Errorf("%d audit change(s), want %d: %+v", got, wantChanges, entry.Details.Changes)
+			}
+			for item, kind := range st.wantKinds {
+				realm, name, _ := strings.Cut(item, "/")
+				if c := changeOf(t, entry, realm, name); c.Kind != kind {
+					t.Errorf("change of %s = %q, want %q", item, c.Kind, kind)
+				}
+			}
+			if st.check != nil {
+				st.check(t, fake)
+			}
+		}) {
+			// later steps build on this one
+			return
+		}
+	}
+}
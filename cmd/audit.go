@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+
+	"github.com/spf13/cobra"
+)
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the local audit trail and the security settings of a realm",
+	}
+	cmd.AddCommand(newAuditListCmd())
+	cmd.AddCommand(newAuditSecurityCmd())
+	return cmd
+}
+
+// auditListOptions holds the flags of `kc audit list`.
+type auditListOptions struct {
+	files      []string
+	since      string
+	status     string
+	changeKind string
+	limit      int
+	items      string
+}
+
+func newAuditListCmd() *cobra.Command {
+	o := &auditListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit entries filtered by age, status, change kind or Jira ticket",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.files, "file", nil, "audit CSV file(s) to read. Repeatable; defaults to the audit file of the profile and its rotated files")
+	cmd.Flags().StringVar(&o.since, "since", "", "only entries newer than this age (e.g. 7d, 24h) or date (2006-01-02)")
+	cmd.Flags().StringVar(&o.status, "status", "", "filter by status: ok|error")
+	cmd.Flags().StringVar(&o.changeKind, "change-kind", "", "filter by change kind, e.g. users_create")
+	cmd.Flags().IntVar(&o.limit, "limit", 0, "show only the N most recent matching entries")
+	cmd.Flags().StringVar(&o.items, "items", "", "also list the items of each entry with their status; 'all' or a status to keep, e.g. failed")
+	return cmd
+}
+
+func (o *auditListOptions) run(cmd *cobra.Command) error {
+	switch o.items {
+	case "", "all", "created", "updated", "deleted", "skipped", "failed":
+	default:
+		return fmt.Errorf("invalid --items %q: must be all, created, updated, deleted, skipped or failed", o.items)
+	}
+	var cutoff time.Time
+	if o.since != "" {
+		t, err := parseSince(o.since, time.Now())
+		if err != nil {
+			return err
+		}
+		cutoff = t
+	}
+	files := o.files
+	if len(files) == 0 {
+		var err error
+		if files, err = audit.Files(); err != nil {
+			return err
+		}
+	}
+	var matched []audit.Entry
+	for _, f := range files {
+		entries, err := audit.Read(f)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed reading audit file %s: %w", f, err)
+		}
+		for _, e := range entries {
+			if !cutoff.IsZero() && e.Timestamp.Before(cutoff) {
+				continue
+			}
+			if o.status != "" && !strings.EqualFold(e.Status, o.status) {
+				continue
+			}
+			if o.changeKind != "" && e.ChangeKind != o.changeKind {
+				continue
+			}
+			if jira := cmdState(cmd).jira; jira != "" && !strings.EqualFold(e.Jira, jira) {
+				continue
+			}
+			matched = append(matched, e)
+		}
+	}
+	if o.limit > 0 && len(matched) > o.limit {
+		matched = matched[len(matched)-o.limit:]
+	}
+
+	var lines []string
+	for _, e := range matched {
+		id := e.ID
+		if id == "" {
+			id = "-"
+		}
+		lines = append(lines, fmt.Sprintf("%-24s  %s  %-5s  %-22s  %-10s  %s", id, e.Timestamp.Format(time.RFC3339), e.Status, e.ChangeKind, e.Jira, e.RawCommand))
+		if a := e.Details.Approval; a != nil {
+			lines = append(lines, fmt.Sprintf("    approved by %s, plan %s", a.ApprovedBy, a.PlanHash))
+		}
+		for _, c := range e.Details.Changes {
+			lines = append(lines, "    - "+formatChangeLine(c))
+		}
+		if o.items == "" {
+			continue
+		}
+		for _, it := range e.Details.Result.AllItems() {
+			if o.items == "all" || it.Status == o.items {
+				lines = append(lines, "    = "+formatItemStatus(it))
+			}
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(matched)))
+	printBox(cmd, lines, "")
+	return nil
+}
+
+func formatChangeLine(c audit.Change) string {
+	if c.Realm == "" {
+		return c.String()
+	}
+	return fmt.Sprintf("[%s] %s", c.Realm, c.String())
+}
+
+// parseSince converts a relative age such as "7d", "12h" or "2w", or an absolute
+// date (2006-01-02 or RFC3339), into the earliest timestamp to include.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := parseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: use a duration like 7d, 24h or 2w, or a date like 2006-01-02", s)
+	}
+	return now.Add(-d), nil
+}
+
+// parseAge extends time.ParseDuration with day (d) and week (w) units.
+func parseAge(s string) (time.Duration, error) {
+	if n := len(s); n > 1 {
+		unit := s[n-1]
+		if unit == 'd' || unit == 'w' {
+			v, err := strconv.Atoi(s[:n-1])
+			if err != nil {
+				return 0, err
+			}
+			d := time.Duration(v) * 24 * time.Hour
+			if unit == 'w' {
+				d *= 7
+			}
+			return d, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+func init() {
+	rootCmd.AddCommand(newAuditCmd())
+}
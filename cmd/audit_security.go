@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// securitySeverities orders the findings of `kc audit security`.
+var securitySeverities = []string{"high", "medium", "low"}
+
+// securityFinding is one risky setting found by `kc audit security`.
+type securityFinding struct {
+	severity, target, finding, remediation string
+}
+
+// auditSecurityOptions holds the flags of `kc audit security`.
+type auditSecurityOptions struct {
+	realm          string
+	includeBuiltin bool
+	output         reportOutput
+}
+
+func newAuditSecurityCmd() *cobra.Command {
+	o := &auditSecurityOptions{}
+	cmd := &cobra.Command{
+		Use:   "security",
+		Short: "Check a realm and its clients for risky settings",
+		Long: `Check the settings of a realm and its clients for common risks and print
+each finding with a severity (high, medium, low) and how to fix it:
+
+  - SSL not required
+  - no or a weak password policy
+  - wildcard redirect URIs and web origins
+  - implicit flow enabled
+  - direct access grants (the password grant) enabled
+
+Built-in clients are not checked unless --include-builtin is given.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.includeBuiltin, "include-builtin", false, "also check the clients Keycloak creates (account, admin-cli, ...)")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *auditSecurityOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	rep, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+
+	findings := realmFindings(rep)
+	for _, c := range clients {
+		if !o.includeBuiltin && manifest.IsBuiltinClient(realm, gocloak.PString(c.ClientID)) {
+			continue
+		}
+		findings = append(findings, clientFindings(c)...)
+	}
+	slices.SortStableFunc(findings, func(a, b securityFinding) int {
+		if c := slices.Index(securitySeverities, a.severity) - slices.Index(securitySeverities, b.severity); c != 0 {
+			return c
+		}
+		return strings.Compare(a.target, b.target)
+	})
+
+	t := &reportTable{columns: []string{"severity", "target", "finding", "remediation"}}
+	counts := map[string]int{}
+	for _, f := range findings {
+		t.rows = append(t.rows, []string{f.severity, f.target, f.finding, f.remediation})
+		counts[f.severity]++
+	}
+	summary := fmt.Sprintf("Findings: %d (high: %d, medium: %d, low: %d)", len(findings), counts["high"], counts["medium"], counts["low"])
+	if len(findings) == 0 && o.output.format == "text" && o.output.out == "" {
+		printBox(cmd, []string{"No risky settings found.", summary}, realm)
+		return nil
+	}
+	return o.output.print(cmd, t, realm, summary)
+}
+
+// realmFindings checks the SSL requirement and the password policy of a realm.
+func realmFindings(rep *gocloak.RealmRepresentation) []securityFinding {
+	var out []securityFinding
+	if strings.EqualFold(gocloak.PString(rep.SslRequired), "none") {
+		out = append(out, securityFinding{"high", "realm", "SSL is not required, so credentials and tokens may travel in clear text",
+			"set Require SSL to external requests (or all requests) in the realm settings"})
+	}
+	policy := gocloak.PString(rep.PasswordPolicy)
+	if strings.TrimSpace(policy) == "" {
+		return append(out, securityFinding{"high", "realm", "no password policy: any password is accepted",
+			"set a password policy with at least length(12), notUsername and passwordHistory"})
+	}
+	length := 0
+	for _, p := range strings.Split(policy, " and ") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(p), "(")
+		if name == "length" {
+			length, _ = strconv.Atoi(strings.TrimSuffix(arg, ")"))
+		}
+	}
+	switch {
+	case length == 0:
+		out = append(out, securityFinding{"medium", "realm", "the password policy sets no minimum length",
+			"add length(12) to the password policy"})
+	case length < 8:
+		out = append(out, securityFinding{"medium", "realm", fmt.Sprintf("the password policy allows passwords of %d characters", length),
+			"raise length in the password policy to 12 or more"})
+	}
+	return out
+}
+
+// clientFindings checks the redirect URIs, web origins and flows of a client.
+func clientFindings(c *gocloak.Client) []securityFinding {
+	target := "client:" + gocloak.PString(c.ClientID)
+	var out []securityFinding
+	for _, uri := range derefStrings(c.RedirectURIs) {
+		switch redirectWildcard(uri) {
+		case "any":
+			out = append(out, securityFinding{"high", target, fmt.Sprintf("redirect URI %q accepts any URL, so codes and tokens can be sent to an attacker", uri),
+				"list the exact callback URLs of the application"})
+		case "host":
+			out = append(out, securityFinding{"high", target, fmt.Sprintf("redirect URI %q has a wildcard in the host", uri),
+				"list each host explicitly"})
+		case "path":
+			out = append(out, securityFinding{"low", target, fmt.Sprintf("redirect URI %q has a wildcard in the path", uri),
+				"list the exact callback paths"})
+		}
+	}
+	if slices.Contains(derefStrings(c.WebOrigins), "*") {
+		out = append(out, securityFinding{"medium", target, `web origin "*" allows cross-origin requests from any site`,
+			`list the allowed origins, or use "+" to allow the origins of the redirect URIs`})
+	}
+	if gocloak.PBool(c.ImplicitFlowEnabled) {
+		out = append(out, securityFinding{"medium", target, "implicit flow is enabled; tokens are returned in the URL",
+			"disable the implicit flow and use the authorization code flow with PKCE"})
+	}
+	if gocloak.PBool(c.DirectAccessGrantsEnabled) && !gocloak.PBool(c.BearerOnly) {
+		kind := "confidential"
+		if gocloak.PBool(c.PublicClient) {
+			kind = "public"
+		}
+		out = append(out, securityFinding{"medium", target, fmt.Sprintf("direct access grants are enabled on a %s client; it can collect user passwords", kind),
+			"disable direct access grants unless a legacy integration needs the password grant"})
+	}
+	return out
+}
+
+// redirectWildcard tells where a redirect URI has a wildcard: "any" for a
+// bare *, "host" or "path", or "" when it has none.
+func redirectWildcard(uri string) string {
+	if !strings.Contains(uri, "*") {
+		return ""
+	}
+	if uri == "*" || uri == "/*" {
+		return "any"
+	}
+	_, rest, ok := strings.Cut(uri, "://")
+	if !ok {
+		// relative to the root URL of the client
+		return "path"
+	}
+	host, _, _ := strings.Cut(rest, "/")
+	if strings.Contains(host, "*") {
+		if host == "*" {
+			return "any"
+		}
+		return "host"
+	}
+	return "path"
+}
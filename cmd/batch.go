@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// batchOptions holds --workers and --rps of commands that create many entities.
+type batchOptions struct {
+	workers int
+	rps     float64
+}
+
+// addBatchFlags registers --workers and --rps, bound to o.
+func addBatchFlags(cmd *cobra.Command, o *batchOptions) {
+	cmd.Flags().IntVar(&o.workers, "workers", 1, "number of entities processed concurrently within a realm")
+	cmd.Flags().Float64Var(&o.rps, "rps", 0, "maximum API requests per second (0 = unlimited)")
+}
+
+// addContinueOnErrorFlag registers --continue-on-error, bound to p.
+func addContinueOnErrorFlag(cmd *cobra.Command, p *bool) {
+	cmd.Flags().BoolVar(p, "continue-on-error", false, "record failed items and go on with the remaining items and realms; exits non-zero with a failure report at the end")
+}
+
+// throttle applies the --rps flag of batch commands, overriding the global
+// --rate-limit for this invocation.
+func throttle(rps float64) {
+	if rps > 0 {
+		keycloak.SetRateLimit(rps, 1)
+	}
+}
+
+// addAtomicFlag registers --atomic on commands that create several entities.
+// withErrorEnd reads it back: when the command fails, rollbackCreated
+// deletes what the run created. It excludes --continue-on-error, so it must
+// be added after addContinueOnErrorFlag.
+func addAtomicFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("atomic", false, "if any item fails, delete the entities this run created (best effort) and fail")
+	cmd.MarkFlagsMutuallyExclusive("atomic", "continue-on-error")
+}
+
+// rollbackCreated deletes the entities the failed command reported as
+// created, newest first, and shows which ones it could not delete. The
+// deletions are recorded in the audit entry of the command.
+func rollbackCreated(cmd *cobra.Command) {
+	r := cmdState(cmd).report
+	if r == nil || len(r.result.Created) == 0 {
+		fmt.Fprintln(cmd.ErrOrStderr(), "--atomic: nothing was created, nothing to roll back.")
+		return
+	}
+	// a fresh deadline: the command may have failed on its own timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(cmd.Context()), 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "--atomic: cannot roll back, login failed: %v. Created and left in place: %d item(s).\n", err, len(r.result.Created))
+		return
+	}
+	created := r.result.Created
+	var lines, realms []string
+	left := 0
+	for i := len(created) - 1; i >= 0; i-- {
+		it := created[i]
+		if !slices.Contains(realms, it.Realm) {
+			realms = append(realms, it.Realm)
+		}
+		if err := deleteCreated(ctx, gc, token, it); err != nil {
+			left++
+			lines = append(lines, fmt.Sprintf("Could not roll back %s %q in realm %q: %v", it.Kind, it.Name, it.Realm, err))
+			continue
+		}
+		recordChange(cmd, it.Realm, it.Kind+" "+it.Name, it.ID)
+		it.Reason = "rolled back (--atomic)"
+		r.result.Deleted = append(r.result.Deleted, it)
+		r.track(kcops.Deleted, it)
+		lines = append(lines, fmt.Sprintf("Rolled back %s %q in realm %q.", it.Kind, it.Name, it.Realm))
+	}
+	summary := fmt.Sprintf("--atomic: the command failed; rolled back %d of %d created item(s).", len(created)-left, len(created))
+	if left > 0 {
+		summary += fmt.Sprintf(" %d left in place: delete them by hand.", left)
+	}
+	printBox(cmd, append(lines, summary), realmsLabel(cmd, realms))
+}
+
+// deleteCreated deletes an entity reported as created by a create command.
+func deleteCreated(ctx context.Context, gc keycloak.API, token string, it audit.ItemResult) error {
+	switch it.Kind {
+	case "user":
+		return gc.DeleteUser(ctx, token, it.Realm, it.ID)
+	case "role":
+		return gc.DeleteRealmRole(ctx, token, it.Realm, it.Name)
+	case "client":
+		return gc.DeleteClient(ctx, token, it.Realm, it.ID)
+	case "clientScope":
+		return gc.DeleteClientScope(ctx, token, it.Realm, it.ID)
+	case "clientRole":
+		cid, role, _ := strings.Cut(it.Name, "/")
+		c, err := getClientByClientID(ctx, gc, token, it.Realm, cid)
+		if err != nil || c == nil || c.ID == nil {
+			return fmt.Errorf("client %q not found", cid)
+		}
+		return gc.DeleteClientRole(ctx, token, it.Realm, *c.ID, role)
+	}
+	return fmt.Errorf("cannot roll back a %s", it.Kind)
+}
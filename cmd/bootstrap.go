@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+// blueprint is the file read by `kc bootstrap`: a realm with what a new
+// tenant needs. The entities use the fields of the apply manifest.
+type blueprint struct {
+	Realm        string                 `yaml:"realm"`
+	DisplayName  string                 `yaml:"displayName,omitempty"`
+	Roles        []manifest.Role        `yaml:"roles,omitempty"`
+	ClientScopes []manifest.ClientScope `yaml:"clientScopes,omitempty"`
+	Clients      []manifest.Client      `yaml:"clients,omitempty"`
+	Groups       []manifest.Group       `yaml:"groups,omitempty"`
+	Admin        *blueprintAdmin        `yaml:"admin,omitempty"`
+	// SMTP holds the smtpServer settings of the realm as Keycloak names
+	// them: host, port, from, fromDisplayName, auth, user, password, ssl,
+	// starttls.
+	SMTP   map[string]string `yaml:"smtp,omitempty"`
+	Themes blueprintThemes   `yaml:"themes,omitempty"`
+}
+
+// blueprintAdmin is the administrator of the new realm.
+type blueprintAdmin struct {
+	Username  string `yaml:"username"`
+	Email     string `yaml:"email,omitempty"`
+	FirstName string `yaml:"firstName,omitempty"`
+	LastName  string `yaml:"lastName,omitempty"`
+	// Password is generated when empty and the user is created.
+	Password string `yaml:"password,omitempty"`
+	// Roles are realm-management roles; realm-admin when empty.
+	Roles []string `yaml:"roles,omitempty"`
+}
+
+type blueprintThemes struct {
+	Login   string `yaml:"login,omitempty"`
+	Account string `yaml:"account,omitempty"`
+	Admin   string `yaml:"admin,omitempty"`
+	Email   string `yaml:"email,omitempty"`
+}
+
+// smtpMaskedPassword is what Keycloak returns instead of the SMTP password.
+const smtpMaskedPassword = "**********"
+
+// bootstrapOptions holds the flags of `kc bootstrap`.
+type bootstrapOptions struct {
+	file            string
+	continueOnError bool
+}
+
+func newBootstrapCmd() *cobra.Command {
+	o := &bootstrapOptions{}
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Set up a tenant realm from a blueprint: realm, clients, roles, groups, scopes, admin, SMTP and themes",
+		Long: `Create a realm with everything a new tenant needs from one blueprint file,
+in one run: client scopes, roles, clients, groups, an administrator holding
+realm-management roles (realm-admin by default), the SMTP settings and the
+themes. What already exists and matches is left alone, so running it again
+only applies the differences; nothing is ever deleted.
+
+Example blueprint:
+
+  realm: acme
+  displayName: Acme Corp
+  roles:
+    - name: billing-viewer
+  clientScopes:
+    - name: billing
+  clients:
+    - clientId: acme-portal
+      publicClient: true
+      redirectUris: [https://portal.acme.example/*]
+      webOrigins: ["+"]
+      defaultClientScopes: [billing]
+  groups:
+    - path: /finance
+      realmRoles: [billing-viewer]
+  admin:
+    username: acme-admin
+    email: it@acme.example
+  smtp:
+    host: smtp.acme.example
+    port: "587"
+    from: no-reply@acme.example
+    starttls: "true"
+  themes:
+    login: acme
+    email: acme
+
+A generated admin password is shown once in the summary.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm", "manage-users", "manage-clients")
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "blueprint (YAML or JSON). Required.")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *bootstrapOptions) run(cmd *cobra.Command) error {
+	if o.file == "" {
+		return errors.New("missing --file: provide the blueprint")
+	}
+	bp, err := loadBlueprint(o.file)
+	if err != nil {
+		return fmt.Errorf("invalid blueprint %s: %w", o.file, err)
+	}
+	state := bp.state()
+	if err := state.Validate(); err != nil {
+		return fmt.Errorf("invalid blueprint %s: %w", o.file, err)
+	}
+	realm := bp.Realm
+
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	actions, err := planManifest(ctx, gc, token, state, nil, false)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, []string{realm}); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	var generated string
+	if bp.Admin != nil && bp.Admin.Password == "" && slices.ContainsFunc(actions, func(a manifest.Action) bool {
+		return a.Kind == manifest.KindUser && a.Op == manifest.OpCreate
+	}) {
+		if generated, err = kcops.GeneratePassword(12); err != nil {
+			return err
+		}
+		state.Realms[0].Users[0].Password = generated
+		addSecret(cmd.Context(), generated)
+	}
+	if err := applyActions(ctx, gc, token, state, actions, rep, o.continueOnError); err != nil {
+		return err
+	}
+	if err := o.applySettings(ctx, gc, token, bp, rep); err != nil {
+		return err
+	}
+	if bp.Admin != nil {
+		if err := o.grantAdmin(ctx, cmd, gc, token, bp, rep); err != nil {
+			return err
+		}
+		if generated != "" && len(rep.result.Errors) == 0 {
+			rep.note(fmt.Sprintf("Password for admin user %q in realm %q: %s", bp.Admin.Username, realm, generated))
+		}
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Created: %d, Updated: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// loadBlueprint reads a blueprint, rejecting unknown fields so that typos
+// do not silently leave settings out.
+func loadBlueprint(file string) (*blueprint, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var bp blueprint
+	if err := dec.Decode(&bp); err != nil {
+		return nil, err
+	}
+	if bp.Realm == "" {
+		return nil, errors.New("missing realm")
+	}
+	if bp.Admin != nil && bp.Admin.Username == "" {
+		return nil, errors.New("admin without username")
+	}
+	return &bp, nil
+}
+
+// state is the part of the blueprint kc apply knows how to converge.
+func (bp *blueprint) state() *manifest.State {
+	r := manifest.Realm{
+		Name:         bp.Realm,
+		Enabled:      gocloak.BoolP(true),
+		DisplayName:  bp.DisplayName,
+		Roles:        bp.Roles,
+		ClientScopes: bp.ClientScopes,
+		Clients:      bp.Clients,
+		Groups:       bp.Groups,
+	}
+	if a := bp.Admin; a != nil {
+		r.Users = []manifest.User{{Username: a.Username, Email: a.Email, FirstName: a.FirstName, LastName: a.LastName, Password: a.Password}}
+	}
+	return &manifest.State{Realms: []manifest.Realm{r}}
+}
+
+// applySettings sets the SMTP server and themes of the realm when they
+// differ from the blueprint.
+func (o *bootstrapOptions) applySettings(ctx context.Context, gc keycloak.API, token string, bp *blueprint, rep *report) error {
+	if len(bp.SMTP) == 0 && bp.Themes == (blueprintThemes{}) {
+		return nil
+	}
+	realm := bp.Realm
+	current, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	update := gocloak.RealmRepresentation{Realm: &realm}
+	var fields []audit.FieldChange
+
+	if len(bp.SMTP) > 0 {
+		have := map[string]string{}
+		if current.SMTPServer != nil {
+			have = *current.SMTPServer
+		}
+		changed := false
+		for _, k := range slices.Sorted(maps.Keys(bp.SMTP)) {
+			v := bp.SMTP[k]
+			if have[k] == v || (k == "password" && have[k] == smtpMaskedPassword) {
+				continue
+			}
+			changed = true
+			if k == "password" {
+				fields = append(fields, audit.FieldChange{Field: "smtpServer.password", New: "(set)"})
+				continue
+			}
+			fields = append(fields, audit.FieldChange{Field: "smtpServer." + k, Old: have[k], New: v})
+		}
+		item := audit.ItemResult{Kind: "realmSmtp", Realm: realm, Name: realm}
+		if changed {
+			smtp := maps.Clone(have)
+			maps.Copy(smtp, bp.SMTP)
+			update.SMTPServer = &smtp
+			rep.add(kcops.Updated, item, fmt.Sprintf("Set the SMTP server of realm %q to %s.", realm, smtp["host"]))
+		} else {
+			rep.skip(item, "unchanged", fmt.Sprintf("SMTP server of realm %q already matches. Skipped.", realm))
+		}
+	}
+
+	want := map[string]string{"login": bp.Themes.Login, "account": bp.Themes.Account, "admin": bp.Themes.Admin, "email": bp.Themes.Email}
+	for _, t := range realmThemeFields(current, &update) {
+		if want[t.kind] == "" {
+			continue
+		}
+		item := audit.ItemResult{Kind: "realmTheme", Realm: realm, Name: t.field}
+		if old := gocloak.PString(*t.have); old != want[t.kind] {
+			*t.set = gocloak.StringP(want[t.kind])
+			fields = append(fields, audit.FieldChange{Field: t.field, Old: old, New: want[t.kind]})
+			rep.add(kcops.Updated, item, fmt.Sprintf("Set %s of realm %q to %s.", t.field, realm, want[t.kind]))
+		} else {
+			rep.skip(item, "unchanged", fmt.Sprintf("%s of realm %q is already %s. Skipped.", t.field, realm, want[t.kind]))
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	if err := gc.UpdateRealm(ctx, token, update); err != nil {
+		return fmt.Errorf("failed updating the settings of realm %s: %w", realm, err)
+	}
+	recordChangeAs(ctx, "realm_update", realm, realm, "", fields...)
+	return nil
+}
+
+// grantAdmin assigns the realm-management roles of the blueprint admin.
+func (o *bootstrapOptions) grantAdmin(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string, bp *blueprint, rep *report) error {
+	realm := bp.Realm
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &bp.Admin.Username, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return fmt.Errorf("failed looking up user %q in realm %s: %w", bp.Admin.Username, realm, err)
+	}
+	if len(users) == 0 {
+		// its creation failed and was reported with --continue-on-error
+		return nil
+	}
+	roles := bp.Admin.Roles
+	if len(roles) == 0 {
+		roles = []string{"realm-admin"}
+	}
+	_, err = assignAdminRoles(ctx, cmd, gc, token, realm, rep, adminPrincipal{kind: "user", name: bp.Admin.Username}, gocloak.PString(users[0].ID), roles)
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(newBootstrapCmd())
+}
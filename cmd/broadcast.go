@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// broadcastOptions holds the flags of `kc broadcast`.
+type broadcastOptions struct {
+	servers         []string
+	parallel        int
+	continueOnError bool
+}
+
+func newBroadcastCmd() *cobra.Command {
+	o := &broadcastOptions{}
+	cmd := &cobra.Command{
+		Use:   "broadcast --servers <profile>,... -- <command> [flags]",
+		Short: "Run the same command against several Keycloak servers",
+		Long: `Run the same kc command against several Keycloak servers, e.g. to keep the
+realms of regional clusters identical, and summarize the outcome per server.
+Each server is a profile: dev reads dev.json next to the config file (default
+is config.json), and a path such as ./eu/prod.json is used as is.
+
+The command runs as a separate kc process per server with --config set to its
+file, so each server logs and audits it in the files of its profile. Servers
+run one after another, stopping at the first failure unless
+--continue-on-error is set; --parallel runs up to N at a time and shows the
+output of each server when it is done. Only servers run one after another
+read stdin, e.g. the confirmation of --allow-protected.
+
+--jira and --output are passed on to every server; put other flags after --:
+
+  kc broadcast --servers eu,us,ap --jira OPS-42 -- apply -f realm.yaml
+  kc broadcast --servers eu,us,ap --parallel 3 -o json -- users list --realm acme`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, args)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.servers, "servers", nil, "profiles or config files of the servers, in order. Repeatable; required.")
+	cmd.Flags().IntVar(&o.parallel, "parallel", 1, "number of servers the command runs against at the same time")
+	cmd.Flags().BoolVar(&o.continueOnError, "continue-on-error", false, "run the command against the remaining servers after a failure")
+	return cmd
+}
+
+// serverRun is the outcome of the command against one server.
+type serverRun struct {
+	Server   string          `json:"server"`
+	Config   string          `json:"config"`
+	Status   string          `json:"status"`
+	ExitCode int             `json:"exit_code"`
+	Duration string          `json:"duration,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Output   string          `json:"output,omitempty"`
+}
+
+func (o *broadcastOptions) run(cmd *cobra.Command, args []string) error {
+	if len(o.servers) == 0 {
+		return errors.New("missing --servers: provide the profiles or config files of the servers")
+	}
+	if cmd.ArgsLenAtDash() != 0 {
+		return errors.New("put the command after --, e.g. kc broadcast --servers dev,prod -- users list")
+	}
+	if args[0] == "broadcast" {
+		return errors.New("kc broadcast cannot run itself")
+	}
+	if o.parallel < 1 {
+		return errors.New("invalid --parallel: must be at least 1")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed locating the kc executable: %w", err)
+	}
+	base := config.Path(cfgFile)
+	runs := make([]*serverRun, len(o.servers))
+	for i, s := range o.servers {
+		file := config.ProfileConfig(s, base)
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("no config file for server %q: %w", s, err)
+		}
+		runs[i] = &serverRun{Server: s, Config: file, Status: "skipped"}
+	}
+	var forwarded []string
+	if jira := cmdState(cmd).jira; jira != "" {
+		forwarded = append(forwarded, "--jira", jira)
+	}
+	if outputFormat != "text" {
+		forwarded = append(forwarded, "--output", outputFormat)
+	}
+
+	ctx, cancel := commandContext(cmd, 30*time.Minute)
+	defer cancel()
+	// child output only goes to the terminal: each server logs it already,
+	// without the secrets only that server knows of
+	live := o.parallel == 1 && outputFormat == "text"
+	var (
+		mu      sync.Mutex
+		stopped bool
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, o.parallel)
+	for _, r := range runs {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := stopped && !o.continueOnError
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(r *serverRun) {
+			defer func() { <-sem; wg.Done() }()
+			c := exec.CommandContext(ctx, exe, append(append([]string{"--config", r.Config}, forwarded...), args...)...)
+			var stdout, stderr bytes.Buffer
+			if live {
+				fmt.Fprintf(consoleOut, "=== %s (%s) ===\n", r.Server, r.Config)
+				c.Stdin, c.Stdout, c.Stderr = cmd.InOrStdin(), consoleOut, consoleErr
+			} else {
+				c.Stdout, c.Stderr = &stdout, &stderr
+			}
+			start := time.Now()
+			err := c.Run()
+			r.Duration = time.Since(start).Round(time.Millisecond).String()
+			r.Status = "ok"
+			if err != nil {
+				r.Status, r.Error, r.ExitCode = "error", err.Error(), -1
+				var exitErr *exec.ExitError
+				if errors.As(err, &exitErr) {
+					r.ExitCode = exitErr.ExitCode()
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				stopped = true
+			}
+			switch {
+			case live:
+			case outputFormat == "json":
+				if json.Valid(stdout.Bytes()) {
+					r.Result = json.RawMessage(bytes.TrimSpace(stdout.Bytes()))
+				} else {
+					r.Output = stdout.String()
+				}
+				consoleErr.Write(stderr.Bytes())
+			default:
+				fmt.Fprintf(consoleOut, "=== %s (%s) ===\n", r.Server, r.Config)
+				consoleOut.Write(stdout.Bytes())
+				consoleErr.Write(stderr.Bytes())
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	rep := newReport(cmd)
+	failed := 0
+	for _, r := range runs {
+		item := audit.ItemResult{Kind: "server", Name: r.Server}
+		switch r.Status {
+		case "ok":
+			rep.note(fmt.Sprintf("%s: ok (%s)", r.Server, r.Duration))
+		case "error":
+			failed++
+			rep.fail(item, fmt.Sprintf("%s: %s (%s)", r.Server, r.Error, r.Duration))
+		default:
+			rep.skip(item, "not run after a failure", fmt.Sprintf("%s: skipped, not run after a failure", r.Server))
+		}
+	}
+	if outputFormat == "json" {
+		// like the output of the servers, the results stay out of this log
+		enc := json.NewEncoder(consoleOut)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(runs); err != nil {
+			return err
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d server(s) failed", failed)
+		}
+		return nil
+	}
+	return rep.print(cmd, "", fmt.Sprintf("Ran %q against %d server(s): %d ok, %d failed, %d skipped.",
+		strings.Join(redactArgs(args), " "), len(runs), len(runs)-failed-len(rep.result.Skipped), failed, len(rep.result.Skipped)))
+}
+
+func init() {
+	rootCmd.AddCommand(newBroadcastCmd())
+}
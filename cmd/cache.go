@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionMu sync.Mutex
+	session   *kcops.Client
+)
+
+// opsClient returns the kcops client of this invocation. Its lookup cache is
+// shared by every command helper, so batches fetch each list once per realm.
+func opsClient(gc keycloak.API, token string) *kcops.Client {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	if session == nil || session.GC != gc || session.Token != token {
+		session = kcops.NewClient(gc, token)
+	}
+	return session
+}
+
+// listRealmNames returns the names of all realms.
+func listRealmNames(ctx context.Context, gc keycloak.API, token string) ([]string, error) {
+	return opsClient(gc, token).RealmNames(ctx)
+}
+
+func getClientByClientID(ctx context.Context, gc keycloak.API, token, realm, cid string) (*gocloak.Client, error) {
+	return opsClient(gc, token).ClientByClientID(ctx, realm, cid)
+}
+
+func findClientScopeByName(ctx context.Context, gc keycloak.API, token, realm, name string) (*gocloak.ClientScope, error) {
+	return opsClient(gc, token).ClientScopeByName(ctx, realm, name)
+}
+
+// recordResult adds the audit change of a kcops result, keeping the
+// representation read before updates and deletes for `kc undo`.
+func recordResult(cmd *cobra.Command, r kcops.Result) {
+	recordResultAs(cmd.Context(), resolveChangeKind(cmd.CommandPath()), r)
+}
+
+// recordResultAs is recordResult for callers without a command of their own,
+// recording in the run state of ctx.
+func recordResultAs(ctx context.Context, kind string, r kcops.Result) {
+	fields := make([]audit.FieldChange, 0, len(r.Fields))
+	for _, f := range r.Fields {
+		fields = append(fields, audit.FieldChange{Field: f.Field, Old: f.Old, New: f.New})
+	}
+	recordChangeAs(ctx, kind, r.Realm, r.Name, r.ID, fields...)
+	if r.Before != nil {
+		setBefore(ctx, r.Before)
+	}
+}
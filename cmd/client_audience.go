@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newClientsAudienceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audience",
+		Short: "Add or remove the audiences of the access tokens of clients",
+		Long: `Manage audience mappers: the access tokens a client gets name each of its
+audiences in the aud claim, so APIs checking aud accept them. add creates an
+oidc-audience-mapper per audience, named "audience <client-id>"; remove
+deletes every audience mapper of the client naming the audience.`,
+	}
+	cmd.AddCommand(newClientsAudienceSetCmd("add", "Add audience(s) to the access tokens of client(s)", false))
+	cmd.AddCommand(newClientsAudienceSetCmd("remove", "Remove audience(s) from the access tokens of client(s)", true))
+	return cmd
+}
+
+// clientsAudienceOptions holds the flags of `kc clients audience add` and
+// `kc clients audience remove`.
+type clientsAudienceOptions struct {
+	remove          bool
+	clientIDs       []string
+	audiences       []string
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newClientsAudienceSetCmd(use, short string, remove bool) *cobra.Command {
+	o := &clientsAudienceOptions{remove: remove}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s) whose tokens carry the audience. Repeatable; required.")
+	addStdinFlag(cmd, "client-id")
+	cmd.Flags().StringSliceVar(&o.audiences, "audience", nil, "client-id(s) of the audience, e.g. the API. Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *clientsAudienceOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 {
+		return errors.New("missing --client-id: provide at least one --client-id")
+	}
+	if len(o.audiences) == 0 {
+		return errors.New("missing --audience: provide at least one --audience")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		if !o.remove {
+			// a mapper naming an unknown client adds nothing to the tokens
+			for _, aud := range o.audiences {
+				if c, err := getClientByClientID(ctx, gc, token, realm, aud); err != nil || c == nil {
+					return fmt.Errorf("audience client %q not found in realm %s", aud, realm)
+				}
+			}
+		}
+		for _, cid := range o.clientIDs {
+			item := audit.ItemResult{Kind: "client", Realm: realm, Name: cid}
+			c, err := getClientByClientID(ctx, gc, token, realm, cid)
+			if err != nil || c == nil || c.ID == nil {
+				if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("client %q not found in realm %s", cid, realm)); err != nil {
+					return err
+				}
+				continue
+			}
+			item.ID = *c.ID
+			// the client may be cached; read its current mappers
+			if c, err = gc.GetClient(ctx, token, realm, item.ID); err != nil {
+				if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("failed reading client %q in realm %s: %w", cid, realm, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, aud := range o.audiences {
+				set := addAudience
+				if o.remove {
+					set = removeAudience
+				}
+				if err := set(cmd, rep, gc, token, c, item, aud); err != nil {
+					if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	if o.remove {
+		return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Removed: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Added: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
+}
+
+// audienceMappers returns the audience mappers of c naming aud.
+func audienceMappers(c *gocloak.Client, aud string) []gocloak.ProtocolMapperRepresentation {
+	if c.ProtocolMappers == nil {
+		return nil
+	}
+	var out []gocloak.ProtocolMapperRepresentation
+	for _, m := range *c.ProtocolMappers {
+		if gocloak.PString(m.ProtocolMapper) == "oidc-audience-mapper" && m.Config != nil && (*m.Config)["included.client.audience"] == aud {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// addAudience creates an audience mapper for aud on client c, unless c has one.
+func addAudience(cmd *cobra.Command, rep *report, gc keycloak.API, token string, c *gocloak.Client, item audit.ItemResult, aud string) error {
+	realm, cid := item.Realm, item.Name
+	if len(audienceMappers(c, aud)) > 0 {
+		rep.skip(item, "already set", fmt.Sprintf("Tokens of client %q in realm %q already carry audience %q. Skipped.", cid, realm, aud))
+		return nil
+	}
+	m := gocloak.ProtocolMapperRepresentation{
+		Name:           gocloak.StringP("audience " + aud),
+		Protocol:       gocloak.StringP("openid-connect"),
+		ProtocolMapper: gocloak.StringP("oidc-audience-mapper"),
+		Config: &map[string]string{
+			"included.client.audience":  aud,
+			"access.token.claim":        "true",
+			"introspection.token.claim": "true",
+			"id.token.claim":            "false",
+		},
+	}
+	id, err := gc.CreateClientProtocolMapper(cmd.Context(), token, realm, item.ID, m)
+	if err != nil {
+		return fmt.Errorf("failed adding audience %q to client %q in realm %s: %w", aud, cid, realm, err)
+	}
+	recordChange(cmd, realm, fmt.Sprintf("client %s mapper %s", cid, *m.Name), id, appendFieldChange(nil, "included.client.audience", nil, &aud)...)
+	rep.add(kcops.Created, item, fmt.Sprintf("Added audience %q to the tokens of client %q in realm %q.", aud, cid, realm))
+	return nil
+}
+
+// removeAudience deletes the audience mappers of client c naming aud.
+func removeAudience(cmd *cobra.Command, rep *report, gc keycloak.API, token string, c *gocloak.Client, item audit.ItemResult, aud string) error {
+	realm, cid := item.Realm, item.Name
+	mappers := audienceMappers(c, aud)
+	if len(mappers) == 0 {
+		rep.skip(item, "not set", fmt.Sprintf("Tokens of client %q in realm %q do not carry audience %q. Skipped.", cid, realm, aud))
+		return nil
+	}
+	for _, m := range mappers {
+		if err := gc.DeleteClientProtocolMapper(cmd.Context(), token, realm, item.ID, gocloak.PString(m.ID)); err != nil {
+			return fmt.Errorf("failed removing audience mapper %q from client %q in realm %s: %w", gocloak.PString(m.Name), cid, realm, err)
+		}
+		recordChange(cmd, realm, fmt.Sprintf("client %s mapper %s", cid, gocloak.PString(m.Name)), gocloak.PString(m.ID), appendFieldChange(nil, "included.client.audience", &aud, gocloak.StringP(""))...)
+	}
+	rep.add(kcops.Deleted, item, fmt.Sprintf("Removed audience %q from the tokens of client %q in realm %q.", aud, cid, realm))
+	return nil
+}
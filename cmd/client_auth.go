@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// clientAuthFlags holds the flags that set how confidential clients
+// authenticate, shared by `kc clients create` and `kc clients update`.
+type clientAuthFlags struct {
+	methods    []string
+	jwksURLs   []string
+	jwksFiles  []string
+	certFiles  []string
+	subjectDNs []string
+}
+
+// clientAuthFlagNames are the flags of clientAuthFlags.
+var clientAuthFlagNames = []string{"auth-method", "jwks-url", "jwks-file", "cert-file", "tls-subject-dn"}
+
+func (f *clientAuthFlags) add(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.methods, "auth-method", nil, "client authentication: client-secret, private-key-jwt or tls-client-auth. Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&f.jwksURLs, "jwks-url", nil, "private-key-jwt: URL of the client's JWKS. Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&f.jwksFiles, "jwks-file", nil, "private-key-jwt: JWKS file to upload. Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&f.certFiles, "cert-file", nil, "private-key-jwt: PEM certificate file to upload. Optional; 0, 1 or N")
+	// a DN has commas, so it is not split on them
+	cmd.Flags().StringArrayVar(&f.subjectDNs, "tls-subject-dn", nil, "tls-client-auth: subject DN of the client certificate, e.g. CN=billing,O=Acme. Optional; 0, 1 or N")
+}
+
+// given reports whether any of the flags was set.
+func (f *clientAuthFlags) given() bool {
+	return len(f.methods) > 0 || len(f.jwksURLs) > 0 || len(f.jwksFiles) > 0 || len(f.certFiles) > 0 || len(f.subjectDNs) > 0
+}
+
+// build reads the uploaded files and validates the settings before any API
+// call. The result follows the 0, 1 or N rule of the flags, for pick.
+func (f *clientAuthFlags) build() ([]*kcops.ClientAuth, error) {
+	if !f.given() {
+		return nil, nil
+	}
+	if len(f.methods) == 0 {
+		return nil, errors.New("missing --auth-method: --jwks-url, --jwks-file, --cert-file and --tls-subject-dn need it")
+	}
+	n := max(len(f.methods), len(f.jwksURLs), len(f.jwksFiles), len(f.certFiles), len(f.subjectDNs))
+	auths := make([]*kcops.ClientAuth, n)
+	for i := range auths {
+		a := &kcops.ClientAuth{}
+		a.Method, _ = pick(f.methods, i)
+		a.JWKSURL, _ = pick(f.jwksURLs, i)
+		a.SubjectDN, _ = pick(f.subjectDNs, i)
+		if file, ok := pick(f.jwksFiles, i); ok {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading --jwks-file %s: %w", file, err)
+			}
+			a.JWKS = strings.TrimSpace(string(b))
+		}
+		if file, ok := pick(f.certFiles, i); ok {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading --cert-file %s: %w", file, err)
+			}
+			a.Certificate = string(b)
+		}
+		if err := a.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid --auth-method %s: %w", a.Method, err)
+		}
+		auths[i] = a
+	}
+	return auths, nil
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newClientsKeysCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Show, upload and rotate the keys of private-key-jwt clients",
+		Long: `Manage the public keys Keycloak verifies the signed JWTs of private-key-jwt
+clients with: a JWKS URL, a stored JWKS or a stored certificate. rotate is
+upload for scheduled automation: it fails when the client has no keys yet or
+already has the new ones, and the previous keys can be restored with
+kc undo.`,
+	}
+	cmd.AddCommand(newClientsKeysShowCmd())
+	cmd.AddCommand(newClientsKeysSetCmd("upload", "Upload keys to client(s)", false))
+	cmd.AddCommand(newClientsKeysSetCmd("rotate", "Replace the keys of client(s)", true))
+	return cmd
+}
+
+// clientsKeysShowOptions holds the flags of `kc clients keys show`.
+type clientsKeysShowOptions struct {
+	clientIDs []string
+	realms    []string
+	allRealms bool
+}
+
+// clientKeysInfo is the JSON output of `kc clients keys show`.
+type clientKeysInfo struct {
+	Realm         string `json:"realm"`
+	ClientID      string `json:"clientId"`
+	Authenticator string `json:"clientAuthenticatorType"`
+	Keys          string `json:"keys"`
+}
+
+func newClientsKeysShowCmd() *cobra.Command {
+	o := &clientsKeysShowOptions{}
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show the keys of client(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *clientsKeysShowOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 {
+		return errors.New("missing --client-id: provide at least one --client-id")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	ops := opsClient(gc, token)
+	var infos []clientKeysInfo
+	for _, realm := range realms {
+		for _, cid := range o.clientIDs {
+			cl, err := ops.ClientByClientID(ctx, realm, cid)
+			if err != nil || cl == nil {
+				return fmt.Errorf("client %q not found in realm %s", cid, realm)
+			}
+			info := clientKeysInfo{Realm: realm, ClientID: cid, Authenticator: gocloak.PString(cl.ClientAuthenticatorType)}
+			if cl.Attributes != nil {
+				info.Keys = kcops.DescribeClientKeys(*cl.Attributes)
+			}
+			infos = append(infos, info)
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, infos)
+	}
+	lines := make([]string, 0, len(infos))
+	for _, i := range infos {
+		keys := i.Keys
+		if keys == "" {
+			keys = "no keys"
+		}
+		lines = append(lines, fmt.Sprintf("%s/%s (%s): %s", i.Realm, i.ClientID, i.Authenticator, keys))
+	}
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+	return nil
+}
+
+// clientsKeysSetOptions holds the flags of `kc clients keys upload` and
+// `kc clients keys rotate`.
+type clientsKeysSetOptions struct {
+	rotate          bool
+	clientIDs       []string
+	cert            string
+	jwksFile        string
+	jwksURL         string
+	ignoreMissing   bool
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newClientsKeysSetCmd(use, short string, rotate bool) *cobra.Command {
+	o := &clientsKeysSetOptions{rotate: rotate}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s). Repeatable; required.")
+	addStdinFlag(cmd, "client-id")
+	cmd.Flags().StringVar(&o.cert, "cert", "", "PEM certificate file to store in the client")
+	cmd.Flags().StringVar(&o.jwksFile, "jwks-file", "", "JWKS file to store in the client")
+	cmd.Flags().StringVar(&o.jwksURL, "jwks-url", "", "URL Keycloak fetches the client's JWKS from")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip clients not found instead of failing")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+// keys reads the key files given and validates the keys before any API call.
+func (o *clientsKeysSetOptions) keys() (kcops.ClientKeys, error) {
+	keys := kcops.ClientKeys{JWKSURL: o.jwksURL}
+	if o.cert != "" {
+		b, err := os.ReadFile(o.cert)
+		if err != nil {
+			return keys, fmt.Errorf("failed reading --cert %s: %w", o.cert, err)
+		}
+		keys.Certificate = string(b)
+	}
+	if o.jwksFile != "" {
+		b, err := os.ReadFile(o.jwksFile)
+		if err != nil {
+			return keys, fmt.Errorf("failed reading --jwks-file %s: %w", o.jwksFile, err)
+		}
+		keys.JWKS = strings.TrimSpace(string(b))
+	}
+	if err := keys.Validate(); err != nil {
+		return keys, fmt.Errorf("invalid keys (--cert, --jwks-file or --jwks-url): %w", err)
+	}
+	return keys, nil
+}
+
+func (o *clientsKeysSetOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 {
+		return errors.New("missing --client-id: provide at least one --client-id")
+	}
+	keys, err := o.keys()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	verb := "Uploaded"
+	if o.rotate {
+		verb = "Rotated"
+	}
+	for _, realm := range realms {
+		results, err := kcops.SetClientKeys(ctx, ops, kcops.SetClientKeysRequest{Realm: realm, ClientIDs: o.clientIDs, Keys: keys, Rotate: o.rotate, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("client", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				reason, line := "not found", fmt.Sprintf("Client %q not found in realm %q. Skipped.", r.Name, realm)
+				if r.ID != "" {
+					reason, line = "unchanged", fmt.Sprintf("Client %q in realm %q already has these keys. Skipped.", r.Name, realm)
+				}
+				rep.skip(opsItem("client", r), reason, line)
+				continue
+			}
+			printWarnings(cmd, r)
+			recordResult(cmd, r)
+			line := fmt.Sprintf("%s the keys of client %q in realm %q: %s.", verb, r.Name, realm, r.Fields[0].New)
+			if old := r.Fields[0].Old; old != "" {
+				line = fmt.Sprintf("%s the keys of client %q in realm %q: %s, was %s.", verb, r.Name, realm, r.Fields[0].New, old)
+			}
+			rep.add(kcops.Updated, opsItem("client", r), line)
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
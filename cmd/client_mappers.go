@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// claimTokens maps the values of --token to the mapper settings adding a
+// claim to that token.
+var claimTokens = map[string]string{
+	"access":        "access.token.claim",
+	"id":            "id.token.claim",
+	"userinfo":      "userinfo.token.claim",
+	"introspection": "introspection.token.claim",
+}
+
+// claimJSONTypes are the claim types of --json-type, as Keycloak names them.
+var claimJSONTypes = []string{"String", "long", "int", "boolean", "JSON"}
+
+func newClientsMappersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mappers",
+		Short: "Add protocol mappers to clients",
+	}
+	cmd.AddCommand(newClientsMappersAddClaimCmd())
+	return cmd
+}
+
+// clientsMappersAddClaimOptions holds the flags of `kc clients mappers add-claim`.
+type clientsMappersAddClaimOptions struct {
+	clientIDs       []string
+	claims          []string
+	tokens          []string
+	jsonType        string
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newClientsMappersAddClaimCmd() *cobra.Command {
+	o := &clientsMappersAddClaimOptions{}
+	cmd := &cobra.Command{
+		Use:   "add-claim",
+		Short: "Add a claim with a fixed value to the tokens of client(s)",
+		Long: `Add a hardcoded claim mapper to each client for every --claim name=value,
+so the tokens the client gets carry that claim. The mapper is named after
+the claim; when the client already has it with another value or tokens, it
+is updated, and when it already matches, skipped. A mapper of another type
+with the same name fails the client.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s). Repeatable; required.")
+	addStdinFlag(cmd, "client-id")
+	cmd.Flags().StringArrayVar(&o.claims, "claim", nil, "claim to add, as name=value. Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.tokens, "token", []string{"access", "id"}, "tokens to add the claims to: access|id|userinfo|introspection")
+	cmd.Flags().StringVar(&o.jsonType, "json-type", "String", "type of the claim values: "+strings.Join(claimJSONTypes, "|"))
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+// mappers builds the mappers of --claim, validating the flags before any
+// API call.
+func (o *clientsMappersAddClaimOptions) mappers() ([]gocloak.ProtocolMapperRepresentation, error) {
+	if len(o.clientIDs) == 0 {
+		return nil, errors.New("missing --client-id: provide at least one --client-id")
+	}
+	if len(o.claims) == 0 {
+		return nil, errors.New("missing --claim: provide at least one --claim name=value")
+	}
+	if !slices.Contains(claimJSONTypes, o.jsonType) {
+		return nil, fmt.Errorf("invalid --json-type %q: must be one of %s", o.jsonType, strings.Join(claimJSONTypes, ", "))
+	}
+	if len(o.tokens) == 0 {
+		return nil, errors.New("missing --token: name at least one token")
+	}
+	for _, t := range o.tokens {
+		if _, ok := claimTokens[t]; !ok {
+			return nil, fmt.Errorf("invalid --token %q: must be access, id, userinfo or introspection", t)
+		}
+	}
+	var out []gocloak.ProtocolMapperRepresentation
+	for _, c := range o.claims {
+		name, value, ok := strings.Cut(c, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid --claim %q: use name=value", c)
+		}
+		if slices.ContainsFunc(out, func(m gocloak.ProtocolMapperRepresentation) bool { return *m.Name == name }) {
+			return nil, fmt.Errorf("invalid --claim: claim %q given twice", name)
+		}
+		config := map[string]string{"claim.name": name, "claim.value": value, "jsonType.label": o.jsonType}
+		for t, key := range claimTokens {
+			config[key] = fmt.Sprint(slices.Contains(o.tokens, t))
+		}
+		out = append(out, gocloak.ProtocolMapperRepresentation{
+			Name:           gocloak.StringP(name),
+			Protocol:       gocloak.StringP("openid-connect"),
+			ProtocolMapper: gocloak.StringP("oidc-hardcoded-claim-mapper"),
+			Config:         &config,
+		})
+	}
+	return out, nil
+}
+
+func (o *clientsMappersAddClaimOptions) run(cmd *cobra.Command) error {
+	mappers, err := o.mappers()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		for _, cid := range o.clientIDs {
+			item := audit.ItemResult{Kind: "client", Realm: realm, Name: cid}
+			c, err := getClientByClientID(ctx, gc, token, realm, cid)
+			if err != nil || c == nil || c.ID == nil {
+				if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("client %q not found in realm %s", cid, realm)); err != nil {
+					return err
+				}
+				continue
+			}
+			item.ID = *c.ID
+			// the client may be cached; read its current mappers
+			if c, err = gc.GetClient(ctx, token, realm, item.ID); err != nil {
+				if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("failed reading client %q in realm %s: %w", cid, realm, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, m := range mappers {
+				if err := addClaimMapper(cmd, rep, gc, token, c, item, m); err != nil {
+					if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Added: %d, Updated: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// addClaimMapper creates mapper m on client c, or updates the mapper of c
+// with its name.
+func addClaimMapper(cmd *cobra.Command, rep *report, gc keycloak.API, token string, c *gocloak.Client, item audit.ItemResult, m gocloak.ProtocolMapperRepresentation) error {
+	ctx := cmd.Context()
+	realm, cid, name := item.Realm, item.Name, *m.Name
+	entity := fmt.Sprintf("client %s mapper %s", cid, name)
+	value := (*m.Config)["claim.value"]
+	var existing *gocloak.ProtocolMapperRepresentation
+	if c.ProtocolMappers != nil {
+		for i := range *c.ProtocolMappers {
+			if gocloak.PString((*c.ProtocolMappers)[i].Name) == name {
+				existing = &(*c.ProtocolMappers)[i]
+			}
+		}
+	}
+	if existing == nil {
+		id, err := gc.CreateClientProtocolMapper(ctx, token, realm, item.ID, m)
+		if err != nil {
+			return fmt.Errorf("failed adding claim %q to client %q in realm %s: %w", name, cid, realm, err)
+		}
+		recordChange(cmd, realm, entity, id, appendFieldChange(nil, "claim.value", nil, &value)...)
+		rep.add(kcops.Created, item, fmt.Sprintf("Added claim %q = %q to client %q in realm %q.", name, value, cid, realm))
+		return nil
+	}
+	if t := gocloak.PString(existing.ProtocolMapper); t != "oidc-hardcoded-claim-mapper" {
+		return fmt.Errorf("client %q in realm %s already has a %s mapper named %q", cid, realm, t, name)
+	}
+	old := map[string]string{}
+	if existing.Config != nil {
+		old = *existing.Config
+	}
+	// settings the flags do not cover, such as lightweight.claim, are kept
+	config := maps.Clone(old)
+	maps.Copy(config, *m.Config)
+	if maps.Equal(old, config) {
+		rep.skip(item, "already set", fmt.Sprintf("Client %q in realm %q already has claim %q = %q. Skipped.", cid, realm, name, value))
+		return nil
+	}
+	m.ID = existing.ID
+	m.Config = &config
+	if err := gc.UpdateClientProtocolMapper(ctx, token, realm, item.ID, *existing.ID, m); err != nil {
+		return fmt.Errorf("failed updating claim %q of client %q in realm %s: %w", name, cid, realm, err)
+	}
+	var fields []audit.FieldChange
+	for _, k := range slices.Sorted(maps.Keys(config)) {
+		if o, ok := old[k]; !ok || o != config[k] {
+			v := config[k]
+			var before *string
+			if ok {
+				before = &o
+			}
+			fields = appendFieldChange(fields, k, before, &v)
+		}
+	}
+	recordChange(cmd, realm, entity, *existing.ID, fields...)
+	rep.add(kcops.Updated, item, fmt.Sprintf("Updated claim %q = %q of client %q in realm %q.", name, value, cid, realm))
+	return nil
+}
@@ -1,142 +1,146 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"kc/internal/config"
-	"kc/internal/keycloak"
+	"kc/internal/audit"
+	"kc/pkg/kcops"
 
 	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var (
-	clientRolesNames        []string
-	clientRolesDescriptions []string
-	clientRolesAllRealms    bool
-	clientRolesRealm        string
-	clientRolesClientID     string
-)
+func newClientRolesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "client-roles",
+		Short: "Manage client roles",
+	}
+	cmd.AddCommand(newClientRolesCreateCmd())
+	return cmd
+}
 
-var clientRolesCmd = &cobra.Command{
-	Use:   "client-roles",
-	Short: "Manage client roles",
+// clientRolesCreateOptions holds the flags of `kc client-roles create`.
+type clientRolesCreateOptions struct {
+	names           []string
+	descriptions    []string
+	allRealms       bool
+	realm           string
+	clientID        string
+	continueOnError bool
 }
 
-var clientRolesCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create client role(s) in a client",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if clientRolesClientID == "" {
-			return errors.New("missing --client-id: target client-id is required")
-		}
-		if len(clientRolesNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		if !(len(clientRolesDescriptions) == 0 || len(clientRolesDescriptions) == 1 || len(clientRolesDescriptions) == len(clientRolesNames)) {
-			return fmt.Errorf("invalid descriptions: when using multiple --name flags, you must pass either no --description, a single --description to apply to all, or one --description per --name (in order)")
-		}
+func newClientRolesCreateCmd() *cobra.Command {
+	o := &clientRolesCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create client role(s) in a client",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "target client-id (required)")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "client role name(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.descriptions, "description", nil, "client role description(s). Pass none, one (applies to all), or one per --name in order.")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "create client role in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	addAtomicFlag(cmd)
+	return cmd
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
-		}
+func (o *clientRolesCreateOptions) run(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client-id: target client-id is required")
+	}
+	if len(o.names) == 0 {
+		return errors.New("missing --name: provide at least one --name")
+	}
+	if !(len(o.descriptions) == 0 || len(o.descriptions) == 1 || len(o.descriptions) == len(o.names)) {
+		return fmt.Errorf("invalid descriptions: when using multiple --name flags, you must pass either no --description, a single --description to apply to all, or one --description per --name (in order)")
+	}
 
-		var targetRealms []string
-		if clientRolesAllRealms {
-			realms, err := gc.GetRealms(ctx, token)
-			if err != nil {
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetRealms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "client ID", []string{o.clientID}); err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "client role name", o.names); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	rep.expect(len(o.names) * len(targetRealms))
+	for _, realm := range targetRealms {
+		c, err := getClientByClientID(ctx, gc, token, realm, o.clientID)
+		if err != nil || c == nil || c.ID == nil {
+			err := fmt.Errorf("client %q not found in realm %s", o.clientID, realm)
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: o.clientID}, err); err != nil {
 				return err
 			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
-			}
-		} else {
-			r := clientRolesRealm
-			if r == "" {
-				r = defaultRealm
-			}
-			if r == "" {
-				r = config.Global.Realm
-			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
-			}
-			targetRealms = []string{r}
+			continue
+		}
+		clientID := *c.ID
+		item := func(role string) audit.ItemResult {
+			return audit.ItemResult{Kind: "clientRole", Realm: realm, Name: o.clientID + "/" + role}
 		}
 
-		created := 0
-		skipped := 0
-		var lines []string
-		for _, realm := range targetRealms {
-			c, err := getClientByClientID(ctx, gc, token, realm, clientRolesClientID)
-			if err != nil || c == nil || c.ID == nil {
-				return fmt.Errorf("client %q not found in realm %s", clientRolesClientID, realm)
+		for i, rn := range o.names {
+			_, err := gc.GetClientRole(ctx, token, realm, clientID, rn)
+			if err == nil {
+				rep.skip(item(rn), "already exists", fmt.Sprintf("Client role %q already exists in client %q (realm %q). Skipped.", rn, o.clientID, realm))
+				continue
 			}
-			clientID := *c.ID
-
-			for i, rn := range clientRolesNames {
-				_, err := gc.GetClientRole(ctx, token, realm, clientID, rn)
-				if err == nil {
-					lines = append(lines, fmt.Sprintf("Client role %q already exists in client %q (realm %q). Skipped.", rn, clientRolesClientID, realm))
-					skipped++
-					continue
-				}
-				if !strings.Contains(strings.ToLower(err.Error()), "404") {
-					return fmt.Errorf("failed checking client role in client %s, realm %s: %w", clientRolesClientID, realm, err)
+			if !strings.Contains(strings.ToLower(err.Error()), "404") {
+				err := fmt.Errorf("failed checking client role in client %s, realm %s: %w", o.clientID, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item(rn), err); err != nil {
+					return err
 				}
+				continue
+			}
 
-				name := rn
-				var desc string
-				if len(clientRolesDescriptions) == 1 {
-					desc = clientRolesDescriptions[0]
-				} else if len(clientRolesDescriptions) == len(clientRolesNames) {
-					desc = clientRolesDescriptions[i]
-				} else {
-					desc = ""
-				}
+			name := rn
+			var desc string
+			if len(o.descriptions) == 1 {
+				desc = o.descriptions[0]
+			} else if len(o.descriptions) == len(o.names) {
+				desc = o.descriptions[i]
+			} else {
+				desc = ""
+			}
 
-				_, err = gc.CreateClientRole(ctx, token, realm, clientID, gocloak.Role{
-					Name:        &name,
-					Description: &desc,
-				})
-				if err != nil {
-					return fmt.Errorf("failed creating client role %q in client %s, realm %s: %w", rn, clientRolesClientID, realm, err)
+			_, err = gc.CreateClientRole(ctx, token, realm, clientID, gocloak.Role{
+				Name:        &name,
+				Description: &desc,
+			})
+			if err != nil {
+				err := fmt.Errorf("failed creating client role %q in client %s, realm %s: %w", rn, o.clientID, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item(rn), err); err != nil {
+					return err
 				}
-				lines = append(lines, fmt.Sprintf("Created client role %q in client %q (realm %q).", rn, clientRolesClientID, realm))
-				created++
+				continue
 			}
+			recordChange(cmd, realm, o.clientID+"/"+rn, "", appendFieldChange(nil, "description", nil, &desc)...)
+			rep.add(kcops.Created, item(rn), fmt.Sprintf("Created client role %q in client %q (realm %q).", rn, o.clientID, realm))
 		}
+	}
 
-		lines = append(lines, fmt.Sprintf("Done. Created: %d, Skipped: %d.", created, skipped))
-		realmLabel := ""
-		if clientRolesAllRealms {
-			realmLabel = "all realms"
-		} else if clientRolesRealm != "" {
-			realmLabel = clientRolesRealm
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
-		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Created: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
 }
 
 func init() {
-	rootCmd.AddCommand(clientRolesCmd)
-
-	clientRolesCmd.AddCommand(clientRolesCreateCmd)
-	clientRolesCreateCmd.Flags().StringVar(&clientRolesClientID, "client-id", "", "target client-id (required)")
-	clientRolesCreateCmd.Flags().StringSliceVar(&clientRolesNames, "name", nil, "client role name(s). Repeatable; required.")
-	clientRolesCreateCmd.Flags().StringSliceVar(&clientRolesDescriptions, "description", nil, "client role description(s). Pass none, one (applies to all), or one per --name in order.")
-	clientRolesCreateCmd.Flags().BoolVar(&clientRolesAllRealms, "all-realms", false, "create client role in all realms")
-	clientRolesCreateCmd.Flags().StringVar(&clientRolesRealm, "realm", "", "target realm")
+	rootCmd.AddCommand(newClientRolesCmd())
 }
@@ -1,355 +1,384 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"time"
 
-	"kc/internal/config"
-	"kc/internal/keycloak"
+	"kc/pkg/kcops"
 
-	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var (
-	csNames        []string
-	csDescriptions []string
-	csProtocols    []string
-	csNewNames     []string
-	csAllRealms    bool
-	csRealm        string
-	csIgnoreMiss   bool
-)
+func newClientScopesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "client-scopes",
+		Short: "Manage client scopes",
+	}
+	cmd.AddCommand(newClientScopesCreateCmd())
+	cmd.AddCommand(newClientScopesUpdateCmd())
+	cmd.AddCommand(newClientScopesDeleteCmd())
+	cmd.AddCommand(newClientScopesListCmd())
+	return cmd
+}
 
-var clientScopesCmd = &cobra.Command{
-	Use:   "client-scopes",
-	Short: "Manage client scopes",
+// clientScopesCreateOptions holds the flags of `kc client-scopes create`.
+type clientScopesCreateOptions struct {
+	names           []string
+	descriptions    []string
+	protocols       []string
+	withMappers     []string
+	allRealms       bool
+	realm           string
+	template        templateOptions
+	continueOnError bool
 }
 
-func resolveCSRealms() ([]string, error) {
-	if csAllRealms {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return nil, err
-		}
-		rs, err := gc.GetRealms(ctx, token)
-		if err != nil {
-			return nil, err
-		}
-		var out []string
-		for _, r := range rs {
-			if r.Realm != nil {
-				out = append(out, *r.Realm)
-			}
-		}
-		return out, nil
+func newClientScopesCreateCmd() *cobra.Command {
+	o := &clientScopesCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create client scope(s)",
+		Long: `Create client scopes. --with-mappers adds protocol mappers from named
+templates to every scope created, in the same request:
+
+  audience:<client>        adds <client> to the aud claim of access tokens
+  groups                   group names in the groups claim
+  realm-roles              realm roles in realm_access.roles
+  client-roles[:<client>]  client roles in resource_access.<client>.roles,
+                           of one client or of all
+  attribute:<name>         the user attribute <name> in the claim <name>
+
+The templates are openid-connect mappers; they cannot be added to saml
+scopes.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "client scope name(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.descriptions, "description", nil, "description(s). Optional; 0,1 or N")
+	cmd.Flags().StringSliceVar(&o.protocols, "protocol", nil, "protocol(s). Optional; 0,1 or N; default openid-connect")
+	cmd.Flags().StringSliceVar(&o.withMappers, "with-mappers", nil, "protocol mappers to add to each scope: "+mapperTemplateUsage()+". Repeatable")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "create in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addTemplateFlags(cmd, &o.template)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	addAtomicFlag(cmd)
+	return cmd
+}
+
+func (o *clientScopesCreateOptions) run(cmd *cobra.Command) error {
+	tplSpecs, err := templateSpecs(cmd, o.template, "name", func(s kcops.ClientScopeSpec) string { return s.Name }, "name", "description", "protocol")
+	if err != nil {
+		return err
 	}
-	r := csRealm
-	if r == "" {
-		r = defaultRealm
+	if len(o.names) == 0 && tplSpecs == nil {
+		return errors.New("missing --name: provide at least one --name or --template")
 	}
-	if r == "" {
-		r = config.Global.Realm
+	if !(len(o.descriptions) == 0 || len(o.descriptions) == 1 || len(o.descriptions) == len(o.names)) {
+		return fmt.Errorf("invalid descriptions: pass none, one (applies to all), or one per --name")
 	}
-	if r == "" {
-		return nil, errors.New("target realm not specified. Use --realm or set realm in config.json")
+	if !(len(o.protocols) == 0 || len(o.protocols) == 1 || len(o.protocols) == len(o.names)) {
+		return fmt.Errorf("invalid protocols: pass none, one (applies to all), or one per --name")
 	}
-	return []string{r}, nil
-}
-
-func findClientScopeByName(ctx context.Context, gc *gocloak.GoCloak, token, realm, name string) (*gocloak.ClientScope, error) {
-	scopes, err := gc.GetClientScopes(ctx, token, realm)
+	mappers, err := parseMapperTemplates(o.withMappers)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, s := range scopes {
-		if s.Name != nil && *s.Name == name {
-			return s, nil
-		}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
 	}
-	return nil, fmt.Errorf("client scope %q not found", name)
-}
-
-var clientScopesCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create client scope(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(csNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		if !(len(csDescriptions) == 0 || len(csDescriptions) == 1 || len(csDescriptions) == len(csNames)) {
-			return fmt.Errorf("invalid descriptions: pass none, one (applies to all), or one per --name")
-		}
-		if !(len(csProtocols) == 0 || len(csProtocols) == 1 || len(csProtocols) == len(csNames)) {
-			return fmt.Errorf("invalid protocols: pass none, one (applies to all), or one per --name")
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	specs := make([]kcops.ClientScopeSpec, len(o.names))
+	for i, n := range o.names {
+		specs[i] = kcops.ClientScopeSpec{Name: n}
+		specs[i].Description, _ = pick(o.descriptions, i)
+		specs[i].Protocol, _ = pick(o.protocols, i)
+	}
+	if tplSpecs != nil {
+		specs = tplSpecs
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+	if err := rejectQualified(ctx, gc, token, "client scope name", names); err != nil {
+		return err
+	}
+	if len(mappers) > 0 {
+		for i := range specs {
+			if p := specs[i].Protocol; p != "" && p != "openid-connect" {
+				return fmt.Errorf("--with-mappers adds openid-connect mappers; client scope %q uses protocol %s", specs[i].Name, p)
+			}
+			specs[i].Mappers = mappers
 		}
-		realms, err := resolveCSRealms()
+	}
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	rep.expect(len(specs) * len(realms))
+	for _, realm := range realms {
+		specs, err := forRealm(specs, realm)
 		if err != nil {
 			return err
 		}
-		created, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for i, n := range csNames {
-				// exists?
-				if _, err := findClientScopeByName(ctx, gc, token, realm, n); err == nil {
-					lines = append(lines, fmt.Sprintf("Client scope %q already exists in realm %q. Skipped.", n, realm))
-					skipped++
-					continue
-				}
-				desc := ""
-				if len(csDescriptions) == 1 {
-					desc = csDescriptions[0]
-				} else if len(csDescriptions) == len(csNames) {
-					desc = csDescriptions[i]
-				}
-				protocol := ""
-				if len(csProtocols) == 1 {
-					protocol = csProtocols[0]
-				} else if len(csProtocols) == len(csNames) {
-					protocol = csProtocols[i]
-				} else {
-					protocol = "openid-connect"
-				}
-				s := gocloak.ClientScope{Name: &n, Description: &desc, Protocol: &protocol}
-				id, err := gc.CreateClientScope(ctx, token, realm, s)
-				if err != nil {
-					if strings.Contains(strings.ToLower(err.Error()), "409") {
-						lines = append(lines, fmt.Sprintf("Client scope %q already exists in realm %q. Skipped.", n, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("failed creating client scope %q in realm %s: %w", n, realm, err)
-				}
-				lines = append(lines, fmt.Sprintf("Created client scope %q (ID: %s) in realm %q.", n, id, realm))
-				created++
+		results, err := kcops.CreateClientScopes(ctx, ops, kcops.CreateClientScopesRequest{Realm: realm, Scopes: specs, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("clientScope", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("clientScope", r), "already exists", fmt.Sprintf("Client scope %q already exists in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
+			recordResult(cmd, r)
+			rep.add(kcops.Created, opsItem("clientScope", r), fmt.Sprintf("Created client scope %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Created: %d, Skipped: %d.", created, skipped))
-		realmLabel := ""
-		if csAllRealms {
-			realmLabel = "all realms"
-		} else if csRealm != "" {
-			realmLabel = csRealm
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Created: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
 }
 
-var clientScopesUpdateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update client scope(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(csNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		if len(csDescriptions) == 0 && len(csProtocols) == 0 && len(csNewNames) == 0 {
-			return errors.New("nothing to update: provide --description/--protocol/--new-name")
-		}
-		if !(len(csDescriptions) == 0 || len(csDescriptions) == 1 || len(csDescriptions) == len(csNames)) {
-			return fmt.Errorf("invalid descriptions")
-		}
-		if !(len(csProtocols) == 0 || len(csProtocols) == 1 || len(csProtocols) == len(csNames)) {
-			return fmt.Errorf("invalid protocols")
-		}
-		if !(len(csNewNames) == 0 || len(csNewNames) == 1 || len(csNewNames) == len(csNames)) {
-			return fmt.Errorf("invalid new-name list")
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
+// clientScopesUpdateOptions holds the flags of `kc client-scopes update`.
+type clientScopesUpdateOptions struct {
+	names           []string
+	descriptions    []string
+	protocols       []string
+	newNames        []string
+	allRealms       bool
+	realm           string
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newClientScopesUpdateCmd() *cobra.Command {
+	o := &clientScopesUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update client scope(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "client scope name(s) to update. Repeatable; required.")
+	addStdinFlag(cmd, "name")
+	cmd.Flags().StringSliceVar(&o.descriptions, "description", nil, "new description(s). Optional; 0,1 or N")
+	cmd.Flags().StringSliceVar(&o.protocols, "protocol", nil, "new protocol(s). Optional; 0,1 or N")
+	cmd.Flags().StringSliceVar(&o.newNames, "new-name", nil, "new name(s). Optional; 0,1 or N")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "update in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip scopes not found instead of failing")
+	_ = cmd.RegisterFlagCompletionFunc("name", completeClientScopes)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *clientScopesUpdateOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: provide at least one --name")
+	}
+	if len(o.descriptions) == 0 && len(o.protocols) == 0 && len(o.newNames) == 0 {
+		return errors.New("nothing to update: provide --description/--protocol/--new-name")
+	}
+	if !(len(o.descriptions) == 0 || len(o.descriptions) == 1 || len(o.descriptions) == len(o.names)) {
+		return fmt.Errorf("invalid descriptions")
+	}
+	if !(len(o.protocols) == 0 || len(o.protocols) == 1 || len(o.protocols) == len(o.names)) {
+		return fmt.Errorf("invalid protocols")
+	}
+	if !(len(o.newNames) == 0 || len(o.newNames) == 1 || len(o.newNames) == len(o.names)) {
+		return fmt.Errorf("invalid new-name list")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "client scope name", o.names); err != nil {
+		return err
+	}
+	updates := make([]kcops.ClientScopeUpdate, len(o.names))
+	for i, n := range o.names {
+		updates[i] = kcops.ClientScopeUpdate{Name: n}
+		if v, ok := pick(o.descriptions, i); ok {
+			updates[i].Description = &v
 		}
-		realms, err := resolveCSRealms()
-		if err != nil {
-			return err
+		if v, ok := pick(o.protocols, i); ok {
+			updates[i].Protocol = &v
 		}
-		updated, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for i, n := range csNames {
-				scope, err := findClientScopeByName(ctx, gc, token, realm, n)
-				if err != nil {
-					if csIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", n, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("client scope %q not found in realm %s", n, realm)
-				}
-				if len(csDescriptions) == 1 {
-					scope.Description = &csDescriptions[0]
-				} else if len(csDescriptions) == len(csNames) {
-					scope.Description = &csDescriptions[i]
-				}
-				if len(csProtocols) == 1 {
-					scope.Protocol = &csProtocols[0]
-				} else if len(csProtocols) == len(csNames) {
-					scope.Protocol = &csProtocols[i]
-				}
-				if len(csNewNames) == 1 {
-					scope.Name = &csNewNames[0]
-				} else if len(csNewNames) == len(csNames) {
-					scope.Name = &csNewNames[i]
-				}
-				if err := gc.UpdateClientScope(ctx, token, realm, *scope); err != nil {
-					return fmt.Errorf("failed updating client scope %q in realm %s: %w", n, realm, err)
-				}
-				finalName := n
-				if scope.Name != nil {
-					finalName = *scope.Name
-				}
-				lines = append(lines, fmt.Sprintf("Updated client scope %q in realm %q. New name: %q.", n, realm, finalName))
-				updated++
+		updates[i].NewName, _ = pick(o.newNames, i)
+	}
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	rep.expect(len(updates) * len(realms))
+	for _, realm := range realms {
+		results, err := kcops.UpdateClientScopes(ctx, ops, kcops.UpdateClientScopesRequest{Realm: realm, Scopes: updates, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("clientScope", r), r.Error)
+				continue
 			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("clientScope", r), "not found", fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			recordResult(cmd, r)
+			rep.add(kcops.Updated, opsItem("clientScope", r), fmt.Sprintf("Updated client scope %q in realm %q. New name: %q.", r.Name, realm, r.NewName))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Updated: %d, Skipped: %d.", updated, skipped))
-		realmLabel := ""
-		if csAllRealms {
-			realmLabel = "all realms"
-		} else if csRealm != "" {
-			realmLabel = csRealm
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
 }
 
-var clientScopesDeleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete client scope(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(csNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
-		}
-		realms, err := resolveCSRealms()
-		if err != nil {
-			return err
-		}
-		deleted, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for _, n := range csNames {
-				scope, err := findClientScopeByName(ctx, gc, token, realm, n)
-				if err != nil {
-					if csIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", n, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("client scope %q not found in realm %s", n, realm)
-				}
-				if err := gc.DeleteClientScope(ctx, token, realm, *scope.ID); err != nil {
-					return fmt.Errorf("failed deleting client scope %q in realm %s: %w", n, realm, err)
-				}
-				lines = append(lines, fmt.Sprintf("Deleted client scope %q (ID: %s) in realm %q.", n, *scope.ID, realm))
-				deleted++
+// clientScopesDeleteOptions holds the flags of `kc client-scopes delete`.
+type clientScopesDeleteOptions struct {
+	names           []string
+	allRealms       bool
+	realm           string
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newClientScopesDeleteCmd() *cobra.Command {
+	o := &clientScopesDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete client scope(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "client scope name(s) to delete. Repeatable; required.")
+	addStdinFlag(cmd, "name")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "delete in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip scopes not found instead of failing")
+	_ = cmd.RegisterFlagCompletionFunc("name", completeClientScopes)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *clientScopesDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: provide at least one --name")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "client scope name", o.names); err != nil {
+		return err
+	}
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	rep.expect(len(o.names) * len(realms))
+	for _, realm := range realms {
+		results, err := kcops.DeleteClientScopes(ctx, ops, kcops.DeleteClientScopesRequest{Realm: realm, Names: o.names, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("clientScope", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("clientScope", r), "not found", fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
+			recordResult(cmd, r)
+			rep.add(kcops.Deleted, opsItem("clientScope", r), fmt.Sprintf("Deleted client scope %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", deleted, skipped))
-		realmLabel := ""
-		if csAllRealms {
-			realmLabel = "all realms"
-		} else if csRealm != "" {
-			realmLabel = csRealm
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
 }
 
-var clientScopesListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List client scopes",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
-		}
-		realms, err := resolveCSRealms()
+// clientScopesListOptions holds the flags of `kc client-scopes list`.
+type clientScopesListOptions struct {
+	allRealms bool
+	realm     string
+	normalize bool
+}
+
+func newClientScopesListCmd() *cobra.Command {
+	o := &clientScopesListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List client scopes",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "list in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
+}
+
+func (o *clientScopesListOptions) run(cmd *cobra.Command) error {
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	total := 0
+	lines := []string{}
+	for _, realm := range realms {
+		scopes, err := gc.GetClientScopes(ctx, token, realm)
 		if err != nil {
 			return err
 		}
-		total := 0
-		lines := []string{}
-		for _, realm := range realms {
-			scopes, err := gc.GetClientScopes(ctx, token, realm)
-			if err != nil {
-				return err
-			}
-			for _, s := range scopes {
-				if s.Name != nil {
-					lines = append(lines, *s.Name)
-					total++
-				}
+		var names []string
+		for _, s := range scopes {
+			if s.Name != nil {
+				names = append(names, *s.Name)
 			}
 		}
-		lines = append(lines, fmt.Sprintf("Total: %d", total))
-		realmLabel := ""
-		if csAllRealms {
-			realmLabel = "all realms"
-		} else if csRealm != "" {
-			realmLabel = csRealm
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if o.normalize {
+			slices.Sort(names)
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+		lines = append(lines, names...)
+		total += len(names)
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", total))
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+	return nil
 }
 
 func init() {
-	rootCmd.AddCommand(clientScopesCmd)
-	clientScopesCmd.AddCommand(clientScopesCreateCmd)
-	clientScopesCreateCmd.Flags().StringSliceVar(&csNames, "name", nil, "client scope name(s). Repeatable; required.")
-	clientScopesCreateCmd.Flags().StringSliceVar(&csDescriptions, "description", nil, "description(s). Optional; 0,1 or N")
-	clientScopesCreateCmd.Flags().StringSliceVar(&csProtocols, "protocol", nil, "protocol(s). Optional; 0,1 or N; default openid-connect")
-	clientScopesCreateCmd.Flags().BoolVar(&csAllRealms, "all-realms", false, "create in all realms")
-	clientScopesCreateCmd.Flags().StringVar(&csRealm, "realm", "", "target realm")
-
-	clientScopesCmd.AddCommand(clientScopesUpdateCmd)
-	clientScopesUpdateCmd.Flags().StringSliceVar(&csNames, "name", nil, "client scope name(s) to update. Repeatable; required.")
-	clientScopesUpdateCmd.Flags().StringSliceVar(&csDescriptions, "description", nil, "new description(s). Optional; 0,1 or N")
-	clientScopesUpdateCmd.Flags().StringSliceVar(&csProtocols, "protocol", nil, "new protocol(s). Optional; 0,1 or N")
-	clientScopesUpdateCmd.Flags().StringSliceVar(&csNewNames, "new-name", nil, "new name(s). Optional; 0,1 or N")
-	clientScopesUpdateCmd.Flags().BoolVar(&csAllRealms, "all-realms", false, "update in all realms")
-	clientScopesUpdateCmd.Flags().StringVar(&csRealm, "realm", "", "target realm")
-	clientScopesUpdateCmd.Flags().BoolVar(&csIgnoreMiss, "ignore-missing", false, "skip scopes not found instead of failing")
-
-	clientScopesCmd.AddCommand(clientScopesDeleteCmd)
-	clientScopesDeleteCmd.Flags().StringSliceVar(&csNames, "name", nil, "client scope name(s) to delete. Repeatable; required.")
-	clientScopesDeleteCmd.Flags().BoolVar(&csAllRealms, "all-realms", false, "delete in all realms")
-	clientScopesDeleteCmd.Flags().StringVar(&csRealm, "realm", "", "target realm")
-	clientScopesDeleteCmd.Flags().BoolVar(&csIgnoreMiss, "ignore-missing", false, "skip scopes not found instead of failing")
-
-	clientScopesCmd.AddCommand(clientScopesListCmd)
-	clientScopesListCmd.Flags().BoolVar(&csAllRealms, "all-realms", false, "list in all realms")
-	clientScopesListCmd.Flags().StringVar(&csRealm, "realm", "", "target realm")
+	rootCmd.AddCommand(newClientScopesCmd())
 }
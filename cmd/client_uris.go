@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// checkRedirectURI validates a redirect URI before it is sent to Keycloak.
+// Mistakes Keycloak would accept but no login could ever use are errors;
+// risky but working values return a warning.
+func checkRedirectURI(s string) (string, error) {
+	if s == "*" {
+		return "accepts any URL, so codes and tokens can be sent to an attacker", nil
+	}
+	if strings.Contains(s, "#") {
+		return "", errors.New("has a fragment (#...), which redirect URIs may not contain")
+	}
+	if strings.HasPrefix(s, "/") {
+		// Keycloak resolves it against the root URL of the client
+		return "is relative to the root URL of the client", nil
+	}
+	u, err := parseAbsoluteURL(s)
+	if err != nil {
+		return "", err
+	}
+	var warnings []string
+	switch redirectWildcard(s) {
+	case "any":
+		warnings = append(warnings, "accepts any URL, so codes and tokens can be sent to an attacker")
+	case "host":
+		warnings = append(warnings, "has a wildcard in the host")
+	case "path":
+		warnings = append(warnings, "has a wildcard in the path; list the exact callback paths if you can")
+	}
+	if w := plainHTTPWarning(u); w != "" {
+		warnings = append(warnings, w)
+	}
+	return strings.Join(warnings, "; "), nil
+}
+
+// checkWebOrigin validates a web origin the way checkRedirectURI does. An
+// origin is scheme://host[:port], "+" (the origins of the redirect URIs) or
+// "*".
+func checkWebOrigin(s string) (string, error) {
+	switch s {
+	case "+":
+		return "", nil
+	case "*":
+		return "allows cross-origin requests from any site", nil
+	}
+	if strings.Contains(s, "#") {
+		return "", errors.New("has a fragment (#...), which web origins may not contain")
+	}
+	u, err := parseAbsoluteURL(s)
+	if err != nil {
+		return "", err
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return "", errors.New("has a path or query; a web origin is only scheme://host[:port]")
+	}
+	if u.Path == "/" {
+		// browsers send the origin without it, so it would never match
+		return "", errors.New("ends in /; a web origin is only scheme://host[:port]")
+	}
+	var warnings []string
+	if strings.Contains(u.Host, "*") {
+		warnings = append(warnings, "has a wildcard in the host")
+	}
+	if w := plainHTTPWarning(u); w != "" {
+		warnings = append(warnings, w)
+	}
+	return strings.Join(warnings, "; "), nil
+}
+
+// parseAbsoluteURL parses s and requires a scheme, and a host for http(s).
+// Custom schemes of native apps, such as com.example.app:/callback, pass.
+func parseAbsoluteURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		// localhost:8080/cb parses as scheme "localhost"
+		return nil, errors.New("is not an absolute URL (missing http:// or https://?)")
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return nil, errors.New("has no host")
+	}
+	return u, nil
+}
+
+// plainHTTPWarning warns about http:// outside of local development, where
+// codes and tokens would travel in clear text.
+func plainHTTPWarning(u *url.URL) string {
+	if u.Scheme != "http" {
+		return ""
+	}
+	host := u.Hostname()
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" || strings.HasSuffix(host, ".localhost") {
+		return ""
+	}
+	return "uses http:// on a host that is not localhost; use https://"
+}
+
+// validateClientURIs checks the redirect URIs and web origins of each
+// client ID before any API call. Invalid values fail the command; warnings
+// are printed, and fail it too with strict.
+func validateClientURIs(cmd *cobra.Command, strict bool, clientIDs []string, redirectURIs, webOrigins [][]string) error {
+	var errs []error
+	check := func(cid, kind string, values []string, fn func(string) (string, error)) {
+		for _, v := range values {
+			warning, err := fn(v)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("client %q: %s %q %v", cid, kind, v, err))
+			case warning != "" && strict:
+				errs = append(errs, fmt.Errorf("client %q: %s %q %s (--strict)", cid, kind, v, warning))
+			case warning != "":
+				fmt.Fprintf(cmd.ErrOrStderr(), "Warning: client %q: %s %q %s.\n", cid, kind, v, warning)
+			}
+		}
+	}
+	for i, cid := range clientIDs {
+		if i < len(redirectURIs) {
+			check(cid, "redirect URI", redirectURIs[i], checkRedirectURI)
+		}
+		if i < len(webOrigins) {
+			check(cid, "web origin", webOrigins[i], checkWebOrigin)
+		}
+	}
+	if len(errs) > 0 {
+		cmd.SilenceUsage = true
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// clientCheck is the outcome of one check of `kc clients validate`.
+type clientCheck struct {
+	Realm    string `json:"realm"`
+	ClientID string `json:"clientId"`
+	Check    string `json:"check"`
+	// Status is ok, warning, error or skipped.
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// clientsValidateOptions holds the flags of `kc clients validate`.
+type clientsValidateOptions struct {
+	clientIDs []string
+	callback  string
+	strict    bool
+	realms    []string
+	allRealms bool
+}
+
+func newClientsValidateCmd() *cobra.Command {
+	o := &clientsValidateOptions{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the configuration of client(s) and, with --callback-check, the login of the deployed app",
+		Long: `Check client(s) for mistakes that break logins: disabled clients, the
+standard flow without redirect URIs, and invalid or risky redirect URIs and
+web origins.
+
+With --callback-check, the URL of the deployed app that starts the login,
+also check the app against the client: the OpenID Connect discovery of the
+realm answers with the expected issuer, the app redirects to the
+authorization endpoint of the realm with this client ID, a redirect URI the
+client allows and the PKCE method it requires, the callback answers, and the
+origin of the app is an allowed web origin. Nothing is changed; the command
+fails when a check fails, or with --strict when one warns.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s) to check. Repeatable; required.")
+	cmd.Flags().StringVar(&o.callback, "callback-check", "", "URL of the deployed app that starts the login, e.g. https://app.example.org")
+	cmd.Flags().BoolVar(&o.strict, "strict", false, "fail on warnings too")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *clientsValidateOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 {
+		return errors.New("missing --client-id: provide at least one --client-id")
+	}
+	if o.callback != "" {
+		if u, err := url.Parse(o.callback); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --callback-check %q: expected an http(s) URL", o.callback)
+		}
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	ops := opsClient(gc, token)
+	var checks []clientCheck
+	for _, realm := range realms {
+		for _, cid := range o.clientIDs {
+			add := func(check, status, format string, args ...any) {
+				checks = append(checks, clientCheck{Realm: realm, ClientID: cid, Check: check, Status: status, Detail: fmt.Sprintf(format, args...)})
+			}
+			cl, err := ops.ClientByClientID(ctx, realm, cid)
+			if err != nil || cl == nil {
+				add("client", "error", "not found in realm %s", realm)
+				continue
+			}
+			checkClientConfig(cl, add)
+			if o.callback != "" {
+				checkClientCallback(ctx, realm, cl, o.callback, add)
+			}
+		}
+	}
+
+	failed, warned := 0, 0
+	for _, c := range checks {
+		switch c.Status {
+		case "error":
+			failed++
+		case "warning":
+			warned++
+		}
+	}
+	if outputFormat == "json" {
+		if err := printJSON(cmd, checks); err != nil {
+			return err
+		}
+	} else {
+		var lines []string
+		for i, c := range checks {
+			if i == 0 || c.Realm != checks[i-1].Realm || c.ClientID != checks[i-1].ClientID {
+				lines = append(lines, fmt.Sprintf("Client %q in realm %q:", c.ClientID, c.Realm))
+			}
+			lines = append(lines, fmt.Sprintf("  %-9s %s: %s", "["+c.Status+"]", c.Check, c.Detail))
+		}
+		lines = append(lines, fmt.Sprintf("Checks: %d, failed: %d, warnings: %d.", len(checks), failed, warned))
+		printBox(cmd, lines, realmsLabel(cmd, realms))
+	}
+	if failed > 0 || (o.strict && warned > 0) {
+		// the problems are listed above
+		cmd.SilenceUsage = true
+		if failed == 0 {
+			return fmt.Errorf("client validation found %d warning(s) (--strict)", warned)
+		}
+		return fmt.Errorf("client validation failed: %d check(s) failed", failed)
+	}
+	return nil
+}
+
+// checkClientConfig checks the settings of cl that need no network access.
+func checkClientConfig(cl *gocloak.Client, add func(check, status, format string, args ...any)) {
+	if gocloak.PBool(cl.Enabled) {
+		add("enabled", "ok", "yes")
+	} else {
+		add("enabled", "error", "the client is disabled; every login fails")
+	}
+	redirects := gocloak.PStringSlice(cl.RedirectURIs)
+	oidc := cl.Protocol == nil || *cl.Protocol == "openid-connect"
+	if oidc && gocloak.PBool(cl.StandardFlowEnabled) && len(redirects) == 0 {
+		add("redirect URIs", "error", "the standard flow is enabled but no redirect URIs are set; logins fail with invalid redirect_uri")
+	}
+	checkValues := func(check string, values []string, fn func(string) (string, error)) {
+		valid := 0
+		for _, v := range values {
+			warning, err := fn(v)
+			switch {
+			case err != nil:
+				add(check, "error", "%q %v", v, err)
+			case warning != "":
+				add(check, "warning", "%q %s", v, warning)
+			default:
+				valid++
+			}
+		}
+		if valid > 0 {
+			add(check, "ok", "%d valid", valid)
+		}
+	}
+	checkValues("redirect URIs", redirects, checkRedirectURI)
+	checkValues("web origins", gocloak.PStringSlice(cl.WebOrigins), checkWebOrigin)
+}
+
+// checkClientCallback starts a login at the app URL callback and checks
+// where the app sends the browser against the configuration of cl.
+func checkClientCallback(ctx context.Context, realm string, cl *gocloak.Client, callback string, add func(check, status, format string, args ...any)) {
+	cid := gocloak.PString(cl.ClientID)
+	authEndpoint := keycloak.OIDCURL(realm, "auth")
+	issuer := strings.TrimRight(config.Global.ServerURL, "/") + "/realms/" + realm
+	doc, err := keycloak.Discovery(ctx, realm)
+	switch {
+	case errors.Is(err, keycloak.ErrOffline):
+		add("discovery", "skipped", "%v", err)
+	case err != nil:
+		add("discovery", "error", "failed fetching .well-known/openid-configuration: %v", err)
+	default:
+		if s, ok := doc["authorization_endpoint"].(string); ok && s != "" {
+			authEndpoint = s
+		}
+		if got, _ := doc["issuer"].(string); got != issuer {
+			add("discovery", "warning", "issuer is %q, not %q; check the hostname settings of Keycloak and its proxy", got, issuer)
+		} else {
+			add("discovery", "ok", "issuer %s", got)
+		}
+	}
+
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	login, err := followAppRedirects(ctx, client, callback)
+	if err != nil {
+		add("login redirect", "error", "%v", err)
+		return
+	}
+	want, _ := url.Parse(authEndpoint)
+	if !strings.HasSuffix(login.Path, "/realms/"+realm+"/protocol/openid-connect/auth") {
+		add("login redirect", "error", "the app redirects to %s, not to the authorization endpoint of realm %s", login.Redacted(), realm)
+		return
+	}
+	if want != nil && login.Host != want.Host {
+		add("login redirect", "warning", "the app redirects to host %s, but the discovery document names %s", login.Host, want.Host)
+	} else {
+		add("login redirect", "ok", "the app redirects to the authorization endpoint of realm %s", realm)
+	}
+
+	q := login.Query()
+	if got := q.Get("client_id"); got != cid {
+		add("client_id", "error", "the app sends client_id %q, not %q", got, cid)
+	} else {
+		add("client_id", "ok", "%s", got)
+	}
+	redirectURI := q.Get("redirect_uri")
+	patterns := gocloak.PStringSlice(cl.RedirectURIs)
+	switch {
+	case redirectURI == "":
+		add("redirect_uri", "error", "the app sends no redirect_uri")
+	case !redirectAllowed(redirectURI, patterns, gocloak.PString(cl.RootURL)):
+		add("redirect_uri", "error", "the app sends %s, which the client does not allow (redirect URIs: %s)", redirectURI, strings.Join(patterns, ", "))
+	default:
+		add("redirect_uri", "ok", "%s is allowed", redirectURI)
+	}
+	if cl.Attributes != nil {
+		if method := (*cl.Attributes)["pkce.code.challenge.method"]; method != "" {
+			if got := q.Get("code_challenge_method"); got != method {
+				add("pkce", "error", "the client requires PKCE %s, but the app sends code_challenge_method %q", method, got)
+			} else {
+				add("pkce", "ok", "%s", method)
+			}
+		}
+	}
+	if redirectURI == "" {
+		return
+	}
+
+	cb, err := url.Parse(redirectURI)
+	if err != nil || cb.Host == "" {
+		add("callback", "error", "redirect_uri %q is not an absolute URL", redirectURI)
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, redirectURI, nil)
+	if err != nil {
+		add("callback", "error", "%v", err)
+		return
+	}
+	resp, err := client.Do(req)
+	switch {
+	case err != nil:
+		add("callback", "error", "%s is unreachable: %v", redirectURI, err)
+	case resp.StatusCode == http.StatusNotFound:
+		add("callback", "error", "%s answers 404 Not Found", redirectURI)
+	case resp.StatusCode >= 500:
+		add("callback", "warning", "%s answers %s without a code; check it handles the real callback", redirectURI, resp.Status)
+	default:
+		add("callback", "ok", "%s answers %s", redirectURI, resp.Status)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	if gocloak.PBool(cl.PublicClient) {
+		origin := cb.Scheme + "://" + cb.Host
+		origins := gocloak.PStringSlice(cl.WebOrigins)
+		allowed := slices.Contains(origins, "*") || slices.Contains(origins, origin) ||
+			(slices.Contains(origins, "+") && slices.ContainsFunc(patterns, func(p string) bool { return strings.HasPrefix(p, origin+"/") || p == origin }))
+		if allowed {
+			add("web origin", "ok", "%s is allowed", origin)
+		} else {
+			add("web origin", "warning", "%s is not a web origin of the client; browser requests from it to Keycloak fail CORS checks", origin)
+		}
+	}
+}
+
+// followAppRedirects requests start and follows redirects within its host,
+// returning the first redirect that leaves it: where the app sends the
+// browser to log in.
+func followAppRedirects(ctx context.Context, client *http.Client, start string) (*url.URL, error) {
+	current, err := url.Parse(start)
+	if err != nil {
+		return nil, err
+	}
+	for range 5 {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s is unreachable: %v", current.Redacted(), err)
+		}
+		resp.Body.Close()
+		loc := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || loc == "" {
+			return nil, fmt.Errorf("%s answers %s without redirecting to Keycloak; give the URL that starts the login", current.Redacted(), resp.Status)
+		}
+		next, err := current.Parse(loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s redirects to an invalid URL %q", current.Redacted(), loc)
+		}
+		if next.Host != current.Host {
+			return next, nil
+		}
+		current = next
+	}
+	return nil, fmt.Errorf("%s redirects more than 5 times within the app", start)
+}
+
+// redirectAllowed reports whether Keycloak accepts uri for a client with
+// the redirect URI patterns given: exact values, or prefixes ending in *.
+// Relative patterns are resolved against the root URL of the client.
+func redirectAllowed(uri string, patterns []string, rootURL string) bool {
+	for _, p := range patterns {
+		if strings.HasPrefix(p, "/") {
+			if rootURL == "" {
+				continue
+			}
+			p = strings.TrimRight(rootURL, "/") + p
+		}
+		if p == "*" || p == uri {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+	return false
+}
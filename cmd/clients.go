@@ -4,78 +4,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"time"
 
-	"kc/internal/config"
+	"kc/internal/audit"
 	"kc/internal/keycloak"
+	"kc/pkg/kcops"
 
 	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var (
-	cliIDs             []string
-	cliNames           []string
-	cliPublics         []bool
-	cliSecrets         []string
-	cliEnabled         []bool
-	cliProtocols       []string
-	cliRootURLs        []string
-	cliBaseURLs        []string
-	cliRedirectURIs    [][]string
-	cliWebOrigins      [][]string
-	cliStandardFlows   []bool
-	cliDirectAccess    []bool
-	cliImplicitFlows   []bool
-	cliServiceAccounts []bool
-	cliNewClientIDs    []string
-	clientsRealms      []string
-	clientsAllRealms   bool
-	clientsIgnoreMiss  bool
-
-	// scopes subcommand
-	scopeClientID   string
-	scopeNames      []string
-	scopeType       string // default | optional
-	scopeIgnoreMiss bool
-)
-
-var clientsCmd = &cobra.Command{
-	Use:   "clients",
-	Short: "Manage clients",
-}
-
-func resolveRealmsForClients(cmd *cobra.Command) ([]string, error) {
-	if clientsAllRealms {
-		ctx := cmd.Context()
-		client, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return nil, err
-		}
-		realms, err := client.GetRealms(ctx, token)
-		if err != nil {
-			return nil, err
-		}
-		var rs []string
-		for _, r := range realms {
-			if r.Realm != nil {
-				rs = append(rs, *r.Realm)
-			}
-		}
-		return rs, nil
+func newClientsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clients",
+		Short: "Manage clients",
 	}
-	if len(clientsRealms) > 0 {
-		return append([]string{}, clientsRealms...), nil
-	}
-	r := defaultRealm
-	if r == "" {
-		r = config.Global.Realm
-	}
-	if r == "" {
-		return nil, errors.New("target realm not specified. Use --realm or set realm in config.json")
-	}
-	return []string{r}, nil
+	cmd.AddCommand(newClientsCreateCmd())
+	cmd.AddCommand(newClientsUpdateCmd())
+	cmd.AddCommand(newClientsDeleteCmd())
+	cmd.AddCommand(newClientsListCmd())
+	cmd.AddCommand(newClientsScopesCmd())
+	cmd.AddCommand(newClientsKeysCmd())
+	cmd.AddCommand(newClientsValidateCmd())
+	cmd.AddCommand(newClientsMappersCmd())
+	cmd.AddCommand(newClientsAudienceCmd())
+	cmd.AddCommand(newClientsRotateSecretsCmd())
+	return cmd
 }
 
 // Helper to pick value 0/1/N aligned to index i
@@ -90,632 +47,1067 @@ func pick[T any](vals []T, i int) (T, bool) {
 	return zero, false
 }
 
-func getClientByClientID(ctx context.Context, gc *gocloak.GoCloak, token, realm, cid string) (*gocloak.Client, error) {
-	params := gocloak.GetClientsParams{ClientID: &cid}
-	list, err := gc.GetClients(ctx, token, realm, params)
-	if err != nil {
-		return nil, err
-	}
-	for _, c := range list {
-		if c.ClientID != nil && *c.ClientID == cid {
-			return c, nil
-		}
+// clientsCreateOptions holds the flags of `kc clients create`.
+type clientsCreateOptions struct {
+	clientIDs       []string
+	names           []string
+	publics         []bool
+	secrets         []string
+	enabled         []bool
+	protocols       []string
+	rootURLs        []string
+	baseURLs        []string
+	redirectURIs    [][]string
+	webOrigins      [][]string
+	directAccess    []bool
+	serviceAccounts []bool
+	presets         []string
+	auth            clientAuthFlags
+	auths           []*kcops.ClientAuth
+	samlMetadata    []string
+	strict          bool
+	realms          []string
+	allRealms       bool
+	interactive     bool
+	template        templateOptions
+	batch           batchOptions
+	continueOnError bool
+}
+
+func newClientsCreateCmd() *cobra.Command {
+	o := &clientsCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create client(s)",
+		Long: `Create client(s). --preset configures a client for a common kind of
+application, so the flows, PKCE and scopes need not be set one by one:
+
+  spa      public, authorization code flow with PKCE (S256), web origin "+",
+           without the offline_access scope
+  backend  confidential, authorization code flow with PKCE (S256)
+  service  confidential, service account (client credentials) only, without
+           the offline_access scope
+  mobile   public, authorization code flow with PKCE (S256), offline_access
+           kept for refresh tokens
+
+Presets disable direct access grants and the implicit flow. Explicit
+--web-origin values replace the preset's.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
 	}
-	return nil, fmt.Errorf("client %q not found", cid)
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "name(s). Optional; 0, 1 or N matching --client-id.")
+	cmd.Flags().BoolSliceVar(&o.publics, "public", nil, "public client(s). Optional; 0, 1 or N; default false")
+	cmd.Flags().StringSliceVar(&o.secrets, "secret", nil, "secret(s). Optional; ignored for public clients")
+	cmd.Flags().BoolSliceVar(&o.enabled, "enabled", nil, "enabled flag(s). Optional; 0, 1 or N; default true")
+	cmd.Flags().StringSliceVar(&o.protocols, "protocol", nil, "protocol(s). Optional; 0, 1 or N; e.g. openid-connect")
+	cmd.Flags().StringSliceVar(&o.rootURLs, "root-url", nil, "root URL(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&o.baseURLs, "base-url", nil, "base URL(s). Optional; 0, 1 or N")
+	// one list applies to every client; see spreadList
+	cmd.Flags().StringSlice("redirect-uri", nil, "redirect URI list per client; repeat flag per client")
+	cmd.Flags().StringSlice("web-origin", nil, "web origin list per client; repeat flag per client")
+	cmd.Flags().StringSliceVar(&o.presets, "preset", nil, "preset(s): spa, backend, service or mobile. Optional; 0, 1 or N")
+	o.auth.add(cmd)
+	cmd.Flags().StringSliceVar(&o.samlMetadata, "from-saml-metadata", nil, "SAML SP metadata file(s); creates one SAML client per file instead of --client-id. Repeatable")
+	cmd.Flags().BoolVar(&o.strict, "strict", false, "fail on redirect URI and web origin warnings (wildcards, http:// outside localhost) instead of printing them")
+	cmd.Flags().BoolVarP(&o.interactive, "interactive", "i", false, "prompt for client parameters step by step")
+	addTemplateFlags(cmd, &o.template)
+	addBatchFlags(cmd, &o.batch)
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	addAtomicFlag(cmd)
+	return cmd
 }
 
-var clientsCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create client(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(cliIDs) == 0 {
-			return errors.New("missing --client-id: provide at least one --client-id")
+func (o *clientsCreateOptions) run(cmd *cobra.Command) error {
+	o.redirectURIs = spreadList(cmd, "redirect-uri", len(o.clientIDs))
+	o.webOrigins = spreadList(cmd, "web-origin", len(o.clientIDs))
+	tplSpecs, err := templateSpecs(cmd, o.template, "clientId", func(s kcops.ClientSpec) string { return s.ClientID },
+		append([]string{"client-id", "name", "public", "secret", "enabled", "protocol", "root-url", "base-url", "redirect-uri", "web-origin", "preset"}, clientAuthFlagNames...)...)
+	if err != nil {
+		return err
+	}
+	if o.auths, err = o.auth.build(); err != nil {
+		return err
+	}
+	if len(o.samlMetadata) > 0 {
+		if tplSpecs, err = o.samlSpecs(cmd); err != nil {
+			return err
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
+	}
+	if o.interactive {
+		if err := o.fillInteractive(cmd); err != nil {
 			return err
 		}
+	}
+	if len(o.clientIDs) == 0 && tplSpecs == nil {
+		return errors.New("missing --client-id: provide at least one --client-id, --template or --from-saml-metadata")
+	}
+	if err := o.validatePresets(tplSpecs); err != nil {
+		return err
+	}
+	specs := o.specs()
+	if tplSpecs != nil {
+		specs = tplSpecs
+	}
+	for _, s := range specs {
+		if s.Auth != nil && (s.PublicClient || kcops.ClientPresets[s.Preset].PublicClient) {
+			return fmt.Errorf("client %q is public; --auth-method only applies to confidential clients", s.ClientID)
+		}
+	}
+	// {realm} is not valid in a host, so the URIs are checked with a sample
+	// realm name in its place
+	sample, err := forRealm(specs, "realm")
+	if err != nil {
+		return err
+	}
+	clientIDs := make([]string, len(specs))
+	redirectURIs := make([][]string, len(specs))
+	webOrigins := make([][]string, len(specs))
+	for i, s := range sample {
+		clientIDs[i], redirectURIs[i], webOrigins[i] = s.ClientID, s.RedirectURIs, s.WebOrigins
+	}
+	if err := validateClientURIs(cmd, o.strict, clientIDs, redirectURIs, webOrigins); err != nil {
+		return err
+	}
+	throttle(o.batch.rps)
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
 
-		realms, err := resolveRealmsForClients(cmd)
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "client ID", clientIDs); err != nil {
+		return err
+	}
+
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	rep.expect(len(specs) * len(realms))
+	for _, realm := range realms {
+		specs, err := forRealm(specs, realm)
 		if err != nil {
 			return err
 		}
+		results, err := kcops.CreateClients(ctx, ops, kcops.CreateClientsRequest{Realm: realm, Clients: specs, Workers: o.batch.workers, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("client", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("client", r), "already exists", fmt.Sprintf("Client %q already exists in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			printWarnings(cmd, r)
+			recordResult(cmd, r)
+			rep.add(kcops.Created, opsItem("client", r), fmt.Sprintf("Created client %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Created: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
+}
 
-		created, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for i, cid := range cliIDs {
-				// existence
-				// existence via GetClients filter
-				existing, err := getClientByClientID(ctx, gc, token, realm, cid)
-				if err == nil && existing != nil && existing.ID != nil {
-					lines = append(lines, fmt.Sprintf("Client %q already exists in realm %q. Skipped.", cid, realm))
-					skipped++
-					continue
-				}
-				var name, secret, protocol, rootURL, baseURL string
-				if v, ok := pick(cliNames, i); ok {
-					name = v
-				}
-				if v, ok := pick(cliSecrets, i); ok {
-					secret = v
-				}
-				if v, ok := pick(cliProtocols, i); ok {
-					protocol = v
-				}
-				if v, ok := pick(cliRootURLs, i); ok {
-					rootURL = v
-				}
-				if v, ok := pick(cliBaseURLs, i); ok {
-					baseURL = v
-				}
-				var enabled, publicClient, stdFlow, direct, implicit, svcAcct bool
-				if v, ok := pick(cliEnabled, i); ok {
-					enabled = v
-				} else {
-					enabled = true
-				}
-				if v, ok := pick(cliPublics, i); ok {
-					publicClient = v
-				}
-				if v, ok := pick(cliStandardFlows, i); ok {
-					stdFlow = v
-				}
-				if v, ok := pick(cliDirectAccess, i); ok {
-					direct = v
-				}
-				if v, ok := pick(cliImplicitFlows, i); ok {
-					implicit = v
-				}
-				if v, ok := pick(cliServiceAccounts, i); ok {
-					svcAcct = v
-				}
+// clientsUpdateOptions holds the flags of `kc clients update`.
+type clientsUpdateOptions struct {
+	clientIDs       []string
+	names           []string
+	publics         []bool
+	secrets         []string
+	enabled         []bool
+	protocols       []string
+	rootURLs        []string
+	baseURLs        []string
+	redirectURIs    [][]string
+	webOrigins      [][]string
+	standardFlows   []bool
+	directAccess    []bool
+	implicitFlows   []bool
+	serviceAccounts []bool
+	newClientIDs    []string
+	auth            clientAuthFlags
+	sets            []string
+	ignoreMissing   bool
+	strict          bool
+	match           matchOptions
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
 
-				cl := gocloak.Client{ClientID: &cid}
-				if name != "" {
-					cl.Name = &name
-				}
-				cl.Enabled = &enabled
-				cl.PublicClient = &publicClient
-				if protocol != "" {
-					cl.Protocol = &protocol
-				}
-				if rootURL != "" {
-					cl.RootURL = &rootURL
-				}
-				if baseURL != "" {
-					cl.BaseURL = &baseURL
-				}
-				if stdFlow {
-					cl.StandardFlowEnabled = &stdFlow
-				}
-				if direct {
-					cl.DirectAccessGrantsEnabled = &direct
-				}
-				if implicit {
-					cl.ImplicitFlowEnabled = &implicit
-				}
-				if svcAcct {
-					cl.ServiceAccountsEnabled = &svcAcct
-				}
+func newClientsUpdateCmd() *cobra.Command {
+	o := &clientsUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update client(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s) to update. Repeatable; required.")
+	addStdinFlag(cmd, "client-id")
+	o.match.add(cmd, "client")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "new name(s). Optional; 0, 1 or N")
+	cmd.Flags().BoolSliceVar(&o.publics, "public", nil, "set public flag(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&o.secrets, "secret", nil, "new secret(s). Optional; ignored for public clients")
+	cmd.Flags().BoolSliceVar(&o.enabled, "enabled", nil, "set enabled flag(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&o.protocols, "protocol", nil, "protocol(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&o.rootURLs, "root-url", nil, "root URL(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSliceVar(&o.baseURLs, "base-url", nil, "base URL(s). Optional; 0, 1 or N")
+	cmd.Flags().StringSlice("redirect-uri", nil, "redirect URI list to replace; applies to all targeted clients")
+	cmd.Flags().StringSlice("web-origin", nil, "web origin list to replace; applies to all targeted clients")
+	cmd.Flags().BoolSliceVar(&o.standardFlows, "standard-flow", nil, "enable standard flow(s). Optional; 0,1 or N")
+	cmd.Flags().BoolSliceVar(&o.directAccess, "direct-access", nil, "enable direct access grants(s). Optional; 0,1 or N")
+	cmd.Flags().BoolSliceVar(&o.implicitFlows, "implicit-flow", nil, "enable implicit flow(s). Optional; 0,1 or N")
+	cmd.Flags().BoolSliceVar(&o.serviceAccounts, "service-accounts", nil, "enable service accounts(s). Optional; 0,1 or N")
+	cmd.Flags().StringSliceVar(&o.newClientIDs, "new-client-id", nil, "new client-id(s). Optional; 0,1 or N")
+	o.auth.add(cmd)
+	addSetFlag(cmd, &o.sets, "client")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip clients not found instead of failing")
+	cmd.Flags().BoolVar(&o.strict, "strict", false, "fail on redirect URI and web origin warnings (wildcards, http:// outside localhost) instead of printing them")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
 
-				id, err := gc.CreateClient(ctx, token, realm, cl)
-				if err != nil {
-					// if 409 already exists (rare), treat as skipped
-					if strings.Contains(strings.ToLower(err.Error()), "409") {
-						fmt.Fprintf(cmd.OutOrStdout(), "Client %q already exists in realm %q. Skipped.\n", cid, realm)
-						skipped++
-						continue
-					}
-					return fmt.Errorf("failed creating client %q in realm %s: %w", cid, realm, err)
-				}
+func (o *clientsUpdateOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 && !o.match.active() {
+		return errors.New("missing --client-id: provide at least one --client-id or --match")
+	}
+	if err := o.match.validate(cmd, "client-id", o.clientIDs, []string{"new-client-id"}, append([]string{"name", "public", "secret", "enabled", "protocol", "root-url", "base-url", "standard-flow", "direct-access", "implicit-flow", "service-accounts"}, clientAuthFlagNames...)); err != nil {
+		return err
+	}
+	// with --match the URIs are checked once, under the patterns
+	targets := o.clientIDs
+	if o.match.active() {
+		targets = []string{strings.Join(o.match.patterns, ",")}
+	}
+	o.redirectURIs = spreadList(cmd, "redirect-uri", len(targets))
+	o.webOrigins = spreadList(cmd, "web-origin", len(targets))
+	// Must have at least one field to update
+	any := len(o.names) > 0 || len(o.publics) > 0 || len(o.secrets) > 0 || len(o.enabled) > 0 || len(o.protocols) > 0 || len(o.rootURLs) > 0 || len(o.baseURLs) > 0 || len(o.redirectURIs) > 0 || len(o.webOrigins) > 0 || len(o.standardFlows) > 0 || len(o.directAccess) > 0 || len(o.implicitFlows) > 0 || len(o.serviceAccounts) > 0 || len(o.newClientIDs) > 0 || o.auth.given() || len(o.sets) > 0
+	if !any {
+		return errors.New("nothing to update: provide at least one field flag")
+	}
+	auths, err := o.auth.build()
+	if err != nil {
+		return err
+	}
+	sets, err := kcops.ParseFieldSets(o.sets)
+	if err != nil {
+		return flagError(err)
+	}
+	for _, s := range sets {
+		if s.Path == "id" || s.Path == "clientId" {
+			return fmt.Errorf("invalid --set %s: rename clients with --new-client-id", s.Path)
+		}
+	}
+	if err := validateClientURIs(cmd, o.strict, targets, o.redirectURIs, o.webOrigins); err != nil {
+		return err
+	}
 
-				// explicit secret setting is not supported by gocloak (only regenerate). If provided, warn and continue.
-				if secret != "" && !publicClient {
-					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: --secret provided for client %q but explicit secret setting is not supported. Skipped setting secret.\n", cid)
-				}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := o.match.realms(ctx, cmd, gc, token, o.clientIDs)
+	if err != nil {
+		return err
+	}
 
-				// Redirect URIs and Web Origins
-				if i < len(cliRedirectURIs) && len(cliRedirectURIs[i]) > 0 {
-					if err := gc.UpdateClient(ctx, token, realm, gocloak.Client{ID: &id, RedirectURIs: &cliRedirectURIs[i]}); err != nil {
-						return fmt.Errorf("failed setting redirect URIs for client %q in realm %s: %w", cid, realm, err)
-					}
-				}
-				if i < len(cliWebOrigins) && len(cliWebOrigins[i]) > 0 {
-					if err := gc.UpdateClient(ctx, token, realm, gocloak.Client{ID: &id, WebOrigins: &cliWebOrigins[i]}); err != nil {
-						return fmt.Errorf("failed setting web origins for client %q in realm %s: %w", cid, realm, err)
-					}
-				}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "update", realms, listClientIDs(ctx, gc, token)); err != nil {
+			return err
+		}
+	}
 
-				lines = append(lines, fmt.Sprintf("Created client %q (ID: %s) in realm %q.", cid, id, realm))
-				created++
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		rep.expect(len(o.match.names(realm, o.clientIDs)))
+	}
+	for _, realm := range realms {
+		clientIDs := o.match.names(realm, o.clientIDs)
+		if len(clientIDs) == 0 {
+			continue
+		}
+		redirectURIs := spreadList(cmd, "redirect-uri", len(clientIDs))
+		webOrigins := spreadList(cmd, "web-origin", len(clientIDs))
+		updates := make([]kcops.ClientUpdate, len(clientIDs))
+		for i, cid := range clientIDs {
+			u := kcops.ClientUpdate{ClientID: cid}
+			if v, ok := pick(o.names, i); ok {
+				u.Name = &v
+			}
+			if v, ok := pick(o.publics, i); ok {
+				u.PublicClient = &v
+			}
+			if v, ok := pick(o.enabled, i); ok {
+				u.Enabled = &v
+			}
+			if v, ok := pick(o.protocols, i); ok {
+				u.Protocol = &v
+			}
+			if v, ok := pick(o.rootURLs, i); ok {
+				u.RootURL = &v
+			}
+			if v, ok := pick(o.baseURLs, i); ok {
+				u.BaseURL = &v
+			}
+			if v, ok := pick(o.standardFlows, i); ok {
+				u.StandardFlowEnabled = &v
 			}
+			if v, ok := pick(o.directAccess, i); ok {
+				u.DirectAccessGrantsEnabled = &v
+			}
+			if v, ok := pick(o.implicitFlows, i); ok {
+				u.ImplicitFlowEnabled = &v
+			}
+			if v, ok := pick(o.serviceAccounts, i); ok {
+				u.ServiceAccountsEnabled = &v
+			}
+			if i < len(redirectURIs) {
+				u.RedirectURIs = redirectURIs[i]
+			}
+			if i < len(webOrigins) {
+				u.WebOrigins = webOrigins[i]
+			}
+			u.Secret, _ = pick(o.secrets, i)
+			u.NewClientID, _ = pick(o.newClientIDs, i)
+			u.Auth, _ = pick(auths, i)
+			u.Set = sets
+			updates[i] = u
 		}
-		lines = append(lines, fmt.Sprintf("Done. Created: %d, Skipped: %d.", created, skipped))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		results, err := kcops.UpdateClients(ctx, ops, kcops.UpdateClientsRequest{Realm: realm, Clients: updates, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("client", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("client", r), "not found", fmt.Sprintf("Client %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			printWarnings(cmd, r)
+			recordResult(cmd, r)
+			rep.add(kcops.Updated, opsItem("client", r), fmt.Sprintf("Updated client %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return flagError(err)
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
 }
 
-var clientsUpdateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update client(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(cliIDs) == 0 {
-			return errors.New("missing --client-id: provide at least one --client-id")
-		}
-		// Must have at least one field to update
-		any := len(cliNames) > 0 || len(cliPublics) > 0 || len(cliSecrets) > 0 || len(cliEnabled) > 0 || len(cliProtocols) > 0 || len(cliRootURLs) > 0 || len(cliBaseURLs) > 0 || len(cliRedirectURIs) > 0 || len(cliWebOrigins) > 0 || len(cliStandardFlows) > 0 || len(cliDirectAccess) > 0 || len(cliImplicitFlows) > 0 || len(cliServiceAccounts) > 0 || len(cliNewClientIDs) > 0
-		if !any {
-			return errors.New("nothing to update: provide at least one field flag")
-		}
+// clientsDeleteOptions holds the flags of `kc clients delete`.
+type clientsDeleteOptions struct {
+	clientIDs       []string
+	ignoreMissing   bool
+	match           matchOptions
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
-		}
-		realms, err := resolveRealmsForClients(cmd)
-		if err != nil {
+func newClientsDeleteCmd() *cobra.Command {
+	o := &clientsDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete client(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s) to delete. Repeatable; required.")
+	addStdinFlag(cmd, "client-id")
+	o.match.add(cmd, "client")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip clients not found instead of failing")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *clientsDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.clientIDs) == 0 && !o.match.active() {
+		return errors.New("missing --client-id: provide at least one --client-id or --match")
+	}
+	if err := o.match.validate(cmd, "client-id", o.clientIDs, nil, nil); err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := o.match.realms(ctx, cmd, gc, token, o.clientIDs)
+	if err != nil {
+		return err
+	}
+
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "delete", realms, listClientIDs(ctx, gc, token)); err != nil {
 			return err
 		}
+	}
 
-		updated, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for i, cid := range cliIDs {
-				c, err := getClientByClientID(ctx, gc, token, realm, cid)
-				if err != nil || c == nil || c.ID == nil {
-					if clientsIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("Client %q not found in realm %q. Skipped.", cid, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("client %q not found in realm %s", cid, realm)
-				}
-				id := *c.ID
-				// Apply updates
-				if v, ok := pick(cliNames, i); ok {
-					c.Name = &v
-				}
-				if v, ok := pick(cliPublics, i); ok {
-					c.PublicClient = &v
-				}
-				if v, ok := pick(cliEnabled, i); ok {
-					c.Enabled = &v
-				}
-				if v, ok := pick(cliProtocols, i); ok {
-					c.Protocol = &v
-				}
-				if v, ok := pick(cliRootURLs, i); ok {
-					c.RootURL = &v
-				}
-				if v, ok := pick(cliBaseURLs, i); ok {
-					c.BaseURL = &v
-				}
-				if v, ok := pick(cliStandardFlows, i); ok {
-					c.StandardFlowEnabled = &v
-				}
-				if v, ok := pick(cliDirectAccess, i); ok {
-					c.DirectAccessGrantsEnabled = &v
-				}
-				if v, ok := pick(cliImplicitFlows, i); ok {
-					c.ImplicitFlowEnabled = &v
-				}
-				if v, ok := pick(cliServiceAccounts, i); ok {
-					c.ServiceAccountsEnabled = &v
-				}
-				if i < len(cliRedirectURIs) && len(cliRedirectURIs[i]) > 0 {
-					c.RedirectURIs = &cliRedirectURIs[i]
-				}
-				if i < len(cliWebOrigins) && len(cliWebOrigins[i]) > 0 {
-					c.WebOrigins = &cliWebOrigins[i]
-				}
-
-				if err := gc.UpdateClient(ctx, token, realm, *c); err != nil {
-					return fmt.Errorf("failed updating client %q in realm %s: %w", cid, realm, err)
-				}
-				if v, ok := pick(cliSecrets, i); ok && v != "" && (c.PublicClient == nil || !*c.PublicClient) {
-					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: --secret provided for client %q but explicit secret setting is not supported. Skipped setting secret.\n", cid)
-				}
-				if v, ok := pick(cliNewClientIDs, i); ok && v != "" {
-					c.ClientID = &v
-					if err := gc.UpdateClient(ctx, token, realm, *c); err != nil {
-						return fmt.Errorf("failed renaming client %q to %q in realm %s: %w", cid, v, realm, err)
-					}
-				}
-				lines = append(lines, fmt.Sprintf("Updated client %q (ID: %s) in realm %q.", cid, id, realm))
-				updated++
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		rep.expect(len(o.match.names(realm, o.clientIDs)))
+	}
+	for _, realm := range realms {
+		clientIDs := o.match.names(realm, o.clientIDs)
+		if len(clientIDs) == 0 {
+			continue
+		}
+		results, err := kcops.DeleteClients(ctx, ops, kcops.DeleteClientsRequest{Realm: realm, ClientIDs: clientIDs, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("client", r), r.Error)
+				continue
 			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("client", r), "not found", fmt.Sprintf("Client %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			recordResult(cmd, r)
+			rep.add(kcops.Deleted, opsItem("client", r), fmt.Sprintf("Deleted client %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Updated: %d, Skipped: %d.", updated, skipped))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// spreadList repeats the list given with the flag name for each of n clients.
+// cobra accumulates repeated list flags into one slice, so a single list
+// applies to every targeted client.
+func spreadList(cmd *cobra.Command, name string, n int) [][]string {
+	list, _ := cmd.Flags().GetStringSlice(name)
+	if !cmd.Flags().Changed(name) || len(list) == 0 {
 		return nil
-	}),
+	}
+	out := make([][]string, n)
+	for i := range out {
+		out[i] = append([]string{}, list...)
+	}
+	return out
+}
+
+// printWarnings writes the non-fatal problems of a kcops result to stderr.
+func printWarnings(cmd *cobra.Command, r kcops.Result) {
+	for _, w := range r.Warnings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", w)
+	}
+}
+
+// clientsListOptions holds the flags of `kc clients list`.
+type clientsListOptions struct {
+	clientIDs []string
+	realms    []string
+	allRealms bool
+	normalize bool
+}
+
+func newClientsListCmd() *cobra.Command {
+	o := &clientsListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List clients",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "filter by client-id (single value supported)")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
 }
 
-var clientsDeleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete client(s)",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(cliIDs) == 0 {
-			return errors.New("missing --client-id: provide at least one --client-id")
+func (o *clientsListOptions) run(cmd *cobra.Command) error {
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	// across realms, each realm gets a header and its own count
+	grouped := len(realms) > 1
+	total := 0
+	lines := []string{}
+	var counts []string
+	for _, realm := range realms {
+		params := gocloak.GetClientsParams{}
+		// when filter by client-id provided as single value, we can use Search or ClientID
+		if len(o.clientIDs) == 1 {
+			params.ClientID = &o.clientIDs[0]
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
+		clients, err := gc.GetClients(ctx, token, realm, params)
 		if err != nil {
 			return err
 		}
-		realms, err := resolveRealmsForClients(cmd)
-		if err != nil {
+		var ids []string
+		for _, c := range clients {
+			if c.ClientID != nil {
+				ids = append(ids, *c.ClientID)
+			}
+		}
+		if o.normalize {
+			slices.Sort(ids)
+		}
+		total += len(ids)
+		if !grouped {
+			lines = append(lines, ids...)
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("Realm %q: %d client(s)", realm, len(ids)))
+		for _, id := range ids {
+			lines = append(lines, "  "+id)
+		}
+		counts = append(counts, fmt.Sprintf("%s: %d", realm, len(ids)))
+	}
+	if grouped {
+		lines = append(lines, fmt.Sprintf("Total: %d (%s)", total, strings.Join(counts, ", ")))
+	} else {
+		lines = append(lines, fmt.Sprintf("Total: %d", total))
+	}
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+	return nil
+}
+
+func newClientsScopesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scopes",
+		Short: "Manage client scope assignments",
+	}
+	cmd.AddCommand(newClientsScopesAssignCmd())
+	cmd.AddCommand(newClientsScopesRemoveCmd())
+	cmd.AddCommand(newClientsScopesSyncCmd())
+	return cmd
+}
+
+// clientsScopesOptions holds the flags of `kc clients scopes assign` and `remove`.
+type clientsScopesOptions struct {
+	clientID        string
+	scopes          []string
+	scopeType       string // default | optional
+	ignoreMissing   bool
+	realms          []string
+	allRealms       bool
+	match           matchOptions
+	dryRun          bool
+	continueOnError bool
+}
+
+func newClientsScopesAssignCmd() *cobra.Command {
+	o := &clientsScopesOptions{}
+	cmd := &cobra.Command{
+		Use:   "assign",
+		Short: "Assign client scopes to a client, or to every client matching a pattern",
+		Long: `Assign client scopes to the client given with --client-id, or with --match to
+every client whose clientId matches a glob (api-*) or a regex prefixed with
+re:, leaving out the clients Keycloak creates. The matched clients are listed
+and kc asks for confirmation before assigning. With --dry-run, kc lists the
+assignments it would make, skipping scopes the clients already have, and
+changes nothing.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.assign(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "target client-id (required unless --match)")
+	o.match.add(cmd, "client")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the assignments without changing anything")
+	cmd.Flags().StringSliceVar(&o.scopes, "scope", nil, "client scope name(s) to assign (required)")
+	cmd.Flags().StringVar(&o.scopeType, "type", "default", "assignment type: default|optional")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeClientScopes)
+	return cmd
+}
+
+func (o *clientsScopesOptions) assign(cmd *cobra.Command) error {
+	if o.clientID == "" && !o.match.active() {
+		return errors.New("missing --client-id: provide --client-id or --match")
+	}
+	var given []string
+	if o.clientID != "" {
+		given = []string{o.clientID}
+	}
+	if err := o.match.validate(cmd, "client-id", given, nil, nil); err != nil {
+		return err
+	}
+	if len(o.scopes) == 0 {
+		return errors.New("missing --scope: provide at least one --scope")
+	}
+	if o.scopeType != "default" && o.scopeType != "optional" {
+		return errors.New("invalid --type: must be 'default' or 'optional'")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		o.match.dryRun = o.dryRun
+		if err := o.match.resolve(cmd, "assign the scopes to", realms, listClientIDs(ctx, gc, token)); err != nil {
 			return err
 		}
+	}
 
-		deleted, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			for _, cid := range cliIDs {
-				c, err := getClientByClientID(ctx, gc, token, realm, cid)
-				if err != nil || c == nil || c.ID == nil {
-					if clientsIgnoreMiss {
-						fmt.Fprintf(cmd.OutOrStdout(), "Client %q not found in realm %q. Skipped.\n", cid, realm)
-						skipped++
-						continue
-					}
-					return fmt.Errorf("client %q not found in realm %s", cid, realm)
-				}
-				if err := gc.DeleteClient(ctx, token, realm, *c.ID); err != nil {
-					return fmt.Errorf("failed deleting client %q in realm %s: %w", cid, realm, err)
-				}
-				lines = append(lines, fmt.Sprintf("Deleted client %q (ID: %s) in realm %q.", cid, *c.ID, realm))
-				deleted++
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		clientIDs := o.match.names(realm, given)
+		if len(clientIDs) == 0 {
+			continue
+		}
+		// cache scopes in realm
+		realmScopes, err := gc.GetClientScopes(ctx, token, realm)
+		if err != nil {
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "realm", Realm: realm, Name: realm}, fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)); err != nil {
+				return err
 			}
+			continue
 		}
-		lines = append(lines, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", deleted, skipped))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		for _, cid := range clientIDs {
+			if err := o.assignToClient(ctx, cmd, gc, token, rep, realm, cid, realmScopes); err != nil {
+				return err
+			}
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	if o.dryRun {
+		return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Dry run: %d assignment(s) planned, %d skipped, nothing changed.", len(rep.result.Created), len(rep.result.Skipped)))
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Assigned: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
 }
 
-var clientsListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List clients",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
+// assignToClient assigns the --scope of o to the client with clientId cid
+// and reports each scope in rep. It returns an error only when the command
+// must stop.
+func (o *clientsScopesOptions) assignToClient(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string, rep *report, realm, cid string, realmScopes []*gocloak.ClientScope) error {
+	client, err := getClientByClientID(ctx, gc, token, realm, cid)
+	if err != nil || client == nil || client.ID == nil {
+		err := fmt.Errorf("client %q not found in realm %s", cid, realm)
+		return rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: cid}, err)
+	}
+	clientID := *client.ID
+	item := func(scope string) audit.ItemResult {
+		return audit.ItemResult{Kind: "scopeAssignment", Realm: realm, Name: cid + "/" + scope, ID: clientID}
+	}
+	var assigned []*gocloak.ClientScope
+	if o.dryRun {
+		if o.scopeType == "default" {
+			assigned, err = gc.GetClientsDefaultScopes(ctx, token, realm, clientID)
+		} else {
+			assigned, err = gc.GetClientsOptionalScopes(ctx, token, realm, clientID)
 		}
-		realms, err := resolveRealmsForClients(cmd)
 		if err != nil {
-			return err
+			return rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: cid}, fmt.Errorf("failed listing %s scopes of client %q in realm %s: %w", o.scopeType, cid, realm, err))
 		}
-
-		total := 0
-		lines := []string{}
-		for _, realm := range realms {
-			params := gocloak.GetClientsParams{}
-			// when filter by client-id provided as single value, we can use Search or ClientID
-			if len(cliIDs) == 1 {
-				params.ClientID = &cliIDs[0]
+	}
+	for _, sn := range o.scopes {
+		var scopeID string
+		for _, sc := range realmScopes {
+			if sc.Name != nil && *sc.Name == sn && sc.ID != nil {
+				scopeID = *sc.ID
+				break
 			}
-			clients, err := gc.GetClients(ctx, token, realm, params)
-			if err != nil {
+		}
+		if scopeID == "" {
+			if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("client scope %q not found in realm %s", sn, realm)); err != nil {
 				return err
 			}
-			for _, c := range clients {
-				if c.ClientID != nil {
-					lines = append(lines, *c.ClientID)
-					total++
+			continue
+		}
+		if o.dryRun {
+			if slices.ContainsFunc(assigned, func(sc *gocloak.ClientScope) bool { return gocloak.PString(sc.ID) == scopeID }) {
+				rep.skip(item(sn), "already assigned", fmt.Sprintf("Scope %q already %s for client %q in realm %q. Skipped.", sn, o.scopeType, cid, realm))
+			} else {
+				rep.add(kcops.Created, item(sn), fmt.Sprintf("Would assign %s scope %q to client %q in realm %q.", o.scopeType, sn, cid, realm))
+			}
+			continue
+		}
+		if o.scopeType == "default" {
+			if err := gc.AddDefaultScopeToClient(ctx, token, realm, clientID, scopeID); err != nil {
+				if strings.Contains(strings.ToLower(err.Error()), "409") {
+					rep.skip(item(sn), "already assigned", fmt.Sprintf("Scope %q already default for client %q in realm %q. Skipped.", sn, cid, realm))
+					continue
+				}
+				if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("failed assigning default scope %q to client %q in realm %s: %w", sn, cid, realm, err)); err != nil {
+					return err
 				}
+				continue
+			}
+		} else {
+			if err := gc.AddOptionalScopeToClient(ctx, token, realm, clientID, scopeID); err != nil {
+				if strings.Contains(strings.ToLower(err.Error()), "409") {
+					rep.skip(item(sn), "already assigned", fmt.Sprintf("Scope %q already optional for client %q in realm %q. Skipped.", sn, cid, realm))
+					continue
+				}
+				if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("failed assigning optional scope %q to client %q in realm %s: %w", sn, cid, realm, err)); err != nil {
+					return err
+				}
+				continue
 			}
 		}
-		lines = append(lines, fmt.Sprintf("Total: %d", total))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
-		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+		recordChange(cmd, realm, cid, clientID, audit.FieldChange{Field: o.scopeType + "ClientScopes", New: "+" + sn})
+		rep.add(kcops.Created, item(sn), fmt.Sprintf("Assigned %s scope %q to client %q in realm %q.", o.scopeType, sn, cid, realm))
+	}
+	return nil
 }
 
-var clientsScopesCmd = &cobra.Command{
-	Use:   "scopes",
-	Short: "Manage client scope assignments",
+func newClientsScopesRemoveCmd() *cobra.Command {
+	o := &clientsScopesOptions{}
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove client scopes from a client",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.remove(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "target client-id (required)")
+	cmd.Flags().StringSliceVar(&o.scopes, "scope", nil, "client scope name(s) to remove (required)")
+	cmd.Flags().StringVar(&o.scopeType, "type", "default", "assignment type: default|optional")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip scopes not found/assigned instead of failing")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	_ = cmd.RegisterFlagCompletionFunc("scope", completeClientScopes)
+	return cmd
 }
 
-var clientsScopesAssignCmd = &cobra.Command{
-	Use:   "assign",
-	Short: "Assign client scopes to a client",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if scopeClientID == "" {
-			return errors.New("missing --client-id")
-		}
-		if len(scopeNames) == 0 {
-			return errors.New("missing --scope: provide at least one --scope")
-		}
-		if scopeType != "default" && scopeType != "optional" {
-			return errors.New("invalid --type: must be 'default' or 'optional'")
+func (o *clientsScopesOptions) remove(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client-id")
+	}
+	if len(o.scopes) == 0 {
+		return errors.New("missing --scope: provide at least one --scope")
+	}
+	if o.scopeType != "default" && o.scopeType != "optional" {
+		return errors.New("invalid --type: must be 'default' or 'optional'")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		client, err := getClientByClientID(ctx, gc, token, realm, o.clientID)
+		if err != nil || client == nil || client.ID == nil {
+			err := fmt.Errorf("client %q not found in realm %s", o.clientID, realm)
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: o.clientID}, err); err != nil {
+				return err
+			}
+			continue
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
+		clientID := *client.ID
+		item := func(scope string) audit.ItemResult {
+			return audit.ItemResult{Kind: "scopeAssignment", Realm: realm, Name: o.clientID + "/" + scope, ID: clientID}
 		}
-		realms, err := resolveRealmsForClients(cmd)
+		// cache realm scopes
+		realmScopes, err := gc.GetClientScopes(ctx, token, realm)
 		if err != nil {
-			return err
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: o.clientID}, err); err != nil {
+				return err
+			}
+			continue
 		}
-
-		assigned, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			client, err := getClientByClientID(ctx, gc, token, realm, scopeClientID)
-			if err != nil || client == nil || client.ID == nil {
-				return fmt.Errorf("client %q not found in realm %s", scopeClientID, realm)
+		for _, sn := range o.scopes {
+			var scopeID string
+			for _, sc := range realmScopes {
+				if sc.Name != nil && *sc.Name == sn && sc.ID != nil {
+					scopeID = *sc.ID
+					break
+				}
 			}
-			clientID := *client.ID
-			// cache scopes in realm
-			realmScopes, err := gc.GetClientScopes(ctx, token, realm)
-			if err != nil {
-				return err
+			if scopeID == "" {
+				if o.ignoreMissing {
+					rep.skip(item(sn), "scope not found", fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", sn, realm))
+					continue
+				}
+				if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("client scope %q not found in realm %s", sn, realm)); err != nil {
+					return err
+				}
+				continue
 			}
-			for _, sn := range scopeNames {
-				var scopeID string
-				for _, sc := range realmScopes {
-					if sc.Name != nil && *sc.Name == sn && sc.ID != nil {
-						scopeID = *sc.ID
-						break
+			if o.scopeType == "default" {
+				if err := gc.RemoveDefaultScopeFromClient(ctx, token, realm, clientID, scopeID); err != nil {
+					if strings.Contains(strings.ToLower(err.Error()), "404") && o.ignoreMissing {
+						rep.skip(item(sn), "not assigned", fmt.Sprintf("Default scope %q not assigned to client %q in realm %q. Skipped.", sn, o.clientID, realm))
+						continue
 					}
+					if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("failed removing default scope %q from client %q in realm %s: %w", sn, o.clientID, realm, err)); err != nil {
+						return err
+					}
+					continue
 				}
-				if scopeID == "" {
-					return fmt.Errorf("client scope %q not found in realm %s", sn, realm)
-				}
-				if scopeType == "default" {
-					if err := gc.AddDefaultScopeToClient(ctx, token, realm, clientID, scopeID); err != nil {
-						if strings.Contains(strings.ToLower(err.Error()), "409") {
-							lines = append(lines, fmt.Sprintf("Scope %q already default for client %q in realm %q. Skipped.", sn, scopeClientID, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("failed assigning default scope %q to client %q in realm %s: %w", sn, scopeClientID, realm, err)
+			} else {
+				if err := gc.RemoveOptionalScopeFromClient(ctx, token, realm, clientID, scopeID); err != nil {
+					if strings.Contains(strings.ToLower(err.Error()), "404") && o.ignoreMissing {
+						rep.skip(item(sn), "not assigned", fmt.Sprintf("Optional scope %q not assigned to client %q in realm %q. Skipped.", sn, o.clientID, realm))
+						continue
 					}
-				} else {
-					if err := gc.AddOptionalScopeToClient(ctx, token, realm, clientID, scopeID); err != nil {
-						if strings.Contains(strings.ToLower(err.Error()), "409") {
-							lines = append(lines, fmt.Sprintf("Scope %q already optional for client %q in realm %q. Skipped.", sn, scopeClientID, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("failed assigning optional scope %q to client %q in realm %s: %w", sn, scopeClientID, realm, err)
+					if err := rep.failOrStop(o.continueOnError, item(sn), fmt.Errorf("failed removing optional scope %q from client %q in realm %s: %w", sn, o.clientID, realm, err)); err != nil {
+						return err
 					}
+					continue
 				}
-				lines = append(lines, fmt.Sprintf("Assigned %s scope %q to client %q in realm %q.", scopeType, sn, scopeClientID, realm))
-				assigned++
 			}
+			recordChange(cmd, realm, o.clientID, clientID, audit.FieldChange{Field: o.scopeType + "ClientScopes", New: "-" + sn})
+			rep.add(kcops.Deleted, item(sn), fmt.Sprintf("Removed %s scope %q from client %q in realm %q.", o.scopeType, sn, o.clientID, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Assigned: %d, Skipped: %d.", assigned, skipped))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Removed: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+func init() {
+	rootCmd.AddCommand(newClientsCmd())
+}
+
+// o.specs builds the clients to create from the flags.
+func (o *clientsCreateOptions) specs() []kcops.ClientSpec {
+	specs := make([]kcops.ClientSpec, len(o.clientIDs))
+	for i, cid := range o.clientIDs {
+		specs[i] = kcops.ClientSpec{ClientID: cid, Enabled: true}
+		specs[i].Name, _ = pick(o.names, i)
+		specs[i].Secret, _ = pick(o.secrets, i)
+		specs[i].Protocol, _ = pick(o.protocols, i)
+		specs[i].RootURL, _ = pick(o.rootURLs, i)
+		specs[i].BaseURL, _ = pick(o.baseURLs, i)
+		if v, ok := pick(o.enabled, i); ok {
+			specs[i].Enabled = v
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+		specs[i].PublicClient, _ = pick(o.publics, i)
+		specs[i].DirectAccessGrantsEnabled, _ = pick(o.directAccess, i)
+		specs[i].ServiceAccountsEnabled, _ = pick(o.serviceAccounts, i)
+		specs[i].Preset, _ = pick(o.presets, i)
+		specs[i].Auth, _ = pick(o.auths, i)
+		if i < len(o.redirectURIs) {
+			specs[i].RedirectURIs = o.redirectURIs[i]
+		}
+		if i < len(o.webOrigins) {
+			specs[i].WebOrigins = o.webOrigins[i]
+		}
+	}
+	return specs
 }
 
-var clientsScopesRemoveCmd = &cobra.Command{
-	Use:   "remove",
-	Short: "Remove client scopes from a client",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if scopeClientID == "" {
-			return errors.New("missing --client-id")
+// validatePresets checks the --preset names, or the presets of the template
+// specs, before anything is created. A preset decides whether the client is
+// public, so --public is rejected alongside it.
+func (o *clientsCreateOptions) validatePresets(tplSpecs []kcops.ClientSpec) error {
+	presets := o.presets
+	for _, s := range tplSpecs {
+		if s.Preset != "" {
+			presets = append(presets, s.Preset)
 		}
-		if len(scopeNames) == 0 {
-			return errors.New("missing --scope: provide at least one --scope")
+	}
+	for _, p := range presets {
+		if _, ok := kcops.ClientPresets[p]; !ok {
+			return fmt.Errorf("invalid --preset %q: use spa, backend, service or mobile", p)
 		}
-		if scopeType != "default" && scopeType != "optional" {
-			return errors.New("invalid --type: must be 'default' or 'optional'")
+	}
+	if len(o.presets) > 0 && len(o.publics) > 0 {
+		return errors.New("--public cannot be combined with --preset: the preset decides whether the client is public")
+	}
+	return nil
+}
+
+// samlSpecs reads the --from-saml-metadata files into one SAML client each.
+// The client ID, protocol, redirect URIs and credentials come from the
+// metadata, so the flags setting them are rejected; --name and --enabled
+// apply as usual.
+func (o *clientsCreateOptions) samlSpecs(cmd *cobra.Command) ([]kcops.ClientSpec, error) {
+	for _, f := range append([]string{"client-id", "template", "interactive", "protocol", "public", "secret", "preset", "redirect-uri", "service-accounts", "direct-access"}, clientAuthFlagNames...) {
+		if cmd.Flags().Changed(f) {
+			return nil, fmt.Errorf("--from-saml-metadata cannot be combined with --%s: the metadata describes the client", f)
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		gc, token, err := keycloak.Login(ctx)
+	}
+	webOrigins := spreadList(cmd, "web-origin", len(o.samlMetadata))
+	specs := make([]kcops.ClientSpec, len(o.samlMetadata))
+	for i, file := range o.samlMetadata {
+		b, err := os.ReadFile(file)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed reading --from-saml-metadata %s: %w", file, err)
 		}
-		realms, err := resolveRealmsForClients(cmd)
+		spec, err := kcops.SAMLClientFromMetadata(b)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("invalid --from-saml-metadata %s: %w", file, err)
+		}
+		spec.Name, _ = pick(o.names, i)
+		if v, ok := pick(o.enabled, i); ok {
+			spec.Enabled = v
 		}
+		spec.RootURL, _ = pick(o.rootURLs, i)
+		spec.BaseURL, _ = pick(o.baseURLs, i)
+		if i < len(webOrigins) {
+			spec.WebOrigins = webOrigins[i]
+		}
+		specs[i] = spec
+	}
+	return specs, nil
+}
 
-		removed, skipped := 0, 0
-		var lines []string
-		for _, realm := range realms {
-			client, err := getClientByClientID(ctx, gc, token, realm, scopeClientID)
-			if err != nil || client == nil || client.ID == nil {
-				return fmt.Errorf("client %q not found in realm %s", scopeClientID, realm)
-			}
-			clientID := *client.ID
-			// cache realm scopes
-			realmScopes, err := gc.GetClientScopes(ctx, token, realm)
+// o.fillInteractive asks for the parameters of one client when no
+// --client-id was given, then confirms the resulting request.
+func (o *clientsCreateOptions) fillInteractive(cmd *cobra.Command) error {
+	w := newWizard(cmd)
+	if err := w.askJira(); err != nil {
+		return err
+	}
+	if err := w.askRealms(&o.realms, &o.allRealms); err != nil {
+		return err
+	}
+
+	if len(o.clientIDs) == 0 {
+		cid, err := w.ask("Client-id", "", validateName)
+		if err != nil {
+			return err
+		}
+		o.clientIDs = []string{cid}
+		if len(o.names) == 0 {
+			v, err := w.ask("Display name", cid, nil)
 			if err != nil {
 				return err
 			}
-			for _, sn := range scopeNames {
-				var scopeID string
-				for _, sc := range realmScopes {
-					if sc.Name != nil && *sc.Name == sn && sc.ID != nil {
-						scopeID = *sc.ID
-						break
-					}
-				}
-				if scopeID == "" {
-					if scopeIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("Client scope %q not found in realm %q. Skipped.", sn, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("client scope %q not found in realm %s", sn, realm)
-				}
-				if scopeType == "default" {
-					if err := gc.RemoveDefaultScopeFromClient(ctx, token, realm, clientID, scopeID); err != nil {
-						if strings.Contains(strings.ToLower(err.Error()), "404") && scopeIgnoreMiss {
-							lines = append(lines, fmt.Sprintf("Default scope %q not assigned to client %q in realm %q. Skipped.", sn, scopeClientID, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("failed removing default scope %q from client %q in realm %s: %w", sn, scopeClientID, realm, err)
-					}
-				} else {
-					if err := gc.RemoveOptionalScopeFromClient(ctx, token, realm, clientID, scopeID); err != nil {
-						if strings.Contains(strings.ToLower(err.Error()), "404") && scopeIgnoreMiss {
-							lines = append(lines, fmt.Sprintf("Optional scope %q not assigned to client %q in realm %q. Skipped.", sn, scopeClientID, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("failed removing optional scope %q from client %q in realm %s: %w", sn, scopeClientID, realm, err)
-					}
+			o.names = []string{v}
+		}
+		if len(o.protocols) == 0 {
+			v, err := w.ask("Protocol (openid-connect or saml)", "openid-connect", func(s string) error {
+				if s != "openid-connect" && s != "saml" {
+					return errors.New("protocol must be openid-connect or saml")
 				}
-				lines = append(lines, fmt.Sprintf("Removed %s scope %q from client %q in realm %q.", scopeType, sn, scopeClientID, realm))
-				removed++
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			o.protocols = []string{v}
+		}
+		if len(o.publics) == 0 {
+			v, err := w.askBool("Public client (browser or mobile app without a secret)?", false)
+			if err != nil {
+				return err
 			}
+			o.publics = []bool{v}
 		}
-		lines = append(lines, fmt.Sprintf("Done. Removed: %d, Skipped: %d.", removed, skipped))
-		realmLabel := ""
-		if clientsAllRealms {
-			realmLabel = "all realms"
-		} else if len(clientsRealms) == 1 {
-			realmLabel = clientsRealms[0]
-		} else if len(realms) == 1 {
-			realmLabel = realms[0]
+		if len(o.rootURLs) == 0 {
+			v, err := w.ask("Root URL (optional, e.g. https://app.example.com)", "", validateURL)
+			if err != nil {
+				return err
+			}
+			o.rootURLs = []string{v}
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
-}
-
-func init() {
-	rootCmd.AddCommand(clientsCmd)
-
-	clientsCmd.AddCommand(clientsCreateCmd)
-	clientsCreateCmd.Flags().StringSliceVar(&cliIDs, "client-id", nil, "client-id(s). Repeatable; required.")
-	clientsCreateCmd.Flags().StringSliceVar(&cliNames, "name", nil, "name(s). Optional; 0, 1 or N matching --client-id.")
-	clientsCreateCmd.Flags().BoolSliceVar(&cliPublics, "public", nil, "public client(s). Optional; 0, 1 or N; default false")
-	clientsCreateCmd.Flags().StringSliceVar(&cliSecrets, "secret", nil, "secret(s). Optional; ignored for public clients")
-	clientsCreateCmd.Flags().BoolSliceVar(&cliEnabled, "enabled", nil, "enabled flag(s). Optional; 0, 1 or N; default true")
-	clientsCreateCmd.Flags().StringSliceVar(&cliProtocols, "protocol", nil, "protocol(s). Optional; 0, 1 or N; e.g. openid-connect")
-	clientsCreateCmd.Flags().StringSliceVar(&cliRootURLs, "root-url", nil, "root URL(s). Optional; 0, 1 or N")
-	clientsCreateCmd.Flags().StringSliceVar(&cliBaseURLs, "base-url", nil, "base URL(s). Optional; 0, 1 or N")
-	// For lists, accept comma-separated via repeated flag usage (cobra handles)
-	clientsCreateCmd.Flags().StringSlice("redirect-uri", nil, "redirect URI list per client; repeat flag per client")
-	clientsCreateCmd.Flags().StringSlice("web-origin", nil, "web origin list per client; repeat flag per client")
-	// Bind the above slice-of-slices manually in PreRunE? We'll parse at runtime: cobra can't directly bind [][]string easily.
-	// Approach: users can pass multiple --redirect-uri flags; cobra accumulates into one slice, which can't map per-client cleanly.
-	// To keep parity with current style, we'll allow only one list applied to all clients; advanced per-index lists can be added later.
-	// Therefore, we override: read once into tmp and apply to all by expanding.
-
-	clientsCmd.AddCommand(clientsUpdateCmd)
-	clientsUpdateCmd.Flags().StringSliceVar(&cliIDs, "client-id", nil, "client-id(s) to update. Repeatable; required.")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliNames, "name", nil, "new name(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliPublics, "public", nil, "set public flag(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliSecrets, "secret", nil, "new secret(s). Optional; ignored for public clients")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliEnabled, "enabled", nil, "set enabled flag(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliProtocols, "protocol", nil, "protocol(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliRootURLs, "root-url", nil, "root URL(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliBaseURLs, "base-url", nil, "base URL(s). Optional; 0, 1 or N")
-	clientsUpdateCmd.Flags().StringSlice("redirect-uri", nil, "redirect URI list to replace; applies to all targeted clients")
-	clientsUpdateCmd.Flags().StringSlice("web-origin", nil, "web origin list to replace; applies to all targeted clients")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliStandardFlows, "standard-flow", nil, "enable standard flow(s). Optional; 0,1 or N")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliDirectAccess, "direct-access", nil, "enable direct access grants(s). Optional; 0,1 or N")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliImplicitFlows, "implicit-flow", nil, "enable implicit flow(s). Optional; 0,1 or N")
-	clientsUpdateCmd.Flags().BoolSliceVar(&cliServiceAccounts, "service-accounts", nil, "enable service accounts(s). Optional; 0,1 or N")
-	clientsUpdateCmd.Flags().StringSliceVar(&cliNewClientIDs, "new-client-id", nil, "new client-id(s). Optional; 0,1 or N")
-	clientsUpdateCmd.Flags().BoolVar(&clientsIgnoreMiss, "ignore-missing", false, "skip clients not found instead of failing")
-
-	clientsCmd.AddCommand(clientsDeleteCmd)
-	clientsDeleteCmd.Flags().StringSliceVar(&cliIDs, "client-id", nil, "client-id(s) to delete. Repeatable; required.")
-	clientsDeleteCmd.Flags().BoolVar(&clientsIgnoreMiss, "ignore-missing", false, "skip clients not found instead of failing")
-
-	clientsCmd.AddCommand(clientsListCmd)
-	clientsListCmd.Flags().StringSliceVar(&cliIDs, "client-id", nil, "filter by client-id (single value supported)")
-
-	clientsCmd.AddCommand(clientsScopesCmd)
-	clientsScopesCmd.AddCommand(clientsScopesAssignCmd)
-	clientsScopesCmd.AddCommand(clientsScopesRemoveCmd)
-	clientsScopesAssignCmd.Flags().StringVar(&scopeClientID, "client-id", "", "target client-id (required)")
-	clientsScopesAssignCmd.Flags().StringSliceVar(&scopeNames, "scope", nil, "client scope name(s) to assign (required)")
-	clientsScopesAssignCmd.Flags().StringVar(&scopeType, "type", "default", "assignment type: default|optional")
-	clientsScopesRemoveCmd.Flags().StringVar(&scopeClientID, "client-id", "", "target client-id (required)")
-	clientsScopesRemoveCmd.Flags().StringSliceVar(&scopeNames, "scope", nil, "client scope name(s) to remove (required)")
-	clientsScopesRemoveCmd.Flags().StringVar(&scopeType, "type", "default", "assignment type: default|optional")
-	clientsScopesRemoveCmd.Flags().BoolVar(&scopeIgnoreMiss, "ignore-missing", false, "skip scopes not found/assigned instead of failing")
-
-	// realm scope for all subcommands
-	for _, c := range []*cobra.Command{clientsCreateCmd, clientsUpdateCmd, clientsDeleteCmd, clientsListCmd, clientsScopesAssignCmd, clientsScopesRemoveCmd} {
-		c.Flags().StringSliceVar(&clientsRealms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
-		c.Flags().BoolVar(&clientsAllRealms, "all-realms", false, "apply to all realms")
-	}
-
-	// Normalize redirect-uri/web-origin into per-index slices during PreRun for create/update
-	normalizeLists := func(cmd *cobra.Command) {
+		if len(o.baseURLs) == 0 {
+			v, err := w.ask("Base URL (optional)", "", nil)
+			if err != nil {
+				return err
+			}
+			o.baseURLs = []string{v}
+		}
+		// the lists of --redirect-uri/--web-origin were spread per client-id
+		// before the client-id was known
 		if cmd.Flags().Changed("redirect-uri") {
 			list, _ := cmd.Flags().GetStringSlice("redirect-uri")
-			if len(list) > 0 {
-				cliRedirectURIs = make([][]string, len(cliIDs))
-				for i := range cliIDs {
-					cliRedirectURIs[i] = append([]string{}, list...)
-				}
+			o.redirectURIs = [][]string{list}
+		} else {
+			var def []string
+			if o.rootURLs[0] != "" {
+				def = []string{strings.TrimSuffix(o.rootURLs[0], "/") + "/*"}
+			}
+			v, err := w.askList("Valid redirect URIs (optional)", def, func(s string) error {
+				_, err := checkRedirectURI(s)
+				return err
+			})
+			if err != nil {
+				return err
 			}
+			o.redirectURIs = [][]string{v}
 		}
 		if cmd.Flags().Changed("web-origin") {
 			list, _ := cmd.Flags().GetStringSlice("web-origin")
-			if len(list) > 0 {
-				cliWebOrigins = make([][]string, len(cliIDs))
-				for i := range cliIDs {
-					cliWebOrigins[i] = append([]string{}, list...)
+			o.webOrigins = [][]string{list}
+		} else {
+			var def []string
+			if len(o.redirectURIs[0]) > 0 {
+				// "+" allows the origins of the redirect URIs
+				def = []string{"+"}
+			}
+			v, err := w.askList("Web origins (optional)", def, func(s string) error {
+				_, err := checkWebOrigin(s)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			o.webOrigins = [][]string{v}
+		}
+		if o.protocols[0] == "openid-connect" {
+			v, err := w.askBool("Enable direct access grants (password grant)?", false)
+			if err != nil {
+				return err
+			}
+			o.directAccess = []bool{v}
+			if !o.publics[0] {
+				v, err := w.askBool("Enable service accounts (client credentials grant)?", false)
+				if err != nil {
+					return err
 				}
+				o.serviceAccounts = []bool{v}
 			}
 		}
 	}
-	clientsCreateCmd.PreRun = func(cmd *cobra.Command, args []string) { normalizeLists(cmd) }
-	clientsUpdateCmd.PreRun = func(cmd *cobra.Command, args []string) { normalizeLists(cmd) }
+	if len(o.enabled) == 0 {
+		v, err := w.askBool("Enabled?", true)
+		if err != nil {
+			return err
+		}
+		o.enabled = []bool{v}
+	}
+
+	return w.confirm("create", w.realmsLabel(o.realms, o.allRealms), kcops.CreateClientsRequest{Clients: o.specs()})
 }
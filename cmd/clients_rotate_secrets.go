@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// Client attributes kept by the client secret rotation policy of Keycloak
+// (secret-rotation executor), in seconds since the epoch.
+const (
+	secretCreationAttr       = "client.secret.creation.time"
+	secretExpirationAttr     = "client.secret.expiration.time"
+	rotatedSecretAttr        = "client.secret.rotated"
+	rotatedSecretExpiresAttr = "client.secret.rotated.expiration.time"
+)
+
+// clientsRotateSecretsOptions holds the flags of `kc clients rotate-secrets`.
+type clientsRotateSecretsOptions struct {
+	patterns        []string
+	clientIDs       []string
+	out             string
+	grace           time.Duration
+	dryRun          bool
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+// rotatedSecret is an entry of the --out file of `kc clients rotate-secrets`.
+type rotatedSecret struct {
+	Realm    string `json:"realm"`
+	ClientID string `json:"clientId"`
+	ID       string `json:"id"`
+	Secret   string `json:"secret"`
+	// ExpiresAt is when the new secret expires, set by a rotation policy.
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	// PreviousExpiresAt is when the old secret stops working: later than
+	// now only under a rotation policy.
+	PreviousExpiresAt string `json:"previousExpiresAt"`
+}
+
+func newClientsRotateSecretsCmd() *cobra.Command {
+	o := &clientsRotateSecretsOptions{}
+	cmd := &cobra.Command{
+		Use:   "rotate-secrets",
+		Short: "Regenerate the secrets of confidential clients and write them to a file",
+		Long: `Regenerate the secrets of the confidential clients matching --match (or
+named with --client-id), e.g. from a scheduled job:
+
+  kc clients rotate-secrets --match 'svc-*' --out secrets.json --grace 24h
+
+The new secrets are written only to the --out JSON file, created with mode
+0600, for a vault sync job to pick up; they never appear in the output, the
+log or the audit. The file is rewritten after every client, so it is
+complete up to a failure. Unlike --match of update and delete, nothing is
+asked on stdin; --dry-run lists the clients that would be rotated.
+
+When the realm has a client secret rotation policy (secret-rotation
+executor), Keycloak keeps the old secret working for a while; --grace sets
+how long, from now. Without such a policy the old secret stops working at
+once, so --grace cannot apply and the clients must pick up the new secret
+before their next token request. previousExpiresAt in the file tells which
+case applied. Public clients and clients authenticating with keys are
+skipped, as are the clients created by Keycloak. Rotations cannot be undone.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.patterns, "match", nil, "rotate the clients whose client-id matches a glob (svc-*) or a regex prefixed with re:. Repeatable")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "client-id(s) to rotate. Repeatable")
+	cmd.Flags().StringVar(&o.out, "out", "", "JSON file to create for the new secrets; must not exist (required unless --dry-run)")
+	cmd.Flags().DurationVar(&o.grace, "grace", 0, "keep the old secret working this long, e.g. 24h (needs a client secret rotation policy; default: the policy's)")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the clients that would be rotated without rotating them")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "rotate clients in all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *clientsRotateSecretsOptions) run(cmd *cobra.Command) error {
+	if len(o.patterns) == 0 && len(o.clientIDs) == 0 {
+		return errors.New("missing --match or --client-id: provide the clients to rotate")
+	}
+	if err := validatePatterns(o.patterns); err != nil {
+		return err
+	}
+	if o.grace < 0 {
+		return errors.New("invalid --grace: cannot be negative")
+	}
+	if o.out == "" && !o.dryRun {
+		return errors.New("missing --out: the new secrets are only written to this file")
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	// clients are selected in every realm first, so --out is only created
+	// when there is a secret to rotate
+	selected := make([][]*gocloak.Client, len(realms))
+	rotatable := 0
+	for i, realm := range realms {
+		clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+		if err != nil {
+			return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+		}
+		for _, c := range clients {
+			cid := gocloak.PString(c.ClientID)
+			if manifest.IsBuiltinClient(realm, cid) {
+				continue
+			}
+			if slices.Contains(o.clientIDs, cid) || slices.ContainsFunc(o.patterns, func(p string) bool { return matchName(p, cid) }) {
+				selected[i] = append(selected[i], c)
+				if noSecretReason(c) == "" {
+					rotatable++
+				}
+			}
+		}
+	}
+
+	var written []rotatedSecret
+	var f *os.File
+	if !o.dryRun && rotatable > 0 {
+		// created before the first rotation, so a bad path changes nothing
+		if f, err = os.OpenFile(o.out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600); errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("--out %s already exists; kc does not overwrite credential files", o.out)
+		} else if err != nil {
+			return fmt.Errorf("failed creating --out: %w", err)
+		}
+		defer func() {
+			f.Close()
+			// no secret was rotated: an empty file must not block the next run
+			if len(written) == 0 {
+				os.Remove(o.out)
+			}
+		}()
+	}
+
+	rep := newReport(cmd)
+	wouldRotate := 0
+	// stop fails the command, pointing at the secrets rotated before the failure
+	stop := func(err error) error {
+		if len(written) > 0 {
+			return fmt.Errorf("%w (the new secrets of the %d client(s) rotated before are in %s)", err, len(written), o.out)
+		}
+		return err
+	}
+	for i, realm := range realms {
+		rep.expect(len(selected[i]))
+		for _, c := range selected[i] {
+			cid, id := gocloak.PString(c.ClientID), gocloak.PString(c.ID)
+			item := audit.ItemResult{Kind: "client", Realm: realm, Name: cid, ID: id}
+			if reason := noSecretReason(c); reason != "" {
+				rep.skip(item, reason, fmt.Sprintf("Client %q in realm %q is a %s. Skipped.", cid, realm, reason))
+				continue
+			}
+			if o.dryRun {
+				wouldRotate++
+				rep.note(fmt.Sprintf("Would rotate the secret of client %q in realm %q.", cid, realm))
+				continue
+			}
+			entry, warning, err := o.rotate(ctx, gc, token, realm, c)
+			// a rotated secret is written also when a later step failed
+			if entry.Secret != "" {
+				written = append(written, entry)
+				if err := writeRotatedSecrets(f, written); err != nil {
+					return stop(fmt.Errorf("secret of client %q in realm %s was rotated but writing %s failed: %w", cid, realm, o.out, err))
+				}
+			}
+			if err != nil {
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return stop(err)
+				}
+				continue
+			}
+			// the audit entry keeps the fact of the rotation, never the secret
+			recordChange(cmd, realm, cid, id,
+				audit.FieldChange{Field: "secret", Old: "(set)", New: "(generated)"},
+				audit.FieldChange{Field: "previousSecretExpiresAt", New: entry.PreviousExpiresAt})
+			msg := fmt.Sprintf("Rotated the secret of client %q in realm %q; the old one works until %s.", cid, realm, entry.PreviousExpiresAt)
+			if warning != "" {
+				msg = fmt.Sprintf("Rotated the secret of client %q in realm %q; the old one stopped working (%s).", cid, realm, warning)
+			}
+			rep.add(kcops.Updated, item, msg)
+		}
+	}
+	if o.dryRun {
+		return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Dry run: %d secret(s) would be rotated, %d client(s) skipped.", wouldRotate, len(rep.result.Skipped)))
+	}
+	if len(written) > 0 {
+		rep.note(fmt.Sprintf("New secrets written to %s. Sync them and delete the file afterwards.", o.out))
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Rotated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// noSecretReason tells why client c has no secret to rotate, or "" when it
+// is a confidential client authenticating with its secret.
+func noSecretReason(c *gocloak.Client) string {
+	if gocloak.PBool(c.PublicClient) {
+		return "public client"
+	}
+	switch a := gocloak.PString(c.ClientAuthenticatorType); a {
+	case "", "client-secret", "client-secret-jwt":
+		return ""
+	default:
+		return "client authenticating with " + a
+	}
+}
+
+// rotate regenerates the secret of client c and, under a rotation policy,
+// keeps the old secret working for --grace. warning is set when the old
+// secret stopped working at once. The entry holds the new secret as soon as
+// it exists, also with an error.
+func (o *clientsRotateSecretsOptions) rotate(ctx context.Context, gc keycloak.API, token, realm string, c *gocloak.Client) (rotatedSecret, string, error) {
+	cid, id := gocloak.PString(c.ClientID), gocloak.PString(c.ID)
+	now := time.Now()
+	cred, err := gc.RegenerateClientSecret(ctx, token, realm, id)
+	if err != nil {
+		return rotatedSecret{}, "", fmt.Errorf("failed rotating the secret of client %q in realm %s: %w", cid, realm, err)
+	}
+	secret := gocloak.PString(cred.Value)
+	addSecret(ctx, secret)
+	entry := rotatedSecret{Realm: realm, ClientID: cid, ID: id, Secret: secret}
+
+	after, err := gc.GetClient(ctx, token, realm, id)
+	if err != nil {
+		return entry, "", fmt.Errorf("secret of client %q in realm %s was rotated but reading its expiry failed: %w", cid, realm, err)
+	}
+	attrs := map[string]string{}
+	if after.Attributes != nil {
+		attrs = *after.Attributes
+	}
+	entry.ExpiresAt = formatSecretTime(attrs[secretExpirationAttr])
+	if attrs[rotatedSecretAttr] == "" {
+		entry.PreviousExpiresAt = now.UTC().Format(time.RFC3339)
+		warning := "no client secret rotation policy in the realm"
+		if o.grace > 0 {
+			warning += ", so --grace does not apply"
+		}
+		return entry, warning, nil
+	}
+	if o.grace > 0 {
+		attrs[rotatedSecretExpiresAttr] = strconv.FormatInt(now.Add(o.grace).Unix(), 10)
+		after.Attributes = &attrs
+		if err := gc.UpdateClient(ctx, token, realm, *after); err != nil {
+			return entry, "", fmt.Errorf("secret of client %q in realm %s was rotated but setting --grace failed: %w", cid, realm, err)
+		}
+	}
+	entry.PreviousExpiresAt = formatSecretTime(attrs[rotatedSecretExpiresAttr])
+	return entry, "", nil
+}
+
+// formatSecretTime formats a time attribute of the secret rotation policy,
+// in seconds, as RFC 3339; "" when unset.
+func formatSecretTime(s string) string {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || sec <= 0 {
+		return ""
+	}
+	return formatEventTime(sec * 1000)
+}
+
+// writeRotatedSecrets rewrites the --out file with the secrets rotated so far.
+func writeRotatedSecrets(f *os.File, secrets []rotatedSecret) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(secrets)
+}
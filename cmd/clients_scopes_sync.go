@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// clientsScopesSyncOptions holds the flags of `kc clients scopes sync`.
+type clientsScopesSyncOptions struct {
+	clientID        string
+	defaults        []string
+	optionals       []string
+	realms          []string
+	allRealms       bool
+	dryRun          bool
+	continueOnError bool
+}
+
+func newClientsScopesSyncCmd() *cobra.Command {
+	o := &clientsScopesSyncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Make the scopes of a client exactly the given ones",
+		Long: `Assign and remove client scopes so that the default scopes of the client are
+exactly --default and its optional scopes exactly --optional, e.g. from a
+pipeline that keeps them in version control. A type whose flag is not given
+is left as it is; pass it empty (--optional "") to remove all its scopes.
+openid is not a client scope, Keycloak always grants it, and is ignored.
+
+All the scopes must exist in the realm before anything changes. With
+--dry-run, kc lists the changes without making them.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "target client-id (required)")
+	cmd.Flags().StringSliceVar(&o.defaults, "default", nil, "the default client scopes the client must have")
+	cmd.Flags().StringSliceVar(&o.optionals, "optional", nil, "the optional client scopes the client must have")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the changes without making them")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	_ = cmd.RegisterFlagCompletionFunc("default", completeClientScopes)
+	_ = cmd.RegisterFlagCompletionFunc("optional", completeClientScopes)
+	return cmd
+}
+
+// scopeSet is the wanted scopes of one assignment type.
+type scopeSet struct {
+	typ   string // default | optional
+	names []string
+}
+
+func (o *clientsScopesSyncOptions) run(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client-id")
+	}
+	var sets []scopeSet
+	if cmd.Flags().Changed("default") {
+		sets = append(sets, scopeSet{"default", syncScopeNames(o.defaults)})
+	}
+	if cmd.Flags().Changed("optional") {
+		sets = append(sets, scopeSet{"optional", syncScopeNames(o.optionals)})
+	}
+	if len(sets) == 0 {
+		return errors.New("missing --default or --optional: provide the scopes the client must have")
+	}
+	if len(sets) == 2 {
+		for _, n := range sets[0].names {
+			if slices.Contains(sets[1].names, n) {
+				return fmt.Errorf("client scope %q cannot be both default and optional", n)
+			}
+		}
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		if err := o.syncRealm(ctx, cmd, gc, token, rep, realm, sets); err != nil {
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "client", Realm: realm, Name: o.clientID}, err); err != nil {
+				return err
+			}
+		}
+	}
+	if o.dryRun {
+		return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Dry run: %d assignment(s) and %d removal(s) planned, nothing changed.", len(rep.result.Created), len(rep.result.Deleted)))
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Assigned: %d, Removed: %d.", len(rep.result.Created), len(rep.result.Deleted)))
+}
+
+// syncRealm reconciles the scopes of the client in realm to sets. Removals
+// go first, so a scope can move from default to optional and back.
+func (o *clientsScopesSyncOptions) syncRealm(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string, rep *report, realm string, sets []scopeSet) error {
+	client, err := getClientByClientID(ctx, gc, token, realm, o.clientID)
+	if err != nil || client == nil || client.ID == nil {
+		return fmt.Errorf("client %q not found in realm %s", o.clientID, realm)
+	}
+	clientID := *client.ID
+	realmScopes, err := gc.GetClientScopes(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+	}
+	ids := map[string]string{}
+	for _, sc := range realmScopes {
+		if sc.Name != nil && sc.ID != nil {
+			ids[*sc.Name] = *sc.ID
+		}
+	}
+	for _, set := range sets {
+		for _, n := range set.names {
+			if _, ok := ids[n]; !ok {
+				return fmt.Errorf("client scope %q not found in realm %s", n, realm)
+			}
+		}
+	}
+
+	type change struct {
+		typ, name string
+		add       bool
+	}
+	var removals, additions []change
+	for _, set := range sets {
+		var current []*gocloak.ClientScope
+		if set.typ == "default" {
+			current, err = gc.GetClientsDefaultScopes(ctx, token, realm, clientID)
+		} else {
+			current, err = gc.GetClientsOptionalScopes(ctx, token, realm, clientID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed listing %s scopes of client %q in realm %s: %w", set.typ, o.clientID, realm, err)
+		}
+		var names []string
+		for _, sc := range current {
+			if sc.Name != nil {
+				names = append(names, *sc.Name)
+			}
+		}
+		toAdd, toRemove := setDelta(names, set.names)
+		for _, n := range toRemove {
+			removals = append(removals, change{set.typ, n, false})
+		}
+		for _, n := range toAdd {
+			additions = append(additions, change{set.typ, n, true})
+		}
+	}
+
+	for _, c := range append(removals, additions...) {
+		item := audit.ItemResult{Kind: "scopeAssignment", Realm: realm, Name: o.clientID + "/" + c.name, ID: clientID}
+		if o.dryRun {
+			if c.add {
+				rep.add(kcops.Created, item, fmt.Sprintf("Would assign %s scope %q to client %q in realm %q.", c.typ, c.name, o.clientID, realm))
+			} else {
+				rep.add(kcops.Deleted, item, fmt.Sprintf("Would remove %s scope %q from client %q in realm %q.", c.typ, c.name, o.clientID, realm))
+			}
+			continue
+		}
+		scopeID := ids[c.name]
+		switch {
+		case c.add && c.typ == "default":
+			err = gc.AddDefaultScopeToClient(ctx, token, realm, clientID, scopeID)
+		case c.add:
+			err = gc.AddOptionalScopeToClient(ctx, token, realm, clientID, scopeID)
+		case c.typ == "default":
+			err = gc.RemoveDefaultScopeFromClient(ctx, token, realm, clientID, scopeID)
+		default:
+			err = gc.RemoveOptionalScopeFromClient(ctx, token, realm, clientID, scopeID)
+		}
+		if c.add {
+			if err != nil {
+				return fmt.Errorf("failed assigning %s scope %q to client %q in realm %s: %w", c.typ, c.name, o.clientID, realm, err)
+			}
+			recordChange(cmd, realm, o.clientID, clientID, audit.FieldChange{Field: c.typ + "ClientScopes", New: "+" + c.name})
+			rep.add(kcops.Created, item, fmt.Sprintf("Assigned %s scope %q to client %q in realm %q.", c.typ, c.name, o.clientID, realm))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed removing %s scope %q from client %q in realm %s: %w", c.typ, c.name, o.clientID, realm, err)
+		}
+		recordChange(cmd, realm, o.clientID, clientID, audit.FieldChange{Field: c.typ + "ClientScopes", New: "-" + c.name})
+		rep.add(kcops.Deleted, item, fmt.Sprintf("Removed %s scope %q from client %q in realm %q.", c.typ, c.name, o.clientID, realm))
+	}
+	if len(removals)+len(additions) == 0 {
+		rep.note(fmt.Sprintf("The scopes of client %q in realm %q are already in sync.", o.clientID, realm))
+	}
+	return nil
+}
+
+// syncScopeNames drops the empty names, left by an empty flag, duplicates
+// and openid, which Keycloak grants without a client scope.
+func syncScopeNames(names []string) []string {
+	out := []string{}
+	for _, n := range names {
+		if n != "" && n != "openid" && !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestClients(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		fail       []string
+		wantErr    string
+		want       wantResult
+		wantKind   string
+		wantFields map[string]string
+	}{
+		{
+			name:       "create",
+			args:       []string{"create", "--client-id", "web", "--preset", "spa", "--redirect-uri", "https://app.example.org/*"},
+			want:       wantResult{created: []string{"test/web"}},
+			wantKind:   "clients_create",
+			wantFields: map[string]string{"publicClient": "true", "redirectUris": "https://app.example.org/*"},
+		},
+		{
+			name: "create an existing client",
+			args: []string{"create", "--client-id", "api"},
+			want: wantResult{skipped: []string{"test/api"}},
+		},
+		{
+			name:    "create failing",
+			args:    []string{"create", "--client-id", "web"},
+			fail:    []string{"CreateClient"},
+			wantErr: `failed creating client "web" in realm test`,
+		},
+		{
+			name:       "update with --set",
+			args:       []string{"update", "--client-id", "api", "--set", "attributes.frontchannel.logout=true", "--set", "bearerOnly=true"},
+			want:       wantResult{updated: []string{"test/api"}},
+			wantKind:   "clients_update",
+			wantFields: map[string]string{"attributes.frontchannel.logout": "true", "bearerOnly": "true"},
+		},
+		{
+			name:    "update with --set of an unknown field",
+			args:    []string{"update", "--client-id", "api", "--set", "bearerOnlyy=true"},
+			wantErr: `client "api": invalid --set bearerOnlyy: no such field`,
+		},
+		{
+			name:    "update with --set of the clientId",
+			args:    []string{"update", "--client-id", "api", "--set", "clientId=web"},
+			wantErr: "invalid --set clientId: rename clients with --new-client-id",
+		},
+		{
+			name:     "delete",
+			args:     []string{"delete", "--client-id", "api"},
+			want:     wantResult{deleted: []string{"test/api"}},
+			wantKind: "clients_delete",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestAPI(t)
+			if _, err := fake.CreateClient(context.Background(), "", "test", gocloak.Client{ClientID: gocloak.StringP("api"), Enabled: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			api := &failingAPI{API: fake, fail: map[string]bool{}}
+			for _, name := range tt.fail {
+				api.fail[name] = true
+			}
+			_, entry, err := run(t, api, newClientsCmd, append([]string{"clients", "--realm", "test"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				checkEntry(t, entry, "error", wantResult{})
+				if len(entry.Details.Changes) > 0 {
+					t.Errorf("changes = %+v, want none", entry.Details.Changes)
+				}
+				return
+			}
+			checkEntry(t, entry, "ok", tt.want)
+			if tt.wantKind == "" {
+				return
+			}
+			c := changeOf(t, entry, "test", tt.args[2])
+			if c.Kind != tt.wantKind {
+				t.Errorf("change kind = %q, want %q", c.Kind, tt.wantKind)
+			}
+			for field, want := range tt.wantFields {
+				if f, _ := fieldOf(c, field); f.New != want {
+					t.Errorf("%s = %+v, want %q", field, f, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClientsRotateSecrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     wantResult
+		wantFile bool
+	}{
+		{
+			name:     "rotates confidential clients",
+			args:     []string{"--match", "svc-*"},
+			want:     wantResult{updated: []string{"test/svc-api"}, skipped: []string{"test/svc-web"}},
+			wantFile: true,
+		},
+		{
+			name: "creates no file without a secret to rotate",
+			args: []string{"--client-id", "svc-web"},
+			want: wantResult{skipped: []string{"test/svc-web"}},
+		},
+		{
+			name: "creates no file without a match",
+			args: []string{"--match", "other-*"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := newTestAPI(t)
+			if _, err := api.CreateClient(ctx, "", "test", gocloak.Client{ClientID: gocloak.StringP("svc-api")}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := api.CreateClient(ctx, "", "test", gocloak.Client{ClientID: gocloak.StringP("svc-web"), PublicClient: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(t.TempDir(), "secrets.json")
+			_, entry, err := run(t, api, newClientsCmd, append([]string{"clients", "rotate-secrets", "--realm", "test", "--out", out}, tt.args...)...)
+			checkErr(t, err, "")
+			checkEntry(t, entry, "ok", tt.want)
+			data, err := os.ReadFile(out)
+			if exists := err == nil; exists != tt.wantFile {
+				t.Fatalf("--out exists = %v, want %v", exists, tt.wantFile)
+			}
+			if !tt.wantFile {
+				return
+			}
+			var secrets []rotatedSecret
+			if err := json.Unmarshal(data, &secrets); err != nil || len(secrets) != 1 || secrets[0].Secret == "" {
+				t.Fatalf("--out = %s (%v)", data, err)
+			}
+			c := changeOf(t, entry, "test", "svc-api")
+			if f, _ := fieldOf(c, "secret"); f.New != "(generated)" {
+				t.Errorf("secret change = %+v, want it without the secret", f)
+			}
+		})
+	}
+}
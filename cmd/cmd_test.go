@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// run executes args, e.g. "users", "create", "--username", "alice", on a
+// command tree built by group, under a root like kc's, against api. It
+// returns the output and the audit entry the run wrote. Every run starts
+// from an empty audit file and a run state of its own.
+func run(t *testing.T, api keycloak.API, group func() *cobra.Command, args ...string) (string, audit.Entry, error) {
+	t.Helper()
+	config.Global = config.Config{AuthRealm: "master", Realm: "master"}
+	return runIn(t, "", api, group, args...)
+}
+
+// runIn is run with stdin and config.Global as set by the caller. Like the
+// real root it reads --stdin values and has --allow-protected.
+func runIn(t *testing.T, stdin string, api keycloak.API, group func() *cobra.Command, args ...string) (string, audit.Entry, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), audit.FileName)
+	audit.SetPath(path)
+	t.Cleanup(func() {
+		audit.SetPath(audit.FileName)
+		allowProtected = false
+	})
+	st := newRunState()
+	st.api = api
+
+	root := &cobra.Command{
+		Use:           "kc",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.WithValue(cmd.Context(), ctxKeyStart{}, time.Now())
+			cmd.SetContext(context.WithValue(ctx, ctxKeyEnded{}, false))
+			return readStdinValues(cmd)
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if ended, _ := cmd.Context().Value(ctxKeyEnded{}).(bool); !ended {
+				start, _ := cmd.Context().Value(ctxKeyStart{}).(time.Time)
+				end := time.Now()
+				appendAudit(cmd, "ok", start, end, end.Sub(start))
+			}
+		},
+	}
+	root.PersistentFlags().StringVar(&defaultRealm, "realm", "", "target realm")
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "output format")
+	root.PersistentFlags().BoolVar(&allowProtected, "allow-protected", false, "change protected realms")
+	root.AddCommand(group())
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetIn(strings.NewReader(stdin))
+	root.SetArgs(args)
+	err := root.ExecuteContext(withRunState(context.Background(), st))
+
+	entries, rerr := audit.Read(path)
+	if rerr != nil || len(entries) == 0 {
+		t.Fatalf("no audit entry for %v: %v (command error: %v)", args, rerr, err)
+	}
+	return out.String(), entries[len(entries)-1], err
+}
+
+// failingAPI is an API whose calls named in fail return a server error, e.g.
+// "DeleteUser", to drive the error paths of the commands.
+type failingAPI struct {
+	keycloak.API
+	fail map[string]bool
+}
+
+var errServer = errors.New("500 Internal Server Error: test failure")
+
+func (f *failingAPI) DeleteUser(ctx context.Context, token, realm, userID string) error {
+	if f.fail["DeleteUser"] {
+		return errServer
+	}
+	return f.API.DeleteUser(ctx, token, realm, userID)
+}
+
+func (f *failingAPI) CreateClient(ctx context.Context, token, realm string, client gocloak.Client) (string, error) {
+	if f.fail["CreateClient"] {
+		return "", errServer
+	}
+	return f.API.CreateClient(ctx, token, realm, client)
+}
+
+// newTestAPI returns a Fake with the realm "test".
+func newTestAPI(t *testing.T) *keycloak.Fake {
+	t.Helper()
+	f := keycloak.NewFake()
+	if _, err := f.CreateRealm(context.Background(), "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test"), Enabled: gocloak.BoolP(true)}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// itemNames lists the realm/name of items, in order.
+func itemNames(items []audit.ItemResult) []string {
+	names := make([]string, 0, len(items))
+	for _, it := range items {
+		names = append(names, it.Realm+"/"+it.Name)
+	}
+	return names
+}
+
+// wantResult is the expected result of a command run, by realm/name.
+type wantResult struct {
+	created, updated, deleted, skipped, failed []string
+}
+
+// checkEntry fails t unless entry has status and the items of want.
+func checkEntry(t *testing.T, entry audit.Entry, status string, want wantResult) {
+	t.Helper()
+	if entry.Status != status {
+		t.Errorf("audit status = %q, want %q", entry.Status, status)
+	}
+	res := entry.Details.Result
+	if res == nil {
+		res = &audit.Result{}
+	}
+	for _, c := range []struct {
+		what      string
+		got, want []string
+	}{
+		{"created", itemNames(res.Created), want.created},
+		{"updated", itemNames(res.Updated), want.updated},
+		{"deleted", itemNames(res.Deleted), want.deleted},
+		{"skipped", itemNames(res.Skipped), want.skipped},
+		{"failed", itemNames(res.Errors), want.failed},
+	} {
+		if !slices.Equal(c.got, c.want) && len(c.got)+len(c.want) > 0 {
+			t.Errorf("%s = %v, want %v", c.what, c.got, c.want)
+		}
+	}
+}
+
+// changeOf returns the audit change of entity in realm in entry.
+func changeOf(t *testing.T, entry audit.Entry, realm, entity string) audit.Change {
+	t.Helper()
+	for _, c := range entry.Details.Changes {
+		if c.Realm == realm && c.Entity == entity {
+			return c
+		}
+	}
+	t.Fatalf("no audit change of %q in realm %s in %+v", entity, realm, entry.Details.Changes)
+	return audit.Change{}
+}
+
+// fieldOf returns the change of field in c.
+func fieldOf(c audit.Change, field string) (audit.FieldChange, bool) {
+	for _, f := range c.Fields {
+		if f.Field == field {
+			return f, true
+		}
+	}
+	return audit.FieldChange{}, false
+}
+
+// checkErr fails t unless err contains want, or is nil when want is empty.
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Fatalf("expected an error containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Fatalf("error %q does not contain %q", err, want)
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the Keycloak calls made while the shell waits for
+// completions.
+const completionTimeout = 5 * time.Second
+
+// isCompletionCommand reports whether cmd prints a completion script or
+// answers a completion request; those run without config, logging or audit.
+func isCompletionCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		switch c.Name() {
+		case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+	return false
+}
+
+// registerCompletions adds completion of realm names and client-ids read from
+// the server to the flags taking them. Flags naming roles or client scopes
+// register theirs in the constructor of their command.
+func registerCompletions() {
+	_ = rootCmd.RegisterFlagCompletionFunc("realm", completeRealms)
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		for _, name := range []string{"realm", "exclude-realm"} {
+			if c.LocalNonPersistentFlags().Lookup(name) != nil {
+				_ = c.RegisterFlagCompletionFunc(name, completeRealms)
+			}
+		}
+		// clients create takes new client-ids; everywhere else they exist
+		if c.CommandPath() != "kc clients create" && c.LocalNonPersistentFlags().Lookup("client-id") != nil {
+			_ = c.RegisterFlagCompletionFunc("client-id", completeClientIDs)
+		}
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+	walk(rootCmd)
+}
+
+// completionSession logs in for a completion request. Failures give no
+// completions rather than an error, since the shell cannot show it.
+func completionSession() (context.Context, context.CancelFunc, keycloak.API, string, bool) {
+	if err := loadConfig(); err != nil {
+		return nil, nil, nil, "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	gc, token, err := connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, "", false
+	}
+	return ctx, cancel, gc, token, true
+}
+
+// completionRealm is the realm whose entities are completed: the first
+// --realm of the command, or the default realm.
+func completionRealm(cmd *cobra.Command) string {
+	if f := cmd.Flags().Lookup("realm"); f != nil && f.Changed {
+		if list := flagStrings(cmd, "realm"); len(list) > 0 {
+			return list[0]
+		}
+		if f.Value.Type() == "string" && f.Value.String() != "" {
+			return f.Value.String()
+		}
+	}
+	if defaultRealm != "" {
+		return defaultRealm
+	}
+	return config.Global.Realm
+}
+
+func completeRealms(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, gc, token, ok := completionSession()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+	names, err := listRealmNames(ctx, gc, token)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeClientIDs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, gc, token, ok := completionSession()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+	clients, err := gc.GetClients(ctx, token, completionRealm(cmd), gocloak.GetClientsParams{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var ids []string
+	for _, c := range clients {
+		if c.ClientID != nil {
+			ids = append(ids, *c.ClientID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeRealmRoles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, gc, token, ok := completionSession()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+	roles, err := gc.GetRealmRoles(ctx, token, completionRealm(cmd), gocloak.GetRoleParams{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return roleNamesOf(roles), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClientRoles completes roles of the client given with --client-id.
+func completeClientRoles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cid, _ := cmd.Flags().GetString("client-id")
+	if cid == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ctx, cancel, gc, token, ok := completionSession()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+	realm := completionRealm(cmd)
+	client, err := getClientByClientID(ctx, gc, token, realm, cid)
+	if err != nil || client.ID == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	roles, err := gc.GetClientRoles(ctx, token, realm, *client.ID, gocloak.GetRoleParams{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return roleNamesOf(roles), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeClientScopes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, gc, token, ok := completionSession()
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer cancel()
+	scopes, err := gc.GetClientScopes(ctx, token, completionRealm(cmd))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, s := range scopes {
+		if s.Name != nil {
+			names = append(names, *s.Name)
+		}
+	}
+	slices.Sort(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func roleNamesOf(roles []*gocloak.Role) []string {
+	var names []string
+	for _, r := range roles {
+		if r.Name != nil {
+			names = append(names, *r.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,464 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Create, inspect and change config.json",
+		Long: `Create, inspect and change the config file: --config, or the default
+config.json next to the binary or in the current directory. These commands
+run even when the file is missing or broken, so they can create or repair it.`,
+	}
+	cmd.AddCommand(newConfigInitCmd())
+	cmd.AddCommand(newConfigViewCmd())
+	cmd.AddCommand(newConfigSetCmd())
+	cmd.AddCommand(newConfigValidateCmd())
+	return cmd
+}
+
+// isConfigCommand reports whether cmd is one of the `kc config` commands,
+// which must start without a valid config file.
+func isConfigCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c.Name() == "config" && c.Parent() != nil && !c.Parent().HasParent() {
+			return true
+		}
+	}
+	return false
+}
+
+// configInitOptions holds the flags of `kc config init`.
+type configInitOptions struct {
+	force bool
+}
+
+func newConfigInitCmd() *cobra.Command {
+	o := &configInitOptions{}
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create config.json by answering questions, testing the login before saving",
+		Long: `Create the config file by answering questions: the server URL, the realm to
+log in to, the default target realm and the credentials of the client or
+admin user. Before the file is written, kc logs in with the answers; when
+that fails it asks whether to save anyway.
+
+An existing file is only replaced with --force; its values are then the
+defaults of the questions and the keys not asked about are kept.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.force, "force", false, "go through the questions again for an existing config file")
+	return cmd
+}
+
+func (o *configInitOptions) run(cmd *cobra.Command) error {
+	path := config.Path(cfgFile)
+	doc := map[string]any{}
+	existing, err := config.ReadDocument(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil && !o.force:
+		return fmt.Errorf("failed reading %s: %w", path, err)
+	case !o.force:
+		return fmt.Errorf("%s already exists: change it with kc config set, or use --force to answer the questions again", path)
+	case err != nil:
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: starting over, %v\n", err)
+	default:
+		doc = existing
+	}
+	before := map[string]string{}
+	for _, key := range config.Keys() {
+		before[key] = config.Lookup(doc, key)
+	}
+
+	w := newWizard(cmd)
+	required := func(validate func(string) error) func(string) error {
+		return func(s string) error {
+			if s == "" {
+				return errors.New("a value is required")
+			}
+			return validate(s)
+		}
+	}
+	answers := map[string]string{}
+	ask := func(key, label, def string, validate func(string) error) error {
+		if cur := config.Lookup(doc, key); cur != "" {
+			def = cur
+		}
+		v, err := w.ask(label, def, validate)
+		answers[key] = v
+		return err
+	}
+	// secrets are never shown as defaults; an empty answer keeps the current one
+	askSecret := func(key, label string) error {
+		if config.Lookup(doc, key) != "" {
+			label += " (leave empty to keep the current one)"
+		}
+		v, err := w.ask(label, "", func(s string) error {
+			if s == "" && config.Lookup(doc, key) == "" {
+				return errors.New("a value is required")
+			}
+			return nil
+		})
+		addSecret(cmd.Context(), v)
+		if v != "" {
+			answers[key] = v
+		}
+		return err
+	}
+
+	if err := ask("server_url", "Server URL, e.g. https://sso.example.com", "", required(validateURL)); err != nil {
+		return err
+	}
+	if err := ask("auth_realm", "Realm to log in to", "master", validateName); err != nil {
+		return err
+	}
+	if err := ask("realm", "Default target realm", answers["auth_realm"], validateName); err != nil {
+		return err
+	}
+	if err := ask("grant_type", "Grant type: client_credentials (service account) or password (admin user)", "client_credentials", func(s string) error {
+		if s != "client_credentials" && s != "password" {
+			return errors.New("answer client_credentials or password")
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if answers["grant_type"] == "password" {
+		if err := ask("username", "Admin username", "", validateName); err != nil {
+			return err
+		}
+		if err := askSecret("password", "Admin password"); err != nil {
+			return err
+		}
+	} else {
+		if err := ask("client_id", "Client ID of the service account", "", validateName); err != nil {
+			return err
+		}
+		if err := askSecret("client_secret", "Client secret"); err != nil {
+			return err
+		}
+	}
+	for key, v := range answers {
+		if err := config.Set(doc, key, v); err != nil {
+			return err
+		}
+	}
+	data, err := config.MarshalDocument(doc)
+	if err != nil {
+		return err
+	}
+	c, err := config.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "\nLogging in to %s ...\n", c.ServerURL)
+	connection := "ok"
+	if err := testLogin(cmd, c); err != nil {
+		fmt.Fprintf(cmd.OutOrStdout(), "Login failed: %v\n", err)
+		save, err := w.askBool("Save anyway?", false)
+		if err != nil {
+			return err
+		}
+		if !save {
+			return errWizardAborted
+		}
+		connection = "failed"
+	}
+	if err := config.WriteDocument(path, doc); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	var lines []string
+	for _, key := range config.Keys() {
+		if now := config.Lookup(doc, key); now != before[key] {
+			recordConfigChange(cmd, path, key, before[key], now)
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Wrote %s.", path))
+	lines = append(lines, fmt.Sprintf("Login as %s: %s.", loginName(c), connection))
+	for _, p := range config.Check(c) {
+		lines = append(lines, "Warning: "+p)
+	}
+	printBox(cmd, lines, "")
+	return nil
+}
+
+// testLogin logs in with c instead of the loaded config.
+func testLogin(cmd *cobra.Command, c config.Config) error {
+	loaded := config.Global
+	config.Global = c
+	defer func() { config.Global = loaded }()
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	_, _, err := connect(ctx)
+	return err
+}
+
+// loginName names the account c logs in with.
+func loginName(c config.Config) string {
+	if c.GrantType == "password" {
+		return "user " + c.Username
+	}
+	return "client " + c.ClientID
+}
+
+// recordConfigChange records a change of key in the config file for the
+// audit, without the values of secrets.
+func recordConfigChange(cmd *cobra.Command, path, key, old, now string) {
+	if isSensitiveConfigKey(key) {
+		old, now = setMarker(old), setMarker(now)
+	}
+	recordChange(cmd, "", path, "", audit.FieldChange{Field: key, Old: old, New: now})
+}
+
+// setMarker stands for a secret in the audit: "(set)" when there is one.
+func setMarker(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "(set)"
+}
+
+// configViewOptions holds the flags of `kc config view`.
+type configViewOptions struct {
+	redact bool
+}
+
+func newConfigViewCmd() *cobra.Command {
+	o := &configViewOptions{}
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print the config file",
+		Long: `Print the config file as JSON, keys in a fixed order. With --redact the
+client secret, password, serve tokens and notification webhook are replaced
+by ***, so the output can be shared.`,
+		Args: cobra.NoArgs,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.redact, "redact", false, "mask credentials, tokens and webhooks")
+	return cmd
+}
+
+func (o *configViewOptions) run(cmd *cobra.Command) error {
+	path := config.Path(cfgFile)
+	doc, err := config.ReadDocument(path)
+	if err != nil {
+		return fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	if o.redact {
+		redactConfig(doc)
+	}
+	data, err := config.MarshalDocument(doc)
+	if err != nil {
+		return err
+	}
+	_, err = cmd.OutOrStdout().Write(data)
+	return err
+}
+
+// configSetOptions holds the flags of `kc config set`.
+type configSetOptions struct{}
+
+func newConfigSetCmd() *cobra.Command {
+	o := &configSetOptions{}
+	cmd := &cobra.Command{
+		Use:   "set <key>=<value>...",
+		Short: "Change keys of the config file",
+		Long: fmt.Sprintf(`Change keys of the config file, creating it if needed. Values are converted
+to the type of the key: numbers, comma-separated lists (protected_realms) or
+strings. An empty value removes the key, and - reads the value from stdin so
+secrets stay out of the shell history:
+
+  kc config set server_url=https://sso.example.com realm=acme
+  kc config set log_rotation.max_size_mb=10 serve_tokens.ci=
+  kc config set client_secret=- < secret.txt
+
+The file is only written when the result is valid. Keys:
+
+  %s`, strings.Join(config.Keys(), "\n  ")),
+		Args: cobra.MinimumNArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, args)
+		}),
+	}
+	return cmd
+}
+
+func (o *configSetOptions) run(cmd *cobra.Command, args []string) error {
+	path := config.Path(cfgFile)
+	doc, err := config.ReadDocument(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		doc = map[string]any{}
+	} else if err != nil {
+		return fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	type assignment struct{ key, old, value string }
+	var set []assignment
+	stdinUsed := false
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid %q: use <key>=<value>", arg)
+		}
+		if value == "-" {
+			if stdinUsed {
+				return errors.New("only one value can be read from stdin")
+			}
+			stdinUsed = true
+			b, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return fmt.Errorf("failed reading %s from stdin: %w", key, err)
+			}
+			value = strings.TrimSpace(string(b))
+		}
+		if isSensitiveConfigKey(key) {
+			addSecret(cmd.Context(), value)
+		}
+		old := config.Lookup(doc, key)
+		if err := config.Set(doc, key, value); err != nil {
+			return err
+		}
+		set = append(set, assignment{key, old, config.Lookup(doc, key)})
+	}
+	data, err := config.MarshalDocument(doc)
+	if err != nil {
+		return err
+	}
+	c, err := config.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s was not changed: %w", path, err)
+	}
+	if err := config.WriteDocument(path, doc); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+
+	var lines []string
+	for _, a := range set {
+		recordConfigChange(cmd, path, a.key, a.old, a.value)
+		switch {
+		case a.value == "":
+			lines = append(lines, fmt.Sprintf("Removed %s.", a.key))
+		case isSensitiveConfigKey(a.key):
+			lines = append(lines, fmt.Sprintf("Set %s.", a.key))
+		default:
+			lines = append(lines, fmt.Sprintf("Set %s = %s.", a.key, a.value))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Wrote %s.", path))
+	for _, p := range config.Check(c) {
+		lines = append(lines, "Warning: "+p)
+	}
+	printBox(cmd, lines, "")
+	return nil
+}
+
+// configValidateOptions holds the flags of `kc config validate`.
+type configValidateOptions struct {
+	login bool
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	o := &configValidateOptions{}
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config file for errors, unknown keys and missing credentials",
+		Long: `Check the config file: that it is valid JSON that kc loads, that it holds
+the credentials its grant type needs, that its URLs are absolute http(s) URLs
+and that it has no keys kc does not know, such as misspelled ones. With
+--login, kc also logs in with it. The command fails when a problem is found,
+so it can gate a deployment.`,
+		Args: cobra.NoArgs,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.login, "login", false, "also log in to the server with the file")
+	return cmd
+}
+
+// configValidation is the result of `kc config validate`.
+type configValidation struct {
+	File     string   `json:"file"`
+	Valid    bool     `json:"valid"`
+	Problems []string `json:"problems"`
+}
+
+func (o *configValidateOptions) run(cmd *cobra.Command) error {
+	path := config.Path(cfgFile)
+	res := configValidation{File: path, Problems: []string{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed reading %s: %w", path, err)
+	}
+	doc := map[string]any{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		res.Problems = append(res.Problems, fmt.Sprintf("not a JSON object: %v", err))
+	} else if c, err := config.Parse(data); err != nil {
+		res.Problems = append(res.Problems, err.Error())
+		res.Problems = append(res.Problems, unknownKeyProblems(doc)...)
+	} else {
+		res.Problems = append(res.Problems, unknownKeyProblems(doc)...)
+		res.Problems = append(res.Problems, config.Check(c)...)
+		if o.login && len(res.Problems) == 0 {
+			if err := testLogin(cmd, c); err != nil {
+				res.Problems = append(res.Problems, fmt.Sprintf("login as %s failed: %v", loginName(c), err))
+			}
+		}
+	}
+	res.Valid = len(res.Problems) == 0
+
+	if outputFormat == "json" {
+		if err := printJSON(cmd, res); err != nil {
+			return err
+		}
+	} else {
+		lines := []string{fmt.Sprintf("File: %s", path)}
+		for _, p := range res.Problems {
+			lines = append(lines, "  - "+p)
+		}
+		if res.Valid {
+			lines = append(lines, "No problems found.")
+		} else {
+			lines = append(lines, fmt.Sprintf("Problems: %d.", len(res.Problems)))
+		}
+		printBox(cmd, lines, "")
+	}
+	if !res.Valid {
+		// the problems are listed above
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s has %d problem(s)", path, len(res.Problems))
+	}
+	return nil
+}
+
+// unknownKeyProblems reports the keys of doc kc does not read.
+func unknownKeyProblems(doc map[string]any) []string {
+	var out []string
+	for _, k := range config.UnknownKeys(doc) {
+		out = append(out, fmt.Sprintf("unknown key %q", k))
+	}
+	return out
+}
+
+func init() {
+	rootCmd.AddCommand(newConfigCmd())
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/spf13/cobra"
+)
+
+// commandContext derives the context for a command run from cmd.Context(),
+// which is cancelled on Ctrl-C, bounded by the global --timeout or else by the
+// command's default timeout.
+func commandContext(cmd *cobra.Command, def time.Duration) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if commandTimeout > 0 {
+		def = commandTimeout
+	}
+	return context.WithTimeout(ctx, def)
+}
+
+// addRealmSelectionFlags registers --realm-match, --exclude-realm and
+// --realm-file on commands that can target several realms. They are read
+// back from cmd by resolveRealms.
+func addRealmSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("realm-match", nil, "target realms matching a glob (tenant-*) or a regex prefixed with re: (re:^tenant-[0-9]+$)")
+	cmd.Flags().StringSlice("exclude-realm", nil, "realm name or pattern to leave out of the target realms")
+	cmd.Flags().String("realm-file", "", "file listing target realms, one per line (# starts a comment)")
+}
+
+// resolveSingleRealm returns the realm of a command that works on one realm
+// only: its --realm flag or else the default realm.
+func resolveSingleRealm(cmd *cobra.Command) (string, error) {
+	realms, err := resolveRealms(cmd.Context(), cmd, nil, "")
+	if err != nil {
+		return "", err
+	}
+	return realms[0], nil
+}
+
+// resolveRealms returns the realms a command targets: every realm with
+// --all-realms, the realms matching --realm-match, the values of the
+// command's --realm flag and --realm-file, or else the default realm from the
+// global flag or config.json. --exclude-realm is applied last. gc is only used
+// to list realms for --all-realms and --realm-match. With --preflight, the
+// admin roles of a mutating command are checked in every realm returned.
+func resolveRealms(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string) ([]string, error) {
+	realms, err := selectRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return nil, err
+	}
+	if excludes := flagStrings(cmd, "exclude-realm"); len(excludes) > 0 {
+		realms = slices.DeleteFunc(realms, func(r string) bool {
+			return slices.ContainsFunc(excludes, func(p string) bool { return matchName(p, r) })
+		})
+		if len(realms) == 0 {
+			return nil, errors.New("no target realms left after --exclude-realm")
+		}
+	}
+	if err := preflight(ctx, cmd, gc, token, realms); err != nil {
+		return nil, err
+	}
+	return realms, nil
+}
+
+func selectRealms(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string) ([]string, error) {
+	if all, err := cmd.Flags().GetBool("all-realms"); err == nil && all {
+		return listRealmNames(ctx, gc, token)
+	}
+	for _, p := range flagStrings(cmd, "realm-match") {
+		if strings.HasPrefix(p, "re:") {
+			if _, err := regexp.Compile(strings.TrimPrefix(p, "re:")); err != nil {
+				return nil, fmt.Errorf("invalid --realm-match %q: %w", p, err)
+			}
+		} else if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid --realm-match %q: %w", p, err)
+		}
+	}
+
+	var realms []string
+	if f := cmd.Flags().Lookup("realm"); f != nil {
+		switch f.Value.Type() {
+		case "stringSlice":
+			realms, _ = cmd.Flags().GetStringSlice("realm")
+			realms = append([]string{}, realms...)
+		case "string":
+			if v := f.Value.String(); v != "" {
+				realms = []string{v}
+			}
+		}
+	}
+	if file, _ := cmd.Flags().GetString("realm-file"); file != "" {
+		fromFile, err := readRealmFile(file)
+		if err != nil {
+			return nil, err
+		}
+		realms = append(realms, fromFile...)
+	}
+	if patterns := flagStrings(cmd, "realm-match"); len(patterns) > 0 {
+		all, err := listRealmNames(ctx, gc, token)
+		if err != nil {
+			return nil, err
+		}
+		matched := 0
+		for _, r := range all {
+			if slices.ContainsFunc(patterns, func(p string) bool { return matchName(p, r) }) {
+				realms = append(realms, r)
+				matched++
+			}
+		}
+		if matched == 0 {
+			return nil, fmt.Errorf("no realm matches --realm-match %s", strings.Join(patterns, ", "))
+		}
+	}
+	if len(realms) > 0 {
+		// a realm listed and matched is still processed once
+		seen := map[string]bool{}
+		return slices.DeleteFunc(realms, func(r string) bool {
+			dup := seen[r]
+			seen[r] = true
+			return dup
+		}), nil
+	}
+
+	r := defaultRealm
+	if r == "" {
+		r = config.Global.Realm
+	}
+	if r == "" {
+		return nil, errors.New("target realm not specified. Use --realm or set realm in config.json")
+	}
+	return []string{r}, nil
+}
+
+// matchName reports whether a realm or entity name matches pattern: a regex
+// when prefixed with "re:", otherwise a glob (a plain name matches only itself).
+func matchName(pattern, name string) bool {
+	if re, ok := strings.CutPrefix(pattern, "re:"); ok {
+		m, err := regexp.MatchString(re, name)
+		return err == nil && m
+	}
+	m, err := path.Match(pattern, name)
+	return err == nil && m
+}
+
+func readRealmFile(file string) ([]string, error) {
+	realms, err := readListFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading --realm-file: %w", err)
+	}
+	if len(realms) == 0 {
+		return nil, fmt.Errorf("--realm-file %s lists no realms", file)
+	}
+	return realms, nil
+}
+
+// readListFile returns the entries of a file listing one per line; blank
+// lines are skipped and # starts a comment.
+func readListFile(file string) ([]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			list = append(list, line)
+		}
+	}
+	return list, nil
+}
+
+// flagStrings returns a string slice flag, or nil when cmd does not define it.
+func flagStrings(cmd *cobra.Command, name string) []string {
+	v, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// realmsLabel is the realm shown in the output box header for resolved realms.
+func realmsLabel(cmd *cobra.Command, realms []string) string {
+	if all, err := cmd.Flags().GetBool("all-realms"); err == nil && all {
+		if len(flagStrings(cmd, "exclude-realm")) > 0 {
+			return "all realms (with exclusions)"
+		}
+		return "all realms"
+	}
+	if p := flagStrings(cmd, "realm-match"); len(p) > 0 && len(realms) != 1 {
+		return strings.Join(p, ", ")
+	}
+	if len(realms) == 1 {
+		return realms[0]
+	}
+	return ""
+}
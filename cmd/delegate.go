@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// delegateCapability is what a capability of `kc delegate` is made of:
+// realm-management roles when it covers the whole realm, or scopes of the
+// admin permissions of a group plus the roles needed to find its members
+// when it is scoped with --group.
+type delegateCapability struct {
+	realmRoles  []string
+	groupScopes []string
+	groupRoles  []string
+}
+
+// delegateCapabilities are the capabilities `kc delegate` can hand out.
+// Keycloak has no finer permission than managing a user to reset their
+// password, so reset-password and manage-users grant the same.
+var delegateCapabilities = map[string]delegateCapability{
+	"view-users":        {realmRoles: []string{"view-users"}, groupScopes: []string{"view-members"}, groupRoles: []string{"query-users", "query-groups"}},
+	"manage-users":      {realmRoles: []string{"manage-users"}, groupScopes: []string{"view-members", "manage-members"}, groupRoles: []string{"query-users", "query-groups"}},
+	"reset-password":    {realmRoles: []string{"manage-users"}, groupScopes: []string{"view-members", "manage-members"}, groupRoles: []string{"query-users", "query-groups"}},
+	"manage-membership": {realmRoles: []string{"manage-users"}, groupScopes: []string{"view-members", "manage-membership"}, groupRoles: []string{"query-users", "query-groups"}},
+	"view-clients":      {realmRoles: []string{"view-clients"}},
+	"view-events":       {realmRoles: []string{"view-events"}},
+	"impersonate":       {realmRoles: []string{"impersonation"}},
+}
+
+// delegateOptions holds the flags of `kc delegate`.
+type delegateOptions struct {
+	realm        string
+	group        string
+	capabilities []string
+	granteeType  string
+	dryRun       bool
+}
+
+func newDelegateCmd() *cobra.Command {
+	o := &delegateOptions{}
+	cmd := &cobra.Command{
+		Use:   "delegate <grantee>",
+		Short: "Delegate admin capabilities on a realm or a group to a group or user",
+		Long: `Delegate admin capabilities to a group (or, with --grantee-type user, a user)
+of the realm. Without --group the capabilities cover the whole realm and are
+granted as realm-management roles. With --group they only cover the members of
+that group: kc enables fine-grained admin permissions on it, grants their
+scopes to the grantee and assigns the query roles needed to find those users.
+
+Capabilities: ` + strings.Join(slices.Sorted(maps.Keys(delegateCapabilities)), ", ") + `.
+view-clients, view-events and impersonate cannot be scoped to a group.
+
+For example, to let the helpdesk group reset passwords of the users in
+/support only:
+
+  kc delegate helpdesk --realm myrealm --group /support --capabilities reset-password,view-users`,
+		Args: cobra.ExactArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, args[0])
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-realm", "manage-authorization")
+	cmd.Flags().StringSliceVar(&o.capabilities, "capabilities", nil, "capabilities to delegate, comma-separated (required)")
+	cmd.Flags().StringVar(&o.group, "group", "", "limit the capabilities to the members of this group, by path")
+	cmd.Flags().StringVar(&o.granteeType, "grantee-type", "group", "what <grantee> names: group (a path; the leading / is optional) or user (a username)")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "show the roles and scopes that would be granted without changing anything")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+// plan returns the realm-management roles and the group scopes the
+// capabilities need.
+func (o *delegateOptions) plan() (roles, scopes []string, err error) {
+	for _, c := range o.capabilities {
+		capability, ok := delegateCapabilities[c]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown capability %q: use %s", c, strings.Join(slices.Sorted(maps.Keys(delegateCapabilities)), ", "))
+		}
+		if o.group == "" {
+			roles = append(roles, capability.realmRoles...)
+			continue
+		}
+		if len(capability.groupScopes) == 0 {
+			return nil, nil, fmt.Errorf("capability %q cannot be scoped to a group; drop --group to grant it on the whole realm", c)
+		}
+		roles = append(roles, capability.groupRoles...)
+		scopes = append(scopes, capability.groupScopes...)
+	}
+	slices.Sort(roles)
+	slices.Sort(scopes)
+	return slices.Compact(roles), slices.Compact(scopes), nil
+}
+
+func (o *delegateOptions) run(cmd *cobra.Command, grantee string) error {
+	if len(o.capabilities) == 0 {
+		return errors.New("missing --capabilities: provide at least one capability")
+	}
+	var p adminPrincipal
+	switch o.granteeType {
+	case "group":
+		p = adminPrincipal{kind: "group", name: "/" + strings.TrimPrefix(grantee, "/")}
+	case "user":
+		p = adminPrincipal{kind: "user", name: grantee}
+	default:
+		return fmt.Errorf("invalid --grantee-type %q: use group or user", o.granteeType)
+	}
+	roles, scopes, err := o.plan()
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	granteeID, err := delegateGranteeID(ctx, gc, token, realm, p)
+	if err != nil {
+		return err
+	}
+	var target keycloak.PermissionTarget
+	if o.group != "" {
+		t := permissionTargetFlags{group: o.group}
+		if target, err = t.resolve(ctx, gc, token, realm); err != nil {
+			return err
+		}
+	}
+
+	rep := newReport(cmd)
+	rep.note(fmt.Sprintf("Delegating %s to %s", strings.Join(o.capabilities, ", "), p))
+	if o.dryRun {
+		if len(roles) > 0 {
+			rep.note("Would assign realm-management roles: " + strings.Join(roles, ", "))
+		}
+		if len(scopes) > 0 {
+			rep.note(fmt.Sprintf("Would grant on %s: %s", target, strings.Join(scopes, ", ")))
+		}
+		return rep.print(cmd, realm, "Dry run: nothing changed.")
+	}
+
+	assigned, err := assignAdminRoles(ctx, cmd, gc, token, realm, rep, p, granteeID, roles)
+	if err != nil {
+		return err
+	}
+	granted := 0
+	if len(scopes) > 0 {
+		if granted, err = grantAdminScopes(ctx, cmd, gc, token, realm, rep, target, scopes, p, true); err != nil {
+			return err
+		}
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Roles assigned: %d, Scopes granted: %d, Skipped: %d.", assigned, granted, len(rep.result.Skipped)))
+}
+
+// delegateGranteeID returns the ID of the group or user p.
+func delegateGranteeID(ctx context.Context, gc keycloak.API, token, realm string, p adminPrincipal) (string, error) {
+	if p.kind == "user" {
+		return principalUserID(ctx, gc, token, realm, p.name)
+	}
+	g, err := gc.GetGroupByPath(ctx, token, realm, p.name)
+	if err != nil {
+		return "", fmt.Errorf("failed looking up group %q in realm %s: %w", p.name, realm, err)
+	}
+	return gocloak.PString(g.ID), nil
+}
+
+// assignAdminRoles maps the realm-management roles to the group or user p,
+// skipping the roles it already holds directly. It returns how many roles it
+// assigned.
+func assignAdminRoles(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token, realm string, rep *report, p adminPrincipal, granteeID string, roles []string) (int, error) {
+	if len(roles) == 0 {
+		return 0, nil
+	}
+	rmID, err := keycloak.RealmManagementID(ctx, gc, token, realm)
+	if err != nil {
+		return 0, err
+	}
+	available, err := gc.GetClientRoles(ctx, token, realm, rmID, gocloak.GetRoleParams{})
+	if err != nil {
+		return 0, fmt.Errorf("failed listing the roles of %s in realm %s: %w", keycloak.RealmManagementClientID, realm, err)
+	}
+	var held []*gocloak.Role
+	if p.kind == "user" {
+		held, err = gc.GetClientRolesByUserID(ctx, token, realm, rmID, granteeID)
+	} else {
+		held, err = gc.GetClientRolesByGroupID(ctx, token, realm, rmID, granteeID)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed reading the admin roles of %s in realm %s: %w", p, realm, err)
+	}
+
+	var add []gocloak.Role
+	var items []audit.ItemResult
+	for _, name := range roles {
+		item := audit.ItemResult{Kind: "adminRole", Realm: realm, Name: p.name + "/" + name}
+		if slices.ContainsFunc(held, func(r *gocloak.Role) bool { return gocloak.PString(r.Name) == name }) {
+			rep.skip(item, "already assigned", fmt.Sprintf("%s already holds %s. Skipped.", p, name))
+			continue
+		}
+		i := slices.IndexFunc(available, func(r *gocloak.Role) bool { return gocloak.PString(r.Name) == name })
+		if i < 0 {
+			return 0, fmt.Errorf("role %s not found in %s of realm %s", name, keycloak.RealmManagementClientID, realm)
+		}
+		item.ID = gocloak.PString(available[i].ID)
+		add = append(add, *available[i])
+		items = append(items, item)
+	}
+	if len(add) == 0 {
+		return 0, nil
+	}
+	if p.kind == "user" {
+		err = gc.AddClientRoleToUser(ctx, token, realm, rmID, granteeID, add)
+	} else {
+		err = gc.AddClientRolesToGroup(ctx, token, realm, rmID, granteeID, add)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed assigning admin roles to %s in realm %s: %w", p, realm, err)
+	}
+	for _, item := range items {
+		recordChange(cmd, realm, item.Name, item.ID)
+		rep.add(kcops.Created, item, fmt.Sprintf("Assigned %s role %s to %s.", keycloak.RealmManagementClientID, strings.TrimPrefix(item.Name, p.name+"/"), p))
+	}
+	return len(items), nil
+}
+
+func init() {
+	rootCmd.AddCommand(newDelegateCmd())
+}
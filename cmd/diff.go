@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+// diffOptions holds the flags of `kc diff`.
+type diffOptions struct {
+	file     string
+	realms   []string
+	prune    bool
+	exitCode bool
+}
+
+func newDiffCmd() *cobra.Command {
+	o := &diffOptions{}
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show how the server differs from a manifest without changing anything",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "desired-state manifest (YAML or JSON). Required.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "only diff the given manifest realm(s)")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "also report undeclared entities that apply --prune would delete")
+	cmd.Flags().BoolVar(&o.exitCode, "exit-code", false, "exit with an error when differences are found (for CI checks)")
+	return cmd
+}
+
+func (o *diffOptions) run(cmd *cobra.Command) error {
+	if o.file == "" {
+		return errors.New("missing --file: provide the desired-state manifest")
+	}
+	state, err := manifest.Load(o.file)
+	if err != nil {
+		return fmt.Errorf("invalid manifest %s: %w", o.file, err)
+	}
+	for _, r := range o.realms {
+		if state.Find(r) == nil {
+			return fmt.Errorf("realm %q is not declared in %s", r, o.file)
+		}
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	actions, err := planManifest(ctx, gc, token, state, o.realms, o.prune)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		patch := manifest.Patch(actions)
+		if patch == nil {
+			patch = []manifest.PatchOp{}
+		}
+		if err := printJSON(cmd, patch); err != nil {
+			return err
+		}
+	} else {
+		var lines []string
+		for _, a := range actions {
+			lines = append(lines, a.String())
+		}
+		if len(actions) == 0 {
+			lines = append(lines, "No differences: the server matches the manifest.")
+		} else {
+			lines = append(lines, fmt.Sprintf("%d difference(s) found.", len(actions)))
+		}
+		printBox(cmd, lines, manifestRealmLabel(state, o.realms))
+	}
+	if o.exitCode && len(actions) > 0 {
+		return fmt.Errorf("%d difference(s) between the server and %s", len(actions), o.file)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newDiffCmd())
+}
@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+const eventsPageSize = 100
+
+func newEventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Query server-side events",
+	}
+	cmd.AddCommand(newEventsAdminCmd())
+	cmd.AddCommand(newEventsLoginCmd())
+	return cmd
+}
+
+func newEventsAdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Admin events recorded by Keycloak",
+	}
+	cmd.AddCommand(newEventsAdminListCmd())
+	return cmd
+}
+
+func newEventsLoginCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Login (user) events recorded by Keycloak",
+	}
+	cmd.AddCommand(newEventsLoginListCmd())
+	return cmd
+}
+
+// fetchPaged calls fetch page by page starting at offset first until limit items
+// were collected (limit <= 0 means all) or the server returns a short page.
+func fetchPaged[T any](first, limit, pageSize int, fetch func(first, max int) ([]T, error)) ([]T, error) {
+	var out []T
+	_, err := streamPaged(first, limit, pageSize, fetch, func(item T) error {
+		out = append(out, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func formatEventTime(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// eventsAdminListOptions holds the flags of `kc events admin list`.
+type eventsAdminListOptions struct {
+	eventsListOptions
+	operations    []string
+	resourceTypes []string
+	resourcePath  string
+}
+
+func newEventsAdminListCmd() *cobra.Command {
+	o := &eventsAdminListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List admin events of a realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	streaming(cmd)
+	addEventsListFlags(cmd, &o.eventsListOptions)
+	cmd.Flags().StringSliceVar(&o.operations, "operation", nil, "operation type(s): CREATE|UPDATE|DELETE|ACTION. Repeatable")
+	cmd.Flags().StringSliceVar(&o.resourceTypes, "resource-type", nil, "resource type(s), e.g. USER, CLIENT, REALM_ROLE. Repeatable")
+	cmd.Flags().StringVar(&o.resourcePath, "resource-path", "", "resource path filter, e.g. users/*")
+	return cmd
+}
+
+func (o *eventsAdminListOptions) run(cmd *cobra.Command) error {
+	var cutoff time.Time
+	if o.since != "" {
+		t, err := parseSince(o.since, time.Now())
+		if err != nil {
+			return err
+		}
+		cutoff = t
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	params := keycloak.AdminEventsParams{
+		OperationTypes: o.operations,
+		ResourceTypes:  o.resourceTypes,
+		ResourcePath:   o.resourcePath,
+	}
+	if !cutoff.IsZero() {
+		params.DateFrom = cutoff.Format("2006-01-02")
+	}
+	fetch := func(first, max int) ([]*keycloak.AdminEvent, error) {
+		params.First = first
+		params.Max = max
+		return keycloak.GetAdminEvents(ctx, gc, token, realm, params)
+	}
+	if outputFormat == "ndjson" {
+		_, err := streamPaged(o.first, o.max, eventsPageSize, fetch, func(e *keycloak.AdminEvent) error {
+			if !cutoff.IsZero() && time.UnixMilli(e.Time).Before(cutoff) {
+				return nil
+			}
+			return printNDJSON(cmd, e)
+		})
+		if err != nil {
+			return fmt.Errorf("failed fetching admin events in realm %s: %w", realm, err)
+		}
+		return nil
+	}
+	events, err := fetchPaged(o.first, o.max, eventsPageSize, fetch)
+	if err != nil {
+		return fmt.Errorf("failed fetching admin events in realm %s: %w", realm, err)
+	}
+	// dateFrom only has day granularity on the server
+	filtered := events[:0]
+	for _, e := range events {
+		if cutoff.IsZero() || !time.UnixMilli(e.Time).Before(cutoff) {
+			filtered = append(filtered, e)
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, filtered)
+	}
+	lines := make([]string, 0, len(filtered)+1)
+	for _, e := range filtered {
+		lines = append(lines, fmt.Sprintf("%s  %-7s  %-14s  %s  (by user %s via %s)", formatEventTime(e.Time), e.OperationType, e.ResourceType, e.ResourcePath, e.AuthDetails.UserID, e.AuthDetails.ClientID))
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(filtered)))
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// eventsLoginListOptions holds the flags of `kc events login list`.
+type eventsLoginListOptions struct {
+	eventsListOptions
+	user     string
+	types    []string
+	clientID string
+}
+
+func newEventsLoginListCmd() *cobra.Command {
+	o := &eventsLoginListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List login events of a realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	streaming(cmd)
+	addEventsListFlags(cmd, &o.eventsListOptions)
+	cmd.Flags().StringVar(&o.user, "user", "", "username whose events to list")
+	cmd.Flags().StringSliceVar(&o.types, "type", nil, "event type(s), e.g. LOGIN, LOGIN_ERROR. Repeatable")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "client-id filter")
+	return cmd
+}
+
+func (o *eventsLoginListOptions) run(cmd *cobra.Command) error {
+	var cutoff time.Time
+	if o.since != "" {
+		t, err := parseSince(o.since, time.Now())
+		if err != nil {
+			return err
+		}
+		cutoff = t
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	params := gocloak.GetEventsParams{Type: o.types}
+	if o.clientID != "" {
+		params.Client = &o.clientID
+	}
+	userNames := map[string]string{}
+	if o.user != "" {
+		users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &o.user, Exact: gocloak.BoolP(true)})
+		if err != nil {
+			return fmt.Errorf("failed searching user %q in realm %s: %w", o.user, realm, err)
+		}
+		if len(users) == 0 || users[0].ID == nil {
+			return fmt.Errorf("user %q not found in realm %s", o.user, realm)
+		}
+		params.UserID = users[0].ID
+		userNames[*users[0].ID] = o.user
+	}
+	if !cutoff.IsZero() {
+		params.DateFrom = gocloak.StringP(cutoff.Format("2006-01-02"))
+	}
+	fetch := func(first, max int) ([]*gocloak.EventRepresentation, error) {
+		params.First = gocloak.Int32P(int32(first))
+		params.Max = gocloak.Int32P(int32(max))
+		return gc.GetEvents(ctx, token, realm, params)
+	}
+	if outputFormat == "ndjson" {
+		_, err := streamPaged(o.first, o.max, eventsPageSize, fetch, func(e *gocloak.EventRepresentation) error {
+			if !cutoff.IsZero() && time.UnixMilli(e.Time).Before(cutoff) {
+				return nil
+			}
+			return printNDJSON(cmd, e)
+		})
+		if err != nil {
+			return fmt.Errorf("failed fetching login events in realm %s: %w", realm, err)
+		}
+		return nil
+	}
+	events, err := fetchPaged(o.first, o.max, eventsPageSize, fetch)
+	if err != nil {
+		return fmt.Errorf("failed fetching login events in realm %s: %w", realm, err)
+	}
+	filtered := events[:0]
+	for _, e := range events {
+		if cutoff.IsZero() || !time.UnixMilli(e.Time).Before(cutoff) {
+			filtered = append(filtered, e)
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, filtered)
+	}
+	lines := make([]string, 0, len(filtered)+1)
+	for _, e := range filtered {
+		user := gocloak.PString(e.UserID)
+		if n, ok := userNames[user]; ok {
+			user = n
+		}
+		line := fmt.Sprintf("%s  %-20s  client=%s  user=%s  ip=%s", formatEventTime(e.Time), gocloak.PString(e.Type), gocloak.PString(e.ClientID), user, gocloak.PString(e.IPAddress))
+		if reason, ok := e.Details["error"]; ok {
+			line += "  error=" + reason
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(filtered)))
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// eventsListOptions holds the flags shared by the events list commands.
+type eventsListOptions struct {
+	since string
+	first int
+	max   int
+}
+
+func addEventsListFlags(cmd *cobra.Command, o *eventsListOptions) {
+	cmd.Flags().StringVar(&o.since, "since", "", "only events newer than this age (e.g. 24h, 7d) or date (2006-01-02)")
+	cmd.Flags().IntVar(&o.first, "first", 0, "offset of the first event to return")
+	cmd.Flags().IntVar(&o.max, "max", 100, "maximum number of events to return (0 = all, fetched in pages)")
+}
+
+func init() {
+	rootCmd.AddCommand(newEventsCmd())
+}
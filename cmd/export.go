@@ -0,0 +1,311 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+	tf "kc/internal/terraform"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export realm configuration to other tools",
+	}
+	cmd.AddCommand(newExportTerraformCmd())
+	return cmd
+}
+
+// exportTerraformOptions holds the flags of `kc export terraform`.
+type exportTerraformOptions struct {
+	realm     string
+	out       string
+	normalize bool
+}
+
+func newExportTerraformCmd() *cobra.Command {
+	o := &exportTerraformOptions{}
+	cmd := &cobra.Command{
+		Use:   "terraform",
+		Short: "Emit Keycloak provider HCL (Terraform/OpenTofu) for clients, roles, scopes and groups",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.realm, "realm", "", "realm to export. Required.")
+	cmd.Flags().StringVar(&o.out, "out", "./tf", "directory to write the .tf files to")
+	cmd.Flags().BoolVar(&o.normalize, "normalize", false, "omit the export date and imports.tf (which holds the server IDs), for files that diff cleanly")
+	return cmd
+}
+
+func (o *exportTerraformOptions) run(cmd *cobra.Command) error {
+	if o.realm == "" {
+		return errors.New("missing --realm: provide the realm to export")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	files, counts, err := buildTerraform(ctx, gc, token, o.realm, o.normalize)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(o.out, 0o755); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		path := filepath.Join(o.out, name)
+		if err := os.WriteFile(path, files[name], 0o644); err != nil {
+			return fmt.Errorf("failed writing %s: %w", path, err)
+		}
+		lines = append(lines, "Wrote "+path)
+	}
+	lines = append(lines, fmt.Sprintf("Client scopes: %d, Roles: %d, Clients: %d, Groups: %d", counts["scopes"], counts["roles"], counts["clients"], counts["groups"]))
+	if counts["skipped"] > 0 {
+		lines = append(lines, fmt.Sprintf("Skipped %d non-OIDC client(s); see clients.tf comments.", counts["skipped"]))
+	}
+	if o.normalize {
+		lines = append(lines, "Done. imports.tf was omitted (--normalize); import the existing resources before the first apply.")
+	} else {
+		lines = append(lines, "Done. Run `terraform plan` to import the existing resources into state.")
+	}
+	printBox(cmd, lines, o.realm)
+	return nil
+}
+
+// buildTerraform reads the realm and renders one .tf file per entity kind plus
+// import blocks mapping every resource to its live object. With normalize the
+// files carry neither the export date nor the import blocks.
+func buildTerraform(ctx context.Context, gc keycloak.API, token, realm string, normalize bool) (map[string][]byte, map[string]int, error) {
+	const realmID = tf.Ref("data.keycloak_realm.realm.id")
+	header := fmt.Sprintf("Exported from realm %q by kc on %s.", realm, time.Now().Format("2006-01-02"))
+	if normalize {
+		header = fmt.Sprintf("Exported from realm %q by kc.", realm)
+	}
+	names := tf.Names{}
+	counts := map[string]int{}
+	var imports []*tf.Block
+	addImport := func(to, id string) {
+		imports = append(imports, tf.NewBlock("import").Set("to", tf.Ref(to)).Set("id", tf.String(realm+"/"+id)))
+	}
+	files := map[string][]byte{}
+
+	files["providers.tf"] = tf.Render(header, []*tf.Block{
+		tf.NewBlock("terraform").Nest(tf.NewBlock("required_providers").Set("keycloak", tf.Object{
+			"source":  tf.String("keycloak/keycloak"),
+			"version": tf.String(">= 5.0.0"),
+		})),
+	})
+	files["realm.tf"] = tf.Render(header, []*tf.Block{
+		tf.NewBlock("data", "keycloak_realm", "realm").Set("realm", tf.String(realm)),
+	})
+
+	// client scopes
+	scopes, err := gc.GetClientScopes(ctx, token, realm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return gocloak.PString(scopes[i].Name) < gocloak.PString(scopes[j].Name) })
+	scopeRefs := map[string]tf.Value{}
+	var blocks []*tf.Block
+	for _, s := range scopes {
+		name := gocloak.PString(s.Name)
+		if manifest.IsBuiltinClientScope(name) {
+			continue
+		}
+		typ := "keycloak_openid_client_scope"
+		if gocloak.PString(s.Protocol) == "saml" {
+			typ = "keycloak_saml_client_scope"
+		}
+		id := names.For("scope_" + name)
+		b := tf.NewBlock("resource", typ, id).Set("realm_id", realmID).Set("name", tf.String(name))
+		if d := gocloak.PString(s.Description); d != "" {
+			b.Set("description", tf.String(d))
+		}
+		blocks = append(blocks, b)
+		scopeRefs[name] = tf.Ref(typ + "." + id + ".name")
+		addImport(typ+"."+id, gocloak.PString(s.ID))
+		counts["scopes"]++
+	}
+	if len(blocks) > 0 {
+		files["client_scopes.tf"] = tf.Render(header, blocks)
+	}
+
+	// realm roles
+	roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+	}
+	sort.Slice(roles, func(i, j int) bool { return gocloak.PString(roles[i].Name) < gocloak.PString(roles[j].Name) })
+	roleRefs := map[string]tf.Value{}
+	blocks = nil
+	for _, r := range roles {
+		name := gocloak.PString(r.Name)
+		id := names.For("role_" + name)
+		if manifest.IsBuiltinRole(realm, name) {
+			// referenced by groups but owned by Keycloak
+			roleRefs[name] = tf.Ref("data.keycloak_role." + id + ".id")
+			blocks = append(blocks, tf.NewBlock("data", "keycloak_role", id).Set("realm_id", realmID).Set("name", tf.String(name)))
+			continue
+		}
+		b := tf.NewBlock("resource", "keycloak_role", id).Set("realm_id", realmID).Set("name", tf.String(name))
+		if d := gocloak.PString(r.Description); d != "" {
+			b.Set("description", tf.String(d))
+		}
+		blocks = append(blocks, b)
+		roleRefs[name] = tf.Ref("keycloak_role." + id + ".id")
+		addImport("keycloak_role."+id, gocloak.PString(r.ID))
+		counts["roles"]++
+	}
+	if len(blocks) > 0 {
+		files["roles.tf"] = tf.Render(header, blocks)
+	}
+
+	// clients
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return gocloak.PString(clients[i].ClientID) < gocloak.PString(clients[j].ClientID)
+	})
+	scopeList := func(ss *[]string) tf.List {
+		var l tf.List
+		for _, s := range derefStrings(ss) {
+			if ref, ok := scopeRefs[s]; ok {
+				l = append(l, ref)
+			} else {
+				l = append(l, tf.String(s))
+			}
+		}
+		return l
+	}
+	blocks = nil
+	var skipped []string
+	for _, c := range clients {
+		clientID := gocloak.PString(c.ClientID)
+		if manifest.IsBuiltinClient(realm, clientID) {
+			continue
+		}
+		if p := gocloak.PString(c.Protocol); p != "" && p != "openid-connect" {
+			skipped = append(skipped, fmt.Sprintf("Client %q uses protocol %s and was not exported.", clientID, p))
+			counts["skipped"]++
+			continue
+		}
+		id := names.For("client_" + clientID)
+		access := "CONFIDENTIAL"
+		if gocloak.PBool(c.BearerOnly) {
+			access = "BEARER-ONLY"
+		} else if gocloak.PBool(c.PublicClient) {
+			access = "PUBLIC"
+		}
+		b := tf.NewBlock("resource", "keycloak_openid_client", id).
+			Set("realm_id", realmID).
+			Set("client_id", tf.String(clientID)).
+			Set("access_type", tf.String(access))
+		if n := gocloak.PString(c.Name); n != "" {
+			b.Set("name", tf.String(n))
+		}
+		b.Set("enabled", tf.Bool(gocloak.PBool(c.Enabled)))
+		b.Set("standard_flow_enabled", tf.Bool(gocloak.PBool(c.StandardFlowEnabled)))
+		b.Set("implicit_flow_enabled", tf.Bool(gocloak.PBool(c.ImplicitFlowEnabled)))
+		b.Set("direct_access_grants_enabled", tf.Bool(gocloak.PBool(c.DirectAccessGrantsEnabled)))
+		b.Set("service_accounts_enabled", tf.Bool(gocloak.PBool(c.ServiceAccountsEnabled)))
+		if u := gocloak.PString(c.RootURL); u != "" {
+			b.Set("root_url", tf.String(u))
+		}
+		if u := gocloak.PString(c.BaseURL); u != "" {
+			b.Set("base_url", tf.String(u))
+		}
+		if uris := derefStrings(c.RedirectURIs); len(uris) > 0 {
+			b.Set("valid_redirect_uris", tf.Strings(uris))
+		}
+		if origins := derefStrings(c.WebOrigins); len(origins) > 0 {
+			b.Set("web_origins", tf.Strings(origins))
+		}
+		if access == "CONFIDENTIAL" {
+			b.Comment("the client secret is not exported; set client_secret or let Keycloak keep the current one")
+		}
+		blocks = append(blocks, b)
+		addImport("keycloak_openid_client."+id, gocloak.PString(c.ID))
+		ref := tf.Ref("keycloak_openid_client." + id + ".id")
+		if l := scopeList(c.DefaultClientScopes); len(l) > 0 {
+			blocks = append(blocks, tf.NewBlock("resource", "keycloak_openid_client_default_scopes", id).
+				Set("realm_id", realmID).Set("client_id", ref).Set("default_scopes", l))
+			addImport("keycloak_openid_client_default_scopes."+id, gocloak.PString(c.ID))
+		}
+		if l := scopeList(c.OptionalClientScopes); len(l) > 0 {
+			blocks = append(blocks, tf.NewBlock("resource", "keycloak_openid_client_optional_scopes", id).
+				Set("realm_id", realmID).Set("client_id", ref).Set("optional_scopes", l))
+			addImport("keycloak_openid_client_optional_scopes."+id, gocloak.PString(c.ID))
+		}
+		counts["clients"]++
+	}
+	if len(blocks) > 0 || len(skipped) > 0 {
+		files["clients.tf"] = tf.Render(strings.Join(append([]string{header}, skipped...), "\n"), blocks)
+	}
+
+	// groups, parents before children
+	groups, err := listGroups(ctx, gc, token, realm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+	}
+	groupIDs := map[string]string{}
+	blocks = nil
+	for _, g := range groups {
+		path := gocloak.PString(g.Path)
+		id := names.For("group_" + path)
+		groupIDs[path] = id
+		b := tf.NewBlock("resource", "keycloak_group", id).Set("realm_id", realmID).Set("name", tf.String(gocloak.PString(g.Name)))
+		if parent, _ := splitGroupPath(path); parent != "" {
+			if pid, ok := groupIDs[parent]; ok {
+				b.Set("parent_id", tf.Ref("keycloak_group."+pid+".id"))
+			}
+		}
+		blocks = append(blocks, b)
+		addImport("keycloak_group."+id, gocloak.PString(g.ID))
+		var roleIDs tf.List
+		for _, r := range withoutDefaultRoles(realm, derefStrings(g.RealmRoles)) {
+			if ref, ok := roleRefs[r]; ok {
+				roleIDs = append(roleIDs, ref)
+			}
+		}
+		if len(roleIDs) > 0 {
+			blocks = append(blocks, tf.NewBlock("resource", "keycloak_group_roles", id).
+				Set("realm_id", realmID).Set("group_id", tf.Ref("keycloak_group."+id+".id")).Set("role_ids", roleIDs))
+			addImport("keycloak_group_roles."+id, gocloak.PString(g.ID))
+		}
+		counts["groups"]++
+	}
+	if len(blocks) > 0 {
+		files["groups.tf"] = tf.Render(header, blocks)
+	}
+
+	if len(imports) > 0 && !normalize {
+		files["imports.tf"] = tf.Render(header+"\nImport blocks need Terraform >= 1.5 or OpenTofu >= 1.6; delete this file after the first apply.", imports)
+	}
+	return files, counts, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newExportCmd())
+}
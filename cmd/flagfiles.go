@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// expandFlagFiles replaces the @file values of list flags, e.g.
+// --username @users.txt, with the lines of the file, so long lists need not
+// fit on the command line. @@ starts a literal value beginning with @.
+func expandFlagFiles(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		sv, ok := f.Value.(pflag.SliceValue)
+		if err != nil || !ok || (f.Value.Type() != "stringSlice" && f.Value.Type() != "stringArray") {
+			return
+		}
+		var out []string
+		expanded := false
+		for _, v := range sv.GetSlice() {
+			switch {
+			case strings.HasPrefix(v, "@@"):
+				out = append(out, v[1:])
+				expanded = true
+			case strings.HasPrefix(v, "@") && len(v) > 1:
+				var values []string
+				if values, err = readValuesFile(v[1:]); err != nil {
+					err = fmt.Errorf("failed reading --%s %s: %w", f.Name, v, err)
+					return
+				}
+				if len(values) == 0 {
+					err = fmt.Errorf("--%s %s lists no values", f.Name, v)
+					return
+				}
+				out = append(out, values...)
+				expanded = true
+			default:
+				out = append(out, v)
+			}
+		}
+		if expanded {
+			err = sv.Replace(out)
+		}
+	})
+	return err
+}
+
+// readValuesFile returns the values of a file listing one per line.
+func readValuesFile(file string) ([]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseValues(string(b)), nil
+}
+
+// parseValues splits text listing one value per line. Unlike readListFile,
+// # only starts a comment at the beginning of a line, since values such as
+// passwords may contain it.
+func parseValues(text string) []string {
+	var values []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+// stdinAnnotation names the list flag --stdin adds values to.
+const stdinAnnotation = "kc/stdin"
+
+// addStdinFlag adds --stdin to cmd, reading more values of the list flag
+// named target from stdin, one per line, so that target lists can be piped
+// from other tools.
+func addStdinFlag(cmd *cobra.Command, target string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[stdinAnnotation] = target
+	cmd.Flags().Bool("stdin", false, fmt.Sprintf("read more --%s values from stdin, one per line", target))
+}
+
+// readStdinValues appends the values piped on stdin to the target flag of
+// commands run with --stdin.
+func readStdinValues(cmd *cobra.Command) error {
+	target, ok := cmd.Annotations[stdinAnnotation]
+	if !ok {
+		return nil
+	}
+	if on, _ := cmd.Flags().GetBool("stdin"); !on {
+		return nil
+	}
+	b, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return fmt.Errorf("failed reading stdin: %w", err)
+	}
+	values := parseValues(string(b))
+	if len(values) == 0 {
+		return fmt.Errorf("--stdin: no --%s values on stdin", target)
+	}
+	sv := cmd.Flags().Lookup(target).Value.(pflag.SliceValue)
+	return sv.Replace(append(sv.GetSlice(), values...))
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newGroupsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "groups",
+		Short: "Manage the group hierarchy",
+	}
+	cmd.AddCommand(newGroupsMoveCmd())
+	cmd.AddCommand(newGroupsRenameCmd())
+	return cmd
+}
+
+// groupsMoveOptions holds the flags of `kc groups move` and `rename`.
+type groupsMoveOptions struct {
+	group           string
+	to              string
+	newName         string
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newGroupsMoveCmd() *cobra.Command {
+	o := &groupsMoveOptions{}
+	cmd := &cobra.Command{
+		Use:   "move",
+		Short: "Move a group, with its subgroups, to another path",
+		Long: `Move the group at --group to the path --to, e.g. --group /teams/a --to
+/org/teams/a. The parent of --to must exist; use --to /a to make the group a
+top-level one. The last segment of --to is the new name of the group, so a
+move can rename it too. Subgroups, members and role mappings go with the
+group. kc undo moves it back.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, "move")
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringVar(&o.group, "group", "", "path of the group to move, e.g. /teams/a (required)")
+	cmd.Flags().StringVar(&o.to, "to", "", "new path of the group, e.g. /org/teams/a (required)")
+	o.addFlags(cmd)
+	return cmd
+}
+
+func newGroupsRenameCmd() *cobra.Command {
+	o := &groupsMoveOptions{}
+	cmd := &cobra.Command{
+		Use:   "rename",
+		Short: "Rename a group, keeping it under the same parent",
+		Long: `Give the group at --group the name --new-name under the same parent, which
+changes its path and the paths of its subgroups. kc undo restores the old
+name.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, "rename")
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringVar(&o.group, "group", "", "path of the group to rename, e.g. /teams/a (required)")
+	cmd.Flags().StringVar(&o.newName, "new-name", "", "new name of the group (required)")
+	o.addFlags(cmd)
+	return cmd
+}
+
+// addFlags registers the flags shared by move and rename.
+func (o *groupsMoveOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply to all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip realms without the group instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+}
+
+func (o *groupsMoveOptions) run(cmd *cobra.Command, action string) error {
+	if o.group == "" {
+		return errors.New("missing --group")
+	}
+	from := "/" + strings.Trim(o.group, "/")
+	if from == "/" {
+		return fmt.Errorf("invalid --group %q", o.group)
+	}
+	var to string
+	if action == "move" {
+		if o.to == "" {
+			return errors.New("missing --to")
+		}
+		to = "/" + strings.Trim(o.to, "/")
+		if to == "/" {
+			return fmt.Errorf("invalid --to %q: it must end with the name of the group", o.to)
+		}
+		if to == from || strings.HasPrefix(to, from+"/") {
+			return fmt.Errorf("invalid --to %q: a group cannot move into itself", o.to)
+		}
+	} else {
+		if o.newName == "" {
+			return errors.New("missing --new-name")
+		}
+		if strings.Contains(o.newName, "/") {
+			return fmt.Errorf("invalid --new-name %q: use kc groups move to change the parent", o.newName)
+		}
+		parent, _ := splitGroupPath(from)
+		to = parent + "/" + o.newName
+	}
+
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+	if err := rejectQualified(ctx, gc, token, "group path", []string{o.group}); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		item := audit.ItemResult{Kind: "group", Realm: realm, Name: from}
+		g, err := gc.GetGroupByPath(ctx, token, realm, from)
+		if err != nil || g == nil || g.ID == nil {
+			if o.ignoreMissing {
+				rep.skip(item, "not found", fmt.Sprintf("Group %q not found in realm %q. Skipped.", from, realm))
+				continue
+			}
+			if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("group %q not found in realm %s", from, realm)); err != nil {
+				return err
+			}
+			continue
+		}
+		item.ID = *g.ID
+		if to == from {
+			rep.skip(item, "unchanged", fmt.Sprintf("Group %q in realm %q already has that name. Skipped.", from, realm))
+			continue
+		}
+		if err := moveGroup(ctx, gc, token, realm, *g.ID, from, to); err != nil {
+			if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("failed to %s group %q in realm %s: %w", action, from, realm, err)); err != nil {
+				return err
+			}
+			continue
+		}
+		recordChangeWithBefore(cmd, realm, from, *g.ID, g, audit.FieldChange{Field: "path", Old: from, New: to})
+		verb := "Moved"
+		if action == "rename" {
+			verb = "Renamed"
+		}
+		rep.add(kcops.Updated, item, fmt.Sprintf("%s group %q to %q in realm %q.", verb, from, to, realm))
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// moveGroup gives the group groupID, now at path from, the path to: under
+// the same parent it is renamed, otherwise it is posted to its new parent,
+// which Keycloak treats as a move, and renamed after when the name changes
+// too. Nothing changes when to is taken or its parent does not exist.
+func moveGroup(ctx context.Context, gc keycloak.API, token, realm, groupID, from, to string) error {
+	if _, err := gc.GetGroupByPath(ctx, token, realm, to); err == nil {
+		return fmt.Errorf("group %q already exists", to)
+	}
+	oldParent, oldName := splitGroupPath(from)
+	parent, name := splitGroupPath(to)
+	if parent != oldParent {
+		moved := gocloak.Group{ID: &groupID, Name: &oldName}
+		if parent == "" {
+			_, err := gc.CreateGroup(ctx, token, realm, moved)
+			if err != nil {
+				return err
+			}
+		} else {
+			p, err := gc.GetGroupByPath(ctx, token, realm, parent)
+			if err != nil || p == nil || p.ID == nil {
+				return fmt.Errorf("parent group %q not found", parent)
+			}
+			if _, err := gc.CreateChildGroup(ctx, token, realm, *p.ID, moved); err != nil {
+				return err
+			}
+		}
+	}
+	if name != oldName {
+		return gc.UpdateGroup(ctx, token, realm, gocloak.Group{ID: &groupID, Name: &name})
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newGroupsCmd())
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+func newInitialAccessCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "initial-access",
+		Short: "Manage initial access tokens for dynamic client registration",
+	}
+	cmd.AddCommand(newInitialAccessCreateCmd())
+	cmd.AddCommand(newInitialAccessListCmd())
+	cmd.AddCommand(newInitialAccessDeleteCmd())
+	return cmd
+}
+
+// initialAccessCreateOptions holds the flags of `kc realms initial-access create`.
+type initialAccessCreateOptions struct {
+	realm      string
+	count      int
+	expiration string
+}
+
+func newInitialAccessCreateCmd() *cobra.Command {
+	o := &initialAccessCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an initial access token",
+		Long:  "Create an initial access token that lets clients register themselves. The token is shown only once.",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().IntVar(&o.count, "count", 1, "how many clients can register with the token")
+	cmd.Flags().StringVar(&o.expiration, "expiration", "1d", "lifetime of the token, e.g. 24h, 7d or 2w; 0 never expires")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *initialAccessCreateOptions) run(cmd *cobra.Command) error {
+	if o.count < 1 {
+		return errors.New("invalid --count: must be at least 1")
+	}
+	expiration, err := parseAge(o.expiration)
+	if err != nil || expiration < 0 {
+		return fmt.Errorf("invalid --expiration %q: use a duration like 24h, 7d or 2w, or 0", o.expiration)
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	created, err := keycloak.CreateInitialAccess(ctx, gc, token, realm, expiration, o.count)
+	if err != nil {
+		return fmt.Errorf("failed creating initial access token in realm %s: %w", realm, err)
+	}
+	// the audit entry keeps the limits, never the token itself
+	count, expires := fmt.Sprint(o.count), o.expiration
+	fields := appendFieldChange(nil, "count", nil, &count)
+	fields = appendFieldChange(fields, "expiration", nil, &expires)
+	recordChange(cmd, realm, "initial-access", created.ID, fields...)
+
+	rep := newReport(cmd)
+	rep.add(kcops.Created, audit.ItemResult{Kind: "initialAccess", Realm: realm, Name: created.ID, ID: created.ID},
+		fmt.Sprintf("Created initial access token %s in realm %q for %d client(s), %s.", created.ID, realm, o.count, describeExpiry(created)))
+	if outputFormat == "json" {
+		// scripts need the token, which the result does not carry
+		return printJSON(cmd, created)
+	}
+	rep.note("Token: " + created.Token)
+	return rep.print(cmd, realm, "Done. Copy the token now; Keycloak does not show it again.")
+}
+
+// describeExpiry tells when an initial access token expires.
+func describeExpiry(a *keycloak.InitialAccess) string {
+	if at := a.ExpiresAt(); !at.IsZero() {
+		return "expires " + at.UTC().Format(time.RFC3339)
+	}
+	return "never expires"
+}
+
+// initialAccessListOptions holds the flags of `kc realms initial-access list`.
+type initialAccessListOptions struct {
+	realm string
+}
+
+func newInitialAccessListCmd() *cobra.Command {
+	o := &initialAccessListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the initial access tokens of a realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *initialAccessListOptions) run(cmd *cobra.Command) error {
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := keycloak.GetInitialAccess(ctx, gc, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed listing initial access tokens in realm %s: %w", realm, err)
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, list)
+	}
+	lines := make([]string, 0, len(list)+1)
+	for _, a := range list {
+		lines = append(lines, fmt.Sprintf("%s  created %s, %s, %d of %d registration(s) left",
+			a.ID, time.Unix(a.Timestamp, 0).UTC().Format(time.RFC3339), describeExpiry(a), a.RemainingCount, a.Count))
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(list)))
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// initialAccessDeleteOptions holds the flags of `kc realms initial-access delete`.
+type initialAccessDeleteOptions struct {
+	realm string
+	ids   []string
+}
+
+func newInitialAccessDeleteCmd() *cobra.Command {
+	o := &initialAccessDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Revoke initial access token(s)",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-clients")
+	cmd.Flags().StringSliceVar(&o.ids, "id", nil, "ID(s) of the tokens to revoke, as shown by list. Repeatable; required.")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *initialAccessDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.ids) == 0 {
+		return errors.New("missing --id: provide at least one --id")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, id := range o.ids {
+		item := audit.ItemResult{Kind: "initialAccess", Realm: realm, Name: id, ID: id}
+		if err := keycloak.DeleteInitialAccess(ctx, gc, token, realm, id); err != nil {
+			if strings.Contains(err.Error(), "404") {
+				rep.skip(item, "not found", fmt.Sprintf("Initial access token %s not found in realm %q. Skipped.", id, realm))
+				continue
+			}
+			return fmt.Errorf("failed revoking initial access token %s in realm %s: %w", id, realm, err)
+		}
+		recordChange(cmd, realm, "initial-access", id)
+		rep.add(kcops.Deleted, item, fmt.Sprintf("Revoked initial access token %s in realm %q.", id, realm))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kc/internal/lint"
+
+	"github.com/spf13/cobra"
+)
+
+// lintOptions holds the flags of `kc lint`.
+type lintOptions struct {
+	rulesFile string
+	realms    []string
+	allRealms bool
+	output    reportOutput
+}
+
+func newLintCmd() *cobra.Command {
+	o := &lintOptions{}
+	cmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check that realms follow naming conventions and contain the required entities",
+		Long: `Check every selected realm against a rules file and list the violations, so
+that tenant realms created from the same template stay consistent. The rules
+file sets the naming conventions of roles, clients, client scopes and groups
+as regular expressions, and the roles, client scopes, groups and clients
+every realm must contain:
+
+  naming:
+    roles: ^[a-z][a-z0-9-]*$
+    clients: ^tenant-
+  require:
+    roles: [tenant-admin]
+    client_scopes: [tenant-info]
+    groups: [/admins]
+    clients:
+      - client_id: portal
+        enabled: true
+        scopes: [tenant-info]
+
+Entities Keycloak creates on its own are not checked against the naming
+conventions. Nothing is changed; the command fails when a realm breaks a
+rule, so it can gate a CI pipeline.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.rulesFile, "rules", "", "lint rules file (YAML). Required.")
+	o.output.add(cmd)
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "lint all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *lintOptions) run(cmd *cobra.Command) error {
+	if o.rulesFile == "" {
+		return errors.New("missing --rules: provide the lint rules file")
+	}
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	rules, err := lint.Load(o.rulesFile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	t := &reportTable{columns: []string{"realm", "rule", "target", "violation"}}
+	failing := 0
+	for _, realm := range realms {
+		state, err := fetchRealmState(ctx, gc, token, realm, stateOptions{Roles: true, ClientScopes: true, Clients: true, Groups: true})
+		if err != nil {
+			return err
+		}
+		if state == nil {
+			return fmt.Errorf("realm %s not found", realm)
+		}
+		violations := rules.Check(state)
+		if len(violations) > 0 {
+			failing++
+		}
+		for _, v := range violations {
+			t.rows = append(t.rows, []string{realm, v.Rule, v.Target, v.Message})
+		}
+	}
+
+	summary := fmt.Sprintf("Realms: %d, failing: %d, violations: %d.", len(realms), failing, len(t.rows))
+	if len(t.rows) == 0 && o.output.format == "text" && o.output.out == "" {
+		printBox(cmd, []string{"No violations found.", summary}, realmsLabel(cmd, realms))
+		return nil
+	}
+	if err := o.output.print(cmd, t, realmsLabel(cmd, realms), summary); err != nil {
+		return err
+	}
+	if len(t.rows) > 0 {
+		// the violations are listed above
+		cmd.SilenceUsage = true
+		return fmt.Errorf("lint failed: %d violation(s) in %d realm(s)", len(t.rows), failing)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newLintCmd())
+}
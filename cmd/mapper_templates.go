@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// mapperTemplate is a protocol mapper that --with-mappers creates by name.
+type mapperTemplate struct {
+	// arg names the value after the colon, as in audience:api; empty when
+	// the template takes none. optional templates work without it.
+	arg      string
+	optional bool
+	build    func(arg string) gocloak.ProtocolMappers
+}
+
+// mapperTemplates are the openid-connect mappers most scopes need.
+var mapperTemplates = map[string]mapperTemplate{
+	"audience": {arg: "client", build: func(client string) gocloak.ProtocolMappers {
+		return oidcMapper("audience "+client, "oidc-audience-mapper", gocloak.ProtocolMappersConfig{
+			IncludedClientAudience: gocloak.StringP(client),
+			AccessTokenClaim:       gocloak.StringP("true"),
+			IDTokenClaim:           gocloak.StringP("false"),
+		})
+	}},
+	"groups": {build: func(string) gocloak.ProtocolMappers {
+		return oidcMapper("groups", "oidc-group-membership-mapper", gocloak.ProtocolMappersConfig{
+			ClaimName:          gocloak.StringP("groups"),
+			FullPath:           gocloak.StringP("false"),
+			AccessTokenClaim:   gocloak.StringP("true"),
+			IDTokenClaim:       gocloak.StringP("true"),
+			UserinfoTokenClaim: gocloak.StringP("true"),
+		})
+	}},
+	"realm-roles": {build: func(string) gocloak.ProtocolMappers {
+		return oidcMapper("realm roles", "oidc-usermodel-realm-role-mapper", gocloak.ProtocolMappersConfig{
+			ClaimName:        gocloak.StringP("realm_access.roles"),
+			JSONTypeLabel:    gocloak.StringP("String"),
+			Multivalued:      gocloak.StringP("true"),
+			AccessTokenClaim: gocloak.StringP("true"),
+		})
+	}},
+	"client-roles": {arg: "client", optional: true, build: func(client string) gocloak.ProtocolMappers {
+		name := "client roles"
+		if client != "" {
+			name += " " + client
+		}
+		m := oidcMapper(name, "oidc-usermodel-client-role-mapper", gocloak.ProtocolMappersConfig{
+			ClaimName:        gocloak.StringP("resource_access.${client_id}.roles"),
+			JSONTypeLabel:    gocloak.StringP("String"),
+			Multivalued:      gocloak.StringP("true"),
+			AccessTokenClaim: gocloak.StringP("true"),
+		})
+		if client != "" {
+			m.ProtocolMappersConfig.UsermodelClientRoleMappingClientID = gocloak.StringP(client)
+		}
+		return m
+	}},
+	"attribute": {arg: "name", build: func(attr string) gocloak.ProtocolMappers {
+		return oidcMapper(attr, "oidc-usermodel-attribute-mapper", gocloak.ProtocolMappersConfig{
+			UserAttribute:      gocloak.StringP(attr),
+			ClaimName:          gocloak.StringP(attr),
+			JSONTypeLabel:      gocloak.StringP("String"),
+			AccessTokenClaim:   gocloak.StringP("true"),
+			IDTokenClaim:       gocloak.StringP("true"),
+			UserinfoTokenClaim: gocloak.StringP("true"),
+		})
+	}},
+}
+
+func oidcMapper(name, mapperType string, config gocloak.ProtocolMappersConfig) gocloak.ProtocolMappers {
+	return gocloak.ProtocolMappers{
+		Name:                  gocloak.StringP(name),
+		Protocol:              gocloak.StringP("openid-connect"),
+		ProtocolMapper:        gocloak.StringP(mapperType),
+		ProtocolMappersConfig: &config,
+	}
+}
+
+// mapperTemplateUsage lists the templates for flag help, e.g. audience:<client>.
+func mapperTemplateUsage() string {
+	var names []string
+	for name, t := range mapperTemplates {
+		switch {
+		case t.arg != "" && t.optional:
+			name += "[:<" + t.arg + ">]"
+		case t.arg != "":
+			name += ":<" + t.arg + ">"
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// parseMapperTemplates builds the mappers of --with-mappers values such as
+// audience:api or groups. Two templates creating a mapper of the same name
+// are rejected, since a scope cannot hold both.
+func parseMapperTemplates(values []string) ([]gocloak.ProtocolMappers, error) {
+	var out []gocloak.ProtocolMappers
+	for _, v := range values {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(v), ":")
+		t, ok := mapperTemplates[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid --with-mappers %q: unknown template; use %s", v, mapperTemplateUsage())
+		}
+		switch {
+		case t.arg == "" && hasArg:
+			return nil, fmt.Errorf("invalid --with-mappers %q: %s takes no value", v, name)
+		case t.arg != "" && !t.optional && arg == "":
+			return nil, fmt.Errorf("invalid --with-mappers %q: use %s:<%s>", v, name, t.arg)
+		}
+		m := t.build(arg)
+		if slices.ContainsFunc(out, func(o gocloak.ProtocolMappers) bool { return *o.Name == *m.Name }) {
+			return nil, fmt.Errorf("invalid --with-mappers: mapper %q given twice", *m.Name)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// matchOptions holds --match of update and delete commands, which targets
+// the entities whose names match a pattern instead of listing them. It also
+// splits the names given as realm/name or realm:name by realm.
+type matchOptions struct {
+	patterns []string
+	// kind is the entity in messages, e.g. "user".
+	kind string
+	// matched holds the names resolved per realm.
+	matched map[string][]string
+	// itemFlags take one value per name, in the order of the names.
+	itemFlags []string
+	// qualified holds the realm-qualified names per realm, and unqualified
+	// the other names, which apply to the realms in selected.
+	qualified   map[string][]string
+	unqualified []string
+	selected    []string
+	// dryRun lists the matches without asking, for commands that then
+	// change nothing.
+	dryRun bool
+}
+
+func (m *matchOptions) add(cmd *cobra.Command, kind string) {
+	m.kind = kind
+	cmd.Flags().StringSliceVar(&m.patterns, "match", nil, fmt.Sprintf("target the %ss whose name matches a glob (svc-*) or a regex prefixed with re:, after a preview and confirmation. Repeatable.", kind))
+}
+
+// active reports whether --match was given.
+func (m *matchOptions) active() bool {
+	return len(m.patterns) > 0
+}
+
+// validate checks the patterns and that --match is not combined with the
+// names in nameFlag, flags reading stdin (the confirmation is read from
+// it), or the renames in renameFlags, which cannot apply to many entities.
+// Flags in perItemFlags take one value for every match.
+func (m *matchOptions) validate(cmd *cobra.Command, nameFlag string, names []string, renameFlags, perItemFlags []string) error {
+	m.itemFlags = append(slices.Clone(renameFlags), perItemFlags...)
+	if !m.active() {
+		return nil
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("--match cannot be combined with --%s: target either the names or a pattern", nameFlag)
+	}
+	if f := stdinFlag(cmd); f != "" {
+		return fmt.Errorf("--match cannot be combined with %s: the confirmation is read from stdin", f)
+	}
+	for _, f := range renameFlags {
+		if cmd.Flags().Changed(f) {
+			return fmt.Errorf("--match cannot be combined with --%s: every matched %s would get the same name", f, m.kind)
+		}
+	}
+	for _, f := range perItemFlags {
+		if sv, ok := cmd.Flags().Lookup(f).Value.(pflag.SliceValue); ok && len(sv.GetSlice()) > 1 {
+			return fmt.Errorf("invalid --%s: with --match, pass one value for every matched %s", f, m.kind)
+		}
+	}
+	return validatePatterns(m.patterns)
+}
+
+// validatePatterns checks --match patterns: globs, or regular expressions
+// prefixed with re:.
+func validatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if re, ok := strings.CutPrefix(p, "re:"); ok {
+			if _, err := regexp.Compile(re); err != nil {
+				return fmt.Errorf("invalid --match %q: %w", p, err)
+			}
+		} else if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid --match %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// resolve lists the names of each realm with list and keeps those matching
+// a pattern. It prints them and asks to go ahead with action, e.g. "delete";
+// no match at all is not an error.
+func (m *matchOptions) resolve(cmd *cobra.Command, action string, realms []string, list func(realm string) ([]string, error)) error {
+	m.matched = map[string][]string{}
+	total := 0
+	out := cmd.OutOrStdout()
+	for _, realm := range realms {
+		names, err := list(realm)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if slices.ContainsFunc(m.patterns, func(p string) bool { return matchName(p, name) }) {
+				m.matched[realm] = append(m.matched[realm], name)
+			}
+		}
+		if len(m.matched[realm]) == 0 {
+			continue
+		}
+		total += len(m.matched[realm])
+		fmt.Fprintf(out, "%d %s(s) match %s in realm %q:\n", len(m.matched[realm]), m.kind, strings.Join(m.patterns, ", "), realm)
+		for _, name := range m.matched[realm] {
+			fmt.Fprintf(out, "  %s\n", name)
+		}
+	}
+	if total == 0 {
+		fmt.Fprintf(out, "No %s matches %s.\n", m.kind, strings.Join(m.patterns, ", "))
+		return nil
+	}
+	// the matches are listed above; usage would only bury them
+	cmd.SilenceUsage = true
+	if m.dryRun {
+		return nil
+	}
+	ok, err := newWizard(cmd).askBool(fmt.Sprintf("%s these %d %s(s)?", strings.ToUpper(action[:1])+action[1:], total, m.kind), false)
+	if err != nil {
+		return fmt.Errorf("--match needs a confirmation on stdin: %w", err)
+	}
+	if !ok {
+		return errWizardAborted
+	}
+	return nil
+}
+
+// names returns the names to handle in realm: the matches with --match,
+// otherwise the names given, without those qualified with another realm.
+func (m *matchOptions) names(realm string, given []string) []string {
+	if m.active() {
+		return m.matched[realm]
+	}
+	if m.qualified != nil {
+		var out []string
+		if slices.Contains(m.selected, realm) {
+			out = append(out, m.unqualified...)
+		}
+		return append(out, m.qualified[realm]...)
+	}
+	return given
+}
+
+// realms resolves the target realms of the names given. A name qualified
+// with the realm it is in, as realm/name or realm:name, only applies to that
+// realm, which becomes a target realm; the other names apply to the realms
+// selected by the realm flags. A prefix that is not the name of a realm is
+// part of the name, e.g. of a client ID that is a URL.
+func (m *matchOptions) realms(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string, given []string) ([]string, error) {
+	if m.active() || !slices.ContainsFunc(given, func(n string) bool { return strings.ContainsAny(n, "/:") }) {
+		return resolveRealms(ctx, cmd, gc, token)
+	}
+	all, err := listRealmNames(ctx, gc, token)
+	if err != nil {
+		return nil, err
+	}
+	qualified := map[string][]string{}
+	var unqualified, named []string
+	for _, n := range given {
+		realm, name, ok := splitQualified(n, all)
+		if !ok {
+			unqualified = append(unqualified, n)
+			continue
+		}
+		if _, seen := qualified[realm]; !seen {
+			named = append(named, realm)
+		}
+		qualified[realm] = append(qualified[realm], name)
+	}
+	if len(named) == 0 {
+		return resolveRealms(ctx, cmd, gc, token)
+	}
+	// the names of a realm no longer line up with the values of the flags
+	for _, f := range m.itemFlags {
+		if sv, ok := cmd.Flags().Lookup(f).Value.(pflag.SliceValue); ok && len(sv.GetSlice()) > 1 {
+			return nil, fmt.Errorf("invalid --%s: with realm-qualified names, pass one value for every %s", f, m.kind)
+		}
+	}
+	m.qualified, m.unqualified = qualified, unqualified
+
+	var realms []string
+	if len(unqualified) > 0 {
+		if realms, err = resolveRealms(ctx, cmd, gc, token); err != nil {
+			return nil, err
+		}
+		m.selected = slices.Clone(realms)
+	}
+	named = slices.DeleteFunc(named, func(r string) bool { return slices.Contains(realms, r) })
+	if len(named) > 0 {
+		if err := preflight(ctx, cmd, gc, token, named); err != nil {
+			return nil, err
+		}
+	}
+	return append(realms, named...), nil
+}
+
+// splitQualified splits a name given as realm/name or realm:name, when
+// realm is one of realms.
+func splitQualified(n string, realms []string) (realm, name string, ok bool) {
+	i := strings.IndexAny(n, "/:")
+	if i <= 0 || i == len(n)-1 || !slices.Contains(realms, n[:i]) {
+		return "", "", false
+	}
+	return n[:i], n[i+1:], true
+}
+
+// rejectQualified fails when one of names, described by what (e.g.
+// "username"), is qualified with a realm as realm/name or realm:name, for
+// commands that do not split names by realm, so no entity is created or
+// looked up under the qualified name.
+func rejectQualified(ctx context.Context, gc keycloak.API, token, what string, names []string) error {
+	if !slices.ContainsFunc(names, func(n string) bool { return strings.ContainsAny(n, "/:") }) {
+		return nil
+	}
+	all, err := listRealmNames(ctx, gc, token)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if realm, name, ok := splitQualified(n, all); ok {
+			return fmt.Errorf("invalid %s %q: this command does not take realm-qualified names; use --realm %s with %q", what, n, realm, name)
+		}
+	}
+	return nil
+}
+
+// listUsernames lists the usernames of a realm for --match.
+func listUsernames(ctx context.Context, gc keycloak.API, token string) func(realm string) ([]string, error) {
+	return func(realm string) ([]string, error) {
+		users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+			return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max, BriefRepresentation: gocloak.BoolP(true)})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+		}
+		names := make([]string, 0, len(users))
+		for _, u := range users {
+			names = append(names, gocloak.PString(u.Username))
+		}
+		return names, nil
+	}
+}
+
+// listClientIDs lists the client IDs of a realm for --match, leaving out the
+// clients Keycloak creates.
+func listClientIDs(ctx context.Context, gc keycloak.API, token string) func(realm string) ([]string, error) {
+	return func(realm string) ([]string, error) {
+		clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+		}
+		var names []string
+		for _, c := range clients {
+			if cid := gocloak.PString(c.ClientID); !manifest.IsBuiltinClient(realm, cid) {
+				names = append(names, cid)
+			}
+		}
+		slices.Sort(names)
+		return names, nil
+	}
+}
+
+// listRoleNames lists the realm roles of a realm for --match, leaving out the
+// roles Keycloak creates.
+func listRoleNames(ctx context.Context, gc keycloak.API, token string) func(realm string) ([]string, error) {
+	return func(realm string) ([]string, error) {
+		roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+		}
+		var names []string
+		for _, r := range roles {
+			if name := gocloak.PString(r.Name); !manifest.IsBuiltinRole(realm, name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		return names, nil
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+func newMonitorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "monitor",
+		Short: "Watch realm configuration for changes made outside of kc",
+	}
+	cmd.AddCommand(newMonitorBaselineCmd())
+	cmd.AddCommand(newMonitorDriftCmd())
+	return cmd
+}
+
+// monitorBaselineOptions holds the flags of `kc monitor baseline`.
+type monitorBaselineOptions struct {
+	realms []string
+	out    string
+}
+
+func newMonitorBaselineCmd() *cobra.Command {
+	o := &monitorBaselineOptions{}
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Capture the roles, client scopes, clients and groups of realms as a drift baseline",
+		Long: `Capture the current configuration of realms as a manifest to use as the
+baseline of kc monitor drift. The file is an ordinary manifest, so it can also
+be reviewed, versioned and used with kc diff and kc apply. It is written as
+JSON when --out ends in .json, and as YAML otherwise.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "realm(s) to capture. Repeatable; required.")
+	cmd.Flags().StringVar(&o.out, "out", "", "baseline file to write (required)")
+	return cmd
+}
+
+func (o *monitorBaselineOptions) run(cmd *cobra.Command) error {
+	if len(o.realms) == 0 {
+		return errors.New("missing --realm: name the realm(s) to capture")
+	}
+	if o.out == "" {
+		return errors.New("missing --out: provide the baseline file to write")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	var state manifest.State
+	var lines []string
+	for _, realm := range o.realms {
+		r, err := fetchRealmState(ctx, gc, token, realm, stateOptions{Roles: true, ClientScopes: true, Clients: true, Groups: true})
+		if err != nil {
+			return err
+		}
+		if r == nil {
+			return fmt.Errorf("realm %s not found", realm)
+		}
+		r.StripBuiltins()
+		state.Realms = append(state.Realms, *r)
+		lines = append(lines, fmt.Sprintf("%s: Roles: %d, Client scopes: %d, Clients: %d, Groups: %d", realm, len(r.Roles), len(r.ClientScopes), len(r.Clients), len(r.Groups)))
+	}
+	var b []byte
+	if strings.EqualFold(filepath.Ext(o.out), ".json") {
+		b, err = json.MarshalIndent(state, "", "  ")
+	} else {
+		b, err = yaml.Marshal(state)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.out, b, 0o644); err != nil {
+		return fmt.Errorf("failed writing %s: %w", o.out, err)
+	}
+	lines = append(lines, fmt.Sprintf("Done. Baseline written to %s.", o.out))
+	printBox(cmd, lines, strings.Join(o.realms, ", "))
+	return nil
+}
+
+// monitorDriftOptions holds the flags of `kc monitor drift`.
+type monitorDriftOptions struct {
+	baseline string
+	realms   []string
+	interval time.Duration
+	webhook  string
+	once     bool
+}
+
+func newMonitorDriftCmd() *cobra.Command {
+	o := &monitorDriftOptions{}
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Compare realms to a baseline periodically and alert when they drift",
+		Long: `Read the realms of the baseline manifest from the server every --interval and
+compare them to it, including entities added since the baseline was taken.
+When a realm drifts, or its drift changes, an alert is printed and, with
+--webhook, posted as JSON; when it matches the baseline again a resolved
+alert follows. Differences are listed as kc diff does: what kc apply would
+do to bring the realm back to the baseline.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.baseline, "baseline", "", "baseline manifest (YAML or JSON), e.g. from kc monitor baseline. Required.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "only monitor the given baseline realm(s)")
+	cmd.Flags().DurationVar(&o.interval, "interval", 10*time.Minute, "time between checks")
+	cmd.Flags().StringVar(&o.webhook, "webhook", "", "URL to POST alerts to as JSON")
+	cmd.Flags().BoolVar(&o.once, "once", false, "check once and exit with an error on drift, e.g. from cron")
+	return cmd
+}
+
+// driftAlert is an alert of `kc monitor drift`, printed with --output json
+// and posted to --webhook.
+type driftAlert struct {
+	// Status is drift or resolved.
+	Status      string             `json:"status"`
+	Realm       string             `json:"realm"`
+	Time        time.Time          `json:"time"`
+	Baseline    string             `json:"baseline"`
+	Differences []manifest.PatchOp `json:"differences,omitempty"`
+}
+
+func (o *monitorDriftOptions) run(cmd *cobra.Command) error {
+	if o.baseline == "" {
+		return errors.New("missing --baseline: provide the baseline manifest")
+	}
+	if o.interval <= 0 {
+		return errors.New("invalid --interval: must be positive")
+	}
+	state, err := manifest.Load(o.baseline)
+	if err != nil {
+		return fmt.Errorf("invalid baseline %s: %w", o.baseline, err)
+	}
+	realms := o.realms
+	for _, r := range realms {
+		if state.Find(r) == nil {
+			return fmt.Errorf("realm %q is not in baseline %s", r, o.baseline)
+		}
+	}
+	if len(realms) == 0 {
+		for _, r := range state.Realms {
+			realms = append(realms, r.Name)
+		}
+	}
+
+	// last holds the differences last alerted per realm, to alert only on changes
+	last := map[string]string{}
+	if !o.once {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Monitoring realm(s) %s against %s every %s\n", strings.Join(realms, ", "), o.baseline, o.interval)
+	}
+	for {
+		ctx, cancel := commandContext(cmd, 5*time.Minute)
+		drifted, err := o.check(ctx, cmd, state, realms, last)
+		cancel()
+		if o.once {
+			if err != nil {
+				return err
+			}
+			if drifted > 0 {
+				// the differences are listed above; usage would only bury them
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%d realm(s) drifted from %s", drifted, o.baseline)
+			}
+			printBox(cmd, []string{fmt.Sprintf("No drift: the realms match %s.", o.baseline)}, strings.Join(realms, ", "))
+			return nil
+		}
+		if err != nil {
+			if errors.Is(cmd.Context().Err(), context.Canceled) {
+				return err
+			}
+			// a failed check is retried at the next interval
+			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] ERROR: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-time.After(o.interval):
+		}
+	}
+}
+
+// check compares each realm to the baseline, alerting on new or changed drift
+// and on drift that went away. It returns how many realms drifted.
+func (o *monitorDriftOptions) check(ctx context.Context, cmd *cobra.Command, state *manifest.State, realms []string, last map[string]string) (int, error) {
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return 0, err
+	}
+	drifted := 0
+	var alertErrs []error
+	for _, realm := range realms {
+		actions, err := planManifest(ctx, gc, token, state, []string{realm}, true)
+		if err != nil {
+			return drifted, err
+		}
+		var lines []string
+		for _, a := range actions {
+			lines = append(lines, a.String())
+		}
+		slices.Sort(lines)
+		key := strings.Join(lines, "\n")
+		if len(actions) > 0 {
+			drifted++
+		}
+		if key == last[realm] {
+			continue
+		}
+		alert := driftAlert{Status: "drift", Realm: realm, Time: time.Now().UTC(), Baseline: o.baseline, Differences: manifest.Patch(actions)}
+		if len(actions) == 0 {
+			alert.Status = "resolved"
+		}
+		if err := o.alert(ctx, cmd, alert, lines); err != nil {
+			alertErrs = append(alertErrs, err)
+			continue
+		}
+		last[realm] = key
+	}
+	return drifted, errors.Join(alertErrs...)
+}
+
+// alert prints a drift alert and posts it to --webhook.
+func (o *monitorDriftOptions) alert(ctx context.Context, cmd *cobra.Command, a driftAlert, lines []string) error {
+	if outputFormat == "json" {
+		if err := printJSON(cmd, a); err != nil {
+			return err
+		}
+	} else {
+		out := cmd.OutOrStdout()
+		ts := a.Time.Format(time.RFC3339)
+		if a.Status == "resolved" {
+			fmt.Fprintf(out, "[%s] RESOLVED: realm %q matches %s again.\n", ts, a.Realm, o.baseline)
+		} else {
+			fmt.Fprintf(out, "[%s] DRIFT: realm %q differs from %s in %d place(s):\n", ts, a.Realm, o.baseline, len(lines))
+			for _, l := range lines {
+				fmt.Fprintf(out, "  %s\n", l)
+			}
+		}
+	}
+	if o.webhook == "" {
+		return nil
+	}
+	if err := postJSON(ctx, o.webhook, a); err != nil {
+		return fmt.Errorf("failed posting the alert of realm %s to the webhook: %w", a.Realm, err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newMonitorCmd())
+}
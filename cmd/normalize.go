@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// volatileFields are the JSON keys --normalize drops: IDs the server assigns
+// and timestamps, which differ between servers and between runs. Keys ending
+// in Timestamp are dropped as well.
+var volatileFields = map[string]bool{
+	"id":          true,
+	"containerId": true,
+	"parentId":    true,
+	"timestamp":   true,
+	"start":       true,
+	"lastAccess":  true,
+	"expiration":  true,
+	"notBefore":   true,
+}
+
+// normalizeSortKeys are the keys arrays of objects are sorted by under
+// --normalize, most significant first.
+var normalizeSortKeys = []string{"realm", "subType", "path", "clientId", "name", "username", "alias", "target"}
+
+func addNormalizeFlag(cmd *cobra.Command, p *bool) {
+	cmd.Flags().BoolVar(p, "normalize", false, "sort entities by name and omit IDs and timestamps, for output that diffs cleanly")
+}
+
+// printListJSON prints v as JSON, normalized when normalize is set.
+func printListJSON(cmd *cobra.Command, v interface{}, normalize bool) error {
+	if !normalize {
+		return printJSON(cmd, v)
+	}
+	n, err := normalizeJSON(v)
+	if err != nil {
+		return err
+	}
+	return printJSON(cmd, n)
+}
+
+// normalizeJSON returns the JSON form of v without volatile fields, with
+// arrays of objects sorted by their name-like keys and arrays of strings
+// sorted. Object keys are already sorted by the encoder.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return normalizeValue(out), nil
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, x := range v {
+			if volatileFields[k] || strings.HasSuffix(k, "Timestamp") {
+				delete(v, k)
+				continue
+			}
+			v[k] = normalizeValue(x)
+		}
+	case []interface{}:
+		for i, x := range v {
+			v[i] = normalizeValue(x)
+		}
+		slices.SortStableFunc(v, compareNormalized)
+	}
+	return v
+}
+
+// compareNormalized orders strings alphabetically and objects by
+// normalizeSortKeys; other values keep their order.
+func compareNormalized(a, b interface{}) int {
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb)
+		}
+		return 0
+	}
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	if !okA || !okB {
+		return 0
+	}
+	for _, k := range normalizeSortKeys {
+		sa, _ := ma[k].(string)
+		sb, _ := mb[k].(string)
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// notifyTimeout bounds the post of a completion notification, so a slow
+// webhook cannot hold the command.
+const notifyTimeout = 10 * time.Second
+
+// completionNotice is the summary posted to notify_webhook after a command
+// that changes the server, as is with notify_format generic.
+type completionNotice struct {
+	Command string    `json:"command"`
+	Kind    string    `json:"kind"`
+	Realms  []string  `json:"realms"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Jira    string    `json:"jira,omitempty"`
+	Actor   string    `json:"actor,omitempty"`
+	Server  string    `json:"server"`
+	Time    time.Time `json:"time"`
+	// Duration is in seconds.
+	Duration float64 `json:"duration"`
+	Created  int     `json:"created"`
+	Updated  int     `json:"updated"`
+	Deleted  int     `json:"deleted"`
+	Skipped  int     `json:"skipped"`
+	Errors   int     `json:"errors"`
+	// Changes counts the audited changes of commands that report no items.
+	Changes int `json:"changes"`
+}
+
+// notifyCompletion posts a summary of cmd to notify_webhook when cmd changes
+// the server. It must run before appendAudit, which clears what cmd recorded.
+// A failed post only prints a warning.
+func notifyCompletion(cmd *cobra.Command, status string, cmdErr error, end time.Time, dur time.Duration) {
+	url := config.Global.NotifyWebhook
+	if url == "" || !isMutating(cmd) {
+		return
+	}
+	if f := cmd.Flags().Lookup("dry-run"); f != nil && f.Value.String() == "true" {
+		return
+	}
+	_, actor := resolveActor()
+	n := completionNotice{
+		Command:  buildRawCommand(),
+		Kind:     resolveChangeKind(cmd.CommandPath()),
+		Realms:   noticeRealms(cmd),
+		Status:   status,
+		Jira:     cmdState(cmd).jira,
+		Actor:    actor,
+		Server:   config.Global.ServerURL,
+		Time:     end.UTC(),
+		Duration: dur.Seconds(),
+		Changes:  len(cmdState(cmd).changes),
+	}
+	if cmdErr != nil {
+		n.Error = cmdErr.Error()
+	}
+	if r := reportResult(cmd); r != nil {
+		n.Created, n.Updated, n.Deleted, n.Skipped, n.Errors = len(r.Created), len(r.Updated), len(r.Deleted), len(r.Skipped), len(r.Errors)
+	}
+
+	var payload any = n
+	switch config.Global.NotifyFormat {
+	case "slack":
+		payload = map[string]string{"text": n.text()}
+	case "teams":
+		color := "2EB886"
+		if status != "ok" {
+			color = "D00000"
+		}
+		payload = map[string]string{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    n.title(),
+			"themeColor": color,
+			"title":      n.title(),
+			// Teams renders markdown, where a lone newline is no line break
+			"text": strings.ReplaceAll(n.body(), "\n", "\n\n"),
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := postJSON(ctx, url, payload); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed notifying %s: %v\n", url, err)
+	}
+}
+
+// noticeRealms returns the realms cmd touched, as recorded in its report and
+// audit changes, or the realms it targeted when it recorded none, e.g. when
+// it failed early.
+func noticeRealms(cmd *cobra.Command) []string {
+	var realms []string
+	add := func(r string) {
+		if r != "" && !slices.Contains(realms, r) {
+			realms = append(realms, r)
+		}
+	}
+	if r := reportResult(cmd); r != nil {
+		for _, l := range [][]audit.ItemResult{r.Created, r.Updated, r.Deleted, r.Skipped, r.Errors} {
+			for _, it := range l {
+				add(it.Realm)
+			}
+		}
+	}
+	for _, c := range cmdState(cmd).changes {
+		add(c.Realm)
+	}
+	if len(realms) == 0 {
+		if all, _ := cmd.Flags().GetBool("all-realms"); all {
+			return []string{"all realms"}
+		}
+		if f := cmd.Flags().Lookup("realm"); f != nil && f.Changed {
+			if sv, ok := f.Value.(pflag.SliceValue); ok {
+				for _, r := range sv.GetSlice() {
+					add(r)
+				}
+			} else {
+				add(f.Value.String())
+			}
+		}
+	}
+	if len(realms) == 0 {
+		add(resolveTargetRealms())
+	}
+	slices.Sort(realms)
+	return realms
+}
+
+// title is the first line of the notice, e.g. "kc users_create ok on acme (OPS-12)".
+func (n completionNotice) title() string {
+	t := fmt.Sprintf("kc %s %s", n.Kind, n.Status)
+	if len(n.Realms) > 0 {
+		t += " on " + strings.Join(n.Realms, ", ")
+	}
+	if n.Jira != "" {
+		t += " (" + n.Jira + ")"
+	}
+	return t
+}
+
+// body lists the details of the notice, one per line.
+func (n completionNotice) body() string {
+	lines := []string{"Command: " + n.Command}
+	if n.Actor != "" {
+		lines = append(lines, "By: "+n.Actor)
+	}
+	lines = append(lines, fmt.Sprintf("Created: %d, Updated: %d, Deleted: %d, Skipped: %d, Errors: %d, Changes: %d.",
+		n.Created, n.Updated, n.Deleted, n.Skipped, n.Errors, n.Changes))
+	lines = append(lines, fmt.Sprintf("Duration: %s", time.Duration(n.Duration*float64(time.Second)).Round(time.Millisecond)))
+	if n.Error != "" {
+		lines = append(lines, "Error: "+n.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// text renders the notice as a Slack message.
+func (n completionNotice) text() string {
+	return "*" + n.title() + "*\n" + n.body()
+}
+
+// postJSON posts v as JSON to url and fails unless the answer is 2xx.
+func postJSON(ctx context.Context, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
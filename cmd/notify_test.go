@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// notifyCmd returns `kc users create`, as mutating as the real one, with a
+// run state that created alice in realm test.
+func notifyCmd(t *testing.T, args ...string) (*cobra.Command, *bytes.Buffer) {
+	t.Helper()
+	root := &cobra.Command{Use: "kc"}
+	users := &cobra.Command{Use: "users"}
+	cmd := &cobra.Command{Use: "create", Run: func(*cobra.Command, []string) {}}
+	mutating(cmd, "manage-users")
+	cmd.Flags().Bool("dry-run", false, "")
+	root.AddCommand(users)
+	users.AddCommand(cmd)
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(append([]string{"users", "create"}, args...))
+	st := newRunState()
+	st.jira = "OPS-12"
+	if err := root.ExecuteContext(withRunState(context.Background(), st)); err != nil {
+		t.Fatal(err)
+	}
+	newReport(cmd).add(kcops.Created, audit.ItemResult{Kind: "user", Realm: "test", Name: "alice"}, "")
+	recordChange(cmd, "test", "alice", "1")
+	return cmd, &out
+}
+
+func TestNotifyCompletion(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		args    []string
+		status  int
+		cmdErr  error
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "generic",
+			format: "generic",
+			want:   []string{`"kind":"users_create"`, `"realms":["test"]`, `"status":"ok"`, `"jira":"OPS-12"`, `"created":1`, `"changes":1`},
+		},
+		{
+			name:   "slack",
+			format: "slack",
+			cmdErr: errors.New("boom"),
+			want:   []string{`"text":"*kc users_create error on test (OPS-12)*`, `Created: 1,`, `Error: boom`},
+		},
+		{
+			name:   "teams",
+			format: "teams",
+			want:   []string{`"@type":"MessageCard"`, `"themeColor":"2EB886"`, `"title":"kc users_create ok on test (OPS-12)"`},
+		},
+		{
+			name: "skips a dry run",
+			args: []string{"--dry-run"},
+		},
+		{
+			name:    "warns when the webhook fails",
+			status:  http.StatusBadGateway,
+			want:    []string{`"kind":"users_create"`},
+			wantErr: "Warning: failed notifying",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				if !json.Valid(b) || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("payload %q is not JSON", b)
+				}
+				got = append(got, string(b))
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+			}))
+			defer srv.Close()
+			config.Global = config.Config{NotifyWebhook: srv.URL, NotifyFormat: tt.format}
+			t.Cleanup(func() { config.Global = config.Config{} })
+
+			cmd, out := notifyCmd(t, tt.args...)
+			status := "ok"
+			if tt.cmdErr != nil {
+				status = "error"
+			}
+			notifyCompletion(cmd, status, tt.cmdErr, time.Now(), time.Second)
+			if len(tt.want) == 0 {
+				if len(got) > 0 {
+					t.Errorf("posted %q, want nothing", got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("posted %d notice(s), want 1", len(got))
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got[0], w) {
+					t.Errorf("payload %s lacks %s", got[0], w)
+				}
+			}
+			if !strings.Contains(out.String(), tt.wantErr) {
+				t.Errorf("output %q lacks %q", out.String(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotifySkipsReadOnlyCommands(t *testing.T) {
+	posted := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { posted = true }))
+	defer srv.Close()
+	config.Global = config.Config{NotifyWebhook: srv.URL}
+	t.Cleanup(func() { config.Global = config.Config{} })
+	cmd := &cobra.Command{Use: "list"}
+	cmd.SetContext(withRunState(context.Background(), newRunState()))
+	notifyCompletion(cmd, "ok", nil, time.Now(), time.Second)
+	if posted {
+		t.Error("a command that changes nothing was notified")
+	}
+}
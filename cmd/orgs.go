@@ -0,0 +1,649 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newOrgsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "orgs",
+		Short: "Manage organizations (Keycloak 25+)",
+	}
+	cmd.AddCommand(newOrgsCreateCmd())
+	cmd.AddCommand(newOrgsListCmd())
+	cmd.AddCommand(newOrgsUpdateCmd())
+	cmd.AddCommand(newOrgsDeleteCmd())
+	cmd.AddCommand(newOrgsMembersCmd())
+	cmd.AddCommand(newOrgsIDPCmd())
+	return cmd
+}
+
+func newOrgsMembersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "members",
+		Short: "Manage the members of an organization",
+	}
+	cmd.AddCommand(newOrgsMembersAddCmd())
+	cmd.AddCommand(newOrgsMembersRemoveCmd())
+	cmd.AddCommand(newOrgsMembersListCmd())
+	return cmd
+}
+
+func newOrgsIDPCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "idp",
+		Short: "Manage the identity providers of an organization",
+	}
+	cmd.AddCommand(newOrgsIDPLinkCmd())
+	return cmd
+}
+
+// orgsSession logs in for an orgs command and checks that the server
+// supports organizations, which older servers answer with a bare 404.
+func orgsSession(ctx context.Context) (keycloak.API, string, error) {
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := keycloak.RequireVersion(ctx, gc, token, keycloak.OrganizationsVersion, "organizations"); err != nil {
+		return nil, "", err
+	}
+	return gc, token, nil
+}
+
+// getOrganization returns the organization called name, failing when the
+// realm has none.
+func getOrganization(ctx context.Context, gc keycloak.API, token, realm, name string) (*keycloak.Organization, error) {
+	org, err := keycloak.FindOrganization(ctx, gc, token, realm, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed looking up organization %q in realm %s: %w", name, realm, err)
+	}
+	if org == nil {
+		return nil, fmt.Errorf("organization %q not found in realm %s", name, realm)
+	}
+	return org, nil
+}
+
+// orgItem describes an organization in the command report.
+func orgItem(realm string, org *keycloak.Organization) audit.ItemResult {
+	return audit.ItemResult{Kind: "organization", Realm: realm, Name: org.Name, ID: org.ID}
+}
+
+// orgDomains returns domain names as the domains of an organization.
+func orgDomains(names []string) []keycloak.OrganizationDomain {
+	out := make([]keycloak.OrganizationDomain, len(names))
+	for i, n := range names {
+		out[i] = keycloak.OrganizationDomain{Name: n}
+	}
+	return out
+}
+
+func domainNames(org *keycloak.Organization) []string {
+	out := make([]string, len(org.Domains))
+	for i, d := range org.Domains {
+		out[i] = d.Name
+	}
+	return out
+}
+
+// orgsCreateOptions holds the flags of `kc orgs create`.
+type orgsCreateOptions struct {
+	realm       string
+	name        string
+	alias       string
+	domains     []string
+	description string
+	redirectURL string
+	enabled     bool
+}
+
+func newOrgsCreateCmd() *cobra.Command {
+	o := &orgsCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an organization in a realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVar(&o.name, "name", "", "organization name (required)")
+	cmd.Flags().StringVar(&o.alias, "alias", "", "organization alias; defaults to the name on the server")
+	cmd.Flags().StringSliceVar(&o.domains, "domain", nil, "internet domain owned by the organization, e.g. acme.com. Repeatable; at least one is required")
+	cmd.Flags().StringVar(&o.description, "description", "", "organization description")
+	cmd.Flags().StringVar(&o.redirectURL, "redirect-url", "", "URL members are sent to after registering or accepting an invitation")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", true, "whether the organization is enabled; defaults to true")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *orgsCreateOptions) run(cmd *cobra.Command) error {
+	if o.name == "" {
+		return errors.New("missing --name: organization name is required")
+	}
+	if len(o.domains) == 0 {
+		return errors.New("missing --domain: provide at least one --domain")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := keycloak.FindOrganization(ctx, gc, token, realm, o.name)
+	if err != nil {
+		return fmt.Errorf("failed looking up organization %q in realm %s: %w", o.name, realm, err)
+	}
+	rep := newReport(cmd)
+	if existing != nil {
+		rep.skip(orgItem(realm, existing), "already exists", fmt.Sprintf("Organization %q already exists in realm %q. Skipped.", o.name, realm))
+		return rep.print(cmd, realm, "Done. Created: 0, Skipped: 1.")
+	}
+	org := keycloak.Organization{
+		Name:        o.name,
+		Alias:       o.alias,
+		Enabled:     o.enabled,
+		Description: o.description,
+		RedirectURL: o.redirectURL,
+		Domains:     orgDomains(o.domains),
+	}
+	if err := keycloak.CreateOrganization(ctx, gc, token, realm, org); err != nil {
+		return fmt.Errorf("failed creating organization %q in realm %s: %w", o.name, realm, err)
+	}
+	created, err := getOrganization(ctx, gc, token, realm, o.name)
+	if err != nil {
+		return fmt.Errorf("organization %q created but not found afterwards: %w", o.name, err)
+	}
+	domains := strings.Join(o.domains, ",")
+	fields := appendFieldChange(nil, "domains", nil, &domains)
+	fields = appendFieldChange(fields, "enabled", nil, &o.enabled)
+	recordChange(cmd, realm, o.name, created.ID, fields...)
+	rep.add(kcops.Created, orgItem(realm, created), fmt.Sprintf("Created organization %q (ID: %s) in realm %q.", o.name, created.ID, realm))
+	return rep.print(cmd, realm, "Done. Created: 1, Skipped: 0.")
+}
+
+// orgsListOptions holds the flags of `kc orgs list`.
+type orgsListOptions struct {
+	realm     string
+	search    string
+	normalize bool
+}
+
+func newOrgsListCmd() *cobra.Command {
+	o := &orgsListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the organizations of a realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.search, "search", "", "only organizations whose name or domain contains this text")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
+}
+
+func (o *orgsListOptions) run(cmd *cobra.Command) error {
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+	orgs, err := keycloak.GetOrganizations(ctx, gc, token, realm, o.search, false)
+	if err != nil {
+		return fmt.Errorf("failed listing organizations in realm %s: %w", realm, err)
+	}
+	if o.normalize {
+		slices.SortFunc(orgs, func(a, b *keycloak.Organization) int { return strings.Compare(a.Name, b.Name) })
+		for _, org := range orgs {
+			slices.SortFunc(org.Domains, func(a, b keycloak.OrganizationDomain) int { return strings.Compare(a.Name, b.Name) })
+		}
+	}
+	if outputFormat == "json" {
+		return printListJSON(cmd, orgs, o.normalize)
+	}
+	lines := make([]string, 0, len(orgs)+1)
+	for _, org := range orgs {
+		state := "enabled"
+		if !org.Enabled {
+			state = "disabled"
+		}
+		lines = append(lines, fmt.Sprintf("%s  (alias %s, %s)  domains: %s", org.Name, org.Alias, state, strings.Join(domainNames(org), ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(orgs)))
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// orgsUpdateOptions holds the flags of `kc orgs update`.
+type orgsUpdateOptions struct {
+	realm       string
+	name        string
+	newName     string
+	domains     []string
+	description string
+	redirectURL string
+	enabled     bool
+}
+
+func newOrgsUpdateCmd() *cobra.Command {
+	o := &orgsUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update an organization; only the given flags are changed",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVar(&o.name, "name", "", "name of the organization to update (required)")
+	cmd.Flags().StringVar(&o.newName, "new-name", "", "rename the organization")
+	cmd.Flags().StringSliceVar(&o.domains, "domain", nil, "replace the domains of the organization. Repeatable")
+	cmd.Flags().StringVar(&o.description, "description", "", "new description")
+	cmd.Flags().StringVar(&o.redirectURL, "redirect-url", "", "new redirect URL")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", true, "enable or disable the organization")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *orgsUpdateOptions) run(cmd *cobra.Command) error {
+	if o.name == "" {
+		return errors.New("missing --name: organization name is required")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+	org, err := getOrganization(ctx, gc, token, realm, o.name)
+	if err != nil {
+		return err
+	}
+	before := *org
+
+	var fields []audit.FieldChange
+	flags := cmd.Flags()
+	if flags.Changed("new-name") && o.newName != org.Name {
+		fields = appendFieldChange(fields, "name", &org.Name, &o.newName)
+		org.Name = o.newName
+	}
+	if flags.Changed("domain") {
+		oldDomains, newDomains := strings.Join(domainNames(org), ","), strings.Join(o.domains, ",")
+		if oldDomains != newDomains {
+			fields = appendFieldChange(fields, "domains", &oldDomains, &newDomains)
+			org.Domains = orgDomains(o.domains)
+		}
+	}
+	if flags.Changed("description") && o.description != org.Description {
+		fields = appendFieldChange(fields, "description", &org.Description, &o.description)
+		org.Description = o.description
+	}
+	if flags.Changed("redirect-url") && o.redirectURL != org.RedirectURL {
+		fields = appendFieldChange(fields, "redirectUrl", &org.RedirectURL, &o.redirectURL)
+		org.RedirectURL = o.redirectURL
+	}
+	if flags.Changed("enabled") && o.enabled != org.Enabled {
+		fields = appendFieldChange(fields, "enabled", &org.Enabled, &o.enabled)
+		org.Enabled = o.enabled
+	}
+
+	rep := newReport(cmd)
+	if len(fields) == 0 {
+		rep.skip(orgItem(realm, org), "no changes", fmt.Sprintf("Organization %q in realm %q already up to date. Skipped.", o.name, realm))
+		return rep.print(cmd, realm, "Done. Updated: 0, Skipped: 1.")
+	}
+	if err := keycloak.UpdateOrganization(ctx, gc, token, realm, *org); err != nil {
+		return fmt.Errorf("failed updating organization %q in realm %s: %w", o.name, realm, err)
+	}
+	recordChangeWithBefore(cmd, realm, o.name, org.ID, before, fields...)
+	rep.add(kcops.Updated, orgItem(realm, org), fmt.Sprintf("Updated organization %q (ID: %s) in realm %q.", o.name, org.ID, realm))
+	return rep.print(cmd, realm, "Done. Updated: 1, Skipped: 0.")
+}
+
+// orgsDeleteOptions holds the flags of `kc orgs delete`.
+type orgsDeleteOptions struct {
+	realm           string
+	names           []string
+	continueOnError bool
+}
+
+func newOrgsDeleteCmd() *cobra.Command {
+	o := &orgsDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete organization(s); their members stay in the realm",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "organization name(s) to delete. Repeatable; required.")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *orgsDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: provide at least one --name")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, name := range o.names {
+		org, err := keycloak.FindOrganization(ctx, gc, token, realm, name)
+		if err != nil {
+			err := fmt.Errorf("failed looking up organization %q in realm %s: %w", name, realm, err)
+			if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "organization", Realm: realm, Name: name}, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if org == nil {
+			rep.skip(audit.ItemResult{Kind: "organization", Realm: realm, Name: name}, "not found", fmt.Sprintf("Organization %q not found in realm %q. Skipped.", name, realm))
+			continue
+		}
+		if err := keycloak.DeleteOrganization(ctx, gc, token, realm, org.ID); err != nil {
+			err := fmt.Errorf("failed deleting organization %q in realm %s: %w", name, realm, err)
+			if err := rep.failOrStop(o.continueOnError, orgItem(realm, org), err); err != nil {
+				return err
+			}
+			continue
+		}
+		recordChangeWithBefore(cmd, realm, name, org.ID, org)
+		rep.add(kcops.Deleted, orgItem(realm, org), fmt.Sprintf("Deleted organization %q (ID: %s) in realm %q.", name, org.ID, realm))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// orgsMembersOptions holds the flags of `kc orgs members add` and
+// `kc orgs members remove`.
+type orgsMembersOptions struct {
+	realm           string
+	org             string
+	usernames       []string
+	continueOnError bool
+}
+
+func newOrgsMembersAddCmd() *cobra.Command {
+	o := &orgsMembersOptions{}
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add existing realm users to an organization",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, true)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	addOrgsMembersFlags(cmd, o)
+	return cmd
+}
+
+func newOrgsMembersRemoveCmd() *cobra.Command {
+	o := &orgsMembersOptions{}
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove users from an organization",
+		Long:  "Remove users from an organization. Managed members, created through an identity provider of the organization, are deleted from the realm by Keycloak.",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, false)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	addOrgsMembersFlags(cmd, o)
+	return cmd
+}
+
+func addOrgsMembersFlags(cmd *cobra.Command, o *orgsMembersOptions) {
+	cmd.Flags().StringVar(&o.org, "org", "", "organization name (required)")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s). Repeatable; required.")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+}
+
+func (o *orgsMembersOptions) run(cmd *cobra.Command, add bool) error {
+	if o.org == "" {
+		return errors.New("missing --org: organization name is required")
+	}
+	if len(o.usernames) == 0 {
+		return errors.New("missing --username: provide at least one --username")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+	org, err := getOrganization(ctx, gc, token, realm, o.org)
+	if err != nil {
+		return err
+	}
+	members, err := keycloak.GetOrganizationMembers(ctx, gc, token, realm, org.ID)
+	if err != nil {
+		return fmt.Errorf("failed listing members of organization %q in realm %s: %w", o.org, realm, err)
+	}
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[gocloak.PString(m.ID)] = true
+	}
+
+	rep := newReport(cmd)
+	for _, un := range o.usernames {
+		item := audit.ItemResult{Kind: "organizationMember", Realm: realm, Name: o.org + "/" + un}
+		users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+		if err != nil {
+			err := fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+			if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(users) == 0 || users[0].ID == nil {
+			rep.skip(item, "user not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+			continue
+		}
+		userID := *users[0].ID
+		item.ID = userID
+		switch {
+		case add && isMember[userID]:
+			rep.skip(item, "already a member", fmt.Sprintf("User %q is already a member of organization %q. Skipped.", un, o.org))
+			continue
+		case !add && !isMember[userID]:
+			rep.skip(item, "not a member", fmt.Sprintf("User %q is not a member of organization %q. Skipped.", un, o.org))
+			continue
+		}
+		if add {
+			err = keycloak.AddOrganizationMember(ctx, gc, token, realm, org.ID, userID)
+		} else {
+			err = keycloak.RemoveOrganizationMember(ctx, gc, token, realm, org.ID, userID)
+		}
+		if err != nil {
+			err := fmt.Errorf("failed updating membership of user %q in organization %q: %w", un, o.org, err)
+			if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+				return err
+			}
+			continue
+		}
+		recordChange(cmd, realm, o.org+"/"+un, userID)
+		if add {
+			rep.add(kcops.Created, item, fmt.Sprintf("Added user %q to organization %q (realm %q).", un, o.org, realm))
+		} else {
+			rep.add(kcops.Deleted, item, fmt.Sprintf("Removed user %q from organization %q (realm %q).", un, o.org, realm))
+		}
+	}
+	if add {
+		return rep.print(cmd, realm, fmt.Sprintf("Done. Added: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Removed: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// orgsMembersListOptions holds the flags of `kc orgs members list`.
+type orgsMembersListOptions struct {
+	realm     string
+	org       string
+	normalize bool
+}
+
+func newOrgsMembersListCmd() *cobra.Command {
+	o := &orgsMembersListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the members of an organization",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.org, "org", "", "organization name (required)")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
+}
+
+func (o *orgsMembersListOptions) run(cmd *cobra.Command) error {
+	if o.org == "" {
+		return errors.New("missing --org: organization name is required")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+	org, err := getOrganization(ctx, gc, token, realm, o.org)
+	if err != nil {
+		return err
+	}
+	members, err := keycloak.GetOrganizationMembers(ctx, gc, token, realm, org.ID)
+	if err != nil {
+		return fmt.Errorf("failed listing members of organization %q in realm %s: %w", o.org, realm, err)
+	}
+	if o.normalize {
+		slices.SortFunc(members, func(a, b *gocloak.User) int {
+			return strings.Compare(gocloak.PString(a.Username), gocloak.PString(b.Username))
+		})
+	}
+	if outputFormat == "json" {
+		return printListJSON(cmd, members, o.normalize)
+	}
+	lines := make([]string, 0, len(members)+1)
+	for _, m := range members {
+		line := fmt.Sprintf("%s  %s", gocloak.PString(m.Username), gocloak.PString(m.Email))
+		if !o.normalize {
+			line += fmt.Sprintf("  (ID: %s)", gocloak.PString(m.ID))
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(members)))
+	printBox(cmd, lines, realm+" / "+o.org)
+	return nil
+}
+
+// orgsIDPLinkOptions holds the flags of `kc orgs idp link`.
+type orgsIDPLinkOptions struct {
+	realm   string
+	org     string
+	aliases []string
+}
+
+func newOrgsIDPLinkCmd() *cobra.Command {
+	o := &orgsIDPLinkOptions{}
+	cmd := &cobra.Command{
+		Use:   "link",
+		Short: "Link identity provider(s) of the realm to an organization",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVar(&o.org, "org", "", "organization name (required)")
+	cmd.Flags().StringSliceVar(&o.aliases, "alias", nil, "identity provider alias(es). Repeatable; required.")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *orgsIDPLinkOptions) run(cmd *cobra.Command) error {
+	if o.org == "" {
+		return errors.New("missing --org: organization name is required")
+	}
+	if len(o.aliases) == 0 {
+		return errors.New("missing --alias: provide at least one --alias")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := orgsSession(ctx)
+	if err != nil {
+		return err
+	}
+	org, err := getOrganization(ctx, gc, token, realm, o.org)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, alias := range o.aliases {
+		item := audit.ItemResult{Kind: "organizationIdp", Realm: realm, Name: o.org + "/" + alias}
+		if err := keycloak.LinkOrganizationIDP(ctx, gc, token, realm, org.ID, alias); err != nil {
+			if strings.Contains(err.Error(), "409") {
+				rep.skip(item, "already linked", fmt.Sprintf("Identity provider %q is already linked to organization %q. Skipped.", alias, o.org))
+				continue
+			}
+			return fmt.Errorf("failed linking identity provider %q to organization %q in realm %s: %w", alias, o.org, realm, err)
+		}
+		recordChange(cmd, realm, o.org+"/"+alias, org.ID)
+		rep.add(kcops.Created, item, fmt.Sprintf("Linked identity provider %q to organization %q (realm %q).", alias, o.org, realm))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Linked: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
+}
+
+func init() {
+	rootCmd.AddCommand(newOrgsCmd())
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+	"kc/internal/manifest"
+
+	"github.com/spf13/cobra"
+)
+
+// planVersion is the format of the files written by `kc plan`.
+const planVersion = 1
+
+// changePlan is the file written by `kc plan` and applied by `kc apply-plan`:
+// the manifest as reviewed, with the actions it led to and the hash of both.
+type changePlan struct {
+	Version   int               `json:"version"`
+	CreatedAt string            `json:"createdAt"`
+	CreatedBy string            `json:"createdBy"`
+	Server    string            `json:"server"`
+	File      string            `json:"file"`
+	Realms    []string          `json:"realms,omitempty"`
+	Prune     bool              `json:"prune,omitempty"`
+	Manifest  *manifest.State   `json:"manifest"`
+	Actions   []manifest.Action `json:"actions"`
+	Hash      string            `json:"hash,omitempty"`
+}
+
+// contentHash returns the SHA-256 of p without its hash, e.g. sha256:3fa9....
+func (p changePlan) contentHash() (string, error) {
+	p.Hash = ""
+	b, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// planOptions holds the flags of `kc plan`.
+type planOptions struct {
+	file   string
+	out    string
+	realms []string
+	prune  bool
+}
+
+func newPlanCmd() *cobra.Command {
+	o := &planOptions{}
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Write the changes a manifest needs to a plan file for review and approval",
+		Long: `Compute what kc apply would change to converge the server to a manifest and
+write it, with the manifest itself, to a plan file identified by the hash of
+its content. Nothing is changed. A reviewer checks the plan and, once
+approved, kc apply-plan applies it given that hash and the approver, so no
+change reaches the server without a second person (four-eyes rule).`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "desired-state manifest (YAML or JSON). Required.")
+	cmd.Flags().StringVar(&o.out, "out", "", "plan file to write. Required.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "only plan the given manifest realm(s)")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "also delete managed entities that are not declared in the manifest")
+	return cmd
+}
+
+func (o *planOptions) run(cmd *cobra.Command) error {
+	if o.file == "" {
+		return errors.New("missing --file: provide the desired-state manifest")
+	}
+	if o.out == "" {
+		return errors.New("missing --out: provide the plan file to write")
+	}
+	state, err := manifest.Load(o.file)
+	if err != nil {
+		return fmt.Errorf("invalid manifest %s: %w", o.file, err)
+	}
+	for _, r := range o.realms {
+		if state.Find(r) == nil {
+			return fmt.Errorf("realm %q is not declared in %s", r, o.file)
+		}
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	actions, err := planManifest(ctx, gc, token, state, o.realms, o.prune)
+	if err != nil {
+		return err
+	}
+
+	_, actor := resolveActor()
+	plan := changePlan{
+		Version:   planVersion,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedBy: actor,
+		Server:    config.Global.ServerURL,
+		File:      o.file,
+		Realms:    o.realms,
+		Prune:     o.prune,
+		Manifest:  state,
+		Actions:   actions,
+	}
+	if plan.Actions == nil {
+		plan.Actions = []manifest.Action{}
+	}
+	if plan.Hash, err = plan.contentHash(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the manifest may hold passwords
+	if err := os.WriteFile(o.out, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed writing %s: %w", o.out, err)
+	}
+
+	var lines []string
+	for _, a := range actions {
+		lines = append(lines, a.String())
+	}
+	if len(actions) == 0 {
+		lines = append(lines, "No differences: the server matches the manifest.")
+	}
+	lines = append(lines,
+		fmt.Sprintf("Plan written to %s: %d action(s).", o.out, len(actions)),
+		"Hash: "+plan.Hash,
+		fmt.Sprintf("Apply it once approved: kc apply-plan %s --hash %s --approved-by <approver>", o.out, plan.Hash))
+	printBox(cmd, lines, manifestRealmLabel(state, o.realms))
+	return nil
+}
+
+// applyPlanOptions holds the flags of `kc apply-plan`.
+type applyPlanOptions struct {
+	hash            string
+	approvedBy      string
+	continueOnError bool
+}
+
+func newApplyPlanCmd() *cobra.Command {
+	o := &applyPlanOptions{}
+	cmd := &cobra.Command{
+		Use:   "apply-plan <plan.json>",
+		Short: "Apply an approved plan written by kc plan",
+		Long: `Apply a plan written by kc plan. --hash must be the hash kc plan printed, so
+the plan applied is the one reviewed: a plan edited since fails to load.
+--approved-by names the approver, who cannot be the identity that wrote the
+plan; both are recorded in the audit log.
+
+The server is planned again first. If its state changed since the plan was
+written, so that the actions differ, nothing is applied: run kc plan again
+and have the new plan approved.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, args[0])
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-clients", "manage-realm")
+	cmd.Flags().StringVar(&o.hash, "hash", "", "hash of the approved plan, as printed by kc plan. Required.")
+	cmd.Flags().StringVar(&o.approvedBy, "approved-by", "", "identity of the approver. Required.")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *applyPlanOptions) run(cmd *cobra.Command, path string) error {
+	if o.hash == "" {
+		return errors.New("missing --hash: provide the hash of the approved plan")
+	}
+	if strings.TrimSpace(o.approvedBy) == "" {
+		return errors.New("missing --approved-by: provide the identity of the approver")
+	}
+	plan, err := loadPlan(path)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(strings.TrimPrefix(o.hash, "sha256:"), strings.TrimPrefix(plan.Hash, "sha256:")) {
+		return fmt.Errorf("--hash %s does not match plan %s (%s): apply the plan that was approved", o.hash, path, plan.Hash)
+	}
+	if plan.CreatedBy != "" && strings.EqualFold(plan.CreatedBy, o.approvedBy) {
+		return fmt.Errorf("plan %s was written by %s, who cannot approve it too", path, plan.CreatedBy)
+	}
+	if plan.Server != config.Global.ServerURL {
+		return fmt.Errorf("plan %s was written for %s, not %s", path, plan.Server, config.Global.ServerURL)
+	}
+
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	actions, err := planManifest(ctx, gc, token, plan.Manifest, plan.Realms, plan.Prune)
+	if err != nil {
+		return err
+	}
+	if stale, err := actionsDiffer(plan.Actions, actions); err != nil {
+		return err
+	} else if stale {
+		// the new actions explain why; usage would only bury them
+		cmd.SilenceUsage = true
+		lines := []string{"The server changed since the plan was written. Actions now needed:"}
+		for _, a := range actions {
+			lines = append(lines, a.String())
+		}
+		printBox(cmd, lines, manifestRealmLabel(plan.Manifest, plan.Realms))
+		return fmt.Errorf("plan %s is stale: nothing was applied; run kc plan again and have it approved", path)
+	}
+	var realms []string
+	for _, a := range actions {
+		if !slices.Contains(realms, a.Realm) {
+			realms = append(realms, a.Realm)
+		}
+	}
+	if err := preflight(ctx, cmd, gc, token, realms); err != nil {
+		return err
+	}
+
+	cmdState(cmd).approval = &audit.Approval{PlanHash: plan.Hash, ApprovedBy: o.approvedBy, PlannedBy: plan.CreatedBy}
+	rep := newReport(cmd)
+	rep.note(fmt.Sprintf("Applying plan %s (%s), approved by %s.", path, plan.Hash, o.approvedBy))
+	if err := applyActions(ctx, gc, token, plan.Manifest, actions, rep, o.continueOnError); err != nil {
+		return err
+	}
+	return rep.print(cmd, manifestRealmLabel(plan.Manifest, plan.Realms), fmt.Sprintf("Done. Created: %d, Updated: %d, Deleted: %d.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Deleted)))
+}
+
+// loadPlan reads a plan file and checks that its content still matches its hash.
+func loadPlan(path string) (*changePlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan changePlan
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("invalid plan %s: unsupported version %d", path, plan.Version)
+	}
+	if plan.Manifest == nil {
+		return nil, fmt.Errorf("invalid plan %s: no manifest", path)
+	}
+	if err := plan.Manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	sum, err := plan.contentHash()
+	if err != nil {
+		return nil, err
+	}
+	if sum != plan.Hash {
+		return nil, fmt.Errorf("plan %s was modified after it was written: its content does not match its hash", path)
+	}
+	return &plan, nil
+}
+
+// actionsDiffer reports whether the actions planned now differ from those
+// of the plan file. Values decoded from the file lose their Go types, so
+// both are compared as JSON.
+func actionsDiffer(planned, now []manifest.Action) (bool, error) {
+	if now == nil {
+		now = []manifest.Action{}
+	}
+	a, err := json.Marshal(planned)
+	if err != nil {
+		return false, err
+	}
+	b, err := json.Marshal(now)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(a, b), nil
+}
+
+func init() {
+	rootCmd.AddCommand(newPlanCmd())
+	rootCmd.AddCommand(newApplyPlanCmd())
+}
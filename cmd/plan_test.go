@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kc/internal/manifest"
+)
+
+func TestPlanContentHash(t *testing.T) {
+	plan := changePlan{Version: planVersion, Server: "https://kc.example.org", Manifest: &manifest.State{}, Actions: []manifest.Action{}}
+	sum, err := plan.contentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sum, "sha256:") || len(sum) != len("sha256:")+64 {
+		t.Fatalf("hash = %q, want sha256: and 64 hex digits", sum)
+	}
+	plan.Hash = sum
+	if again, _ := plan.contentHash(); again != sum {
+		t.Errorf("hash with Hash set = %q, want %q: the hash must not cover itself", again, sum)
+	}
+	plan.Prune = true
+	if changed, _ := plan.contentHash(); changed == sum {
+		t.Error("hash unchanged after the plan changed")
+	}
+}
+
+func TestPlanAndApplyPlan(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(file, []byte(applyManifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "plan.json")
+	api := newTestAPI(t)
+
+	if _, _, err := run(t, api, newPlanCmd, "plan", "-f", file, "--out", path); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := loadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Actions) == 0 {
+		t.Fatal("plan has no actions")
+	}
+	if _, err := api.GetRealm(context.Background(), "", "shop"); err == nil {
+		t.Fatal("kc plan changed the server")
+	}
+
+	t.Run("rejects an edited plan", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		edited := filepath.Join(t.TempDir(), "plan.json")
+		if err := os.WriteFile(edited, []byte(strings.Replace(string(data), `"web"`, `"evil"`, 1)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = run(t, api, newApplyPlanCmd, "apply-plan", edited, "--hash", plan.Hash, "--approved-by", "bob")
+		checkErr(t, err, "was modified after it was written")
+	})
+	t.Run("rejects another hash", func(t *testing.T) {
+		_, _, err := run(t, api, newApplyPlanCmd, "apply-plan", path, "--hash", "sha256:"+strings.Repeat("0", 64), "--approved-by", "bob")
+		checkErr(t, err, "does not match plan")
+	})
+	t.Run("applies the approved plan", func(t *testing.T) {
+		_, entry, err := run(t, api, newApplyPlanCmd, "apply-plan", path, "--hash", plan.Hash, "--approved-by", "bob")
+		checkErr(t, err, "")
+		checkEntry(t, entry, "ok", wantResult{created: []string{"shop/shop", "shop/viewer", "shop/web", "shop//staff", "shop/alice"}})
+		if a := entry.Details.Approval; a == nil || a.PlanHash != plan.Hash || a.ApprovedBy != "bob" {
+			t.Errorf("approval = %+v, want the plan hash approved by bob", a)
+		}
+	})
+	t.Run("refuses a stale plan", func(t *testing.T) {
+		_, entry, err := run(t, api, newApplyPlanCmd, "apply-plan", path, "--hash", plan.Hash, "--approved-by", "bob")
+		checkErr(t, err, "is stale")
+		if entry.Details.Approval != nil {
+			t.Errorf("approval recorded for a stale plan: %+v", entry.Details.Approval)
+		}
+	})
+}
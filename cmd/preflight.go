@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/spf13/cobra"
+)
+
+// adminRolesAnnotation holds the comma-separated admin roles a command needs
+// in every target realm. Only commands that change server state carry it.
+const adminRolesAnnotation = "kc/admin-roles"
+
+// preflightEnabled is set by the global --preflight flag.
+var preflightEnabled bool
+
+// mutating marks cmd as changing server state with the given admin roles,
+// e.g. manage-users, which --preflight checks before any change is made.
+func mutating(cmd *cobra.Command, roles ...string) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[adminRolesAnnotation] = strings.Join(roles, ",")
+}
+
+// isMutating reports whether cmd changes server state.
+func isMutating(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[adminRolesAnnotation]
+	return ok
+}
+
+// requiredAdminRoles returns the admin roles cmd needs in each target realm.
+func requiredAdminRoles(cmd *cobra.Command) []string {
+	v := cmd.Annotations[adminRolesAnnotation]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// preflight checks, with --preflight, that the authenticated principal holds
+// the admin roles of a mutating cmd in every realm, and fails listing every
+// gap before anything changes. The roles are read from the access token;
+// with a nil gc it logs in first. Protected realms are refused first, with
+// or without --preflight (see checkProtected).
+func preflight(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token string, realms []string) error {
+	if err := checkProtected(cmd, realms); err != nil {
+		return err
+	}
+	if !preflightEnabled || !isMutating(cmd) {
+		return nil
+	}
+	now := time.Now().Format(time.RFC3339)
+	if keycloak.FakeMode() {
+		fmt.Fprintf(cmd.ErrOrStderr(), "[%s] PREFLIGHT: skipped in offline mode\n", now)
+		return nil
+	}
+	if gc == nil {
+		var err error
+		if _, token, err = connect(ctx); err != nil {
+			return err
+		}
+	}
+	t, err := keycloak.DecodeJWT(token)
+	if err != nil {
+		return fmt.Errorf("preflight: cannot read the roles of the access token: %w", err)
+	}
+	required := requiredAdminRoles(cmd)
+	superAdmin := config.Global.AuthRealm == "master" && slices.Contains(t.RealmRoles(), "admin")
+
+	var gaps []string
+	for _, realm := range realms {
+		if superAdmin {
+			break
+		}
+		client, ok := keycloak.ManagementClient(config.Global.AuthRealm, realm)
+		if !ok {
+			gaps = append(gaps, fmt.Sprintf("%s: principal of realm %s cannot administer other realms", realm, config.Global.AuthRealm))
+			continue
+		}
+		held := t.ClientRoles(client)
+		var missing []string
+		for _, r := range required {
+			if !slices.Contains(held, r) {
+				missing = append(missing, r)
+			}
+		}
+		if len(missing) > 0 {
+			gaps = append(gaps, fmt.Sprintf("%s: missing %s", realm, strings.Join(missing, ", ")))
+		}
+	}
+	if len(gaps) > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("preflight failed in %d of %d realm(s), nothing was changed:\n  - %s", len(gaps), len(realms), strings.Join(gaps, "\n  - "))
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "[%s] PREFLIGHT: ok, %s in %d realm(s)\n", now, strings.Join(required, ", "), len(realms))
+	return nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// allowProtected is set by the global --allow-protected flag.
+var allowProtected bool
+
+// checkProtected refuses a mutating cmd that targets realms matching
+// protected_realms of config.json, unless --allow-protected is given and the
+// change is confirmed on stdin. Realms swept up by --all-realms count too.
+// The confirmation cannot share stdin with --stdin or a "-" value, so those
+// are refused too.
+func checkProtected(cmd *cobra.Command, realms []string) error {
+	if !isMutating(cmd) || len(config.Global.ProtectedRealms) == 0 {
+		return nil
+	}
+	st := cmdState(cmd)
+	var protected []string
+	for _, r := range realms {
+		if !st.confirmed[r] && isProtected(r) {
+			protected = append(protected, r)
+		}
+	}
+	if len(protected) == 0 {
+		return nil
+	}
+	if !allowProtected {
+		return fmt.Errorf("realm(s) %s are protected (protected_realms in config.json): pass --allow-protected to change them, or leave them out with --exclude-realm", strings.Join(protected, ", "))
+	}
+	if f := stdinFlag(cmd); f != "" {
+		return fmt.Errorf("--allow-protected cannot be combined with %s: the confirmation is read from stdin", f)
+	}
+	ok, err := newWizard(cmd).askBool(fmt.Sprintf("Change protected realm(s) %s?", strings.Join(protected, ", ")), false)
+	if err != nil {
+		return fmt.Errorf("--allow-protected needs a confirmation on stdin: %w", err)
+	}
+	if !ok {
+		return errWizardAborted
+	}
+	for _, r := range protected {
+		st.confirmed[r] = true
+	}
+	return nil
+}
+
+// stdinFlag returns the flag of cmd that reads stdin, --stdin or a
+// sensitive flag given "-", e.g. "--password -", or "" when none does.
+func stdinFlag(cmd *cobra.Command) string {
+	if on, _ := cmd.Flags().GetBool("stdin"); on {
+		return "--stdin"
+	}
+	name := ""
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if name != "" || !sensitiveFlags[f.Name] {
+			return
+		}
+		values := []string{f.Value.String()}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			values = sv.GetSlice()
+		}
+		if slices.Contains(values, "-") {
+			name = "--" + f.Name + " -"
+		}
+	})
+	return name
+}
+
+// isProtected reports whether realm matches protected_realms of config.json.
+func isProtected(realm string) bool {
+	return slices.ContainsFunc(config.Global.ProtectedRealms, func(p string) bool { return matchName(p, realm) })
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&allowProtected, "allow-protected", false, "let a command that changes the server target realms listed in protected_realms of config.json, after a confirmation")
+}
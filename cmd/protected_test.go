@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"kc/internal/config"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestProtectedRealms(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		stdin    string
+		wantErr  string
+		wantLeft int
+	}{
+		{
+			name:     "refused without --allow-protected",
+			args:     []string{"--username", "alice"},
+			wantErr:  "are protected",
+			wantLeft: 2,
+		},
+		{
+			name:     "changed once confirmed",
+			args:     []string{"--username", "alice", "--allow-protected"},
+			stdin:    "y\n",
+			wantLeft: 1,
+		},
+		{
+			name:     "aborted when not confirmed",
+			args:     []string{"--username", "alice", "--allow-protected"},
+			stdin:    "n\n",
+			wantErr:  errWizardAborted.Error(),
+			wantLeft: 2,
+		},
+		{
+			name:     "refused with --stdin",
+			args:     []string{"--allow-protected", "--stdin"},
+			stdin:    "alice\ny\n",
+			wantErr:  "--allow-protected cannot be combined with --stdin",
+			wantLeft: 2,
+		},
+		{
+			name:     "both confirmations of --match read from one stdin",
+			args:     []string{"--match", "*", "--allow-protected"},
+			stdin:    "y\ny\n",
+			wantLeft: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := newTestAPI(t)
+			for _, name := range []string{"alice", "bob"} {
+				if _, err := api.CreateUser(ctx, "", "test", gocloak.User{Username: gocloak.StringP(name)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			config.Global = config.Config{AuthRealm: "master", Realm: "master", ProtectedRealms: []string{"t*"}}
+			_, _, err := runIn(t, tt.stdin, api, newUsersCmd, append([]string{"users", "delete", "--realm", "test"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			users, _ := api.GetUsers(ctx, "", "test", gocloak.GetUsersParams{})
+			if len(users) != tt.wantLeft {
+				t.Errorf("%d user(s) left, want %d", len(users), tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestStdinFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"none", []string{"--username", "alice"}, ""},
+		{"--stdin", []string{"--stdin"}, "--stdin"},
+		{"a sensitive flag given -", []string{"--password", "x", "--password", "-"}, "--password -"},
+		{"- of another flag", []string{"--username", "-"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newUsersUpdateCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if got := stdinFlag(cmd); got != tt.want {
+				t.Errorf("stdinFlag = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
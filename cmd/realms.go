@@ -1,47 +1,190 @@
 package cmd
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"kc/internal/audit"
 	"kc/internal/keycloak"
+	"kc/pkg/kcops"
 
+	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var realmsCmd = &cobra.Command{
-	Use:   "realms",
-	Short: "Manage realms",
+func newRealmsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "realms",
+		Short: "Manage realms",
+	}
+	cmd.AddCommand(newRealmsListCmd())
+	cmd.AddCommand(newRealmsUpdateCmd())
+	cmd.AddCommand(newInitialAccessCmd())
+	cmd.AddCommand(newRegistrationPoliciesCmd())
+	cmd.AddCommand(newRealmsLogoutAllCmd())
+	cmd.AddCommand(newRealmsThemesCmd())
+	cmd.AddCommand(newRealmsBruteForceCmd())
+	cmd.AddCommand(newRealmsStatsCmd())
+	return cmd
 }
 
-var realmsListCmd = &cobra.Command{
-	Use:   "list",
-	Short: "List realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
-		if err != nil {
-			return err
-		}
-		realms, err := client.GetRealms(ctx, token)
-		if err != nil {
-			return err
-		}
+// realmsListOptions holds the flags of `kc realms list`.
+type realmsListOptions struct {
+	normalize   bool
+	details     bool
+	enabledOnly bool
+}
+
+// realmDetails is one realm of `kc realms list --details`.
+type realmDetails struct {
+	Realm         string `json:"realm"`
+	Enabled       bool   `json:"enabled"`
+	DisplayName   string `json:"displayName,omitempty"`
+	SSLRequired   string `json:"sslRequired,omitempty"`
+	Users         int    `json:"users"`
+	DefaultLocale string `json:"defaultLocale,omitempty"`
+}
+
+func newRealmsListCmd() *cobra.Command {
+	o := &realmsListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List realms",
+		Long: `List the realms of the server. --details adds, for each realm, whether it is
+enabled, its display name, SSL requirement, number of users and default
+locale; counting users takes one request per realm.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	addNormalizeFlag(cmd, &o.normalize)
+	cmd.Flags().BoolVar(&o.details, "details", false, "show enabled state, display name, SSL requirement, user count and default locale")
+	cmd.Flags().BoolVar(&o.enabledOnly, "enabled-only", false, "only list enabled realms")
+	return cmd
+}
+
+func (o *realmsListOptions) run(cmd *cobra.Command) error {
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := client.GetRealms(ctx, token)
+	if err != nil {
+		return err
+	}
+	realms = slices.DeleteFunc(realms, func(r *gocloak.RealmRepresentation) bool {
+		return r.Realm == nil || (o.enabledOnly && !gocloak.PBool(r.Enabled))
+	})
+	if o.normalize {
+		slices.SortFunc(realms, func(a, b *gocloak.RealmRepresentation) int { return strings.Compare(*a.Realm, *b.Realm) })
+	}
+	if !o.details {
 		lines := make([]string, 0, len(realms)+1)
 		for _, r := range realms {
-			if r.Realm != nil {
-				lines = append(lines, *r.Realm)
-			}
+			lines = append(lines, *r.Realm)
 		}
 		lines = append(lines, fmt.Sprintf("Total: %d", len(realms)))
 		printBox(cmd, lines, "all realms")
 		return nil
-	}),
+	}
+
+	out := make([]realmDetails, 0, len(realms))
+	for _, r := range realms {
+		users, err := keycloak.CountUsers(ctx, client, token, *r.Realm)
+		if err != nil {
+			return fmt.Errorf("failed counting users in realm %s: %w", *r.Realm, err)
+		}
+		out = append(out, realmDetails{
+			Realm:         *r.Realm,
+			Enabled:       gocloak.PBool(r.Enabled),
+			DisplayName:   gocloak.PString(r.DisplayName),
+			SSLRequired:   gocloak.PString(r.SslRequired),
+			Users:         users,
+			DefaultLocale: gocloak.PString(r.DefaultLocale),
+		})
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, out)
+	}
+	t := &reportTable{columns: []string{"realm", "enabled", "display name", "ssl", "users", "locale"}}
+	for _, d := range out {
+		t.rows = append(t.rows, []string{d.Realm, strconv.FormatBool(d.Enabled), d.DisplayName, d.SSLRequired, strconv.Itoa(d.Users), d.DefaultLocale})
+	}
+	printBox(cmd, append(t.textLines(), fmt.Sprintf("Total: %d", len(out))), "all realms")
+	return nil
+}
+
+// realmsLogoutAllOptions holds the flags of `kc realms logout-all`.
+type realmsLogoutAllOptions struct {
+	names []string
+}
+
+func newRealmsLogoutAllCmd() *cobra.Command {
+	o := &realmsLogoutAllOptions{}
+	cmd := &cobra.Command{
+		Use:   "logout-all",
+		Short: "End every session of a realm and revoke the tokens issued so far",
+		Long: `End every user session of the realm, set its not-before policy to now so
+tokens issued until now are rejected, and push that policy to the clients
+that have an admin URL. Meant for incident response: every user of the realm
+has to log in again.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to log out. Repeatable; required.")
+	return cmd
+}
+
+func (o *realmsLogoutAllOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to log out explicitly")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, o.names); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range o.names {
+		if _, err := keycloak.LogoutAll(ctx, gc, token, realm); err != nil {
+			return fmt.Errorf("failed logging out realm %s: %w", realm, err)
+		}
+		now := time.Now()
+		notBefore := int(now.Unix())
+		if err := gc.UpdateRealm(ctx, token, gocloak.RealmRepresentation{Realm: gocloak.StringP(realm), NotBefore: &notBefore}); err != nil {
+			return fmt.Errorf("sessions of realm %s ended, but setting not-before failed: %w", realm, err)
+		}
+		pushed, err := keycloak.PushRevocation(ctx, gc, token, realm)
+		if err != nil {
+			return fmt.Errorf("sessions of realm %s ended, but pushing not-before failed: %w", realm, err)
+		}
+		at := now.UTC().Format(time.RFC3339)
+		recordChange(cmd, realm, realm, "", appendFieldChange(nil, "notBefore", nil, &at)...)
+		rep.add(kcops.Updated, audit.ItemResult{Kind: "realm", Realm: realm, Name: realm},
+			fmt.Sprintf("Logged out all sessions of realm %q; tokens issued before %s are rejected.", realm, at))
+		if len(pushed.SuccessRequests) > 0 {
+			rep.note(fmt.Sprintf("  not-before pushed to %d client(s).", len(pushed.SuccessRequests)))
+		}
+		for _, u := range pushed.FailedRequests {
+			rep.note("  push failed: " + u)
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, o.names), fmt.Sprintf("Done. Logged out: %d realm(s).", len(rep.result.Updated)))
 }
 
 func init() {
-	rootCmd.AddCommand(realmsCmd)
-	realmsCmd.AddCommand(realmsListCmd)
+	rootCmd.AddCommand(newRealmsCmd())
 }
@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"kc/internal/audit"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// bruteForceField is one brute force detection setting of a realm, as shown
+// by `kc realms brute-force get`.
+type bruteForceField struct {
+	flag, field, value string
+}
+
+// bruteForceFields lists the brute force detection settings of r; unset
+// values are empty.
+func bruteForceFields(r *gocloak.RealmRepresentation) []bruteForceField {
+	boolean := func(p *bool) string {
+		if p == nil {
+			return ""
+		}
+		return strconv.FormatBool(*p)
+	}
+	seconds := func(p *int) string {
+		if p == nil {
+			return ""
+		}
+		return (time.Duration(*p) * time.Second).String()
+	}
+	millis := ""
+	if r.QuickLoginCheckMilliSeconds != nil {
+		millis = (time.Duration(*r.QuickLoginCheckMilliSeconds) * time.Millisecond).String()
+	}
+	failures := ""
+	if r.FailureFactor != nil {
+		failures = strconv.Itoa(*r.FailureFactor)
+	}
+	return []bruteForceField{
+		{"enabled", "bruteForceProtected", boolean(r.BruteForceProtected)},
+		{"permanent-lockout", "permanentLockout", boolean(r.PermanentLockout)},
+		{"max-failures", "failureFactor", failures},
+		{"wait-increment", "waitIncrementSeconds", seconds(r.WaitIncrementSeconds)},
+		{"max-wait", "maxFailureWaitSeconds", seconds(r.MaxFailureWaitSeconds)},
+		{"failure-reset", "maxDeltaTimeSeconds", seconds(r.MaxDeltaTimeSeconds)},
+		{"quick-login-check", "quickLoginCheckMilliSeconds", millis},
+		{"min-quick-login-wait", "minimumQuickLoginWaitSeconds", seconds(r.MinimumQuickLoginWaitSeconds)},
+	}
+}
+
+func newRealmsBruteForceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "brute-force",
+		Short: "Show and set the brute force detection (account lockout) of realms",
+	}
+	cmd.AddCommand(newRealmsBruteForceGetCmd())
+	cmd.AddCommand(newRealmsBruteForceSetCmd())
+	return cmd
+}
+
+// realmsBruteForceGetOptions holds the flags of `kc realms brute-force get`.
+type realmsBruteForceGetOptions struct {
+	names []string
+}
+
+func newRealmsBruteForceGetCmd() *cobra.Command {
+	o := &realmsBruteForceGetOptions{}
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Show the brute force detection settings of realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to show. Repeatable; required.")
+	return cmd
+}
+
+func (o *realmsBruteForceGetOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to show")
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	out := make([]map[string]string, 0, len(o.names))
+	for _, realm := range o.names {
+		r, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+		}
+		settings := map[string]string{"realm": realm}
+		for _, f := range bruteForceFields(r) {
+			settings[f.field] = f.value
+		}
+		out = append(out, settings)
+		if outputFormat == "json" {
+			continue
+		}
+		var lines []string
+		for _, f := range bruteForceFields(r) {
+			v := f.value
+			if v == "" {
+				v = "-"
+			}
+			lines = append(lines, fmt.Sprintf("%-21s %s", f.flag, v))
+		}
+		printBox(cmd, lines, realm)
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, out)
+	}
+	return nil
+}
+
+// realmsBruteForceSetOptions holds the flags of `kc realms brute-force set`.
+type realmsBruteForceSetOptions struct {
+	names             []string
+	enabled           bool
+	permanentLockout  bool
+	maxFailures       int
+	waitIncrement     time.Duration
+	maxWait           time.Duration
+	failureReset      time.Duration
+	quickLoginCheck   time.Duration
+	minQuickLoginWait time.Duration
+}
+
+func newRealmsBruteForceSetCmd() *cobra.Command {
+	o := &realmsBruteForceSetOptions{}
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set the brute force detection settings of realms",
+		Long: `Set the brute force detection of one or more realms, so the account lockout
+policy can be the same everywhere. Only the settings given change; realms
+that already match are skipped.
+
+After --max-failures failed logins the account is locked: for
+--wait-increment, growing with each further failure up to --max-wait, or
+until an administrator unlocks it with --permanent-lockout. Failures older
+than --failure-reset are forgotten. Logins faster than --quick-login-check
+apart lock the account for --min-quick-login-wait.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to change. Repeatable; required.")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", false, "turn brute force detection on or off")
+	cmd.Flags().BoolVar(&o.permanentLockout, "permanent-lockout", false, "lock accounts until an administrator unlocks them")
+	cmd.Flags().IntVar(&o.maxFailures, "max-failures", 0, "failed logins before the account is locked")
+	cmd.Flags().DurationVar(&o.waitIncrement, "wait-increment", 0, "lockout added by each failure past --max-failures, e.g. 60s")
+	cmd.Flags().DurationVar(&o.maxWait, "max-wait", 0, "longest temporary lockout, e.g. 15m")
+	cmd.Flags().DurationVar(&o.failureReset, "failure-reset", 0, "time after which the failure count is reset, e.g. 12h")
+	cmd.Flags().DurationVar(&o.quickLoginCheck, "quick-login-check", 0, "failures closer together than this count as quick logins, e.g. 1s")
+	cmd.Flags().DurationVar(&o.minQuickLoginWait, "min-quick-login-wait", 0, "lockout after a quick login failure, e.g. 60s")
+	addStdinFlag(cmd, "name")
+	return cmd
+}
+
+// wanted builds the settings given on the command line into a realm
+// representation, validating them.
+func (o *realmsBruteForceSetOptions) wanted(cmd *cobra.Command) (gocloak.RealmRepresentation, error) {
+	var want gocloak.RealmRepresentation
+	changed := cmd.Flags().Changed
+	if changed("enabled") {
+		want.BruteForceProtected = &o.enabled
+	}
+	if changed("permanent-lockout") {
+		want.PermanentLockout = &o.permanentLockout
+	}
+	if changed("max-failures") {
+		if o.maxFailures < 1 {
+			return want, fmt.Errorf("invalid --max-failures %d: must be at least 1", o.maxFailures)
+		}
+		want.FailureFactor = &o.maxFailures
+	}
+	for _, d := range []struct {
+		flag  string
+		value time.Duration
+		set   **int
+	}{
+		{"wait-increment", o.waitIncrement, &want.WaitIncrementSeconds},
+		{"max-wait", o.maxWait, &want.MaxFailureWaitSeconds},
+		{"failure-reset", o.failureReset, &want.MaxDeltaTimeSeconds},
+		{"min-quick-login-wait", o.minQuickLoginWait, &want.MinimumQuickLoginWaitSeconds},
+	} {
+		if !changed(d.flag) {
+			continue
+		}
+		if d.value < 0 || d.value%time.Second != 0 {
+			return want, fmt.Errorf("invalid --%s %s: must be whole seconds, e.g. 60s", d.flag, d.value)
+		}
+		*d.set = gocloak.IntP(int(d.value / time.Second))
+	}
+	if changed("quick-login-check") {
+		if o.quickLoginCheck < 0 || o.quickLoginCheck%time.Millisecond != 0 {
+			return want, fmt.Errorf("invalid --quick-login-check %s: must be whole milliseconds, e.g. 1s", o.quickLoginCheck)
+		}
+		ms := o.quickLoginCheck.Milliseconds()
+		want.QuickLoginCheckMilliSeconds = &ms
+	}
+	return want, nil
+}
+
+func (o *realmsBruteForceSetOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to change")
+	}
+	want, err := o.wanted(cmd)
+	if err != nil {
+		return err
+	}
+	wanted := bruteForceFields(&want)
+	if !slices.ContainsFunc(wanted, func(f bruteForceField) bool { return f.value != "" }) {
+		return errors.New("nothing to set: provide --enabled, --permanent-lockout, --max-failures or a duration flag")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, o.names); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range o.names {
+		current, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+		}
+		var fields []audit.FieldChange
+		for i, f := range bruteForceFields(current) {
+			v := wanted[i].value
+			if v == "" {
+				continue
+			}
+			item := audit.ItemResult{Kind: "realmBruteForce", Realm: realm, Name: f.field}
+			if v == f.value {
+				rep.skip(item, "unchanged", fmt.Sprintf("%s of realm %q is already %s. Skipped.", f.field, realm, v))
+				continue
+			}
+			fields = append(fields, audit.FieldChange{Field: f.field, Old: f.value, New: v})
+			rep.add(kcops.Updated, item, fmt.Sprintf("Set %s of realm %q to %s.", f.field, realm, v))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		update := want
+		update.Realm = gocloak.StringP(realm)
+		if err := gc.UpdateRealm(ctx, token, update); err != nil {
+			return fmt.Errorf("failed updating the brute force detection of realm %s: %w", realm, err)
+		}
+		recordChange(cmd, realm, realm, "", fields...)
+	}
+	return rep.print(cmd, realmsLabel(cmd, o.names), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
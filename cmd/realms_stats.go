@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// realmsStatsOptions holds the flags of `kc realms stats`.
+type realmsStatsOptions struct {
+	realms    []string
+	allRealms bool
+	output    reportOutput
+}
+
+// realmStats are the sizes of one realm shown by `kc realms stats`.
+type realmStats struct {
+	users, clients, roles, groups, sessions, idps int
+}
+
+func newRealmsStatsCmd() *cobra.Command {
+	o := &realmsStatsOptions{}
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Count the users, clients, roles, groups, sessions and identity providers of realms",
+		Long: `Summarize the size of each target realm, one row per realm, for capacity
+reports:
+
+  users     users of the realm
+  clients   clients, built-in ones included
+  roles     realm roles, client roles left out
+  groups    groups, subgroups included
+  sessions  active user sessions; a session shared by several clients
+            counts once
+  idps      enabled identity providers
+
+Use --format json (or csv, markdown) and --out to feed other tools.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "realm(s) to summarize. If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "summarize all realms")
+	addRealmSelectionFlags(cmd)
+	o.output.add(cmd)
+	return cmd
+}
+
+func (o *realmsStatsOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	t := &reportTable{columns: []string{"realm", "users", "clients", "roles", "groups", "sessions", "idps"}}
+	var total realmStats
+	for _, realm := range realms {
+		s, err := countRealm(ctx, gc, token, realm)
+		if err != nil {
+			return err
+		}
+		t.rows = append(t.rows, []string{realm, strconv.Itoa(s.users), strconv.Itoa(s.clients), strconv.Itoa(s.roles),
+			strconv.Itoa(s.groups), strconv.Itoa(s.sessions), strconv.Itoa(s.idps)})
+		total.users += s.users
+		total.sessions += s.sessions
+	}
+	summary := fmt.Sprintf("Realms: %d, Users: %d, Sessions: %d", len(realms), total.users, total.sessions)
+	return o.output.print(cmd, t, realmsLabel(cmd, realms), summary)
+}
+
+// countRealm reads the sizes of realm.
+func countRealm(ctx context.Context, gc keycloak.API, token, realm string) (realmStats, error) {
+	var s realmStats
+	var err error
+	if s.users, err = keycloak.CountUsers(ctx, gc, token, realm); err != nil {
+		return s, fmt.Errorf("failed counting users in realm %s: %w", realm, err)
+	}
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return s, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	s.clients = len(clients)
+	roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+	if err != nil {
+		return s, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+	}
+	s.roles = len(roles)
+	groups, err := listGroups(ctx, gc, token, realm)
+	if err != nil {
+		return s, fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+	}
+	s.groups = len(groups)
+
+	// the stats count a session once per client it used; list the sessions
+	// of those clients to count each one once
+	stats, err := keycloak.GetClientSessionStats(ctx, gc, token, realm)
+	if err != nil {
+		return s, fmt.Errorf("failed reading session stats of realm %s: %w", realm, err)
+	}
+	seen := map[string]bool{}
+	for _, st := range stats {
+		if st.Active == 0 {
+			continue
+		}
+		sessions, err := fetchPaged(0, 0, sessionsPageSize, func(first, max int) ([]*gocloak.UserSessionRepresentation, error) {
+			return gc.GetClientUserSessions(ctx, token, realm, st.ID, gocloak.GetClientUserSessionsParams{First: &first, Max: &max})
+		})
+		if err != nil {
+			return s, fmt.Errorf("failed listing sessions of client %q in realm %s: %w", st.ClientID, realm, err)
+		}
+		for _, session := range sessions {
+			seen[gocloak.PString(session.ID)] = true
+		}
+	}
+	s.sessions = len(seen)
+
+	idps, err := keycloak.GetIdentityProviders(ctx, gc, token, realm)
+	if err != nil {
+		return s, fmt.Errorf("failed listing identity providers in realm %s: %w", realm, err)
+	}
+	for _, idp := range idps {
+		if idp.Enabled {
+			s.idps++
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// realmThemeTypes are the theme types a realm selects, in display order.
+var realmThemeTypes = []string{"login", "account", "admin", "email"}
+
+// realmThemeField is one theme setting of a realm: have points into the
+// current representation, set into the update.
+type realmThemeField struct {
+	kind, field string
+	have, set   **string
+}
+
+// realmThemeFields pairs the theme settings of current and update.
+func realmThemeFields(current, update *gocloak.RealmRepresentation) []realmThemeField {
+	return []realmThemeField{
+		{"login", "loginTheme", &current.LoginTheme, &update.LoginTheme},
+		{"account", "accountTheme", &current.AccountTheme, &update.AccountTheme},
+		{"admin", "adminTheme", &current.AdminTheme, &update.AdminTheme},
+		{"email", "emailTheme", &current.EmailTheme, &update.EmailTheme},
+	}
+}
+
+// availableThemes returns the names of the themes installed on the server
+// per type, or nil when the server info does not list them.
+func availableThemes(ctx context.Context, gc keycloak.API, token string) (map[string][]string, error) {
+	info, err := gc.GetServerInfo(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching server info: %w", err)
+	}
+	if info.Themes == nil {
+		return nil, nil
+	}
+	names := func(themes []gocloak.ThemeRepresentation) []string {
+		out := make([]string, 0, len(themes))
+		for _, t := range themes {
+			out = append(out, t.Name)
+		}
+		slices.Sort(out)
+		return out
+	}
+	return map[string][]string{
+		"login":   names(info.Themes.Login),
+		"account": names(info.Themes.Accounts),
+		"admin":   names(info.Themes.Admin),
+		"email":   names(info.Themes.Email),
+		"common":  names(info.Themes.Common),
+		"welcome": names(info.Themes.Welcome),
+	}, nil
+}
+
+func newRealmsThemesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "themes",
+		Short: "Show and set the login, account, admin and email themes of realms",
+	}
+	cmd.AddCommand(newRealmsThemesGetCmd())
+	cmd.AddCommand(newRealmsThemesSetCmd())
+	cmd.AddCommand(newRealmsThemesAvailableCmd())
+	return cmd
+}
+
+// realmsThemesGetOptions holds the flags of `kc realms themes get`.
+type realmsThemesGetOptions struct {
+	names []string
+}
+
+// realmThemes is the JSON output of `kc realms themes get`; an empty theme
+// is the server default.
+type realmThemes struct {
+	Realm   string `json:"realm"`
+	Login   string `json:"login"`
+	Account string `json:"account"`
+	Admin   string `json:"admin"`
+	Email   string `json:"email"`
+}
+
+func newRealmsThemesGetCmd() *cobra.Command {
+	o := &realmsThemesGetOptions{}
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Show the themes of realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to show. Repeatable; required.")
+	return cmd
+}
+
+func (o *realmsThemesGetOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to show")
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	out := make([]realmThemes, 0, len(o.names))
+	for _, realm := range o.names {
+		r, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+		}
+		out = append(out, realmThemes{
+			Realm:   realm,
+			Login:   gocloak.PString(r.LoginTheme),
+			Account: gocloak.PString(r.AccountTheme),
+			Admin:   gocloak.PString(r.AdminTheme),
+			Email:   gocloak.PString(r.EmailTheme),
+		})
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, out)
+	}
+	for _, t := range out {
+		lines := make([]string, 0, len(realmThemeTypes))
+		for i, v := range []string{t.Login, t.Account, t.Admin, t.Email} {
+			if v == "" {
+				v = "(server default)"
+			}
+			lines = append(lines, fmt.Sprintf("%-8s %s", realmThemeTypes[i], v))
+		}
+		printBox(cmd, lines, t.Realm)
+	}
+	return nil
+}
+
+// realmsThemesSetOptions holds the flags of `kc realms themes set`.
+type realmsThemesSetOptions struct {
+	names   []string
+	login   string
+	account string
+	admin   string
+	email   string
+}
+
+func newRealmsThemesSetCmd() *cobra.Command {
+	o := &realmsThemesSetOptions{}
+	cmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set the themes of realms",
+		Long: `Set the login, account, admin and email themes of one or more realms. Only
+the themes given change; an empty value ("") goes back to the server
+default. When the server lists its installed themes, names it does not
+know are rejected before any realm changes.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to change. Repeatable; required.")
+	cmd.Flags().StringVar(&o.login, "login", "", "login theme")
+	cmd.Flags().StringVar(&o.account, "account", "", "account console theme")
+	cmd.Flags().StringVar(&o.admin, "admin", "", "admin console theme")
+	cmd.Flags().StringVar(&o.email, "email", "", "email theme")
+	addStdinFlag(cmd, "name")
+	return cmd
+}
+
+// wanted returns the themes given on the command line by type.
+func (o *realmsThemesSetOptions) wanted(cmd *cobra.Command) map[string]string {
+	want := map[string]string{}
+	for kind, v := range map[string]string{"login": o.login, "account": o.account, "admin": o.admin, "email": o.email} {
+		if cmd.Flags().Changed(kind) {
+			want[kind] = v
+		}
+	}
+	return want
+}
+
+func (o *realmsThemesSetOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to change")
+	}
+	want := o.wanted(cmd)
+	if len(want) == 0 {
+		return errors.New("nothing to set: provide --login, --account, --admin or --email")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	installed, err := availableThemes(ctx, gc, token)
+	if err != nil {
+		return err
+	}
+	if installed != nil {
+		for _, kind := range realmThemeTypes {
+			v, ok := want[kind]
+			if ok && v != "" && !slices.Contains(installed[kind], v) {
+				return fmt.Errorf("invalid --%s %q: not an installed %s theme (available: %s)", kind, v, kind, strings.Join(installed[kind], ", "))
+			}
+		}
+	}
+	if err := preflight(ctx, cmd, gc, token, o.names); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range o.names {
+		current, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+		}
+		update := gocloak.RealmRepresentation{Realm: gocloak.StringP(realm)}
+		var fields []audit.FieldChange
+		for _, t := range realmThemeFields(current, &update) {
+			v, ok := want[t.kind]
+			if !ok {
+				continue
+			}
+			item := audit.ItemResult{Kind: "realmTheme", Realm: realm, Name: t.field}
+			old := gocloak.PString(*t.have)
+			if old == v {
+				rep.skip(item, "unchanged", fmt.Sprintf("%s of realm %q is already %s. Skipped.", t.field, realm, themeLabel(v)))
+				continue
+			}
+			*t.set = gocloak.StringP(v)
+			fields = append(fields, audit.FieldChange{Field: t.field, Old: old, New: v})
+			rep.add(kcops.Updated, item, fmt.Sprintf("Set %s of realm %q to %s.", t.field, realm, themeLabel(v)))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if err := gc.UpdateRealm(ctx, token, update); err != nil {
+			return fmt.Errorf("failed updating the themes of realm %s: %w", realm, err)
+		}
+		recordChange(cmd, realm, realm, "", fields...)
+	}
+	return rep.print(cmd, realmsLabel(cmd, o.names), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// themeLabel names a theme in messages, where empty means the default.
+func themeLabel(name string) string {
+	if name == "" {
+		return "the server default"
+	}
+	return name
+}
+
+func newRealmsThemesAvailableCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "available",
+		Short: "List the themes installed on the server",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := commandContext(cmd, 30*time.Second)
+			defer cancel()
+			gc, token, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			installed, err := availableThemes(ctx, gc, token)
+			if err != nil {
+				return err
+			}
+			if installed == nil {
+				return errors.New("the server info does not list the installed themes")
+			}
+			if outputFormat == "json" {
+				return printJSON(cmd, installed)
+			}
+			var lines []string
+			for _, kind := range append(slices.Clone(realmThemeTypes), "common", "welcome") {
+				if len(installed[kind]) > 0 {
+					lines = append(lines, fmt.Sprintf("%-8s %s", kind, strings.Join(installed[kind], ", ")))
+				}
+			}
+			printBox(cmd, lines, "installed themes")
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// realmsUpdateOptions holds the flags of `kc realms update`.
+type realmsUpdateOptions struct {
+	names []string
+	sets  []string
+}
+
+func newRealmsUpdateCmd() *cobra.Command {
+	o := &realmsUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Set fields of realm(s) by path, e.g. attributes.frontendUrl",
+		Long: `Set any field of the realm representation by its dotted path, so settings
+without a command of their own can be changed:
+
+  kc realms update --name myrealm --set attributes.frontendUrl=https://login.example.org
+  kc realms update --name myrealm --set registrationAllowed=true --set accessTokenLifespan=300
+
+A value is JSON when the field is not a string (true, 300, ["a","b"]) and
+the text itself otherwise. Under attributes the rest of the path is the
+attribute name, dots included. Fields the realm representation does not
+have are rejected; new settings of Keycloak usually go under attributes.
+Realms already holding the values are skipped, and kc undo restores the
+previous settings.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "realm(s) to change. Repeatable; required.")
+	addStdinFlag(cmd, "name")
+	addSetFlag(cmd, &o.sets, "realm")
+	return cmd
+}
+
+func (o *realmsUpdateOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: name the realm(s) to change")
+	}
+	if len(o.sets) == 0 {
+		return errors.New("nothing to update: provide at least one --set")
+	}
+	sets, err := kcops.ParseFieldSets(o.sets)
+	if err != nil {
+		return flagError(err)
+	}
+	for _, s := range sets {
+		if s.Path == "id" || s.Path == "realm" {
+			return fmt.Errorf("invalid --set %s: realms cannot be renamed", s.Path)
+		}
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, o.names); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range o.names {
+		current, err := gc.GetRealm(ctx, token, realm)
+		if err != nil {
+			return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+		}
+		item := audit.ItemResult{Kind: "realm", Realm: realm, Name: realm, ID: gocloak.PString(current.ID)}
+		update := *current
+		changes, err := kcops.ApplyFieldSets(&update, sets)
+		if err != nil {
+			return fmt.Errorf("realm %s: %w", realm, flagError(err))
+		}
+		if len(changes) == 0 {
+			rep.skip(item, "unchanged", fmt.Sprintf("Realm %q already has these values. Skipped.", realm))
+			continue
+		}
+		if err := gc.UpdateRealm(ctx, token, update); err != nil {
+			return fmt.Errorf("failed updating realm %s: %w", realm, err)
+		}
+		fields := make([]audit.FieldChange, 0, len(changes))
+		for _, c := range changes {
+			fields = append(fields, audit.FieldChange{Field: c.Field, Old: c.Old, New: c.New})
+		}
+		recordChangeWithBefore(cmd, realm, realm, item.ID, *current, fields...)
+		rep.add(kcops.Updated, item, fmt.Sprintf("Updated realm %q: %s.", realm, describeFieldChanges(fields)))
+	}
+	return rep.print(cmd, realmsLabel(cmd, o.names), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// describeFieldChanges lists changed fields for the text output, e.g.
+// "attributes.frontendUrl → https://login.example.org"; secrets are masked.
+func describeFieldChanges(fields []audit.FieldChange) string {
+	var parts []string
+	for _, f := range fields {
+		v := f.New
+		if isSensitivePath(f.Field) {
+			v = redactedValue
+		}
+		parts = append(parts, f.Field+" → "+v)
+	}
+	return strings.Join(parts, ", ")
+}
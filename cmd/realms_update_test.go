@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestRealmsUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		want       wantResult
+		wantFields map[string]string
+	}{
+		{
+			name:       "sets fields by path",
+			args:       []string{"--name", "test", "--set", "attributes.frontendUrl=https://login.example.org", "--set", "registrationAllowed=true"},
+			want:       wantResult{updated: []string{"test/test"}},
+			wantFields: map[string]string{"attributes.frontendUrl": "https://login.example.org", "registrationAllowed": "true"},
+		},
+		{
+			name: "skips unchanged realms",
+			args: []string{"--name", "test", "--set", "displayName=Test"},
+			want: wantResult{skipped: []string{"test/test"}},
+		},
+		{
+			name:    "rejects an unknown field",
+			args:    []string{"--name", "test", "--set", "registrationAllowedd=true"},
+			wantErr: "realm test: invalid --set registrationAllowedd: no such field",
+		},
+		{
+			name:    "rejects a rename",
+			args:    []string{"--name", "test", "--set", "realm=other"},
+			wantErr: "realms cannot be renamed",
+		},
+		{
+			name:    "fails on a missing realm",
+			args:    []string{"--name", "nowhere", "--set", "displayName=X"},
+			wantErr: "failed fetching realm nowhere",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := newTestAPI(t)
+			if err := api.UpdateRealm(ctx, "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test"), DisplayName: gocloak.StringP("Test")}); err != nil {
+				t.Fatal(err)
+			}
+			_, entry, err := run(t, api, newRealmsCmd, append([]string{"realms", "update"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				checkEntry(t, entry, "error", wantResult{})
+				return
+			}
+			checkEntry(t, entry, "ok", tt.want)
+			if len(tt.wantFields) == 0 {
+				if len(entry.Details.Changes) > 0 {
+					t.Errorf("changes = %+v, want none", entry.Details.Changes)
+				}
+				return
+			}
+			c := changeOf(t, entry, "test", "test")
+			if c.Kind != "realms_update" || len(c.Before) == 0 {
+				t.Errorf("change = %+v, want realms_update with the realm before", c)
+			}
+			for field, want := range tt.wantFields {
+				if f, _ := fieldOf(c, field); f.New != want {
+					t.Errorf("%s = %+v, want %q", field, f, want)
+				}
+			}
+			r, err := api.GetRealm(ctx, "", "test")
+			if err != nil || !gocloak.PBool(r.RegistrationAllowed) || (*r.Attributes)["frontendUrl"] != "https://login.example.org" {
+				t.Errorf("realm not updated: %+v (%v)", r, err)
+			}
+		})
+	}
+}
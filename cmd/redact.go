@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// redactedValue replaces secrets wherever kc persists what a command did.
+const redactedValue = "***"
+
+// sensitiveFlags are the flags whose values are secrets. Their values are
+// masked in the command line kept by the log, the audit and notifications.
+var sensitiveFlags = map[string]bool{
+	"password":      true,
+	"password-hash": true,
+	"secret-data":   true,
+	"secret":        true,
+	"client-secret": true,
+	"token":         true,
+}
+
+// sensitiveFields are the audit field names whose values are secrets.
+var sensitiveFields = map[string]bool{
+	"password":     true,
+	"secret":       true,
+	"clientSecret": true,
+}
+
+// isSensitiveConfigKey reports whether a key of config.json holds a secret:
+// the credentials, a serve token or the notification webhook, whose URL is
+// its own credential.
+func isSensitiveConfigKey(key string) bool {
+	switch key {
+	case "client_secret", "password", "serve_tokens", "notify_webhook":
+		return true
+	}
+	return strings.HasPrefix(key, "serve_tokens.")
+}
+
+// redactConfig masks the secrets of a config.json document in place.
+func redactConfig(doc map[string]any) {
+	for key, v := range doc {
+		if !isSensitiveConfigKey(key) {
+			continue
+		}
+		if tokens, ok := v.(map[string]any); ok {
+			for name := range tokens {
+				tokens[name] = redactedValue
+			}
+		} else if v != "" {
+			doc[key] = redactedValue
+		}
+	}
+}
+
+// addSecret registers values to mask in everything the run of ctx writes to
+// the log: the values of its sensitive flags, of config.json and those it
+// generated or received, e.g. a generated password.
+func addSecret(ctx context.Context, values ...string) {
+	st := stateOf(ctx)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, v := range values {
+		// shorter values would mask ordinary words
+		if len(v) >= 4 && !slices.Contains(st.secrets, v) {
+			st.secrets = append(st.secrets, v)
+		}
+	}
+	// longest first, so a secret containing another is masked whole
+	slices.SortFunc(st.secrets, func(a, b string) int { return len(b) - len(a) })
+}
+
+// addFlagSecrets registers the values of the sensitive flags cmd was given,
+// after @file and --stdin expansion, and the credentials of config.json.
+func addFlagSecrets(cmd *cobra.Command) {
+	ctx := cmd.Context()
+	addSecret(ctx, config.Global.ClientSecret, config.Global.Password)
+	for _, t := range config.Global.ServeTokens {
+		addSecret(ctx, t)
+	}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name == "set" {
+			for _, a := range f.Value.(pflag.SliceValue).GetSlice() {
+				if path, value, ok := strings.Cut(a, "="); ok && isSensitivePath(path) {
+					addSecret(ctx, value)
+				}
+			}
+			return
+		}
+		if !sensitiveFlags[f.Name] {
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			addSecret(ctx, sv.GetSlice()...)
+		} else if f.Value.String() != "-" {
+			addSecret(ctx, f.Value.String())
+		}
+	})
+}
+
+// redactSecrets masks the secrets registered in st in s.
+func redactSecrets(st *runState, s string) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, v := range st.secrets {
+		s = strings.ReplaceAll(s, v, redactedValue)
+	}
+	return s
+}
+
+// redactArgs masks the values of sensitive flags in a command line, e.g.
+// --password Str0ng! becomes --password ***, of secret keys set with
+// `kc config set`, e.g. client_secret=***, and of secret fields set with
+// --set, e.g. --set smtpServer.password=***. "-" (stdin) and @file references
+// are kept, as they are no secrets themselves. Arguments after -- are masked
+// too: kc broadcast passes them on as a command line.
+func redactArgs(args []string) []string {
+	out := slices.Clone(args)
+	maskNext, setNext := false, false
+	for i, a := range args {
+		if maskNext {
+			maskNext = false
+			if !isSecretReference(a) {
+				out[i] = redactedValue
+			}
+			continue
+		}
+		if setNext {
+			setNext = false
+			if path, value, ok := strings.Cut(a, "="); ok && isSensitivePath(path) && value != "" {
+				out[i] = path + "=" + redactedValue
+			}
+			continue
+		}
+		if a == "--set" {
+			setNext = true
+			continue
+		}
+		if set, ok := strings.CutPrefix(a, "--set="); ok {
+			if path, value, ok := strings.Cut(set, "="); ok && isSensitivePath(path) && value != "" {
+				out[i] = "--set=" + path + "=" + redactedValue
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(a, "="); ok && !strings.HasPrefix(a, "-") {
+			if isSensitiveConfigKey(key) && value != "" && !isSecretReference(value) {
+				out[i] = key + "=" + redactedValue
+			}
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(a, "--"), "=")
+		if !strings.HasPrefix(a, "--") || !sensitiveFlags[name] {
+			continue
+		}
+		if !hasValue {
+			maskNext = true
+		} else if !isSecretReference(value) {
+			out[i] = "--" + name + "=" + redactedValue
+		}
+	}
+	return out
+}
+
+// isSecretReference reports whether a flag value points at its secret rather
+// than holding it: "-" reads stdin and @path a file (but @@ escapes a literal @).
+func isSecretReference(v string) bool {
+	return v == "-" || (strings.HasPrefix(v, "@") && !strings.HasPrefix(v, "@@") && len(v) > 1)
+}
+
+// redactChanges returns changes with the values of sensitive fields replaced
+// by "(set)", for the audit log.
+func redactChanges(changes []audit.Change) []audit.Change {
+	out := make([]audit.Change, len(changes))
+	for i, c := range changes {
+		out[i] = c
+		if !slices.ContainsFunc(c.Fields, func(f audit.FieldChange) bool { return isSensitivePath(f.Field) }) {
+			continue
+		}
+		out[i].Fields = slices.Clone(c.Fields)
+		for j, f := range out[i].Fields {
+			if !isSensitivePath(f.Field) {
+				continue
+			}
+			// the fact of a change is kept
+			if f.Old != "" {
+				out[i].Fields[j].Old = "(set)"
+			}
+			if f.New != "" && f.New != "(generated)" {
+				out[i].Fields[j].New = "(set)"
+			}
+		}
+	}
+	return out
+}
+
+// maxLogLine bounds what redactingWriter holds back waiting for a newline.
+const maxLogLine = 64 << 10
+
+// redactingWriter masks the secrets registered in st in everything written
+// to w, the log file. It writes whole lines, so that a secret split across
+// two writes is masked too; Close writes the rest and closes w.
+type redactingWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	st  *runState
+	buf []byte
+}
+
+func newRedactingWriter(w io.Writer, st *runState) *redactingWriter {
+	return &redactingWriter{w: w, st: st}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf = append(r.buf, p...)
+	n := bytes.LastIndexByte(r.buf, '\n') + 1
+	if len(r.buf) > maxLogLine {
+		n = len(r.buf)
+	}
+	if n == 0 {
+		return len(p), nil
+	}
+	_, err := io.WriteString(r.w, redactSecrets(r.st, string(r.buf[:n])))
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes what is left of the last line and closes w.
+func (r *redactingWriter) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	if len(r.buf) > 0 {
+		_, err = io.WriteString(r.w, redactSecrets(r.st, string(r.buf)))
+		r.buf = nil
+	}
+	if c, ok := r.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
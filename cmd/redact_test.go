@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"flag and value", []string{"users", "create", "--password", "Str0ng!"}, []string{"users", "create", "--password", "***"}},
+		{"flag=value", []string{"--client-secret=abc"}, []string{"--client-secret=***"}},
+		{"stdin and file references", []string{"--password", "-", "--secret=@secret.txt"}, []string{"--password", "-", "--secret=@secret.txt"}},
+		{"escaped @", []string{"--password", "@@home"}, []string{"--password", "***"}},
+		{"--set of a secret field", []string{"--set", "smtpServer.password=x", "--set=secret=y", "--set", "displayName=Acme"}, []string{"--set", "smtpServer.password=***", "--set=secret=***", "--set", "displayName=Acme"}},
+		{"config set", []string{"config", "set", "client_secret=abc", "realm=acme"}, []string{"config", "set", "client_secret=***", "realm=acme"}},
+		{"other flags", []string{"--username", "alice", "--realm", "acme"}, []string{"--username", "alice", "--realm", "acme"}},
+		{"after --", []string{"broadcast", "--", "users", "create", "--password", "p"}, []string{"broadcast", "--", "users", "create", "--password", "***"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactArgs(tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("redactArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactChanges(t *testing.T) {
+	changes := []audit.Change{{
+		Kind: "users_update", Realm: "test", Entity: "alice",
+		Fields: []audit.FieldChange{
+			{Field: "password", Old: "old-pass", New: "new-pass"},
+			{Field: "email", Old: "a@example.org", New: "alice@example.org"},
+		},
+	}, {
+		Kind: "clients_rotate_secrets", Realm: "test", Entity: "api",
+		Fields: []audit.FieldChange{{Field: "secret", New: "(generated)"}},
+	}}
+	got := redactChanges(changes)
+	if f := got[0].Fields[0]; f.Old != "(set)" || f.New != "(set)" {
+		t.Errorf("password change = %+v, want both sides (set)", f)
+	}
+	if f := got[0].Fields[1]; f.New != "alice@example.org" {
+		t.Errorf("email change = %+v, want it kept", f)
+	}
+	if f := got[1].Fields[0]; f.New != "(generated)" {
+		t.Errorf("generated secret = %+v, want (generated) kept", f)
+	}
+	if changes[0].Fields[0].New != "new-pass" {
+		t.Error("redactChanges modified its input")
+	}
+}
+
+func TestAddFlagSecrets(t *testing.T) {
+	config.Global = config.Config{ClientSecret: "config-secret"}
+	t.Cleanup(func() { config.Global = config.Config{} })
+	cmd := &cobra.Command{Use: "create", Run: func(*cobra.Command, []string) {}}
+	cmd.Flags().StringSlice("password", nil, "")
+	cmd.Flags().String("secret", "", "")
+	cmd.Flags().StringSlice("set", nil, "")
+	cmd.Flags().String("username", "", "")
+	cmd.SetArgs([]string{"--password", "pass-one,pass-two", "--secret", "-", "--set", "smtpServer.password=smtp-pass", "--username", "alice-user"})
+	st := newRunState()
+	cmd.SetContext(withRunState(context.Background(), st))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	addFlagSecrets(cmd)
+
+	var log bytes.Buffer
+	w := newRedactingWriter(&log, st)
+	w.Write([]byte("pass-one pass-two smtp-pass config-secret alice-user - abc\n"))
+	if got, want := log.String(), "*** *** *** *** alice-user - abc\n"; got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestRedactingWriterSplitWrites(t *testing.T) {
+	st := newRunState()
+	addSecret(withRunState(context.Background(), st), "Str0ng-Pa55")
+	var log bytes.Buffer
+	w := newRedactingWriter(&log, st)
+	for _, p := range []string{"Password: Str0", "ng-Pa", "55\nnext ", "line Str0ng", "-Pa55"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if got, want := log.String(), "Password: ***\n"; got != want {
+		t.Errorf("log before Close = %q, want only the whole line %q", got, want)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := log.String(), "Password: ***\nnext line ***"; got != want {
+		t.Errorf("log = %q, want %q", got, want)
+	}
+}
+
+func TestRedactSecretsLongestFirst(t *testing.T) {
+	ctx := withRunState(context.Background(), newRunState())
+	addSecret(ctx, "abcd", "abcdefgh", "ab")
+	if got := redactSecrets(stateOf(ctx), "x abcdefgh y abcd z ab"); got != "x *** y *** z ab" {
+		t.Errorf("redactSecrets = %q, want the longer secret masked whole and short values kept", got)
+	}
+}
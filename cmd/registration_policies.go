@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newRegistrationPoliciesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registration-policies",
+		Short: "Manage the client registration policies of a realm",
+		Long: `Manage the client registration policies of a realm. Anonymous policies
+apply to registrations without a token, authenticated policies to
+registrations with an initial access token or a bearer token.`,
+	}
+	cmd.AddCommand(newRegistrationPoliciesListCmd())
+	cmd.AddCommand(newRegistrationPoliciesCreateCmd())
+	cmd.AddCommand(newRegistrationPoliciesUpdateCmd())
+	cmd.AddCommand(newRegistrationPoliciesDeleteCmd())
+	return cmd
+}
+
+// addPolicyAccessFlag registers --access, the kind of registration a policy
+// applies to.
+func addPolicyAccessFlag(cmd *cobra.Command, p *string, def string) {
+	cmd.Flags().StringVar(p, "access", def, "registration kind the policy applies to: anonymous|authenticated")
+}
+
+func validatePolicyAccess(access string, allowEmpty bool) error {
+	switch access {
+	case keycloak.RegistrationAnonymous, keycloak.RegistrationAuthenticated:
+		return nil
+	case "":
+		if allowEmpty {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --access %q: use anonymous or authenticated", access)
+}
+
+// parsePolicyConfig turns repeated key=value flags into a component config.
+// A key given several times gets several values.
+func parsePolicyConfig(pairs []string) (map[string][]string, error) {
+	cfg := map[string][]string{}
+	for _, kv := range pairs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --config %q: expected key=value", kv)
+		}
+		cfg[k] = append(cfg[k], v)
+	}
+	return cfg, nil
+}
+
+// getRegistrationPolicies returns the registration policies of realm, only
+// those of access unless it is empty.
+func getRegistrationPolicies(ctx context.Context, gc keycloak.API, token, realm, access string) ([]*gocloak.Component, error) {
+	comps, err := gc.GetComponentsWithParams(ctx, token, realm, gocloak.GetComponentsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing registration policies in realm %s: %w", realm, err)
+	}
+	// the type filter of the components endpoint is not exposed by gocloak
+	var out []*gocloak.Component
+	for _, c := range comps {
+		if gocloak.PString(c.ProviderType) == keycloak.RegistrationPolicyType && (access == "" || gocloak.PString(c.SubType) == access) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// findRegistrationPolicy returns the policy of access called name, or nil.
+func findRegistrationPolicy(ctx context.Context, gc keycloak.API, token, realm, access, name string) (*gocloak.Component, error) {
+	policies, err := getRegistrationPolicies(ctx, gc, token, realm, access)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range policies {
+		if gocloak.PString(p.Name) == name {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func policyItem(realm, access, name, id string) audit.ItemResult {
+	return audit.ItemResult{Kind: "registrationPolicy", Realm: realm, Name: access + "/" + name, ID: id}
+}
+
+// formatPolicyConfig renders a component config as sorted key=value pairs.
+func formatPolicyConfig(cfg *map[string][]string) string {
+	if cfg == nil {
+		return ""
+	}
+	var parts []string
+	for _, k := range slices.Sorted(maps.Keys(*cfg)) {
+		parts = append(parts, k+"="+strings.Join((*cfg)[k], ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// registrationPoliciesListOptions holds the flags of `kc realms registration-policies list`.
+type registrationPoliciesListOptions struct {
+	realm     string
+	access    string
+	normalize bool
+}
+
+func newRegistrationPoliciesListCmd() *cobra.Command {
+	o := &registrationPoliciesListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List client registration policies",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.access, "access", "", "only policies for anonymous or authenticated registration")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addNormalizeFlag(cmd, &o.normalize)
+	return cmd
+}
+
+func (o *registrationPoliciesListOptions) run(cmd *cobra.Command) error {
+	if err := validatePolicyAccess(o.access, true); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	policies, err := getRegistrationPolicies(ctx, gc, token, realm, o.access)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(policies, func(a, b *gocloak.Component) int {
+		if c := strings.Compare(gocloak.PString(a.SubType), gocloak.PString(b.SubType)); c != 0 || !o.normalize {
+			return c
+		}
+		return strings.Compare(gocloak.PString(a.Name), gocloak.PString(b.Name))
+	})
+	if outputFormat == "json" {
+		return printListJSON(cmd, policies, o.normalize)
+	}
+	lines := make([]string, 0, len(policies)+1)
+	for _, p := range policies {
+		lines = append(lines, fmt.Sprintf("%-13s  %s  (%s)  %s", gocloak.PString(p.SubType), gocloak.PString(p.Name), gocloak.PString(p.ProviderID), formatPolicyConfig(p.ComponentConfig)))
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(policies)))
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// registrationPoliciesCreateOptions holds the flags of `kc realms registration-policies create`.
+type registrationPoliciesCreateOptions struct {
+	realm    string
+	access   string
+	name     string
+	provider string
+	config   []string
+}
+
+func newRegistrationPoliciesCreateCmd() *cobra.Command {
+	o := &registrationPoliciesCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a client registration policy",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVar(&o.name, "name", "", "policy name (required)")
+	cmd.Flags().StringVar(&o.provider, "provider", "", "policy provider, e.g. trusted-hosts, max-clients, allowed-client-templates, consent-required (required)")
+	cmd.Flags().StringSliceVar(&o.config, "config", nil, "provider setting as key=value, e.g. trusted-hosts=10.0.0.0/8. Repeatable; repeat a key for several values")
+	addPolicyAccessFlag(cmd, &o.access, keycloak.RegistrationAnonymous)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *registrationPoliciesCreateOptions) run(cmd *cobra.Command) error {
+	if o.name == "" {
+		return errors.New("missing --name: policy name is required")
+	}
+	if o.provider == "" {
+		return errors.New("missing --provider: policy provider is required")
+	}
+	if err := validatePolicyAccess(o.access, false); err != nil {
+		return err
+	}
+	cfg, err := parsePolicyConfig(o.config)
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	existing, err := findRegistrationPolicy(ctx, gc, token, realm, o.access, o.name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		rep.skip(policyItem(realm, o.access, o.name, gocloak.PString(existing.ID)), "already exists", fmt.Sprintf("Registration policy %q (%s) already exists in realm %q. Skipped.", o.name, o.access, realm))
+		return rep.print(cmd, realm, "Done. Created: 0, Skipped: 1.")
+	}
+	r, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed reading realm %s: %w", realm, err)
+	}
+	id, err := gc.CreateComponent(ctx, token, realm, gocloak.Component{
+		Name:            &o.name,
+		ProviderID:      &o.provider,
+		ProviderType:    gocloak.StringP(keycloak.RegistrationPolicyType),
+		ParentID:        r.ID,
+		SubType:         &o.access,
+		ComponentConfig: &cfg,
+	})
+	if err != nil {
+		return fmt.Errorf("failed creating registration policy %q in realm %s: %w", o.name, realm, err)
+	}
+	settings := formatPolicyConfig(&cfg)
+	fields := appendFieldChange(nil, "provider", nil, &o.provider)
+	fields = appendFieldChange(fields, "config", nil, &settings)
+	recordChange(cmd, realm, o.access+"/"+o.name, id, fields...)
+	rep.add(kcops.Created, policyItem(realm, o.access, o.name, id), fmt.Sprintf("Created %s registration policy %q (%s) in realm %q.", o.access, o.name, o.provider, realm))
+	return rep.print(cmd, realm, "Done. Created: 1, Skipped: 0.")
+}
+
+// registrationPoliciesUpdateOptions holds the flags of `kc realms registration-policies update`.
+type registrationPoliciesUpdateOptions struct {
+	realm  string
+	access string
+	name   string
+	config []string
+}
+
+func newRegistrationPoliciesUpdateCmd() *cobra.Command {
+	o := &registrationPoliciesUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Change settings of a client registration policy",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVar(&o.name, "name", "", "policy name (required)")
+	cmd.Flags().StringSliceVar(&o.config, "config", nil, "provider setting as key=value; replaces that key, other keys are kept. Repeatable; required")
+	addPolicyAccessFlag(cmd, &o.access, keycloak.RegistrationAnonymous)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *registrationPoliciesUpdateOptions) run(cmd *cobra.Command) error {
+	if o.name == "" {
+		return errors.New("missing --name: policy name is required")
+	}
+	if len(o.config) == 0 {
+		return errors.New("missing --config: provide at least one --config key=value")
+	}
+	if err := validatePolicyAccess(o.access, false); err != nil {
+		return err
+	}
+	changes, err := parsePolicyConfig(o.config)
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	policy, err := findRegistrationPolicy(ctx, gc, token, realm, o.access, o.name)
+	if err != nil {
+		return err
+	}
+	if policy == nil {
+		return fmt.Errorf("%s registration policy %q not found in realm %s", o.access, o.name, realm)
+	}
+
+	cfg := map[string][]string{}
+	if policy.ComponentConfig != nil {
+		maps.Copy(cfg, *policy.ComponentConfig)
+	}
+	var fields []audit.FieldChange
+	for _, k := range slices.Sorted(maps.Keys(changes)) {
+		if slices.Equal(cfg[k], changes[k]) {
+			continue
+		}
+		old, val := strings.Join(cfg[k], ","), strings.Join(changes[k], ",")
+		fields = appendFieldChange(fields, "config."+k, &old, &val)
+		cfg[k] = changes[k]
+	}
+
+	rep := newReport(cmd)
+	item := policyItem(realm, o.access, o.name, gocloak.PString(policy.ID))
+	if len(fields) == 0 {
+		rep.skip(item, "no changes", fmt.Sprintf("Registration policy %q (%s) in realm %q already up to date. Skipped.", o.name, o.access, realm))
+		return rep.print(cmd, realm, "Done. Updated: 0, Skipped: 1.")
+	}
+	before := *policy
+	policy.ComponentConfig = &cfg
+	if err := gc.UpdateComponent(ctx, token, realm, *policy); err != nil {
+		return fmt.Errorf("failed updating registration policy %q in realm %s: %w", o.name, realm, err)
+	}
+	recordChangeWithBefore(cmd, realm, o.access+"/"+o.name, item.ID, before, fields...)
+	rep.add(kcops.Updated, item, fmt.Sprintf("Updated %s registration policy %q in realm %q.", o.access, o.name, realm))
+	return rep.print(cmd, realm, "Done. Updated: 1, Skipped: 0.")
+}
+
+// registrationPoliciesDeleteOptions holds the flags of `kc realms registration-policies delete`.
+type registrationPoliciesDeleteOptions struct {
+	realm  string
+	access string
+	names  []string
+}
+
+func newRegistrationPoliciesDeleteCmd() *cobra.Command {
+	o := &registrationPoliciesDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete client registration policies",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "policy name(s). Repeatable; required.")
+	addPolicyAccessFlag(cmd, &o.access, keycloak.RegistrationAnonymous)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *registrationPoliciesDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 {
+		return errors.New("missing --name: provide at least one --name")
+	}
+	if err := validatePolicyAccess(o.access, false); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, name := range o.names {
+		policy, err := findRegistrationPolicy(ctx, gc, token, realm, o.access, name)
+		if err != nil {
+			return err
+		}
+		if policy == nil {
+			rep.skip(policyItem(realm, o.access, name, ""), "not found", fmt.Sprintf("Registration policy %q (%s) not found in realm %q. Skipped.", name, o.access, realm))
+			continue
+		}
+		id := gocloak.PString(policy.ID)
+		if err := gc.DeleteComponent(ctx, token, realm, id); err != nil {
+			return fmt.Errorf("failed deleting registration policy %q in realm %s: %w", name, realm, err)
+		}
+		recordChangeWithBefore(cmd, realm, o.access+"/"+name, id, policy)
+		rep.add(kcops.Deleted, policyItem(realm, o.access, name, id), fmt.Sprintf("Deleted %s registration policy %q in realm %q.", o.access, name, realm))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"kc/internal/audit"
+	"kc/internal/manifest"
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// report collects the outcome of a command item by item. Text output renders
+// its messages in a box; --output json prints its Result instead.
+type report struct {
+	result audit.Result
+	lines  []string
+	// total is the number of items the command set out to handle, when it
+	// told with expect; 0 when unknown.
+	total int
+}
+
+// newReport starts the report of the running cmd, kept in its run state.
+// appendAudit stores its Result in the audit entry, also when the command
+// fails midway.
+func newReport(cmd *cobra.Command) *report {
+	st := cmdState(cmd)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.report = &report{}
+	return st.report
+}
+
+// add records item under outcome, with msg as its line in the text output.
+func (r *report) add(outcome kcops.Outcome, item audit.ItemResult, msg string) {
+	switch outcome {
+	case kcops.Created:
+		r.result.Created = append(r.result.Created, item)
+	case kcops.Updated:
+		r.result.Updated = append(r.result.Updated, item)
+	case kcops.Deleted:
+		r.result.Deleted = append(r.result.Deleted, item)
+	case kcops.Skipped:
+		r.result.Skipped = append(r.result.Skipped, item)
+	}
+	r.track(outcome, item)
+	r.note(msg)
+}
+
+// track appends item to the items of the result in the order they were
+// handled, with outcome as its status.
+func (r *report) track(outcome kcops.Outcome, item audit.ItemResult) {
+	item.Status = string(outcome)
+	r.result.Items = append(r.result.Items, item)
+}
+
+// skip records item as skipped for reason.
+func (r *report) skip(item audit.ItemResult, reason, msg string) {
+	item.Reason = reason
+	r.add(kcops.Skipped, item, msg)
+}
+
+// fail records item as failed with msg, the error of the item. Failures are
+// listed after the summary.
+func (r *report) fail(item audit.ItemResult, msg string) {
+	item.Error = msg
+	r.result.Errors = append(r.result.Errors, item)
+	r.track(kcops.Failed, item)
+}
+
+// failOrStop records err as the failure of item and returns nil when
+// keepGoing (--continue-on-error) is set; otherwise it returns err, which
+// stops the command.
+func (r *report) failOrStop(keepGoing bool, item audit.ItemResult, err error) error {
+	if !keepGoing {
+		return err
+	}
+	r.fail(item, err.Error())
+	return nil
+}
+
+// expect adds n to the items the command is about to handle, so a timeout
+// can tell how much of the batch was done.
+func (r *report) expect(n int) {
+	r.total += n
+}
+
+// done returns the number of items handled so far, failed ones included.
+func (r *report) done() int {
+	res := r.result
+	return len(res.Created) + len(res.Updated) + len(res.Deleted) + len(res.Skipped) + len(res.Errors)
+}
+
+// printTimeout shows what the running command got done before it ran out of
+// time, so the operator knows what is left to re-run.
+func printTimeout(cmd *cobra.Command) {
+	r := cmdState(cmd).report
+	if r == nil {
+		fmt.Fprintln(cmd.ErrOrStderr(), "The command timed out; raise --timeout to give it more time.")
+		return
+	}
+	progress := fmt.Sprintf("Timed out after %d item(s).", r.done())
+	if r.total > 0 {
+		progress = fmt.Sprintf("Timed out after %d of %d item(s) (%d%%).", r.done(), r.total, r.done()*100/r.total)
+	}
+	res := r.result
+	lines := append(slices.Clone(r.lines), progress,
+		fmt.Sprintf("Done before the timeout: Created: %d, Updated: %d, Deleted: %d, Skipped: %d, Failed: %d.", len(res.Created), len(res.Updated), len(res.Deleted), len(res.Skipped), len(res.Errors)),
+		"Re-run the command for the items not listed above; raise --timeout to give it more time.")
+	lines = append(lines, r.itemLines()...)
+	if outputFormat == "json" {
+		printJSON(cmd, res)
+		fmt.Fprintln(cmd.ErrOrStderr(), progress)
+		return
+	}
+	var realms []string
+	for _, items := range [][]audit.ItemResult{res.Created, res.Updated, res.Deleted, res.Skipped, res.Errors} {
+		for _, it := range items {
+			if !slices.Contains(realms, it.Realm) {
+				realms = append(realms, it.Realm)
+			}
+		}
+	}
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+}
+
+// isTimeout reports whether err comes from the deadline of the command
+// context. gocloak flattens errors to text, hence the second check.
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), context.DeadlineExceeded.Error())
+}
+
+// note adds a line to the text output only, e.g. a generated password.
+func (r *report) note(msg string) {
+	r.lines = append(r.lines, msg)
+}
+
+// print writes the report followed by summary and the status of each item,
+// or the Result with --output json. When items failed it returns an error, so
+// the command exits non-zero.
+func (r *report) print(cmd *cobra.Command, realmLabel, summary string) error {
+	failed := len(r.result.Errors)
+	if outputFormat == "json" {
+		if err := printJSON(cmd, r.result); err != nil {
+			return err
+		}
+	} else {
+		lines := append(r.lines, summary)
+		if failed > 0 {
+			lines = append(lines, fmt.Sprintf("Failed: %d.", failed))
+		}
+		printBox(cmd, append(lines, r.itemLines()...), realmLabel)
+	}
+	if failed > 0 {
+		// the failures are listed above; usage would only bury them
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d item(s) failed", failed)
+	}
+	return nil
+}
+
+// itemLines lists the items handled so far under an "Items:" heading, one
+// line each with its status, or nothing when there are none.
+func (r *report) itemLines() []string {
+	if len(r.result.Items) == 0 {
+		return nil
+	}
+	lines := []string{"Items:"}
+	for _, it := range r.result.Items {
+		lines = append(lines, "  "+formatItemStatus(it))
+	}
+	return lines
+}
+
+// formatItemStatus renders an item of a Result as one line led by its
+// status, e.g. "skipped [demo] user jdoe: already exists"; failed items end
+// with their error.
+func formatItemStatus(it audit.ItemResult) string {
+	line := fmt.Sprintf("%-7s [%s] %s %s", it.Status, it.Realm, it.Kind, it.Name)
+	switch {
+	case it.Error != "":
+		line += ": " + it.Error
+	case it.Reason != "":
+		line += ": " + it.Reason
+	}
+	return line
+}
+
+// opsItem describes the item of a kcops result.
+func opsItem(kind string, res kcops.Result) audit.ItemResult {
+	return audit.ItemResult{Kind: kind, Realm: res.Realm, Name: res.Name, ID: res.ID}
+}
+
+// flagError rewrites a kcops error that names request fields, e.g. an
+// invalid FieldSet, in terms of the flags setting those fields.
+func flagError(err error) error {
+	var fs *kcops.FieldSetError
+	switch {
+	case errors.As(err, &fs):
+		return errors.New(strings.Replace(err.Error(), fs.Error(), fmt.Sprintf("invalid --set %s: %s", fs.Set, fs.Reason), 1))
+	case errors.Is(err, kcops.ErrClientIDRequired):
+		return errors.New("missing --client-id when using --client-role")
+	case errors.Is(err, kcops.ErrPasswordNotGenerated):
+		return fmt.Errorf("%w; give one with --password", err)
+	}
+	return err
+}
+
+// addAction records an applied manifest action.
+func (r *report) addAction(a manifest.Action) {
+	outcome := map[manifest.Op]kcops.Outcome{
+		manifest.OpCreate: kcops.Created,
+		manifest.OpUpdate: kcops.Updated,
+		manifest.OpDelete: kcops.Deleted,
+	}[a.Op]
+	r.add(outcome, audit.ItemResult{Kind: string(a.Kind), Realm: a.Realm, Name: a.Name}, a.String())
+}
+
+// reportResult returns the Result of the running cmd for its audit entry,
+// or nil when it reported nothing.
+func reportResult(cmd *cobra.Command) *audit.Result {
+	st := cmdState(cmd)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.result()
+}
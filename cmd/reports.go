@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Read-only reports on the configuration of a realm",
+	}
+	cmd.AddCommand(newReportScopesCmd())
+	cmd.AddCommand(newReportRolesCmd())
+	cmd.AddCommand(newReportStaleCmd())
+	cmd.AddCommand(newReportLoginsCmd())
+	cmd.AddCommand(newReportClientSecretsCmd())
+	return cmd
+}
+
+// reportTable is the result of a `kc report` command: one row per entity,
+// rendered as a text table, CSV, JSON or markdown.
+type reportTable struct {
+	columns []string
+	rows    [][]string
+}
+
+// reportOutput holds the flags every `kc report` command shares.
+type reportOutput struct {
+	format string
+	out    string
+}
+
+func (r *reportOutput) add(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&r.format, "format", "", "report format: text|csv|json|markdown (default: text, or json with --output json)")
+	cmd.Flags().StringVar(&r.out, "out", "", "write the report to this file instead of the output")
+}
+
+func (r *reportOutput) validate() error {
+	if r.format == "" {
+		r.format = outputFormat
+	}
+	switch r.format {
+	case "text", "csv", "json", "markdown":
+		return nil
+	}
+	return fmt.Errorf("invalid --format %q: must be text, csv, json or markdown", r.format)
+}
+
+// print renders t in the chosen format, to --out when set. Text output goes
+// in a box, with summary as its last line.
+func (r *reportOutput) print(cmd *cobra.Command, t *reportTable, realm, summary string) error {
+	if r.format == "text" && r.out == "" {
+		printBox(cmd, append(t.textLines(), summary), realm)
+		return nil
+	}
+	var buf bytes.Buffer
+	var err error
+	switch r.format {
+	case "text":
+		_, err = io.WriteString(&buf, strings.Join(t.textLines(), "\n")+"\n")
+	case "csv":
+		err = t.writeCSV(&buf)
+	case "json":
+		err = t.writeJSON(&buf)
+	case "markdown":
+		t.writeMarkdown(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	if r.out == "" {
+		_, err = cmd.OutOrStdout().Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(r.out, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed writing %s: %w", r.out, err)
+	}
+	printBox(cmd, []string{summary, fmt.Sprintf("Done. Report written to %s.", r.out)}, realm)
+	return nil
+}
+
+// textLines aligns the columns of t.
+func (t *reportTable) textLines() []string {
+	widths := make([]int, len(t.columns))
+	for i, c := range t.columns {
+		widths[i] = len(c)
+	}
+	for _, row := range t.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	format := func(cells []string) string {
+		var b strings.Builder
+		for i, cell := range cells {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			fmt.Fprintf(&b, "%-*s", widths[i], cell)
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	lines := []string{format(t.columns)}
+	for _, row := range t.rows {
+		lines = append(lines, format(row))
+	}
+	return lines
+}
+
+func (t *reportTable) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// writeJSON writes one object per row, keyed by column.
+func (t *reportTable) writeJSON(w io.Writer) error {
+	out := make([]map[string]string, 0, len(t.rows))
+	for _, row := range t.rows {
+		m := make(map[string]string, len(row))
+		for i, cell := range row {
+			m[t.columns[i]] = cell
+		}
+		out = append(out, m)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func (t *reportTable) writeMarkdown(w io.Writer) {
+	cells := func(row []string) string {
+		escaped := make([]string, len(row))
+		for i, c := range row {
+			escaped[i] = strings.ReplaceAll(c, "|", `\|`)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+	io.WriteString(w, cells(t.columns))
+	sep := make([]string, len(t.columns))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	io.WriteString(w, cells(sep))
+	for _, row := range t.rows {
+		io.WriteString(w, cells(row))
+	}
+}
+
+// reportScopesOptions holds the flags of `kc report scopes`.
+type reportScopesOptions struct {
+	realm          string
+	includeBuiltin bool
+	output         reportOutput
+}
+
+func newReportScopesCmd() *cobra.Command {
+	o := &reportScopesOptions{}
+	cmd := &cobra.Command{
+		Use:   "scopes",
+		Short: "Matrix of the clients of a realm and their default and optional client scopes",
+		Long: `Print a matrix with one row per client and one column per client scope of
+the realm. A cell is "default" or "optional" when the scope is assigned to the
+client that way, and empty otherwise; the defaults and optionals columns count
+the assignments of each client. Use it to audit scope sprawl across the
+clients of a realm.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().BoolVar(&o.includeBuiltin, "include-builtin", false, "also list the clients Keycloak creates (account, admin-cli, ...)")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *reportScopesOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	scopes, err := gc.GetClientScopes(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+	}
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	t, assigned := scopeMatrix(realm, scopes, clients, o.includeBuiltin)
+	if len(t.rows) == 0 {
+		return errors.New("no clients to report; use --include-builtin to list the built-in clients")
+	}
+	summary := fmt.Sprintf("Clients: %d, Client scopes: %d, Assignments: %d", len(t.rows), len(t.columns)-3, assigned)
+	return o.output.print(cmd, t, realm, summary)
+}
+
+// scopeMatrix builds the rows of `kc report scopes`, sorted by clientId, and
+// counts the assignments. Scopes assigned to a client but missing from the
+// realm's list still get a column.
+func scopeMatrix(realm string, scopes []*gocloak.ClientScope, clients []*gocloak.Client, includeBuiltin bool) (*reportTable, int) {
+	var names []string
+	for _, s := range scopes {
+		names = append(names, gocloak.PString(s.Name))
+	}
+	clients = slices.DeleteFunc(slices.Clone(clients), func(c *gocloak.Client) bool {
+		return !includeBuiltin && manifest.IsBuiltinClient(realm, gocloak.PString(c.ClientID))
+	})
+	for _, c := range clients {
+		names = append(names, derefStrings(c.DefaultClientScopes)...)
+		names = append(names, derefStrings(c.OptionalClientScopes)...)
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	slices.SortFunc(clients, func(a, b *gocloak.Client) int {
+		return strings.Compare(gocloak.PString(a.ClientID), gocloak.PString(b.ClientID))
+	})
+
+	t := &reportTable{columns: append(append([]string{"clientId"}, names...), "defaults", "optionals")}
+	assigned := 0
+	for _, c := range clients {
+		defaults, optionals := derefStrings(c.DefaultClientScopes), derefStrings(c.OptionalClientScopes)
+		row := []string{gocloak.PString(c.ClientID)}
+		for _, name := range names {
+			switch {
+			case slices.Contains(defaults, name):
+				row = append(row, "default")
+			case slices.Contains(optionals, name):
+				row = append(row, "optional")
+			default:
+				row = append(row, "")
+			}
+		}
+		row = append(row, fmt.Sprint(len(defaults)), fmt.Sprint(len(optionals)))
+		assigned += len(defaults) + len(optionals)
+		t.rows = append(t.rows, row)
+	}
+	return t, assigned
+}
+
+func init() {
+	rootCmd.AddCommand(newReportCmd())
+}
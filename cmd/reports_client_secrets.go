@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// reportClientSecretsOptions holds the flags of `kc report client-secrets`.
+type reportClientSecretsOptions struct {
+	realm          string
+	maxAge         string
+	includeBuiltin bool
+	output         reportOutput
+}
+
+func newReportClientSecretsCmd() *cobra.Command {
+	o := &reportClientSecretsOptions{}
+	cmd := &cobra.Command{
+		Use:   "client-secrets",
+		Short: "Age and expiry of the secrets of confidential clients, flagging old ones",
+		Long: `List the confidential clients of a realm with the creation time of their
+secret and, when a client secret rotation policy applies (secret-rotation
+executor), when the secret expires and until when the previous one still
+works. The status column flags:
+
+  old        the secret is older than --max-age; rotate it with
+             kc clients rotate-secrets
+  expired    the rotation policy expired the secret
+  unknown    Keycloak keeps no creation time for the secret, e.g. one set
+             before it recorded them; rotate it to start tracking its age
+  ok         none of the above
+
+Public clients and clients authenticating with keys have no secret and are
+left out, and so are built-in clients unless --include-builtin is given.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.maxAge, "max-age", "90d", "flag secrets older than this, e.g. 90d, 12w or 720h")
+	cmd.Flags().BoolVar(&o.includeBuiltin, "include-builtin", false, "also list the clients Keycloak creates (admin-cli, broker, ...)")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *reportClientSecretsOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	maxAge, err := parseAge(o.maxAge)
+	if err != nil || maxAge <= 0 {
+		return fmt.Errorf("invalid --max-age %q: use a duration like 90d, 12w or 720h", o.maxAge)
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+
+	now := time.Now()
+	t := &reportTable{columns: []string{"clientId", "enabled", "created", "ageDays", "rotationPolicy", "expires", "previousExpires", "status"}}
+	counts := map[string]int{}
+	for _, c := range clients {
+		clientID := gocloak.PString(c.ClientID)
+		if noSecretReason(c) != "" || (!o.includeBuiltin && manifest.IsBuiltinClient(realm, clientID)) {
+			continue
+		}
+		attrs := map[string]string{}
+		if c.Attributes != nil {
+			attrs = *c.Attributes
+		}
+		created, age, status := "", "", "unknown"
+		if sec, err := strconv.ParseInt(attrs[secretCreationAttr], 10, 64); err == nil && sec > 0 {
+			at := time.Unix(sec, 0)
+			created = formatSecretTime(attrs[secretCreationAttr])
+			age = strconv.Itoa(int(now.Sub(at).Hours() / 24))
+			status = "ok"
+			if now.Sub(at) > maxAge {
+				status = "old"
+			}
+		}
+		policy := "false"
+		if _, ok := attrs[secretExpirationAttr]; ok {
+			policy = "true"
+		}
+		if sec, err := strconv.ParseInt(attrs[secretExpirationAttr], 10, 64); err == nil && sec > 0 && time.Unix(sec, 0).Before(now) {
+			status = "expired"
+		}
+		counts[status]++
+		t.rows = append(t.rows, []string{clientID, strconv.FormatBool(gocloak.PBool(c.Enabled)), created, age, policy,
+			formatSecretTime(attrs[secretExpirationAttr]), formatSecretTime(attrs[rotatedSecretExpiresAttr]), status})
+	}
+	slices.SortFunc(t.rows, func(a, b []string) int { return slices.Compare(a, b) })
+	summary := fmt.Sprintf("Confidential clients: %d, Older than %s: %d, Expired: %d, Unknown age: %d", len(t.rows), o.maxAge, counts["old"], counts["expired"], counts["unknown"])
+	return o.output.print(cmd, t, realm, summary)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// loginEventTypes are the events counted by `kc report logins`.
+var loginEventTypes = []string{"LOGIN", "LOGIN_ERROR", "CLIENT_LOGIN", "CLIENT_LOGIN_ERROR"}
+
+// reportLoginsOptions holds the flags of `kc report logins`.
+type reportLoginsOptions struct {
+	realm  string
+	since  string
+	top    int
+	output reportOutput
+}
+
+// loginCount is the successful and failed logins of one client, user or IP.
+type loginCount struct {
+	name             string
+	logins, failures int
+}
+
+func newReportLoginsCmd() *cobra.Command {
+	o := &reportLoginsOptions{}
+	cmd := &cobra.Command{
+		Use:   "logins",
+		Short: "Successful and failed logins per client, error, user and IP address",
+		Long: `Count the login events of a realm since --since, to spot credential stuffing
+and misbehaving clients:
+
+  client  logins and failures per client
+  error   failures per error, e.g. invalid_user_credentials, user_not_found
+  user    the --top users with the most failures; the username tried is
+          shown, so unknown users are listed too
+  ip      the --top IP addresses with the most failures
+
+Login events must be enabled in the realm and kept at least as long as
+--since; kc warns when they are kept for less.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.since, "since", "7d", "how far back to count logins, e.g. 24h, 7d or a date like 2006-01-02")
+	cmd.Flags().IntVar(&o.top, "top", 10, "number of failing users and IP addresses to list")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *reportLoginsOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	if o.top < 0 {
+		return fmt.Errorf("invalid --top %d: must be 0 or more", o.top)
+	}
+	now := time.Now()
+	cutoff, err := parseSince(o.since, now)
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	rep, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	if !gocloak.PBool(rep.EventsEnabled) {
+		return fmt.Errorf("login events are disabled in realm %s; enable them to count logins", realm)
+	}
+	if kept := time.Duration(gocloak.PInt64(rep.EventsExpiration)) * time.Second; kept > 0 && now.Add(-kept).After(cutoff) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: realm %s keeps login events for %s only, less than --since; older logins are not counted.\n", realm, kept)
+	}
+
+	params := gocloak.GetEventsParams{Type: loginEventTypes, DateFrom: gocloak.StringP(cutoff.Format("2006-01-02"))}
+	events, err := fetchPaged(0, 0, eventsPageSize, func(first, max int) ([]*gocloak.EventRepresentation, error) {
+		params.First = gocloak.Int32P(int32(first))
+		params.Max = gocloak.Int32P(int32(max))
+		return gc.GetEvents(ctx, token, realm, params)
+	})
+	if err != nil {
+		return fmt.Errorf("failed fetching login events in realm %s: %w", realm, err)
+	}
+
+	clients, errs, users, ips := map[string]*loginCount{}, map[string]*loginCount{}, map[string]*loginCount{}, map[string]*loginCount{}
+	count := func(m map[string]*loginCount, name string, failed bool) {
+		c, ok := m[name]
+		if !ok {
+			c = &loginCount{name: name}
+			m[name] = c
+		}
+		if failed {
+			c.failures++
+		} else {
+			c.logins++
+		}
+	}
+	logins, failures := 0, 0
+	for _, e := range events {
+		if time.UnixMilli(e.Time).Before(cutoff) {
+			continue
+		}
+		t := gocloak.PString(e.Type)
+		failed := t == "LOGIN_ERROR" || t == "CLIENT_LOGIN_ERROR"
+		if failed {
+			failures++
+		} else {
+			logins++
+		}
+		count(clients, gocloak.PString(e.ClientID), failed)
+		if !failed {
+			continue
+		}
+		count(errs, e.Details["error"], true)
+		user := e.Details["username"]
+		if user == "" {
+			user = gocloak.PString(e.UserID)
+		}
+		if user != "" {
+			count(users, user, true)
+		}
+		if ip := gocloak.PString(e.IPAddress); ip != "" {
+			count(ips, ip, true)
+		}
+	}
+
+	t := &reportTable{columns: []string{"kind", "name", "logins", "failures"}}
+	add := func(kind string, m map[string]*loginCount, limit int, failuresOnly bool) {
+		sorted := make([]*loginCount, 0, len(m))
+		for _, c := range m {
+			sorted = append(sorted, c)
+		}
+		slices.SortFunc(sorted, func(a, b *loginCount) int {
+			return cmp.Or(cmp.Compare(b.failures, a.failures), cmp.Compare(b.logins, a.logins), cmp.Compare(a.name, b.name))
+		})
+		if limit >= 0 && len(sorted) > limit {
+			sorted = sorted[:limit]
+		}
+		for _, c := range sorted {
+			l := strconv.Itoa(c.logins)
+			if failuresOnly {
+				l = ""
+			}
+			t.rows = append(t.rows, []string{kind, c.name, l, strconv.Itoa(c.failures)})
+		}
+	}
+	add("client", clients, -1, false)
+	add("error", errs, -1, true)
+	add("user", users, o.top, true)
+	add("ip", ips, o.top, true)
+	summary := fmt.Sprintf("Logins: %d, Failures: %d, since %s", logins, failures, cutoff.Format("2006-01-02"))
+	return o.output.print(cmd, t, realm, summary)
+}
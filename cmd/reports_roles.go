@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// reportRolesOptions holds the flags of `kc report roles`.
+type reportRolesOptions struct {
+	realm  string
+	roles  []string
+	output reportOutput
+}
+
+func newReportRolesCmd() *cobra.Command {
+	o := &reportRolesOptions{}
+	cmd := &cobra.Command{
+		Use:   "roles",
+		Short: "Who holds which realm role, directly, through groups or through composite roles",
+		Long: `List every user holding a realm role, one row per user, role and way the
+role is held, for access reviews:
+
+  direct     the role is mapped to the user
+  group      the role is mapped to a group of the user, or to a parent of one;
+             source is the group path, and the member group for a parent
+  composite  a role the user holds includes it; source is the chain of
+             composite roles and how the first one is held
+
+The default roles of the realm, which every user holds, are left out.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.roles, "role", nil, "only report these realm roles. Repeatable.")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+// roleGrant is one way a user holds a realm role.
+type roleGrant struct {
+	role, via, source string
+}
+
+func (o *reportRolesOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+	}
+	for _, name := range o.roles {
+		if !slices.ContainsFunc(roles, func(r *gocloak.Role) bool { return gocloak.PString(r.Name) == name }) {
+			return fmt.Errorf("role %q not found in realm %s", name, realm)
+		}
+	}
+	composites := map[string][]string{}
+	for _, r := range roles {
+		if !gocloak.PBool(r.Composite) {
+			continue
+		}
+		children, err := keycloak.GetRealmRoleComposites(ctx, gc, token, realm, gocloak.PString(r.Name))
+		if err != nil {
+			return fmt.Errorf("failed reading the composites of role %q in realm %s: %w", gocloak.PString(r.Name), realm, err)
+		}
+		for _, c := range children {
+			composites[gocloak.PString(r.Name)] = append(composites[gocloak.PString(r.Name)], gocloak.PString(c.Name))
+		}
+	}
+	groups, err := listGroups(ctx, gc, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+	}
+	groupRoles := map[string][]string{}
+	for _, g := range groups {
+		groupRoles[gocloak.PString(g.Path)] = withoutDefaultRoles(realm, derefStrings(g.RealmRoles))
+	}
+	users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+	}
+
+	t := &reportTable{columns: []string{"role", "username", "enabled", "via", "source"}}
+	holders := map[string]bool{}
+	for _, u := range users {
+		username := gocloak.PString(u.Username)
+		direct, err := gc.GetRealmRolesByUserID(ctx, token, realm, gocloak.PString(u.ID))
+		if err != nil {
+			return fmt.Errorf("failed reading roles of user %q in realm %s: %w", username, realm, err)
+		}
+		memberOf, err := gc.GetUserGroups(ctx, token, realm, gocloak.PString(u.ID), gocloak.GetGroupsParams{})
+		if err != nil {
+			return fmt.Errorf("failed reading groups of user %q in realm %s: %w", username, realm, err)
+		}
+		var names, paths []string
+		for _, r := range direct {
+			names = append(names, gocloak.PString(r.Name))
+		}
+		for _, g := range memberOf {
+			paths = append(paths, gocloak.PString(g.Path))
+		}
+		for _, g := range userRoleGrants(withoutDefaultRoles(realm, names), paths, groupRoles, composites) {
+			if len(o.roles) > 0 && !slices.Contains(o.roles, g.role) {
+				continue
+			}
+			t.rows = append(t.rows, []string{g.role, username, fmt.Sprint(gocloak.PBool(u.Enabled)), g.via, g.source})
+			holders[username] = true
+		}
+	}
+	slices.SortFunc(t.rows, func(a, b []string) int { return slices.Compare(a, b) })
+	t.rows = slices.CompactFunc(t.rows, slices.Equal)
+	summary := fmt.Sprintf("Users with roles: %d, Assignments: %d", len(holders), len(t.rows))
+	return o.output.print(cmd, t, realm, summary)
+}
+
+// userRoleGrants returns every way a user holds a realm role: the roles
+// mapped to the user, those mapped to the user's groups or their parents, and
+// the roles both include through composites.
+func userRoleGrants(direct, groupPaths []string, groupRoles, composites map[string][]string) []roleGrant {
+	var base []roleGrant
+	for _, r := range direct {
+		base = append(base, roleGrant{role: r, via: "direct"})
+	}
+	for _, p := range groupPaths {
+		for from := p; from != ""; from, _ = splitGroupPath(from) {
+			source := from
+			if from != p {
+				source = fmt.Sprintf("%s, inherited by %s", from, p)
+			}
+			for _, r := range groupRoles[from] {
+				base = append(base, roleGrant{role: r, via: "group", source: source})
+			}
+		}
+	}
+	out := slices.Clone(base)
+	for _, b := range base {
+		origin := "direct"
+		if b.via == "group" {
+			origin = "group " + b.source
+		}
+		var expand func(chain []string)
+		expand = func(chain []string) {
+			for _, c := range composites[chain[len(chain)-1]] {
+				if slices.Contains(chain, c) {
+					continue
+				}
+				out = append(out, roleGrant{role: c, via: "composite", source: fmt.Sprintf("%s (%s)", strings.Join(chain, " > "), origin)})
+				expand(append(slices.Clone(chain), c))
+			}
+		}
+		expand([]string{b.role})
+	}
+	return out
+}
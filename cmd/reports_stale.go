@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// staleEventTypes are the login events that show a user or client in use.
+// Only LOGIN and CLIENT_LOGIN count for users: refreshing a token is not
+// logging in.
+var staleEventTypes = []string{"LOGIN", "CLIENT_LOGIN", "CODE_TO_TOKEN", "REFRESH_TOKEN"}
+
+// reportStaleOptions holds the flags of `kc report stale`.
+type reportStaleOptions struct {
+	realm          string
+	since          string
+	includeBuiltin bool
+	output         reportOutput
+}
+
+func newReportStaleCmd() *cobra.Command {
+	o := &reportStaleOptions{}
+	cmd := &cobra.Command{
+		Use:   "stale",
+		Short: "Users and clients not used recently, and disabled clients, to drive cleanups",
+		Long: `Flag the entities of a realm worth cleaning up:
+
+  - users without a login since --since and without a session; users
+    created since then are reported as never logged in
+  - clients without sessions, offline sessions or logins since --since
+  - disabled clients
+
+Logins are read from the login events of the realm, so they must be enabled
+and kept at least as long as --since; kc warns otherwise. Bearer-only and
+built-in clients are not checked.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.since, "since", "90d", "how far back to look for logins, e.g. 90d, 12w or a date like 2006-01-02")
+	cmd.Flags().BoolVar(&o.includeBuiltin, "include-builtin", false, "also check the clients Keycloak creates (account, admin-cli, ...)")
+	o.output.add(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *reportStaleOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	cutoff, err := parseSince(o.since, now)
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	rep, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	eventsOn := gocloak.PBool(rep.EventsEnabled)
+	if !eventsOn {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: login events are disabled in realm %s; users and clients are judged by their current sessions only.\n", realm)
+	} else if kept := time.Duration(gocloak.PInt64(rep.EventsExpiration)) * time.Second; kept > 0 && now.Add(-kept).After(cutoff) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: realm %s keeps login events for %s only, less than --since; older logins are not seen.\n", realm, kept)
+	}
+
+	// who logged in or used a client since the cutoff
+	activeUsers, activeClients := map[string]bool{}, map[string]bool{}
+	if eventsOn {
+		params := gocloak.GetEventsParams{Type: staleEventTypes, DateFrom: gocloak.StringP(cutoff.Format("2006-01-02"))}
+		events, err := fetchPaged(0, 0, eventsPageSize, func(first, max int) ([]*gocloak.EventRepresentation, error) {
+			params.First = gocloak.Int32P(int32(first))
+			params.Max = gocloak.Int32P(int32(max))
+			return gc.GetEvents(ctx, token, realm, params)
+		})
+		if err != nil {
+			return fmt.Errorf("failed fetching login events in realm %s: %w", realm, err)
+		}
+		for _, e := range events {
+			if time.UnixMilli(e.Time).Before(cutoff) {
+				continue
+			}
+			activeClients[gocloak.PString(e.ClientID)] = true
+			if t := gocloak.PString(e.Type); t == "LOGIN" || t == "CLIENT_LOGIN" {
+				activeUsers[gocloak.PString(e.UserID)] = true
+			}
+		}
+	}
+
+	t := &reportTable{columns: []string{"kind", "name", "enabled", "created", "reason"}}
+	since := "since " + cutoff.Format("2006-01-02")
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	for _, c := range clients {
+		clientID := gocloak.PString(c.ClientID)
+		if gocloak.PBool(c.BearerOnly) || (!o.includeBuiltin && manifest.IsBuiltinClient(realm, clientID)) {
+			continue
+		}
+		if !gocloak.PBool(c.Enabled) {
+			t.rows = append(t.rows, []string{"client", clientID, "false", "", "disabled"})
+			continue
+		}
+		sessions, err := fetchPaged(0, 0, sessionsPageSize, func(first, max int) ([]*gocloak.UserSessionRepresentation, error) {
+			return gc.GetClientUserSessions(ctx, token, realm, *c.ID, gocloak.GetClientUserSessionsParams{First: &first, Max: &max})
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing sessions of client %q in realm %s: %w", clientID, realm, err)
+		}
+		offline, err := fetchPaged(0, 0, sessionsPageSize, func(first, max int) ([]*gocloak.UserSessionRepresentation, error) {
+			return gc.GetClientOfflineSessions(ctx, token, realm, *c.ID, gocloak.GetClientUserSessionsParams{First: &first, Max: &max})
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing offline sessions of client %q in realm %s: %w", clientID, realm, err)
+		}
+		for _, s := range append(sessions, offline...) {
+			activeUsers[gocloak.PString(s.UserID)] = true
+		}
+		if len(sessions) == 0 && len(offline) == 0 && !activeClients[clientID] {
+			reason := "no sessions"
+			if eventsOn {
+				reason = "no sessions or logins " + since
+			}
+			t.rows = append(t.rows, []string{"client", clientID, "true", "", reason})
+		}
+	}
+
+	users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+	}
+	staleUsers := 0
+	for _, u := range users {
+		if activeUsers[gocloak.PString(u.ID)] {
+			continue
+		}
+		reason, created := "no session", ""
+		if eventsOn {
+			reason = "no login " + since
+		}
+		if u.CreatedTimestamp != nil {
+			at := time.UnixMilli(*u.CreatedTimestamp)
+			created = at.Format("2006-01-02")
+			if eventsOn && !at.Before(cutoff) {
+				reason = "never logged in"
+			}
+		}
+		t.rows = append(t.rows, []string{"user", gocloak.PString(u.Username), fmt.Sprint(gocloak.PBool(u.Enabled)), created, reason})
+		staleUsers++
+	}
+	slices.SortFunc(t.rows, func(a, b []string) int { return slices.Compare(a, b) })
+	summary := fmt.Sprintf("Stale users: %d of %d, Stale or disabled clients: %d", staleUsers, len(users), len(t.rows)-staleUsers)
+	return o.output.print(cmd, t, realm, summary)
+}
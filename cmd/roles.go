@@ -1,350 +1,398 @@
 package cmd
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"kc/internal/config"
+	"kc/internal/audit"
 	"kc/internal/keycloak"
+	"kc/pkg/kcops"
 
-	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var (
-	roleNames        []string
-	roleDescriptions []string
-	allRealms        bool
-	rolesRealm       string
-	newRoleNames     []string
-	ignoreMissing    bool
-	ignoreMissingDel bool
-	interactive      bool
-)
+func newRolesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "roles",
+		Short: "Manage roles",
+	}
+	cmd.AddCommand(newRolesCreateCmd())
+	cmd.AddCommand(newRolesUpdateCmd())
+	cmd.AddCommand(newRolesDeleteCmd())
+	cmd.AddCommand(newRolesSyncCmd())
+	return cmd
+}
 
-var rolesCmd = &cobra.Command{
-	Use:   "roles",
-	Short: "Manage roles",
+// rolesCreateOptions holds the flags of `kc roles create`.
+type rolesCreateOptions struct {
+	names           []string
+	descriptions    []string
+	allRealms       bool
+	realm           string
+	interactive     bool
+	template        templateOptions
+	continueOnError bool
 }
 
-var rolesCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create a role in a realm or in all realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if interactive {
-			if err := fillRolesCreateInteractive(cmd); err != nil {
-				return err
-			}
-		}
-		if len(roleNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		// Validate descriptions: allowed counts are 0, 1, or exactly the number of names
-		if !(len(roleDescriptions) == 0 || len(roleDescriptions) == 1 || len(roleDescriptions) == len(roleNames)) {
-			return fmt.Errorf("invalid descriptions: when using multiple --name flags, you must pass either no --description, a single --description to apply to all, or one --description per --name (in order)")
+func newRolesCreateCmd() *cobra.Command {
+	o := &rolesCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a role in a realm or in all realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "role name(s). You can repeat --name multiple times.")
+	cmd.Flags().StringSliceVar(&o.descriptions, "description", nil, "role description(s). Pass none, one (applies to all), or one per --name in order.")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "create role in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVarP(&o.interactive, "interactive", "i", false, "prompt for role parameters interactively")
+	addTemplateFlags(cmd, &o.template)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	addAtomicFlag(cmd)
+	return cmd
+}
+
+func (o *rolesCreateOptions) run(cmd *cobra.Command) error {
+	tplSpecs, err := templateSpecs(cmd, o.template, "name", func(s kcops.RoleSpec) string { return s.Name }, "name", "description")
+	if err != nil {
+		return err
+	}
+	if o.interactive {
+		if err := o.fillInteractive(cmd); err != nil {
+			return err
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
+	}
+	if len(o.names) == 0 && tplSpecs == nil {
+		return errors.New("missing --name: provide at least one --name or --template")
+	}
+	// Validate descriptions: allowed counts are 0, 1, or exactly the number of names
+	if !(len(o.descriptions) == 0 || len(o.descriptions) == 1 || len(o.descriptions) == len(o.names)) {
+		return fmt.Errorf("invalid descriptions: when using multiple --name flags, you must pass either no --description, a single --description to apply to all, or one --description per --name (in order)")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, client, token)
+	if err != nil {
+		return err
+	}
+	specs := make([]kcops.RoleSpec, len(o.names))
+	for i, rn := range o.names {
+		specs[i] = kcops.RoleSpec{Name: rn}
+		specs[i].Description, _ = pick(o.descriptions, i)
+	}
+	if tplSpecs != nil {
+		specs = tplSpecs
+	}
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+	if err := rejectQualified(ctx, client, token, "role name", names); err != nil {
+		return err
+	}
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	rep.expect(len(specs) * len(targetRealms))
+	for _, realm := range targetRealms {
+		specs, err := forRealm(specs, realm)
 		if err != nil {
 			return err
 		}
-		var targetRealms []string
-		if allRealms {
-			realms, err := client.GetRealms(ctx, token)
-			if err != nil {
-				return err
-			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
+		results, err := kcops.CreateRoles(ctx, ops, kcops.CreateRolesRequest{Realm: realm, Roles: specs, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("role", r), r.Error)
+				continue
 			}
-		} else {
-			r := rolesRealm
-			if r == "" {
-				r = defaultRealm
-			}
-			if r == "" {
-				r = config.Global.Realm
-			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
-			}
-			targetRealms = []string{r}
-		}
-		created := 0
-		skipped := 0
-		var lines []string
-		for _, realm := range targetRealms {
-			for i, rn := range roleNames {
-				exists := false
-				_, err := client.GetRealmRole(ctx, token, realm, rn)
-				if err == nil {
-					exists = true
-				} else {
-					if !strings.Contains(strings.ToLower(err.Error()), "404") {
-						return fmt.Errorf("failed checking role in realm %s: %w", realm, err)
-					}
-				}
-				if exists {
-					lines = append(lines, fmt.Sprintf("Role %q already exists in realm %q. Skipped.", rn, realm))
-					skipped++
-					continue
-				}
-				name := rn
-				var desc string
-				if len(roleDescriptions) == 1 {
-					desc = roleDescriptions[0]
-				} else if len(roleDescriptions) == len(roleNames) {
-					desc = roleDescriptions[i]
-				} else {
-					desc = ""
-				}
-				_, err = client.CreateRealmRole(ctx, token, realm, gocloak.Role{
-					Name:        &name,
-					Description: &desc,
-				})
-				if err != nil {
-					return fmt.Errorf("failed creating role %q in realm %s: %w", rn, realm, err)
-				}
-				lines = append(lines, fmt.Sprintf("Created role %q in realm %q.", rn, realm))
-				created++
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("role", r), "already exists", fmt.Sprintf("Role %q already exists in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
+			recordResult(cmd, r)
+			rep.add(kcops.Created, opsItem("role", r), fmt.Sprintf("Created role %q in realm %q.", r.Name, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Created: %d, Skipped: %d.", created, skipped))
-		realmLabel := ""
-		if allRealms {
-			realmLabel = "all realms"
-		} else if rolesRealm != "" {
-			realmLabel = rolesRealm
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Created: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
+}
+
+// rolesUpdateOptions holds the flags of `kc roles update`.
+type rolesUpdateOptions struct {
+	names           []string
+	descriptions    []string
+	newNames        []string
+	allRealms       bool
+	realm           string
+	ignoreMissing   bool
+	checkReferences bool
+	match           matchOptions
+	continueOnError bool
+}
+
+func newRolesUpdateCmd() *cobra.Command {
+	o := &rolesUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update role(s) in a realm or across realms",
+		Long: `Update the description or the name of role(s) in a realm or across realms.
+
+With --check-references, kc first lists where each renamed role is used:
+the users and groups it is mapped to and the composite roles including it,
+which follow the rename, and the protocol mappers of clients and client
+scopes naming it in their config, which a rename breaks. When any mapper
+names a role, nothing is renamed and the command fails, so the mappers can
+be updated first. Applications reading the role name from tokens cannot be
+checked and need updating too.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "role name(s) to update. Repeatable; required.")
+	addStdinFlag(cmd, "name")
+	o.match.add(cmd, "role")
+	cmd.Flags().StringSliceVar(&o.descriptions, "description", nil, "new description(s). Pass none, one (applies to all), or one per --name in order.")
+	cmd.Flags().StringSliceVar(&o.newNames, "new-name", nil, "new role name(s). Pass none, one (applies to all), or one per --name in order.")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "update role(s) in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip roles not found instead of failing")
+	cmd.Flags().BoolVar(&o.checkReferences, "check-references", false, "list the users, groups, composites and mappers using each renamed role first; fail before renaming when mappers name it")
+	_ = cmd.RegisterFlagCompletionFunc("name", completeRealmRoles)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
 }
 
-var rolesUpdateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update role(s) in a realm or across realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(roleNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
+func (o *rolesUpdateOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 && !o.match.active() {
+		return errors.New("missing --name: provide at least one --name or --match")
+	}
+	if err := o.match.validate(cmd, "name", o.names, []string{"new-name"}, []string{"description"}); err != nil {
+		return err
+	}
+	// At least one of description or new-name must be provided
+	if len(o.descriptions) == 0 && len(o.newNames) == 0 {
+		return errors.New("nothing to update: provide --description and/or --new-name")
+	}
+	// Validate counts for description and new-name: 0, 1, or len(names)
+	if !(len(o.descriptions) == 0 || len(o.descriptions) == 1 || len(o.descriptions) == len(o.names)) {
+		return fmt.Errorf("invalid descriptions: pass none, one (applies to all), or one per --name (in order)")
+	}
+	if !(len(o.newNames) == 0 || len(o.newNames) == 1 || len(o.newNames) == len(o.names)) {
+		return fmt.Errorf("invalid new names: pass none, one (applies to all), or one per --name (in order)")
+	}
+
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetRealms, err := o.match.realms(ctx, cmd, client, token, o.names)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "update", targetRealms, listRoleNames(ctx, client, token)); err != nil {
+			return err
+		}
+	}
+
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	updatesByRealm := map[string][]kcops.RoleUpdate{}
+	for _, realm := range targetRealms {
+		names := o.match.names(realm, o.names)
+		updates := make([]kcops.RoleUpdate, len(names))
+		for i, rn := range names {
+			updates[i] = kcops.RoleUpdate{Name: rn}
+			if len(o.descriptions) > 0 {
+				desc, _ := pick(o.descriptions, i)
+				updates[i].Description = &desc
+			}
+			updates[i].NewName, _ = pick(o.newNames, i)
 		}
-		// At least one of description or new-name must be provided
-		if len(roleDescriptions) == 0 && len(newRoleNames) == 0 {
-			return errors.New("nothing to update: provide --description and/or --new-name")
+		updatesByRealm[realm] = updates
+		rep.expect(len(updates))
+	}
+	if o.checkReferences {
+		if err := o.checkRenames(ctx, cmd, rep, client, token, targetRealms, updatesByRealm); err != nil {
+			return err
 		}
-		// Validate counts for description and new-name: 0, 1, or len(names)
-		if !(len(roleDescriptions) == 0 || len(roleDescriptions) == 1 || len(roleDescriptions) == len(roleNames)) {
-			return fmt.Errorf("invalid descriptions: pass none, one (applies to all), or one per --name (in order)")
+		if len(rep.result.Errors) > 0 {
+			return rep.print(cmd, realmsLabel(cmd, targetRealms), "Stopped before renaming: update the mappers naming the roles first.")
 		}
-		if !(len(newRoleNames) == 0 || len(newRoleNames) == 1 || len(newRoleNames) == len(roleNames)) {
-			return fmt.Errorf("invalid new names: pass none, one (applies to all), or one per --name (in order)")
+	}
+	for _, realm := range targetRealms {
+		updates := updatesByRealm[realm]
+		if len(updates) == 0 {
+			continue
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
-		if err != nil {
+		results, err := kcops.UpdateRoles(ctx, ops, kcops.UpdateRolesRequest{Realm: realm, Roles: updates, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("role", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("role", r), "not found", fmt.Sprintf("Role %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			recordResult(cmd, r)
+			rep.add(kcops.Updated, opsItem("role", r), fmt.Sprintf("Updated role %q in realm %q. New name: %q.", r.Name, realm, r.NewName))
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
 			return err
 		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
 
-		var targetRealms []string
-		if allRealms {
-			realms, err := client.GetRealms(ctx, token)
-			if err != nil {
-				return err
+// checkRenames reports the references of the roles renamed by updates. A
+// mapper naming a role is a failure of the report.
+func (o *rolesUpdateOptions) checkRenames(ctx context.Context, cmd *cobra.Command, rep *report, gc keycloak.API, token string, realms []string, updates map[string][]kcops.RoleUpdate) error {
+	for _, realm := range realms {
+		for _, u := range updates[realm] {
+			if u.NewName == "" || u.NewName == u.Name {
+				continue
 			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
+			// missing roles are left to the update and --ignore-missing
+			if _, err := gc.GetRealmRole(ctx, token, realm, u.Name); err != nil {
+				continue
 			}
-		} else {
-			r := rolesRealm
-			if r == "" {
-				r = defaultRealm
+			refs, err := findRoleReferences(ctx, gc, token, realm, u.Name)
+			if err != nil {
+				return err
 			}
-			if r == "" {
-				r = config.Global.Realm
+			for _, line := range refs.lines(realm, u.Name) {
+				rep.note(line)
 			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
+			for _, ref := range refs.byName {
+				rep.fail(audit.ItemResult{Kind: "role", Realm: realm, Name: u.Name}, fmt.Sprintf("role %q in realm %s is named by %s; renaming it to %q breaks the mapper", u.Name, realm, ref, u.NewName))
 			}
-			targetRealms = []string{r}
 		}
+	}
+	return nil
+}
 
-		updated := 0
-		skipped := 0
-		var lines []string
-		for _, realm := range targetRealms {
-			for i, rn := range roleNames {
-				role, err := client.GetRealmRole(ctx, token, realm, rn)
-				if err != nil {
-					// 404 handling
-					if strings.Contains(strings.ToLower(err.Error()), "404") {
-						if ignoreMissing {
-							lines = append(lines, fmt.Sprintf("Role %q not found in realm %q. Skipped.", rn, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("role %q not found in realm %s", rn, realm)
-					}
-					return fmt.Errorf("failed fetching role %q in realm %s: %w", rn, realm, err)
-				}
-				// Apply changes
-				if len(roleDescriptions) == 1 {
-					role.Description = &roleDescriptions[0]
-				} else if len(roleDescriptions) == len(roleNames) {
-					role.Description = &roleDescriptions[i]
-				}
-				if len(newRoleNames) == 1 {
-					role.Name = &newRoleNames[0]
-				} else if len(newRoleNames) == len(roleNames) {
-					role.Name = &newRoleNames[i]
-				}
-				if err := client.UpdateRealmRole(ctx, token, realm, rn, *role); err != nil {
-					return fmt.Errorf("failed updating role %q in realm %s: %w", rn, realm, err)
-				}
-				finalName := rn
-				if role.Name != nil {
-					finalName = *role.Name
-				}
-				lines = append(lines, fmt.Sprintf("Updated role %q in realm %q. New name: %q.", rn, realm, finalName))
-				updated++
-			}
-		}
-		lines = append(lines, fmt.Sprintf("Done. Updated: %d, Skipped: %d.", updated, skipped))
-		realmLabel := ""
-		if allRealms {
-			realmLabel = "all realms"
-		} else if rolesRealm != "" {
-			realmLabel = rolesRealm
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
-		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+// rolesDeleteOptions holds the flags of `kc roles delete`.
+type rolesDeleteOptions struct {
+	names           []string
+	allRealms       bool
+	realm           string
+	ignoreMissing   bool
+	match           matchOptions
+	continueOnError bool
 }
 
-var rolesDeleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete role(s) in a realm or across realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(roleNames) == 0 {
-			return errors.New("missing --name: provide at least one --name")
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
-		if err != nil {
+func newRolesDeleteCmd() *cobra.Command {
+	o := &rolesDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete role(s) in a realm or across realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringSliceVar(&o.names, "name", nil, "role name(s) to delete. Repeatable; required.")
+	addStdinFlag(cmd, "name")
+	o.match.add(cmd, "role")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "delete role(s) in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip roles not found instead of failing")
+	_ = cmd.RegisterFlagCompletionFunc("name", completeRealmRoles)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *rolesDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.names) == 0 && !o.match.active() {
+		return errors.New("missing --name: provide at least one --name or --match")
+	}
+	if err := o.match.validate(cmd, "name", o.names, nil, nil); err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetRealms, err := o.match.realms(ctx, cmd, client, token, o.names)
+	if err != nil {
+		return err
+	}
+
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "delete", targetRealms, listRoleNames(ctx, client, token)); err != nil {
 			return err
 		}
+	}
 
-		var targetRealms []string
-		if allRealms {
-			realms, err := client.GetRealms(ctx, token)
-			if err != nil {
-				return err
-			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
-			}
-		} else {
-			r := rolesRealm
-			if r == "" {
-				r = defaultRealm
-			}
-			if r == "" {
-				r = config.Global.Realm
-			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
-			}
-			targetRealms = []string{r}
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		rep.expect(len(o.match.names(realm, o.names)))
+	}
+	for _, realm := range targetRealms {
+		names := o.match.names(realm, o.names)
+		if len(names) == 0 {
+			continue
 		}
-
-		deleted := 0
-		skipped := 0
-		var lines []string
-		for _, realm := range targetRealms {
-			for _, rn := range roleNames {
-				if err := client.DeleteRealmRole(ctx, token, realm, rn); err != nil {
-					if strings.Contains(strings.ToLower(err.Error()), "404") {
-						if ignoreMissingDel {
-							lines = append(lines, fmt.Sprintf("Role %q not found in realm %q. Skipped.", rn, realm))
-							skipped++
-							continue
-						}
-						return fmt.Errorf("role %q not found in realm %s", rn, realm)
-					}
-					return fmt.Errorf("failed deleting role %q in realm %s: %w", rn, realm, err)
-				}
-				lines = append(lines, fmt.Sprintf("Deleted role %q in realm %q.", rn, realm))
-				deleted++
+		results, err := kcops.DeleteRoles(ctx, ops, kcops.DeleteRolesRequest{Realm: realm, Names: names, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("role", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("role", r), "not found", fmt.Sprintf("Role %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
+			recordResult(cmd, r)
+			rep.add(kcops.Deleted, opsItem("role", r), fmt.Sprintf("Deleted role %q in realm %q.", r.Name, realm))
 		}
-		lines = append(lines, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", deleted, skipped))
-		realmLabel := ""
-		if allRealms {
-			realmLabel = "all realms"
-		} else if rolesRealm != "" {
-			realmLabel = rolesRealm
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
 }
 
 func init() {
-	rootCmd.AddCommand(rolesCmd)
-	rolesCmd.AddCommand(rolesCreateCmd)
-	rolesCreateCmd.Flags().StringSliceVar(&roleNames, "name", nil, "role name(s). You can repeat --name multiple times.")
-	rolesCreateCmd.Flags().StringSliceVar(&roleDescriptions, "description", nil, "role description(s). Pass none, one (applies to all), or one per --name in order.")
-	rolesCreateCmd.Flags().BoolVar(&allRealms, "all-realms", false, "create role in all realms")
-	rolesCreateCmd.Flags().StringVar(&rolesRealm, "realm", "", "target realm")
-	rolesCreateCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "prompt for role parameters interactively")
-
-	rolesCmd.AddCommand(rolesUpdateCmd)
-	rolesUpdateCmd.Flags().StringSliceVar(&roleNames, "name", nil, "role name(s) to update. Repeatable; required.")
-	rolesUpdateCmd.Flags().StringSliceVar(&roleDescriptions, "description", nil, "new description(s). Pass none, one (applies to all), or one per --name in order.")
-	rolesUpdateCmd.Flags().StringSliceVar(&newRoleNames, "new-name", nil, "new role name(s). Pass none, one (applies to all), or one per --name in order.")
-	rolesUpdateCmd.Flags().BoolVar(&allRealms, "all-realms", false, "update role(s) in all realms")
-	rolesUpdateCmd.Flags().StringVar(&rolesRealm, "realm", "", "target realm")
-	rolesUpdateCmd.Flags().BoolVar(&ignoreMissing, "ignore-missing", false, "skip roles not found instead of failing")
-
-	rolesCmd.AddCommand(rolesDeleteCmd)
-	rolesDeleteCmd.Flags().StringSliceVar(&roleNames, "name", nil, "role name(s) to delete. Repeatable; required.")
-	rolesDeleteCmd.Flags().BoolVar(&allRealms, "all-realms", false, "delete role(s) in all realms")
-	rolesDeleteCmd.Flags().StringVar(&rolesRealm, "realm", "", "target realm")
-	rolesDeleteCmd.Flags().BoolVar(&ignoreMissingDel, "ignore-missing", false, "skip roles not found instead of failing")
+	rootCmd.AddCommand(newRolesCmd())
 }
 
-func fillRolesCreateInteractive(cmd *cobra.Command) error {
-	reader := bufio.NewReader(cmd.InOrStdin())
+func (o *rolesCreateOptions) fillInteractive(cmd *cobra.Command) error {
+	reader := cmdState(cmd).stdin(cmd)
 
-	if jiraTicket == "" {
+	if st := cmdState(cmd); st.jira == "" {
 		fmt.Fprint(cmd.OutOrStdout(), "Jira ticket (optional, leave empty to skip): ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			return err
 		}
-		jiraTicket = strings.TrimSpace(line)
+		st.jira = strings.TrimSpace(line)
 	}
 
-	if !allRealms && rolesRealm == "" {
+	if !o.allRealms && o.realm == "" {
 		fmt.Fprint(cmd.OutOrStdout(), "Create role in all realms? [y/N]: ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -352,20 +400,20 @@ func fillRolesCreateInteractive(cmd *cobra.Command) error {
 		}
 		answer := strings.ToLower(strings.TrimSpace(line))
 		if answer == "y" || answer == "yes" {
-			allRealms = true
+			o.allRealms = true
 		}
 	}
 
-	if !allRealms && rolesRealm == "" {
+	if !o.allRealms && o.realm == "" {
 		fmt.Fprint(cmd.OutOrStdout(), "Target realm (leave empty to use default/config): ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			return err
 		}
-		rolesRealm = strings.TrimSpace(line)
+		o.realm = strings.TrimSpace(line)
 	}
 
-	if len(roleNames) == 0 {
+	if len(o.names) == 0 {
 		fmt.Fprint(cmd.OutOrStdout(), "Role name(s) (comma-separated): ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -375,12 +423,12 @@ func fillRolesCreateInteractive(cmd *cobra.Command) error {
 		for _, p := range parts {
 			name := strings.TrimSpace(p)
 			if name != "" {
-				roleNames = append(roleNames, name)
+				o.names = append(o.names, name)
 			}
 		}
 	}
 
-	if len(roleDescriptions) == 0 {
+	if len(o.descriptions) == 0 {
 		fmt.Fprint(cmd.OutOrStdout(), "Role description (optional, applies to all names): ")
 		line, err := reader.ReadString('\n')
 		if err != nil {
@@ -388,7 +436,7 @@ func fillRolesCreateInteractive(cmd *cobra.Command) error {
 		}
 		desc := strings.TrimSpace(line)
 		if desc != "" {
-			roleDescriptions = []string{desc}
+			o.descriptions = []string{desc}
 		}
 	}
 
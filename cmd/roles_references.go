@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// roleReferences are the places a realm role is used. Users, groups and
+// composites hold the role by ID and follow a rename; byName are protocol
+// mappers naming the role in their config, which a rename breaks.
+type roleReferences struct {
+	users      []string
+	groups     []string
+	composites []string
+	byName     []string
+}
+
+// findRoleReferences looks up the references of realm role name in realm.
+func findRoleReferences(ctx context.Context, gc keycloak.API, token, realm, name string) (roleReferences, error) {
+	var refs roleReferences
+	users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return keycloak.GetRealmRoleUsers(ctx, gc, token, realm, name, first, max)
+	})
+	if err != nil {
+		return refs, fmt.Errorf("failed listing the users of role %q in realm %s: %w", name, realm, err)
+	}
+	for _, u := range users {
+		refs.users = append(refs.users, gocloak.PString(u.Username))
+	}
+
+	groups, err := listGroups(ctx, gc, token, realm)
+	if err != nil {
+		return refs, fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+	}
+	for _, g := range groups {
+		if g.RealmRoles != nil && slices.Contains(*g.RealmRoles, name) {
+			refs.groups = append(refs.groups, gocloak.PString(g.Path))
+		}
+	}
+
+	roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+	if err != nil {
+		return refs, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+	}
+	for _, r := range roles {
+		if !gocloak.PBool(r.Composite) || gocloak.PString(r.Name) == name {
+			continue
+		}
+		children, err := keycloak.GetRealmRoleComposites(ctx, gc, token, realm, gocloak.PString(r.Name))
+		if err != nil {
+			return refs, fmt.Errorf("failed reading the composites of role %q in realm %s: %w", gocloak.PString(r.Name), realm, err)
+		}
+		if slices.ContainsFunc(children, func(c *gocloak.Role) bool { return gocloak.PString(c.Name) == name }) {
+			refs.composites = append(refs.composites, gocloak.PString(r.Name))
+		}
+	}
+
+	// hardcoded-role and role-name mappers store the role name, not its ID
+	mappersNaming := func(mappers []gocloak.ProtocolMapperRepresentation, owner string) {
+		for _, m := range mappers {
+			if m.Config == nil {
+				continue
+			}
+			for _, k := range slices.Sorted(maps.Keys(*m.Config)) {
+				if (*m.Config)[k] == name {
+					refs.byName = append(refs.byName, fmt.Sprintf("mapper %q of %s (%s)", gocloak.PString(m.Name), owner, k))
+				}
+			}
+		}
+	}
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return refs, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	for _, c := range clients {
+		if c.ProtocolMappers != nil {
+			mappersNaming(*c.ProtocolMappers, fmt.Sprintf("client %q", gocloak.PString(c.ClientID)))
+		}
+	}
+	scopes, err := gc.GetClientScopes(ctx, token, realm)
+	if err != nil {
+		return refs, fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+	}
+	for _, s := range scopes {
+		if s.ProtocolMappers == nil || len(*s.ProtocolMappers) == 0 {
+			continue
+		}
+		mappers, err := keycloak.GetClientScopeMappers(ctx, gc, token, realm, gocloak.PString(s.ID))
+		if err != nil {
+			return refs, fmt.Errorf("failed reading the mappers of client scope %q in realm %s: %w", gocloak.PString(s.Name), realm, err)
+		}
+		mappersNaming(mappers, fmt.Sprintf("client scope %q", gocloak.PString(s.Name)))
+	}
+	return refs, nil
+}
+
+// lines describes refs for the output of `kc roles update --check-references`.
+func (refs roleReferences) lines(realm, name string) []string {
+	list := func(names []string) string {
+		const shown = 5
+		if len(names) > shown {
+			return fmt.Sprintf("%s and %d more", strings.Join(names[:shown], ", "), len(names)-shown)
+		}
+		return strings.Join(names, ", ")
+	}
+	var out []string
+	if len(refs.users) > 0 {
+		out = append(out, fmt.Sprintf("Role %q in realm %q is mapped to %d user(s): %s.", name, realm, len(refs.users), list(refs.users)))
+	}
+	if len(refs.groups) > 0 {
+		out = append(out, fmt.Sprintf("Role %q in realm %q is mapped to %d group(s): %s.", name, realm, len(refs.groups), list(refs.groups)))
+	}
+	if len(refs.composites) > 0 {
+		out = append(out, fmt.Sprintf("Role %q in realm %q is included in composite role(s): %s.", name, realm, list(refs.composites)))
+	}
+	if len(out) == 0 && len(refs.byName) == 0 {
+		out = append(out, fmt.Sprintf("Role %q in realm %q has no references.", name, realm))
+	}
+	return out
+}
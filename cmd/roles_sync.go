@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/manifest"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+// rolesSyncOptions holds the flags of `kc roles sync`.
+type rolesSyncOptions struct {
+	file            string
+	prune           bool
+	dryRun          bool
+	allRealms       bool
+	realm           string
+	continueOnError bool
+}
+
+// rolesFile is the file of kc roles sync: the realm roles a realm must have.
+type rolesFile struct {
+	Roles []manifest.Role `yaml:"roles"`
+}
+
+func newRolesSyncCmd() *cobra.Command {
+	o := &rolesSyncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Make the realm roles of a realm match a file",
+		Long: `Create the realm roles of --file missing in the realm and set the description
+of the others to the one in the file. With --prune, the roles of the realm
+not in the file are deleted too, except the ones Keycloak creates
+(default-roles-<realm>, offline_access, uma_authorization and, in master,
+admin and create-realm). The file lists the roles under roles:
+
+  roles:
+    - name: admin
+      description: Full access
+    - name: viewer
+
+With --dry-run, kc lists the changes without making them.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-realm")
+	cmd.Flags().StringVarP(&o.file, "file", "f", "", "YAML file with the roles the realm must have (required)")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "delete the roles not in the file")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the changes without making them")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "sync the roles of all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *rolesSyncOptions) run(cmd *cobra.Command) error {
+	if o.file == "" {
+		return errors.New("missing --file")
+	}
+	want, err := loadRolesFile(o.file)
+	if err != nil {
+		return fmt.Errorf("invalid --file %s: %w", o.file, err)
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, client, token)
+	if err != nil {
+		return err
+	}
+
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		current, err := client.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{BriefRepresentation: gocloak.BoolP(false)})
+		if err != nil {
+			return fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+		}
+		descriptions := map[string]string{}
+		for _, r := range current {
+			descriptions[gocloak.PString(r.Name)] = gocloak.PString(r.Description)
+		}
+		var creates []kcops.RoleSpec
+		var updates []kcops.RoleUpdate
+		for _, r := range want {
+			desc, ok := descriptions[r.Name]
+			switch {
+			case !ok:
+				creates = append(creates, kcops.RoleSpec{Name: r.Name, Description: r.Description})
+			case desc != r.Description:
+				updates = append(updates, kcops.RoleUpdate{Name: r.Name, Description: gocloak.StringP(r.Description)})
+			}
+		}
+		var deletes []string
+		if o.prune {
+			for _, r := range current {
+				name := gocloak.PString(r.Name)
+				if !manifest.IsBuiltinRole(realm, name) && !slices.ContainsFunc(want, func(w manifest.Role) bool { return w.Name == name }) {
+					deletes = append(deletes, name)
+				}
+			}
+			slices.Sort(deletes)
+		}
+		if len(creates)+len(updates)+len(deletes) == 0 {
+			rep.note(fmt.Sprintf("The roles of realm %q already match %s.", realm, o.file))
+			continue
+		}
+
+		if o.dryRun {
+			for _, s := range creates {
+				rep.add(kcops.Created, audit.ItemResult{Kind: "role", Realm: realm, Name: s.Name}, fmt.Sprintf("Would create role %q in realm %q.", s.Name, realm))
+			}
+			for _, u := range updates {
+				rep.add(kcops.Updated, audit.ItemResult{Kind: "role", Realm: realm, Name: u.Name}, fmt.Sprintf("Would update the description of role %q in realm %q: %q → %q.", u.Name, realm, descriptions[u.Name], *u.Description))
+			}
+			for _, n := range deletes {
+				rep.add(kcops.Deleted, audit.ItemResult{Kind: "role", Realm: realm, Name: n}, fmt.Sprintf("Would delete role %q in realm %q.", n, realm))
+			}
+			continue
+		}
+
+		results, err := kcops.CreateRoles(ctx, ops, kcops.CreateRolesRequest{Realm: realm, Roles: creates, ContinueOnError: o.continueOnError})
+		o.report(ctx, rep, "roles_create", results, func(r kcops.Result) string {
+			return fmt.Sprintf("Created role %q in realm %q.", r.Name, realm)
+		})
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+		results, err = kcops.UpdateRoles(ctx, ops, kcops.UpdateRolesRequest{Realm: realm, Roles: updates, ContinueOnError: o.continueOnError})
+		o.report(ctx, rep, "roles_update", results, func(r kcops.Result) string {
+			return fmt.Sprintf("Updated the description of role %q in realm %q.", r.Name, realm)
+		})
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+		results, err = kcops.DeleteRoles(ctx, ops, kcops.DeleteRolesRequest{Realm: realm, Names: deletes, ContinueOnError: o.continueOnError})
+		o.report(ctx, rep, "roles_delete", results, func(r kcops.Result) string {
+			return fmt.Sprintf("Deleted role %q in realm %q.", r.Name, realm)
+		})
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	if o.dryRun {
+		return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Dry run: %d to create, %d to update, %d to delete, nothing changed.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Deleted)))
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Created: %d, Updated: %d, Deleted: %d.", len(rep.result.Created), len(rep.result.Updated), len(rep.result.Deleted)))
+}
+
+// report adds the results of one kcops call to rep and records each change
+// under kind, the one of the roles command making it, so kc undo can revert
+// it.
+func (o *rolesSyncOptions) report(ctx context.Context, rep *report, kind string, results []kcops.Result, line func(kcops.Result) string) {
+	for _, r := range results {
+		if r.Outcome == kcops.Failed {
+			rep.fail(opsItem("role", r), r.Error)
+			continue
+		}
+		recordResultAs(ctx, kind, r)
+		rep.add(r.Outcome, opsItem("role", r), line(r))
+	}
+}
+
+// loadRolesFile reads the file of kc roles sync, rejecting unknown fields,
+// roles without a name and duplicates.
+func loadRolesFile(file string) ([]manifest.Role, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var f rolesFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, r := range f.Roles {
+		if r.Name == "" {
+			return nil, errors.New("role without name")
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("role %q listed twice", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return f.Roles, nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestRoles(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		want       wantResult
+		wantKind   string
+		wantFields map[string]string
+		wantRoles  map[string]bool
+	}{
+		{
+			name:      "create",
+			args:      []string{"create", "--name", "editor", "--name", "viewer", "--description", "Edits"},
+			want:      wantResult{created: []string{"test/editor"}, skipped: []string{"test/viewer"}},
+			wantKind:  "roles_create",
+			wantRoles: map[string]bool{"editor": true, "viewer": true},
+		},
+		{
+			name:       "update",
+			args:       []string{"update", "--name", "viewer", "--description", "Reads everything"},
+			want:       wantResult{updated: []string{"test/viewer"}},
+			wantKind:   "roles_update",
+			wantFields: map[string]string{"description": "Reads everything"},
+		},
+		{
+			name:       "rename",
+			args:       []string{"update", "--name", "viewer", "--new-name", "reader"},
+			want:       wantResult{updated: []string{"test/viewer"}},
+			wantKind:   "roles_update",
+			wantFields: map[string]string{"name": "reader"},
+			wantRoles:  map[string]bool{"viewer": false, "reader": true},
+		},
+		{
+			name:      "delete",
+			args:      []string{"delete", "--name", "viewer"},
+			want:      wantResult{deleted: []string{"test/viewer"}},
+			wantKind:  "roles_delete",
+			wantRoles: map[string]bool{"viewer": false},
+		},
+		{
+			name:    "delete a missing role",
+			args:    []string{"delete", "--name", "nobody"},
+			wantErr: `role "nobody" not found in realm test`,
+		},
+		{
+			name: "delete a missing role with --ignore-missing",
+			args: []string{"delete", "--name", "nobody", "--ignore-missing"},
+			want: wantResult{skipped: []string{"test/nobody"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			api := newTestAPI(t)
+			if _, err := api.CreateRealmRole(ctx, "", "test", gocloak.Role{Name: gocloak.StringP("viewer"), Description: gocloak.StringP("Reads")}); err != nil {
+				t.Fatal(err)
+			}
+			_, entry, err := run(t, api, newRolesCmd, append([]string{"roles", "--realm", "test"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				checkEntry(t, entry, "error", wantResult{})
+				return
+			}
+			checkEntry(t, entry, "ok", tt.want)
+			if tt.wantKind != "" {
+				name := tt.args[2]
+				c := changeOf(t, entry, "test", name)
+				if c.Kind != tt.wantKind {
+					t.Errorf("change kind = %q, want %q", c.Kind, tt.wantKind)
+				}
+				for field, want := range tt.wantFields {
+					if f, _ := fieldOf(c, field); f.New != want {
+						t.Errorf("%s = %+v, want %q", field, f, want)
+					}
+				}
+			}
+			for role, want := range tt.wantRoles {
+				if _, err := api.GetRealmRole(ctx, "", "test", role); (err == nil) != want {
+					t.Errorf("role %q exists = %v, want %v", role, err == nil, want)
+				}
+			}
+		})
+	}
+}
@@ -2,16 +2,24 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"kc/internal/audit"
 	"kc/internal/config"
+	"kc/internal/i18n"
+	"kc/internal/keycloak"
 	"kc/internal/ui"
 
+	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
@@ -20,7 +28,13 @@ var (
 	defaultRealm string
 	logFile      string
 	jiraTicket   string
-	auditDetails string
+	outputFormat string
+	rateLimit    float64
+	rateBurst    int
+	// outputLang is the language of the text output (--lang).
+	outputLang string
+	// commandTimeout overrides the default timeout of every command when set.
+	commandTimeout time.Duration
 )
 
 var rootCmd = &cobra.Command{
@@ -33,9 +47,56 @@ var rootCmd = &cobra.Command{
 		return cmd.Help()
 	}),
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if err := config.Load(cfgFile); err != nil {
+		if isCompletionCommand(cmd) {
+			return nil
+		}
+		if err := loadConfig(); err != nil {
+			switch {
+			case isConfigCommand(cmd):
+				// kc config creates and repairs the file, so it must start without it
+				config.Global = config.Config{AuthRealm: "master"}
+			case errors.Is(err, config.ErrNotFound) && cmd.CommandPath() == "kc broadcast":
+				// the servers have config files of their own
+				config.Global = config.Config{AuthRealm: "master"}
+			default:
+				return err
+			}
+		}
+		if err := setupFileLocations(cmd); err != nil {
+			return err
+		}
+		if err := expandFlagFiles(cmd); err != nil {
+			return err
+		}
+		if err := readStdinValues(cmd); err != nil {
 			return err
 		}
+		rps, burst := config.Global.RateLimit, config.Global.RateBurst
+		if cmd.Flags().Changed("rate-limit") {
+			rps = rateLimit
+		}
+		if cmd.Flags().Changed("rate-burst") {
+			burst = rateBurst
+		}
+		keycloak.SetRateLimit(rps, burst)
+		lang := config.Global.Lang
+		if cmd.Flags().Changed("lang") {
+			lang = outputLang
+		}
+		if err := i18n.SetLanguage(lang); err != nil {
+			return fmt.Errorf("invalid --lang: %w", err)
+		}
+		switch outputFormat {
+		case "text", "json":
+		case "ndjson":
+			if !isStreaming(cmd) {
+				return fmt.Errorf("--output ndjson is not supported by %s; use --output json", cmd.CommandPath())
+			}
+		default:
+			return fmt.Errorf("invalid --output %q: must be 'text', 'json' or 'ndjson'", outputFormat)
+		}
+		cmdState(cmd).jira = jiraTicket
+		addFlagSecrets(cmd)
 		if err := setupTeeWriters(cmd); err != nil {
 			return err
 		}
@@ -44,30 +105,52 @@ var rootCmd = &cobra.Command{
 		fmt.Fprintf(cmd.ErrOrStderr(), "[%s] START: %s\n", start.Format(time.RFC3339), raw)
 		ctx := context.WithValue(cmd.Context(), ctxKeyStart{}, start)
 		ctx = context.WithValue(ctx, ctxKeyEnded{}, false)
+		ctx, err := startTracing(ctx, cmd)
+		if err != nil {
+			// tracing must not stop the command itself
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: tracing is off: %v\n", err)
+		}
 		cmd.SetContext(ctx)
 		return nil
 	},
 	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		if isCompletionCommand(cmd) {
+			return nil
+		}
 		ended, _ := cmd.Context().Value(ctxKeyEnded{}).(bool)
 		if !ended {
 			start, _ := cmd.Context().Value(ctxKeyStart{}).(time.Time)
 			end := time.Now()
 			dur := end.Sub(start)
+			reportStats(cmd, "ok", end, dur)
+			endTracing(cmd, nil)
 			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] END: status=ok dur=%s\n\n", end.Format(time.RFC3339), dur)
+			notifyCompletion(cmd, "ok", nil, end, dur)
 			appendAudit(cmd, "ok", start, end, dur)
 		}
-		if logDest != nil {
-			_ = logDest.Close()
-			logDest = nil
-		}
+		closeLog()
 		return nil
 	},
 }
 
+// closeLog writes the rest of the log and closes it. A command that fails
+// skips PersistentPostRunE, so Execute closes the log too.
+func closeLog() {
+	if logDest != nil {
+		_ = logDest.Close()
+		logDest = nil
+	}
+}
+
 func Execute() {
 	rootCmd.SetOut(os.Stdout)
 	rootCmd.SetErr(os.Stderr)
-	if err := rootCmd.Execute(); err != nil {
+	registerCompletions()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err := rootCmd.ExecuteContext(withRunState(ctx, newRunState()))
+	closeLog()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -75,8 +158,25 @@ func Execute() {
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default: config.json next to the binary or current directory)")
 	rootCmd.PersistentFlags().StringVar(&defaultRealm, "realm", "", "target realm")
-	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "kc.log", "path to the log file")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "path to the log file (default: kc.log in log_dir of config.json, or the data directory of the profile)")
 	rootCmd.PersistentFlags().StringVar(&jiraTicket, "jira", "", "Jira ticket identifier for display in command output")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "output format: text|json|ndjson (ndjson: one JSON object per line, streamed; list commands only)")
+	rootCmd.PersistentFlags().StringVar(&outputLang, "lang", "", "language of the text output: "+strings.Join(i18n.Languages(), "|")+" (overrides lang in config.json; default en)")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "timeout", 0, "maximum duration of the command, e.g. 30s or 10m (default: per-command)")
+	rootCmd.PersistentFlags().Float64Var(&rateLimit, "rate-limit", 0, "maximum admin API requests per second (0 = unlimited; overrides rate_limit in config.json)")
+	rootCmd.PersistentFlags().IntVar(&rateBurst, "rate-burst", 1, "requests allowed to exceed --rate-limit in a burst (overrides rate_burst in config.json)")
+	rootCmd.PersistentFlags().BoolVar(&preflightEnabled, "preflight", false, "check the admin roles of mutating commands in every target realm before changing anything")
+}
+
+// loadConfig loads --config or the default config.json. Offline runs
+// (KC_FAKE=1) need no server settings, so the file may be missing there.
+func loadConfig() error {
+	err := config.Load(cfgFile)
+	if err != nil && keycloak.FakeMode() && errors.Is(err, config.ErrNotFound) {
+		config.Global = config.Config{AuthRealm: "master", Realm: "master"}
+		return nil
+	}
+	return err
 }
 
 type ctxKeyStart struct{}
@@ -84,28 +184,127 @@ type ctxKeyEnded struct{}
 
 var logDest io.WriteCloser
 
+// consoleOut and consoleErr are the terminal the command writes to, without
+// the log file behind cmd.OutOrStdout and cmd.ErrOrStderr.
+var consoleOut, consoleErr io.Writer = os.Stdout, os.Stderr
+
+// setupFileLocations points the audit file to audit_dir and, without
+// --log-file, the log to log_dir, creating them as needed. The files kc used
+// to write to the working directory are moved there (see migrateLegacyFile).
+func setupFileLocations(cmd *cobra.Command) error {
+	dir, err := config.AuditDir()
+	if err != nil {
+		return fmt.Errorf("failed locating the audit directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed creating the audit directory: %w", err)
+	}
+	path := filepath.Join(dir, audit.FileName)
+	migrateLegacyFile(cmd, audit.FileName, path)
+	audit.SetPath(path)
+	audit.SetRotation(auditRotation(config.Global.AuditRotation))
+	if cmd.Flags().Changed("log-file") {
+		return nil
+	}
+	if dir, err = config.LogDir(); err != nil {
+		return fmt.Errorf("failed locating the log directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed creating the log directory: %w", err)
+	}
+	logFile = filepath.Join(dir, "kc.log")
+	migrateLegacyFile(cmd, "kc.log", logFile)
+	return nil
+}
+
+// migrateLegacyFile moves legacy, a file kc wrote to the working directory
+// before it kept its files in the profile directory, to path, the file that
+// replaces it, unless path exists already. Then legacy is left alone, as kc
+// no longer reads it, with a warning to merge or remove it.
+func migrateLegacyFile(cmd *cobra.Command, legacy, path string) {
+	old, err := os.Stat(legacy)
+	if err != nil {
+		return
+	}
+	if cur, err := os.Stat(path); err == nil {
+		if !os.SameFile(old, cur) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s in the working directory is no longer used; kc writes %s instead. Merge it into that file or remove it.\n", legacy, path)
+		}
+		return
+	}
+	if err := moveFile(legacy, path); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s in the working directory is no longer used and moving it to %s failed: %v\n", legacy, path, err)
+		return
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Moved %s from the working directory to %s.\n", legacy, path)
+}
+
+// moveFile renames from to to, copying it when they are on different
+// file systems.
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(to)
+		return err
+	}
+	src.Close()
+	return os.Remove(from)
+}
+
 func setupTeeWriters(cmd *cobra.Command) error {
 	lf := logFile
 	if lf == "" {
 		lf = "kc.log"
 	}
+	if err := audit.Rotate(lf, auditRotation(config.Global.LogRotation), time.Now()); err != nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed rotating %s: %v\n", lf, err)
+	}
 	f, err := os.OpenFile(lf, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return err
 	}
-	logDest = f
-	out := io.MultiWriter(cmd.OutOrStdout(), f)
-	errw := io.MultiWriter(cmd.ErrOrStderr(), f)
+	consoleOut, consoleErr = cmd.OutOrStdout(), cmd.ErrOrStderr()
+	// secrets reach the terminal, e.g. a generated password, but never the
+	// log; both streams share one writer so the log keeps their order
+	lw := newRedactingWriter(f, cmdState(cmd))
+	logDest = lw
+	out := io.MultiWriter(cmd.OutOrStdout(), lw)
+	errw := io.MultiWriter(cmd.ErrOrStderr(), lw)
 	cmd.SetOut(out)
 	cmd.SetErr(errw)
 	return nil
 }
 
+// auditRotation converts a rotation of config.json for audit.Rotate.
+func auditRotation(r config.Rotation) audit.Rotation {
+	return audit.Rotation{
+		MaxSize:  int64(r.MaxSizeMB) << 20,
+		MaxFiles: r.MaxFiles,
+		MaxAge:   time.Duration(r.MaxAgeDays) * 24 * time.Hour,
+	}
+}
+
 func buildRawCommand() string {
 	if len(os.Args) == 0 {
 		return "./kc.exe"
 	}
-	return "./kc.exe " + strings.Join(os.Args[1:], " ")
+	return "./kc.exe " + strings.Join(redactArgs(os.Args[1:]), " ")
 }
 
 func withErrorEnd(run func(cmd *cobra.Command, args []string) error) func(*cobra.Command, []string) error {
@@ -115,8 +314,18 @@ func withErrorEnd(run func(cmd *cobra.Command, args []string) error) func(*cobra
 			start, _ := cmd.Context().Value(ctxKeyStart{}).(time.Time)
 			end := time.Now()
 			dur := end.Sub(start)
+			if isTimeout(err) {
+				printTimeout(cmd)
+				cmd.SilenceUsage = true
+			}
+			if atomic, _ := cmd.Flags().GetBool("atomic"); atomic {
+				rollbackCreated(cmd)
+			}
 			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] ERROR: %v\n", end.Format(time.RFC3339), err)
+			reportStats(cmd, "error", end, dur)
+			endTracing(cmd, err)
 			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] END: status=error dur=%s\n\n", end.Format(time.RFC3339), dur)
+			notifyCompletion(cmd, "error", err, end, dur)
 			appendAudit(cmd, "error", start, end, dur)
 			ctx := context.WithValue(cmd.Context(), ctxKeyEnded{}, true)
 			cmd.SetContext(ctx)
@@ -127,35 +336,130 @@ func withErrorEnd(run func(cmd *cobra.Command, args []string) error) func(*cobra
 
 func printBox(cmd *cobra.Command, lines []string, realmLabel string) {
 	opts := ui.BoxOptions{
-		JiraTicket: jiraTicket,
+		JiraTicket: cmdState(cmd).jira,
 		Realm:      realmLabel,
 		Title:      "Keycloak CLI",
 	}
-	box := ui.RenderBox(lines, opts)
+	translated := make([]string, len(lines))
+	for i, l := range lines {
+		translated[i] = i18n.Translate(l)
+	}
+	box := ui.RenderBox(translated, opts)
 	fmt.Fprintln(cmd.OutOrStdout(), box)
 }
 
+// printJSON writes v as indented JSON to the command output instead of a box.
+func printJSON(cmd *cobra.Command, v interface{}) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
 func appendAudit(cmd *cobra.Command, status string, start, end time.Time, dur time.Duration) {
 	raw := buildRawCommand()
 	actorType, actorID := resolveActor()
 	targetRealms := resolveTargetRealms()
 	changeKind := resolveChangeKind(cmd.CommandPath())
+	st := cmdState(cmd)
+	changes, result := st.takeChanges()
 	entry := audit.Entry{
+		ID:           audit.NewID(end),
 		Timestamp:    end,
 		Status:       status,
 		CommandPath:  cmd.CommandPath(),
 		RawCommand:   raw,
-		Jira:         jiraTicket,
+		Jira:         st.jira,
 		ActorType:    actorType,
 		ActorID:      actorID,
 		AuthRealm:    config.Global.AuthRealm,
 		ChangeKind:   changeKind,
 		TargetRealms: targetRealms,
 		Duration:     dur.String(),
-		Details:      auditDetails,
+		Details:      audit.Details{Changes: redactChanges(changes), Result: result, Approval: st.approval},
+	}
+	_ = audit.Append(entry)
+	st.approval = nil
+}
+
+// appendServiceAudit writes an audit entry for one unit of work of a
+// long-running command such as serve or watch, with the changes recorded
+// since the previous entry in the run state of ctx, and returns its ID.
+func appendServiceAudit(ctx context.Context, cmd *cobra.Command, status, raw, jira, actorType, actorID, kind, realms string, start, end time.Time) string {
+	changes, result := stateOf(ctx).takeChanges()
+	entry := audit.Entry{
+		ID:           audit.NewID(end),
+		Timestamp:    end,
+		Status:       status,
+		CommandPath:  cmd.CommandPath(),
+		RawCommand:   raw,
+		Jira:         jira,
+		ActorType:    actorType,
+		ActorID:      actorID,
+		AuthRealm:    config.Global.AuthRealm,
+		ChangeKind:   kind,
+		TargetRealms: realms,
+		Duration:     end.Sub(start).String(),
+		Details:      audit.Details{Changes: redactChanges(changes), Result: result},
 	}
 	_ = audit.Append(entry)
-	auditDetails = ""
+	return entry.ID
+}
+
+// recordChange adds an affected entity to the details of the current audit entry.
+func recordChange(cmd *cobra.Command, realm, entity, id string, fields ...audit.FieldChange) {
+	recordChangeAs(cmd.Context(), resolveChangeKind(cmd.CommandPath()), realm, entity, id, fields...)
+}
+
+// recordChangeAs is recordChange for commands touching several entity kinds,
+// recording in the run state of ctx.
+func recordChangeAs(ctx context.Context, kind, realm, entity, id string, fields ...audit.FieldChange) {
+	st := stateOf(ctx)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.changes = append(st.changes, audit.Change{
+		Kind:   kind,
+		Realm:  realm,
+		Entity: entity,
+		ID:     id,
+		Fields: fields,
+	})
+}
+
+// recordChangeWithBefore is recordChange keeping the representation read before
+// the modification, so `kc undo` can restore it. Client secrets are dropped.
+func recordChangeWithBefore(cmd *cobra.Command, realm, entity, id string, before interface{}, fields ...audit.FieldChange) {
+	recordChange(cmd, realm, entity, id, fields...)
+	setBefore(cmd.Context(), before)
+}
+
+// setBefore attaches the previous representation to the last change recorded
+// in the run state of ctx.
+func setBefore(ctx context.Context, before interface{}) {
+	if c, ok := before.(gocloak.Client); ok {
+		c.Secret = nil
+		before = c
+	}
+	if b, err := json.Marshal(before); err == nil {
+		st := stateOf(ctx)
+		st.mu.Lock()
+		st.changes[len(st.changes)-1].Before = b
+		st.mu.Unlock()
+	}
+}
+
+// appendFieldChange appends a field change when newVal is set and differs from oldVal.
+func appendFieldChange[T comparable](fields []audit.FieldChange, field string, oldVal, newVal *T) []audit.FieldChange {
+	if newVal == nil {
+		return fields
+	}
+	old := ""
+	if oldVal != nil {
+		if *oldVal == *newVal {
+			return fields
+		}
+		old = fmt.Sprint(*oldVal)
+	}
+	return append(fields, audit.FieldChange{Field: field, Old: old, New: fmt.Sprint(*newVal)})
 }
 
 func resolveActor() (string, string) {
@@ -182,8 +486,30 @@ func resolveChangeKind(path string) string {
 	switch path {
 	case "kc users create":
 		return "users_create"
+	case "kc users list":
+		return "users_list"
 	case "kc users update":
 		return "users_update"
+	case "kc users reset-password":
+		return "users_reset_password"
+	case "kc users export":
+		return "users_export"
+	case "kc users consents list":
+		return "users_consents_list"
+	case "kc users consents revoke":
+		return "users_consents_revoke"
+	case "kc users offboard":
+		return "users_offboard"
+	case "kc users anonymize":
+		return "users_anonymize"
+	case "kc users disable":
+		return "users_disable"
+	case "kc users set-enabled":
+		return "users_set_enabled"
+	case "kc users migrate":
+		return "users_migrate"
+	case "kc users dedupe":
+		return "users_dedupe"
 	case "kc users delete":
 		return "users_delete"
 	case "kc clients create":
@@ -194,6 +520,22 @@ func resolveChangeKind(path string) string {
 		return "clients_delete"
 	case "kc clients list":
 		return "clients_list"
+	case "kc clients validate":
+		return "clients_validate"
+	case "kc clients keys show":
+		return "clients_keys_show"
+	case "kc clients keys upload":
+		return "clients_keys_upload"
+	case "kc clients keys rotate":
+		return "clients_keys_rotate"
+	case "kc clients mappers add-claim":
+		return "clients_mappers_add_claim"
+	case "kc clients audience add":
+		return "clients_audience_add"
+	case "kc clients audience remove":
+		return "clients_audience_remove"
+	case "kc clients rotate-secrets":
+		return "clients_rotate_secrets"
 	case "kc client-scopes create":
 		return "client_scopes_create"
 	case "kc client-scopes update":
@@ -208,8 +550,132 @@ func resolveChangeKind(path string) string {
 		return "roles_update"
 	case "kc roles delete":
 		return "roles_delete"
+	case "kc roles sync":
+		return "roles_sync"
+	case "kc groups move":
+		return "groups_move"
+	case "kc groups rename":
+		return "groups_rename"
 	case "kc realms list":
 		return "realms_list"
+	case "kc realms update":
+		return "realms_update"
+	case "kc orgs create":
+		return "orgs_create"
+	case "kc orgs update":
+		return "orgs_update"
+	case "kc orgs delete":
+		return "orgs_delete"
+	case "kc orgs list":
+		return "orgs_list"
+	case "kc orgs members add":
+		return "orgs_members_add"
+	case "kc orgs members remove":
+		return "orgs_members_remove"
+	case "kc orgs members list":
+		return "orgs_members_list"
+	case "kc orgs idp link":
+		return "orgs_idp_link"
+	case "kc realms initial-access create":
+		return "initial_access_create"
+	case "kc realms initial-access delete":
+		return "initial_access_delete"
+	case "kc realms registration-policies create":
+		return "registration_policies_create"
+	case "kc realms registration-policies update":
+		return "registration_policies_update"
+	case "kc realms registration-policies delete":
+		return "registration_policies_delete"
+	case "kc admin-permissions enable":
+		return "admin_permissions_enable"
+	case "kc admin-permissions disable":
+		return "admin_permissions_disable"
+	case "kc admin-permissions show":
+		return "admin_permissions_show"
+	case "kc admin-permissions grant":
+		return "admin_permissions_grant"
+	case "kc admin-permissions revoke":
+		return "admin_permissions_revoke"
+	case "kc delegate":
+		return "delegate"
+	case "kc realms logout-all":
+		return "realms_logout_all"
+	case "kc realms themes get":
+		return "realms_themes_get"
+	case "kc realms themes set":
+		return "realms_themes_set"
+	case "kc realms themes available":
+		return "realms_themes_available"
+	case "kc realms brute-force get":
+		return "realms_brute_force_get"
+	case "kc realms brute-force set":
+		return "realms_brute_force_set"
+	case "kc realms stats":
+		return "realms_stats"
+	case "kc sessions revoke":
+		return "sessions_revoke"
+	case "kc sessions offline revoke":
+		return "sessions_offline_revoke"
+	case "kc sessions offline list":
+		return "sessions_offline_list"
+	case "kc audit list":
+		return "audit_list"
+	case "kc audit security":
+		return "audit_security"
+	case "kc report scopes":
+		return "report_scopes"
+	case "kc report roles":
+		return "report_roles"
+	case "kc report stale":
+		return "report_stale"
+	case "kc report logins":
+		return "report_logins"
+	case "kc report client-secrets":
+		return "report_client_secrets"
+	case "kc events admin list":
+		return "events_admin_list"
+	case "kc events login list":
+		return "events_login_list"
+	case "kc apply":
+		return "apply"
+	case "kc bootstrap":
+		return "bootstrap"
+	case "kc diff":
+		return "diff"
+	case "kc snapshot create":
+		return "snapshot_create"
+	case "kc snapshot restore":
+		return "snapshot_restore"
+	case "kc export terraform":
+		return "export_terraform"
+	case "kc undo":
+		return "undo"
+	case "kc serve":
+		return "serve"
+	case "kc watch":
+		return "watch"
+	case "kc lint":
+		return "lint"
+	case "kc plan":
+		return "plan"
+	case "kc apply-plan":
+		return "apply_plan"
+	case "kc monitor baseline":
+		return "monitor_baseline"
+	case "kc monitor drift":
+		return "monitor_drift"
+	case "kc broadcast":
+		return "broadcast"
+	case "kc search":
+		return "search"
+	case "kc config init":
+		return "config_init"
+	case "kc config view":
+		return "config_view"
+	case "kc config set":
+		return "config_set"
+	case "kc config validate":
+		return "config_validate"
 	default:
 		return path
 	}
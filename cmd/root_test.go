@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestMigrateLegacyFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// legacy and current are the contents of the files, "" for none
+		legacy, current string
+		sameFile        bool
+		want            string
+		wantLegacy      bool
+		wantOut         string
+	}{
+		{name: "no legacy file", current: "new", want: "new"},
+		{name: "moves the legacy file", legacy: "old", want: "old", wantOut: "Moved "},
+		{name: "keeps both when moved before", legacy: "old", current: "new", want: "new", wantLegacy: true, wantOut: "kc.log in the working directory is no longer used; kc writes"},
+		{name: "the same file", legacy: "old", sameFile: true, want: "old", wantLegacy: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			legacy := filepath.Join(dir, "kc.log")
+			path := filepath.Join(dir, "profile", "kc.log")
+			if tt.sameFile {
+				path = legacy
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if tt.legacy != "" {
+				writeTestFile(t, legacy, tt.legacy)
+			}
+			if tt.current != "" {
+				writeTestFile(t, path, tt.current)
+			}
+			var out bytes.Buffer
+			cmd := &cobra.Command{}
+			cmd.SetErr(&out)
+			migrateLegacyFile(cmd, legacy, path)
+
+			if b, err := os.ReadFile(path); err != nil || string(b) != tt.want {
+				t.Errorf("%s = %q, %v; want %q", path, b, err, tt.want)
+			}
+			if _, err := os.Stat(legacy); (err == nil) != tt.wantLegacy {
+				t.Errorf("legacy file exists = %v, want %v", err == nil, tt.wantLegacy)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) || (tt.wantOut == "") != (out.Len() == 0) {
+				t.Errorf("output %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
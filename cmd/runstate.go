@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"slices"
+	"sync"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+
+	"github.com/spf13/cobra"
+)
+
+// runState is the state of one run of a command: the changes and the report
+// of its audit entry, the plan it applies, the protected realms it
+// confirmed and the secrets masked in its log. It travels on the context of
+// the command, so runs in one process, e.g. the requests of serve, share
+// none of it.
+type runState struct {
+	mu       sync.Mutex
+	changes  []audit.Change
+	report   *report
+	approval *audit.Approval
+	// jira is the ticket of --jira, or the one the wizard asked for.
+	jira string
+	// confirmed holds the protected realms confirmed in this run, so a
+	// command resolving its realms twice asks once.
+	confirmed map[string]bool
+	// secrets are masked by redactSecrets, longest first.
+	secrets []string
+	// in reads the answers to prompts. One reader serves the whole run, so a
+	// prompt never buffers the answer meant for the next one.
+	in *bufio.Reader
+	// api, when set, is what connect returns instead of logging in, e.g. a
+	// Fake in tests.
+	api   keycloak.API
+	token string
+}
+
+type ctxKeyRun struct{}
+
+func newRunState() *runState {
+	return &runState{confirmed: map[string]bool{}}
+}
+
+// withRunState returns ctx carrying st.
+func withRunState(ctx context.Context, st *runState) context.Context {
+	return context.WithValue(ctx, ctxKeyRun{}, st)
+}
+
+// stateOf returns the run state of ctx. A context without one, e.g. of a
+// command run outside Execute, gets a fresh state that nothing reads back.
+func stateOf(ctx context.Context) *runState {
+	if ctx != nil {
+		if st, ok := ctx.Value(ctxKeyRun{}).(*runState); ok {
+			return st
+		}
+	}
+	return newRunState()
+}
+
+// cmdState is stateOf for the context of cmd.
+func cmdState(cmd *cobra.Command) *runState {
+	return stateOf(cmd.Context())
+}
+
+// fork returns a fresh state for a run started by the run of st, e.g. a
+// request of serve, keeping its connection and the secrets to mask.
+func (st *runState) fork() *runState {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	f := newRunState()
+	f.api, f.token = st.api, st.token
+	f.secrets = slices.Clone(st.secrets)
+	return f
+}
+
+// stdin returns the reader of the prompts of the run.
+func (st *runState) stdin(cmd *cobra.Command) *bufio.Reader {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.in == nil {
+		st.in = bufio.NewReader(cmd.InOrStdin())
+	}
+	return st.in
+}
+
+// takeChanges returns the recorded changes and report result and starts
+// over, for a command writing one audit entry per unit of work.
+func (st *runState) takeChanges() ([]audit.Change, *audit.Result) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	changes, result := st.changes, st.result()
+	st.changes, st.report = nil, nil
+	return changes, result
+}
+
+// result returns the Result of the report of the run, or nil when it
+// reported nothing. st.mu must be held.
+func (st *runState) result() *audit.Result {
+	if st.report == nil || st.report.result.IsEmpty() {
+		return nil
+	}
+	return &st.report.result
+}
+
+// connect logs in with the settings of config.json, or returns the API of
+// the run state of ctx when it has one.
+func connect(ctx context.Context) (keycloak.API, string, error) {
+	if st := stateOf(ctx); st.api != nil {
+		return st.api, st.token, nil
+	}
+	return keycloak.Login(ctx)
+}
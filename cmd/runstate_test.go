@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"kc/internal/audit"
+)
+
+func TestRunStateIsolation(t *testing.T) {
+	a := withRunState(context.Background(), newRunState())
+	b := withRunState(context.Background(), newRunState())
+	recordChangeAs(a, "users_create", "test", "alice", "1")
+	recordChangeAs(b, "users_delete", "test", "bob", "2")
+	setBefore(b, map[string]string{"username": "bob"})
+	addSecret(a, "s3cret-a")
+
+	changes, _ := stateOf(a).takeChanges()
+	if len(changes) != 1 || changes[0].Entity != "alice" || changes[0].Before != nil {
+		t.Errorf("changes of a = %+v, want only alice", changes)
+	}
+	if got := redactSecrets(stateOf(b), "s3cret-a"); got != "s3cret-a" {
+		t.Errorf("b masks the secret of a: %q", got)
+	}
+	if changes, _ := stateOf(a).takeChanges(); len(changes) != 0 {
+		t.Errorf("takeChanges kept %+v", changes)
+	}
+
+	st := stateOf(a)
+	st.api = newTestAPI(t)
+	st.confirmed["prod"] = true
+	recordChangeAs(a, "users_create", "test", "carol", "3", audit.FieldChange{Field: "email", New: "c@example.org"})
+	f := st.fork()
+	if f.api != st.api || redactSecrets(f, "s3cret-a") != redactedValue {
+		t.Error("fork dropped the connection or the secrets")
+	}
+	if len(f.changes) != 0 || len(f.confirmed) != 0 {
+		t.Errorf("fork kept changes %+v or confirmations %v", f.changes, f.confirmed)
+	}
+	if api, _, err := connect(withRunState(context.Background(), f)); err != nil || api != st.api {
+		t.Errorf("connect = %v, %v; want the API of the run state", api, err)
+	}
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+func newSchedulerCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scheduler",
+		Short: "Run the scheduled work of kc, such as ending temporary disables",
+	}
+	cmd.AddCommand(newSchedulerRunCmd())
+	return cmd
+}
+
+// schedulerRunOptions holds the flags of `kc scheduler run`.
+type schedulerRunOptions struct {
+	interval  time.Duration
+	once      bool
+	dryRun    bool
+	realms    []string
+	allRealms bool
+}
+
+func newSchedulerRunCmd() *cobra.Command {
+	o := &schedulerRunOptions{}
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Enable the users whose temporary disable has ended",
+		Long: `Enable again the users disabled with kc users disable --until once the
+time has passed, and remove their kc.disabledUntil attribute. Users enabled
+by hand in the meantime only lose the attribute.
+
+kc checks every --interval until stopped; with --once it checks a single time
+and exits, e.g. from cron. Each user enabled is written to the audit as a
+change of kind scheduler. A failing check is retried at the next interval.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().DurationVar(&o.interval, "interval", time.Hour, "time between checks")
+	cmd.Flags().BoolVar(&o.once, "once", false, "check once and exit, e.g. from cron")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the users that would be enabled without changing anything")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "realm(s) to check. If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "check all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *schedulerRunOptions) run(cmd *cobra.Command) error {
+	if o.interval <= 0 {
+		return errors.New("invalid --interval: must be positive")
+	}
+	var enabled, failed int
+	var realms []string
+	for {
+		ctx, cancel := commandContext(cmd, 5*time.Minute)
+		checked, n, f, err := o.check(ctx, cmd)
+		cancel()
+		enabled, failed, realms = enabled+n, failed+f, checked
+		if err != nil {
+			if o.once || errors.Is(cmd.Context().Err(), context.Canceled) {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] ERROR: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if o.once {
+			break
+		}
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-time.After(o.interval):
+		}
+	}
+	verb := "Enabled"
+	if o.dryRun {
+		verb = "Would enable"
+	}
+	printBox(cmd, []string{fmt.Sprintf("Done. %s: %d, Failed: %d.", verb, enabled, failed)}, strings.Join(realms, ", "))
+	if failed > 0 {
+		return fmt.Errorf("%d user(s) could not be enabled", failed)
+	}
+	return nil
+}
+
+// check enables the users of every target realm whose disable has ended,
+// with one audit entry per realm with changes, and returns the realms
+// checked and how many users were enabled and failed.
+func (o *schedulerRunOptions) check(ctx context.Context, cmd *cobra.Command) ([]string, int, int, error) {
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// realms are resolved at every check, so --all-realms picks up new ones
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	out := cmd.OutOrStdout()
+	ops := opsClient(gc, token)
+	enabled, failed := 0, 0
+	for _, realm := range realms {
+		start := time.Now()
+		results, err := kcops.EnableExpiredUsers(ctx, ops, kcops.EnableExpiredUsersRequest{Realm: realm, Now: start, DryRun: o.dryRun})
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return realms, enabled, failed, err
+		}
+		for _, r := range results {
+			now := time.Now().Format(time.RFC3339)
+			switch {
+			case r.Outcome == kcops.Failed:
+				fmt.Fprintf(cmd.ErrOrStderr(), "[%s] ERROR: %s\n", now, r.Error)
+				failed++
+			case o.dryRun:
+				fmt.Fprintf(out, "[%s] Would enable user %q in realm %q.\n", now, r.Name, realm)
+				enabled++
+			default:
+				fmt.Fprintf(out, "[%s] Enabled user %q (ID: %s) in realm %q: its disable has ended.\n", now, r.Name, r.ID, realm)
+				recordResultAs(ctx, "scheduler", r)
+				enabled++
+			}
+		}
+		if len(results) > 0 && !o.dryRun {
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			actorType, actorID := resolveActor()
+			appendServiceAudit(ctx, cmd, status, "scheduler: enable users whose disable has ended", "", actorType, actorID, "scheduler", realm, start, time.Now())
+		}
+	}
+	return realms, enabled, failed, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newSchedulerCmd())
+}
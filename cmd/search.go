@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// searchTypes are the entity types kc search looks at, in output order.
+var searchTypes = []string{"user", "client", "role", "group", "scope"}
+
+// searchOptions holds the flags of `kc search`.
+type searchOptions struct {
+	types     []string
+	realms    []string
+	allRealms bool
+}
+
+// searchMatch is one entity found by kc search.
+type searchMatch struct {
+	Type  string `json:"type"`
+	Realm string `json:"realm"`
+	Name  string `json:"name"`
+	ID    string `json:"id,omitempty"`
+	// Detail tells where the term was found when it is not in Name, e.g. the
+	// email of a user, and the client of a client role.
+	Detail string `json:"detail,omitempty"`
+}
+
+func newSearchCmd() *cobra.Command {
+	o := &searchOptions{}
+	cmd := &cobra.Command{
+		Use:   "search TERM",
+		Short: "Find the users, clients, roles, groups and client scopes matching a text",
+		Long: `Search the target realms for entities whose identifier contains TERM,
+ignoring case, to find where an identifier lives:
+
+  user    username, email, first or last name
+  client  clientId or name
+  role    realm role name, or client role name (the client is shown)
+  group   group name (the full path is shown)
+  scope   client scope name
+
+--type limits the search to some of them. Client roles are read client by
+client, so searching roles in realms with many clients takes a while.`,
+		Args: cobra.ExactArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd, args[0])
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.types, "type", nil, "only search these types: "+strings.Join(searchTypes, "|")+". Repeatable.")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "realm(s) to search. If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "search all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *searchOptions) run(cmd *cobra.Command, term string) error {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return errors.New("missing search term")
+	}
+	for _, t := range o.types {
+		if !slices.Contains(searchTypes, t) {
+			return fmt.Errorf("invalid --type %q: must be one of %s", t, strings.Join(searchTypes, ", "))
+		}
+	}
+	types := o.types
+	if len(types) == 0 {
+		types = searchTypes
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	matches := []searchMatch{}
+	for _, realm := range realms {
+		for _, t := range searchTypes {
+			if !slices.Contains(types, t) {
+				continue
+			}
+			found, err := searchRealm(ctx, gc, token, realm, t, term)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, found...)
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, matches)
+	}
+	lines := make([]string, 0, len(matches)+1)
+	for _, m := range matches {
+		line := fmt.Sprintf("%-6s  %s", m.Type, m.Name)
+		if len(realms) > 1 {
+			line = fmt.Sprintf("%-6s  %s  (realm %s)", m.Type, m.Name, m.Realm)
+		}
+		if m.Detail != "" {
+			line += "  " + m.Detail
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(matches)))
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+	return nil
+}
+
+// searchRealm returns the entities of type t in realm matching term.
+func searchRealm(ctx context.Context, gc keycloak.API, token, realm, t, term string) ([]searchMatch, error) {
+	has := func(values ...*string) bool {
+		for _, v := range values {
+			if strings.Contains(strings.ToLower(gocloak.PString(v)), strings.ToLower(term)) {
+				return true
+			}
+		}
+		return false
+	}
+	var out []searchMatch
+	switch t {
+	case "user":
+		// the server searches username, email and names, by prefix unless
+		// the term is wrapped in *
+		search := "*" + term + "*"
+		users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+			return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Search: &search, First: &first, Max: &max, BriefRepresentation: gocloak.BoolP(true)})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed searching users in realm %s: %w", realm, err)
+		}
+		for _, u := range users {
+			m := searchMatch{Type: t, Realm: realm, Name: gocloak.PString(u.Username), ID: gocloak.PString(u.ID)}
+			if !has(u.Username) && u.Email != nil {
+				m.Detail = "email " + *u.Email
+			}
+			out = append(out, m)
+		}
+	case "client", "role":
+		clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+		}
+		if t == "client" {
+			for _, c := range clients {
+				if has(c.ClientID, c.Name) {
+					m := searchMatch{Type: t, Realm: realm, Name: gocloak.PString(c.ClientID), ID: gocloak.PString(c.ID)}
+					if !has(c.ClientID) {
+						m.Detail = "name " + gocloak.PString(c.Name)
+					}
+					out = append(out, m)
+				}
+			}
+			break
+		}
+		roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+		}
+		for _, r := range roles {
+			if has(r.Name) {
+				out = append(out, searchMatch{Type: t, Realm: realm, Name: gocloak.PString(r.Name), ID: gocloak.PString(r.ID)})
+			}
+		}
+		for _, c := range clients {
+			croles, err := gc.GetClientRoles(ctx, token, realm, gocloak.PString(c.ID), gocloak.GetRoleParams{})
+			if err != nil {
+				return nil, fmt.Errorf("failed listing roles of client %q in realm %s: %w", gocloak.PString(c.ClientID), realm, err)
+			}
+			for _, r := range croles {
+				if has(r.Name) {
+					out = append(out, searchMatch{Type: t, Realm: realm, Name: gocloak.PString(r.Name), ID: gocloak.PString(r.ID), Detail: "client " + gocloak.PString(c.ClientID)})
+				}
+			}
+		}
+	case "group":
+		groups, err := listGroups(ctx, gc, token, realm)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+		}
+		for _, g := range groups {
+			if has(g.Name) {
+				out = append(out, searchMatch{Type: t, Realm: realm, Name: gocloak.PString(g.Path), ID: gocloak.PString(g.ID)})
+			}
+		}
+	case "scope":
+		scopes, err := gc.GetClientScopes(ctx, token, realm)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+		}
+		for _, s := range scopes {
+			if has(s.Name) {
+				out = append(out, searchMatch{Type: t, Realm: realm, Name: gocloak.PString(s.Name), ID: gocloak.PString(s.ID)})
+			}
+		}
+	}
+	return out, nil
+}
+
+func init() {
+	rootCmd.AddCommand(newSearchCmd())
+}
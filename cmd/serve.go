@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"kc/internal/config"
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// maxServeBody caps the size of request bodies accepted by `kc serve`.
+const maxServeBody = 1 << 20
+
+// serveHandler decodes a request body and runs the matching kcops operation.
+type serveHandler func(ctx context.Context, ops *kcops.Client, body []byte) ([]kcops.Result, error)
+
+// serveOp adapts a kcops operation to a serveHandler. Unknown fields are
+// rejected so typos do not silently drop attributes.
+func serveOp[R any](run func(context.Context, *kcops.Client, R) ([]kcops.Result, error)) serveHandler {
+	return func(ctx context.Context, ops *kcops.Client, body []byte) ([]kcops.Result, error) {
+		var req R
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			return nil, badRequest{fmt.Errorf("invalid request body: %w", err)}
+		}
+		return run(ctx, ops, req)
+	}
+}
+
+// serveRoutes maps "<entity>/<op>" to its handler. The audit change kind is
+// derived from the path, e.g. client-scopes/update -> client_scopes_update.
+var serveRoutes = map[string]serveHandler{
+	"users/create":         serveOp(kcops.CreateUsers),
+	"users/update":         serveOp(kcops.UpdateUsers),
+	"users/delete":         serveOp(kcops.DeleteUsers),
+	"roles/create":         serveOp(kcops.CreateRoles),
+	"roles/update":         serveOp(kcops.UpdateRoles),
+	"roles/delete":         serveOp(kcops.DeleteRoles),
+	"clients/create":       serveOp(kcops.CreateClients),
+	"clients/update":       serveOp(kcops.UpdateClients),
+	"clients/delete":       serveOp(kcops.DeleteClients),
+	"client-scopes/create": serveOp(kcops.CreateClientScopes),
+	"client-scopes/update": serveOp(kcops.UpdateClientScopes),
+	"client-scopes/delete": serveOp(kcops.DeleteClientScopes),
+}
+
+type badRequest struct{ error }
+
+// serveResponse is the body returned for every operation.
+type serveResponse struct {
+	AuditID string         `json:"auditId,omitempty"`
+	Results []kcops.Result `json:"results"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// serveOptions holds the flags of `kc serve`.
+type serveOptions struct {
+	listen  string
+	tlsCert string
+	tlsKey  string
+}
+
+func newServeCmd() *cobra.Command {
+	o := &serveOptions{}
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Expose provisioning operations as an authenticated HTTP API",
+		Long: "Expose users, roles, clients and client-scopes create/update/delete as an HTTP API.\n" +
+			"Callers authenticate with a bearer token from serve_tokens in config.json (or KC_SERVE_TOKEN);\n" +
+			"every call is written to the audit log with the caller as actor.",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.listen, "listen", ":8089", "address to listen on")
+	cmd.Flags().StringVar(&o.tlsCert, "tls-cert", "", "TLS certificate file; serves HTTPS together with --tls-key")
+	cmd.Flags().StringVar(&o.tlsKey, "tls-key", "", "TLS private key file")
+	return cmd
+}
+
+func (o *serveOptions) run(cmd *cobra.Command) error {
+	tokens := serveTokens()
+	if len(tokens) == 0 {
+		return errors.New("no API tokens configured: set serve_tokens in config.json or KC_SERVE_TOKEN")
+	}
+	if (o.tlsCert == "") != (o.tlsKey == "") {
+		return errors.New("--tls-cert and --tls-key must be used together")
+	}
+	if o.tlsCert == "" {
+		fmt.Fprintln(cmd.ErrOrStderr(), "Warning: serving plain HTTP; use --tls-cert/--tls-key or a TLS-terminating proxy outside localhost.")
+	}
+
+	s := &apiServer{cmd: cmd, tokens: tokens}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	for route, h := range serveRoutes {
+		mux.Handle("POST /v1/"+route, s.handle(route, h))
+	}
+	srv := &http.Server{
+		Addr:              o.listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if o.tlsCert != "" {
+			errc <- srv.ListenAndServeTLS(o.tlsCert, o.tlsKey)
+		} else {
+			errc <- srv.ListenAndServe()
+		}
+	}()
+	fmt.Fprintf(cmd.ErrOrStderr(), "Listening on %s (%d callers)\n", o.listen, len(tokens))
+	select {
+	case err := <-errc:
+		return err
+	case <-cmd.Context().Done():
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// serveTokens returns the accepted bearer tokens keyed by token, mapping to the caller name.
+func serveTokens() map[string]string {
+	tokens := map[string]string{}
+	for name, tok := range config.Global.ServeTokens {
+		if tok != "" {
+			tokens[tok] = name
+		}
+	}
+	if tok := os.Getenv("KC_SERVE_TOKEN"); tok != "" {
+		tokens[tok] = "default"
+	}
+	return tokens
+}
+
+// apiServer runs one operation at a time, as the CLI does. Each request is a
+// run of its own, with a run state and an audit entry of its own.
+type apiServer struct {
+	cmd    *cobra.Command
+	tokens map[string]string
+	mu     sync.Mutex
+}
+
+// caller returns the name of the caller authenticated by r, or "".
+func (s *apiServer) caller(r *http.Request) string {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		return ""
+	}
+	// compare against every token so timing does not reveal which one matched
+	name := ""
+	for tok, n := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(tok)) == 1 {
+			name = n
+		}
+	}
+	return name
+}
+
+func (s *apiServer) handle(route string, h serveHandler) http.Handler {
+	kind := strings.ReplaceAll(strings.ReplaceAll(route, "-", "_"), "/", "_")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := s.caller(r)
+		if caller == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeServeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxServeBody))
+		if err != nil {
+			writeServeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+			return
+		}
+		var target struct {
+			Realm string `json:"realm"`
+		}
+		if err := json.Unmarshal(body, &target); err != nil || target.Realm == "" {
+			writeServeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing realm"})
+			return
+		}
+		// requests cannot be confirmed, so --allow-protected on serve lets them through
+		if isProtected(target.Realm) && !allowProtected {
+			writeServeJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("realm %s is protected (protected_realms in config.json)", target.Realm)})
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		start := time.Now()
+		timeout := commandTimeout
+		if timeout <= 0 {
+			timeout = 120 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(withRunState(r.Context(), cmdState(s.cmd).fork()), timeout)
+		defer cancel()
+
+		var results []kcops.Result
+		gc, token, err := connect(ctx)
+		if err == nil {
+			results, err = h(ctx, kcops.NewClient(gc, token), body)
+		}
+		if !errors.As(err, &badRequest{}) {
+			for _, res := range results {
+				if res.Outcome != kcops.Skipped {
+					recordResultAs(ctx, kind, res)
+				}
+			}
+		}
+		end := time.Now()
+		status := "ok"
+		if err != nil {
+			status = "error"
+		}
+		auditID := appendServiceAudit(ctx, s.cmd, status, r.Method+" "+r.URL.Path, r.Header.Get("X-Jira"), "api", caller, kind, target.Realm, start, end)
+		fmt.Fprintf(s.cmd.ErrOrStderr(), "[%s] API %s %s caller=%s realm=%s status=%s dur=%s\n", end.Format(time.RFC3339), r.Method, r.URL.Path, caller, target.Realm, status, end.Sub(start))
+
+		resp := serveResponse{AuditID: auditID, Results: results}
+		if resp.Results == nil {
+			resp.Results = []kcops.Result{}
+		}
+		switch {
+		case err == nil:
+			writeServeJSON(w, http.StatusOK, resp)
+		case errors.As(err, &badRequest{}):
+			resp.Error = err.Error()
+			writeServeJSON(w, http.StatusBadRequest, resp)
+		default:
+			// Keycloak rejected the operation; results hold the items done before
+			resp.Error = err.Error()
+			writeServeJSON(w, http.StatusBadGateway, resp)
+		}
+	})
+}
+
+func writeServeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func init() {
+	rootCmd.AddCommand(newServeCmd())
+}
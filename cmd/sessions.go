@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+const sessionsPageSize = 100
+
+func newSessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Revoke user sessions and inspect offline sessions",
+	}
+	cmd.AddCommand(newSessionsRevokeCmd())
+	cmd.AddCommand(newSessionsOfflineCmd())
+	return cmd
+}
+
+func newSessionsOfflineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "offline",
+		Short: "Audit and revoke offline sessions (long-lived offline tokens)",
+	}
+	cmd.AddCommand(newSessionsOfflineListCmd())
+	cmd.AddCommand(newSessionsOfflineRevokeCmd())
+	return cmd
+}
+
+// sessionsRevokeOptions holds the flags of `kc sessions revoke`.
+type sessionsRevokeOptions struct {
+	usernames       []string
+	clientIDs       []string
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newSessionsRevokeCmd() *cobra.Command {
+	o := &sessionsRevokeOptions{}
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Log out the sessions of users or clients",
+		Long: `Log out every session of the given users, or every session that used the
+given clients. A session is shared by all clients a user signed in to, so
+revoking by client also ends the user's sessions in other clients. Refresh
+tokens of the sessions stop working at once; issued access tokens stay valid
+until they expire.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "user", nil, "username whose sessions to revoke. Repeatable")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client", nil, "client-id whose sessions to revoke. Repeatable")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "revoke sessions in all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *sessionsRevokeOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 && len(o.clientIDs) == 0 {
+		return errors.New("missing --user or --client: provide at least one")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		for _, un := range o.usernames {
+			if err := revokeUserSessions(ctx, cmd, rep, gc, token, realm, un); err != nil {
+				if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "userSessions", Realm: realm, Name: un}, err); err != nil {
+					return err
+				}
+			}
+		}
+		for _, cid := range o.clientIDs {
+			if err := revokeClientSessions(ctx, cmd, rep, gc, token, realm, cid); err != nil {
+				if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "clientSessions", Realm: realm, Name: cid}, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Revoked: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// revokeUserSessions logs out every session of user un.
+func revokeUserSessions(ctx context.Context, cmd *cobra.Command, rep *report, gc keycloak.API, token, realm, un string) error {
+	item := audit.ItemResult{Kind: "userSessions", Realm: realm, Name: un}
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+	}
+	if len(users) == 0 || users[0].ID == nil {
+		rep.skip(item, "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+		return nil
+	}
+	item.ID = *users[0].ID
+	sessions, err := gc.GetUserSessions(ctx, token, realm, item.ID)
+	if err != nil {
+		return fmt.Errorf("failed listing sessions of user %q in realm %s: %w", un, realm, err)
+	}
+	if len(sessions) == 0 {
+		rep.skip(item, "no sessions", fmt.Sprintf("User %q has no active sessions in realm %q. Skipped.", un, realm))
+		return nil
+	}
+	if err := gc.LogoutAllSessions(ctx, token, realm, item.ID); err != nil {
+		return fmt.Errorf("failed revoking sessions of user %q in realm %s: %w", un, realm, err)
+	}
+	count := fmt.Sprint(len(sessions))
+	recordChange(cmd, realm, "user "+un, item.ID, appendFieldChange(nil, "sessions", &count, gocloak.StringP("0"))...)
+	rep.add(kcops.Deleted, item, fmt.Sprintf("Revoked %d session(s) of user %q in realm %q.", len(sessions), un, realm))
+	return nil
+}
+
+// revokeClientSessions logs out every session that used client cid.
+func revokeClientSessions(ctx context.Context, cmd *cobra.Command, rep *report, gc keycloak.API, token, realm, cid string) error {
+	item := audit.ItemResult{Kind: "clientSessions", Realm: realm, Name: cid}
+	c, err := getClientByClientID(ctx, gc, token, realm, cid)
+	if err != nil || c == nil || c.ID == nil {
+		rep.skip(item, "not found", fmt.Sprintf("Client %q not found in realm %q. Skipped.", cid, realm))
+		return nil
+	}
+	item.ID = *c.ID
+	sessions, err := fetchPaged(0, 0, sessionsPageSize, func(first, max int) ([]*gocloak.UserSessionRepresentation, error) {
+		return gc.GetClientUserSessions(ctx, token, realm, item.ID, gocloak.GetClientUserSessionsParams{First: &first, Max: &max})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing sessions of client %q in realm %s: %w", cid, realm, err)
+	}
+	if len(sessions) == 0 {
+		rep.skip(item, "no sessions", fmt.Sprintf("Client %q has no active sessions in realm %q. Skipped.", cid, realm))
+		return nil
+	}
+	revoked := 0
+	for _, s := range sessions {
+		err := gc.LogoutUserSession(ctx, token, realm, gocloak.PString(s.ID))
+		// a session may end on its own or with another one of the same user
+		if err != nil && !strings.Contains(err.Error(), "404") {
+			return fmt.Errorf("failed revoking session %s of client %q in realm %s: %w", gocloak.PString(s.ID), cid, realm, err)
+		}
+		revoked++
+	}
+	count := fmt.Sprint(revoked)
+	recordChange(cmd, realm, "client "+cid, item.ID, appendFieldChange(nil, "sessions", &count, gocloak.StringP("0"))...)
+	rep.add(kcops.Deleted, item, fmt.Sprintf("Revoked %d session(s) of client %q in realm %q.", revoked, cid, realm))
+	return nil
+}
+
+// sessionsOfflineListOptions holds the flags of `kc sessions offline list`.
+type sessionsOfflineListOptions struct {
+	realm    string
+	clientID string
+	username string
+}
+
+func newSessionsOfflineListCmd() *cobra.Command {
+	o := &sessionsOfflineListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the offline sessions of a client",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	streaming(cmd)
+	cmd.Flags().StringVar(&o.clientID, "client", "", "client-id whose offline sessions to list (required)")
+	cmd.Flags().StringVar(&o.username, "user", "", "only the offline sessions of this username")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	return cmd
+}
+
+func (o *sessionsOfflineListOptions) run(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client: client-id is required")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	c, err := getClientByClientID(ctx, gc, token, realm, o.clientID)
+	if err != nil || c == nil || c.ID == nil {
+		return fmt.Errorf("client %q not found in realm %s", o.clientID, realm)
+	}
+	fetch := func(first, max int) ([]*gocloak.UserSessionRepresentation, error) {
+		return gc.GetClientOfflineSessions(ctx, token, realm, *c.ID, gocloak.GetClientUserSessionsParams{First: &first, Max: &max})
+	}
+	if outputFormat == "ndjson" {
+		_, err := streamPaged(0, 0, sessionsPageSize, fetch, func(s *gocloak.UserSessionRepresentation) error {
+			if o.username != "" && gocloak.PString(s.Username) != o.username {
+				return nil
+			}
+			return printNDJSON(cmd, s)
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing offline sessions of client %q in realm %s: %w", o.clientID, realm, err)
+		}
+		return nil
+	}
+	sessions, err := fetchPaged(0, 0, sessionsPageSize, fetch)
+	if err != nil {
+		return fmt.Errorf("failed listing offline sessions of client %q in realm %s: %w", o.clientID, realm, err)
+	}
+	if o.username != "" {
+		sessions = slices.DeleteFunc(sessions, func(s *gocloak.UserSessionRepresentation) bool { return gocloak.PString(s.Username) != o.username })
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, sessions)
+	}
+	lines := make([]string, 0, len(sessions)+1)
+	for _, s := range sessions {
+		lines = append(lines, fmt.Sprintf("%-20s  started %s  last used %s  from %s  (session %s)",
+			gocloak.PString(s.Username), formatEventTime(gocloak.PInt64(s.Start)), formatEventTime(gocloak.PInt64(s.LastAccess)), gocloak.PString(s.IPAddress), gocloak.PString(s.ID)))
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(sessions)))
+	printBox(cmd, lines, realm+" / "+o.clientID)
+	return nil
+}
+
+// sessionsOfflineRevokeOptions holds the flags of `kc sessions offline revoke`.
+type sessionsOfflineRevokeOptions struct {
+	usernames       []string
+	clientIDs       []string
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newSessionsOfflineRevokeCmd() *cobra.Command {
+	o := &sessionsOfflineRevokeOptions{}
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke the offline tokens of users",
+		Long: `Revoke the offline tokens of users, for the given clients or for every client
+holding one. This is the "Revoke" action of the user's Consents tab, so a
+consent given to those clients is withdrawn too.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "user", nil, "username whose offline tokens to revoke. Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client", nil, "only revoke the offline tokens of these client-ids. Repeatable")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "revoke offline tokens in all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *sessionsOfflineRevokeOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 {
+		return errors.New("missing --user: provide at least one --user")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		for _, un := range o.usernames {
+			if err := o.revokeUser(ctx, cmd, rep, gc, token, realm, un); err != nil {
+				if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "offlineSessions", Realm: realm, Name: un}, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Revoked: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// revokeUser revokes the offline tokens of user un, one report item per client.
+func (o *sessionsOfflineRevokeOptions) revokeUser(ctx context.Context, cmd *cobra.Command, rep *report, gc keycloak.API, token, realm, un string) error {
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+	}
+	if len(users) == 0 || users[0].ID == nil {
+		rep.skip(audit.ItemResult{Kind: "offlineSessions", Realm: realm, Name: un}, "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+		return nil
+	}
+	userID := *users[0].ID
+	consents, err := keycloak.GetUserConsents(ctx, gc, token, realm, userID)
+	if err != nil {
+		return fmt.Errorf("failed listing offline tokens of user %q in realm %s: %w", un, realm, err)
+	}
+	var clients []string
+	for _, c := range consents {
+		if c.HasOfflineTokens() && (len(o.clientIDs) == 0 || slices.Contains(o.clientIDs, c.ClientID)) {
+			clients = append(clients, c.ClientID)
+		}
+	}
+	for _, cid := range o.clientIDs {
+		if !slices.Contains(clients, cid) {
+			rep.skip(audit.ItemResult{Kind: "offlineSessions", Realm: realm, Name: un + "/" + cid, ID: userID}, "no offline tokens", fmt.Sprintf("User %q holds no offline tokens of client %q in realm %q. Skipped.", un, cid, realm))
+		}
+	}
+	if len(o.clientIDs) == 0 && len(clients) == 0 {
+		rep.skip(audit.ItemResult{Kind: "offlineSessions", Realm: realm, Name: un, ID: userID}, "no offline tokens", fmt.Sprintf("User %q holds no offline tokens in realm %q. Skipped.", un, realm))
+	}
+	for _, cid := range clients {
+		if err := gc.RevokeUserConsents(ctx, token, realm, userID, cid); err != nil {
+			return fmt.Errorf("failed revoking offline tokens of user %q for client %q in realm %s: %w", un, cid, realm, err)
+		}
+		recordChange(cmd, realm, un+"/"+cid, userID)
+		rep.add(kcops.Deleted, audit.ItemResult{Kind: "offlineSessions", Realm: realm, Name: un + "/" + cid, ID: userID},
+			fmt.Sprintf("Revoked offline tokens of user %q for client %q in realm %q.", un, cid, realm))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newSessionsCmd())
+}
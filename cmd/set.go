@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// addSetFlag adds --set to an update command, setting any field of the
+// representation of kind by its dotted path, so settings without a flag of
+// their own, e.g. of a new Keycloak version, can be changed.
+func addSetFlag(cmd *cobra.Command, p *[]string, kind string) {
+	cmd.Flags().StringArrayVar(p, "set", nil, fmt.Sprintf("set a field of the %s representation by dotted path, e.g. attributes.frontendUrl=https://login.example.org; JSON values for non-string fields. Repeatable", kind))
+}
+
+// isSensitivePath reports whether a --set path holds a secret, e.g.
+// smtpServer.password, by its last segment.
+func isSensitivePath(path string) bool {
+	return sensitiveFields[path[strings.LastIndex(path, ".")+1:]]
+}
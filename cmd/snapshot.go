@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/config"
+	"kc/internal/manifest"
+	"kc/internal/snapshot"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Capture and restore realm snapshots",
+	}
+	cmd.AddCommand(newSnapshotCreateCmd())
+	cmd.AddCommand(newSnapshotRestoreCmd())
+	return cmd
+}
+
+// snapshotCreateOptions holds the flags of `kc snapshot create`.
+type snapshotCreateOptions struct {
+	realm     string
+	out       string
+	withUsers bool
+}
+
+func newSnapshotCreateCmd() *cobra.Command {
+	o := &snapshotCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Capture realm settings, roles, client scopes, clients and groups into an archive",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.realm, "realm", "", "realm to capture. Required.")
+	cmd.Flags().StringVar(&o.out, "out", "", "archive to write (default snap-<realm>-<timestamp>.tar.gz)")
+	cmd.Flags().BoolVar(&o.withUsers, "with-users", false, "also capture users with their realm roles and groups (passwords are never captured)")
+	return cmd
+}
+
+func (o *snapshotCreateOptions) run(cmd *cobra.Command) error {
+	if o.realm == "" {
+		return errors.New("missing --realm: provide the realm to capture")
+	}
+	now := time.Now()
+	if o.out == "" {
+		o.out = fmt.Sprintf("snap-%s-%s.tar.gz", o.realm, now.Format("2006-01-02-150405"))
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	rep, err := gc.GetRealm(ctx, token, o.realm)
+	if err != nil {
+		return fmt.Errorf("failed fetching realm %s: %w", o.realm, err)
+	}
+	state, err := fetchRealmState(ctx, gc, token, o.realm, stateOptions{
+		Roles: true, ClientScopes: true, Clients: true, Groups: true,
+		Users: o.withUsers, AllUsers: true, DetailUsers: true,
+	})
+	if err != nil {
+		return err
+	}
+	state.StripBuiltins()
+
+	realmJSON, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestYAML, err := yaml.Marshal(manifest.State{Realms: []manifest.Realm{*state}})
+	if err != nil {
+		return err
+	}
+	err = snapshot.Write(o.out, &snapshot.Archive{
+		Meta: snapshot.Meta{
+			FormatVersion: snapshot.FormatVersion,
+			Realm:         o.realm,
+			ServerURL:     config.Global.ServerURL,
+			CreatedAt:     now.UTC(),
+			IncludesUsers: o.withUsers,
+		},
+		Files: map[string][]byte{snapshot.RealmFile: realmJSON, snapshot.ManifestFile: manifestYAML},
+	})
+	if err != nil {
+		return fmt.Errorf("failed writing snapshot %s: %w", o.out, err)
+	}
+
+	lines := []string{
+		fmt.Sprintf("Roles: %d, Client scopes: %d, Clients: %d, Groups: %d", len(state.Roles), len(state.ClientScopes), len(state.Clients), len(state.Groups)),
+	}
+	if o.withUsers {
+		lines = append(lines, fmt.Sprintf("Users: %d", len(state.Users)))
+	}
+	lines = append(lines, fmt.Sprintf("Done. Snapshot written to %s.", o.out))
+	printBox(cmd, lines, o.realm)
+	return nil
+}
+
+// snapshotRestoreOptions holds the flags of `kc snapshot restore`.
+type snapshotRestoreOptions struct {
+	in     string
+	target string
+	prune  bool
+	dryRun bool
+}
+
+func newSnapshotRestoreCmd() *cobra.Command {
+	o := &snapshotRestoreOptions{}
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Roll a realm back to the state captured in a snapshot",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-clients", "manage-realm")
+	cmd.Flags().StringVar(&o.in, "in", "", "snapshot archive to restore. Required.")
+	cmd.Flags().StringVar(&o.target, "realm", "", "realm to restore into (default: the captured realm)")
+	cmd.Flags().BoolVar(&o.prune, "prune", false, "delete entities created after the snapshot was taken")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "print the planned actions without changing anything")
+	return cmd
+}
+
+func (o *snapshotRestoreOptions) run(cmd *cobra.Command) error {
+	if o.in == "" {
+		return errors.New("missing --in: provide the snapshot archive")
+	}
+	arc, err := snapshot.Read(o.in)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", o.in, err)
+	}
+	state, err := manifest.Parse(arc.Files[snapshot.ManifestFile])
+	if err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", o.in, err)
+	}
+	desired := &state.Realms[0]
+	target := o.target
+	if target == "" {
+		target = arc.Meta.Realm
+	}
+	desired.Name = target
+
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, []string{target}); err != nil {
+		return err
+	}
+	actual, err := fetchRealmState(ctx, gc, token, target, stateOptionsFor(desired, o.prune))
+	if err != nil {
+		return err
+	}
+	actions := manifest.Diff(desired, actual, manifest.Options{Prune: o.prune})
+
+	rep := newReport(cmd)
+	rep.note(fmt.Sprintf("Snapshot of %s taken %s", arc.Meta.Realm, arc.Meta.CreatedAt.Local().Format("2006-01-02 15:04:05")))
+	if o.dryRun {
+		for _, a := range actions {
+			rep.note(a.String())
+		}
+		return rep.print(cmd, target, fmt.Sprintf("Dry run: %d action(s) planned, realm settings would be restored, nothing changed.", len(actions)))
+	}
+
+	for _, a := range actions {
+		if err := applyAction(ctx, gc, token, a, desired); err != nil {
+			return fmt.Errorf("failed to %s %s %q in realm %s: %w", a.Op, a.Kind, a.Name, a.Realm, err)
+		}
+		recordChangeAs(ctx, string(a.Kind)+"_"+string(a.Op), a.Realm, a.Name, "")
+		rep.addAction(a)
+	}
+	if b, ok := arc.Files[snapshot.RealmFile]; ok {
+		var realmRep gocloak.RealmRepresentation
+		if err := json.Unmarshal(b, &realmRep); err != nil {
+			return fmt.Errorf("invalid %s in snapshot: %w", snapshot.RealmFile, err)
+		}
+		// IDs are server generated and would not match a recreated realm.
+		realmRep.ID, realmRep.Realm, realmRep.DefaultRole = nil, &target, nil
+		realmRep.Clients, realmRep.ClientScopes, realmRep.Groups, realmRep.Roles, realmRep.Users = nil, nil, nil, nil, nil
+		if err := gc.UpdateRealm(ctx, token, realmRep); err != nil {
+			return fmt.Errorf("failed restoring settings of realm %s: %w", target, err)
+		}
+		recordChangeAs(ctx, "realm_update", target, target, "")
+		rep.add(kcops.Updated, audit.ItemResult{Kind: "realm", Realm: target, Name: target}, fmt.Sprintf("Restored settings of realm %s.", target))
+	}
+	return rep.print(cmd, target, fmt.Sprintf("Done. %d action(s) applied, realm settings restored.", len(actions)))
+}
+
+func init() {
+	rootCmd.AddCommand(newSnapshotCmd())
+}
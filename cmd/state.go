@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"kc/internal/keycloak"
+	"kc/internal/manifest"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+const statePageSize = 500
+
+// stateOptions selects which entity kinds fetchRealmState reads from the server.
+type stateOptions struct {
+	Roles        bool
+	ClientScopes bool
+	Clients      bool
+	Groups       bool
+	Users        bool
+	// AllUsers lists every user of the realm; otherwise only UserNames are read.
+	AllUsers  bool
+	UserNames []string
+	// DetailUsers reads realm roles and groups of every listed user, not
+	// only of those named in UserNames.
+	DetailUsers bool
+}
+
+// stateOptionsFor reads only the kinds a desired realm manages.
+func stateOptionsFor(desired *manifest.Realm, prune bool) stateOptions {
+	o := stateOptions{
+		Roles:        desired.Roles != nil,
+		ClientScopes: desired.ClientScopes != nil,
+		Clients:      desired.Clients != nil,
+		Groups:       desired.Groups != nil,
+		Users:        desired.Users != nil,
+		AllUsers:     prune,
+	}
+	for _, u := range desired.Users {
+		o.UserNames = append(o.UserNames, u.Username)
+	}
+	return o
+}
+
+// fetchRealmState reads the live state of a realm in manifest form. It returns
+// nil without error when the realm does not exist.
+func fetchRealmState(ctx context.Context, gc keycloak.API, token, realm string, opts stateOptions) (*manifest.Realm, error) {
+	rep, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "404") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	out := &manifest.Realm{Name: realm, Enabled: rep.Enabled, DisplayName: gocloak.PString(rep.DisplayName)}
+
+	if opts.Roles {
+		roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing roles in realm %s: %w", realm, err)
+		}
+		out.Roles = []manifest.Role{}
+		for _, r := range roles {
+			out.Roles = append(out.Roles, manifest.Role{Name: gocloak.PString(r.Name), Description: gocloak.PString(r.Description)})
+		}
+	}
+
+	if opts.ClientScopes {
+		scopes, err := gc.GetClientScopes(ctx, token, realm)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing client scopes in realm %s: %w", realm, err)
+		}
+		out.ClientScopes = []manifest.ClientScope{}
+		for _, s := range scopes {
+			out.ClientScopes = append(out.ClientScopes, manifest.ClientScope{Name: gocloak.PString(s.Name), Description: gocloak.PString(s.Description), Protocol: gocloak.PString(s.Protocol)})
+		}
+	}
+
+	if opts.Clients {
+		clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+		if err != nil {
+			return nil, fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+		}
+		out.Clients = []manifest.Client{}
+		for _, c := range clients {
+			out.Clients = append(out.Clients, clientToManifest(c))
+		}
+	}
+
+	if opts.Groups {
+		groups, err := listGroups(ctx, gc, token, realm)
+		if err != nil {
+			return nil, fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+		}
+		out.Groups = []manifest.Group{}
+		for _, g := range groups {
+			out.Groups = append(out.Groups, manifest.Group{Path: gocloak.PString(g.Path), RealmRoles: withoutDefaultRoles(realm, derefStrings(g.RealmRoles))})
+		}
+	}
+
+	if opts.Users {
+		var users []*gocloak.User
+		if opts.AllUsers {
+			users, err = fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+				return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+			}
+		} else {
+			for _, un := range opts.UserNames {
+				un := un
+				found, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+				if err != nil {
+					return nil, fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
+				}
+				users = append(users, found...)
+			}
+		}
+		detailed := map[string]bool{}
+		for _, un := range opts.UserNames {
+			detailed[un] = true
+		}
+		out.Users = []manifest.User{}
+		for _, u := range users {
+			mu := manifest.User{
+				Username:  gocloak.PString(u.Username),
+				Email:     gocloak.PString(u.Email),
+				FirstName: gocloak.PString(u.FirstName),
+				LastName:  gocloak.PString(u.LastName),
+				Enabled:   u.Enabled,
+			}
+			if (opts.DetailUsers || detailed[mu.Username]) && u.ID != nil {
+				roles, err := gc.GetRealmRolesByUserID(ctx, token, realm, *u.ID)
+				if err != nil {
+					return nil, fmt.Errorf("failed reading roles of user %q in realm %s: %w", mu.Username, realm, err)
+				}
+				for _, r := range roles {
+					mu.RealmRoles = append(mu.RealmRoles, gocloak.PString(r.Name))
+				}
+				mu.RealmRoles = withoutDefaultRoles(realm, mu.RealmRoles)
+				groups, err := gc.GetUserGroups(ctx, token, realm, *u.ID, gocloak.GetGroupsParams{})
+				if err != nil {
+					return nil, fmt.Errorf("failed reading groups of user %q in realm %s: %w", mu.Username, realm, err)
+				}
+				for _, g := range groups {
+					mu.Groups = append(mu.Groups, gocloak.PString(g.Path))
+				}
+			}
+			out.Users = append(out.Users, mu)
+		}
+	}
+	return out, nil
+}
+
+func clientToManifest(c *gocloak.Client) manifest.Client {
+	return manifest.Client{
+		ClientID:                  gocloak.PString(c.ClientID),
+		Name:                      gocloak.PString(c.Name),
+		Enabled:                   c.Enabled,
+		PublicClient:              c.PublicClient,
+		Protocol:                  gocloak.PString(c.Protocol),
+		RootURL:                   gocloak.PString(c.RootURL),
+		BaseURL:                   gocloak.PString(c.BaseURL),
+		RedirectURIs:              derefStrings(c.RedirectURIs),
+		WebOrigins:                derefStrings(c.WebOrigins),
+		StandardFlowEnabled:       c.StandardFlowEnabled,
+		DirectAccessGrantsEnabled: c.DirectAccessGrantsEnabled,
+		ImplicitFlowEnabled:       c.ImplicitFlowEnabled,
+		ServiceAccountsEnabled:    c.ServiceAccountsEnabled,
+		DefaultClientScopes:       derefStrings(c.DefaultClientScopes),
+		OptionalClientScopes:      derefStrings(c.OptionalClientScopes),
+	}
+}
+
+// listGroups returns every group of a realm, depth first, with paths and realm roles.
+// Keycloak 23+ no longer inlines subgroups, so children are fetched explicitly.
+func listGroups(ctx context.Context, gc keycloak.API, token, realm string) ([]*gocloak.Group, error) {
+	top, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.Group, error) {
+		return gc.GetGroups(ctx, token, realm, gocloak.GetGroupsParams{First: &first, Max: &max, BriefRepresentation: gocloak.BoolP(false)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Group
+	var walk func(gs []*gocloak.Group) error
+	walk = func(gs []*gocloak.Group) error {
+		sort.Slice(gs, func(i, j int) bool { return gocloak.PString(gs[i].Path) < gocloak.PString(gs[j].Path) })
+		for _, g := range gs {
+			out = append(out, g)
+			var children []*gocloak.Group
+			if g.SubGroups != nil && len(*g.SubGroups) > 0 {
+				for i := range *g.SubGroups {
+					children = append(children, &(*g.SubGroups)[i])
+				}
+			} else if g.ID != nil {
+				q := url.Values{"briefRepresentation": {"false"}, "max": {"1000"}}
+				if err := gc.Do(ctx, token, http.MethodGet, keycloak.AdminURL(realm, "groups", *g.ID, "children"), q, nil, &children); err != nil {
+					// older servers inline subgroups and have no children endpoint
+					if !strings.Contains(err.Error(), "404") && !strings.Contains(err.Error(), "405") {
+						return err
+					}
+				}
+			}
+			if err := walk(children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(top); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func derefStrings(p *[]string) []string {
+	if p == nil {
+		return nil
+	}
+	return append([]string{}, *p...)
+}
+
+func withoutDefaultRoles(realm string, roles []string) []string {
+	var out []string
+	for _, r := range roles {
+		if r != "default-roles-"+realm {
+			out = append(out, r)
+		}
+	}
+	return out
+}
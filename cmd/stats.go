@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// statsEnabled is set by the global --stats flag.
+	statsEnabled     bool
+	statsPushgateway string
+	statsStatsd      string
+)
+
+// commandStats are the metrics of one command run.
+type commandStats struct {
+	keycloak.Stats
+	kind     string
+	ok       bool
+	duration time.Duration
+	end      time.Time
+}
+
+// reportStats prints the API metrics of the command with --stats and sends
+// them to the configured Pushgateway and statsd. Failing to send them is
+// only a warning: the command itself has already run.
+func reportStats(cmd *cobra.Command, status string, end time.Time, dur time.Duration) {
+	s := commandStats{
+		Stats:    keycloak.CurrentStats(),
+		kind:     resolveChangeKind(cmd.CommandPath()),
+		ok:       status == "ok",
+		duration: dur,
+		end:      end,
+	}
+	if statsEnabled {
+		fmt.Fprintf(cmd.ErrOrStderr(), "[%s] STATS: api_calls=%d api_time=%s retries=%d rate_limit_waits=%d rate_limit_wait=%s\n",
+			end.Format(time.RFC3339), s.Calls, s.APITime.Round(time.Millisecond), s.Retries, s.RateLimitWaits, s.RateLimitTime.Round(time.Millisecond))
+	}
+	gateway, statsd := config.Global.StatsPushgateway, config.Global.StatsStatsd
+	if cmd.Flags().Changed("stats-pushgateway") {
+		gateway = statsPushgateway
+	}
+	if cmd.Flags().Changed("stats-statsd") {
+		statsd = statsStatsd
+	}
+	if gateway != "" {
+		if err := pushStats(gateway, s); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed pushing metrics to %s: %v\n", gateway, err)
+		}
+	}
+	if statsd != "" {
+		if err := sendStatsd(statsd, s); err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed sending metrics to statsd %s: %v\n", statsd, err)
+		}
+	}
+}
+
+// pushStats replaces the metrics of the command's group on a Prometheus
+// Pushgateway, grouped by job kc and the change kind of the command.
+func pushStats(gateway string, s commandStats) error {
+	ok := 0
+	if s.ok {
+		ok = 1
+	}
+	var b strings.Builder
+	metric := func(name, help string, v any) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, v)
+	}
+	metric("kc_api_calls", "Admin API requests of the last run, retries included.", s.Calls)
+	metric("kc_api_time_seconds", "Time spent waiting for admin API responses in the last run.", s.APITime.Seconds())
+	metric("kc_api_retries", "Admin API requests retried after 429 or 503 in the last run.", s.Retries)
+	metric("kc_rate_limit_waits", "Admin API requests delayed by the client-side rate limit in the last run.", s.RateLimitWaits)
+	metric("kc_rate_limit_wait_seconds", "Time spent waiting for the client-side rate limit in the last run.", s.RateLimitTime.Seconds())
+	metric("kc_command_duration_seconds", "Duration of the last run.", s.duration.Seconds())
+	metric("kc_command_success", "Whether the last run succeeded (1) or failed (0).", ok)
+	metric("kc_command_last_run_timestamp_seconds", "End of the last run, in Unix seconds.", s.end.Unix())
+
+	target := strings.TrimRight(gateway, "/") + "/metrics/job/kc/command/" + url.PathEscape(s.kind)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, strings.NewReader(b.String()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway answered %s", resp.Status)
+	}
+	return nil
+}
+
+// sendStatsd sends the metrics as statsd counters and timers named
+// kc.<change kind>.<metric>, in one UDP packet.
+func sendStatsd(addr string, s commandStats) error {
+	prefix := "kc." + s.kind + "."
+	result := "error"
+	if s.ok {
+		result = "ok"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%sapi_calls:%d|c\n", prefix, s.Calls)
+	fmt.Fprintf(&b, "%sapi_time:%d|ms\n", prefix, s.APITime.Milliseconds())
+	fmt.Fprintf(&b, "%sretries:%d|c\n", prefix, s.Retries)
+	fmt.Fprintf(&b, "%srate_limit_waits:%d|c\n", prefix, s.RateLimitWaits)
+	fmt.Fprintf(&b, "%srate_limit_wait:%d|ms\n", prefix, s.RateLimitTime.Milliseconds())
+	fmt.Fprintf(&b, "%sduration:%d|ms\n", prefix, s.duration.Milliseconds())
+	fmt.Fprintf(&b, "%sruns.%s:1|c", prefix, result)
+	conn, err := net.DialTimeout("udp", addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write(b.Bytes())
+	return err
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&statsEnabled, "stats", false, "print admin API metrics (calls, API time, retries, rate-limit waits) when the command ends")
+	rootCmd.PersistentFlags().StringVar(&statsPushgateway, "stats-pushgateway", "", "Prometheus Pushgateway URL to push the metrics of the command to (overrides stats_pushgateway in config.json)")
+	rootCmd.PersistentFlags().StringVar(&statsStatsd, "stats-statsd", "", "statsd host:port to send the metrics of the command to (overrides stats_statsd in config.json)")
+}
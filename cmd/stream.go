@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+
+	"github.com/spf13/cobra"
+)
+
+// ndjsonAnnotation marks the commands that support --output ndjson.
+const ndjsonAnnotation = "kc/ndjson"
+
+// streaming marks cmd as writing one JSON object per line with --output
+// ndjson, as soon as each page of results is read.
+func streaming(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[ndjsonAnnotation] = "true"
+}
+
+// isStreaming reports whether cmd supports --output ndjson.
+func isStreaming(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[ndjsonAnnotation]
+	return ok
+}
+
+// printNDJSON writes v as one line of JSON to the command output.
+func printNDJSON(cmd *cobra.Command, v interface{}) error {
+	return json.NewEncoder(cmd.OutOrStdout()).Encode(v)
+}
+
+// streamPaged calls fetch page by page like fetchPaged, but passes each item
+// to emit before reading the next page instead of collecting them, so memory
+// stays flat however many items there are. It returns how many were read.
+func streamPaged[T any](first, limit, pageSize int, fetch func(first, max int) ([]T, error), emit func(T) error) (int, error) {
+	n := 0
+	for limit <= 0 || n < limit {
+		size := pageSize
+		if limit > 0 && limit-n < size {
+			size = limit - n
+		}
+		page, err := fetch(first, size)
+		if err != nil {
+			return n, err
+		}
+		for _, item := range page {
+			if err := emit(item); err != nil {
+				return n, err
+			}
+		}
+		n += len(page)
+		if len(page) < size {
+			break
+		}
+		first += len(page)
+	}
+	return n, nil
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// templateOptions holds --template and --var of create commands.
+type templateOptions struct {
+	file string
+	vars []string
+}
+
+// templateFuncs are available in --template files besides the text/template builtins.
+var templateFuncs = template.FuncMap{
+	"split": func(s, sep string) []string { return strings.Split(s, sep) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// addTemplateFlags registers --template and --var, bound to o.
+func addTemplateFlags(cmd *cobra.Command, o *templateOptions) {
+	cmd.Flags().StringVar(&o.file, "template", "", "Go template rendering the JSON of the entity (or a list of them) to create, instead of the per-entity flags")
+	cmd.Flags().StringArrayVar(&o.vars, "var", nil, "template variable as key=value, available as {{.key}}. Repeatable.")
+}
+
+// templateSpecs renders the --template of o with its --var values and decodes the
+// result into specs, accepting one JSON object or an array of them. It
+// returns nil when --template is not set. key returns the field every spec
+// must set, named keyField in errors, and entityFlags are the per-entity
+// flags the template replaces.
+func templateSpecs[T any](cmd *cobra.Command, o templateOptions, keyField string, key func(T) string, entityFlags ...string) ([]T, error) {
+	if o.file == "" {
+		if len(o.vars) > 0 {
+			return nil, errors.New("--var requires --template")
+		}
+		return nil, nil
+	}
+	for _, name := range append(entityFlags, "interactive") {
+		if cmd.Flags().Changed(name) {
+			return nil, fmt.Errorf("--template cannot be combined with --%s", name)
+		}
+	}
+	vars := map[string]string{}
+	for _, kv := range o.vars {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --var %q: expected key=value", kv)
+		}
+		vars[k] = v
+	}
+
+	src, err := os.ReadFile(o.file)
+	if err != nil {
+		return nil, err
+	}
+	// a variable used but not passed is an error, not an empty string
+	t, err := template.New(o.file).Funcs(templateFuncs).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, vars); err != nil {
+		return nil, fmt.Errorf("failed rendering template: %w", err)
+	}
+
+	rendered := bytes.TrimSpace(out.Bytes())
+	var specs []T
+	dec := json.NewDecoder(bytes.NewReader(rendered))
+	dec.DisallowUnknownFields()
+	if bytes.HasPrefix(rendered, []byte("[")) {
+		err = dec.Decode(&specs)
+	} else {
+		var spec T
+		err = dec.Decode(&spec)
+		specs = []T{spec}
+	}
+	// the rendered JSON is not quoted in errors as it may hold passwords
+	if err != nil {
+		return nil, fmt.Errorf("template %s did not render valid JSON: %w", o.file, err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("template %s rendered no entities", o.file)
+	}
+	for i, s := range specs {
+		if key(s) == "" {
+			return nil, fmt.Errorf("template %s: entity %d has no %s", o.file, i+1, keyField)
+		}
+	}
+	return specs, nil
+}
+
+// realmPlaceholder in the values of create commands stands for the name of
+// each target realm, e.g. --redirect-uri "https://{realm}.example.org/cb", so
+// per-tenant values need no per-realm invocation.
+const realmPlaceholder = "{realm}"
+
+// forRealm returns specs with realmPlaceholder replaced by realm in every
+// string value. specs is returned as is when no value holds the placeholder.
+func forRealm[T any](specs []T, realm string) ([]T, error) {
+	data, err := json.Marshal(specs)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(data, []byte(realmPlaceholder)) {
+		return specs, nil
+	}
+	name, _ := json.Marshal(realm)
+	data = bytes.ReplaceAll(data, []byte(realmPlaceholder), name[1:len(name)-1])
+	var out []T
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed replacing %s with realm %s: %w", realmPlaceholder, realm, err)
+	}
+	return out, nil
+}
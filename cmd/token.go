@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/config"
+	"kc/internal/keycloak"
+
+	"github.com/spf13/cobra"
+)
+
+// timeClaims are the NumericDate claims shown with their date.
+var timeClaims = []string{"exp", "iat", "nbf", "auth_time"}
+
+func newTokenCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Inspect access, refresh and ID tokens",
+	}
+	cmd.AddCommand(newTokenDecodeCmd())
+	cmd.AddCommand(newTokenIntrospectCmd())
+	cmd.AddCommand(newTokenGetCmd())
+	return cmd
+}
+
+// clientSecretFor returns the secret given for clientID, or client_secret
+// from config.json when clientID is the configured client.
+func clientSecretFor(clientID, secret string) string {
+	if secret == "" && clientID == config.Global.ClientID {
+		return config.Global.ClientSecret
+	}
+	return secret
+}
+
+// readTokenArg returns the token given as value, or read from stdin when
+// value is empty or "-".
+func readTokenArg(cmd *cobra.Command, value string) (string, error) {
+	if value != "" && value != "-" {
+		return strings.TrimSpace(value), nil
+	}
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", errors.New("no token given: pass it as argument or on stdin")
+	}
+	return token, nil
+}
+
+// claimLines renders claims sorted by name, one per line, with the dates of
+// time claims.
+func claimLines(claims map[string]interface{}, indent string) []string {
+	var lines []string
+	for _, k := range slices.Sorted(maps.Keys(claims)) {
+		v := claims[k]
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case float64:
+			s = fmt.Sprint(int64(v))
+			if v != float64(int64(v)) {
+				s = fmt.Sprint(v)
+			}
+			if slices.Contains(timeClaims, k) {
+				s += "  (" + time.Unix(int64(v), 0).UTC().Format(time.RFC3339) + ")"
+			}
+		default:
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		lines = append(lines, indent+k+": "+s)
+	}
+	return lines
+}
+
+// describeTokenExpiry tells whether a token with the given exp claim is still
+// valid at now.
+func describeTokenExpiry(exp time.Time, ok bool, now time.Time) string {
+	if !ok {
+		return "no exp claim; never expires"
+	}
+	left := exp.Sub(now).Round(time.Second)
+	if left <= 0 {
+		return fmt.Sprintf("expired %s ago", -left)
+	}
+	return fmt.Sprintf("valid for another %s", left)
+}
+
+// issuerRealm returns the realm named by the iss claim of a Keycloak token.
+func issuerRealm(t *keycloak.JWT) string {
+	iss, _ := t.Claims["iss"].(string)
+	if _, realm, ok := strings.Cut(iss, "/realms/"); ok {
+		return realm
+	}
+	return "unknown issuer " + iss
+}
+
+// tokenDecodeOutput is the --output json form of `kc token decode`.
+type tokenDecodeOutput struct {
+	*keycloak.JWT
+	ExpiresAt string `json:"expiresAt,omitempty"`
+	Expired   bool   `json:"expired"`
+}
+
+func newTokenDecodeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decode [jwt]",
+		Short: "Decode a JWT offline and show its header, claims and expiry",
+		Long: `Decode a JWT offline and show its header, claims and expiry. The
+signature is not verified; use "kc token introspect" to ask the server whether
+a token is valid. Without an argument, or with "-", the token is read from stdin.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			var arg string
+			if len(args) == 1 {
+				arg = args[0]
+			}
+			raw, err := readTokenArg(cmd, arg)
+			if err != nil {
+				return err
+			}
+			t, err := keycloak.DecodeJWT(raw)
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			exp, hasExp := t.Time("exp")
+			if outputFormat == "json" {
+				out := tokenDecodeOutput{JWT: t, Expired: hasExp && !exp.After(now)}
+				if hasExp {
+					out.ExpiresAt = exp.UTC().Format(time.RFC3339)
+				}
+				return printJSON(cmd, out)
+			}
+			lines := []string{"Header:"}
+			lines = append(lines, claimLines(t.Header, "  ")...)
+			lines = append(lines, "Claims:")
+			lines = append(lines, claimLines(t.Claims, "  ")...)
+			lines = append(lines, "Expiry: "+describeTokenExpiry(exp, hasExp, now))
+			printBox(cmd, lines, issuerRealm(t))
+			return nil
+		}),
+	}
+	return cmd
+}
+
+// tokenIntrospectOptions holds the flags of `kc token introspect`.
+type tokenIntrospectOptions struct {
+	realm        string
+	clientID     string
+	clientSecret string
+	token        string
+}
+
+func newTokenIntrospectCmd() *cobra.Command {
+	o := &tokenIntrospectOptions{}
+	cmd := &cobra.Command{
+		Use:   "introspect",
+		Short: "Ask the realm whether a token is active",
+		Long: `Ask the introspection endpoint of the realm whether a token is active and
+show its claims as the server sees them. The request authenticates as a
+confidential client of that realm; its secret defaults to client_secret from
+config.json when --client-id is the configured client_id.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "client-id of the confidential client that introspects (required)")
+	cmd.Flags().StringVar(&o.clientSecret, "client-secret", "", "secret of --client-id")
+	cmd.Flags().StringVar(&o.token, "token", "", `token to introspect; "-" reads it from stdin (required)`)
+	cmd.Flags().StringVar(&o.realm, "realm", "", "realm that issued the token")
+	return cmd
+}
+
+func (o *tokenIntrospectOptions) run(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client-id: the introspecting client is required")
+	}
+	if o.token == "" {
+		return errors.New(`missing --token: pass the token, or "-" to read it from stdin`)
+	}
+	secret := clientSecretFor(o.clientID, o.clientSecret)
+	if secret == "" {
+		return errors.New("missing --client-secret: introspection requires a confidential client")
+	}
+	token, err := readTokenArg(cmd, o.token)
+	if err != nil {
+		return err
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	res, err := keycloak.Introspect(ctx, realm, o.clientID, secret, token)
+	if err != nil {
+		return fmt.Errorf("failed introspecting token in realm %s: %w", realm, err)
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, res)
+	}
+	active, _ := res["active"].(bool)
+	lines := []string{fmt.Sprintf("Active: %t", active)}
+	if !active {
+		lines = append(lines, "The token is expired, revoked, malformed or was issued by another realm.")
+	} else {
+		delete(res, "active")
+		lines = append(lines, claimLines(res, "  ")...)
+	}
+	printBox(cmd, lines, realm)
+	return nil
+}
+
+// tokenGetOptions holds the flags of `kc token get`.
+type tokenGetOptions struct {
+	realm        string
+	grant        string
+	clientID     string
+	clientSecret string
+	username     string
+	password     string
+	scopes       []string
+	raw          bool
+}
+
+func newTokenGetCmd() *cobra.Command {
+	o := &tokenGetOptions{}
+	cmd := &cobra.Command{
+		Use:   "get",
+		Short: "Obtain tokens as a test user or as a client",
+		Long: `Obtain access, refresh and ID tokens from a realm, as a user with
+--grant password or as a service account with --grant client_credentials, for
+testing APIs protected by that realm. The password grant requires Direct
+Access Grants on the client.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.grant, "grant", "password", "grant type: password|client_credentials")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "client-id the tokens are issued to (required)")
+	cmd.Flags().StringVar(&o.clientSecret, "client-secret", "", "client secret; leave empty for public clients")
+	cmd.Flags().StringVar(&o.username, "username", "", "user to log in as (password grant)")
+	cmd.Flags().StringVar(&o.password, "password", "", `password of --username; "-" reads it from stdin (password grant)`)
+	cmd.Flags().StringSliceVar(&o.scopes, "scope", nil, "extra scope(s) to request, e.g. offline_access. Repeatable")
+	cmd.Flags().BoolVar(&o.raw, "raw", false, "print only the access token, e.g. to pipe into `kc token decode`")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "realm to obtain the tokens from")
+	return cmd
+}
+
+func (o *tokenGetOptions) run(cmd *cobra.Command) error {
+	if o.clientID == "" {
+		return errors.New("missing --client-id: the client the tokens are issued to is required")
+	}
+	req := keycloak.TokenRequest{
+		GrantType:    o.grant,
+		ClientID:     o.clientID,
+		ClientSecret: clientSecretFor(o.clientID, o.clientSecret),
+		Scope:        strings.Join(o.scopes, " "),
+	}
+	switch o.grant {
+	case "password":
+		if o.username == "" {
+			return errors.New("missing --username: the password grant logs in as a user")
+		}
+		if o.password == "" {
+			return errors.New(`missing --password: pass it, or "-" to read it from stdin`)
+		}
+		password := o.password
+		if password == "-" {
+			data, err := io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+			addSecret(cmd.Context(), password)
+		}
+		req.Username, req.Password = o.username, password
+	case "client_credentials":
+		if req.ClientSecret == "" {
+			return errors.New("missing --client-secret: the client_credentials grant requires a confidential client")
+		}
+	default:
+		return fmt.Errorf("invalid --grant %q: use password or client_credentials", o.grant)
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	req.Realm = realm
+	ctx, cancel := commandContext(cmd, 30*time.Second)
+	defer cancel()
+	tok, err := keycloak.GetToken(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed obtaining tokens from realm %s: %w", realm, err)
+	}
+	// shown on the terminal to be copied, kept out of the log
+	addSecret(cmd.Context(), tok.AccessToken, tok.RefreshToken, tok.IDToken)
+
+	out := cmd.OutOrStdout()
+	switch {
+	case o.raw:
+		fmt.Fprintln(out, tok.AccessToken)
+		return nil
+	case outputFormat == "json":
+		return printJSON(cmd, tok)
+	}
+	subject := "client " + o.clientID
+	if o.grant == "password" {
+		subject = "user " + o.username + " via " + o.clientID
+	}
+	lines := []string{
+		fmt.Sprintf("Issued to %s (grant %s).", subject, o.grant),
+		fmt.Sprintf("Scope: %s", tok.Scope),
+		fmt.Sprintf("Access token expires in %ds, refresh token in %ds.", tok.ExpiresIn, tok.RefreshExpiresIn),
+	}
+	printBox(cmd, lines, realm)
+	// plain lines, so the tokens can be copied or eval'd
+	fmt.Fprintf(out, "ACCESS_TOKEN=%s\n", tok.AccessToken)
+	if tok.RefreshToken != "" {
+		fmt.Fprintf(out, "REFRESH_TOKEN=%s\n", tok.RefreshToken)
+	}
+	if tok.IDToken != "" {
+		fmt.Fprintf(out, "ID_TOKEN=%s\n", tok.IDToken)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(newTokenCmd())
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/spf13/cobra"
+)
+
+// tracerProvider exports the spans of the command; nil when tracing is off.
+var tracerProvider *sdktrace.TracerProvider
+
+// tracingConfigured reports whether the OTEL_* environment names an OTLP
+// endpoint to send traces to.
+func tracingConfigured() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// startTracing registers an OTLP trace exporter configured by the standard
+// OTEL_* environment variables and starts the span of the command, returning
+// ctx carrying it. Without an endpoint it returns ctx unchanged.
+func startTracing(ctx context.Context, cmd *cobra.Command) (context.Context, error) {
+	if !tracingConfigured() {
+		return ctx, nil
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	var exporter *otlptrace.Exporter
+	var err error
+	switch protocol {
+	case "", "http/protobuf":
+		exporter, err = otlptracehttp.New(ctx)
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return ctx, fmt.Errorf("unsupported OTEL_EXPORTER_OTLP_PROTOCOL %q: must be http/protobuf or grpc", protocol)
+	}
+	if err != nil {
+		return ctx, fmt.Errorf("failed setting up the OTLP trace exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("kc")))
+	if err == nil {
+		res, err = resource.Merge(res, resource.Environment())
+	}
+	if err != nil {
+		return ctx, fmt.Errorf("invalid OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	// the raw command line may hold secrets, so only the command is recorded
+	attrs := []attribute.KeyValue{
+		attribute.String("kc.command", cmd.CommandPath()),
+		attribute.String("kc.change_kind", resolveChangeKind(cmd.CommandPath())),
+	}
+	if jira := cmdState(cmd).jira; jira != "" {
+		attrs = append(attrs, attribute.String("kc.jira", jira))
+	}
+	ctx, _ = otel.Tracer("kc/cmd").Start(ctx, cmd.CommandPath(), trace.WithAttributes(attrs...))
+	return ctx, nil
+}
+
+// endTracing ends the span of the command with its outcome and flushes the
+// spans not exported yet. A failed export is only a warning.
+func endTracing(cmd *cobra.Command, err error) {
+	if tracerProvider == nil {
+		return
+	}
+	span := trace.SpanFromContext(cmd.Context())
+	if realm := resolveTargetRealms(); realm != "" {
+		span.SetAttributes(attribute.String("kc.realm", realm))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed exporting traces: %v\n", err)
+	}
+	tracerProvider = nil
+}
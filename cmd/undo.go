@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// undoOptions holds the flags of `kc undo`.
+type undoOptions struct {
+	auditID string
+	dryRun  bool
+}
+
+func newUndoCmd() *cobra.Command {
+	o := &undoOptions{}
+	cmd := &cobra.Command{
+		Use:   "undo",
+		Short: "Revert the updates and deletes recorded in an audit entry",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users", "manage-clients", "manage-realm")
+	cmd.Flags().StringVar(&o.auditID, "audit-id", "", "ID of the audit entry to revert (see `kc audit list`). Required.")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "show what would be reverted without changing anything")
+	return cmd
+}
+
+func (o *undoOptions) run(cmd *cobra.Command) error {
+	if o.auditID == "" {
+		return errors.New("missing --audit-id: see `kc audit list` for entry IDs")
+	}
+	files, err := audit.Files()
+	if err != nil {
+		return err
+	}
+	var entries []audit.Entry
+	for _, f := range files {
+		read, err := audit.Read(f)
+		if err != nil {
+			if f != audit.Path() && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed reading audit file %s: %w", f, err)
+		}
+		entries = append(entries, read...)
+	}
+	var entry *audit.Entry
+	for i := range entries {
+		if entries[i].ID == o.auditID {
+			entry = &entries[i]
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("audit entry %q not found in %s", o.auditID, audit.Path())
+	}
+	if len(entry.Details.Changes) == 0 {
+		return fmt.Errorf("audit entry %q recorded no changes", o.auditID)
+	}
+
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	var gc keycloak.API
+	var token string
+	if !o.dryRun {
+		if gc, token, err = connect(ctx); err != nil {
+			return err
+		}
+		var realms []string
+		for _, c := range entry.Details.Changes {
+			if !slices.Contains(realms, c.Realm) {
+				realms = append(realms, c.Realm)
+			}
+		}
+		if err := preflight(ctx, cmd, gc, token, realms); err != nil {
+			return err
+		}
+	}
+
+	rep := newReport(cmd)
+	rep.note(fmt.Sprintf("Undoing %s (%s, %s)", entry.ID, entry.ChangeKind, entry.Timestamp.Local().Format("2006-01-02 15:04:05")))
+	// newest change first, so renames and deletes unwind in order
+	for i := len(entry.Details.Changes) - 1; i >= 0; i-- {
+		c := entry.Details.Changes[i]
+		item := undoItem(c)
+		if len(c.Before) == 0 {
+			rep.skip(item, "no previous state recorded", fmt.Sprintf("Skipped %s: no previous state recorded.", formatChangeLine(c)))
+			continue
+		}
+		if o.dryRun {
+			rep.note("Would revert " + formatChangeLine(c))
+			continue
+		}
+		note, err := undoChange(ctx, gc, token, c)
+		opsClient(gc, token).Invalidate(c.Realm)
+		if err != nil {
+			return fmt.Errorf("failed reverting %s: %w", formatChangeLine(c), err)
+		}
+		recordChangeAs(ctx, c.Kind+"_undo", c.Realm, c.Entity, c.ID, audit.FieldChange{Field: "audit_id", New: entry.ID})
+		line := "Reverted " + formatChangeLine(c)
+		if note != "" {
+			line += " (" + note + ")"
+		}
+		// reverting a delete recreates the entity
+		outcome := kcops.Updated
+		if strings.HasSuffix(c.Kind, "_delete") {
+			outcome = kcops.Created
+		}
+		rep.add(outcome, item, line)
+	}
+	skipped := len(rep.result.Skipped)
+	if o.dryRun {
+		return rep.print(cmd, entry.TargetRealms, fmt.Sprintf("Dry run: %d change(s) can be reverted, %d skipped.", len(entry.Details.Changes)-skipped, skipped))
+	}
+	return rep.print(cmd, entry.TargetRealms, fmt.Sprintf("Done. Reverted: %d, Skipped: %d.", len(rep.result.Created)+len(rep.result.Updated), skipped))
+}
+
+// undoItem describes the entity of a recorded change, e.g. kind "user" for
+// users_update.
+func undoItem(c audit.Change) audit.ItemResult {
+	kind := c.Kind
+	if i := strings.LastIndex(kind, "_"); i > 0 {
+		kind = kind[:i]
+	}
+	kind = map[string]string{"users": "user", "roles": "role", "clients": "client", "clients_keys": "client", "client_scopes": "clientScope", "groups": "group", "realms": "realm"}[kind]
+	if kind == "" {
+		kind = c.Kind
+	}
+	return audit.ItemResult{Kind: kind, Realm: c.Realm, Name: c.Entity, ID: c.ID}
+}
+
+// undoChange restores the representation captured before an update or delete.
+// The returned note describes what could not be restored.
+func undoChange(ctx context.Context, gc keycloak.API, token string, c audit.Change) (string, error) {
+	realm := c.Realm
+	switch c.Kind {
+	case "users_update", "users_disable", "users_set_enabled", "users_dedupe", "users_delete":
+		var u gocloak.User
+		if err := json.Unmarshal(c.Before, &u); err != nil {
+			return "", err
+		}
+		if c.Kind != "users_delete" {
+			note := ""
+			for _, f := range c.Fields {
+				if f.Field == "password" {
+					note = "password not reverted"
+				}
+			}
+			return note, gc.UpdateUser(ctx, token, realm, u)
+		}
+		u.ID = nil
+		_, err := gc.CreateUser(ctx, token, realm, u)
+		return "recreated with a new ID; credentials, roles and groups are not restored", err
+
+	case "users_offboard":
+		var u gocloak.User
+		if err := json.Unmarshal(c.Before, &u); err != nil {
+			return "", err
+		}
+		if slices.ContainsFunc(c.Fields, func(f audit.FieldChange) bool { return f.Field == "deleted" }) {
+			u.ID = nil
+			_, err := gc.CreateUser(ctx, token, realm, u)
+			return "recreated with a new ID; credentials, roles, groups, sessions and consents are not restored", err
+		}
+		return "sessions and consents are not restored", gc.UpdateUser(ctx, token, realm, u)
+
+	case "roles_update", "roles_delete":
+		var r gocloak.Role
+		if err := json.Unmarshal(c.Before, &r); err != nil {
+			return "", err
+		}
+		if c.Kind == "roles_update" {
+			current := gocloak.PString(r.Name)
+			for _, f := range c.Fields {
+				if f.Field == "name" {
+					current = f.New
+				}
+			}
+			return "", gc.UpdateRealmRole(ctx, token, realm, current, r)
+		}
+		r.ID = nil
+		_, err := gc.CreateRealmRole(ctx, token, realm, r)
+		return "role mappings are not restored", err
+
+	case "client_scopes_update":
+		var s gocloak.ClientScope
+		if err := json.Unmarshal(c.Before, &s); err != nil {
+			return "", err
+		}
+		return "", gc.UpdateClientScope(ctx, token, realm, s)
+
+	case "client_scopes_delete":
+		var s gocloak.ClientScope
+		if err := json.Unmarshal(c.Before, &s); err != nil {
+			return "", err
+		}
+		s.ID = nil
+		_, err := gc.CreateClientScope(ctx, token, realm, s)
+		return "client assignments are not restored", err
+
+	case "clients_update", "clients_keys_upload", "clients_keys_rotate":
+		var cl gocloak.Client
+		if err := json.Unmarshal(c.Before, &cl); err != nil {
+			return "", err
+		}
+		// the secret is not recorded; keep the current one
+		return "", gc.UpdateClient(ctx, token, realm, cl)
+
+	case "realms_update":
+		var r gocloak.RealmRepresentation
+		if err := json.Unmarshal(c.Before, &r); err != nil {
+			return "", err
+		}
+		// Keycloak ignores the fields a realm update leaves out, so fields
+		// unset before are emptied, or left as they are when not text
+		var kept []string
+		for _, f := range c.Fields {
+			if f.Old != "" {
+				continue
+			}
+			if _, err := kcops.ApplyFieldSets(&r, []kcops.FieldSet{{Path: f.Field, Value: ""}}); err != nil {
+				kept = append(kept, f.Field)
+			}
+		}
+		note := ""
+		if len(kept) > 0 {
+			note = "not unset: " + strings.Join(kept, ", ")
+		}
+		return note, gc.UpdateRealm(ctx, token, r)
+
+	case "groups_move", "groups_rename":
+		var g gocloak.Group
+		if err := json.Unmarshal(c.Before, &g); err != nil {
+			return "", err
+		}
+		current := gocloak.PString(g.Path)
+		for _, f := range c.Fields {
+			if f.Field == "path" {
+				current = f.New
+			}
+		}
+		return "", moveGroup(ctx, gc, token, realm, gocloak.PString(g.ID), current, gocloak.PString(g.Path))
+
+	case "clients_delete":
+		var cl gocloak.Client
+		if err := json.Unmarshal(c.Before, &cl); err != nil {
+			return "", err
+		}
+		cl.ID = nil
+		_, err := gc.CreateClient(ctx, token, realm, cl)
+		return "recreated with a new secret", err
+	}
+	return "", fmt.Errorf("change kind %s cannot be undone", c.Kind)
+}
+
+func init() {
+	rootCmd.AddCommand(newUndoCmd())
+}
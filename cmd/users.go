@@ -1,588 +1,730 @@
 package cmd
 
 import (
-	"context"
-	"crypto/rand"
+	"encoding/csv"
 	"errors"
 	"fmt"
-	"math/big"
+	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
-	"kc/internal/config"
 	"kc/internal/keycloak"
+	"kc/pkg/kcops"
 
-	"github.com/Nerzal/gocloak/v13"
 	"github.com/spf13/cobra"
 )
 
-var (
-	usernames          []string
-	emails             []string
-	firstNames         []string
-	lastNames          []string
-	passwords          []string
-	usersEnabled       bool
-	usersRealms        []string
-	usersAllRealms     bool
-	realmRoleNames     []string
-	clientRoleNames    []string
-	clientRoleClientID string
-	// update-specific
-	updEmails     []string
-	updFirstNames []string
-	updLastNames  []string
-	updPasswords  []string
-	updEnabled    bool
-	updIgnoreMiss bool
-	delIgnoreMiss bool
-)
+func newUsersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage users",
+	}
+	cmd.AddCommand(newUsersListCmd())
+	cmd.AddCommand(newUsersCreateCmd())
+	cmd.AddCommand(newUsersUpdateCmd())
+	cmd.AddCommand(newUsersDeleteCmd())
+	cmd.AddCommand(newUsersDisableCmd())
+	cmd.AddCommand(newUsersSetEnabledCmd())
+	cmd.AddCommand(newUsersResetPasswordCmd())
+	cmd.AddCommand(newUsersExportCmd())
+	cmd.AddCommand(newUsersConsentsCmd())
+	cmd.AddCommand(newUsersOffboardCmd())
+	cmd.AddCommand(newUsersAnonymizeCmd())
+	cmd.AddCommand(newUsersMigrateCmd())
+	cmd.AddCommand(newUsersDedupeCmd())
+	return cmd
+}
 
-var usersCmd = &cobra.Command{
-	Use:   "users",
-	Short: "Manage users",
+// usersCreateOptions holds the flags of `kc users create`.
+type usersCreateOptions struct {
+	usernames       []string
+	emails          []string
+	firstNames      []string
+	lastNames       []string
+	passwords       []string
+	passwordHashes  []string
+	credentialData  []string
+	secretData      []string
+	enabled         bool
+	realms          []string
+	allRealms       bool
+	realmRoles      []string
+	clientRoles     []string
+	clientID        string
+	interactive     bool
+	template        templateOptions
+	count           int
+	seqStart        int
+	out             string
+	notifyUser      bool
+	linkLifespan    time.Duration
+	batch           batchOptions
+	continueOnError bool
 }
 
-var usersCreateCmd = &cobra.Command{
-	Use:   "create",
-	Short: "Create user(s) in one or multiple realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(usernames) == 0 {
-			return errors.New("missing --username: provide at least one --username")
-		}
-		// Validate optional per-user slices: allowed counts are 0, 1, or equal to usernames
-		validateSlice := func(name string, n int) error {
-			if !(n == 0 || n == 1 || n == len(usernames)) {
-				return fmt.Errorf("invalid %s: when using multiple --username, you must pass either no %s, a single %s to apply to all, or one %s per --username (in order)", name, name, name, name)
-			}
-			return nil
+func newUsersCreateCmd() *cobra.Command {
+	o := &usersCreateOptions{}
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create user(s) in one or multiple realms",
+		Long: `Create user(s) in one or multiple realms.
+
+With --count, a --template holding {seq} is a username pattern rather than
+a template file: kc creates --count users, replacing {seq} with 1, 2, 3...
+(from --seq-start), e.g. for load tests:
+
+  kc users create --template "loadtest-{seq}" --count 500 --out creds.csv
+
+A single --username holding {seq} works the same. {seq} is replaced in --email, --first-name and --last-name too,
+and every user gets a generated password. Use --out to collect the
+credentials in a CSV file instead of the output.
+
+With --notify-user, users are created without a password and Keycloak
+emails each of them a link to set their own (execute-actions email with
+UPDATE_PASSWORD), so no credential appears in the output, the log or the
+audit. Every user needs an --email and the realm an SMTP server.
+
+With --password-hash, users keep a password hashed elsewhere, e.g. when
+migrating from a legacy system: a bcrypt hash ($2a$, $2b$ or $2y$, which
+needs a bcrypt hash provider installed in Keycloak) or a PBKDF2 one written
+pbkdf2-sha256:<iterations>:<base64 salt>:<base64 hash> (also pbkdf2 and
+pbkdf2-sha512). --credential-data and --secret-data give any other hash as
+the JSON credential data and secret data of Keycloak. Hashes are imported as
+they are and never written to the output, the log or the audit.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.emails, "email", nil, "email(s). Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringSliceVar(&o.firstNames, "first-name", nil, "first name(s). Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringSliceVar(&o.lastNames, "last-name", nil, "last name(s). Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringSliceVar(&o.passwords, "password", nil, "password(s). Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringSliceVar(&o.passwordHashes, "password-hash", nil, "password hash(es) to import instead of --password: bcrypt or algorithm:iterations:salt:hash. Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringArrayVar(&o.credentialData, "credential-data", nil, "Keycloak credentialData JSON of a password hash, with --secret-data. Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringArrayVar(&o.secretData, "secret-data", nil, "Keycloak secretData JSON of a password hash, with --credential-data. Optional; 0, 1 or N matching --username.")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", true, "whether the user(s) are enabled; defaults to true")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "create users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().StringSliceVar(&o.realmRoles, "realm-role", nil, "realm role name(s) to assign to each created user")
+	cmd.Flags().StringSliceVar(&o.clientRoles, "client-role", nil, "client role name(s) to assign to each created user")
+	cmd.Flags().StringVar(&o.clientID, "client-id", "", "client-id whose roles will be assigned to created users")
+	cmd.Flags().BoolVarP(&o.interactive, "interactive", "i", false, "prompt for user parameters step by step")
+	addTemplateFlags(cmd, &o.template)
+	cmd.Flags().IntVar(&o.count, "count", 0, "create this many users from the {seq} pattern of --template (or --username)")
+	cmd.Flags().IntVar(&o.seqStart, "seq-start", 1, "first value of {seq} with --count")
+	cmd.Flags().StringVar(&o.out, "out", "", "CSV file to create for the credentials of the created users (realm,username,password), instead of printing them; must not exist")
+	addNotifyUserFlags(cmd, &o.notifyUser, &o.linkLifespan)
+	addBatchFlags(cmd, &o.batch)
+	_ = cmd.RegisterFlagCompletionFunc("realm-role", completeRealmRoles)
+	_ = cmd.RegisterFlagCompletionFunc("client-role", completeClientRoles)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	addAtomicFlag(cmd)
+	return cmd
+}
+
+func (o *usersCreateOptions) run(cmd *cobra.Command) error {
+	if err := o.seqTemplate(cmd); err != nil {
+		return err
+	}
+	tplSpecs, err := templateSpecs(cmd, o.template, "username", func(s kcops.UserSpec) string { return s.Username },
+		"username", "email", "first-name", "last-name", "password", "password-hash", "credential-data", "secret-data", "enabled")
+	if err != nil {
+		return err
+	}
+	if o.interactive {
+		if err := o.fillInteractive(cmd); err != nil {
+			return err
 		}
-		if err := validateSlice("--email", len(emails)); err != nil {
+	}
+	if len(o.usernames) == 0 && tplSpecs == nil {
+		return errors.New("missing --username: provide at least one --username or --template")
+	}
+	if o.count != 0 || cmd.Flags().Changed("seq-start") {
+		if err := o.expandSeq(tplSpecs != nil); err != nil {
 			return err
 		}
-		if err := validateSlice("--first-name", len(firstNames)); err != nil {
+	}
+	if len(o.clientRoles) > 0 && o.clientID == "" {
+		return errors.New("missing --client-id when using --client-role")
+	}
+	// Validate optional per-user slices: allowed counts are 0, 1, or equal to o.usernames
+	validateSlice := func(name string, n int) error {
+		if !(n == 0 || n == 1 || n == len(o.usernames)) {
+			return fmt.Errorf("invalid %s: when using multiple --username, you must pass either no %s, a single %s to apply to all, or one %s per --username (in order)", name, name, name, name)
+		}
+		return nil
+	}
+	if err := validateSlice("--email", len(o.emails)); err != nil {
+		return err
+	}
+	if err := validateSlice("--first-name", len(o.firstNames)); err != nil {
+		return err
+	}
+	if err := validateSlice("--last-name", len(o.lastNames)); err != nil {
+		return err
+	}
+	if err := validateSlice("--password", len(o.passwords)); err != nil {
+		return err
+	}
+	if err := validateSlice("--password-hash", len(o.passwordHashes)); err != nil {
+		return err
+	}
+	if err := validateSlice("--credential-data", len(o.credentialData)); err != nil {
+		return err
+	}
+	if err := validateSlice("--secret-data", len(o.secretData)); err != nil {
+		return err
+	}
+	if len(o.credentialData) != len(o.secretData) {
+		return errors.New("invalid --credential-data: pass one --secret-data per --credential-data")
+	}
+	if len(o.passwords) > 0 && (len(o.passwordHashes) > 0 || len(o.credentialData) > 0) {
+		return errors.New("--password cannot be combined with --password-hash or --credential-data: give the password or its hash")
+	}
+	if o.notifyUser && (len(o.passwords) > 0 || len(o.passwordHashes) > 0 || len(o.credentialData) > 0) {
+		return errors.New("--notify-user cannot be combined with --password or a password hash: users set their own password")
+	}
+	if o.notifyUser && o.out != "" {
+		return errors.New("--notify-user cannot be combined with --out: no credentials are generated")
+	}
+
+	throttle(o.batch.rps)
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Resolve target realms
+	targetRealms, err := resolveRealms(ctx, cmd, client, token)
+	if err != nil {
+		return err
+	}
+
+	specs := o.specs()
+	if tplSpecs != nil {
+		specs = tplSpecs
+	}
+	usernames := make([]string, len(specs))
+	for i, s := range specs {
+		addSecret(cmd.Context(), s.Password, s.PasswordHash, s.SecretData)
+		usernames[i] = s.Username
+	}
+	if err := rejectQualified(ctx, client, token, "username", usernames); err != nil {
+		return err
+	}
+	if o.notifyUser {
+		for _, s := range specs {
+			if s.Password != "" || s.PasswordHash != "" || s.SecretData != "" {
+				return fmt.Errorf("user %q: --notify-user cannot be combined with a password: users set their own", s.Username)
+			}
+			if s.Email == "" {
+				return fmt.Errorf("user %q has no email: --notify-user needs one per user", s.Username)
+			}
+		}
+	}
+
+	// created before the first user, so a bad path changes nothing
+	var creds *csv.Writer
+	if o.out != "" {
+		f, w, err := createCredentialsFile(o.out, "realm", "username", "password")
+		if err != nil {
 			return err
 		}
-		if err := validateSlice("--last-name", len(lastNames)); err != nil {
+		defer f.Close()
+		creds = w
+	}
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	rep.expect(len(specs) * len(targetRealms))
+	for _, realm := range targetRealms {
+		specs, err := forRealm(specs, realm)
+		if err != nil {
 			return err
 		}
-		if err := validateSlice("--password", len(passwords)); err != nil {
+		realmRoles, err := forRealm(o.realmRoles, realm)
+		if err != nil {
 			return err
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
+		clientRoles, err := forRealm(o.clientRoles, realm)
 		if err != nil {
 			return err
 		}
-
-		// Resolve target realms
-		var targetRealms []string
-		if usersAllRealms {
-			realms, err := client.GetRealms(ctx, token)
-			if err != nil {
-				return err
+		results, err := kcops.CreateUsers(ctx, ops, kcops.CreateUsersRequest{
+			Realm:           realm,
+			Users:           specs,
+			RealmRoles:      realmRoles,
+			ClientID:        strings.ReplaceAll(o.clientID, realmPlaceholder, realm),
+			ClientRoles:     clientRoles,
+			NoPassword:      o.notifyUser,
+			Workers:         o.batch.workers,
+			ContinueOnError: o.continueOnError,
+		})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("user", r), r.Error)
+				continue
 			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("user", r), "already exists", fmt.Sprintf("User %q already exists in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
-		} else if len(usersRealms) > 0 {
-			targetRealms = append(targetRealms, usersRealms...)
-		} else {
-			r := defaultRealm
-			if r == "" {
-				r = config.Global.Realm
+			if r.PasswordGenerated && creds == nil {
+				rep.note(fmt.Sprintf("Generated password for user %q in realm %q.", r.Name, realm))
 			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
+			rep.add(kcops.Created, opsItem("user", r), fmt.Sprintf("Created user %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
+			if o.notifyUser {
+				if err := keycloak.ExecuteActionsEmail(ctx, client, token, realm, r.ID, []string{keycloak.UpdatePasswordAction}, o.linkLifespan); err != nil {
+					rep.fail(opsItem("user", r), fmt.Sprintf("user %q was created in realm %s but the set-password email failed: %v", r.Name, realm, err))
+				} else {
+					rep.note(fmt.Sprintf("Sent user %q in realm %q an email to set their password.", r.Name, realm))
+					r.Fields = append(r.Fields, kcops.FieldChange{Field: "executeActionsEmail", New: keycloak.UpdatePasswordAction})
+				}
+			} else if r.Password == "" {
+				rep.note(fmt.Sprintf("Imported the password hash of user %q in realm %q.", r.Name, realm))
+			} else if creds != nil {
+				creds.Write([]string{realm, r.Name, r.Password})
+			} else {
+				addSecret(cmd.Context(), r.Password)
+				rep.note(fmt.Sprintf("Password for user %q in realm %q: %s", r.Name, realm, r.Password))
 			}
-			targetRealms = []string{r}
-		}
-
-		created := 0
-		skipped := 0
-		var lines []string
-		var passwordPairs []string
-		for _, realm := range targetRealms {
-			for i, un := range usernames {
-				// Lookup existence by username
-				params := gocloak.GetUsersParams{Username: &un}
-				existing, err := client.GetUsers(ctx, token, realm, params)
-				if err != nil {
-					return fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
-				}
-				if len(existing) > 0 {
-					lines = append(lines, fmt.Sprintf("User %q already exists in realm %q. Skipped.", un, realm))
-					skipped++
-					continue
-				}
-
-				var em, fn, ln, pw string
-				if len(emails) == 1 {
-					em = emails[0]
-				} else if len(emails) == len(usernames) {
-					em = emails[i]
-				}
-				if len(firstNames) == 1 {
-					fn = firstNames[0]
-				} else if len(firstNames) == len(usernames) {
-					fn = firstNames[i]
-				}
-				if len(lastNames) == 1 {
-					ln = lastNames[0]
-				} else if len(lastNames) == len(usernames) {
-					ln = lastNames[i]
-				}
-				if len(passwords) == 1 {
-					pw = passwords[0]
-				} else if len(passwords) == len(usernames) {
-					pw = passwords[i]
-				}
-
-				// If no password provided, generate one automatically (fixed length 12)
-				if pw == "" {
-					generated, err := generateStrongPassword(12)
-					if err != nil {
-						return fmt.Errorf("failed generating password for user %q in realm %s: %w", un, realm, err)
-					}
-					pw = generated
-					lines = append(lines, fmt.Sprintf("Generated password for user %q in realm %q.", un, realm))
-				}
-
-				// Validate password strength (provided or generated)
-				if err := validatePasswordStrength(pw); err != nil {
-					return fmt.Errorf("invalid password for user %q in realm %s: %w", un, realm, err)
-				}
-
-				enabled := usersEnabled
-				emailVerified := em != ""
-
-				user := gocloak.User{
-					Username:      &un,
-					Enabled:       &enabled,
-					EmailVerified: &emailVerified,
-				}
-				if em != "" {
-					user.Email = &em
-				}
-				if fn != "" {
-					user.FirstName = &fn
-				}
-				if ln != "" {
-					user.LastName = &ln
-				}
-				creds := []gocloak.CredentialRepresentation{{
-					Type:      gocloak.StringP("password"),
-					Value:     gocloak.StringP(pw),
-					Temporary: gocloak.BoolP(false),
-				}}
-				user.Credentials = &creds
-
-				userID, err := client.CreateUser(ctx, token, realm, user)
-				if err != nil {
-					// Surfacing 409 conflicts more nicely
-					if strings.Contains(strings.ToLower(err.Error()), "409") {
-						fmt.Fprintf(cmd.OutOrStdout(), "User %q already exists in realm %q. Skipped.\n", un, realm)
-						skipped++
-						continue
-					}
-					return fmt.Errorf("failed creating user %q in realm %s: %w", un, realm, err)
-				}
-
-				// Assign realm roles if requested
-				if len(realmRoleNames) > 0 {
-					var roles []gocloak.Role
-					for _, rn := range realmRoleNames {
-						role, err := client.GetRealmRole(ctx, token, realm, rn)
-						if err != nil {
-							return fmt.Errorf("failed fetching realm role %q in realm %s: %w", rn, realm, err)
-						}
-						roles = append(roles, *role)
-					}
-					if err := client.AddRealmRoleToUser(ctx, token, realm, userID, roles); err != nil {
-						return fmt.Errorf("failed assigning roles to user %q in realm %s: %w", un, realm, err)
-					}
-				}
-				// Assign client roles if requested
-				if len(clientRoleNames) > 0 {
-					if clientRoleClientID == "" {
-						return errors.New("missing --client-id when using --client-role")
-					}
-					kcClient, err := getClientByClientID(ctx, client, token, realm, clientRoleClientID)
-					if err != nil || kcClient == nil || kcClient.ID == nil {
-						return fmt.Errorf("client %q not found in realm %s", clientRoleClientID, realm)
-					}
-					idOfClient := *kcClient.ID
-					var roles []gocloak.Role
-					for _, rn := range clientRoleNames {
-						role, err := client.GetClientRole(ctx, token, realm, idOfClient, rn)
-						if err != nil {
-							return fmt.Errorf("failed fetching client role %q for client %s in realm %s: %w", rn, clientRoleClientID, realm, err)
-						}
-						roles = append(roles, *role)
-					}
-					if err := client.AddClientRoleToUser(ctx, token, realm, idOfClient, userID, roles); err != nil {
-						return fmt.Errorf("failed assigning client roles to user %q in realm %s: %w", un, realm, err)
-					}
-				}
-
-				lines = append(lines, fmt.Sprintf("Created user %q (ID: %s) in realm %q.", un, userID, realm))
-				lines = append(lines, fmt.Sprintf("Password for user %q in realm %q: %s", un, realm, pw))
-				passwordPairs = append(passwordPairs, pw)
-				created++
-			}
-		}
-		lines = append(lines, fmt.Sprintf("Done. Created: %d, Skipped: %d.", created, skipped))
-		realmLabel := ""
-		if usersAllRealms {
-			realmLabel = "all realms"
-		} else if len(usersRealms) == 1 {
-			realmLabel = usersRealms[0]
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
+			recordResult(cmd, r)
 		}
-		if len(passwordPairs) > 0 {
-			auditDetails = "passwords: " + strings.Join(passwordPairs, ", ")
+		if creds != nil {
+			if creds.Flush(); creds.Error() != nil {
+				return fmt.Errorf("users were created in realm %s but writing %s failed: %w", realm, o.out, creds.Error())
+			}
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
-}
-
-func validatePasswordStrength(pw string) error {
-	// User-provided (or generated) passwords must be at least 6 characters long
-	if len(pw) < 6 {
-		return fmt.Errorf("password must be at least 6 characters long")
-	}
-	var hasLower, hasUpper, hasDigit, hasSpecial bool
-	for _, r := range pw {
-		switch {
-		case unicode.IsLower(r):
-			hasLower = true
-		case unicode.IsUpper(r):
-			hasUpper = true
-		case unicode.IsDigit(r):
-			hasDigit = true
-		default:
-			// Anything that is not a letter or digit is considered special
-			hasSpecial = true
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			err = flagError(err)
+			if creds != nil && len(rep.result.Created) > 0 {
+				return fmt.Errorf("%w (the credentials of the %d user(s) created before are in %s)", err, len(rep.result.Created), o.out)
+			}
+			return err
 		}
 	}
-	if !hasLower || !hasUpper || !hasDigit || !hasSpecial {
-		return errors.New("password must contain at least one lowercase letter, one uppercase letter, one digit, and one special character")
+	if creds != nil && len(rep.result.Created) > 0 {
+		rep.note(fmt.Sprintf("Credentials written to %s. Hand them over securely and delete the file afterwards.", o.out))
 	}
-	return nil
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Created: %d, Skipped: %d.", len(rep.result.Created), len(rep.result.Skipped)))
 }
 
-func generateStrongPassword(n int) (string, error) {
-	const lower = "abcdefghijklmnopqrstuvwxyz"
-	const upper = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	const digits = "0123456789"
-	const specials = "!@#$%^&*()-_=+[]{}|;:,.<>/?"
-	const all = lower + upper + digits + specials
+// usersUpdateOptions holds the flags of `kc users update`.
+type usersUpdateOptions struct {
+	usernames       []string
+	emails          []string
+	firstNames      []string
+	lastNames       []string
+	passwords       []string
+	enabled         bool
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	match           matchOptions
+	continueOnError bool
+}
 
-	// We need at least one of each type: lower, upper, digit, special
-	if n < 4 {
-		return "", errors.New("password length must be at least 4")
+func newUsersUpdateCmd() *cobra.Command {
+	o := &usersUpdateOptions{}
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update user(s) in one or multiple realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
 	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) to update. Repeatable; required.")
+	addStdinFlag(cmd, "username")
+	o.match.add(cmd, "user")
+	cmd.Flags().StringSliceVar(&o.emails, "email", nil, "new email(s). Optional; 0, 1 or N matching --username.")
+	cmd.Flags().StringSliceVar(&o.firstNames, "first-name", nil, "new first name(s). Optional; 0, 1 or N.")
+	cmd.Flags().StringSliceVar(&o.lastNames, "last-name", nil, "new last name(s). Optional; 0, 1 or N.")
+	cmd.Flags().StringSliceVar(&o.passwords, "password", nil, "new password(s). Optional; 0, 1 or N.")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", true, "set enabled state for users; if flag is present, applies to all or per-user via 0/1/N not supported")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "update users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip users not found instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
 
-	b := make([]byte, n)
-
-	// ensure at least one of each required type
-	pools := []string{lower, upper, digits, specials}
-	for i, pool := range pools {
-		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(pool))))
-		if err != nil {
-			return "", err
-		}
-		b[i] = pool[idx.Int64()]
+func (o *usersUpdateOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 && !o.match.active() {
+		return errors.New("missing --username: provide at least one --username or --match")
+	}
+	if err := o.match.validate(cmd, "username", o.usernames, nil, []string{"email", "first-name", "last-name", "password"}); err != nil {
+		return err
 	}
+	// Determine if enabled flag was provided
+	enabledChanged := cmd.Flags().Changed("enabled")
 
-	for i := len(pools); i < n; i++ {
-		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(all))))
-		if err != nil {
-			return "", err
+	// Must have at least one field to update
+	if len(o.emails) == 0 && len(o.firstNames) == 0 && len(o.lastNames) == 0 && len(o.passwords) == 0 && !enabledChanged {
+		return errors.New("nothing to update: provide at least one of --email/--first-name/--last-name/--password/--enabled")
+	}
+	// Validate 0/1/N for provided slices
+	validate := func(name string, n int) error {
+		if !(n == 0 || n == 1 || n == len(o.usernames)) {
+			return fmt.Errorf("invalid %s: when using multiple --username, pass none, one (applies to all), or one per --username (in order)", name)
 		}
-		b[i] = all[idx.Int64()]
+		return nil
+	}
+	if err := validate("--email", len(o.emails)); err != nil {
+		return err
+	}
+	if err := validate("--first-name", len(o.firstNames)); err != nil {
+		return err
+	}
+	if err := validate("--last-name", len(o.lastNames)); err != nil {
+		return err
+	}
+	if err := validate("--password", len(o.passwords)); err != nil {
+		return err
 	}
 
-	return string(b), nil
-}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
 
-var usersUpdateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update user(s) in one or multiple realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(usernames) == 0 {
-			return errors.New("missing --username: provide at least one --username")
+	// Resolve target realms
+	targetRealms, err := o.match.realms(ctx, cmd, client, token, o.usernames)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "update", targetRealms, listUsernames(ctx, client, token)); err != nil {
+			return err
 		}
-		// Determine if enabled flag was provided
-		enabledChanged := cmd.Flags().Changed("enabled")
+	}
 
-		// Must have at least one field to update
-		if len(updEmails) == 0 && len(updFirstNames) == 0 && len(updLastNames) == 0 && len(updPasswords) == 0 && !enabledChanged {
-			return errors.New("nothing to update: provide at least one of --email/--first-name/--last-name/--password/--enabled")
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		rep.expect(len(o.match.names(realm, o.usernames)))
+	}
+	for _, realm := range targetRealms {
+		usernames := o.match.names(realm, o.usernames)
+		if len(usernames) == 0 {
+			continue
 		}
-		// Validate 0/1/N for provided slices
-		validate := func(name string, n int) error {
-			if !(n == 0 || n == 1 || n == len(usernames)) {
-				return fmt.Errorf("invalid %s: when using multiple --username, pass none, one (applies to all), or one per --username (in order)", name)
+		updates := make([]kcops.UserUpdate, len(usernames))
+		for i, un := range usernames {
+			updates[i] = kcops.UserUpdate{Username: un}
+			updates[i].Email, _ = pick(o.emails, i)
+			updates[i].FirstName, _ = pick(o.firstNames, i)
+			updates[i].LastName, _ = pick(o.lastNames, i)
+			updates[i].Password, _ = pick(o.passwords, i)
+			if enabledChanged {
+				updates[i].Enabled = &o.enabled
 			}
-			return nil
-		}
-		if err := validate("--email", len(updEmails)); err != nil {
-			return err
 		}
-		if err := validate("--first-name", len(updFirstNames)); err != nil {
-			return err
-		}
-		if err := validate("--last-name", len(updLastNames)); err != nil {
-			return err
+		results, err := kcops.UpdateUsers(ctx, ops, kcops.UpdateUsersRequest{Realm: realm, Users: updates, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("user", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("user", r), "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
+			}
+			if r.Password != "" {
+				rep.note(fmt.Sprintf("Updated password for user %q in realm %q.", r.Name, realm))
+				rep.note(fmt.Sprintf("New password for user %q in realm %q: %s", r.Name, realm, r.Password))
+			}
+			recordResult(cmd, r)
+			rep.add(kcops.Updated, opsItem("user", r), fmt.Sprintf("Updated user %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
 		}
-		if err := validate("--password", len(updPasswords)); err != nil {
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
 			return err
 		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Updated: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
-		if err != nil {
+// usersDeleteOptions holds the flags of `kc users delete`.
+type usersDeleteOptions struct {
+	usernames       []string
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	match           matchOptions
+	continueOnError bool
+}
+
+func newUsersDeleteCmd() *cobra.Command {
+	o := &usersDeleteOptions{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete user(s) in one or multiple realms",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) to delete. Repeatable; required.")
+	addStdinFlag(cmd, "username")
+	o.match.add(cmd, "user")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "delete users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip users not found instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersDeleteOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 && !o.match.active() {
+		return errors.New("missing --username: provide at least one --username or --match")
+	}
+	if err := o.match.validate(cmd, "username", o.usernames, nil, nil); err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	client, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	targetRealms, err := o.match.realms(ctx, cmd, client, token, o.usernames)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "delete", targetRealms, listUsernames(ctx, client, token)); err != nil {
 			return err
 		}
+	}
 
-		// Resolve target realms
-		var targetRealms []string
-		if usersAllRealms {
-			realms, err := client.GetRealms(ctx, token)
-			if err != nil {
-				return err
-			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
-			}
-		} else if len(usersRealms) > 0 {
-			targetRealms = append(targetRealms, usersRealms...)
-		} else {
-			r := defaultRealm
-			if r == "" {
-				r = config.Global.Realm
+	ops := opsClient(client, token)
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		rep.expect(len(o.match.names(realm, o.usernames)))
+	}
+	for _, realm := range targetRealms {
+		usernames := o.match.names(realm, o.usernames)
+		if len(usernames) == 0 {
+			continue
+		}
+		results, err := kcops.DeleteUsers(ctx, ops, kcops.DeleteUsersRequest{Realm: realm, Usernames: usernames, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("user", r), r.Error)
+				continue
 			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
+			if r.Outcome == kcops.Skipped {
+				rep.skip(opsItem("user", r), "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", r.Name, realm))
+				continue
 			}
-			targetRealms = []string{r}
-		}
-
-		updated := 0
-		skipped := 0
-		var lines []string
-		var passwordPairs []string
-		for _, realm := range targetRealms {
-			for i, un := range usernames {
-				params := gocloak.GetUsersParams{Username: &un}
-				existing, err := client.GetUsers(ctx, token, realm, params)
-				if err != nil {
-					return fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
-				}
-				if len(existing) == 0 {
-					if updIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("user %q not found in realm %s", un, realm)
-				}
-				userID := *existing[0].ID
+			recordResult(cmd, r)
+			rep.add(kcops.Deleted, opsItem("user", r), fmt.Sprintf("Deleted user %q (ID: %s) in realm %q.", r.Name, r.ID, realm))
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
 
-				var em, fn, ln, pw string
-				if len(updEmails) == 1 {
-					em = updEmails[0]
-				} else if len(updEmails) == len(usernames) {
-					em = updEmails[i]
-				}
-				if len(updFirstNames) == 1 {
-					fn = updFirstNames[0]
-				} else if len(updFirstNames) == len(usernames) {
-					fn = updFirstNames[i]
-				}
-				if len(updLastNames) == 1 {
-					ln = updLastNames[0]
-				} else if len(updLastNames) == len(usernames) {
-					ln = updLastNames[i]
-				}
-				if len(updPasswords) == 1 {
-					pw = updPasswords[0]
-				} else if len(updPasswords) == len(usernames) {
-					pw = updPasswords[i]
-				}
+func init() {
+	rootCmd.AddCommand(newUsersCmd())
+}
 
-				if pw != "" {
-					if err := validatePasswordStrength(pw); err != nil {
-						return fmt.Errorf("invalid password for user %q in realm %s: %w", un, realm, err)
-					}
-				}
+// specs builds the users to create from the flags.
+// seqPlaceholder is replaced with the sequence number of each user created
+// with --count.
+const seqPlaceholder = "{seq}"
 
-				u := gocloak.User{ID: &userID}
-				if em != "" {
-					u.Email = &em
-					ev := true
-					u.EmailVerified = &ev
-				}
-				if fn != "" {
-					u.FirstName = &fn
-				}
-				if ln != "" {
-					u.LastName = &ln
-				}
-				if enabledChanged {
-					u.Enabled = &updEnabled
-				}
+// seqTemplate takes a --template holding {seq}, given with --count, as the
+// username pattern instead of a template file.
+func (o *usersCreateOptions) seqTemplate(cmd *cobra.Command) error {
+	if o.count == 0 || !strings.Contains(o.template.file, seqPlaceholder) {
+		return nil
+	}
+	if cmd.Flags().Changed("username") {
+		return fmt.Errorf("give the %s pattern once, with --template or --username", seqPlaceholder)
+	}
+	if len(o.template.vars) > 0 {
+		return errors.New("--var requires a --template file, not a username pattern")
+	}
+	o.usernames, o.template.file = []string{o.template.file}, ""
+	return nil
+}
 
-				if err := client.UpdateUser(ctx, token, realm, u); err != nil {
-					return fmt.Errorf("failed updating user %q in realm %s: %w", un, realm, err)
-				}
-				if pw != "" {
-					if err := client.SetPassword(ctx, token, userID, realm, pw, false); err != nil {
-						return fmt.Errorf("failed setting password for user %q in realm %s: %w", un, realm, err)
-					}
-					lines = append(lines, fmt.Sprintf("Updated password for user %q in realm %q.", un, realm))
-					lines = append(lines, fmt.Sprintf("New password for user %q in realm %q: %s", un, realm, pw))
-					passwordPairs = append(passwordPairs, pw)
-				}
-				lines = append(lines, fmt.Sprintf("Updated user %q (ID: %s) in realm %q.", un, userID, realm))
-				updated++
-			}
+// expandSeq turns the --username pattern and the single --email, --first-name
+// and --last-name values into --count users.
+func (o *usersCreateOptions) expandSeq(withTemplate bool) error {
+	if o.count == 0 {
+		return errors.New("--seq-start requires --count")
+	}
+	if o.count < 0 {
+		return errors.New("invalid --count: must be positive")
+	}
+	if withTemplate {
+		return fmt.Errorf("--count cannot be combined with a --template file; a username pattern holds %s", seqPlaceholder)
+	}
+	if len(o.usernames) != 1 || !strings.Contains(o.usernames[0], seqPlaceholder) {
+		return fmt.Errorf("--count requires a --template or single --username holding %s, e.g. loadtest-%s", seqPlaceholder, seqPlaceholder)
+	}
+	if len(o.passwords) > 0 || len(o.passwordHashes) > 0 || len(o.credentialData) > 0 {
+		return errors.New("--count cannot be combined with --password or a password hash: every user gets a generated password")
+	}
+	if o.seqStart < 0 {
+		return errors.New("invalid --seq-start: must not be negative")
+	}
+	expand := func(name string, values []string) ([]string, error) {
+		if len(values) == 0 {
+			return nil, nil
 		}
-		lines = append(lines, fmt.Sprintf("Done. Updated: %d, Skipped: %d.", updated, skipped))
-		if len(passwordPairs) > 0 {
-			auditDetails = "passwords: " + strings.Join(passwordPairs, ", ")
+		if len(values) > 1 {
+			return nil, fmt.Errorf("invalid %s: pass a single pattern with --count", name)
 		}
-		realmLabel := ""
-		if usersAllRealms {
-			realmLabel = "all realms"
-		} else if len(usersRealms) == 1 {
-			realmLabel = usersRealms[0]
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
+		out := make([]string, o.count)
+		for i := range out {
+			out[i] = strings.ReplaceAll(values[0], seqPlaceholder, strconv.Itoa(o.seqStart+i))
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
+		return out, nil
+	}
+	var err error
+	if o.usernames, err = expand("--username", o.usernames); err != nil {
+		return err
+	}
+	if o.emails, err = expand("--email", o.emails); err != nil {
+		return err
+	}
+	if o.firstNames, err = expand("--first-name", o.firstNames); err != nil {
+		return err
+	}
+	o.lastNames, err = expand("--last-name", o.lastNames)
+	return err
 }
 
-var usersDeleteCmd = &cobra.Command{
-	Use:   "delete",
-	Short: "Delete user(s) in one or multiple realms",
-	RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
-		if len(usernames) == 0 {
-			return errors.New("missing --username: provide at least one --username")
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-		defer cancel()
-		client, token, err := keycloak.Login(ctx)
+func (o *usersCreateOptions) specs() []kcops.UserSpec {
+	specs := make([]kcops.UserSpec, len(o.usernames))
+	for i, un := range o.usernames {
+		specs[i] = kcops.UserSpec{Username: un, Enabled: o.enabled}
+		specs[i].Email, _ = pick(o.emails, i)
+		specs[i].FirstName, _ = pick(o.firstNames, i)
+		specs[i].LastName, _ = pick(o.lastNames, i)
+		specs[i].Password, _ = pick(o.passwords, i)
+		specs[i].PasswordHash, _ = pick(o.passwordHashes, i)
+		specs[i].CredentialData, _ = pick(o.credentialData, i)
+		specs[i].SecretData, _ = pick(o.secretData, i)
+	}
+	return specs
+}
+
+// fillInteractive asks for the parameters not given as flags and
+// confirms the resulting request.
+func (o *usersCreateOptions) fillInteractive(cmd *cobra.Command) error {
+	w := newWizard(cmd)
+	if err := w.askJira(); err != nil {
+		return err
+	}
+	if err := w.askRealms(&o.realms, &o.allRealms); err != nil {
+		return err
+	}
+
+	if len(o.usernames) == 0 {
+		un, err := w.ask("Username", "", validateName)
 		if err != nil {
 			return err
 		}
-
-		var targetRealms []string
-		if usersAllRealms {
-			realms, err := client.GetRealms(ctx, token)
+		o.usernames = []string{un}
+		if len(o.emails) == 0 {
+			v, err := w.ask("Email (optional)", "", validateEmail)
 			if err != nil {
 				return err
 			}
-			for _, r := range realms {
-				if r.Realm != nil {
-					targetRealms = append(targetRealms, *r.Realm)
-				}
-			}
-		} else if len(usersRealms) > 0 {
-			targetRealms = append(targetRealms, usersRealms...)
-		} else {
-			r := defaultRealm
-			if r == "" {
-				r = config.Global.Realm
+			o.emails = []string{v}
+		}
+		if len(o.firstNames) == 0 {
+			v, err := w.ask("First name (optional)", "", nil)
+			if err != nil {
+				return err
 			}
-			if r == "" {
-				return errors.New("target realm not specified. Use --realm or set realm in config.json")
+			o.firstNames = []string{v}
+		}
+		if len(o.lastNames) == 0 {
+			v, err := w.ask("Last name (optional)", "", nil)
+			if err != nil {
+				return err
 			}
-			targetRealms = []string{r}
-		}
-
-		deleted := 0
-		skipped := 0
-		var lines []string
-		for _, realm := range targetRealms {
-			for _, un := range usernames {
-				params := gocloak.GetUsersParams{Username: &un}
-				existing, err := client.GetUsers(ctx, token, realm, params)
-				if err != nil {
-					return fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
-				}
-				if len(existing) == 0 {
-					if delIgnoreMiss {
-						lines = append(lines, fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
-						skipped++
-						continue
-					}
-					return fmt.Errorf("user %q not found in realm %s", un, realm)
-				}
-				userID := *existing[0].ID
-				if err := client.DeleteUser(ctx, token, realm, userID); err != nil {
-					return fmt.Errorf("failed deleting user %q in realm %s: %w", un, realm, err)
+			o.lastNames = []string{v}
+		}
+		if len(o.passwords) == 0 {
+			v, err := w.ask("Password (leave empty to generate one)", "", func(s string) error {
+				if s == "" {
+					return nil
 				}
-				lines = append(lines, fmt.Sprintf("Deleted user %q (ID: %s) in realm %q.", un, userID, realm))
-				deleted++
+				return kcops.ValidatePassword(s)
+			})
+			if err != nil {
+				return err
 			}
+			o.passwords = []string{v}
+			addSecret(cmd.Context(), v)
 		}
-		lines = append(lines, fmt.Sprintf("Done. Deleted: %d, Skipped: %d.", deleted, skipped))
-		realmLabel := ""
-		if usersAllRealms {
-			realmLabel = "all realms"
-		} else if len(usersRealms) == 1 {
-			realmLabel = usersRealms[0]
-		} else if len(targetRealms) == 1 {
-			realmLabel = targetRealms[0]
+	}
+	if !cmd.Flags().Changed("enabled") {
+		v, err := w.askBool("Enabled?", o.enabled)
+		if err != nil {
+			return err
 		}
-		printBox(cmd, lines, realmLabel)
-		return nil
-	}),
-}
+		o.enabled = v
+	}
+	if len(o.realmRoles) == 0 {
+		v, err := w.askList("Realm roles to assign (optional)", nil, nil)
+		if err != nil {
+			return err
+		}
+		o.realmRoles = v
+	}
+	if o.clientID == "" && len(o.clientRoles) == 0 {
+		v, err := w.ask("Client-id whose roles to assign (optional)", "", nil)
+		if err != nil {
+			return err
+		}
+		o.clientID = v
+	}
+	if o.clientID != "" && len(o.clientRoles) == 0 {
+		v, err := w.ask(fmt.Sprintf("Roles of client %s to assign (comma-separated)", o.clientID), "", func(s string) error {
+			if len(splitList(s)) == 0 {
+				return errors.New("at least one role is required")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		o.clientRoles = splitList(v)
+	}
 
-func init() {
-	rootCmd.AddCommand(usersCmd)
-	usersCmd.AddCommand(usersCreateCmd)
-	usersCreateCmd.Flags().StringSliceVar(&usernames, "username", nil, "username(s). Repeatable; required.")
-	usersCreateCmd.Flags().StringSliceVar(&emails, "email", nil, "email(s). Optional; 0, 1 or N matching --username.")
-	usersCreateCmd.Flags().StringSliceVar(&firstNames, "first-name", nil, "first name(s). Optional; 0, 1 or N matching --username.")
-	usersCreateCmd.Flags().StringSliceVar(&lastNames, "last-name", nil, "last name(s). Optional; 0, 1 or N matching --username.")
-	usersCreateCmd.Flags().StringSliceVar(&passwords, "password", nil, "password(s). Optional; 0, 1 or N matching --username.")
-	usersCreateCmd.Flags().BoolVar(&usersEnabled, "enabled", true, "whether the user(s) are enabled; defaults to true")
-	usersCreateCmd.Flags().StringSliceVar(&usersRealms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
-	usersCreateCmd.Flags().BoolVar(&usersAllRealms, "all-realms", false, "create users in all realms")
-	usersCreateCmd.Flags().StringSliceVar(&realmRoleNames, "realm-role", nil, "realm role name(s) to assign to each created user")
-	usersCreateCmd.Flags().StringSliceVar(&clientRoleNames, "client-role", nil, "client role name(s) to assign to each created user")
-	usersCreateCmd.Flags().StringVar(&clientRoleClientID, "client-id", "", "client-id whose roles will be assigned to created users")
-
-	usersCmd.AddCommand(usersUpdateCmd)
-	usersUpdateCmd.Flags().StringSliceVar(&usernames, "username", nil, "username(s) to update. Repeatable; required.")
-	usersUpdateCmd.Flags().StringSliceVar(&updEmails, "email", nil, "new email(s). Optional; 0, 1 or N matching --username.")
-	usersUpdateCmd.Flags().StringSliceVar(&updFirstNames, "first-name", nil, "new first name(s). Optional; 0, 1 or N.")
-	usersUpdateCmd.Flags().StringSliceVar(&updLastNames, "last-name", nil, "new last name(s). Optional; 0, 1 or N.")
-	usersUpdateCmd.Flags().StringSliceVar(&updPasswords, "password", nil, "new password(s). Optional; 0, 1 or N.")
-	usersUpdateCmd.Flags().BoolVar(&updEnabled, "enabled", true, "set enabled state for users; if flag is present, applies to all or per-user via 0/1/N not supported")
-	usersUpdateCmd.Flags().StringSliceVar(&usersRealms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
-	usersUpdateCmd.Flags().BoolVar(&usersAllRealms, "all-realms", false, "update users in all realms")
-	usersUpdateCmd.Flags().BoolVar(&updIgnoreMiss, "ignore-missing", false, "skip users not found instead of failing")
-
-	usersCmd.AddCommand(usersDeleteCmd)
-	usersDeleteCmd.Flags().StringSliceVar(&usernames, "username", nil, "username(s) to delete. Repeatable; required.")
-	usersDeleteCmd.Flags().StringSliceVar(&usersRealms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
-	usersDeleteCmd.Flags().BoolVar(&usersAllRealms, "all-realms", false, "delete users in all realms")
-	usersDeleteCmd.Flags().BoolVar(&delIgnoreMiss, "ignore-missing", false, "skip users not found instead of failing")
+	req := kcops.CreateUsersRequest{
+		Users:       o.specs(),
+		RealmRoles:  o.realmRoles,
+		ClientID:    o.clientID,
+		ClientRoles: o.clientRoles,
+	}
+	// the payload is printed and logged, so o.passwords are masked
+	for i := range req.Users {
+		if req.Users[i].PasswordHash != "" || req.Users[i].SecretData != "" {
+			req.Users[i].PasswordHash, req.Users[i].CredentialData, req.Users[i].SecretData = "********", "", ""
+		} else if req.Users[i].Password != "" {
+			req.Users[i].Password = "********"
+		} else {
+			req.Users[i].Password = "(generated)"
+		}
+	}
+	return w.confirm("create", w.realmsLabel(o.realms, o.allRealms), req)
 }
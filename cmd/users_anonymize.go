@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// usersAnonymizeOptions holds the flags of `kc users anonymize`.
+type usersAnonymizeOptions struct {
+	usernames       []string
+	keepAttributes  []string
+	rename          bool
+	match           matchOptions
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newUsersAnonymizeCmd() *cobra.Command {
+	o := &usersAnonymizeOptions{}
+	cmd := &cobra.Command{
+		Use:   "anonymize",
+		Short: "Scrub the personal data of user(s) while keeping the accounts",
+		Long: `Clear the email, first and last name and attributes of users, keeping the
+accounts and their IDs so that records of other systems pointing at them stay
+valid. Use it instead of users delete when a data-retention policy requires
+erasing personal data but not the account.
+
+Attributes named with --keep-attribute survive. The username is kept unless
+--rename replaces it with "anonymized-" and the start of the user ID. The
+old values are not written to the output, the log or the audit, so an
+anonymization cannot be undone.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) to anonymize. Repeatable; required.")
+	addStdinFlag(cmd, "username")
+	o.match.add(cmd, "user")
+	cmd.Flags().StringSliceVar(&o.keepAttributes, "keep-attribute", nil, "attribute to keep, e.g. an employee number other systems join on. Repeatable")
+	cmd.Flags().BoolVar(&o.rename, "rename", false, `replace the username with "anonymized-" and the start of the user ID`)
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "anonymize users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip users not found instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersAnonymizeOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 && !o.match.active() {
+		return errors.New("missing --username: provide at least one --username or --match")
+	}
+	if err := o.match.validate(cmd, "username", o.usernames, nil, nil); err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := o.match.realms(ctx, cmd, gc, token, o.usernames)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "anonymize", realms, listUsernames(ctx, gc, token)); err != nil {
+			return err
+		}
+	}
+
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		usernames := o.match.names(realm, o.usernames)
+		if len(usernames) == 0 {
+			continue
+		}
+		results, err := kcops.AnonymizeUsers(ctx, ops, kcops.AnonymizeUsersRequest{Realm: realm, Usernames: usernames, KeepAttributes: o.keepAttributes, Rename: o.rename, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("user", r), r.Error)
+				continue
+			}
+			printWarnings(cmd, r)
+			if r.Outcome == kcops.Skipped {
+				reason, line := "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", r.Name, realm)
+				if r.ID != "" {
+					reason, line = "unchanged", fmt.Sprintf("User %q in realm %q has no personal data left. Skipped.", r.Name, realm)
+				}
+				rep.skip(opsItem("user", r), reason, line)
+				continue
+			}
+			recordResult(cmd, r)
+			line := fmt.Sprintf("Anonymized user %q (ID: %s) in realm %q: %d field(s) scrubbed.", r.Name, r.ID, realm, len(r.Fields))
+			if r.NewName != r.Name {
+				line = fmt.Sprintf("Anonymized user %q (ID: %s) in realm %q as %q: %d field(s) scrubbed.", r.Name, r.ID, realm, r.NewName, len(r.Fields))
+			}
+			rep.add(kcops.Updated, opsItem("user", r), line)
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Anonymized: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
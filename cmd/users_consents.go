@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+func newUsersConsentsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "consents",
+		Short: "Review and revoke the consents users granted to clients",
+	}
+	cmd.AddCommand(newUsersConsentsListCmd())
+	cmd.AddCommand(newUsersConsentsRevokeCmd())
+	return cmd
+}
+
+// userConsentInfo is the JSON output of `kc users consents list`.
+type userConsentInfo struct {
+	Realm         string   `json:"realm"`
+	Username      string   `json:"username"`
+	ClientID      string   `json:"clientId"`
+	Scopes        []string `json:"grantedClientScopes,omitempty"`
+	OfflineTokens bool     `json:"offlineTokens"`
+	Created       string   `json:"created,omitempty"`
+	LastUpdated   string   `json:"lastUpdated,omitempty"`
+}
+
+// userConsents looks up user un and returns its ID and the consents it
+// granted to clientIDs, or to every client when clientIDs is empty. The ID
+// is empty when the user does not exist.
+func userConsents(ctx context.Context, gc keycloak.API, token, realm, un string, clientIDs []string) (string, []*keycloak.UserConsent, error) {
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+	}
+	if len(users) == 0 || users[0].ID == nil {
+		return "", nil, nil
+	}
+	userID := *users[0].ID
+	consents, err := keycloak.GetUserConsents(ctx, gc, token, realm, userID)
+	if err != nil {
+		return userID, nil, fmt.Errorf("failed listing consents of user %q in realm %s: %w", un, realm, err)
+	}
+	if len(clientIDs) > 0 {
+		consents = slices.DeleteFunc(consents, func(c *keycloak.UserConsent) bool { return !slices.Contains(clientIDs, c.ClientID) })
+	}
+	return userID, consents, nil
+}
+
+// usersConsentsListOptions holds the flags of `kc users consents list`.
+type usersConsentsListOptions struct {
+	usernames []string
+	clientIDs []string
+	realms    []string
+	allRealms bool
+}
+
+func newUsersConsentsListCmd() *cobra.Command {
+	o := &usersConsentsListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the clients users consented to or hold offline tokens for",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s). Repeatable; required.")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "only the consents to these client-ids. Repeatable")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "list consents in all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *usersConsentsListOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 {
+		return errors.New("missing --username: provide at least one --username")
+	}
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	infos := []userConsentInfo{}
+	var lines []string
+	for _, realm := range realms {
+		for _, un := range o.usernames {
+			userID, consents, err := userConsents(ctx, gc, token, realm, un, o.clientIDs)
+			if err != nil {
+				return err
+			}
+			if userID == "" {
+				lines = append(lines, fmt.Sprintf("%s/%s: user not found", realm, un))
+				continue
+			}
+			if len(consents) == 0 {
+				lines = append(lines, fmt.Sprintf("%s/%s: no consents", realm, un))
+			}
+			for _, c := range consents {
+				info := userConsentInfo{Realm: realm, Username: un, ClientID: c.ClientID, Scopes: c.GrantedClientScopes, OfflineTokens: c.HasOfflineTokens()}
+				if c.CreatedDate > 0 {
+					info.Created = formatEventTime(c.CreatedDate)
+				}
+				if c.LastUpdatedDate > 0 {
+					info.LastUpdated = formatEventTime(c.LastUpdatedDate)
+				}
+				infos = append(infos, info)
+				line := fmt.Sprintf("%s/%s -> %s: scopes %s", realm, un, c.ClientID, strings.Join(c.GrantedClientScopes, ", "))
+				if len(c.GrantedClientScopes) == 0 {
+					line = fmt.Sprintf("%s/%s -> %s: no scopes", realm, un, c.ClientID)
+				}
+				if info.OfflineTokens {
+					line += ", offline tokens"
+				}
+				if info.LastUpdated != "" {
+					line += ", updated " + info.LastUpdated
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	if outputFormat == "json" {
+		return printJSON(cmd, infos)
+	}
+	lines = append(lines, fmt.Sprintf("Total: %d", len(infos)))
+	printBox(cmd, lines, realmsLabel(cmd, realms))
+	return nil
+}
+
+// usersConsentsRevokeOptions holds the flags of `kc users consents revoke`.
+type usersConsentsRevokeOptions struct {
+	usernames       []string
+	clientIDs       []string
+	realms          []string
+	allRealms       bool
+	continueOnError bool
+}
+
+func newUsersConsentsRevokeCmd() *cobra.Command {
+	o := &usersConsentsRevokeOptions{}
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke the consents users granted to clients",
+		Long: `Revoke the consents of users, for the given clients or for every client they
+consented to, e.g. when offboarding a user or answering a privacy request.
+Keycloak also revokes the offline tokens of those clients, and the user is
+asked for consent again at the next login. Revoked consents cannot be
+restored with kc undo.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) whose consents to revoke. Repeatable; required.")
+	addStdinFlag(cmd, "username")
+	cmd.Flags().StringSliceVar(&o.clientIDs, "client-id", nil, "only revoke the consents to these client-ids. Repeatable")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "revoke consents in all realms")
+	addRealmSelectionFlags(cmd)
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersConsentsRevokeOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 {
+		return errors.New("missing --username: provide at least one --username")
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		for _, un := range o.usernames {
+			if err := o.revokeUser(ctx, cmd, rep, gc, token, realm, un); err != nil {
+				if err := rep.failOrStop(o.continueOnError, audit.ItemResult{Kind: "userConsent", Realm: realm, Name: un}, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Revoked: %d, Skipped: %d.", len(rep.result.Deleted), len(rep.result.Skipped)))
+}
+
+// revokeUser revokes the consents of user un, one report item per client.
+func (o *usersConsentsRevokeOptions) revokeUser(ctx context.Context, cmd *cobra.Command, rep *report, gc keycloak.API, token, realm, un string) error {
+	userID, consents, err := userConsents(ctx, gc, token, realm, un, o.clientIDs)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		rep.skip(audit.ItemResult{Kind: "userConsent", Realm: realm, Name: un}, "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+		return nil
+	}
+	for _, cid := range o.clientIDs {
+		if !slices.ContainsFunc(consents, func(c *keycloak.UserConsent) bool { return c.ClientID == cid }) {
+			rep.skip(audit.ItemResult{Kind: "userConsent", Realm: realm, Name: un + "/" + cid, ID: userID}, "no consent", fmt.Sprintf("User %q has no consent to client %q in realm %q. Skipped.", un, cid, realm))
+		}
+	}
+	if len(o.clientIDs) == 0 && len(consents) == 0 {
+		rep.skip(audit.ItemResult{Kind: "userConsent", Realm: realm, Name: un, ID: userID}, "no consents", fmt.Sprintf("User %q has no consents in realm %q. Skipped.", un, realm))
+	}
+	for _, c := range consents {
+		if err := gc.RevokeUserConsents(ctx, token, realm, userID, c.ClientID); err != nil {
+			return fmt.Errorf("failed revoking the consent of user %q to client %q in realm %s: %w", un, c.ClientID, realm, err)
+		}
+		var fields []audit.FieldChange
+		if len(c.GrantedClientScopes) > 0 {
+			fields = append(fields, audit.FieldChange{Field: "grantedClientScopes", Old: strings.Join(c.GrantedClientScopes, ",")})
+		}
+		if c.HasOfflineTokens() {
+			fields = append(fields, audit.FieldChange{Field: "offlineTokens", Old: "true", New: "false"})
+		}
+		recordChange(cmd, realm, un+"/"+c.ClientID, userID, fields...)
+		rep.add(kcops.Deleted, audit.ItemResult{Kind: "userConsent", Realm: realm, Name: un + "/" + c.ClientID, ID: userID},
+			fmt.Sprintf("Revoked the consent of user %q to client %q in realm %q.", un, c.ClientID, realm))
+	}
+	return nil
+}
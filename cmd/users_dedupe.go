@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersDedupeOptions holds the flags of `kc users dedupe`.
+type usersDedupeOptions struct {
+	by     string
+	merge  bool
+	keep   string
+	output reportOutput
+}
+
+// duplicateUsers are the users sharing a key, oldest first.
+type duplicateUsers struct {
+	key   string
+	users []*gocloak.User
+}
+
+func newUsersDedupeCmd() *cobra.Command {
+	o := &usersDedupeOptions{}
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find users sharing an email and merge them into one account",
+		Long: `Find the users of a realm sharing an email (--by email, the default) or a
+first and last name (--by name), compared ignoring case. Users without one
+are not compared. Without --merge, kc only reports the duplicates, marking
+the user --keep would keep.
+
+With --merge, for every set of duplicates kc keeps one user, the oldest or
+the newest by creation time (--keep), gives it the groups, realm roles and
+client roles of the others, and disables the others. Nothing is deleted:
+check the result, then remove the disabled users with kc users delete.
+kc undo enables the disabled users again; the memberships given to the kept
+users stay.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringVar(&o.by, "by", "email", "what duplicates share: email|name")
+	cmd.Flags().BoolVar(&o.merge, "merge", false, "merge the memberships of every set of duplicates into one user and disable the others")
+	cmd.Flags().StringVar(&o.keep, "keep", "oldest", "user to keep of every set of duplicates: oldest|newest")
+	o.output.add(cmd)
+	cmd.Flags().String("realm", "", "target realm")
+	return cmd
+}
+
+func (o *usersDedupeOptions) run(cmd *cobra.Command) error {
+	if o.by != "email" && o.by != "name" {
+		return fmt.Errorf("invalid --by %q: must be email or name", o.by)
+	}
+	if o.keep != "oldest" && o.keep != "newest" {
+		return fmt.Errorf("invalid --keep %q: must be oldest or newest", o.keep)
+	}
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	if o.merge {
+		// only merging changes the server: the report alone runs in
+		// protected realms and without admin roles
+		mutating(cmd, "manage-users")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+	}
+	dups := findDuplicateUsers(users, o.by)
+
+	if !o.merge {
+		t := &reportTable{columns: []string{o.by, "username", "id", "created", "enabled", "keep"}}
+		count := 0
+		for _, d := range dups {
+			keeper := o.keeper(d)
+			for _, u := range d.users {
+				keep := ""
+				if u == keeper {
+					keep = "yes"
+				}
+				t.rows = append(t.rows, []string{d.key, gocloak.PString(u.Username), gocloak.PString(u.ID), formatCreated(u.CreatedTimestamp),
+					strconv.FormatBool(gocloak.PBool(u.Enabled)), keep})
+				count++
+			}
+		}
+		summary := fmt.Sprintf("Duplicates: %d user(s) sharing %d %s(s). Merge them with --merge.", count, len(dups), o.by)
+		if len(dups) == 0 {
+			summary = fmt.Sprintf("No users share an %s.", o.by)
+		}
+		return o.output.print(cmd, t, realm, summary)
+	}
+
+	clients, err := gc.GetClients(ctx, token, realm, gocloak.GetClientsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing clients in realm %s: %w", realm, err)
+	}
+	rep := newReport(cmd)
+	disabled := 0
+	for _, d := range dups {
+		n, err := o.mergeDuplicates(ctx, cmd, gc, token, realm, clients, d, rep)
+		disabled += n
+		if err != nil {
+			return err
+		}
+	}
+	if len(dups) == 0 {
+		rep.note(fmt.Sprintf("No users share an %s.", o.by))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Duplicates merged: %d, Disabled: %d.", len(dups), disabled))
+}
+
+// keeper returns the user of d that --keep keeps.
+func (o *usersDedupeOptions) keeper(d duplicateUsers) *gocloak.User {
+	if o.keep == "newest" {
+		return d.users[len(d.users)-1]
+	}
+	return d.users[0]
+}
+
+// mergeDuplicates gives the keeper of d the groups, realm roles and client
+// roles of the other users of d, then disables them. It returns how many
+// users it disabled.
+func (o *usersDedupeOptions) mergeDuplicates(ctx context.Context, cmd *cobra.Command, gc keycloak.API, token, realm string, clients []*gocloak.Client, d duplicateUsers, rep *report) (int, error) {
+	keeper := o.keeper(d)
+	keeperID, keeperName := gocloak.PString(keeper.ID), gocloak.PString(keeper.Username)
+	groups, err := gc.GetUserGroups(ctx, token, realm, keeperID, gocloak.GetGroupsParams{})
+	if err != nil {
+		return 0, fmt.Errorf("failed listing groups of user %q in realm %s: %w", keeperName, realm, err)
+	}
+	haveGroups := map[string]bool{}
+	for _, g := range groups {
+		haveGroups[gocloak.PString(g.ID)] = true
+	}
+	roles, err := gc.GetRealmRolesByUserID(ctx, token, realm, keeperID)
+	if err != nil {
+		return 0, fmt.Errorf("failed listing realm roles of user %q in realm %s: %w", keeperName, realm, err)
+	}
+	haveRoles := map[string]bool{}
+	for _, r := range roles {
+		haveRoles[gocloak.PString(r.Name)] = true
+	}
+	haveClientRoles := map[string]bool{}
+	for _, c := range clients {
+		roles, err := gc.GetClientRolesByUserID(ctx, token, realm, *c.ID, keeperID)
+		if err != nil {
+			return 0, fmt.Errorf("failed listing roles of client %q of user %q in realm %s: %w", gocloak.PString(c.ClientID), keeperName, realm, err)
+		}
+		for _, r := range roles {
+			haveClientRoles[gocloak.PString(c.ClientID)+"/"+gocloak.PString(r.Name)] = true
+		}
+	}
+
+	var addedGroups, addedRoles, addedClientRoles, merged []string
+	mergeErr := func() error {
+		for _, u := range d.users {
+			if u == keeper {
+				continue
+			}
+			userID, username := gocloak.PString(u.ID), gocloak.PString(u.Username)
+			groups, err := gc.GetUserGroups(ctx, token, realm, userID, gocloak.GetGroupsParams{})
+			if err != nil {
+				return fmt.Errorf("failed listing groups of user %q in realm %s: %w", username, realm, err)
+			}
+			for _, g := range groups {
+				if haveGroups[gocloak.PString(g.ID)] {
+					continue
+				}
+				if err := gc.AddUserToGroup(ctx, token, realm, keeperID, gocloak.PString(g.ID)); err != nil {
+					return fmt.Errorf("failed adding user %q to group %s in realm %s: %w", keeperName, gocloak.PString(g.Path), realm, err)
+				}
+				haveGroups[gocloak.PString(g.ID)] = true
+				addedGroups = append(addedGroups, gocloak.PString(g.Path))
+			}
+
+			roles, err := gc.GetRealmRolesByUserID(ctx, token, realm, userID)
+			if err != nil {
+				return fmt.Errorf("failed listing realm roles of user %q in realm %s: %w", username, realm, err)
+			}
+			var add []gocloak.Role
+			for _, r := range roles {
+				if !haveRoles[gocloak.PString(r.Name)] {
+					add = append(add, *r)
+				}
+			}
+			if len(add) > 0 {
+				if err := gc.AddRealmRoleToUser(ctx, token, realm, keeperID, add); err != nil {
+					return fmt.Errorf("failed assigning realm roles to user %q in realm %s: %w", keeperName, realm, err)
+				}
+				for _, r := range add {
+					haveRoles[gocloak.PString(r.Name)] = true
+					addedRoles = append(addedRoles, gocloak.PString(r.Name))
+				}
+			}
+
+			for _, c := range clients {
+				clientID := gocloak.PString(c.ClientID)
+				roles, err := gc.GetClientRolesByUserID(ctx, token, realm, *c.ID, userID)
+				if err != nil {
+					return fmt.Errorf("failed listing roles of client %q of user %q in realm %s: %w", clientID, username, realm, err)
+				}
+				var add []gocloak.Role
+				for _, r := range roles {
+					if !haveClientRoles[clientID+"/"+gocloak.PString(r.Name)] {
+						add = append(add, *r)
+					}
+				}
+				if len(add) == 0 {
+					continue
+				}
+				if err := gc.AddClientRoleToUser(ctx, token, realm, *c.ID, keeperID, add); err != nil {
+					return fmt.Errorf("failed assigning roles of client %q to user %q in realm %s: %w", clientID, keeperName, realm, err)
+				}
+				for _, r := range add {
+					haveClientRoles[clientID+"/"+gocloak.PString(r.Name)] = true
+					addedClientRoles = append(addedClientRoles, clientID+"/"+gocloak.PString(r.Name))
+				}
+			}
+			merged = append(merged, username)
+		}
+		return nil
+	}()
+	// record whatever was added, also when a later step failed
+	var fields []audit.FieldChange
+	if len(addedGroups) > 0 {
+		fields = append(fields, audit.FieldChange{Field: "groups", New: strings.Join(addedGroups, ",")})
+	}
+	if len(addedRoles) > 0 {
+		fields = append(fields, audit.FieldChange{Field: "realmRoles", New: strings.Join(addedRoles, ",")})
+	}
+	if len(addedClientRoles) > 0 {
+		fields = append(fields, audit.FieldChange{Field: "clientRoles", New: strings.Join(addedClientRoles, ",")})
+	}
+	if len(fields) > 0 {
+		recordChange(cmd, realm, "user "+keeperName, keeperID, fields...)
+	}
+	if mergeErr != nil {
+		return 0, mergeErr
+	}
+
+	keeperItem := audit.ItemResult{Kind: "user", Realm: realm, Name: keeperName, ID: keeperID}
+	if added := len(addedGroups) + len(addedRoles) + len(addedClientRoles); added > 0 {
+		rep.add(kcops.Updated, keeperItem, fmt.Sprintf("Kept user %q for %s %q: merged %d membership(s) of %s.", keeperName, o.by, d.key, added, strings.Join(merged, ", ")))
+	} else {
+		rep.skip(keeperItem, "unchanged", fmt.Sprintf("Kept user %q for %s %q: it already holds the memberships of %s.", keeperName, o.by, d.key, strings.Join(merged, ", ")))
+	}
+	disabled := 0
+
+	for _, u := range d.users {
+		if u == keeper {
+			continue
+		}
+		userID, username := gocloak.PString(u.ID), gocloak.PString(u.Username)
+		item := audit.ItemResult{Kind: "user", Realm: realm, Name: username, ID: userID}
+		if !gocloak.PBool(u.Enabled) {
+			rep.skip(item, "unchanged", fmt.Sprintf("User %q in realm %q is already disabled. Skipped.", username, realm))
+			continue
+		}
+		update := *u
+		update.Enabled = gocloak.BoolP(false)
+		if err := gc.UpdateUser(ctx, token, realm, update); err != nil {
+			return disabled, fmt.Errorf("failed disabling user %q in realm %s: %w", username, realm, err)
+		}
+		disabled++
+		recordChangeWithBefore(cmd, realm, "user "+username, userID, *u, audit.FieldChange{Field: "enabled", Old: "true", New: "false"})
+		rep.add(kcops.Updated, item, fmt.Sprintf("Disabled user %q (ID: %s), a duplicate of %q.", username, userID, keeperName))
+	}
+	return disabled, nil
+}
+
+// findDuplicateUsers groups users sharing an email or a first and last name,
+// compared ignoring case, and sorts every group oldest first. Groups are
+// sorted by key.
+func findDuplicateUsers(users []*gocloak.User, by string) []duplicateUsers {
+	byKey := map[string][]*gocloak.User{}
+	for _, u := range users {
+		key := strings.ToLower(strings.TrimSpace(gocloak.PString(u.Email)))
+		if by == "name" {
+			first, last := strings.TrimSpace(gocloak.PString(u.FirstName)), strings.TrimSpace(gocloak.PString(u.LastName))
+			key = ""
+			if first != "" && last != "" {
+				key = strings.ToLower(first + " " + last)
+			}
+		}
+		if key != "" {
+			byKey[key] = append(byKey[key], u)
+		}
+	}
+	var dups []duplicateUsers
+	for key, group := range byKey {
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortStableFunc(group, func(a, b *gocloak.User) int {
+			if c := gocloak.PInt64(a.CreatedTimestamp) - gocloak.PInt64(b.CreatedTimestamp); c != 0 {
+				if c < 0 {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(gocloak.PString(a.Username), gocloak.PString(b.Username))
+		})
+		dups = append(dups, duplicateUsers{key: key, users: group})
+	}
+	slices.SortFunc(dups, func(a, b duplicateUsers) int { return strings.Compare(a.key, b.key) })
+	return dups
+}
+
+// formatCreated formats a creation timestamp in milliseconds, if known.
+func formatCreated(ms *int64) string {
+	if ms == nil || *ms == 0 {
+		return ""
+	}
+	return time.UnixMilli(*ms).Local().Format("2006-01-02 15:04")
+}
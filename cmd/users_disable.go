@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kc/pkg/kcops"
+
+	"github.com/spf13/cobra"
+)
+
+// usersDisableOptions holds the flags of `kc users disable`.
+type usersDisableOptions struct {
+	usernames       []string
+	until           string
+	match           matchOptions
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newUsersDisableCmd() *cobra.Command {
+	o := &usersDisableOptions{}
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Disable user(s), optionally until a date",
+		Long: `Disable users so they cannot sign in, e.g. for a leave of absence.
+
+With --until, the end of the disable is stored in the kc.disabledUntil
+attribute of the user and kc scheduler run enables the user again once it has
+passed. Without it, the users stay disabled and a pending --until is dropped.
+On Keycloak 24 and later the user profile of the realm must keep unmanaged
+attributes (Realm settings > General > Unmanaged attributes) or the attribute
+is lost. kc undo enables the users again.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) to disable. Repeatable; required.")
+	addStdinFlag(cmd, "username")
+	o.match.add(cmd, "user")
+	cmd.Flags().StringVar(&o.until, "until", "", "enable the users again at this date (2006-01-02), time (RFC 3339) or after this duration (e.g. 2w, 30d)")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "disable users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip users not found instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersDisableOptions) run(cmd *cobra.Command) error {
+	if len(o.usernames) == 0 && !o.match.active() {
+		return errors.New("missing --username: provide at least one --username or --match")
+	}
+	if err := o.match.validate(cmd, "username", o.usernames, nil, nil); err != nil {
+		return err
+	}
+	var until time.Time
+	if o.until != "" {
+		var err error
+		if until, err = parseUntil(o.until, time.Now()); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := o.match.realms(ctx, cmd, gc, token, o.usernames)
+	if err != nil {
+		return err
+	}
+	if o.match.active() {
+		if err := o.match.resolve(cmd, "disable", realms, listUsernames(ctx, gc, token)); err != nil {
+			return err
+		}
+	}
+
+	ops := opsClient(gc, token)
+	rep := newReport(cmd)
+	for _, realm := range realms {
+		usernames := o.match.names(realm, o.usernames)
+		if len(usernames) == 0 {
+			continue
+		}
+		results, err := kcops.DisableUsers(ctx, ops, kcops.DisableUsersRequest{Realm: realm, Usernames: usernames, Until: until, IgnoreMissing: o.ignoreMissing, ContinueOnError: o.continueOnError})
+		for _, r := range results {
+			if r.Outcome == kcops.Failed {
+				rep.fail(opsItem("user", r), r.Error)
+				continue
+			}
+			if r.Outcome == kcops.Skipped {
+				reason, line := "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", r.Name, realm)
+				if r.ID != "" {
+					reason, line = "unchanged", fmt.Sprintf("User %q in realm %q is already disabled. Skipped.", r.Name, realm)
+				}
+				rep.skip(opsItem("user", r), reason, line)
+				continue
+			}
+			recordResult(cmd, r)
+			line := fmt.Sprintf("Disabled user %q (ID: %s) in realm %q.", r.Name, r.ID, realm)
+			if !until.IsZero() {
+				line = fmt.Sprintf("Disabled user %q (ID: %s) in realm %q until %s.", r.Name, r.ID, realm, until.Local().Format("2006-01-02 15:04"))
+			}
+			rep.add(kcops.Updated, opsItem("user", r), line)
+		}
+		if err != nil && !errors.Is(err, kcops.ErrItemsFailed) {
+			return err
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, realms), fmt.Sprintf("Done. Disabled: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// parseUntil converts a date (2006-01-02, the start of that day), an RFC
+// 3339 time or a duration such as "2w" or "30d" from now into the end of a
+// temporary disable, which must be in the future.
+func parseUntil(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.ParseInLocation("2006-01-02", s, time.Local)
+	}
+	if err != nil {
+		d, derr := parseAge(s)
+		if derr != nil {
+			return time.Time{}, fmt.Errorf("invalid --until %q: use a date like 2006-01-02, an RFC 3339 time or a duration like 2w", s)
+		}
+		t = now.Add(d)
+	}
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("invalid --until %q: it is not in the future", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersListOptions holds the flags of `kc users list`.
+type usersListOptions struct {
+	attrs     []string
+	search    string
+	usernames bool
+	realms    []string
+	allRealms bool
+	output    reportOutput
+}
+
+func newUsersListCmd() *cobra.Command {
+	o := &usersListOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List users, optionally those with given attribute values",
+		Long: `List the users of the target realms. --attr keeps the users whose attribute
+holds the value, e.g. --attr department=finance; given several times, users
+must match every one. Keycloak does the filtering (the q parameter of the
+user search), so a cohort is found without reading every user.
+
+--usernames prints only the usernames, one per line, to pipe the cohort into
+a command taking --stdin:
+
+  kc users list --attr contract=ended --usernames |
+    kc users disable --stdin`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	cmd.Flags().StringArrayVar(&o.attrs, "attr", nil, "only users whose attribute holds this value, as name=value. Repeatable")
+	cmd.Flags().StringVar(&o.search, "search", "", "only users whose username, email, first or last name contains this text")
+	cmd.Flags().BoolVar(&o.usernames, "usernames", false, "print only the usernames, one per line")
+	o.output.add(cmd)
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "list users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.MarkFlagsMutuallyExclusive("usernames", "format")
+	cmd.MarkFlagsMutuallyExclusive("usernames", "out")
+	return cmd
+}
+
+func (o *usersListOptions) run(cmd *cobra.Command) error {
+	if err := o.output.validate(); err != nil {
+		return err
+	}
+	q, err := attributeQuery(o.attrs)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	realms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	t := &reportTable{columns: []string{"realm", "username", "email", "firstName", "lastName", "enabled"}}
+	var names []string
+	for _, realm := range realms {
+		users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+			params := gocloak.GetUsersParams{First: &first, Max: &max}
+			if q != "" {
+				params.Q = &q
+			}
+			if o.search != "" {
+				params.Search = &o.search
+			}
+			return gc.GetUsers(ctx, token, realm, params)
+		})
+		if err != nil {
+			return fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+		}
+		for _, u := range users {
+			names = append(names, gocloak.PString(u.Username))
+			t.rows = append(t.rows, []string{realm, gocloak.PString(u.Username), gocloak.PString(u.Email), gocloak.PString(u.FirstName),
+				gocloak.PString(u.LastName), strconv.FormatBool(gocloak.PBool(u.Enabled))})
+		}
+	}
+	if o.usernames {
+		for _, n := range names {
+			fmt.Fprintln(cmd.OutOrStdout(), n)
+		}
+		return nil
+	}
+	return o.output.print(cmd, t, realmsLabel(cmd, realms), fmt.Sprintf("Total: %d", len(t.rows)))
+}
+
+// attributeQuery turns --attr name=value pairs into the q parameter of the
+// user search of Keycloak, "name:value name2:value2", quoting values with
+// spaces.
+func attributeQuery(attrs []string) (string, error) {
+	var terms []string
+	for _, a := range attrs {
+		name, value, ok := strings.Cut(a, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || value == "" {
+			return "", fmt.Errorf("invalid --attr %q: use name=value", a)
+		}
+		if strings.ContainsAny(name, ` :"`) || strings.Contains(value, `"`) {
+			return "", fmt.Errorf("invalid --attr %q: the name cannot hold spaces, colons or quotes, nor the value quotes", a)
+		}
+		if strings.ContainsAny(value, " \t") {
+			value = `"` + value + `"`
+		}
+		terms = append(terms, name+":"+value)
+	}
+	return strings.Join(terms, " "), nil
+}
@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersMigrateOptions holds the flags of `kc users migrate`.
+type usersMigrateOptions struct {
+	fromRealm          string
+	toRealm            string
+	withPasswordHashes bool
+	exportFiles        []string
+	skipExisting       bool
+	dryRun             bool
+}
+
+func newUsersMigrateCmd() *cobra.Command {
+	o := &usersMigrateOptions{}
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Copy the users of a realm into another realm, optionally with their password hashes",
+		Long: `Copy the users of --from-realm into --to-realm with their profile,
+attributes, required actions, realm roles and groups, e.g. to consolidate
+tenants. The realm roles and groups must exist in --to-realm under the same
+names and paths; kc checks this before importing anything. Users are imported
+with the partial import of Keycloak, 500 per transaction, so a failing batch
+imports none of its users.
+
+The admin API of Keycloak never returns password hashes. With
+--with-password-hashes they are read from --export-file, the realm export
+written on the server by "kc.sh export --realm <from-realm>" (repeat it for
+the files of --users different_files), and imported as they are, so users keep
+their passwords. Users without a password in the export, and every user
+without --with-password-hashes, have no password in --to-realm and must reset
+it. Federated users and service accounts are skipped.
+
+Hashes are never written to the output, the log or the audit.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringVar(&o.fromRealm, "from-realm", "", "realm to copy the users from (required)")
+	cmd.Flags().StringVar(&o.toRealm, "to-realm", "", "realm to copy the users into (required)")
+	cmd.Flags().BoolVar(&o.withPasswordHashes, "with-password-hashes", false, "keep the passwords, reading their hashes from --export-file")
+	cmd.Flags().StringSliceVar(&o.exportFiles, "export-file", nil, "realm export JSON of --from-realm holding the users and their credentials. Repeatable")
+	cmd.Flags().BoolVar(&o.skipExisting, "skip-existing", false, "skip users whose username exists in --to-realm instead of failing")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the users that would be migrated without changing anything")
+	_ = cmd.RegisterFlagCompletionFunc("from-realm", completeRealms)
+	_ = cmd.RegisterFlagCompletionFunc("to-realm", completeRealms)
+	return cmd
+}
+
+func (o *usersMigrateOptions) run(cmd *cobra.Command) error {
+	if o.fromRealm == "" || o.toRealm == "" {
+		return errors.New("missing --from-realm or --to-realm: provide both realms")
+	}
+	if o.fromRealm == o.toRealm {
+		return errors.New("invalid --to-realm: it must differ from --from-realm")
+	}
+	if o.withPasswordHashes && len(o.exportFiles) == 0 {
+		return errors.New("missing --export-file: --with-password-hashes reads the hashes from a realm export of --from-realm")
+	}
+	if !o.withPasswordHashes && len(o.exportFiles) > 0 {
+		return errors.New("invalid --export-file: only used with --with-password-hashes")
+	}
+	var hashes map[string][]gocloak.CredentialRepresentation
+	if o.withPasswordHashes {
+		var err error
+		if hashes, err = readPasswordHashes(o.exportFiles, o.fromRealm); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := commandContext(cmd, 30*time.Minute)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	if err := preflight(ctx, cmd, gc, token, []string{o.fromRealm, o.toRealm}); err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	source, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return gc.GetUsers(ctx, token, o.fromRealm, gocloak.GetUsersParams{First: &first, Max: &max, BriefRepresentation: gocloak.BoolP(false)})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing users in realm %s: %w", o.fromRealm, err)
+	}
+	var users []*gocloak.User
+	withoutPassword := 0
+	for _, u := range source {
+		name := gocloak.PString(u.Username)
+		item := audit.ItemResult{Kind: "user", Realm: o.toRealm, Name: name}
+		if gocloak.PString(u.FederationLink) != "" || gocloak.PString(u.ServiceAccountClientID) != "" {
+			rep.skip(item, "federated", fmt.Sprintf("User %q is federated or a service account. Skipped.", name))
+			continue
+		}
+		user, err := o.userToImport(ctx, gc, token, u)
+		if err != nil {
+			return err
+		}
+		if creds, ok := hashes[name]; ok {
+			user.Credentials = &creds
+		} else {
+			withoutPassword++
+		}
+		users = append(users, user)
+	}
+	if err := checkImportTargets(ctx, gc, token, o.toRealm, users); err != nil {
+		return err
+	}
+
+	if o.dryRun {
+		for _, u := range users {
+			rep.note(fmt.Sprintf("Would migrate user %q.", *u.Username))
+		}
+		return rep.print(cmd, o.toRealm, fmt.Sprintf("Dry run: %d user(s) would be migrated from realm %s, %d of them without a password. Nothing changed.", len(users), o.fromRealm, withoutPassword))
+	}
+
+	policy := "FAIL"
+	if o.skipExisting {
+		policy = "SKIP"
+	}
+	imported := 0
+	for batch := range slices.Chunk(users, statePageSize) {
+		res, err := keycloak.ImportPartial(ctx, gc, token, o.toRealm, keycloak.PartialImport{IfResourceExists: policy, Users: batch})
+		if err != nil {
+			if imported > 0 {
+				return fmt.Errorf("failed importing users into realm %s: %w (%d user(s) imported before; the failed batch imported none)", o.toRealm, err, imported)
+			}
+			return fmt.Errorf("failed importing users into realm %s: %w", o.toRealm, err)
+		}
+		for _, r := range res.Results {
+			item := audit.ItemResult{Kind: "user", Realm: o.toRealm, Name: r.ResourceName, ID: r.ID}
+			if r.Action == "SKIPPED" {
+				rep.skip(item, "exists", fmt.Sprintf("User %q already exists in realm %s. Skipped.", r.ResourceName, o.toRealm))
+				continue
+			}
+			imported++
+			recordChange(cmd, o.toRealm, "user "+r.ResourceName, r.ID)
+			rep.add(kcops.Created, item, fmt.Sprintf("Migrated user %q (ID: %s).", r.ResourceName, r.ID))
+		}
+	}
+	return rep.print(cmd, o.toRealm, fmt.Sprintf("Done. Migrated: %d, Skipped: %d, without a password: %d.", len(rep.result.Created), len(rep.result.Skipped), withoutPassword))
+}
+
+// userToImport returns u as a user of a partial import: its profile with
+// the names of its realm roles and the paths of its groups, without IDs.
+func (o *usersMigrateOptions) userToImport(ctx context.Context, gc keycloak.API, token string, u *gocloak.User) (*gocloak.User, error) {
+	name := gocloak.PString(u.Username)
+	roles, err := gc.GetRealmRolesByUserID(ctx, token, o.fromRealm, *u.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing realm roles of user %q in realm %s: %w", name, o.fromRealm, err)
+	}
+	groups, err := gc.GetUserGroups(ctx, token, o.fromRealm, *u.ID, gocloak.GetGroupsParams{})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing groups of user %q in realm %s: %w", name, o.fromRealm, err)
+	}
+	var roleNames, paths []string
+	for _, r := range roles {
+		roleNames = append(roleNames, gocloak.PString(r.Name))
+	}
+	for _, g := range groups {
+		paths = append(paths, gocloak.PString(g.Path))
+	}
+	roleNames = withoutDefaultRoles(o.fromRealm, roleNames)
+	return &gocloak.User{
+		Username:         u.Username,
+		Email:            u.Email,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Enabled:          u.Enabled,
+		EmailVerified:    u.EmailVerified,
+		Attributes:       u.Attributes,
+		RequiredActions:  u.RequiredActions,
+		CreatedTimestamp: u.CreatedTimestamp,
+		RealmRoles:       &roleNames,
+		Groups:           &paths,
+	}, nil
+}
+
+// checkImportTargets fails, naming every one missing, unless the realm roles
+// and groups of users exist in realm.
+func checkImportTargets(ctx context.Context, gc keycloak.API, token, realm string, users []*gocloak.User) error {
+	roles, err := gc.GetRealmRoles(ctx, token, realm, gocloak.GetRoleParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing realm roles in realm %s: %w", realm, err)
+	}
+	groups, err := listGroups(ctx, gc, token, realm)
+	if err != nil {
+		return fmt.Errorf("failed listing groups in realm %s: %w", realm, err)
+	}
+	var missing []string
+	for _, u := range users {
+		for _, r := range derefStrings(u.RealmRoles) {
+			if !slices.ContainsFunc(roles, func(x *gocloak.Role) bool { return gocloak.PString(x.Name) == r }) && !slices.Contains(missing, "role "+r) {
+				missing = append(missing, "role "+r)
+			}
+		}
+		for _, p := range derefStrings(u.Groups) {
+			if !slices.ContainsFunc(groups, func(x *gocloak.Group) bool { return gocloak.PString(x.Path) == p }) && !slices.Contains(missing, "group "+p) {
+				missing = append(missing, "group "+p)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing in realm %s: %s; create them first, nothing was imported", realm, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// readPasswordHashes returns the password credentials, hashes included, of
+// the users of the realm exports files, keyed by username. Files of another
+// realm than realm are refused.
+func readPasswordHashes(files []string, realm string) (map[string][]gocloak.CredentialRepresentation, error) {
+	out := map[string][]gocloak.CredentialRepresentation{}
+	for _, path := range files {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading --export-file: %w", err)
+		}
+		var export gocloak.RealmRepresentation
+		if err := json.Unmarshal(b, &export); err != nil {
+			return nil, fmt.Errorf("invalid --export-file %s: %w", path, err)
+		}
+		if got := gocloak.PString(export.Realm); got != realm {
+			return nil, fmt.Errorf("invalid --export-file %s: it exports realm %q, not %s", path, got, realm)
+		}
+		if export.Users == nil {
+			continue
+		}
+		for _, u := range *export.Users {
+			if u.Credentials == nil {
+				continue
+			}
+			for _, c := range *u.Credentials {
+				if gocloak.PString(c.Type) != "password" {
+					continue
+				}
+				c.ID = nil
+				name := strings.ToLower(gocloak.PString(u.Username))
+				out[name] = append(out[name], c)
+			}
+		}
+	}
+	return out, nil
+}
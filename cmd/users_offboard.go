@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersOffboardOptions holds the flags of `kc users offboard`.
+type usersOffboardOptions struct {
+	username       string
+	realm          string
+	disable        bool
+	delete         bool
+	revokeSessions bool
+	revokeConsents bool
+	exportProfile  string
+}
+
+// offboardProfile is the file written by `kc users offboard --export-profile`:
+// what Keycloak holds about the user, e.g. for a data access request.
+type offboardProfile struct {
+	Realm      string                               `json:"realm"`
+	ExportedAt string                               `json:"exportedAt"`
+	User       *gocloak.User                        `json:"user"`
+	Groups     []string                             `json:"groups"`
+	RealmRoles []string                             `json:"realmRoles"`
+	Consents   []*keycloak.UserConsent              `json:"consents"`
+	Sessions   []*gocloak.UserSessionRepresentation `json:"sessions"`
+}
+
+func newUsersOffboardCmd() *cobra.Command {
+	o := &usersOffboardOptions{}
+	cmd := &cobra.Command{
+		Use:   "offboard",
+		Short: "Offboard a user: export its profile, revoke its sessions and consents, disable or delete it",
+		Long: `Run the offboarding of a user as one operation with a single audit change.
+The steps run in this order: --export-profile writes what Keycloak holds
+about the user (attributes, groups, realm roles, consents and sessions) to a
+new JSON file, created with mode 0600; the user is disabled, so it cannot
+sign in again while the rest runs; --revoke-sessions logs out its sessions;
+--revoke-consents revokes its consents and offline tokens; --delete finally
+deletes it.
+
+Everything is looked up and the profile is written before the first change.
+If a later step fails, the user is enabled again when kc disabled it, and
+the error names the steps already done: revoked sessions and consents
+cannot be restored. kc undo re-enables a disabled user or recreates a
+deleted one, without its credentials, roles and groups.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringVar(&o.username, "username", "", "username to offboard (required)")
+	cmd.Flags().StringVar(&o.realm, "realm", "", "target realm")
+	cmd.Flags().BoolVar(&o.disable, "disable", false, "disable the user")
+	cmd.Flags().BoolVar(&o.delete, "delete", false, "delete the user")
+	cmd.Flags().BoolVar(&o.revokeSessions, "revoke-sessions", false, "log out every session of the user")
+	cmd.Flags().BoolVar(&o.revokeConsents, "revoke-consents", false, "revoke every consent and offline token of the user")
+	cmd.Flags().StringVar(&o.exportProfile, "export-profile", "", "JSON file to create with the profile of the user; must not exist")
+	cmd.MarkFlagsMutuallyExclusive("disable", "delete")
+	return cmd
+}
+
+func (o *usersOffboardOptions) run(cmd *cobra.Command) error {
+	if o.username == "" {
+		return errors.New("missing --username: username is required")
+	}
+	if !o.disable && !o.delete && !o.revokeSessions && !o.revokeConsents && o.exportProfile == "" {
+		return errors.New("nothing to do: provide --disable or --delete, --revoke-sessions, --revoke-consents or --export-profile")
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 120*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &o.username, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return fmt.Errorf("failed looking up user %q in realm %s: %w", o.username, realm, err)
+	}
+	if len(users) == 0 || users[0].ID == nil {
+		return fmt.Errorf("user %q not found in realm %s", o.username, realm)
+	}
+	user := users[0]
+	userID := *user.ID
+	sessions, err := gc.GetUserSessions(ctx, token, realm, userID)
+	if err != nil {
+		return fmt.Errorf("failed listing sessions of user %q in realm %s: %w", o.username, realm, err)
+	}
+	consents, err := keycloak.GetUserConsents(ctx, gc, token, realm, userID)
+	if err != nil {
+		return fmt.Errorf("failed listing consents of user %q in realm %s: %w", o.username, realm, err)
+	}
+
+	rep := newReport(cmd)
+	item := audit.ItemResult{Kind: "user", Realm: realm, Name: o.username, ID: userID}
+	if o.exportProfile != "" {
+		if err := o.writeProfile(ctx, gc, token, realm, user, sessions, consents); err != nil {
+			return err
+		}
+		rep.note(fmt.Sprintf("Exported the profile of user %q to %s.", o.username, o.exportProfile))
+	}
+
+	before := *user
+	var fields []audit.FieldChange
+	var done []string
+	disabled := false
+	// fail rolls back the disable and records what could not be undone
+	fail := func(err error) error {
+		if disabled {
+			if rerr := gc.UpdateUser(ctx, token, realm, before); rerr != nil {
+				err = fmt.Errorf("%w; enabling the user again also failed: %v", err, rerr)
+			} else {
+				fields = fields[1:]
+			}
+		}
+		if len(fields) > 0 {
+			recordChangeWithBefore(cmd, realm, "user "+o.username, userID, before, fields...)
+		}
+		if len(done) > 0 {
+			return fmt.Errorf("%w (already done: %s)", err, strings.Join(done, ", "))
+		}
+		return err
+	}
+
+	if (o.disable || o.delete) && gocloak.PBool(user.Enabled) {
+		update := before
+		update.Enabled = gocloak.BoolP(false)
+		if err := gc.UpdateUser(ctx, token, realm, update); err != nil {
+			return fmt.Errorf("failed disabling user %q in realm %s: %w", o.username, realm, err)
+		}
+		disabled = true
+		fields = append(fields, audit.FieldChange{Field: "enabled", Old: "true", New: "false"})
+		rep.add(kcops.Updated, item, fmt.Sprintf("Disabled user %q in realm %q.", o.username, realm))
+	} else if o.disable {
+		rep.skip(item, "unchanged", fmt.Sprintf("User %q in realm %q is already disabled. Skipped.", o.username, realm))
+	}
+
+	if o.revokeSessions && len(sessions) == 0 {
+		rep.skip(audit.ItemResult{Kind: "userSessions", Realm: realm, Name: o.username, ID: userID}, "no sessions", fmt.Sprintf("User %q has no active sessions in realm %q. Skipped.", o.username, realm))
+	} else if o.revokeSessions {
+		if err := gc.LogoutAllSessions(ctx, token, realm, userID); err != nil {
+			return fail(fmt.Errorf("failed revoking sessions of user %q in realm %s: %w", o.username, realm, err))
+		}
+		done = append(done, fmt.Sprintf("%d session(s) revoked", len(sessions)))
+		fields = append(fields, audit.FieldChange{Field: "sessions", Old: strconv.Itoa(len(sessions)), New: "0"})
+		rep.add(kcops.Deleted, audit.ItemResult{Kind: "userSessions", Realm: realm, Name: o.username, ID: userID},
+			fmt.Sprintf("Revoked %d session(s) of user %q in realm %q.", len(sessions), o.username, realm))
+	}
+
+	if o.revokeConsents && len(consents) == 0 {
+		rep.skip(audit.ItemResult{Kind: "userConsent", Realm: realm, Name: o.username, ID: userID}, "no consents", fmt.Sprintf("User %q has no consents in realm %q. Skipped.", o.username, realm))
+	} else if o.revokeConsents {
+		var revoked []string
+		for _, c := range consents {
+			if err := gc.RevokeUserConsents(ctx, token, realm, userID, c.ClientID); err != nil {
+				if len(revoked) > 0 {
+					done = append(done, "consents revoked for "+strings.Join(revoked, ", "))
+					fields = append(fields, audit.FieldChange{Field: "consents", Old: strings.Join(revoked, ",")})
+				}
+				return fail(fmt.Errorf("failed revoking the consent of user %q to client %q in realm %s: %w", o.username, c.ClientID, realm, err))
+			}
+			revoked = append(revoked, c.ClientID)
+		}
+		done = append(done, "consents revoked")
+		fields = append(fields, audit.FieldChange{Field: "consents", Old: strings.Join(revoked, ",")})
+		rep.add(kcops.Deleted, audit.ItemResult{Kind: "userConsent", Realm: realm, Name: o.username, ID: userID},
+			fmt.Sprintf("Revoked the consents of user %q to %s in realm %q.", o.username, strings.Join(revoked, ", "), realm))
+	}
+
+	if o.delete {
+		if err := gc.DeleteUser(ctx, token, realm, userID); err != nil {
+			return fail(fmt.Errorf("failed deleting user %q in realm %s: %w", o.username, realm, err))
+		}
+		fields = append(fields, audit.FieldChange{Field: "deleted", Old: "false", New: "true"})
+		rep.add(kcops.Deleted, item, fmt.Sprintf("Deleted user %q in realm %q.", o.username, realm))
+	}
+
+	if len(fields) > 0 {
+		recordChangeWithBefore(cmd, realm, "user "+o.username, userID, before, fields...)
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. Offboarded user %q in realm %q.", o.username, realm))
+}
+
+// writeProfile writes the profile of user to the --export-profile file,
+// which must not exist.
+func (o *usersOffboardOptions) writeProfile(ctx context.Context, gc keycloak.API, token, realm string, user *gocloak.User, sessions []*gocloak.UserSessionRepresentation, consents []*keycloak.UserConsent) error {
+	profile := offboardProfile{Realm: realm, ExportedAt: time.Now().UTC().Format(time.RFC3339), User: user, Groups: []string{}, RealmRoles: []string{}, Consents: consents, Sessions: sessions}
+	groups, err := gc.GetUserGroups(ctx, token, realm, *user.ID, gocloak.GetGroupsParams{})
+	if err != nil {
+		return fmt.Errorf("failed listing groups of user %q in realm %s: %w", o.username, realm, err)
+	}
+	for _, g := range groups {
+		profile.Groups = append(profile.Groups, gocloak.PString(g.Path))
+	}
+	roles, err := gc.GetRealmRolesByUserID(ctx, token, realm, *user.ID)
+	if err != nil {
+		return fmt.Errorf("failed listing realm roles of user %q in realm %s: %w", o.username, realm, err)
+	}
+	for _, r := range roles {
+		profile.RealmRoles = append(profile.RealmRoles, gocloak.PString(r.Name))
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.exportProfile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("--export-profile %s already exists; kc does not overwrite it", o.exportProfile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed creating --export-profile: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed writing %s: %w", o.exportProfile, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersResetPasswordOptions holds the flags of `kc users reset-password`.
+type usersResetPasswordOptions struct {
+	usernames       []string
+	file            string
+	generate        bool
+	length          int
+	temporary       bool
+	out             string
+	notifyUser      bool
+	linkLifespan    time.Duration
+	realms          []string
+	allRealms       bool
+	ignoreMissing   bool
+	continueOnError bool
+}
+
+func newUsersResetPasswordCmd() *cobra.Command {
+	o := &usersResetPasswordOptions{}
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Reset the passwords of many users and write the new credentials to a CSV file",
+		Long: `Reset the passwords of many users, e.g. for an onboarding wave or after an
+incident, with a random password per user. The new credentials are written
+only to the --out CSV file (realm,username,password,temporary), created with
+mode 0600; they never appear in the output, the log or the audit. Each row is
+written as soon as its password is set, so the file is complete up to a
+failure.
+
+With --notify-user instead of --generate and --out, no password is set:
+Keycloak emails each user a link to choose a new one (execute-actions email
+with UPDATE_PASSWORD), so no credential exists outside Keycloak. Users need
+an email address and the realm an SMTP server.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringSliceVar(&o.usernames, "username", nil, "username(s) to reset. Repeatable.")
+	cmd.Flags().StringVar(&o.file, "file", "", "file listing usernames, one per line (# starts a comment)")
+	cmd.Flags().BoolVar(&o.generate, "generate", false, "generate a random password per user (required unless --notify-user)")
+	cmd.Flags().IntVar(&o.length, "length", 16, "length of the generated passwords")
+	cmd.Flags().BoolVar(&o.temporary, "temporary", false, "require users to change the password at next login")
+	cmd.Flags().StringVar(&o.out, "out", "", "CSV file to create for the new credentials; must not exist (required unless --notify-user)")
+	addNotifyUserFlags(cmd, &o.notifyUser, &o.linkLifespan)
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "target realm(s). If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "reset users in all realms")
+	addRealmSelectionFlags(cmd)
+	cmd.Flags().BoolVar(&o.ignoreMissing, "ignore-missing", false, "skip users not found instead of failing")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersResetPasswordOptions) run(cmd *cobra.Command) error {
+	usernames := append([]string{}, o.usernames...)
+	if o.file != "" {
+		fromFile, err := readListFile(o.file)
+		if err != nil {
+			return fmt.Errorf("failed reading --file: %w", err)
+		}
+		usernames = append(usernames, fromFile...)
+	}
+	if len(usernames) == 0 {
+		return errors.New("missing --username or --file: provide the users to reset")
+	}
+	if o.notifyUser {
+		if o.generate || o.out != "" || o.temporary || cmd.Flags().Changed("length") {
+			return errors.New("--notify-user cannot be combined with --generate, --length, --temporary or --out: users choose their own password")
+		}
+		return o.runNotify(cmd, usernames)
+	}
+	if !o.generate {
+		return errors.New("missing --generate: passwords are always generated, so none is passed on the command line")
+	}
+	if o.length < 8 {
+		return errors.New("invalid --length: generated passwords have at least 8 characters")
+	}
+	if o.out == "" {
+		return errors.New("missing --out: the new credentials are only written to this file")
+	}
+
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	// created before the first reset, so a bad path changes nothing
+	f, w, err := createCredentialsFile(o.out, "realm", "username", "password", "temporary")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rep := newReport(cmd)
+	// stop fails the command, pointing at the credentials of the users reset
+	// before the failure
+	stop := func(err error) error {
+		if n := len(rep.result.Updated); n > 0 {
+			return fmt.Errorf("%w (the new credentials of the %d user(s) reset before are in %s)", err, n, o.out)
+		}
+		return err
+	}
+	temporary := strconv.FormatBool(o.temporary)
+	for _, realm := range targetRealms {
+		for _, un := range usernames {
+			item := audit.ItemResult{Kind: "user", Realm: realm, Name: un}
+			users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: gocloak.StringP(un), Exact: gocloak.BoolP(true)})
+			if err == nil && (len(users) == 0 || users[0].ID == nil) {
+				if o.ignoreMissing {
+					rep.skip(item, "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+					continue
+				}
+				err = errors.New("user not found")
+			}
+			if err != nil {
+				err := fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return stop(err)
+				}
+				continue
+			}
+			item.ID = *users[0].ID
+
+			password, err := kcops.GeneratePassword(o.length)
+			if err != nil {
+				return stop(err)
+			}
+			if err := gc.SetPassword(ctx, token, item.ID, realm, password, o.temporary); err != nil {
+				err := fmt.Errorf("failed resetting password of user %q in realm %s: %w", un, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return stop(err)
+				}
+				continue
+			}
+			if err := w.Write([]string{realm, un, password, temporary}); err != nil {
+				return stop(fmt.Errorf("password of user %q in realm %s was reset but writing %s failed: %w", un, realm, o.out, err))
+			}
+			if w.Flush(); w.Error() != nil {
+				return stop(fmt.Errorf("password of user %q in realm %s was reset but writing %s failed: %w", un, realm, o.out, w.Error()))
+			}
+			// the audit entry keeps the fact of the reset, never the password
+			generated := "(generated)"
+			fields := appendFieldChange(nil, "password", nil, &generated)
+			fields = appendFieldChange(fields, "temporary", nil, &o.temporary)
+			recordChange(cmd, realm, un, item.ID, fields...)
+			rep.add(kcops.Updated, item, fmt.Sprintf("Reset password of user %q in realm %q.", un, realm))
+		}
+	}
+	if len(rep.result.Updated) > 0 {
+		rep.note(fmt.Sprintf("New credentials written to %s. Hand them over securely and delete the file afterwards.", o.out))
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Reset: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// runNotify sends each user the execute-actions email asking them to set a
+// new password, instead of resetting it.
+func (o *usersResetPasswordOptions) runNotify(cmd *cobra.Command, usernames []string) error {
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	targetRealms, err := resolveRealms(ctx, cmd, gc, token)
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(cmd)
+	for _, realm := range targetRealms {
+		for _, un := range usernames {
+			item := audit.ItemResult{Kind: "user", Realm: realm, Name: un}
+			users, err := gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: gocloak.StringP(un), Exact: gocloak.BoolP(true)})
+			if err == nil && (len(users) == 0 || users[0].ID == nil) {
+				if o.ignoreMissing {
+					rep.skip(item, "not found", fmt.Sprintf("User %q not found in realm %q. Skipped.", un, realm))
+					continue
+				}
+				err = errors.New("user not found")
+			}
+			if err != nil {
+				err := fmt.Errorf("failed looking up user %q in realm %s: %w", un, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return err
+				}
+				continue
+			}
+			item.ID = *users[0].ID
+			if gocloak.PString(users[0].Email) == "" {
+				err := fmt.Errorf("user %q in realm %s has no email to send the link to", un, realm)
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := keycloak.ExecuteActionsEmail(ctx, gc, token, realm, item.ID, []string{keycloak.UpdatePasswordAction}, o.linkLifespan); err != nil {
+				err := fmt.Errorf("failed sending the set-password email to user %q in realm %s: %w", un, realm, err)
+				if err := rep.failOrStop(o.continueOnError, item, err); err != nil {
+					return err
+				}
+				continue
+			}
+			action := keycloak.UpdatePasswordAction
+			recordChange(cmd, realm, un, item.ID, appendFieldChange(nil, "executeActionsEmail", nil, &action)...)
+			rep.add(kcops.Updated, item, fmt.Sprintf("Sent user %q in realm %q an email to set a new password.", un, realm))
+		}
+	}
+	return rep.print(cmd, realmsLabel(cmd, targetRealms), fmt.Sprintf("Done. Notified: %d, Skipped: %d.", len(rep.result.Updated), len(rep.result.Skipped)))
+}
+
+// addNotifyUserFlags adds --notify-user and --link-lifespan, which have
+// Keycloak email users a link to set their password instead of kc setting one.
+func addNotifyUserFlags(cmd *cobra.Command, notify *bool, lifespan *time.Duration) {
+	cmd.Flags().BoolVar(notify, "notify-user", false, "email users a link to set their own password instead of generating one (needs user emails and realm SMTP)")
+	cmd.Flags().DurationVar(lifespan, "link-lifespan", 0, "validity of the emailed link, e.g. 72h (default: realm setting, 12h)")
+}
+
+// createCredentialsFile creates the --out CSV file of generated credentials
+// with mode 0600, refusing to overwrite one, and writes its header.
+func createCredentialsFile(path string, header ...string) (*os.File, *csv.Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, nil, fmt.Errorf("--out %s already exists; kc does not overwrite credential files", path)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating --out: %w", err)
+	}
+	w := csv.NewWriter(f)
+	if err := w.Write(header); err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f, w, nil
+}
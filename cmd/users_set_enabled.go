@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// usersSetEnabledOptions holds the flags of `kc users set-enabled`.
+type usersSetEnabledOptions struct {
+	enabled         bool
+	filters         []string
+	dryRun          bool
+	reportFile      string
+	continueOnError bool
+}
+
+// userFilter is one --filter of `kc users set-enabled`, e.g. lastLogin<2023-01-01.
+type userFilter struct {
+	field  string // lastLogin | created
+	before bool   // < rather than >
+	at     time.Time
+}
+
+// affectedUser is a user selected by the filters, with its last login in
+// milliseconds (0 when none is known).
+type affectedUser struct {
+	user      *gocloak.User
+	lastLogin int64
+	result    string
+}
+
+func newUsersSetEnabledCmd() *cobra.Command {
+	o := &usersSetEnabledOptions{}
+	cmd := &cobra.Command{
+		Use:   "set-enabled",
+		Short: "Enable or disable every user matching filters, e.g. dormant accounts",
+		Long: `Enable or disable in bulk the users of a realm selected by --filter, e.g.
+the accounts without a login since a date:
+
+  kc users set-enabled --enabled=false --filter 'lastLogin<2023-01-01' --dry-run
+
+A filter is a field, < or >, and a date (2006-01-02), an RFC 3339 time or an
+age such as 90d or 2w before now. Fields:
+
+  lastLogin  time of the last LOGIN event; a user without one matches
+             lastLogin<X only when it was created before X
+  created    creation time of the user
+
+Repeated filters must all match. Last logins are read from the login events
+of the realm, so kc refuses lastLogin filters when events are disabled or
+not kept back to the filter date. Service accounts and users already in the
+wanted state are left out. With --report, the affected users and what
+happened to each are written to a new CSV file, also with --dry-run. kc undo
+restores the previous state.`,
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().BoolVar(&o.enabled, "enabled", false, "state to set: --enabled=false disables the users, --enabled=true enables them (required)")
+	cmd.Flags().StringArrayVar(&o.filters, "filter", nil, "select users, e.g. 'lastLogin<2023-01-01' or 'created<90d'. Repeatable; required")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "list the users that would change without changing them")
+	cmd.Flags().StringVar(&o.reportFile, "report", "", "CSV file to create listing the affected users; must not exist")
+	cmd.Flags().String("realm", "", "target realm")
+	addContinueOnErrorFlag(cmd, &o.continueOnError)
+	return cmd
+}
+
+func (o *usersSetEnabledOptions) run(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("enabled") {
+		return errors.New("missing --enabled: give --enabled=false to disable the users or --enabled=true to enable them")
+	}
+	if len(o.filters) == 0 {
+		return errors.New("missing --filter: give at least one, e.g. 'lastLogin<2023-01-01'")
+	}
+	now := time.Now()
+	var filters []userFilter
+	var loginsSince time.Time
+	for _, s := range o.filters {
+		f, err := parseUserFilter(s, now)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, f)
+		if f.field == "lastLogin" && (loginsSince.IsZero() || f.at.Before(loginsSince)) {
+			loginsSince = f.at
+		}
+	}
+	if o.reportFile != "" {
+		if _, err := os.Stat(o.reportFile); err == nil {
+			return fmt.Errorf("--report %s already exists; kc does not overwrite it", o.reportFile)
+		}
+	}
+	realm, err := resolveSingleRealm(cmd)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := commandContext(cmd, 300*time.Second)
+	defer cancel()
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	lastLogins := map[string]int64{}
+	if !loginsSince.IsZero() {
+		if lastLogins, err = loginTimes(ctx, gc, token, realm, loginsSince, now); err != nil {
+			return err
+		}
+	}
+	users, err := fetchPaged(0, 0, statePageSize, func(first, max int) ([]*gocloak.User, error) {
+		return gc.GetUsers(ctx, token, realm, gocloak.GetUsersParams{First: &first, Max: &max})
+	})
+	if err != nil {
+		return fmt.Errorf("failed listing users in realm %s: %w", realm, err)
+	}
+	var affected []*affectedUser
+	for _, u := range users {
+		if u.ServiceAccountClientID != nil || gocloak.PBool(u.Enabled) == o.enabled {
+			continue
+		}
+		a := &affectedUser{user: u, lastLogin: lastLogins[gocloak.PString(u.ID)]}
+		if a.matches(filters) {
+			affected = append(affected, a)
+		}
+	}
+
+	verb, verbing, done := "disable", "disabling", "Disabled"
+	if o.enabled {
+		verb, verbing, done = "enable", "enabling", "Enabled"
+	}
+	rep := newReport(cmd)
+	rep.expect(len(affected))
+	err = func() error {
+		for _, a := range affected {
+			un, id := gocloak.PString(a.user.Username), gocloak.PString(a.user.ID)
+			item := audit.ItemResult{Kind: "user", Realm: realm, Name: un, ID: id}
+			if o.dryRun {
+				a.result = "would " + verb
+				rep.note(fmt.Sprintf("Would %s user %q (last login: %s, created: %s).", verb, un, formatLastLogin(a.lastLogin), formatCreated(a.user.CreatedTimestamp)))
+				continue
+			}
+			update := *a.user
+			update.Enabled = gocloak.BoolP(o.enabled)
+			if err := gc.UpdateUser(ctx, token, realm, update); err != nil {
+				a.result = "failed: " + err.Error()
+				if err := rep.failOrStop(o.continueOnError, item, fmt.Errorf("failed %s user %q in realm %s: %w", verbing, un, realm, err)); err != nil {
+					return err
+				}
+				continue
+			}
+			a.result = strings.ToLower(done)
+			recordChangeWithBefore(cmd, realm, "user "+un, id, *a.user, audit.FieldChange{Field: "enabled", Old: strconv.FormatBool(!o.enabled), New: strconv.FormatBool(o.enabled)})
+			rep.add(kcops.Updated, item, fmt.Sprintf("%s user %q (ID: %s) in realm %q.", done, un, id, realm))
+		}
+		return nil
+	}()
+	// the report lists what happened also when a user stopped the run
+	if o.reportFile != "" {
+		if werr := o.writeReport(realm, affected); werr != nil {
+			return errors.Join(err, werr)
+		}
+		rep.note(fmt.Sprintf("Wrote the %d affected user(s) to %s.", len(affected), o.reportFile))
+	}
+	if err != nil {
+		return err
+	}
+	if o.dryRun {
+		return rep.print(cmd, realm, fmt.Sprintf("Dry run: %d user(s) would be %s.", len(affected), strings.ToLower(done)))
+	}
+	return rep.print(cmd, realm, fmt.Sprintf("Done. %s: %d.", done, len(rep.result.Updated)))
+}
+
+// matches reports whether a passes every filter.
+func (a *affectedUser) matches(filters []userFilter) bool {
+	created := time.UnixMilli(gocloak.PInt64(a.user.CreatedTimestamp))
+	for _, f := range filters {
+		var ok bool
+		switch {
+		case f.field == "created" && f.before:
+			ok = created.Before(f.at)
+		case f.field == "created":
+			ok = created.After(f.at)
+		case a.lastLogin == 0:
+			// no login since the earliest filter date: dormant if it existed then
+			ok = f.before && created.Before(f.at)
+		case f.before:
+			ok = time.UnixMilli(a.lastLogin).Before(f.at)
+		default:
+			ok = time.UnixMilli(a.lastLogin).After(f.at)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// parseUserFilter parses a --filter such as lastLogin<2023-01-01 or created>30d.
+func parseUserFilter(s string, now time.Time) (userFilter, error) {
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		return userFilter{}, fmt.Errorf("invalid --filter %q: use a field, < or > and a date, e.g. lastLogin<2023-01-01", s)
+	}
+	f := userFilter{field: strings.TrimSpace(s[:i]), before: s[i] == '<'}
+	if f.field != "lastLogin" && f.field != "created" {
+		return userFilter{}, fmt.Errorf("invalid --filter %q: unknown field %q (valid: lastLogin, created)", s, f.field)
+	}
+	at, err := parseSince(s[i+1:], now)
+	if err != nil {
+		return userFilter{}, fmt.Errorf("invalid --filter %q: use a date like 2006-01-02, an RFC 3339 time or an age like 90d", s)
+	}
+	f.at = at
+	return f, nil
+}
+
+// loginTimes returns the time in milliseconds of the last LOGIN event since
+// since of each user of realm, keyed by user ID. It fails when the realm does
+// not store login events back to since, as users would look dormant.
+func loginTimes(ctx context.Context, gc keycloak.API, token, realm string, since, now time.Time) (map[string]int64, error) {
+	rep, err := gc.GetRealm(ctx, token, realm)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching realm %s: %w", realm, err)
+	}
+	if !gocloak.PBool(rep.EventsEnabled) {
+		return nil, fmt.Errorf("login events are disabled in realm %s, so last logins are unknown; enable them and keep them long enough before filtering on lastLogin", realm)
+	}
+	if kept := time.Duration(gocloak.PInt64(rep.EventsExpiration)) * time.Second; kept > 0 && now.Add(-kept).After(since) {
+		return nil, fmt.Errorf("realm %s keeps login events for %s only, so logins before %s are unknown; use a later lastLogin date", realm, kept, now.Add(-kept).Format("2006-01-02"))
+	}
+	params := gocloak.GetEventsParams{Type: []string{"LOGIN"}, DateFrom: gocloak.StringP(since.Format("2006-01-02"))}
+	events, err := fetchPaged(0, 0, eventsPageSize, func(first, max int) ([]*gocloak.EventRepresentation, error) {
+		params.First = gocloak.Int32P(int32(first))
+		params.Max = gocloak.Int32P(int32(max))
+		return gc.GetEvents(ctx, token, realm, params)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching login events in realm %s: %w", realm, err)
+	}
+	out := map[string]int64{}
+	for _, e := range events {
+		if id := gocloak.PString(e.UserID); id != "" && e.Time > out[id] {
+			out[id] = e.Time
+		}
+	}
+	return out, nil
+}
+
+// formatLastLogin formats a last login in milliseconds, or "none" when unknown.
+func formatLastLogin(ms int64) string {
+	if ms == 0 {
+		return "none"
+	}
+	return formatCreated(&ms)
+}
+
+// writeReport writes the affected users to the --report CSV file, which must
+// not exist. It holds emails, so it is created with mode 0600.
+func (o *usersSetEnabledOptions) writeReport(realm string, affected []*affectedUser) error {
+	f, err := os.OpenFile(o.reportFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("--report %s already exists; kc does not overwrite it", o.reportFile)
+	}
+	if err != nil {
+		return fmt.Errorf("failed creating --report: %w", err)
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"realm", "username", "id", "email", "created", "lastLogin", "result"})
+	for _, a := range affected {
+		lastLogin := ""
+		if a.lastLogin != 0 {
+			lastLogin = formatEventTime(a.lastLogin)
+		}
+		created := ""
+		if a.user.CreatedTimestamp != nil {
+			created = formatEventTime(*a.user.CreatedTimestamp)
+		}
+		result := a.result
+		if result == "" {
+			result = "not run"
+		}
+		w.Write([]string{realm, gocloak.PString(a.user.Username), gocloak.PString(a.user.ID), gocloak.PString(a.user.Email), created, lastLogin, result})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed writing %s: %w", o.reportFile, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestUsersCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+		want    wantResult
+	}{
+		{
+			name: "creates in several realms",
+			args: []string{"--username", "alice", "--email", "alice@example.org", "--realm", "test", "--realm", "master"},
+			want: wantResult{created: []string{"test/alice", "master/alice"}},
+		},
+		{
+			name: "skips existing users",
+			args: []string{"--username", "existing", "--username", "bob", "--realm", "test"},
+			want: wantResult{created: []string{"test/bob"}, skipped: []string{"test/existing"}},
+		},
+		{
+			name:    "rejects mismatched emails",
+			args:    []string{"--username", "a", "--username", "b", "--username", "c", "--email", "a@x.org", "--email", "b@x.org", "--realm", "test"},
+			wantErr: "invalid --email",
+		},
+		{
+			name:    "rejects a realm-qualified username",
+			args:    []string{"--username", "test/alice", "--realm", "master"},
+			wantErr: "does not take realm-qualified names",
+		},
+		{
+			name: "creates --count users from a --template pattern",
+			args: []string{"--template", "loadtest-{seq}", "--count", "3", "--seq-start", "8", "--realm", "test"},
+			want: wantResult{created: []string{"test/loadtest-8", "test/loadtest-9", "test/loadtest-10"}},
+		},
+		{
+			name: "creates --count users from a --username pattern",
+			args: []string{"--username", "loadtest-{seq}", "--count", "2", "--realm", "test"},
+			want: wantResult{created: []string{"test/loadtest-1", "test/loadtest-2"}},
+		},
+		{
+			name:    "takes the pattern once",
+			args:    []string{"--template", "loadtest-{seq}", "--username", "lt-{seq}", "--count", "2", "--realm", "test"},
+			wantErr: "give the {seq} pattern once",
+		},
+		{
+			name:    "needs a pattern for --count",
+			args:    []string{"--username", "alice", "--count", "2", "--realm", "test"},
+			wantErr: "--count requires a --template or single --username holding {seq}",
+		},
+		{
+			name:    "needs --client-id for --client-role",
+			args:    []string{"--username", "alice", "--client-role", "viewer", "--realm", "test"},
+			wantErr: "missing --client-id when using --client-role",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			if _, err := api.CreateUser(context.Background(), "", "test", gocloak.User{Username: gocloak.StringP("existing")}); err != nil {
+				t.Fatal(err)
+			}
+			_, entry, err := run(t, api, newUsersCmd, append([]string{"users", "create"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				checkEntry(t, entry, "error", wantResult{})
+				return
+			}
+			checkEntry(t, entry, "ok", tt.want)
+			for _, item := range tt.want.created {
+				realm, name, _ := strings.Cut(item, "/")
+				c := changeOf(t, entry, realm, name)
+				if c.Kind != "users_create" || c.ID == "" {
+					t.Errorf("change = %+v", c)
+				}
+				if f, ok := fieldOf(c, "password"); !ok || f.New != "(set)" {
+					t.Errorf("password change = %+v, want it redacted", f)
+				}
+			}
+		})
+	}
+}
+
+func TestUsersUpdate(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		want       wantResult
+		wantFields map[string]string
+	}{
+		{
+			name:       "changes the email",
+			args:       []string{"--username", "alice", "--email", "new@example.org"},
+			want:       wantResult{updated: []string{"test/alice"}},
+			wantFields: map[string]string{"email": "new@example.org"},
+		},
+		{
+			name:       "disables",
+			args:       []string{"--username", "alice", "--enabled=false"},
+			want:       wantResult{updated: []string{"test/alice"}},
+			wantFields: map[string]string{"enabled": "false"},
+		},
+		{
+			name:    "fails on a missing user",
+			args:    []string{"--username", "nobody", "--email", "x@example.org"},
+			wantErr: `user "nobody" not found in realm test`,
+		},
+		{
+			name: "skips a missing user with --ignore-missing",
+			args: []string{"--username", "nobody", "--email", "x@example.org", "--ignore-missing"},
+			want: wantResult{skipped: []string{"test/nobody"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			if _, err := api.CreateUser(context.Background(), "", "test", gocloak.User{Username: gocloak.StringP("alice"), Email: gocloak.StringP("old@example.org"), Enabled: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			_, entry, err := run(t, api, newUsersCmd, append([]string{"users", "update", "--realm", "test"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != "" {
+				checkEntry(t, entry, "error", wantResult{})
+				return
+			}
+			checkEntry(t, entry, "ok", tt.want)
+			if len(tt.wantFields) == 0 {
+				return
+			}
+			c := changeOf(t, entry, "test", "alice")
+			if c.Kind != "users_update" || len(c.Before) == 0 {
+				t.Errorf("change = %+v, want users_update with the user before", c)
+			}
+			for field, want := range tt.wantFields {
+				if f, _ := fieldOf(c, field); f.New != want {
+					t.Errorf("%s = %+v, want %q", field, f, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUsersDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		fail     []string
+		wantErr  string
+		want     wantResult
+		wantLeft int
+	}{
+		{
+			name: "deletes",
+			args: []string{"--username", "alice"},
+			want: wantResult{deleted: []string{"test/alice"}},
+		},
+		{
+			name:     "skips a missing user with --ignore-missing",
+			args:     []string{"--username", "nobody", "--ignore-missing"},
+			want:     wantResult{skipped: []string{"test/nobody"}},
+			wantLeft: 1,
+		},
+		{
+			name:     "reports failures with --continue-on-error",
+			args:     []string{"--username", "alice", "--continue-on-error"},
+			fail:     []string{"DeleteUser"},
+			wantErr:  "1 item(s) failed",
+			want:     wantResult{failed: []string{"test/alice"}},
+			wantLeft: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newTestAPI(t)
+			if _, err := fake.CreateUser(ctx, "", "test", gocloak.User{Username: gocloak.StringP("alice")}); err != nil {
+				t.Fatal(err)
+			}
+			api := &failingAPI{API: fake, fail: map[string]bool{}}
+			for _, name := range tt.fail {
+				api.fail[name] = true
+			}
+			_, entry, err := run(t, api, newUsersCmd, append([]string{"users", "delete", "--realm", "test"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			status := "ok"
+			if tt.wantErr != "" {
+				status = "error"
+			}
+			checkEntry(t, entry, status, tt.want)
+			for _, item := range tt.want.deleted {
+				realm, name, _ := strings.Cut(item, "/")
+				if c := changeOf(t, entry, realm, name); c.Kind != "users_delete" || len(c.Before) == 0 {
+					t.Errorf("change = %+v, want users_delete with the user before", c)
+				}
+			}
+			users, _ := fake.GetUsers(ctx, "", "test", gocloak.GetUsersParams{})
+			if len(users) != tt.wantLeft {
+				t.Errorf("%d user(s) left, want %d", len(users), tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestUsersOffboard(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		fail        []string
+		wantErr     string
+		wantEnabled bool
+		wantExists  bool
+		wantFields  []string
+	}{
+		{
+			name:       "disables",
+			args:       []string{"--disable"},
+			wantExists: true,
+			wantFields: []string{"enabled"},
+		},
+		{
+			name:       "deletes",
+			args:       []string{"--delete"},
+			wantFields: []string{"enabled", "deleted"},
+		},
+		{
+			name:        "enables the user again when the delete fails",
+			args:        []string{"--delete"},
+			fail:        []string{"DeleteUser"},
+			wantErr:     `failed deleting user "alice"`,
+			wantEnabled: true,
+			wantExists:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := newTestAPI(t)
+			if _, err := fake.CreateUser(ctx, "", "test", gocloak.User{Username: gocloak.StringP("alice"), Enabled: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			api := &failingAPI{API: fake, fail: map[string]bool{}}
+			for _, name := range tt.fail {
+				api.fail[name] = true
+			}
+			_, entry, err := run(t, api, newUsersCmd, append([]string{"users", "offboard", "--realm", "test", "--username", "alice"}, tt.args...)...)
+			checkErr(t, err, tt.wantErr)
+			if len(tt.wantFields) == 0 && len(entry.Details.Changes) > 0 {
+				t.Errorf("changes = %+v, want none", entry.Details.Changes)
+			}
+			if len(tt.wantFields) > 0 {
+				c := changeOf(t, entry, "test", "user alice")
+				for _, field := range tt.wantFields {
+					if _, ok := fieldOf(c, field); !ok {
+						t.Errorf("no change of %s in %+v", field, c)
+					}
+				}
+			}
+			users, _ := fake.GetUsers(ctx, "", "test", gocloak.GetUsersParams{Username: gocloak.StringP("alice")})
+			if exists := len(users) == 1; exists != tt.wantExists {
+				t.Fatalf("user exists = %v, want %v", exists, tt.wantExists)
+			}
+			if tt.wantExists && gocloak.PBool(users[0].Enabled) != tt.wantEnabled {
+				t.Errorf("enabled = %v, want %v", gocloak.PBool(users[0].Enabled), tt.wantEnabled)
+			}
+		})
+	}
+}
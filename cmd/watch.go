@@ -0,0 +1,545 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"kc/internal/audit"
+	"kc/internal/keycloak"
+	"kc/internal/rules"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/spf13/cobra"
+)
+
+// watchCursor is the position of the last processed event of one realm and
+// source. Seen holds the keys of the events at exactly Time, which the next
+// poll returns again.
+type watchCursor struct {
+	Time int64    `json:"time"`
+	Seen []string `json:"seen,omitempty"`
+}
+
+// watchOptions holds the flags of `kc watch`.
+type watchOptions struct {
+	rulesFile string
+	since     string
+	stateFile string
+	interval  time.Duration
+	once      bool
+	dryRun    bool
+	realms    []string
+	allRealms bool
+}
+
+func newWatchCmd() *cobra.Command {
+	o := &watchOptions{}
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run rule actions on new Keycloak events",
+		Long: "Poll login and admin events and run the actions of matching rules on the user\n" +
+			"the event is about, e.g. assign default roles when a user registers.\n" +
+			"The realm must store events (Realm settings > Events).",
+		RunE: withErrorEnd(func(cmd *cobra.Command, args []string) error {
+			return o.run(cmd)
+		}),
+	}
+	mutating(cmd, "manage-users")
+	cmd.Flags().StringVar(&o.rulesFile, "rules", "", "rules file (YAML). Required.")
+	cmd.Flags().StringVar(&o.since, "since", "", "also process events newer than this age (e.g. 1h, 2d) or date when there is no saved state (default: only new events)")
+	cmd.Flags().StringVar(&o.stateFile, "state-file", "", "file keeping the position of the last processed event, to resume after a restart")
+	cmd.Flags().DurationVar(&o.interval, "interval", 30*time.Second, "time between polls (overrides interval in the rules file)")
+	cmd.Flags().BoolVar(&o.once, "once", false, "poll once and exit, e.g. from cron")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "log the actions that would run without changing anything")
+	cmd.Flags().StringSliceVar(&o.realms, "realm", nil, "realm(s) for rules without realms. If omitted, uses default or config.json")
+	cmd.Flags().BoolVar(&o.allRealms, "all-realms", false, "apply rules without realms to all realms")
+	addRealmSelectionFlags(cmd)
+	return cmd
+}
+
+func (o *watchOptions) run(cmd *cobra.Command) error {
+	if o.rulesFile == "" {
+		return errors.New("missing --rules")
+	}
+	f, err := rules.Load(o.rulesFile)
+	if err != nil {
+		return err
+	}
+	interval := f.PollInterval()
+	if cmd.Flags().Changed("interval") {
+		if o.interval <= 0 {
+			return errors.New("invalid --interval: must be positive")
+		}
+		interval = o.interval
+	}
+	start := time.Now()
+	if o.since != "" {
+		if start, err = parseSince(o.since, time.Now()); err != nil {
+			return err
+		}
+	}
+	cursors := map[string]*watchCursor{}
+	if o.stateFile != "" {
+		if cursors, err = loadWatchState(o.stateFile); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := commandContext(cmd, 60*time.Second)
+	gc, token, err := connect(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	var selected, named []string
+	for _, r := range f.Rules {
+		if len(r.Realms) == 0 && selected == nil {
+			if selected, err = resolveRealms(ctx, cmd, gc, token); err != nil {
+				cancel()
+				return err
+			}
+		}
+		for _, realm := range r.Realms {
+			if !slices.Contains(named, realm) {
+				named = append(named, realm)
+			}
+		}
+	}
+	// realms named in the rules file bypass resolveRealms, so protected_realms
+	// and --preflight are checked here
+	named = slices.DeleteFunc(named, func(r string) bool { return slices.Contains(selected, r) })
+	if len(named) > 0 {
+		if err := preflight(ctx, cmd, gc, token, named); err != nil {
+			cancel()
+			return err
+		}
+	}
+	cancel()
+	realms := append(slices.Clone(selected), named...)
+	slices.Sort(realms)
+
+	w := &watcher{cmd: cmd, opts: o, rules: f.Rules, selected: selected, cursors: cursors, start: start.UnixMilli()}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Watching %d rule(s) in realm(s) %s every %s\n", len(f.Rules), strings.Join(realms, ", "), interval)
+	for {
+		pollCtx, cancel := commandContext(cmd, 5*time.Minute)
+		err := w.poll(pollCtx, realms)
+		cancel()
+		if err != nil {
+			if o.once || errors.Is(cmd.Context().Err(), context.Canceled) {
+				return err
+			}
+			// a failed poll is retried with the cursors left where they were
+			fmt.Fprintf(cmd.ErrOrStderr(), "[%s] ERROR: %v\n", time.Now().Format(time.RFC3339), err)
+		}
+		if o.once {
+			break
+		}
+		select {
+		case <-cmd.Context().Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+	lines := []string{fmt.Sprintf("Done. Rules run: %d, Failed: %d.", w.ran, w.failed)}
+	printBox(cmd, lines, strings.Join(realms, ", "))
+	return nil
+}
+
+type watcher struct {
+	cmd      *cobra.Command
+	opts     *watchOptions
+	rules    []rules.Rule
+	selected []string
+	cursors  map[string]*watchCursor
+	start    int64
+	ran      int
+	failed   int
+}
+
+// cursor returns the cursor of realm and source, starting at --since (or
+// now) when there is no saved state.
+func (w *watcher) cursor(realm, source string) *watchCursor {
+	key := realm + "/" + source
+	if c, ok := w.cursors[key]; ok {
+		return c
+	}
+	c := &watchCursor{Time: w.start}
+	w.cursors[key] = c
+	return c
+}
+
+// poll fetches the events newer than the cursors and runs the matching rules
+// on them, oldest first.
+func (w *watcher) poll(ctx context.Context, realms []string) error {
+	gc, token, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	for _, realm := range realms {
+		for _, source := range []string{rules.SourceLogin, rules.SourceAdmin} {
+			var active []rules.Rule
+			for _, r := range w.rules {
+				if r.On.Source == source && r.AppliesTo(realm, w.selected) {
+					active = append(active, r)
+				}
+			}
+			if len(active) == 0 {
+				continue
+			}
+			c := w.cursor(realm, source)
+			events, err := fetchWatchEvents(ctx, gc, token, realm, source, c)
+			if err != nil {
+				return fmt.Errorf("failed fetching %s events in realm %s: %w", source, realm, err)
+			}
+			for _, e := range events {
+				for _, r := range active {
+					if r.On.Matches(e.Event) {
+						w.run(ctx, gc, token, r, e.Event)
+					}
+				}
+				c.advance(e.Event.Time.UnixMilli(), e.key)
+			}
+			if w.opts.stateFile != "" {
+				if err := saveWatchState(w.opts.stateFile, w.cursors); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// advance moves the cursor past the event at t with the given key.
+func (c *watchCursor) advance(t int64, key string) {
+	if t > c.Time {
+		c.Time = t
+		c.Seen = nil
+	}
+	if t == c.Time && !slices.Contains(c.Seen, key) {
+		c.Seen = append(c.Seen, key)
+	}
+}
+
+// watchEvent is an event with the key that identifies it within its millisecond.
+type watchEvent struct {
+	rules.Event
+	key string
+}
+
+// fetchWatchEvents returns the events of realm newer than c, oldest first.
+// Keycloak returns events newest first, so pages are read until an event
+// older than the cursor shows up.
+func fetchWatchEvents(ctx context.Context, gc keycloak.API, token, realm, source string, c *watchCursor) ([]watchEvent, error) {
+	// dateFrom has day granularity in the server's time zone
+	dateFrom := time.UnixMilli(c.Time).Add(-24 * time.Hour).Format("2006-01-02")
+	var out []watchEvent
+	for first := 0; ; first += eventsPageSize {
+		var page []watchEvent
+		if source == rules.SourceLogin {
+			params := gocloak.GetEventsParams{
+				DateFrom: &dateFrom,
+				First:    gocloak.Int32P(int32(first)),
+				Max:      gocloak.Int32P(eventsPageSize),
+			}
+			events, err := gc.GetEvents(ctx, token, realm, params)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range events {
+				page = append(page, loginWatchEvent(realm, e))
+			}
+		} else {
+			events, err := keycloak.GetAdminEvents(ctx, gc, token, realm, keycloak.AdminEventsParams{DateFrom: dateFrom, First: first, Max: eventsPageSize})
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range events {
+				page = append(page, adminWatchEvent(realm, e))
+			}
+		}
+		done := len(page) < eventsPageSize
+		for _, e := range page {
+			t := e.Time.UnixMilli()
+			if t < c.Time {
+				done = true
+				continue
+			}
+			if t == c.Time && slices.Contains(c.Seen, e.key) {
+				continue
+			}
+			out = append(out, e)
+		}
+		if done {
+			break
+		}
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+func loginWatchEvent(realm string, e *gocloak.EventRepresentation) watchEvent {
+	ev := rules.Event{
+		Source:    rules.SourceLogin,
+		Realm:     realm,
+		Type:      gocloak.PString(e.Type),
+		Time:      time.UnixMilli(e.Time),
+		UserID:    gocloak.PString(e.UserID),
+		ClientID:  gocloak.PString(e.ClientID),
+		IPAddress: gocloak.PString(e.IPAddress),
+		Details:   e.Details,
+	}
+	key := fmt.Sprintf("%s/%s/%s", ev.Type, ev.UserID, gocloak.PString(e.SessionID))
+	return watchEvent{Event: ev, key: key}
+}
+
+func adminWatchEvent(realm string, e *keycloak.AdminEvent) watchEvent {
+	ev := rules.Event{
+		Source:       rules.SourceAdmin,
+		Realm:        realm,
+		Type:         e.OperationType,
+		Time:         time.UnixMilli(e.Time),
+		ClientID:     e.AuthDetails.ClientID,
+		IPAddress:    e.AuthDetails.IPAddress,
+		ResourceType: e.ResourceType,
+		ResourcePath: e.ResourcePath,
+	}
+	// users/<id> and users/<id>/... are about that user
+	if rest, ok := strings.CutPrefix(e.ResourcePath, "users/"); ok {
+		ev.UserID, _, _ = strings.Cut(rest, "/")
+	}
+	key := fmt.Sprintf("%s/%s/%s", ev.Type, ev.ResourcePath, e.AuthDetails.UserID)
+	return watchEvent{Event: ev, key: key}
+}
+
+// run performs the actions of r for event e and writes one audit entry.
+// Failures are reported and do not stop the watch.
+func (w *watcher) run(ctx context.Context, gc keycloak.API, token string, r rules.Rule, e rules.Event) {
+	start := time.Now()
+	out := w.cmd.OutOrStdout()
+	if e.UserID == "" {
+		fmt.Fprintf(out, "[%s] Rule %q: %s event in realm %q is not about a user. Skipped.\n", start.Format(time.RFC3339), r.Name, e.Type, e.Realm)
+		return
+	}
+	user, err := gc.GetUserByID(ctx, token, e.Realm, e.UserID)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "404") {
+			fmt.Fprintf(out, "[%s] Rule %q: user %s no longer exists in realm %q. Skipped.\n", start.Format(time.RFC3339), r.Name, e.UserID, e.Realm)
+			return
+		}
+		w.report(ctx, r, e, start, fmt.Errorf("failed fetching user %s in realm %s: %w", e.UserID, e.Realm, err))
+		return
+	}
+	e.Username = gocloak.PString(user.Username)
+	var done []string
+	for _, a := range r.Actions {
+		desc, err := applyRuleAction(ctx, gc, token, user, a, e, w.opts.dryRun)
+		if err != nil {
+			w.report(ctx, r, e, start, err)
+			return
+		}
+		done = append(done, desc)
+	}
+	verb := "Ran"
+	if w.opts.dryRun {
+		verb = "Would run"
+	}
+	fmt.Fprintf(out, "[%s] %s rule %q on user %q in realm %q: %s.\n", time.Now().Format(time.RFC3339), verb, r.Name, e.Username, e.Realm, strings.Join(done, "; "))
+	w.ran++
+	if !w.opts.dryRun {
+		actorType, actorID := resolveActor()
+		appendServiceAudit(ctx, w.cmd, "ok", watchRaw(r, e), "", actorType, actorID, "watch", e.Realm, start, time.Now())
+	}
+}
+
+func (w *watcher) report(ctx context.Context, r rules.Rule, e rules.Event, start time.Time, err error) {
+	end := time.Now()
+	fmt.Fprintf(w.cmd.ErrOrStderr(), "[%s] ERROR: rule %q on user %s in realm %q: %v\n", end.Format(time.RFC3339), r.Name, e.UserID, e.Realm, err)
+	w.failed++
+	if !w.opts.dryRun {
+		actorType, actorID := resolveActor()
+		appendServiceAudit(ctx, w.cmd, "error", watchRaw(r, e), "", actorType, actorID, "watch", e.Realm, start, end)
+	}
+}
+
+func watchRaw(r rules.Rule, e rules.Event) string {
+	return fmt.Sprintf("rule %s: %s %s user %s", r.Name, e.Source, e.Type, e.UserID)
+}
+
+// applyRuleAction performs one action on user, or only describes it when
+// dryRun is set, and returns the description.
+func applyRuleAction(ctx context.Context, gc keycloak.API, token string, user *gocloak.User, a rules.Action, e rules.Event, dryRun bool) (string, error) {
+	realm, userID, username := e.Realm, e.UserID, e.Username
+	switch {
+	case len(a.AssignRealmRoles) > 0:
+		names, err := rules.ExpandAll(a.AssignRealmRoles, e)
+		if err != nil {
+			return "", err
+		}
+		desc := "assign realm roles " + strings.Join(names, ", ")
+		if dryRun {
+			return desc, nil
+		}
+		var roles []gocloak.Role
+		for _, rn := range names {
+			role, err := gc.GetRealmRole(ctx, token, realm, rn)
+			if err != nil {
+				return "", fmt.Errorf("failed fetching realm role %q in realm %s: %w", rn, realm, err)
+			}
+			roles = append(roles, *role)
+		}
+		if err := gc.AddRealmRoleToUser(ctx, token, realm, userID, roles); err != nil {
+			return "", fmt.Errorf("failed assigning roles to user %q in realm %s: %w", username, realm, err)
+		}
+		recordChangeAs(ctx, "watch", realm, username, userID, audit.FieldChange{Field: "realmRoles", New: "+" + strings.Join(names, ",")})
+		return desc, nil
+
+	case a.AssignClientRoles != nil:
+		cid, err := rules.Expand(a.AssignClientRoles.Client, e)
+		if err != nil {
+			return "", err
+		}
+		names, err := rules.ExpandAll(a.AssignClientRoles.Roles, e)
+		if err != nil {
+			return "", err
+		}
+		desc := fmt.Sprintf("assign client roles %s of %s", strings.Join(names, ", "), cid)
+		if dryRun {
+			return desc, nil
+		}
+		client, err := getClientByClientID(ctx, gc, token, realm, cid)
+		if err != nil || client.ID == nil {
+			return "", fmt.Errorf("client %q not found in realm %s", cid, realm)
+		}
+		var roles []gocloak.Role
+		for _, rn := range names {
+			role, err := gc.GetClientRole(ctx, token, realm, *client.ID, rn)
+			if err != nil {
+				return "", fmt.Errorf("failed fetching client role %q for client %s in realm %s: %w", rn, cid, realm, err)
+			}
+			roles = append(roles, *role)
+		}
+		if err := gc.AddClientRoleToUser(ctx, token, realm, *client.ID, userID, roles); err != nil {
+			return "", fmt.Errorf("failed assigning client roles to user %q in realm %s: %w", username, realm, err)
+		}
+		recordChangeAs(ctx, "watch", realm, username, userID, audit.FieldChange{Field: "clientRoles", New: "+" + cid + ":" + strings.Join(names, ",")})
+		return desc, nil
+
+	case len(a.AddToGroups) > 0:
+		paths, err := rules.ExpandAll(a.AddToGroups, e)
+		if err != nil {
+			return "", err
+		}
+		desc := "add to groups " + strings.Join(paths, ", ")
+		if dryRun {
+			return desc, nil
+		}
+		for _, p := range paths {
+			group, err := gc.GetGroupByPath(ctx, token, realm, p)
+			if err != nil || group.ID == nil {
+				return "", fmt.Errorf("group %q not found in realm %s", p, realm)
+			}
+			if err := gc.AddUserToGroup(ctx, token, realm, userID, *group.ID); err != nil {
+				return "", fmt.Errorf("failed adding user %q to group %s in realm %s: %w", username, p, realm, err)
+			}
+		}
+		recordChangeAs(ctx, "watch", realm, username, userID, audit.FieldChange{Field: "groups", New: "+" + strings.Join(paths, ",")})
+		return desc, nil
+
+	case len(a.SetAttributes) > 0:
+		attrs := map[string][]string{}
+		if user.Attributes != nil {
+			attrs = *user.Attributes
+		}
+		keys := make([]string, 0, len(a.SetAttributes))
+		for k := range a.SetAttributes {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		var fields []audit.FieldChange
+		var parts []string
+		for _, k := range keys {
+			v, err := rules.Expand(a.SetAttributes[k], e)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, audit.FieldChange{Field: "attributes." + k, Old: strings.Join(attrs[k], ","), New: v})
+			attrs[k] = []string{v}
+			parts = append(parts, k+"="+v)
+		}
+		desc := "set attributes " + strings.Join(parts, ", ")
+		if dryRun {
+			return desc, nil
+		}
+		user.Attributes = &attrs
+		if err := gc.UpdateUser(ctx, token, realm, *user); err != nil {
+			return "", fmt.Errorf("failed updating user %q in realm %s: %w", username, realm, err)
+		}
+		recordChangeAs(ctx, "watch", realm, username, userID, fields...)
+		return desc, nil
+
+	default:
+		names, err := rules.ExpandAll(a.RequiredActions, e)
+		if err != nil {
+			return "", err
+		}
+		desc := "add required actions " + strings.Join(names, ", ")
+		if dryRun {
+			return desc, nil
+		}
+		var current []string
+		if user.RequiredActions != nil {
+			current = *user.RequiredActions
+		}
+		updated := append([]string{}, current...)
+		for _, n := range names {
+			if !slices.Contains(updated, n) {
+				updated = append(updated, n)
+			}
+		}
+		user.RequiredActions = &updated
+		if err := gc.UpdateUser(ctx, token, realm, *user); err != nil {
+			return "", fmt.Errorf("failed updating user %q in realm %s: %w", username, realm, err)
+		}
+		recordChangeAs(ctx, "watch", realm, username, userID, audit.FieldChange{Field: "requiredActions", Old: strings.Join(current, ","), New: strings.Join(updated, ",")})
+		return desc, nil
+	}
+}
+
+func loadWatchState(path string) (map[string]*watchCursor, error) {
+	cursors := map[string]*watchCursor{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cursors, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	return cursors, nil
+}
+
+// saveWatchState writes the cursors through a temporary file so a crash never
+// leaves a truncated state behind.
+func saveWatchState(path string, cursors map[string]*watchCursor) error {
+	data, err := json.MarshalIndent(cursors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func init() {
+	rootCmd.AddCommand(newWatchCmd())
+}
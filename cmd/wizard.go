@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"kc/internal/config"
+
+	"github.com/spf13/cobra"
+)
+
+// errWizardAborted is returned when the final confirmation of a wizard is declined.
+var errWizardAborted = errors.New("aborted: nothing was changed")
+
+// wizard asks the questions of an --interactive command one by one. Empty
+// answers take the default shown in brackets, and invalid answers are asked
+// again with the reason.
+type wizard struct {
+	cmd *cobra.Command
+	in  *bufio.Reader
+}
+
+func newWizard(cmd *cobra.Command) *wizard {
+	return &wizard{cmd: cmd, in: cmdState(cmd).stdin(cmd)}
+}
+
+func (w *wizard) readLine() (string, error) {
+	line, err := w.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		if errors.Is(err, io.EOF) {
+			return "", errors.New("interactive input ended before all questions were answered")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// ask prompts for a string. validate may be nil.
+func (w *wizard) ask(label, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(w.cmd.OutOrStdout(), "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(w.cmd.OutOrStdout(), "%s: ", label)
+		}
+		answer, err := w.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(w.cmd.OutOrStdout(), "  %v\n", err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// askBool prompts for yes or no.
+func (w *wizard) askBool(label string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		fmt.Fprintf(w.cmd.OutOrStdout(), "%s [%s]: ", label, hint)
+		answer, err := w.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(w.cmd.OutOrStdout(), "  answer y or n")
+	}
+}
+
+// askList prompts for a comma-separated list. validate, when set, checks each item.
+func (w *wizard) askList(label string, def []string, validate func(string) error) ([]string, error) {
+	answer, err := w.ask(label+" (comma-separated)", strings.Join(def, ","), func(s string) error {
+		if validate == nil {
+			return nil
+		}
+		for _, item := range splitList(s) {
+			if err := validate(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return splitList(answer), nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// askJira asks for the ticket unless --jira was given, as roles create -i does.
+func (w *wizard) askJira() error {
+	st := cmdState(w.cmd)
+	if st.jira != "" {
+		return nil
+	}
+	v, err := w.ask("Jira ticket (optional, leave empty to skip)", "", nil)
+	st.jira = v
+	return err
+}
+
+// askRealms asks for the target realms unless they were selected with flags.
+// "*" selects all realms.
+func (w *wizard) askRealms(realms *[]string, all *bool) error {
+	if *all || len(*realms) > 0 || w.realmsSelected() {
+		return nil
+	}
+	def := defaultRealm
+	if def == "" {
+		def = config.Global.Realm
+	}
+	list, err := w.askList("Target realm(s), * for all realms", []string{def}, nil)
+	if err != nil {
+		return err
+	}
+	if len(list) == 1 && list[0] == "*" {
+		*all = true
+		return nil
+	}
+	*realms = list
+	return nil
+}
+
+// confirm shows what is about to be sent and asks to go ahead.
+func (w *wizard) confirm(action, realms string, payload interface{}) error {
+	data, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w.cmd.OutOrStdout(), "\nAbout to %s in %s:\n%s\n", action, realms, data)
+	ok, err := w.askBool("Proceed?", true)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errWizardAborted
+	}
+	return nil
+}
+
+// realmsSelected reports whether --realm-match or --realm-file was given.
+func (w *wizard) realmsSelected() bool {
+	return w.cmd.Flags().Changed("realm-match") || w.cmd.Flags().Changed("realm-file")
+}
+
+// realmsLabel describes the realms chosen in the wizard for the confirmation.
+func (w *wizard) realmsLabel(realms []string, all bool) string {
+	switch {
+	case all:
+		return "all realms"
+	case w.realmsSelected():
+		return "the selected realms"
+	case len(realms) == 0:
+		return "the default realm"
+	}
+	return "realm(s) " + strings.Join(realms, ", ")
+}
+
+// validateName accepts identifiers such as usernames and client-ids.
+func validateName(s string) error {
+	if s == "" {
+		return errors.New("a value is required")
+	}
+	if strings.ContainsAny(s, " \t") {
+		return errors.New("must not contain spaces")
+	}
+	return nil
+}
+
+func validateEmail(s string) error {
+	if s == "" {
+		return nil
+	}
+	if a, err := mail.ParseAddress(s); err != nil || a.Address != s {
+		return fmt.Errorf("%q is not a valid email address", s)
+	}
+	return nil
+}
+
+// validateURL accepts empty values and absolute http(s) URLs.
+func validateURL(s string) error {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http(s) URL", s)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package e2e runs the kc binary against a real Keycloak and checks the
+// state it leaves on the server. Its tests are behind the e2e build tag; run
+// them from the repository root with `make e2e`, which is
+// `go test -tags e2e ./e2e/...`.
+//
+// By default the tests start one Keycloak container per version in
+// KEYCLOAK_VERSIONS with testcontainers-go (Docker is required) and remove it
+// afterwards. KC_E2E_SERVER runs the scenarios against an existing server
+// instead, and KC_E2E_FAKE=1 against the in-memory Keycloak of KC_FAKE=1,
+// which needs neither.
+package e2e
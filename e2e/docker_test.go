@@ -0,0 +1,50 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// startupTimeout bounds how long a Keycloak container may take to answer.
+const startupTimeout = 3 * time.Minute
+
+// containerTarget runs Keycloak version in dev mode in a container that is
+// removed when the test of the target ends.
+func containerTarget(image, version, user, pass string) *target {
+	return &target{
+		name: "keycloak " + version,
+		start: func(t *testing.T, _ string) string {
+			ctx := context.Background()
+			c, err := testcontainers.Run(ctx, image+":"+version,
+				testcontainers.WithExposedPorts("8080/tcp"),
+				testcontainers.WithEnv(map[string]string{
+					// Keycloak 26 renamed the bootstrap admin variables
+					"KC_BOOTSTRAP_ADMIN_USERNAME": user,
+					"KC_BOOTSTRAP_ADMIN_PASSWORD": pass,
+					"KEYCLOAK_ADMIN":              user,
+					"KEYCLOAK_ADMIN_PASSWORD":     pass,
+				}),
+				testcontainers.WithCmd("start-dev"),
+				testcontainers.WithWaitStrategy(wait.ForHTTP("/realms/master").WithPort("8080/tcp").WithStartupTimeout(startupTimeout)),
+			)
+			testcontainers.CleanupContainer(t, c)
+			if err != nil {
+				t.Fatalf("starting keycloak %s: %v", version, err)
+			}
+			url, err := c.PortEndpoint(ctx, "8080/tcp", "http")
+			if err != nil {
+				t.Fatalf("keycloak %s port: %v", version, err)
+			}
+			return url
+		},
+		connect: adminLogin(user, pass),
+		user:    user,
+		pass:    pass,
+	}
+}
@@ -0,0 +1,168 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// bin is the kc binary built by TestMain.
+var bin string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "kc-e2e-bin-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "e2e:", err)
+		os.Exit(1)
+	}
+	bin = filepath.Join(dir, "kc")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "e2e: building kc: %v\n%s", err, out)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// env returns the environment variable key, or def when it is unset.
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// TestE2E runs the scenarios against every target, each in its own subtest.
+func TestE2E(t *testing.T) {
+	user := env("KC_E2E_ADMIN_USER", "admin")
+	pass := env("KC_E2E_ADMIN_PASSWORD", "admin")
+	var targets []*target
+	switch {
+	case os.Getenv("KC_E2E_FAKE") == "1":
+		targets = append(targets, fakeTarget())
+	case os.Getenv("KC_E2E_SERVER") != "":
+		targets = append(targets, serverTarget(os.Getenv("KC_E2E_SERVER"), user, pass))
+	default:
+		image := env("KEYCLOAK_IMAGE", "quay.io/keycloak/keycloak")
+		for _, v := range strings.Split(env("KEYCLOAK_VERSIONS", "26.0"), ",") {
+			targets = append(targets, containerTarget(image, strings.TrimSpace(v), user, pass))
+		}
+	}
+	for _, tg := range targets {
+		t.Run(tg.name, func(t *testing.T) {
+			runTarget(t, tg)
+		})
+	}
+}
+
+// target is a Keycloak the scenarios run against.
+type target struct {
+	name string
+	// start brings the server up, stopping it in a cleanup of t, and returns
+	// its URL. work is the directory kc runs in.
+	start func(t *testing.T, work string) string
+	// env is added to the environment of kc.
+	env []string
+	// connect returns an admin session to check server state.
+	connect func(ctx context.Context, url string) (keycloak.API, string, error)
+	user    string
+	pass    string
+}
+
+func serverTarget(url, user, pass string) *target {
+	return &target{
+		name:    url,
+		start:   func(*testing.T, string) string { return url },
+		connect: adminLogin(user, pass),
+		user:    user,
+		pass:    pass,
+	}
+}
+
+func fakeTarget() *target {
+	t := &target{name: "fake"}
+	t.start = func(_ *testing.T, work string) string {
+		state := filepath.Join(work, "fake-state.json")
+		t.env = []string{"KC_FAKE=1", "KC_FAKE_STATE=" + state}
+		// every check reads what the last kc run saved
+		t.connect = func(context.Context, string) (keycloak.API, string, error) {
+			f, err := keycloak.LoadFake(state)
+			return f, "", err
+		}
+		return "http://fake.invalid"
+	}
+	return t
+}
+
+func adminLogin(user, pass string) func(ctx context.Context, url string) (keycloak.API, string, error) {
+	return func(ctx context.Context, url string) (keycloak.API, string, error) {
+		gc := gocloak.NewClient(url)
+		tok, err := gc.LoginAdmin(ctx, user, pass, "master")
+		if err != nil {
+			return nil, "", err
+		}
+		return &keycloak.Server{GoCloak: gc}, tok.AccessToken, nil
+	}
+}
+
+// workDir is where kc runs and keeps kc.log, the audit file and the config.
+// KC_E2E_KEEP=1 keeps it for inspection.
+func workDir(t *testing.T) string {
+	if os.Getenv("KC_E2E_KEEP") != "1" {
+		return t.TempDir()
+	}
+	work, err := os.MkdirTemp("", "kc-e2e-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("work directory: %s", work)
+	return work
+}
+
+// runTarget runs the scenarios in order against tg, one subtest each.
+func runTarget(t *testing.T, tg *target) {
+	work := workDir(t)
+	url := tg.start(t, work)
+	cfg := filepath.Join(work, "config.json")
+	data, _ := json.MarshalIndent(map[string]string{
+		"server_url": url,
+		"auth_realm": "master",
+		"realm":      "master",
+		"client_id":  "admin-cli",
+		"grant_type": "password",
+		"username":   tg.user,
+		"password":   tg.pass,
+		"log_dir":    work,
+		"audit_dir":  work,
+	}, "", "  ")
+	if err := os.WriteFile(cfg, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mf := filepath.Join(work, "e2e.yaml")
+	if err := os.WriteFile(mf, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), scenarioTimeout)
+			defer cancel()
+			s.run(ctx, &harness{T: t, target: tg, url: url, work: work, config: cfg, manifest: mf})
+		})
+	}
+}
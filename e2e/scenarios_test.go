@@ -0,0 +1,330 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"kc/internal/keycloak"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// scenarioTimeout bounds the kc runs and checks of one scenario.
+const scenarioTimeout = 2 * time.Minute
+
+// e2eRealms are created by the first scenario and removed by the last one.
+var e2eRealms = []string{"e2e-a", "e2e-b"}
+
+const manifest = `realms:
+  - name: e2e-a
+    enabled: true
+    roles:
+      - name: app-user
+        description: E2E users
+    clientScopes:
+      - name: e2e-scope
+  - name: e2e-b
+    enabled: true
+    roles:
+      - name: app-user
+        description: E2E users
+    clientScopes:
+      - name: e2e-scope
+`
+
+// scenario is one documented behavior: kc commands followed by checks of
+// the server state. Scenarios run in order and build on each other.
+type scenario struct {
+	name string
+	run  func(ctx context.Context, h *harness)
+}
+
+// harness is the test of one scenario against one target.
+type harness struct {
+	*testing.T
+	target   *target
+	url      string
+	work     string
+	config   string
+	manifest string
+}
+
+// exec runs the binary in the work directory and returns its output and the
+// error of a non-zero exit.
+func (h *harness) exec(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, bin, append([]string{"--config", h.config}, args...)...)
+	cmd.Dir = h.work
+	cmd.Env = append(os.Environ(), h.target.env...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	return out.String(), err
+}
+
+// kc runs kc and returns its output; a non-zero exit fails the test.
+func (h *harness) kc(ctx context.Context, args ...string) string {
+	h.Helper()
+	out, err := h.exec(ctx, args...)
+	if err != nil {
+		h.Fatalf("kc %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return out
+}
+
+// kcFails is kc for commands expected to exit non-zero.
+func (h *harness) kcFails(ctx context.Context, args ...string) string {
+	h.Helper()
+	out, err := h.exec(ctx, args...)
+	if err == nil {
+		h.Fatalf("kc %s succeeded, expected an error\n%s", strings.Join(args, " "), out)
+	}
+	return out
+}
+
+// admin returns an admin session to check the server state.
+func (h *harness) admin(ctx context.Context) (keycloak.API, string) {
+	h.Helper()
+	api, token, err := h.target.connect(ctx, h.url)
+	if err != nil {
+		h.Fatalf("admin login: %v", err)
+	}
+	return api, token
+}
+
+// forEachRealm runs check with an admin session for every e2e realm and
+// reports what it returns.
+func (h *harness) forEachRealm(ctx context.Context, check func(api keycloak.API, token, realm string) error) {
+	h.Helper()
+	api, token := h.admin(ctx)
+	for _, realm := range e2eRealms {
+		if err := check(api, token, realm); err != nil {
+			h.Errorf("realm %s: %v", realm, err)
+		}
+	}
+}
+
+func findUser(ctx context.Context, api keycloak.API, token, realm, username string) (*gocloak.User, error) {
+	users, err := api.GetUsers(ctx, token, realm, gocloak.GetUsersParams{Username: &username, Exact: gocloak.BoolP(true)})
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return users[0], nil
+}
+
+func findClient(ctx context.Context, api keycloak.API, token, realm, clientID string) (*gocloak.Client, error) {
+	clients, err := api.GetClients(ctx, token, realm, gocloak.GetClientsParams{ClientID: &clientID})
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("client %q not found", clientID)
+	}
+	return clients[0], nil
+}
+
+func scopeNames(scopes []*gocloak.ClientScope) []string {
+	var names []string
+	for _, s := range scopes {
+		names = append(names, gocloak.PString(s.Name))
+	}
+	return names
+}
+
+func expect(field, got, want string) error {
+	if got != want {
+		return fmt.Errorf("%s is %q, want %q", field, got, want)
+	}
+	return nil
+}
+
+var scenarios = []scenario{
+	{"apply creates realms, roles and client scopes", func(ctx context.Context, h *harness) {
+		// leftovers of an interrupted run against a shared server
+		api, token := h.admin(ctx)
+		for _, realm := range e2eRealms {
+			_ = api.DeleteRealm(ctx, token, realm)
+		}
+		h.kc(ctx, "apply", "--file", h.manifest)
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			role, err := api.GetRealmRole(ctx, token, realm, "app-user")
+			if err != nil {
+				return err
+			}
+			if err := expect("role description", gocloak.PString(role.Description), "E2E users"); err != nil {
+				return err
+			}
+			scopes, err := api.GetClientScopes(ctx, token, realm)
+			if err != nil {
+				return err
+			}
+			if !slices.Contains(scopeNames(scopes), "e2e-scope") {
+				return errors.New("client scope e2e-scope not created")
+			}
+			return nil
+		})
+	}},
+	{"diff finds no drift right after apply", func(ctx context.Context, h *harness) {
+		h.kc(ctx, "diff", "--file", h.manifest, "--exit-code")
+	}},
+	{"users create adds N users with their roles to every matched realm", func(ctx context.Context, h *harness) {
+		h.kc(ctx, "users", "create", "--realm-match", "e2e-*",
+			"--username", "e2e-alice", "--username", "e2e-bob",
+			"--email", "alice@e2e.test", "--email", "bob@e2e.test",
+			"--password", "E2e-Passw0rd!", "--realm-role", "app-user")
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			for _, un := range []string{"alice", "bob"} {
+				u, err := findUser(ctx, api, token, realm, "e2e-"+un)
+				if err != nil {
+					return err
+				}
+				if u == nil {
+					return fmt.Errorf("user e2e-%s not created", un)
+				}
+				if err := expect("email of e2e-"+un, gocloak.PString(u.Email), un+"@e2e.test"); err != nil {
+					return err
+				}
+				roles, err := api.GetRealmRolesByUserID(ctx, token, realm, *u.ID)
+				if err != nil {
+					return err
+				}
+				if !slices.ContainsFunc(roles, func(r *gocloak.Role) bool { return gocloak.PString(r.Name) == "app-user" }) {
+					return fmt.Errorf("user e2e-%s lacks role app-user", un)
+				}
+			}
+			return nil
+		})
+	}},
+	{"users create skips existing users", func(ctx context.Context, h *harness) {
+		out := h.kc(ctx, "users", "create", "--realm-match", "e2e-*", "--username", "e2e-alice")
+		if !strings.Contains(out, "Created: 0, Skipped: 2.") {
+			h.Errorf("expected both realms to skip e2e-alice\n%s", out)
+		}
+	}},
+	{"users update applies one value to all and N values per user", func(ctx context.Context, h *harness) {
+		h.kc(ctx, "users", "update", "--realm-match", "e2e-*",
+			"--username", "e2e-alice", "--username", "e2e-bob",
+			"--first-name", "Tester", "--last-name", "Alpha", "--last-name", "Beta")
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			for un, last := range map[string]string{"e2e-alice": "Alpha", "e2e-bob": "Beta"} {
+				u, err := findUser(ctx, api, token, realm, un)
+				if err != nil || u == nil {
+					return fmt.Errorf("user %s: %v", un, err)
+				}
+				if err := expect("first name of "+un, gocloak.PString(u.FirstName), "Tester"); err != nil {
+					return err
+				}
+				if err := expect("last name of "+un, gocloak.PString(u.LastName), last); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}},
+	{"clients create sets redirect URIs and web origins on every client", func(ctx context.Context, h *harness) {
+		h.kc(ctx, "clients", "create", "--realm-match", "e2e-*",
+			"--client-id", "e2e-portal", "--public", "true",
+			"--redirect-uri", "https://portal.e2e.test/*", "--web-origin", "+")
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			c, err := findClient(ctx, api, token, realm, "e2e-portal")
+			if err != nil {
+				return err
+			}
+			if !gocloak.PBool(c.PublicClient) {
+				return errors.New("e2e-portal is not public")
+			}
+			if c.RedirectURIs == nil || !slices.Equal(*c.RedirectURIs, []string{"https://portal.e2e.test/*"}) {
+				return fmt.Errorf("redirect URIs are %v", gocloak.PStringSlice(c.RedirectURIs))
+			}
+			if c.WebOrigins == nil || !slices.Equal(*c.WebOrigins, []string{"+"}) {
+				return fmt.Errorf("web origins are %v", gocloak.PStringSlice(c.WebOrigins))
+			}
+			return nil
+		})
+	}},
+	{"client-roles create adds the role to the client", func(ctx context.Context, h *harness) {
+		h.kc(ctx, "client-roles", "create", "--realm-match", "e2e-*", "--client-id", "e2e-portal", "--name", "viewer")
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			c, err := findClient(ctx, api, token, realm, "e2e-portal")
+			if err != nil {
+				return err
+			}
+			_, err = api.GetClientRole(ctx, token, realm, *c.ID, "viewer")
+			return err
+		})
+	}},
+	{"clients scopes assign and remove an optional scope", func(ctx context.Context, h *harness) {
+		optional := func(want bool) {
+			h.Helper()
+			h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+				c, err := findClient(ctx, api, token, realm, "e2e-portal")
+				if err != nil {
+					return err
+				}
+				scopes, err := api.GetClientsOptionalScopes(ctx, token, realm, *c.ID)
+				if err != nil {
+					return err
+				}
+				if got := slices.Contains(scopeNames(scopes), "e2e-scope"); got != want {
+					return fmt.Errorf("e2e-scope assigned as optional: %v, want %v", got, want)
+				}
+				return nil
+			})
+		}
+		h.kc(ctx, "clients", "scopes", "assign", "--realm-match", "e2e-*", "--client-id", "e2e-portal", "--scope", "e2e-scope", "--type", "optional")
+		optional(true)
+		h.kc(ctx, "clients", "scopes", "remove", "--realm-match", "e2e-*", "--client-id", "e2e-portal", "--scope", "e2e-scope", "--type", "optional")
+		optional(false)
+	}},
+	{"missing entities fail unless --ignore-missing is given", func(ctx context.Context, h *harness) {
+		h.kcFails(ctx, "clients", "delete", "--realm", "e2e-a", "--client-id", "e2e-ghost")
+		h.kc(ctx, "clients", "delete", "--realm", "e2e-a", "--client-id", "e2e-ghost", "--ignore-missing")
+	}},
+	{"users delete --continue-on-error removes the users and reports the missing ones", func(ctx context.Context, h *harness) {
+		out := h.kcFails(ctx, "users", "delete", "--realm-match", "e2e-*", "--username", "e2e-ghost", "--username", "e2e-bob", "--continue-on-error")
+		if !strings.Contains(out, "Deleted: 2, Skipped: 0.") || !strings.Contains(out, "Failed: 2.") {
+			h.Errorf("expected e2e-bob deleted and e2e-ghost failed in both realms\n%s", out)
+		}
+		h.forEachRealm(ctx, func(api keycloak.API, token, realm string) error {
+			for un, want := range map[string]bool{"e2e-alice": true, "e2e-bob": false} {
+				u, err := findUser(ctx, api, token, realm, un)
+				if err != nil {
+					return err
+				}
+				if (u != nil) != want {
+					return fmt.Errorf("user %s exists: %v, want %v", un, u != nil, want)
+				}
+			}
+			return nil
+		})
+	}},
+	{"audit log records every change", func(ctx context.Context, h *harness) {
+		out := h.kc(ctx, "audit", "list")
+		for _, kind := range []string{"apply", "users_create", "users_update", "clients_create", "users_delete"} {
+			if !strings.Contains(out, kind) {
+				h.Errorf("audit list has no %s entry\n%s", kind, out)
+			}
+		}
+	}},
+	{"cleanup removes the e2e realms", func(ctx context.Context, h *harness) {
+		api, token := h.admin(ctx)
+		for _, realm := range e2eRealms {
+			if err := api.DeleteRealm(ctx, token, realm); err != nil {
+				h.Errorf("deleting realm %s: %v", realm, err)
+			}
+		}
+	}},
+}
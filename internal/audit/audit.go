@@ -1,13 +1,44 @@
 package audit
 
 import (
+	"crypto/rand"
 	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// SchemaVersion identifies the current column layout of the audit CSV.
+// Bump it whenever columns are added, renamed or reordered.
+const SchemaVersion = 3
+
+// columns is the header of the current schema, in order.
+var columns = []string{
+	"id",
+	"timestamp",
+	"status",
+	"command_path",
+	"raw_command",
+	"jira",
+	"actor_type",
+	"actor_id",
+	"auth_realm",
+	"change_kind",
+	"target_realms",
+	"duration",
+	"details",
+	"schema_version",
+}
+
 type Entry struct {
+	ID           string
 	Timestamp    time.Time
 	Status       string
 	CommandPath  string
@@ -19,18 +50,51 @@ type Entry struct {
 	ChangeKind   string
 	TargetRealms string
 	Duration     string
-	Details      string
+	Details      Details
 }
 
+// FileName is the name of the audit CSV file in its directory.
+const FileName = "kc_audit.csv"
+
 var (
 	mu      sync.Mutex
-	csvPath = "kc_audit.csv"
+	csvPath = FileName
 )
 
+func (e Entry) record() []string {
+	return []string{
+		e.ID,
+		e.Timestamp.Format(time.RFC3339),
+		e.Status,
+		e.CommandPath,
+		e.RawCommand,
+		e.Jira,
+		e.ActorType,
+		e.ActorID,
+		e.AuthRealm,
+		e.ChangeKind,
+		e.TargetRealms,
+		e.Duration,
+		e.Details.Encode(),
+		strconv.Itoa(SchemaVersion),
+	}
+}
+
+// NewID returns a unique, time-ordered identifier for an audit entry,
+// e.g. 20240601T101500-3fa9c2d1.
+func NewID(t time.Time) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return t.UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
+}
+
 func Append(e Entry) error {
 	mu.Lock()
 	defer mu.Unlock()
 
+	// a failed rotation must not lose the entry
+	_ = Rotate(csvPath, auditRotation, time.Now())
+
 	fileExists := true
 	if _, err := os.Stat(csvPath); err != nil {
 		if os.IsNotExist(err) {
@@ -40,6 +104,16 @@ func Append(e Entry) error {
 		}
 	}
 
+	width := len(columns)
+	if fileExists {
+		header, err := migrate(csvPath)
+		if err != nil {
+			return err
+		}
+		// columns kept from a newer version stay empty
+		width = max(width, len(header))
+	}
+
 	f, err := os.OpenFile(csvPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return err
@@ -49,44 +123,113 @@ func Append(e Entry) error {
 	w := csv.NewWriter(f)
 
 	if !fileExists {
-		header := []string{
-			"timestamp",
-			"status",
-			"command_path",
-			"raw_command",
-			"jira",
-			"actor_type",
-			"actor_id",
-			"auth_realm",
-			"change_kind",
-			"target_realms",
-			"duration",
-			"details",
-		}
-		if err := w.Write(header); err != nil {
+		if err := w.Write(columns); err != nil {
 			return err
 		}
 	}
 
-	record := []string{
-		e.Timestamp.Format(time.RFC3339),
-		e.Status,
-		e.CommandPath,
-		e.RawCommand,
-		e.Jira,
-		e.ActorType,
-		e.ActorID,
-		e.AuthRealm,
-		e.ChangeKind,
-		e.TargetRealms,
-		e.Duration,
-		e.Details,
-	}
-
-	if err := w.Write(record); err != nil {
+	rec := e.record()
+	rec = append(rec, make([]string, width-len(rec))...)
+	if err := w.Write(rec); err != nil {
 		return err
 	}
 
 	w.Flush()
 	return w.Error()
 }
+
+// migrate rewrites an audit file whose header differs from the current schema,
+// mapping existing values by column name so old rows never end up misaligned.
+// Columns it does not know, e.g. written by a newer version, are kept after
+// the known ones. The original file is kept next to it, named after the
+// schema version of its last row, e.g. kc_audit.csv.v2.bak; if that backup
+// exists already the file is left as it is and migrate fails. It returns the
+// header of the file.
+func migrate(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		f.Close()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	target := slices.Clone(columns)
+	for _, h := range header {
+		if !slices.Contains(target, h) {
+			target = append(target, h)
+		}
+	}
+	if slices.Equal(header, target) {
+		f.Close()
+		return header, nil
+	}
+	rows, err := r.ReadAll()
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		if _, dup := idx[h]; !dup {
+			idx[h] = i
+		}
+	}
+	_, versioned := idx["schema_version"]
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".kc_audit-*.csv")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	w := csv.NewWriter(tmp)
+	if err := w.Write(target); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	for _, row := range rows {
+		out := make([]string, len(target))
+		for i, c := range target {
+			if j, ok := idx[c]; ok && j < len(row) {
+				out[i] = row[j]
+			}
+		}
+		if !versioned {
+			// files without the column predate versioning
+			out[slices.Index(target, "schema_version")] = "1"
+		}
+		if err := w.Write(out); err != nil {
+			tmp.Close()
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	version := 1
+	if i, ok := idx["schema_version"]; ok && len(rows) > 0 && i < len(rows[len(rows)-1]) {
+		if v, err := strconv.Atoi(rows[len(rows)-1][i]); err == nil && v > 0 {
+			version = v
+		}
+	}
+	bak := fmt.Sprintf("%s.v%d.bak", path, version)
+	if _, err := os.Lstat(bak); err == nil {
+		return nil, fmt.Errorf("cannot migrate %s to schema version %d: the backup %s exists already; move it away first", path, SchemaVersion, bak)
+	}
+	if err := os.Rename(path, bak); err != nil {
+		return nil, err
+	}
+	return target, os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		wantBak string
+	}{
+		{
+			name:    "unversioned",
+			file:    "id,timestamp,status\n1,2024-01-01T00:00:00Z,ok\n",
+			wantBak: "kc_audit.csv.v1.bak",
+		},
+		{
+			name:    "an older version",
+			file:    "id,status,schema_version\n1,ok,1\n2,ok,2\n",
+			wantBak: "kc_audit.csv.v2.bak",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, FileName)
+			writeText(t, path, tt.file)
+
+			header, err := migrate(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(header, ",") != strings.Join(columns, ",") {
+				t.Errorf("header = %v, want %v", header, columns)
+			}
+			if b, err := os.ReadFile(filepath.Join(dir, tt.wantBak)); err != nil || string(b) != tt.file {
+				t.Errorf("backup %s = %q, %v; want the original file", tt.wantBak, b, err)
+			}
+			entries, err := Read(path)
+			if err != nil || len(entries) == 0 || entries[0].ID != "1" {
+				t.Errorf("entries = %+v, %v; want the rows kept", entries, err)
+			}
+
+			// a file of the same version again, e.g. restored: the backup
+			// is kept and the file left alone
+			writeText(t, path, tt.file)
+			if _, err := migrate(path); err == nil || !strings.Contains(err.Error(), "exists already") {
+				t.Errorf("second migrate = %v, want the backup refused", err)
+			}
+			if b, _ := os.ReadFile(path); string(b) != tt.file {
+				t.Errorf("file = %q, want it unchanged", b)
+			}
+		})
+	}
+}
+
+func TestAppendMigrates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	SetPath(path)
+	t.Cleanup(func() { SetPath(FileName) })
+	writeText(t, path, "id,status\nold,ok\n")
+	if err := Append(Entry{ID: "new", Timestamp: time.Now(), Status: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != "old" || entries[1].ID != "new" {
+		t.Errorf("entries = %+v, want old and new", entries)
+	}
+}
+
+func writeText(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
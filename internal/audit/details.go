@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FieldChange is a single field-level modification of an entity.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new"`
+}
+
+// Change describes one entity affected by a command.
+type Change struct {
+	Kind   string        `json:"kind"`
+	Realm  string        `json:"realm,omitempty"`
+	Entity string        `json:"entity"`
+	ID     string        `json:"id,omitempty"`
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Before is the entity representation read before it was modified, used by undo.
+	Before json.RawMessage `json:"before,omitempty"`
+}
+
+// String renders the change as a short summary, e.g. "clients_update app1: enabled false→true".
+func (c Change) String() string {
+	var b strings.Builder
+	b.WriteString(c.Kind)
+	b.WriteString(" ")
+	b.WriteString(c.Entity)
+	if len(c.Fields) > 0 {
+		parts := make([]string, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			parts = append(parts, fmt.Sprintf("%s %s→%s", f.Field, f.Old, f.New))
+		}
+		b.WriteString(": ")
+		b.WriteString(strings.Join(parts, ", "))
+	}
+	return b.String()
+}
+
+// ItemResult is one item handled by a command, e.g. a user in a realm.
+type ItemResult struct {
+	// Kind is the entity type, named as in manifests: user, role, client,
+	// clientRole, clientScope, realm, group or scopeAssignment, or
+	// organization, organizationMember, organizationIdp, initialAccess,
+	// registrationPolicy, adminPermission, adminPolicy or adminRole. Client
+	// roles and scope assignments are named <clientId>/<role or scope>,
+	// organization members and identity providers
+	// <organization>/<username or alias>,
+	// registration policies <anonymous|authenticated>/<name>, admin
+	// permissions users, client:<clientId> or group:<path>, followed by
+	// /<scope> for a single scope, and admin roles <grantee>/<role>.
+	Kind  string `json:"kind"`
+	Realm string `json:"realm,omitempty"`
+	Name  string `json:"name"`
+	ID    string `json:"id,omitempty"`
+	// Status is what happened to the item: created, updated, deleted,
+	// skipped or failed. Set on the items of Result.Items.
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Result is the outcome of a command, grouped by what happened to each item.
+// Commands print it with --output json and store it in the audit entry.
+type Result struct {
+	Created []ItemResult `json:"created"`
+	Updated []ItemResult `json:"updated"`
+	Deleted []ItemResult `json:"deleted"`
+	Skipped []ItemResult `json:"skipped"`
+	Errors  []ItemResult `json:"errors"`
+	// Items lists the same items in the order they were handled, each with
+	// its Status, so a run can be followed item by item.
+	Items []ItemResult `json:"items"`
+}
+
+// IsEmpty reports whether no item was handled.
+func (r *Result) IsEmpty() bool {
+	return r == nil || len(r.Created)+len(r.Updated)+len(r.Deleted)+len(r.Skipped)+len(r.Errors) == 0
+}
+
+// MarshalJSON encodes missing groups as empty lists rather than null, so
+// consumers can iterate them unconditionally.
+func (r Result) MarshalJSON() ([]byte, error) {
+	type plain Result
+	for _, l := range []*[]ItemResult{&r.Created, &r.Updated, &r.Deleted, &r.Skipped, &r.Errors, &r.Items} {
+		if *l == nil {
+			*l = []ItemResult{}
+		}
+	}
+	return json.Marshal(plain(r))
+}
+
+// AllItems returns Items, or for entries written before Items existed, the
+// items of the groups with their Status set, in group order.
+func (r *Result) AllItems() []ItemResult {
+	if r == nil {
+		return nil
+	}
+	if len(r.Items) > 0 {
+		return r.Items
+	}
+	var out []ItemResult
+	for _, g := range []struct {
+		status string
+		items  []ItemResult
+	}{{"created", r.Created}, {"updated", r.Updated}, {"deleted", r.Deleted}, {"skipped", r.Skipped}, {"failed", r.Errors}} {
+		for _, it := range g.items {
+			it.Status = g.status
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// Approval identifies the reviewed plan an entry applied and who approved it.
+type Approval struct {
+	PlanHash   string `json:"planHash"`
+	ApprovedBy string `json:"approvedBy"`
+	PlannedBy  string `json:"plannedBy,omitempty"`
+}
+
+// Details is the structured payload stored in the details column of an audit entry.
+type Details struct {
+	Changes  []Change  `json:"changes,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
+	Approval *Approval `json:"approval,omitempty"`
+}
+
+// IsEmpty reports whether there is nothing worth persisting.
+func (d Details) IsEmpty() bool {
+	return len(d.Changes) == 0 && d.Result.IsEmpty() && d.Approval == nil
+}
+
+// Encode serializes the details as compact JSON, or "" when empty.
+func (d Details) Encode() string {
+	if d.IsEmpty() {
+		return ""
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// DecodeDetails parses a details column previously written by Encode.
+// Legacy free-text values are preserved as a single change note.
+func DecodeDetails(s string) Details {
+	var d Details
+	if strings.TrimSpace(s) == "" {
+		return d
+	}
+	if err := json.Unmarshal([]byte(s), &d); err != nil {
+		return Details{Changes: []Change{{Kind: "note", Entity: s}}}
+	}
+	return d
+}
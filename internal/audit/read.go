@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// Path returns the location of the audit CSV file.
+func Path() string {
+	return csvPath
+}
+
+// SetPath sets the audit CSV file Append writes to, e.g. FileName in the
+// audit directory of the profile.
+func SetPath(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	csvPath = path
+}
+
+// Read loads all entries from an audit CSV file. Columns are mapped by header
+// name so files written by older versions remain readable.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	idx := make(map[string]int, len(header))
+	for i, h := range header {
+		idx[h] = i
+	}
+	col := func(rec []string, name string) string {
+		i, ok := idx[name]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return rec[i]
+	}
+
+	var entries []Entry
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		ts, _ := time.Parse(time.RFC3339, col(rec, "timestamp"))
+		entries = append(entries, Entry{
+			ID:           col(rec, "id"),
+			Timestamp:    ts,
+			Status:       col(rec, "status"),
+			CommandPath:  col(rec, "command_path"),
+			RawCommand:   col(rec, "raw_command"),
+			Jira:         col(rec, "jira"),
+			ActorType:    col(rec, "actor_type"),
+			ActorID:      col(rec, "actor_id"),
+			AuthRealm:    col(rec, "auth_realm"),
+			ChangeKind:   col(rec, "change_kind"),
+			TargetRealms: col(rec, "target_realms"),
+			Duration:     col(rec, "duration"),
+			Details:      DecodeDetails(col(rec, "details")),
+		})
+	}
+	return entries, nil
+}
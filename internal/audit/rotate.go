@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rotation caps the growth of an append-only file such as kc.log or the audit
+// file. The zero value never rotates.
+type Rotation struct {
+	// MaxSize rotates the file once it reaches this many bytes (0 = never).
+	MaxSize int64
+	// MaxFiles is the number of rotated files kept (0 = all).
+	MaxFiles int
+	// MaxAge deletes rotated files last written longer ago (0 = never).
+	MaxAge time.Duration
+}
+
+// rotatedLayout is the timestamp added to the name of a rotated file, e.g.
+// kc_audit-20240601T101500.csv.
+const rotatedLayout = "20060102T150405"
+
+var auditRotation Rotation
+
+// SetRotation sets the rotation Append applies to the audit file.
+func SetRotation(r Rotation) {
+	mu.Lock()
+	defer mu.Unlock()
+	auditRotation = r
+}
+
+// Rotate renames path aside with the time of now in its name once it reaches
+// r.MaxSize, then deletes the rotated files of path beyond r.MaxFiles or
+// older than r.MaxAge. A missing path is not an error.
+func Rotate(path string, r Rotation, now time.Time) error {
+	if r.MaxSize <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Size() < r.MaxSize {
+		return nil
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	target := base + "-" + now.UTC().Format(rotatedLayout) + ext
+	// two rotations within a second must not overwrite each other
+	for i := 1; fileExists(target); i++ {
+		target = base + "-" + now.UTC().Format(rotatedLayout) + "." + strconv.Itoa(i) + ext
+	}
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	return prune(path, r, now)
+}
+
+// prune deletes the rotated files of path that r no longer keeps.
+func prune(path string, r Rotation, now time.Time) error {
+	rotated, err := Rotated(path)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, f := range rotated {
+		// rotated is oldest first
+		drop := r.MaxFiles > 0 && i < len(rotated)-r.MaxFiles
+		if !drop && r.MaxAge > 0 {
+			if fi, err := os.Stat(f); err == nil && now.Sub(fi.ModTime()) > r.MaxAge {
+				drop = true
+			}
+		}
+		if drop {
+			if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rotated returns the rotated files of path, oldest first.
+func Rotated(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "-"
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, e := range entries {
+		n := e.Name()
+		if e.IsDir() || !strings.HasPrefix(n, prefix) || !strings.HasSuffix(n, ext) {
+			continue
+		}
+		stamp, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(n, prefix), ext), ".")
+		if _, err := time.Parse(rotatedLayout, stamp); err != nil {
+			continue
+		}
+		out = append(out, filepath.Join(filepath.Dir(path), n))
+	}
+	// the timestamp sorts by name; a .N suffix sorts after its second
+	slices.SortFunc(out, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ext), strings.TrimSuffix(b, ext))
+	})
+	return out, nil
+}
+
+// Files returns the audit files to read for the whole history: the rotated
+// files of the current audit file, oldest first, followed by it.
+func Files() ([]string, error) {
+	rotated, err := Rotated(Path())
+	if err != nil {
+		return nil, err
+	}
+	return append(rotated, Path()), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
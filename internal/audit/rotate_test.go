@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.WriteFile(path, make([]byte, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names
+}
+
+func TestRotate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// existing are rotated files present before, with their age
+		existing map[string]time.Duration
+		size     int
+		rotation Rotation
+		want     []string
+		// wantCurrent reports whether kc.log is left in place
+		wantCurrent bool
+	}{
+		{
+			name:        "below the size",
+			size:        10,
+			rotation:    Rotation{MaxSize: 100},
+			wantCurrent: true,
+		},
+		{
+			name:        "no rotation configured",
+			size:        1000,
+			wantCurrent: true,
+		},
+		{
+			name:     "at the size",
+			size:     100,
+			rotation: Rotation{MaxSize: 100},
+			want:     []string{"kc-20240601T101500.log"},
+		},
+		{
+			name:     "twice in a second",
+			existing: map[string]time.Duration{"kc-20240601T101500.log": 0},
+			size:     100,
+			rotation: Rotation{MaxSize: 100},
+			want:     []string{"kc-20240601T101500.log", "kc-20240601T101500.1.log"},
+		},
+		{
+			name:     "keeps MaxFiles",
+			existing: map[string]time.Duration{"kc-20240501T000000.log": 0, "kc-20240515T000000.log": 0},
+			size:     100,
+			rotation: Rotation{MaxSize: 100, MaxFiles: 2},
+			want:     []string{"kc-20240515T000000.log", "kc-20240601T101500.log"},
+		},
+		{
+			name:     "drops files older than MaxAge",
+			existing: map[string]time.Duration{"kc-20240501T000000.log": 40 * 24 * time.Hour, "kc-20240530T000000.log": 24 * time.Hour},
+			size:     100,
+			rotation: Rotation{MaxSize: 100, MaxAge: 30 * 24 * time.Hour},
+			want:     []string{"kc-20240530T000000.log", "kc-20240601T101500.log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "kc.log")
+			for name, age := range tt.existing {
+				p := filepath.Join(dir, name)
+				writeFile(t, p, 1)
+				if err := os.Chtimes(p, now.Add(-age), now.Add(-age)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// not a rotated file of kc.log, whatever its age
+			writeFile(t, filepath.Join(dir, "kc-notes.log"), 1)
+			writeFile(t, path, tt.size)
+
+			if err := Rotate(path, tt.rotation, now); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Rotated(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(baseNames(got), tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("rotated = %v, want %v", baseNames(got), tt.want)
+			}
+			if _, err := os.Stat(path); (err == nil) != tt.wantCurrent {
+				t.Errorf("kc.log exists = %v, want %v", err == nil, tt.wantCurrent)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "kc-notes.log")); err != nil {
+				t.Errorf("an unrelated file was removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestRotateMissingFile(t *testing.T) {
+	if err := Rotate(filepath.Join(t.TempDir(), "kc.log"), Rotation{MaxSize: 1}, time.Now()); err != nil {
+		t.Errorf("Rotate of a missing file = %v, want nil", err)
+	}
+}
+
+func TestAppendRotates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	SetPath(path)
+	SetRotation(Rotation{MaxSize: 1})
+	t.Cleanup(func() {
+		SetPath(FileName)
+		SetRotation(Rotation{})
+	})
+	for _, id := range []string{"1", "2"} {
+		if err := Append(Entry{ID: id, Timestamp: time.Now(), Status: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[1] != path {
+		t.Fatalf("files = %v, want one rotated file and %s", files, path)
+	}
+	var ids []string
+	for _, f := range files {
+		entries, err := Read(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			ids = append(ids, e.ID)
+		}
+	}
+	if !slices.Equal(ids, []string{"1", "2"}) {
+		t.Errorf("entries across files = %v, want [1 2]", ids)
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -9,18 +11,50 @@ import (
 )
 
 type Config struct {
-	ServerURL  string `mapstructure:"server_url"`
-	AuthRealm  string `mapstructure:"auth_realm"`
-	Realm      string `mapstructure:"realm"`
-	ClientID   string `mapstructure:"client_id"`
+	ServerURL    string `mapstructure:"server_url"`
+	AuthRealm    string `mapstructure:"auth_realm"`
+	Realm        string `mapstructure:"realm"`
+	ClientID     string `mapstructure:"client_id"`
 	ClientSecret string `mapstructure:"client_secret"`
-	Username   string `mapstructure:"username"`
-	Password   string `mapstructure:"password"`
-	GrantType  string `mapstructure:"grant_type"`
+	Username     string `mapstructure:"username"`
+	Password     string `mapstructure:"password"`
+	GrantType    string `mapstructure:"grant_type"`
+	// RateLimit caps admin API requests per second (0 = unlimited).
+	RateLimit float64 `mapstructure:"rate_limit"`
+	RateBurst int     `mapstructure:"rate_burst"`
+	// StatsPushgateway and StatsStatsd receive the API metrics of every
+	// command: a Prometheus Pushgateway URL and a statsd host:port.
+	StatsPushgateway string `mapstructure:"stats_pushgateway"`
+	StatsStatsd      string `mapstructure:"stats_statsd"`
+	// ServeTokens maps caller names to the bearer tokens accepted by `kc serve`.
+	ServeTokens map[string]string `mapstructure:"serve_tokens"`
+	// ProtectedRealms are realm names or patterns that commands changing the
+	// server only target with --allow-protected and a confirmation.
+	ProtectedRealms []string `mapstructure:"protected_realms"`
+	// NotifyWebhook receives a summary after every command that changes the
+	// server, shaped for NotifyFormat: slack, teams or generic (the default).
+	NotifyWebhook string `mapstructure:"notify_webhook"`
+	NotifyFormat  string `mapstructure:"notify_format"`
+	// Profile separates the log and audit files of each configuration; it
+	// defaults to the name of the config file (default for config.json).
+	Profile string `mapstructure:"profile"`
+	// LogDir and AuditDir hold kc.log and kc_audit.csv; by default the
+	// directory of the profile under the OS data directory (see ProfileDir).
+	LogDir   string `mapstructure:"log_dir"`
+	AuditDir string `mapstructure:"audit_dir"`
+	// LogRotation and AuditRotation cap the growth of kc.log and the audit
+	// file; unset, they grow unbounded.
+	LogRotation   Rotation `mapstructure:"log_rotation"`
+	AuditRotation Rotation `mapstructure:"audit_rotation"`
+	// Lang is the language of the text output: en (the default) or es.
+	Lang string `mapstructure:"lang"`
 }
 
 var Global Config
 
+// ErrNotFound is returned by Load when no path is given and no config.json is found.
+var ErrNotFound = errors.New("config.json not found")
+
 func findDefaultConfigPath() string {
 	exe, err := os.Executable()
 	if err == nil {
@@ -38,32 +72,81 @@ func findDefaultConfigPath() string {
 	return ""
 }
 
+// Load reads the config file at path, or the default config.json when path
+// is empty, into Global.
 func Load(path string) error {
-	v := viper.New()
+	c, err := Read(path)
+	if err != nil {
+		return err
+	}
+	Global = c
+	return nil
+}
+
+// Path returns the config file Load reads for path: path itself, the default
+// config.json when one is found, or config.json in the current directory.
+func Path(path string) string {
 	if path != "" {
-		v.SetConfigFile(path)
-	} else {
-		def := findDefaultConfigPath()
-		if def == "" {
-			return errors.New("config.json not found")
+		return path
+	}
+	if def := findDefaultConfigPath(); def != "" {
+		return def
+	}
+	return "config.json"
+}
+
+// Read reads and checks the config file at path, or the default config.json
+// when path is empty.
+func Read(path string) (Config, error) {
+	if path == "" {
+		path = findDefaultConfigPath()
+		if path == "" {
+			return Config{}, ErrNotFound
 		}
-		v.SetConfigFile(def)
 	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return Config{}, err
+	}
+	if c.Profile == "" {
+		c.Profile = profileFromPath(path)
+	}
+	return c, nil
+}
+
+// Parse decodes and checks the content of a config file.
+func Parse(data []byte) (Config, error) {
+	var c Config
+	v := viper.New()
 	v.SetConfigType("json")
-	if err := v.ReadInConfig(); err != nil {
-		return err
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return c, err
 	}
-	if err := v.Unmarshal(&Global); err != nil {
-		return err
+	if err := v.Unmarshal(&c); err != nil {
+		return c, err
 	}
-	if Global.ServerURL == "" {
-		return errors.New("server_url is required")
+	if c.ServerURL == "" {
+		return c, errors.New("server_url is required")
 	}
-	if Global.AuthRealm == "" {
-		Global.AuthRealm = "master"
+	if c.AuthRealm == "" {
+		c.AuthRealm = "master"
 	}
-	if Global.GrantType == "" {
-		Global.GrantType = "client_credentials"
+	if c.GrantType == "" {
+		c.GrantType = "client_credentials"
 	}
-	return nil
+	for key, r := range map[string]Rotation{"log_rotation": c.LogRotation, "audit_rotation": c.AuditRotation} {
+		if r.MaxSizeMB < 0 || r.MaxFiles < 0 || r.MaxAgeDays < 0 {
+			return c, fmt.Errorf("invalid %s: values cannot be negative", key)
+		}
+	}
+	switch c.NotifyFormat {
+	case "", "generic", "slack", "teams":
+	default:
+		return c, fmt.Errorf("invalid notify_format %q: must be 'generic', 'slack' or 'teams'", c.NotifyFormat)
+	}
+	return c, nil
 }
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultProfile is the profile of config.json and of offline runs without one.
+const DefaultProfile = "default"
+
+// profileFromPath names the profile of a config file after its base name,
+// e.g. prod for prod.json, and DefaultProfile for config.json.
+func profileFromPath(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if name == "" || name == "config" {
+		return DefaultProfile
+	}
+	return name
+}
+
+// ProfileConfig returns the config file of the profile name: name.json in the
+// directory of base, the config file in use (config.json for the default
+// profile), or name itself when it is a path to a file.
+func ProfileConfig(name, base string) string {
+	if strings.ContainsAny(name, `/\`) || filepath.Ext(name) == ".json" {
+		return name
+	}
+	if name == DefaultProfile {
+		name = "config"
+	}
+	return filepath.Join(filepath.Dir(base), name+".json")
+}
+
+// dataHome returns where kc keeps its files on this OS: %APPDATA% on
+// Windows, ~/Library/Application Support on macOS and $XDG_STATE_HOME
+// (~/.local/state) elsewhere.
+func dataHome() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		return os.UserConfigDir()
+	}
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return d, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// ProfileDir returns the default directory of the log and audit files of the
+// loaded profile, e.g. ~/.local/state/kc/prod.
+func ProfileDir() (string, error) {
+	home, err := dataHome()
+	if err != nil {
+		return "", err
+	}
+	profile := Global.Profile
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	return filepath.Join(home, "kc", profile), nil
+}
+
+// Rotation is the rotation of a file kc appends to, kc.log or the audit file.
+type Rotation struct {
+	// MaxSizeMB rotates the file once it reaches this size (0 = never).
+	MaxSizeMB int `mapstructure:"max_size_mb"`
+	// MaxFiles is the number of rotated files kept (0 = all).
+	MaxFiles int `mapstructure:"max_files"`
+	// MaxAgeDays deletes rotated files older than this (0 = never).
+	MaxAgeDays int `mapstructure:"max_age_days"`
+}
+
+// LogDir returns log_dir, or the profile directory when unset.
+func LogDir() (string, error) {
+	if Global.LogDir != "" {
+		return os.ExpandEnv(Global.LogDir), nil
+	}
+	return ProfileDir()
+}
+
+// AuditDir returns audit_dir, or the profile directory when unset.
+func AuditDir() (string, error) {
+	if Global.AuditDir != "" {
+		return os.ExpandEnv(Global.AuditDir), nil
+	}
+	return ProfileDir()
+}
@@ -0,0 +1,322 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ReadDocument reads the config file at path as a JSON object, keeping the
+// keys kc does not know, so that `kc config set` rewrites only what it changes.
+func ReadDocument(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]any{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON object: %w", path, err)
+	}
+	return doc, nil
+}
+
+// MarshalDocument encodes doc as indented JSON with the keys in the order of
+// Config, followed by the keys kc does not know, sorted.
+func MarshalDocument(doc map[string]any) ([]byte, error) {
+	var keys []string
+	for _, f := range configFields() {
+		if _, ok := doc[f.tag]; ok {
+			keys = append(keys, f.tag)
+		}
+	}
+	var unknown []string
+	for k := range doc {
+		if !slices.Contains(keys, k) {
+			unknown = append(unknown, k)
+		}
+	}
+	slices.Sort(unknown)
+
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, k := range append(keys, unknown...) {
+		name, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.MarshalIndent(doc[k], "  ", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", name, value)
+	}
+	b.WriteString("\n}\n")
+	return b.Bytes(), nil
+}
+
+// WriteDocument replaces the config file at path with doc. The file is only
+// readable by its owner, as it holds credentials.
+func WriteDocument(path string, doc map[string]any) error {
+	data, err := MarshalDocument(doc)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".kc-config-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// field is a key of config.json.
+type field struct {
+	tag string
+	typ reflect.Type
+}
+
+// fieldsOf returns the keys of config.json a struct type decodes, in order.
+func fieldsOf(t reflect.Type) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" {
+			out = append(out, field{tag, t.Field(i).Type})
+		}
+	}
+	return out
+}
+
+func configFields() []field {
+	return fieldsOf(reflect.TypeOf(Config{}))
+}
+
+func lookupField(fields []field, tag string) (field, bool) {
+	for _, f := range fields {
+		if f.tag == tag {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// Keys lists the keys `kc config set` accepts. Keys of objects are dotted,
+// e.g. log_rotation.max_size_mb and serve_tokens.<name>.
+func Keys() []string {
+	var out []string
+	for _, f := range configFields() {
+		switch f.typ.Kind() {
+		case reflect.Struct:
+			for _, sub := range fieldsOf(f.typ) {
+				out = append(out, f.tag+"."+sub.tag)
+			}
+		case reflect.Map:
+			out = append(out, f.tag+".<name>")
+		default:
+			out = append(out, f.tag)
+		}
+	}
+	return out
+}
+
+// Set sets key of doc to value, converted to the type of the key: a number,
+// a comma-separated list or a string. An empty value removes the key.
+func Set(doc map[string]any, key, value string) error {
+	top, sub, dotted := strings.Cut(key, ".")
+	f, ok := lookupField(configFields(), top)
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	switch f.typ.Kind() {
+	case reflect.Struct:
+		sf, ok := lookupField(fieldsOf(f.typ), sub)
+		if !dotted || !ok {
+			return fmt.Errorf("unknown key %q: use one of %s", key, strings.Join(subKeys(f), ", "))
+		}
+		obj, _ := doc[top].(map[string]any)
+		if obj == nil {
+			obj = map[string]any{}
+		}
+		if err := setValue(obj, sub, sf.typ, key, value); err != nil {
+			return err
+		}
+		setObject(doc, top, obj)
+		return nil
+	case reflect.Map:
+		if !dotted || sub == "" {
+			return fmt.Errorf("invalid key %q: use %s.<name>", key, top)
+		}
+		obj, _ := doc[top].(map[string]any)
+		if obj == nil {
+			obj = map[string]any{}
+		}
+		if err := setValue(obj, sub, f.typ.Elem(), key, value); err != nil {
+			return err
+		}
+		setObject(doc, top, obj)
+		return nil
+	}
+	if dotted {
+		return fmt.Errorf("invalid key %q: %s is not an object", key, top)
+	}
+	return setValue(doc, key, f.typ, key, value)
+}
+
+func subKeys(f field) []string {
+	var out []string
+	for _, sub := range fieldsOf(f.typ) {
+		out = append(out, f.tag+"."+sub.tag)
+	}
+	return out
+}
+
+// setObject stores obj under key, or removes the key once obj is empty.
+func setObject(doc map[string]any, key string, obj map[string]any) {
+	if len(obj) == 0 {
+		delete(doc, key)
+		return
+	}
+	doc[key] = obj
+}
+
+func setValue(obj map[string]any, name string, t reflect.Type, key, value string) error {
+	if value == "" {
+		delete(obj, name)
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be a whole number", key, value)
+		}
+		obj[name] = n
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: must be a number", key, value)
+		}
+		obj[name] = n
+	case reflect.Slice:
+		var list []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+		obj[name] = list
+	default:
+		obj[name] = value
+	}
+	return nil
+}
+
+// Lookup returns the value of key in doc as text, or "" when it is unset.
+func Lookup(doc map[string]any, key string) string {
+	var v any = doc
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		if v, ok = obj[part]; !ok {
+			return ""
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var list []string
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+		return strings.Join(list, ",")
+	case []string:
+		return strings.Join(v, ",")
+	case map[string]any:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
+
+// UnknownKeys returns the keys of doc kc does not read, such as misspelled
+// ones, dotted for keys of objects.
+func UnknownKeys(doc map[string]any) []string {
+	var out []string
+	fields := configFields()
+	for k, v := range doc {
+		f, ok := lookupField(fields, k)
+		if !ok {
+			out = append(out, k)
+			continue
+		}
+		obj, isObject := v.(map[string]any)
+		if f.typ.Kind() != reflect.Struct || !isObject {
+			continue
+		}
+		for sub := range obj {
+			if _, ok := lookupField(fieldsOf(f.typ), sub); !ok {
+				out = append(out, k+"."+sub)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Check returns the problems of c that Load accepts but that stop commands
+// from logging in or sending what they should.
+func Check(c Config) []string {
+	var problems []string
+	if !isHTTPURL(c.ServerURL) {
+		problems = append(problems, fmt.Sprintf("server_url %q is not an absolute http(s) URL", c.ServerURL))
+	}
+	switch c.GrantType {
+	case "client_credentials":
+		if c.ClientID == "" || c.ClientSecret == "" {
+			problems = append(problems, "grant_type client_credentials needs client_id and client_secret")
+		}
+	case "password":
+		if c.Username == "" || c.Password == "" {
+			problems = append(problems, "grant_type password needs username and password")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("grant_type %q is not supported: use client_credentials or password", c.GrantType))
+	}
+	if c.RateLimit < 0 || c.RateBurst < 0 {
+		problems = append(problems, "rate_limit and rate_burst cannot be negative")
+	}
+	for key, u := range map[string]string{"notify_webhook": c.NotifyWebhook, "stats_pushgateway": c.StatsPushgateway} {
+		if u != "" && !isHTTPURL(u) {
+			problems = append(problems, fmt.Sprintf("%s %q is not an absolute http(s) URL", key, u))
+		}
+	}
+	slices.Sort(problems)
+	return problems
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,179 @@
+// Package i18n translates the text output of kc: the lines of the output box
+// and the summaries. The catalogs, one JSON file per language in catalogs/,
+// are embedded in the binary. English is the language of the source, so it
+// needs no catalog, and lines a catalog does not know stay in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalog is the content of a catalog file.
+type catalog struct {
+	// Messages maps the English format strings of kc, as given to
+	// fmt.Sprintf, to their translation. %q, %s, %v and %d stand for any
+	// value; the translation takes the values in order, or by index (%[2]s).
+	Messages map[string]string `json:"messages"`
+	// Words translates the labels of count lines such as
+	// "Done. Created: 3, Skipped: 1.", and their "Done" prefix.
+	Words map[string]string `json:"words"`
+}
+
+// message is a compiled catalog message.
+type message struct {
+	pattern     *regexp.Regexp
+	translation string
+	literal     int // characters outside the verbs, to try specific ones first
+}
+
+var (
+	lang     = "en"
+	messages []message
+	words    map[string]string
+)
+
+// verb matches the fmt verbs of a format string and of its translation.
+var verb = regexp.MustCompile(`%(\[\d+\])?[qsvd%]`)
+
+// countsLine matches lines made of counts, e.g. "Done. Created: 3, Skipped: 1.".
+var countsLine = regexp.MustCompile(`^(Done\. |Done before the timeout: )?([A-Za-z][A-Za-z ()']*: \d+(?:, [A-Za-z][A-Za-z ()']*: \d+)*)(\.?)$`)
+
+// Languages returns the languages kc can print, English first.
+func Languages() []string {
+	out := []string{"en"}
+	entries, _ := catalogFiles.ReadDir("catalogs")
+	for _, e := range entries {
+		out = append(out, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return out
+}
+
+// Language returns the language set with SetLanguage.
+func Language() string {
+	return lang
+}
+
+// SetLanguage selects the language of Translate: "en" or one with a catalog.
+// An empty lang means English.
+func SetLanguage(l string) error {
+	if l == "" || l == "en" {
+		lang, messages, words = "en", nil, nil
+		return nil
+	}
+	if !slices.Contains(Languages(), l) {
+		return fmt.Errorf("invalid language %q: must be one of %s", l, strings.Join(Languages(), ", "))
+	}
+	b, err := catalogFiles.ReadFile(path.Join("catalogs", l+".json"))
+	if err != nil {
+		return err
+	}
+	var c catalog
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("invalid catalog %s.json: %w", l, err)
+	}
+	compiled := make([]message, 0, len(c.Messages))
+	for format, translation := range c.Messages {
+		m, err := compile(format, translation)
+		if err != nil {
+			return fmt.Errorf("invalid catalog %s.json: %w", l, err)
+		}
+		compiled = append(compiled, m)
+	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].literal > compiled[j].literal })
+	lang, messages, words = l, compiled, c.Words
+	return nil
+}
+
+// compile turns an English format string into a pattern matching the lines
+// it produces.
+func compile(format, translation string) (message, error) {
+	var re strings.Builder
+	re.WriteString(`^(\s*)`)
+	last, literal := 0, 0
+	for _, loc := range verb.FindAllStringIndex(format, -1) {
+		re.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		literal += loc[0] - last
+		switch format[loc[1]-1] {
+		case 'q':
+			re.WriteString(`("(?:[^"\\]|\\.)*")`)
+		case 'd':
+			re.WriteString(`(-?\d+)`)
+		case '%':
+			re.WriteString(`%`)
+		default:
+			re.WriteString(`(.*?)`)
+		}
+		last = loc[1]
+	}
+	re.WriteString(regexp.QuoteMeta(format[last:]) + `$`)
+	literal += len(format) - last
+	p, err := regexp.Compile(re.String())
+	if err != nil {
+		return message{}, fmt.Errorf("message %q: %w", format, err)
+	}
+	return message{pattern: p, translation: translation, literal: literal}, nil
+}
+
+// Translate returns line in the selected language, or line itself when the
+// catalog does not know it.
+func Translate(line string) string {
+	if messages == nil {
+		return line
+	}
+	for _, m := range messages {
+		values := m.pattern.FindStringSubmatch(line)
+		if values == nil {
+			continue
+		}
+		indent, values := values[1], values[2:]
+		next := 0
+		return indent + verb.ReplaceAllStringFunc(m.translation, func(v string) string {
+			if v == "%%" {
+				return "%"
+			}
+			i := next
+			if strings.HasPrefix(v, "%[") {
+				fmt.Sscanf(v, "%%[%d]", &i)
+				i--
+			}
+			next = i + 1
+			if i < 0 || i >= len(values) {
+				return v
+			}
+			return values[i]
+		})
+	}
+	if c := countsLine.FindStringSubmatch(line); c != nil {
+		var b strings.Builder
+		if c[1] != "" {
+			b.WriteString(word(strings.TrimRight(c[1], ". :")) + c[1][len(strings.TrimRight(c[1], ". :")):])
+		}
+		for i, pair := range strings.Split(c[2], ", ") {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			label, n, _ := strings.Cut(pair, ": ")
+			b.WriteString(word(label) + ": " + n)
+		}
+		return b.String() + c[3]
+	}
+	return line
+}
+
+// word translates a label of a count line.
+func word(w string) string {
+	if t, ok := words[w]; ok {
+		return t
+	}
+	return w
+}
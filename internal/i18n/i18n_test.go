@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetLanguage(t *testing.T) {
+	t.Cleanup(func() { SetLanguage("en") })
+	// every shipped catalog must load
+	for _, l := range Languages() {
+		if err := SetLanguage(l); err != nil {
+			t.Errorf("SetLanguage(%q) = %v", l, err)
+		}
+	}
+	if err := SetLanguage("xx"); err == nil {
+		t.Error("SetLanguage(xx) succeeded, want an error")
+	}
+	if err := SetLanguage(""); err != nil || Language() != "en" {
+		t.Errorf("SetLanguage(\"\") = %v, language %q; want en", err, Language())
+	}
+	if !slices.Contains(Languages(), "es") || Languages()[0] != "en" {
+		t.Errorf("Languages() = %v, want en first and es", Languages())
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	t.Cleanup(func() { SetLanguage("en") })
+	if err := SetLanguage("es"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"message with values", `Created user "alice" (ID: 1) in realm "acme".`, `Usuario "alice" creado (ID: 1) en el realm "acme".`},
+		{"indented", `  User "bob" already exists in realm "acme". Skipped.`, `  El usuario "bob" ya existe en el realm "acme". Omitido.`},
+		{"counts", "Done. Created: 2, Skipped: 1.", "Listo. Creados: 2, Omitidos: 1."},
+		{"counts before the timeout", "Done before the timeout: Deleted: 3", "Hecho antes del timeout: Eliminados: 3"},
+		{"unknown label", "Done. Widgets: 2.", "Listo. Widgets: 2."},
+		{"unknown line", "Something else entirely", "Something else entirely"},
+		{"quoted value with a quote", `Created user "a\"b" (ID: 1) in realm "acme".`, `Usuario "a\"b" creado (ID: 1) en el realm "acme".`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Translate(tt.line); got != tt.want {
+				t.Errorf("Translate(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+
+	if err := SetLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	if line := "Done. Created: 2."; Translate(line) != line {
+		t.Errorf("English changed %q to %q", line, Translate(line))
+	}
+}
+
+func TestCompileIndexedVerbs(t *testing.T) {
+	m, err := compile("Moved %q to %q.", "%[2]s <- %[1]s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages = []message{m}
+	t.Cleanup(func() { messages = nil })
+	if got := Translate(`Moved "a" to "b".`); got != `"b" <- "a"` {
+		t.Errorf("Translate = %q, want the values swapped", got)
+	}
+}
@@ -0,0 +1,24 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// UpdatePasswordAction is the required action letting a user set a new password.
+const UpdatePasswordAction = "UPDATE_PASSWORD"
+
+// ExecuteActionsEmail sends a user the email of the realm with a link to
+// perform actions, e.g. UpdatePasswordAction. The link is valid for lifespan,
+// or the realm default (12h) when zero. The realm needs an SMTP server and
+// the user an email address.
+func ExecuteActionsEmail(ctx context.Context, api API, token, realm, userID string, actions []string, lifespan time.Duration) error {
+	var q url.Values
+	if lifespan > 0 {
+		q = url.Values{"lifespan": {strconv.Itoa(int(lifespan.Seconds()))}}
+	}
+	return api.Do(ctx, token, http.MethodPut, AdminURL(realm, "users", userID, "execute-actions-email"), q, actions, nil)
+}
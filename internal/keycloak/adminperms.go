@@ -0,0 +1,127 @@
+package keycloak
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// RealmManagementClientID is the client holding the admin roles of a realm.
+// Its authorization settings hold the policies and permissions of
+// fine-grained admin permissions.
+const RealmManagementClientID = "realm-management"
+
+// PermissionTarget is what fine-grained admin permissions are enabled on: the
+// users of a realm, a client or a group.
+type PermissionTarget struct {
+	// Kind is users, client or group.
+	Kind string
+	// ID is the ID of the client or group; empty for users.
+	ID string
+	// Name is the clientId of a client or the path of a group.
+	Name string
+}
+
+// String names the target as kc reports it: users, client:<clientId> or
+// group:<path>.
+func (t PermissionTarget) String() string {
+	if t.Kind == "users" {
+		return "users"
+	}
+	return t.Kind + ":" + t.Name
+}
+
+// GetManagementPermissions returns whether admin permissions are enabled on
+// target and, when they are, the permission ID of each scope.
+func GetManagementPermissions(ctx context.Context, api API, token, realm string, target PermissionTarget) (*gocloak.ManagementPermissionRepresentation, error) {
+	switch target.Kind {
+	case "users":
+		return api.GetUsersManagementPermissions(ctx, token, realm)
+	case "client":
+		return api.GetClientManagementPermissions(ctx, token, realm, target.ID)
+	case "group":
+		return api.GetGroupManagementPermissions(ctx, token, realm, target.ID)
+	}
+	return nil, fmt.Errorf("unknown permission target %q", target.Kind)
+}
+
+// SetManagementPermissions enables or disables admin permissions on target.
+// Disabling deletes the scope permissions, and with them every grant.
+func SetManagementPermissions(ctx context.Context, api API, token, realm string, target PermissionTarget, enabled bool) (*gocloak.ManagementPermissionRepresentation, error) {
+	rep := gocloak.ManagementPermissionRepresentation{Enabled: &enabled}
+	switch target.Kind {
+	case "users":
+		return api.UpdateUsersManagementPermissions(ctx, token, realm, rep)
+	case "client":
+		return api.UpdateClientManagementPermissions(ctx, token, realm, target.ID, rep)
+	case "group":
+		return api.UpdateGroupManagementPermissions(ctx, token, realm, target.ID, rep)
+	}
+	return nil, fmt.Errorf("unknown permission target %q", target.Kind)
+}
+
+// RealmManagementID returns the ID of the realm-management client of realm.
+// It is looked up uncached: the offline fake creates the client only once
+// admin permissions are first enabled.
+func RealmManagementID(ctx context.Context, api API, token, realm string) (string, error) {
+	clients, err := api.GetClients(ctx, token, realm, gocloak.GetClientsParams{ClientID: gocloak.StringP(RealmManagementClientID)})
+	if err != nil {
+		return "", err
+	}
+	for _, c := range clients {
+		if gocloak.PString(c.ClientID) == RealmManagementClientID {
+			return gocloak.PString(c.ID), nil
+		}
+	}
+	return "", fmt.Errorf("client %s not found in realm %s", RealmManagementClientID, realm)
+}
+
+// FindPolicy returns the policy of realm-management called name, or nil when
+// there is none.
+func FindPolicy(ctx context.Context, api API, token, realm, rmID, name string) (*gocloak.PolicyRepresentation, error) {
+	// the name filter matches substrings
+	list, err := api.GetPolicies(ctx, token, realm, rmID, gocloak.GetPolicyParams{Name: &name, Permission: gocloak.BoolP(false)})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range list {
+		if gocloak.PString(p.Name) == name {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// PermissionPolicyIDs returns the IDs of the policies a scope permission is
+// granted through.
+func PermissionPolicyIDs(ctx context.Context, api API, token, realm, rmID, permissionID string) ([]string, error) {
+	list, err := api.GetAuthorizationPolicyAssociatedPolicies(ctx, token, realm, rmID, permissionID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(list))
+	for _, p := range list {
+		ids = append(ids, gocloak.PString(p.ID))
+	}
+	return ids, nil
+}
+
+// SetPermissionPolicies replaces the policies of a scope permission.
+func SetPermissionPolicies(ctx context.Context, api API, token, realm, rmID, permissionID string, policyIDs []string) error {
+	perm, err := api.GetPermission(ctx, token, realm, rmID, permissionID)
+	if err != nil {
+		return err
+	}
+	if gocloak.NilOrEmpty(perm.Type) {
+		perm.Type = gocloak.StringP("scope")
+	}
+	// an empty list clears the policies; nil would leave them unchanged
+	ids := slices.Clone(policyIDs)
+	if ids == nil {
+		ids = []string{}
+	}
+	perm.Policies = &ids
+	return api.UpdatePermission(ctx, token, realm, rmID, *perm)
+}
@@ -0,0 +1,118 @@
+package keycloak
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// API is the part of the Keycloak admin REST API used by kc. Login returns a
+// Server talking to the configured Keycloak, or a Fake in offline mode.
+type API interface {
+	GetRealms(ctx context.Context, token string) ([]*gocloak.RealmRepresentation, error)
+	GetRealm(ctx context.Context, token, realm string) (*gocloak.RealmRepresentation, error)
+	CreateRealm(ctx context.Context, token string, realm gocloak.RealmRepresentation) (string, error)
+	UpdateRealm(ctx context.Context, token string, realm gocloak.RealmRepresentation) error
+	DeleteRealm(ctx context.Context, token, realm string) error
+
+	GetUsers(ctx context.Context, token, realm string, params gocloak.GetUsersParams) ([]*gocloak.User, error)
+	GetUserByID(ctx context.Context, token, realm, userID string) (*gocloak.User, error)
+	CreateUser(ctx context.Context, token, realm string, user gocloak.User) (string, error)
+	UpdateUser(ctx context.Context, token, realm string, user gocloak.User) error
+	DeleteUser(ctx context.Context, token, realm, userID string) error
+	SetPassword(ctx context.Context, token, userID, realm, password string, temporary bool) error
+
+	GetRealmRoles(ctx context.Context, token, realm string, params gocloak.GetRoleParams) ([]*gocloak.Role, error)
+	GetRealmRole(ctx context.Context, token, realm, roleName string) (*gocloak.Role, error)
+	CreateRealmRole(ctx context.Context, token, realm string, role gocloak.Role) (string, error)
+	UpdateRealmRole(ctx context.Context, token, realm, roleName string, role gocloak.Role) error
+	DeleteRealmRole(ctx context.Context, token, realm, roleName string) error
+	GetRealmRolesByUserID(ctx context.Context, token, realm, userID string) ([]*gocloak.Role, error)
+	AddRealmRoleToUser(ctx context.Context, token, realm, userID string, roles []gocloak.Role) error
+	DeleteRealmRoleFromUser(ctx context.Context, token, realm, userID string, roles []gocloak.Role) error
+
+	GetClients(ctx context.Context, token, realm string, params gocloak.GetClientsParams) ([]*gocloak.Client, error)
+	GetClient(ctx context.Context, token, realm, idOfClient string) (*gocloak.Client, error)
+	CreateClient(ctx context.Context, token, realm string, client gocloak.Client) (string, error)
+	UpdateClient(ctx context.Context, token, realm string, client gocloak.Client) error
+	DeleteClient(ctx context.Context, token, realm, idOfClient string) error
+	RegenerateClientSecret(ctx context.Context, token, realm, idOfClient string) (*gocloak.CredentialRepresentation, error)
+	CreateClientProtocolMapper(ctx context.Context, token, realm, idOfClient string, mapper gocloak.ProtocolMapperRepresentation) (string, error)
+	UpdateClientProtocolMapper(ctx context.Context, token, realm, idOfClient, mapperID string, mapper gocloak.ProtocolMapperRepresentation) error
+	DeleteClientProtocolMapper(ctx context.Context, token, realm, idOfClient, mapperID string) error
+
+	GetClientRoles(ctx context.Context, token, realm, idOfClient string, params gocloak.GetRoleParams) ([]*gocloak.Role, error)
+	GetClientRole(ctx context.Context, token, realm, idOfClient, roleName string) (*gocloak.Role, error)
+	CreateClientRole(ctx context.Context, token, realm, idOfClient string, role gocloak.Role) (string, error)
+	DeleteClientRole(ctx context.Context, token, realm, idOfClient, roleName string) error
+	AddClientRoleToUser(ctx context.Context, token, realm, idOfClient, userID string, roles []gocloak.Role) error
+	GetClientRolesByUserID(ctx context.Context, token, realm, idOfClient, userID string) ([]*gocloak.Role, error)
+	AddClientRolesToGroup(ctx context.Context, token, realm, idOfClient, groupID string, roles []gocloak.Role) error
+	GetClientRolesByGroupID(ctx context.Context, token, realm, idOfClient, groupID string) ([]*gocloak.Role, error)
+
+	GetClientScopes(ctx context.Context, token, realm string) ([]*gocloak.ClientScope, error)
+	CreateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) (string, error)
+	UpdateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) error
+	DeleteClientScope(ctx context.Context, token, realm, scopeID string) error
+	GetClientsDefaultScopes(ctx context.Context, token, realm, idOfClient string) ([]*gocloak.ClientScope, error)
+	GetClientsOptionalScopes(ctx context.Context, token, realm, idOfClient string) ([]*gocloak.ClientScope, error)
+	AddDefaultScopeToClient(ctx context.Context, token, realm, idOfClient, scopeID string) error
+	AddOptionalScopeToClient(ctx context.Context, token, realm, idOfClient, scopeID string) error
+	RemoveDefaultScopeFromClient(ctx context.Context, token, realm, idOfClient, scopeID string) error
+	RemoveOptionalScopeFromClient(ctx context.Context, token, realm, idOfClient, scopeID string) error
+
+	GetGroups(ctx context.Context, token, realm string, params gocloak.GetGroupsParams) ([]*gocloak.Group, error)
+	GetGroupByPath(ctx context.Context, token, realm, groupPath string) (*gocloak.Group, error)
+	CreateGroup(ctx context.Context, token, realm string, group gocloak.Group) (string, error)
+	CreateChildGroup(ctx context.Context, token, realm, groupID string, group gocloak.Group) (string, error)
+	UpdateGroup(ctx context.Context, token, realm string, group gocloak.Group) error
+	DeleteGroup(ctx context.Context, token, realm, groupID string) error
+	GetUserGroups(ctx context.Context, token, realm, userID string, params gocloak.GetGroupsParams) ([]*gocloak.Group, error)
+	AddUserToGroup(ctx context.Context, token, realm, userID, groupID string) error
+	DeleteUserFromGroup(ctx context.Context, token, realm, userID, groupID string) error
+	AddRealmRoleToGroup(ctx context.Context, token, realm, groupID string, roles []gocloak.Role) error
+	DeleteRealmRoleFromGroup(ctx context.Context, token, realm, groupID string, roles []gocloak.Role) error
+
+	GetUserSessions(ctx context.Context, token, realm, userID string) ([]*gocloak.UserSessionRepresentation, error)
+	GetClientUserSessions(ctx context.Context, token, realm, idOfClient string, params ...gocloak.GetClientUserSessionsParams) ([]*gocloak.UserSessionRepresentation, error)
+	LogoutAllSessions(ctx context.Context, token, realm, userID string) error
+	LogoutUserSession(ctx context.Context, token, realm, session string) error
+	GetClientOfflineSessions(ctx context.Context, token, realm, idOfClient string, params ...gocloak.GetClientUserSessionsParams) ([]*gocloak.UserSessionRepresentation, error)
+	RevokeUserConsents(ctx context.Context, token, realm, userID, clientID string) error
+
+	GetEvents(ctx context.Context, token, realm string, params gocloak.GetEventsParams) ([]*gocloak.EventRepresentation, error)
+
+	GetComponentsWithParams(ctx context.Context, token, realm string, params gocloak.GetComponentsParams) ([]*gocloak.Component, error)
+	CreateComponent(ctx context.Context, token, realm string, component gocloak.Component) (string, error)
+	UpdateComponent(ctx context.Context, token, realm string, component gocloak.Component) error
+	DeleteComponent(ctx context.Context, token, realm, componentID string) error
+
+	GetUsersManagementPermissions(ctx context.Context, token, realm string) (*gocloak.ManagementPermissionRepresentation, error)
+	UpdateUsersManagementPermissions(ctx context.Context, token, realm string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error)
+	GetClientManagementPermissions(ctx context.Context, token, realm, idOfClient string) (*gocloak.ManagementPermissionRepresentation, error)
+	UpdateClientManagementPermissions(ctx context.Context, token, realm, idOfClient string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error)
+	GetGroupManagementPermissions(ctx context.Context, token, realm, idOfGroup string) (*gocloak.ManagementPermissionRepresentation, error)
+	UpdateGroupManagementPermissions(ctx context.Context, token, realm, idOfGroup string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error)
+	GetPolicies(ctx context.Context, token, realm, idOfClient string, params gocloak.GetPolicyParams) ([]*gocloak.PolicyRepresentation, error)
+	CreatePolicy(ctx context.Context, token, realm, idOfClient string, policy gocloak.PolicyRepresentation) (*gocloak.PolicyRepresentation, error)
+	GetAuthorizationPolicyAssociatedPolicies(ctx context.Context, token, realm, idOfClient, policyID string) ([]*gocloak.PolicyRepresentation, error)
+	GetPermission(ctx context.Context, token, realm, idOfClient, permissionID string) (*gocloak.PermissionRepresentation, error)
+	UpdatePermission(ctx context.Context, token, realm, idOfClient string, permission gocloak.PermissionRepresentation) error
+
+	GetServerInfo(ctx context.Context, token string) (*gocloak.ServerInfoRepresentation, error)
+
+	// Do performs a raw admin REST request for endpoints gocloak does not wrap.
+	// body and result are optional; result receives the decoded JSON response.
+	Do(ctx context.Context, token, method, rawURL string, query url.Values, body, result interface{}) error
+}
+
+// Server is the API of a live Keycloak server, reached through gocloak.
+type Server struct {
+	*gocloak.GoCloak
+}
+
+var (
+	_ API = (*Server)(nil)
+	_ API = (*Fake)(nil)
+)
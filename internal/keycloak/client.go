@@ -7,27 +7,38 @@ import (
 	"kc/internal/config"
 )
 
-func Login(ctx context.Context) (*gocloak.GoCloak, string, error) {
+// Login authenticates against the configured server with the configured grant.
+// With KC_FAKE=1 it returns the in-memory Fake instead, without any request.
+func Login(ctx context.Context) (API, string, error) {
+	if FakeMode() {
+		f, err := sharedFake()
+		if err != nil {
+			return nil, "", err
+		}
+		return f, fakeToken, nil
+	}
 	client := gocloak.NewClient(config.Global.ServerURL)
+	installThrottling(client)
+	installTracing(client)
 	switch config.Global.GrantType {
 	case "client_credentials":
 		token, err := client.LoginClient(ctx, config.Global.ClientID, config.Global.ClientSecret, config.Global.AuthRealm)
 		if err != nil {
 			return nil, "", err
 		}
-		return client, token.AccessToken, nil
+		return &Server{client}, token.AccessToken, nil
 	case "password":
 		// Use admin login with username/password for admin operations
 		token, err := client.LoginAdmin(ctx, config.Global.Username, config.Global.Password, config.Global.AuthRealm)
 		if err != nil {
 			return nil, "", err
 		}
-		return client, token.AccessToken, nil
+		return &Server{client}, token.AccessToken, nil
 	default:
 		token, err := client.LoginClient(ctx, config.Global.ClientID, config.Global.ClientSecret, config.Global.AuthRealm)
 		if err != nil {
 			return nil, "", err
 		}
-		return client, token.AccessToken, nil
+		return &Server{client}, token.AccessToken, nil
 	}
 }
@@ -0,0 +1,41 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// OfflineTokenGrant is the additional grant key of a consent entry whose
+// client holds offline tokens of the user.
+const OfflineTokenGrant = "Offline Token"
+
+// UserConsent is a client the user consented to or holds offline tokens for.
+type UserConsent struct {
+	ClientID            string   `json:"clientId"`
+	GrantedClientScopes []string `json:"grantedClientScopes,omitempty"`
+	// CreatedDate and LastUpdatedDate are milliseconds since the epoch.
+	CreatedDate      int64             `json:"createdDate,omitempty"`
+	LastUpdatedDate  int64             `json:"lastUpdatedDate,omitempty"`
+	AdditionalGrants []AdditionalGrant `json:"additionalGrants,omitempty"`
+}
+
+// AdditionalGrant is a grant next to the consented scopes, e.g. offline tokens.
+type AdditionalGrant struct {
+	Client string `json:"client"`
+	Key    string `json:"key"`
+}
+
+// HasOfflineTokens reports whether the client holds offline tokens of the user.
+func (c *UserConsent) HasOfflineTokens() bool {
+	return slices.ContainsFunc(c.AdditionalGrants, func(g AdditionalGrant) bool { return g.Key == OfflineTokenGrant })
+}
+
+// GetUserConsents lists the consents and offline token grants of a user.
+func GetUserConsents(ctx context.Context, api API, token, realm, userID string) ([]*UserConsent, error) {
+	var out []*UserConsent
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "users", userID, "consents"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// AuthDetails identifies who performed an admin operation.
+type AuthDetails struct {
+	RealmID   string `json:"realmId,omitempty"`
+	ClientID  string `json:"clientId,omitempty"`
+	UserID    string `json:"userId,omitempty"`
+	IPAddress string `json:"ipAddress,omitempty"`
+}
+
+// AdminEvent is the representation returned by the admin-events endpoint.
+type AdminEvent struct {
+	Time           int64       `json:"time"`
+	RealmID        string      `json:"realmId,omitempty"`
+	AuthDetails    AuthDetails `json:"authDetails"`
+	OperationType  string      `json:"operationType"`
+	ResourceType   string      `json:"resourceType,omitempty"`
+	ResourcePath   string      `json:"resourcePath,omitempty"`
+	Representation string      `json:"representation,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// AdminEventsParams filters the admin-events query.
+type AdminEventsParams struct {
+	OperationTypes []string
+	ResourceTypes  []string
+	ResourcePath   string
+	AuthUser       string
+	DateFrom       string
+	DateTo         string
+	First          int
+	Max            int
+}
+
+// GetAdminEvents lists admin events of a realm; gocloak only wraps login events.
+func GetAdminEvents(ctx context.Context, api API, token, realm string, params AdminEventsParams) ([]*AdminEvent, error) {
+	q := url.Values{}
+	for _, o := range params.OperationTypes {
+		q.Add("operationTypes", o)
+	}
+	for _, r := range params.ResourceTypes {
+		q.Add("resourceTypes", r)
+	}
+	if params.ResourcePath != "" {
+		q.Set("resourcePath", params.ResourcePath)
+	}
+	if params.AuthUser != "" {
+		q.Set("authUser", params.AuthUser)
+	}
+	if params.DateFrom != "" {
+		q.Set("dateFrom", params.DateFrom)
+	}
+	if params.DateTo != "" {
+		q.Set("dateTo", params.DateTo)
+	}
+	q.Set("first", strconv.Itoa(params.First))
+	if params.Max > 0 {
+		q.Set("max", strconv.Itoa(params.Max))
+	}
+	var out []*AdminEvent
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "admin-events"), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
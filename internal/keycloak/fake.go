@@ -0,0 +1,2466 @@
+package keycloak
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// fakeToken is the access token handed out in offline mode.
+const fakeToken = "fake-token"
+
+// FakeVersion is the server version reported by the Fake.
+const FakeVersion = "26.0.0"
+
+// FakeMode reports whether KC_FAKE=1 asks for the in-memory Fake instead of
+// a Keycloak server.
+func FakeMode() bool {
+	return os.Getenv("KC_FAKE") == "1"
+}
+
+var (
+	fakeOnce sync.Once
+	fake     *Fake
+	fakeErr  error
+)
+
+// sharedFake returns the Fake of this process, loaded from KC_FAKE_STATE when set.
+func sharedFake() (*Fake, error) {
+	fakeOnce.Do(func() {
+		if path := os.Getenv("KC_FAKE_STATE"); path != "" {
+			fake, fakeErr = LoadFake(path)
+			return
+		}
+		fake = NewFake()
+	})
+	return fake, fakeErr
+}
+
+// Fake is an in-memory API for tests and offline runs. It starts with an
+// empty master realm and mimics the status codes of Keycloak for missing
+// (404) and duplicate (409) entities. Tokens are not checked.
+type Fake struct {
+	mu     sync.Mutex
+	path   string
+	realms map[string]*fakeRealm
+}
+
+// fakeRealm is the state of one realm. Role, group and scope assignments
+// hold IDs, so renames keep them.
+type fakeRealm struct {
+	Realm        *gocloak.RealmRepresentation `json:"realm"`
+	Users        []*gocloak.User              `json:"users,omitempty"`
+	Roles        []*gocloak.Role              `json:"roles,omitempty"`
+	Clients      []*gocloak.Client            `json:"clients,omitempty"`
+	ClientRoles  map[string][]*gocloak.Role   `json:"clientRoles,omitempty"` // client ID -> roles
+	ClientScopes []*gocloak.ClientScope       `json:"clientScopes,omitempty"`
+	Groups       []*gocloak.Group             `json:"groups,omitempty"`
+	// UserRoles holds realm and client role IDs.
+	UserRoles      map[string][]string            `json:"userRoles,omitempty"`
+	UserGroups     map[string][]string            `json:"userGroups,omitempty"`
+	GroupRoles     map[string][]string            `json:"groupRoles,omitempty"`
+	DefaultScopes  map[string][]string            `json:"defaultScopes,omitempty"` // client ID -> scope IDs
+	OptionalScopes map[string][]string            `json:"optionalScopes,omitempty"`
+	Events         []*gocloak.EventRepresentation `json:"events,omitempty"`
+	AdminEvents    []*AdminEvent                  `json:"adminEvents,omitempty"`
+	Components     []*gocloak.Component           `json:"components,omitempty"`
+	InitialAccess  []*InitialAccess               `json:"initialAccess,omitempty"`
+	// Sessions are only created by seeding the state file; the fake has no
+	// login endpoint.
+	Sessions        []*gocloak.UserSessionRepresentation `json:"sessions,omitempty"`
+	OfflineSessions []*gocloak.UserSessionRepresentation `json:"offlineSessions,omitempty"`
+	// Consents are keyed by user ID and, like sessions, only seeded.
+	Consents map[string][]*UserConsent `json:"consents,omitempty"`
+	// IdentityProviders are only seeded too; kc does not manage them.
+	IdentityProviders []*IdentityProvider `json:"identityProviders,omitempty"`
+	// AdminPermissions are keyed users, clients/<id> or groups/<id>; their
+	// scope permissions live in Policies, the authorization settings of
+	// realm-management.
+	AdminPermissions map[string]*gocloak.ManagementPermissionRepresentation `json:"adminPermissions,omitempty"`
+	Policies         []*gocloak.PolicyRepresentation                        `json:"policies,omitempty"`
+}
+
+// NewFake returns an empty in-memory Keycloak.
+func NewFake() *Fake {
+	f := &Fake{realms: map[string]*fakeRealm{}}
+	f.realms["master"] = newFakeRealm("master")
+	return f
+}
+
+// LoadFake returns a Fake persisted to path after every change, starting from
+// the state saved there when the file exists.
+func LoadFake(path string) (*Fake, error) {
+	f := NewFake()
+	f.path = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.realms); err != nil {
+		return nil, fmt.Errorf("invalid fake state %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func newFakeRealm(name string) *fakeRealm {
+	return &fakeRealm{Realm: &gocloak.RealmRepresentation{ID: gocloak.StringP(name), Realm: gocloak.StringP(name), Enabled: gocloak.BoolP(true)}}
+}
+
+// save writes the state to the file of LoadFake. Callers hold f.mu.
+func (f *Fake) save() error {
+	if f.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(f.realms, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Clean(f.path))
+}
+
+// AddEvent records a login event, which only Keycloak itself produces.
+func (f *Fake) AddEvent(realm string, ev gocloak.EventRepresentation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if ev.Time == 0 {
+		ev.Time = time.Now().UnixMilli()
+	}
+	r.Events = append(r.Events, &ev)
+	return f.save()
+}
+
+func (f *Fake) realm(name string) (*fakeRealm, error) {
+	r, ok := f.realms[name]
+	if !ok {
+		return nil, notFound("Realm")
+	}
+	return r, nil
+}
+
+func notFound(what string) error {
+	return &gocloak.APIError{Code: http.StatusNotFound, Message: fmt.Sprintf("404 Not Found: %s not found", what)}
+}
+
+func conflict(msg string) error {
+	return &gocloak.APIError{Code: http.StatusConflict, Message: "409 Conflict: " + msg}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// clone deep-copies v, so callers never share state with the Fake.
+func clone[T any](v *T) *T {
+	out := new(T)
+	data, _ := json.Marshal(v)
+	_ = json.Unmarshal(data, out)
+	return out
+}
+
+func cloneAll[T any](list []*T) []*T {
+	out := make([]*T, 0, len(list))
+	for _, v := range list {
+		out = append(out, clone(v))
+	}
+	return out
+}
+
+// overlay sets the fields of dst that are set in src, as the admin API does
+// for PUT requests.
+func overlay(dst, src interface{}) {
+	data, _ := json.Marshal(src)
+	_ = json.Unmarshal(data, dst)
+}
+
+func page[T any](list []T, first, max *int) []T {
+	if first != nil && *first > 0 {
+		if *first >= len(list) {
+			return nil
+		}
+		list = list[*first:]
+	}
+	if max != nil && *max >= 0 && *max < len(list) {
+		list = list[:*max]
+	}
+	return list
+}
+
+func matches(value *string, search string, exact bool) bool {
+	v := strings.ToLower(gocloak.PString(value))
+	s := strings.ToLower(search)
+	if exact {
+		return v == s
+	}
+	return strings.Contains(v, s)
+}
+
+// attributeQueryTerm is one name:value term of the q parameter of the user
+// search; either side may be quoted.
+var attributeQueryTerm = regexp.MustCompile(`(?:"([^"]*)"|(\S*)):(?:"([^"]*)"|(\S*))`)
+
+// matchesAttributes reports whether u has every attribute value of q,
+// compared ignoring case like Keycloak does.
+func matchesAttributes(u *gocloak.User, q string) bool {
+	for _, m := range attributeQueryTerm.FindAllStringSubmatch(q, -1) {
+		name, value := m[1]+m[2], m[3]+m[4]
+		var values []string
+		if u.Attributes != nil {
+			values = (*u.Attributes)[name]
+		}
+		if !slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *fakeRealm) adminEvent(op, resourceType, path string, rep interface{}) {
+	ev := &AdminEvent{
+		Time:          time.Now().UnixMilli(),
+		RealmID:       gocloak.PString(r.Realm.ID),
+		AuthDetails:   AuthDetails{RealmID: "master", ClientID: "admin-cli", UserID: "fake-admin"},
+		OperationType: op,
+		ResourceType:  resourceType,
+		ResourcePath:  path,
+	}
+	if rep != nil {
+		if data, err := json.Marshal(rep); err == nil {
+			ev.Representation = string(data)
+		}
+	}
+	r.AdminEvents = append(r.AdminEvents, ev)
+}
+
+// Realms
+
+func (f *Fake) GetRealms(ctx context.Context, token string) ([]*gocloak.RealmRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.realms))
+	for name := range f.realms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	out := make([]*gocloak.RealmRepresentation, 0, len(names))
+	for _, name := range names {
+		out = append(out, clone(f.realms[name].Realm))
+	}
+	return out, nil
+}
+
+func (f *Fake) GetRealm(ctx context.Context, token, realm string) (*gocloak.RealmRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	return clone(r.Realm), nil
+}
+
+func (f *Fake) CreateRealm(ctx context.Context, token string, realm gocloak.RealmRepresentation) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := gocloak.PString(realm.Realm)
+	if name == "" {
+		return "", &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: realm name is required"}
+	}
+	if _, ok := f.realms[name]; ok {
+		return "", conflict("Conflict detected. See logs for details")
+	}
+	r := newFakeRealm(name)
+	overlay(r.Realm, realm)
+	r.Realm.ID = gocloak.StringP(name)
+	f.realms[name] = r
+	return name, f.save()
+}
+
+func (f *Fake) UpdateRealm(ctx context.Context, token string, realm gocloak.RealmRepresentation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(gocloak.PString(realm.Realm))
+	if err != nil {
+		return err
+	}
+	overlay(r.Realm, realm)
+	r.adminEvent("UPDATE", "REALM", "", realm)
+	return f.save()
+}
+
+func (f *Fake) DeleteRealm(ctx context.Context, token, realm string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.realm(realm); err != nil {
+		return err
+	}
+	delete(f.realms, realm)
+	return f.save()
+}
+
+// Users
+
+func (r *fakeRealm) user(id string) (*gocloak.User, error) {
+	for _, u := range r.Users {
+		if gocloak.PString(u.ID) == id {
+			return u, nil
+		}
+	}
+	return nil, notFound("User")
+}
+
+func (f *Fake) GetUsers(ctx context.Context, token, realm string, params gocloak.GetUsersParams) ([]*gocloak.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	exact := params.Exact != nil && *params.Exact
+	var out []*gocloak.User
+	for _, u := range r.Users {
+		switch {
+		case params.Username != nil && !matches(u.Username, *params.Username, exact),
+			params.Email != nil && !matches(u.Email, *params.Email, exact),
+			params.FirstName != nil && !matches(u.FirstName, *params.FirstName, exact),
+			params.LastName != nil && !matches(u.LastName, *params.LastName, exact),
+			params.Enabled != nil && gocloak.PBool(u.Enabled) != *params.Enabled:
+			continue
+		case params.Search != nil:
+			s := strings.Trim(*params.Search, "*")
+			if !matches(u.Username, s, false) && !matches(u.Email, s, false) && !matches(u.FirstName, s, false) && !matches(u.LastName, s, false) {
+				continue
+			}
+		}
+		if params.Q != nil && !matchesAttributes(u, *params.Q) {
+			continue
+		}
+		out = append(out, u)
+	}
+	return cloneAll(page(out, params.First, params.Max)), nil
+}
+
+func (f *Fake) GetUserByID(ctx context.Context, token, realm, userID string) (*gocloak.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	u, err := r.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return clone(u), nil
+}
+
+func (f *Fake) CreateUser(ctx context.Context, token, realm string, user gocloak.User) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	name := strings.ToLower(gocloak.PString(user.Username))
+	for _, u := range r.Users {
+		if gocloak.PString(u.Username) == name {
+			return "", conflict("User exists with same username")
+		}
+	}
+	u := clone(&user)
+	u.ID = gocloak.StringP(newID())
+	u.Username = gocloak.StringP(name)
+	u.Credentials = nil
+	u.CreatedTimestamp = gocloak.Int64P(time.Now().UnixMilli())
+	r.Users = append(r.Users, u)
+	r.adminEvent("CREATE", "USER", "users/"+*u.ID, u)
+	return *u.ID, f.save()
+}
+
+func (f *Fake) UpdateUser(ctx context.Context, token, realm string, user gocloak.User) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	u, err := r.user(gocloak.PString(user.ID))
+	if err != nil {
+		return err
+	}
+	user.Credentials = nil
+	// Keycloak replaces the attributes when they are given
+	if user.Attributes != nil {
+		u.Attributes = nil
+	}
+	overlay(u, user)
+	r.adminEvent("UPDATE", "USER", "users/"+*u.ID, user)
+	return f.save()
+}
+
+func (f *Fake) DeleteUser(ctx context.Context, token, realm, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	r.Users = slices.DeleteFunc(r.Users, func(u *gocloak.User) bool { return gocloak.PString(u.ID) == userID })
+	delete(r.UserRoles, userID)
+	delete(r.UserGroups, userID)
+	r.adminEvent("DELETE", "USER", "users/"+userID, nil)
+	return f.save()
+}
+
+func (f *Fake) SetPassword(ctx context.Context, token, userID, realm, password string, temporary bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	r.adminEvent("ACTION", "USER", "users/"+userID+"/reset-password", nil)
+	return f.save()
+}
+
+// Realm roles
+
+func (r *fakeRealm) role(name string) (*gocloak.Role, error) {
+	for _, role := range r.Roles {
+		if gocloak.PString(role.Name) == name {
+			return role, nil
+		}
+	}
+	return nil, notFound("Role")
+}
+
+// realmComposites lists the realm roles the composite role name includes.
+// Composites only come from the state file: kc does not create them.
+func (r *fakeRealm) realmComposites(name string) ([]*gocloak.Role, error) {
+	role, err := r.role(name)
+	if err != nil {
+		return nil, err
+	}
+	out := []*gocloak.Role{}
+	if role.Composites == nil || role.Composites.Realm == nil {
+		return out, nil
+	}
+	for _, c := range *role.Composites.Realm {
+		if child, err := r.role(c); err == nil {
+			out = append(out, clone(child))
+		}
+	}
+	return out, nil
+}
+
+// realmRoleUsers pages the users the realm role name is mapped to directly.
+func (r *fakeRealm) realmRoleUsers(name string, query url.Values) ([]*gocloak.User, error) {
+	role, err := r.role(name)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.User
+	for _, u := range r.Users {
+		if slices.Contains(r.UserRoles[gocloak.PString(u.ID)], gocloak.PString(role.ID)) {
+			out = append(out, u)
+		}
+	}
+	first, _ := strconv.Atoi(query.Get("first"))
+	max := -1
+	if v := query.Get("max"); v != "" {
+		max, _ = strconv.Atoi(v)
+	}
+	return cloneAll(page(out, &first, &max)), nil
+}
+
+func searchRoles(roles []*gocloak.Role, params gocloak.GetRoleParams) []*gocloak.Role {
+	var out []*gocloak.Role
+	for _, role := range roles {
+		if params.Search != nil && !matches(role.Name, *params.Search, false) {
+			continue
+		}
+		out = append(out, role)
+	}
+	return cloneAll(page(out, params.First, params.Max))
+}
+
+func (f *Fake) GetRealmRoles(ctx context.Context, token, realm string, params gocloak.GetRoleParams) ([]*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	return searchRoles(r.Roles, params), nil
+}
+
+func (f *Fake) GetRealmRole(ctx context.Context, token, realm, roleName string) (*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	role, err := r.role(roleName)
+	if err != nil {
+		return nil, err
+	}
+	return clone(role), nil
+}
+
+func (f *Fake) CreateRealmRole(ctx context.Context, token, realm string, role gocloak.Role) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	name := gocloak.PString(role.Name)
+	if _, err := r.role(name); err == nil {
+		return "", conflict(fmt.Sprintf("Role with name %s already exists", name))
+	}
+	created := clone(&role)
+	created.ID = gocloak.StringP(newID())
+	created.ClientRole = gocloak.BoolP(false)
+	created.ContainerID = r.Realm.ID
+	r.Roles = append(r.Roles, created)
+	r.adminEvent("CREATE", "REALM_ROLE", "roles/"+name, created)
+	return name, f.save()
+}
+
+func (f *Fake) UpdateRealmRole(ctx context.Context, token, realm, roleName string, role gocloak.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	existing, err := r.role(roleName)
+	if err != nil {
+		return err
+	}
+	if newName := gocloak.PString(role.Name); newName != "" && newName != roleName {
+		if _, err := r.role(newName); err == nil {
+			return conflict(fmt.Sprintf("Role with name %s already exists", newName))
+		}
+	}
+	// composites hold names here but IDs in Keycloak, so they follow a rename
+	if newName := gocloak.PString(role.Name); newName != "" && newName != roleName {
+		for _, other := range r.Roles {
+			if other.Composites != nil && other.Composites.Realm != nil {
+				for i, c := range *other.Composites.Realm {
+					if c == roleName {
+						(*other.Composites.Realm)[i] = newName
+					}
+				}
+			}
+		}
+	}
+	role.ID = existing.ID
+	overlay(existing, role)
+	r.adminEvent("UPDATE", "REALM_ROLE", "roles-by-id/"+*existing.ID, role)
+	return f.save()
+}
+
+func (f *Fake) DeleteRealmRole(ctx context.Context, token, realm, roleName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	role, err := r.role(roleName)
+	if err != nil {
+		return err
+	}
+	r.Roles = slices.DeleteFunc(r.Roles, func(x *gocloak.Role) bool { return x == role })
+	r.unassign(*role.ID)
+	r.adminEvent("DELETE", "REALM_ROLE", "roles/"+roleName, nil)
+	return f.save()
+}
+
+// unassign drops a deleted role from users and groups.
+func (r *fakeRealm) unassign(roleID string) {
+	for id, ids := range r.UserRoles {
+		r.UserRoles[id] = slices.DeleteFunc(ids, func(x string) bool { return x == roleID })
+	}
+	for id, ids := range r.GroupRoles {
+		r.GroupRoles[id] = slices.DeleteFunc(ids, func(x string) bool { return x == roleID })
+	}
+}
+
+// roleIDs resolves roles given by name, or by ID when set, among candidates.
+func roleIDs(candidates []*gocloak.Role, roles []gocloak.Role) ([]string, error) {
+	var ids []string
+	for _, want := range roles {
+		i := slices.IndexFunc(candidates, func(c *gocloak.Role) bool {
+			if want.ID != nil {
+				return gocloak.PString(c.ID) == *want.ID
+			}
+			return gocloak.PString(c.Name) == gocloak.PString(want.Name)
+		})
+		if i < 0 {
+			return nil, notFound("Role")
+		}
+		ids = append(ids, *candidates[i].ID)
+	}
+	return ids, nil
+}
+
+func addIDs(m *map[string][]string, key string, ids []string) {
+	if *m == nil {
+		*m = map[string][]string{}
+	}
+	for _, id := range ids {
+		if !slices.Contains((*m)[key], id) {
+			(*m)[key] = append((*m)[key], id)
+		}
+	}
+}
+
+func removeIDs(m map[string][]string, key string, ids []string) {
+	m[key] = slices.DeleteFunc(m[key], func(x string) bool { return slices.Contains(ids, x) })
+}
+
+func (f *Fake) GetRealmRolesByUserID(ctx context.Context, token, realm, userID string) ([]*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.user(userID); err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Role
+	for _, role := range r.Roles {
+		if slices.Contains(r.UserRoles[userID], *role.ID) {
+			out = append(out, clone(role))
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) AddRealmRoleToUser(ctx context.Context, token, realm, userID string, roles []gocloak.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	ids, err := roleIDs(r.Roles, roles)
+	if err != nil {
+		return err
+	}
+	addIDs(&r.UserRoles, userID, ids)
+	r.adminEvent("CREATE", "REALM_ROLE_MAPPING", "users/"+userID+"/role-mappings/realm", roles)
+	return f.save()
+}
+
+func (f *Fake) DeleteRealmRoleFromUser(ctx context.Context, token, realm, userID string, roles []gocloak.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	ids, err := roleIDs(r.Roles, roles)
+	if err != nil {
+		return err
+	}
+	removeIDs(r.UserRoles, userID, ids)
+	r.adminEvent("DELETE", "REALM_ROLE_MAPPING", "users/"+userID+"/role-mappings/realm", roles)
+	return f.save()
+}
+
+// Clients
+
+func (r *fakeRealm) client(id string) (*gocloak.Client, error) {
+	for _, c := range r.Clients {
+		if gocloak.PString(c.ID) == id {
+			return c, nil
+		}
+	}
+	return nil, notFound("Client")
+}
+
+func (f *Fake) GetClients(ctx context.Context, token, realm string, params gocloak.GetClientsParams) ([]*gocloak.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	search := params.Search != nil && *params.Search
+	if !search && gocloak.PString(params.ClientID) == "realm-management" && r.ensureRealmManagement() {
+		if err := f.save(); err != nil {
+			return nil, err
+		}
+	}
+	var out []*gocloak.Client
+	for _, c := range r.Clients {
+		if params.ClientID != nil && !matches(c.ClientID, *params.ClientID, !search) {
+			continue
+		}
+		out = append(out, c)
+	}
+	out = cloneAll(page(out, params.First, params.Max))
+	for _, c := range out {
+		r.fillClientScopes(c)
+	}
+	return out, nil
+}
+
+func (f *Fake) GetClient(ctx context.Context, token, realm, idOfClient string) (*gocloak.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.client(idOfClient)
+	if err != nil {
+		return nil, err
+	}
+	c = clone(c)
+	r.fillClientScopes(c)
+	return c, nil
+}
+
+// fillClientScopes sets the names of the default and optional client scopes
+// of c, as Keycloak returns them in the client representation.
+func (r *fakeRealm) fillClientScopes(c *gocloak.Client) {
+	names := func(ids []string) *[]string {
+		out := []string{}
+		for _, s := range r.ClientScopes {
+			if slices.Contains(ids, gocloak.PString(s.ID)) {
+				out = append(out, gocloak.PString(s.Name))
+			}
+		}
+		return &out
+	}
+	c.DefaultClientScopes = names(r.DefaultScopes[gocloak.PString(c.ID)])
+	c.OptionalClientScopes = names(r.OptionalScopes[gocloak.PString(c.ID)])
+}
+
+func (r *fakeRealm) clientIDTaken(clientID, except string) bool {
+	return slices.ContainsFunc(r.Clients, func(c *gocloak.Client) bool {
+		return gocloak.PString(c.ClientID) == clientID && gocloak.PString(c.ID) != except
+	})
+}
+
+func (f *Fake) CreateClient(ctx context.Context, token, realm string, client gocloak.Client) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	cid := gocloak.PString(client.ClientID)
+	if r.clientIDTaken(cid, "") {
+		return "", conflict(fmt.Sprintf("Client %s already exists", cid))
+	}
+	c := clone(&client)
+	c.ID = gocloak.StringP(newID())
+	if c.Protocol == nil {
+		c.Protocol = gocloak.StringP("openid-connect")
+	}
+	if c.Enabled == nil {
+		c.Enabled = gocloak.BoolP(true)
+	}
+	r.Clients = append(r.Clients, c)
+	r.adminEvent("CREATE", "CLIENT", "clients/"+*c.ID, c)
+	return *c.ID, f.save()
+}
+
+func (f *Fake) UpdateClient(ctx context.Context, token, realm string, client gocloak.Client) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	c, err := r.client(gocloak.PString(client.ID))
+	if err != nil {
+		return err
+	}
+	if cid := gocloak.PString(client.ClientID); cid != "" && r.clientIDTaken(cid, *c.ID) {
+		return conflict(fmt.Sprintf("Client %s already exists", cid))
+	}
+	overlay(c, client)
+	r.adminEvent("UPDATE", "CLIENT", "clients/"+*c.ID, client)
+	return f.save()
+}
+
+func (f *Fake) DeleteClient(ctx context.Context, token, realm, idOfClient string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return err
+	}
+	r.Clients = slices.DeleteFunc(r.Clients, func(c *gocloak.Client) bool { return gocloak.PString(c.ID) == idOfClient })
+	for _, role := range r.ClientRoles[idOfClient] {
+		r.unassign(*role.ID)
+	}
+	delete(r.ClientRoles, idOfClient)
+	delete(r.DefaultScopes, idOfClient)
+	delete(r.OptionalScopes, idOfClient)
+	r.adminEvent("DELETE", "CLIENT", "clients/"+idOfClient, nil)
+	return f.save()
+}
+
+// RegenerateClientSecret sets a new random secret on a confidential client.
+// Like the secret rotation policy of Keycloak, a client with a secret
+// expiration time keeps its old secret as the rotated one until that time.
+func (f *Fake) RegenerateClientSecret(ctx context.Context, token, realm, idOfClient string) (*gocloak.CredentialRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.client(idOfClient)
+	if err != nil {
+		return nil, err
+	}
+	if gocloak.PBool(c.PublicClient) {
+		return nil, &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: public clients have no secret"}
+	}
+	now := time.Now().Unix()
+	if c.Attributes == nil {
+		c.Attributes = &map[string]string{}
+	}
+	attrs := *c.Attributes
+	if expires, ok := attrs["client.secret.expiration.time"]; ok && c.Secret != nil {
+		attrs["client.secret.rotated"] = *c.Secret
+		attrs["client.secret.rotated.creation.time"] = attrs["client.secret.creation.time"]
+		attrs["client.secret.rotated.expiration.time"] = expires
+		if created, err := strconv.ParseInt(attrs["client.secret.creation.time"], 10, 64); err == nil {
+			if until, err := strconv.ParseInt(expires, 10, 64); err == nil {
+				attrs["client.secret.expiration.time"] = strconv.FormatInt(now+until-created, 10)
+			}
+		}
+	}
+	attrs["client.secret.creation.time"] = strconv.FormatInt(now, 10)
+	c.Secret = gocloak.StringP(strings.ReplaceAll(newID(), "-", ""))
+	r.adminEvent("ACTION", "CLIENT", "clients/"+idOfClient+"/client-secret", nil)
+	if err := f.save(); err != nil {
+		return nil, err
+	}
+	return &gocloak.CredentialRepresentation{Type: gocloak.StringP("secret"), Value: gocloak.StringP(*c.Secret)}, nil
+}
+
+// Client protocol mappers
+
+func (f *Fake) CreateClientProtocolMapper(ctx context.Context, token, realm, idOfClient string, mapper gocloak.ProtocolMapperRepresentation) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	c, err := r.client(idOfClient)
+	if err != nil {
+		return "", err
+	}
+	if c.ProtocolMappers == nil {
+		c.ProtocolMappers = &[]gocloak.ProtocolMapperRepresentation{}
+	}
+	name := gocloak.PString(mapper.Name)
+	if slices.ContainsFunc(*c.ProtocolMappers, func(m gocloak.ProtocolMapperRepresentation) bool { return gocloak.PString(m.Name) == name }) {
+		return "", conflict("Protocol mapper exists with same name")
+	}
+	m := clone(&mapper)
+	m.ID = gocloak.StringP(newID())
+	*c.ProtocolMappers = append(*c.ProtocolMappers, *m)
+	r.adminEvent("CREATE", "PROTOCOL_MAPPER", "clients/"+idOfClient+"/protocol-mappers/models/"+*m.ID, m)
+	return *m.ID, f.save()
+}
+
+func (f *Fake) UpdateClientProtocolMapper(ctx context.Context, token, realm, idOfClient, mapperID string, mapper gocloak.ProtocolMapperRepresentation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	c, err := r.client(idOfClient)
+	if err != nil {
+		return err
+	}
+	if c.ProtocolMappers != nil {
+		for i, m := range *c.ProtocolMappers {
+			if gocloak.PString(m.ID) == mapperID {
+				updated := clone(&mapper)
+				updated.ID = m.ID
+				(*c.ProtocolMappers)[i] = *updated
+				r.adminEvent("UPDATE", "PROTOCOL_MAPPER", "clients/"+idOfClient+"/protocol-mappers/models/"+mapperID, updated)
+				return f.save()
+			}
+		}
+	}
+	return notFound("Protocol mapper")
+}
+
+func (f *Fake) DeleteClientProtocolMapper(ctx context.Context, token, realm, idOfClient, mapperID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	c, err := r.client(idOfClient)
+	if err != nil {
+		return err
+	}
+	if c.ProtocolMappers == nil || !slices.ContainsFunc(*c.ProtocolMappers, func(m gocloak.ProtocolMapperRepresentation) bool { return gocloak.PString(m.ID) == mapperID }) {
+		return notFound("Protocol mapper")
+	}
+	*c.ProtocolMappers = slices.DeleteFunc(*c.ProtocolMappers, func(m gocloak.ProtocolMapperRepresentation) bool { return gocloak.PString(m.ID) == mapperID })
+	r.adminEvent("DELETE", "PROTOCOL_MAPPER", "clients/"+idOfClient+"/protocol-mappers/models/"+mapperID, nil)
+	return f.save()
+}
+
+// Client roles
+
+func (f *Fake) GetClientRoles(ctx context.Context, token, realm, idOfClient string, params gocloak.GetRoleParams) ([]*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return nil, err
+	}
+	return searchRoles(r.ClientRoles[idOfClient], params), nil
+}
+
+func (f *Fake) GetClientRole(ctx context.Context, token, realm, idOfClient, roleName string) (*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return nil, err
+	}
+	for _, role := range r.ClientRoles[idOfClient] {
+		if gocloak.PString(role.Name) == roleName {
+			return clone(role), nil
+		}
+	}
+	return nil, notFound("Could not find role")
+}
+
+func (f *Fake) CreateClientRole(ctx context.Context, token, realm, idOfClient string, role gocloak.Role) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return "", err
+	}
+	name := gocloak.PString(role.Name)
+	if slices.ContainsFunc(r.ClientRoles[idOfClient], func(x *gocloak.Role) bool { return gocloak.PString(x.Name) == name }) {
+		return "", conflict(fmt.Sprintf("Role with name %s already exists", name))
+	}
+	created := clone(&role)
+	created.ID = gocloak.StringP(newID())
+	created.ClientRole = gocloak.BoolP(true)
+	created.ContainerID = gocloak.StringP(idOfClient)
+	if r.ClientRoles == nil {
+		r.ClientRoles = map[string][]*gocloak.Role{}
+	}
+	r.ClientRoles[idOfClient] = append(r.ClientRoles[idOfClient], created)
+	r.adminEvent("CREATE", "CLIENT_ROLE", "clients/"+idOfClient+"/roles/"+name, created)
+	return name, f.save()
+}
+
+func (f *Fake) DeleteClientRole(ctx context.Context, token, realm, idOfClient, roleName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return err
+	}
+	i := slices.IndexFunc(r.ClientRoles[idOfClient], func(x *gocloak.Role) bool { return gocloak.PString(x.Name) == roleName })
+	if i < 0 {
+		return notFound("Role")
+	}
+	role := r.ClientRoles[idOfClient][i]
+	r.ClientRoles[idOfClient] = slices.Delete(r.ClientRoles[idOfClient], i, i+1)
+	r.unassign(*role.ID)
+	r.adminEvent("DELETE", "CLIENT_ROLE", "clients/"+idOfClient+"/roles/"+roleName, nil)
+	return f.save()
+}
+
+func (f *Fake) AddClientRoleToUser(ctx context.Context, token, realm, idOfClient, userID string, roles []gocloak.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return err
+	}
+	ids, err := roleIDs(r.ClientRoles[idOfClient], roles)
+	if err != nil {
+		return err
+	}
+	addIDs(&r.UserRoles, userID, ids)
+	r.adminEvent("CREATE", "CLIENT_ROLE_MAPPING", "users/"+userID+"/role-mappings/clients/"+idOfClient, roles)
+	return f.save()
+}
+
+func (f *Fake) GetClientRolesByUserID(ctx context.Context, token, realm, idOfClient, userID string) ([]*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.user(userID); err != nil {
+		return nil, err
+	}
+	return r.mappedClientRoles(idOfClient, r.UserRoles[userID])
+}
+
+func (f *Fake) AddClientRolesToGroup(ctx context.Context, token, realm, idOfClient, groupID string, roles []gocloak.Role) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.group(groupID); err != nil {
+		return err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return err
+	}
+	ids, err := roleIDs(r.ClientRoles[idOfClient], roles)
+	if err != nil {
+		return err
+	}
+	addIDs(&r.GroupRoles, groupID, ids)
+	r.adminEvent("CREATE", "CLIENT_ROLE_MAPPING", "groups/"+groupID+"/role-mappings/clients/"+idOfClient, roles)
+	return f.save()
+}
+
+func (f *Fake) GetClientRolesByGroupID(ctx context.Context, token, realm, idOfClient, groupID string) ([]*gocloak.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.group(groupID); err != nil {
+		return nil, err
+	}
+	return r.mappedClientRoles(idOfClient, r.GroupRoles[groupID])
+}
+
+// mappedClientRoles returns the roles of the client among the mapped role IDs.
+func (r *fakeRealm) mappedClientRoles(idOfClient string, mapped []string) ([]*gocloak.Role, error) {
+	if _, err := r.client(idOfClient); err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Role
+	for _, role := range r.ClientRoles[idOfClient] {
+		if slices.Contains(mapped, *role.ID) {
+			out = append(out, clone(role))
+		}
+	}
+	return out, nil
+}
+
+// Client scopes
+
+func (r *fakeRealm) scope(id string) (*gocloak.ClientScope, error) {
+	for _, s := range r.ClientScopes {
+		if gocloak.PString(s.ID) == id {
+			return s, nil
+		}
+	}
+	return nil, notFound("Could not find client scope")
+}
+
+func (r *fakeRealm) scopeNameTaken(name, except string) bool {
+	return slices.ContainsFunc(r.ClientScopes, func(s *gocloak.ClientScope) bool {
+		return gocloak.PString(s.Name) == name && gocloak.PString(s.ID) != except
+	})
+}
+
+func (f *Fake) GetClientScopes(ctx context.Context, token, realm string) ([]*gocloak.ClientScope, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	return cloneAll(r.ClientScopes), nil
+}
+
+func (f *Fake) CreateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	name := gocloak.PString(scope.Name)
+	if r.scopeNameTaken(name, "") {
+		return "", conflict(fmt.Sprintf("Client Scope %s already exists", name))
+	}
+	s := clone(&scope)
+	s.ID = gocloak.StringP(newID())
+	if s.Protocol == nil {
+		s.Protocol = gocloak.StringP("openid-connect")
+	}
+	if s.ProtocolMappers != nil {
+		for i := range *s.ProtocolMappers {
+			(*s.ProtocolMappers)[i].ID = gocloak.StringP(newID())
+		}
+	}
+	r.ClientScopes = append(r.ClientScopes, s)
+	r.adminEvent("CREATE", "CLIENT_SCOPE", "client-scopes/"+*s.ID, s)
+	return *s.ID, f.save()
+}
+
+func (f *Fake) UpdateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	s, err := r.scope(gocloak.PString(scope.ID))
+	if err != nil {
+		return err
+	}
+	if name := gocloak.PString(scope.Name); name != "" && r.scopeNameTaken(name, *s.ID) {
+		return conflict(fmt.Sprintf("Client Scope %s already exists", name))
+	}
+	overlay(s, scope)
+	r.adminEvent("UPDATE", "CLIENT_SCOPE", "client-scopes/"+*s.ID, scope)
+	return f.save()
+}
+
+func (f *Fake) DeleteClientScope(ctx context.Context, token, realm, scopeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.scope(scopeID); err != nil {
+		return err
+	}
+	r.ClientScopes = slices.DeleteFunc(r.ClientScopes, func(s *gocloak.ClientScope) bool { return gocloak.PString(s.ID) == scopeID })
+	for id := range r.DefaultScopes {
+		removeIDs(r.DefaultScopes, id, []string{scopeID})
+	}
+	for id := range r.OptionalScopes {
+		removeIDs(r.OptionalScopes, id, []string{scopeID})
+	}
+	r.adminEvent("DELETE", "CLIENT_SCOPE", "client-scopes/"+scopeID, nil)
+	return f.save()
+}
+
+// clientScopes returns the default or optional scopes of a client.
+func (f *Fake) clientScopes(realm, idOfClient string, optional bool) ([]*gocloak.ClientScope, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return nil, err
+	}
+	ids := r.DefaultScopes[idOfClient]
+	if optional {
+		ids = r.OptionalScopes[idOfClient]
+	}
+	var out []*gocloak.ClientScope
+	for _, s := range r.ClientScopes {
+		if slices.Contains(ids, *s.ID) {
+			out = append(out, clone(s))
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) GetClientsDefaultScopes(ctx context.Context, token, realm, idOfClient string) ([]*gocloak.ClientScope, error) {
+	return f.clientScopes(realm, idOfClient, false)
+}
+
+func (f *Fake) GetClientsOptionalScopes(ctx context.Context, token, realm, idOfClient string) ([]*gocloak.ClientScope, error) {
+	return f.clientScopes(realm, idOfClient, true)
+}
+
+// linkScope adds or removes a default or optional scope of a client.
+func (f *Fake) linkScope(realm, idOfClient, scopeID string, optional, add bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return err
+	}
+	if _, err := r.scope(scopeID); err != nil {
+		return err
+	}
+	m, kind := &r.DefaultScopes, "default-client-scopes"
+	if optional {
+		m, kind = &r.OptionalScopes, "optional-client-scopes"
+	}
+	path := "clients/" + idOfClient + "/" + kind + "/" + scopeID
+	if add {
+		addIDs(m, idOfClient, []string{scopeID})
+		r.adminEvent("CREATE", "CLIENT_SCOPE_CLIENT_MAPPING", path, nil)
+	} else {
+		if !slices.Contains((*m)[idOfClient], scopeID) {
+			return notFound("Client scope mapping")
+		}
+		removeIDs(*m, idOfClient, []string{scopeID})
+		r.adminEvent("DELETE", "CLIENT_SCOPE_CLIENT_MAPPING", path, nil)
+	}
+	return f.save()
+}
+
+func (f *Fake) AddDefaultScopeToClient(ctx context.Context, token, realm, idOfClient, scopeID string) error {
+	return f.linkScope(realm, idOfClient, scopeID, false, true)
+}
+
+func (f *Fake) AddOptionalScopeToClient(ctx context.Context, token, realm, idOfClient, scopeID string) error {
+	return f.linkScope(realm, idOfClient, scopeID, true, true)
+}
+
+func (f *Fake) RemoveDefaultScopeFromClient(ctx context.Context, token, realm, idOfClient, scopeID string) error {
+	return f.linkScope(realm, idOfClient, scopeID, false, false)
+}
+
+func (f *Fake) RemoveOptionalScopeFromClient(ctx context.Context, token, realm, idOfClient, scopeID string) error {
+	return f.linkScope(realm, idOfClient, scopeID, true, false)
+}
+
+// Groups
+
+func (r *fakeRealm) group(id string) (*gocloak.Group, error) {
+	for _, g := range r.Groups {
+		if gocloak.PString(g.ID) == id {
+			return g, nil
+		}
+	}
+	return nil, notFound("Could not find group by id")
+}
+
+// children returns the direct subgroups of the group at path; "" gives the
+// top-level groups.
+func (r *fakeRealm) children(path string) []*gocloak.Group {
+	var out []*gocloak.Group
+	for _, g := range r.Groups {
+		p := gocloak.PString(g.Path)
+		if p[:strings.LastIndex(p, "/")] == path {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// withRoles copies g with the names of its realm roles.
+func (r *fakeRealm) withRoles(g *gocloak.Group) *gocloak.Group {
+	out := clone(g)
+	names := []string{}
+	for _, role := range r.Roles {
+		if slices.Contains(r.GroupRoles[*g.ID], *role.ID) {
+			names = append(names, *role.Name)
+		}
+	}
+	out.RealmRoles = &names
+	return out
+}
+
+// addGroup creates group under parentPath or, like Keycloak, moves it there
+// when it has the ID of an existing group.
+func (r *fakeRealm) addGroup(parentPath string, group gocloak.Group) (string, error) {
+	name := gocloak.PString(group.Name)
+	if group.ID != nil {
+		if g, err := r.group(*group.ID); err == nil {
+			if name == "" {
+				name = gocloak.PString(g.Name)
+			}
+			if p := *g.Path; parentPath == p || strings.HasPrefix(parentPath, p+"/") {
+				return "", &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: Cannot move group into one of its subgroups"}
+			}
+			if err := r.renameGroup(g, parentPath, name); err != nil {
+				return "", err
+			}
+			r.adminEvent("UPDATE", "GROUP", "groups/"+*g.ID, group)
+			return *g.ID, nil
+		}
+	}
+	for _, g := range r.children(parentPath) {
+		if gocloak.PString(g.Name) == name {
+			return "", conflict(fmt.Sprintf("Top level group named '%s' already exists.", name))
+		}
+	}
+	g := clone(&group)
+	g.ID = gocloak.StringP(newID())
+	g.Path = gocloak.StringP(parentPath + "/" + name)
+	g.SubGroups = nil
+	g.RealmRoles = nil
+	r.Groups = append(r.Groups, g)
+	r.adminEvent("CREATE", "GROUP", "groups/"+*g.ID, g)
+	return *g.ID, nil
+}
+
+// renameGroup gives g the path parentPath/name, updating the paths of its
+// subgroups.
+func (r *fakeRealm) renameGroup(g *gocloak.Group, parentPath, name string) error {
+	for _, sib := range r.children(parentPath) {
+		if sib != g && gocloak.PString(sib.Name) == name {
+			return conflict(fmt.Sprintf("Sibling group named '%s' already exists.", name))
+		}
+	}
+	old, path := *g.Path, parentPath+"/"+name
+	for _, x := range r.Groups {
+		if p := gocloak.PString(x.Path); strings.HasPrefix(p, old+"/") {
+			x.Path = gocloak.StringP(path + strings.TrimPrefix(p, old))
+		}
+	}
+	g.Name = gocloak.StringP(name)
+	g.Path = gocloak.StringP(path)
+	return nil
+}
+
+func (f *Fake) GetGroups(ctx context.Context, token, realm string, params gocloak.GetGroupsParams) ([]*gocloak.Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Group
+	for _, g := range r.Groups {
+		if params.Search != nil {
+			if !matches(g.Name, *params.Search, params.Exact != nil && *params.Exact) {
+				continue
+			}
+		} else if strings.Count(gocloak.PString(g.Path), "/") > 1 {
+			continue
+		}
+		out = append(out, r.withRoles(g))
+	}
+	return page(out, params.First, params.Max), nil
+}
+
+func (f *Fake) GetGroupByPath(ctx context.Context, token, realm, groupPath string) (*gocloak.Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(groupPath, "/") {
+		groupPath = "/" + groupPath
+	}
+	for _, g := range r.Groups {
+		if gocloak.PString(g.Path) == groupPath {
+			return r.withRoles(g), nil
+		}
+	}
+	return nil, notFound("Group path does not exist")
+}
+
+func (f *Fake) CreateGroup(ctx context.Context, token, realm string, group gocloak.Group) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	id, err := r.addGroup("", group)
+	if err != nil {
+		return "", err
+	}
+	return id, f.save()
+}
+
+func (f *Fake) CreateChildGroup(ctx context.Context, token, realm, groupID string, group gocloak.Group) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	parent, err := r.group(groupID)
+	if err != nil {
+		return "", err
+	}
+	id, err := r.addGroup(*parent.Path, group)
+	if err != nil {
+		return "", err
+	}
+	return id, f.save()
+}
+
+func (f *Fake) UpdateGroup(ctx context.Context, token, realm string, group gocloak.Group) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	g, err := r.group(gocloak.PString(group.ID))
+	if err != nil {
+		return err
+	}
+	if name := gocloak.PString(group.Name); name != "" && name != gocloak.PString(g.Name) {
+		p := *g.Path
+		if err := r.renameGroup(g, p[:strings.LastIndex(p, "/")], name); err != nil {
+			return err
+		}
+	}
+	if group.Attributes != nil {
+		g.Attributes = clone(group.Attributes)
+	}
+	r.adminEvent("UPDATE", "GROUP", "groups/"+*g.ID, group)
+	return f.save()
+}
+
+func (f *Fake) DeleteGroup(ctx context.Context, token, realm, groupID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	g, err := r.group(groupID)
+	if err != nil {
+		return err
+	}
+	// subgroups go with their parent
+	prefix := *g.Path + "/"
+	var gone []string
+	r.Groups = slices.DeleteFunc(r.Groups, func(x *gocloak.Group) bool {
+		if x == g || strings.HasPrefix(gocloak.PString(x.Path), prefix) {
+			gone = append(gone, *x.ID)
+			return true
+		}
+		return false
+	})
+	for _, id := range gone {
+		delete(r.GroupRoles, id)
+	}
+	for uid := range r.UserGroups {
+		removeIDs(r.UserGroups, uid, gone)
+	}
+	r.adminEvent("DELETE", "GROUP", "groups/"+groupID, nil)
+	return f.save()
+}
+
+func (f *Fake) GetUserGroups(ctx context.Context, token, realm, userID string, params gocloak.GetGroupsParams) ([]*gocloak.Group, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.user(userID); err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Group
+	for _, g := range r.Groups {
+		if slices.Contains(r.UserGroups[userID], *g.ID) {
+			out = append(out, r.withRoles(g))
+		}
+	}
+	return page(out, params.First, params.Max), nil
+}
+
+func (f *Fake) userGroup(realm, userID, groupID string, add bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	if _, err := r.group(groupID); err != nil {
+		return err
+	}
+	path := "users/" + userID + "/groups/" + groupID
+	if add {
+		addIDs(&r.UserGroups, userID, []string{groupID})
+		r.adminEvent("CREATE", "GROUP_MEMBERSHIP", path, nil)
+	} else {
+		removeIDs(r.UserGroups, userID, []string{groupID})
+		r.adminEvent("DELETE", "GROUP_MEMBERSHIP", path, nil)
+	}
+	return f.save()
+}
+
+func (f *Fake) AddUserToGroup(ctx context.Context, token, realm, userID, groupID string) error {
+	return f.userGroup(realm, userID, groupID, true)
+}
+
+func (f *Fake) DeleteUserFromGroup(ctx context.Context, token, realm, userID, groupID string) error {
+	return f.userGroup(realm, userID, groupID, false)
+}
+
+func (f *Fake) groupRoles(realm, groupID string, roles []gocloak.Role, add bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.group(groupID); err != nil {
+		return err
+	}
+	ids, err := roleIDs(r.Roles, roles)
+	if err != nil {
+		return err
+	}
+	path := "groups/" + groupID + "/role-mappings/realm"
+	if add {
+		addIDs(&r.GroupRoles, groupID, ids)
+		r.adminEvent("CREATE", "REALM_ROLE_MAPPING", path, roles)
+	} else {
+		removeIDs(r.GroupRoles, groupID, ids)
+		r.adminEvent("DELETE", "REALM_ROLE_MAPPING", path, roles)
+	}
+	return f.save()
+}
+
+func (f *Fake) AddRealmRoleToGroup(ctx context.Context, token, realm, groupID string, roles []gocloak.Role) error {
+	return f.groupRoles(realm, groupID, roles, true)
+}
+
+func (f *Fake) DeleteRealmRoleFromGroup(ctx context.Context, token, realm, groupID string, roles []gocloak.Role) error {
+	return f.groupRoles(realm, groupID, roles, false)
+}
+
+// Events
+
+// inDateRange checks an event time against the yyyy-MM-dd bounds of the events endpoints.
+func inDateRange(ms int64, from, to string) bool {
+	day := time.UnixMilli(ms).UTC().Format(time.DateOnly)
+	return (from == "" || day >= from) && (to == "" || day <= to)
+}
+
+func (f *Fake) GetEvents(ctx context.Context, token, realm string, params gocloak.GetEventsParams) ([]*gocloak.EventRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.EventRepresentation
+	// newest first, like Keycloak
+	for i := len(r.Events) - 1; i >= 0; i-- {
+		ev := r.Events[i]
+		switch {
+		case len(params.Type) > 0 && !slices.Contains(params.Type, gocloak.PString(ev.Type)),
+			params.Client != nil && gocloak.PString(ev.ClientID) != *params.Client,
+			params.UserID != nil && gocloak.PString(ev.UserID) != *params.UserID,
+			!inDateRange(ev.Time, gocloak.PString(params.DateFrom), gocloak.PString(params.DateTo)):
+			continue
+		}
+		out = append(out, ev)
+	}
+	first, max := 0, 100
+	if params.First != nil {
+		first = int(*params.First)
+	}
+	if params.Max != nil {
+		max = int(*params.Max)
+	}
+	return cloneAll(page(out, &first, &max)), nil
+}
+
+// Sessions
+
+func (f *Fake) GetUserSessions(ctx context.Context, token, realm, userID string) ([]*gocloak.UserSessionRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.user(userID); err != nil {
+		return nil, err
+	}
+	var out []*gocloak.UserSessionRepresentation
+	for _, s := range r.Sessions {
+		if gocloak.PString(s.UserID) == userID {
+			out = append(out, s)
+		}
+	}
+	return cloneAll(out), nil
+}
+
+func (f *Fake) GetClientUserSessions(ctx context.Context, token, realm, idOfClient string, params ...gocloak.GetClientUserSessionsParams) ([]*gocloak.UserSessionRepresentation, error) {
+	return f.clientSessions(realm, idOfClient, false, params)
+}
+
+func (f *Fake) GetClientOfflineSessions(ctx context.Context, token, realm, idOfClient string, params ...gocloak.GetClientUserSessionsParams) ([]*gocloak.UserSessionRepresentation, error) {
+	return f.clientSessions(realm, idOfClient, true, params)
+}
+
+// clientSessions returns the regular or offline sessions that used a client.
+func (f *Fake) clientSessions(realm, idOfClient string, offline bool, params []gocloak.GetClientUserSessionsParams) ([]*gocloak.UserSessionRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.client(idOfClient); err != nil {
+		return nil, err
+	}
+	sessions := r.Sessions
+	if offline {
+		sessions = r.OfflineSessions
+	}
+	var out []*gocloak.UserSessionRepresentation
+	for _, s := range sessions {
+		if s.Clients != nil {
+			if _, ok := (*s.Clients)[idOfClient]; ok {
+				out = append(out, s)
+			}
+		}
+	}
+	first, max := 0, -1
+	if len(params) > 0 {
+		if params[0].First != nil {
+			first = *params[0].First
+		}
+		if params[0].Max != nil {
+			max = *params[0].Max
+		}
+	}
+	return cloneAll(page(out, &first, &max)), nil
+}
+
+func (f *Fake) LogoutAllSessions(ctx context.Context, token, realm, userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	r.Sessions = slices.DeleteFunc(r.Sessions, func(s *gocloak.UserSessionRepresentation) bool { return gocloak.PString(s.UserID) == userID })
+	r.adminEvent("ACTION", "USER", "users/"+userID+"/logout", nil)
+	return f.save()
+}
+
+func (f *Fake) LogoutUserSession(ctx context.Context, token, realm, session string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	n := len(r.Sessions)
+	r.Sessions = slices.DeleteFunc(r.Sessions, func(s *gocloak.UserSessionRepresentation) bool { return gocloak.PString(s.ID) == session })
+	if len(r.Sessions) == n {
+		return notFound("Session")
+	}
+	r.adminEvent("DELETE", "USER_SESSION", "sessions/"+session, nil)
+	return f.save()
+}
+
+// RevokeUserConsents drops the consent and the offline sessions of a user
+// for a client, identified by its clientId.
+func (f *Fake) RevokeUserConsents(ctx context.Context, token, realm, userID, clientID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.user(userID); err != nil {
+		return err
+	}
+	found := false
+	if consents := r.Consents[userID]; len(consents) > 0 {
+		r.Consents[userID] = slices.DeleteFunc(consents, func(c *UserConsent) bool { return c.ClientID == clientID })
+		found = len(r.Consents[userID]) < len(consents)
+	}
+	for _, s := range r.OfflineSessions {
+		if gocloak.PString(s.UserID) != userID || s.Clients == nil {
+			continue
+		}
+		for id, cid := range *s.Clients {
+			if cid == clientID {
+				delete(*s.Clients, id)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return notFound("Consent nor offline token")
+	}
+	r.OfflineSessions = slices.DeleteFunc(r.OfflineSessions, func(s *gocloak.UserSessionRepresentation) bool {
+		return s.Clients == nil || len(*s.Clients) == 0
+	})
+	r.adminEvent("ACTION", "USER", "users/"+userID+"/consents/"+clientID, nil)
+	return f.save()
+}
+
+// executeActionsEmail checks what Keycloak needs to send the email of
+// execute-actions-email and records the admin event; no email is sent.
+func (r *fakeRealm) executeActionsEmail(userID string, body interface{}) error {
+	u, err := r.user(userID)
+	if err != nil {
+		return err
+	}
+	if gocloak.PString(u.Email) == "" {
+		return &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: User email missing"}
+	}
+	if r.Realm.SMTPServer == nil || (*r.Realm.SMTPServer)["host"] == "" {
+		return &gocloak.APIError{Code: http.StatusInternalServerError, Message: "500 Internal Server Error: Failed to send execute actions email"}
+	}
+	r.adminEvent("ACTION", "USER", "users/"+userID+"/execute-actions-email", body)
+	return nil
+}
+
+// partialImport imports the users of body, a PartialImport, in one
+// transaction like Keycloak: an unknown role or group fails the whole import.
+// Credentials are kept as given, hashes included.
+func (r *fakeRealm) partialImport(body interface{}) (*PartialImportResult, error) {
+	var req PartialImport
+	overlay(&req, body)
+	switch req.IfResourceExists {
+	case "FAIL", "SKIP", "OVERWRITE":
+	default:
+		return nil, &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: invalid ifResourceExists"}
+	}
+	type pending struct {
+		user          *gocloak.User
+		roles, groups []string
+	}
+	var (
+		add []pending
+		res = &PartialImportResult{Results: []PartialImportItem{}}
+	)
+	for _, in := range req.Users {
+		name := strings.ToLower(gocloak.PString(in.Username))
+		if slices.ContainsFunc(r.Users, func(u *gocloak.User) bool { return gocloak.PString(u.Username) == name }) {
+			if req.IfResourceExists != "SKIP" {
+				return nil, conflict(fmt.Sprintf("User '%s' already exists", name))
+			}
+			res.Skipped++
+			res.Results = append(res.Results, PartialImportItem{Action: "SKIPPED", ResourceType: "USER", ResourceName: name})
+			continue
+		}
+		var roles []gocloak.Role
+		for _, rn := range gocloak.PStringSlice(in.RealmRoles) {
+			roles = append(roles, gocloak.Role{Name: gocloak.StringP(rn)})
+		}
+		roleIDs, err := roleIDs(r.Roles, roles)
+		if err != nil {
+			return nil, &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: role not found in user " + name}
+		}
+		var groupIDs []string
+		for _, path := range gocloak.PStringSlice(in.Groups) {
+			i := slices.IndexFunc(r.Groups, func(g *gocloak.Group) bool { return gocloak.PString(g.Path) == path })
+			if i < 0 {
+				return nil, &gocloak.APIError{Code: http.StatusBadRequest, Message: "400 Bad Request: group " + path + " not found in user " + name}
+			}
+			groupIDs = append(groupIDs, *r.Groups[i].ID)
+		}
+		u := clone(in)
+		u.ID = gocloak.StringP(newID())
+		u.Username = gocloak.StringP(name)
+		u.RealmRoles, u.Groups = nil, nil
+		if u.CreatedTimestamp == nil {
+			u.CreatedTimestamp = gocloak.Int64P(time.Now().UnixMilli())
+		}
+		add = append(add, pending{u, roleIDs, groupIDs})
+	}
+	for _, p := range add {
+		r.Users = append(r.Users, p.user)
+		addIDs(&r.UserRoles, *p.user.ID, p.roles)
+		addIDs(&r.UserGroups, *p.user.ID, p.groups)
+		res.Added++
+		res.Results = append(res.Results, PartialImportItem{Action: "ADDED", ResourceType: "USER", ResourceName: *p.user.Username, ID: *p.user.ID})
+	}
+	r.adminEvent("CREATE", "REALM", "partialImport", nil)
+	return res, nil
+}
+
+// consents returns the seeded consents of a user, with an offline token
+// grant for each client holding one of its offline sessions.
+func (r *fakeRealm) consents(userID string) ([]*UserConsent, error) {
+	if _, err := r.user(userID); err != nil {
+		return nil, err
+	}
+	var out []*UserConsent
+	byClient := map[string]*UserConsent{}
+	for _, c := range r.Consents[userID] {
+		cp := *c
+		cp.AdditionalGrants = slices.Clone(c.AdditionalGrants)
+		byClient[c.ClientID] = &cp
+		out = append(out, &cp)
+	}
+	for _, s := range r.OfflineSessions {
+		if gocloak.PString(s.UserID) != userID || s.Clients == nil {
+			continue
+		}
+		for _, cid := range *s.Clients {
+			c := byClient[cid]
+			if c == nil {
+				c = &UserConsent{ClientID: cid}
+				byClient[cid] = c
+				out = append(out, c)
+			}
+			if !c.HasOfflineTokens() {
+				c.AdditionalGrants = append(c.AdditionalGrants, AdditionalGrant{Client: cid, Key: OfflineTokenGrant})
+			}
+		}
+	}
+	slices.SortFunc(out, func(a, b *UserConsent) int { return strings.Compare(a.ClientID, b.ClientID) })
+	return out, nil
+}
+
+// Components
+
+func (f *Fake) GetComponentsWithParams(ctx context.Context, token, realm string, params gocloak.GetComponentsParams) ([]*gocloak.Component, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.Component
+	for _, c := range r.Components {
+		switch {
+		case params.Name != nil && gocloak.PString(c.Name) != *params.Name,
+			params.ProviderType != nil && gocloak.PString(c.ProviderType) != *params.ProviderType,
+			params.ParentID != nil && gocloak.PString(c.ParentID) != *params.ParentID:
+			continue
+		}
+		out = append(out, c)
+	}
+	return cloneAll(out), nil
+}
+
+func (f *Fake) CreateComponent(ctx context.Context, token, realm string, component gocloak.Component) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return "", err
+	}
+	c := clone(&component)
+	c.ID = gocloak.StringP(newID())
+	if c.ParentID == nil {
+		c.ParentID = r.Realm.ID
+	}
+	r.Components = append(r.Components, c)
+	r.adminEvent("CREATE", "COMPONENT", "components/"+*c.ID, c)
+	return *c.ID, f.save()
+}
+
+func (f *Fake) UpdateComponent(ctx context.Context, token, realm string, component gocloak.Component) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	c, err := r.component(gocloak.PString(component.ID))
+	if err != nil {
+		return err
+	}
+	overlay(c, component)
+	// the config is replaced, not merged
+	if component.ComponentConfig != nil {
+		c.ComponentConfig = clone(&component).ComponentConfig
+	}
+	r.adminEvent("UPDATE", "COMPONENT", "components/"+*c.ID, component)
+	return f.save()
+}
+
+func (f *Fake) DeleteComponent(ctx context.Context, token, realm, componentID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	if _, err := r.component(componentID); err != nil {
+		return err
+	}
+	r.Components = slices.DeleteFunc(r.Components, func(c *gocloak.Component) bool { return gocloak.PString(c.ID) == componentID })
+	r.adminEvent("DELETE", "COMPONENT", "components/"+componentID, nil)
+	return f.save()
+}
+
+func (r *fakeRealm) component(id string) (*gocloak.Component, error) {
+	for _, c := range r.Components {
+		if gocloak.PString(c.ID) == id {
+			return c, nil
+		}
+	}
+	return nil, notFound("Could not find component")
+}
+
+// Fine-grained admin permissions
+
+// fakePermissionScopes are the scopes Keycloak creates a permission for when
+// admin permissions are enabled on users, a client or a group.
+var fakePermissionScopes = map[string][]string{
+	"users":   {"view", "manage", "map-roles", "manage-group-membership", "impersonate", "user-impersonated"},
+	"clients": {"view", "manage", "configure", "map-roles", "map-roles-client-scope", "map-roles-composite", "token-exchange"},
+	"groups":  {"view", "manage", "view-members", "manage-members", "manage-membership"},
+}
+
+func (f *Fake) GetUsersManagementPermissions(ctx context.Context, token, realm string) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "users", nil)
+}
+
+func (f *Fake) UpdateUsersManagementPermissions(ctx context.Context, token, realm string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "users", &permissions)
+}
+
+func (f *Fake) GetClientManagementPermissions(ctx context.Context, token, realm, idOfClient string) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "clients/"+idOfClient, nil)
+}
+
+func (f *Fake) UpdateClientManagementPermissions(ctx context.Context, token, realm, idOfClient string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "clients/"+idOfClient, &permissions)
+}
+
+func (f *Fake) GetGroupManagementPermissions(ctx context.Context, token, realm, idOfGroup string) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "groups/"+idOfGroup, nil)
+}
+
+func (f *Fake) UpdateGroupManagementPermissions(ctx context.Context, token, realm, idOfGroup string, permissions gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error) {
+	return f.managementPermissions(realm, "groups/"+idOfGroup, &permissions)
+}
+
+// managementPermissions reads the admin permissions of key, after applying
+// update when it is not nil. Enabling creates one scope permission per scope,
+// disabling deletes them, as Keycloak does.
+func (f *Fake) managementPermissions(realm, key string, update *gocloak.ManagementPermissionRepresentation) (*gocloak.ManagementPermissionRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	kind, id, _ := strings.Cut(key, "/")
+	switch kind {
+	case "clients":
+		_, err = r.client(id)
+	case "groups":
+		_, err = r.group(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	current := r.AdminPermissions[key]
+	if current == nil {
+		current = &gocloak.ManagementPermissionRepresentation{Enabled: gocloak.BoolP(false)}
+	}
+	if update == nil || update.Enabled == nil || *update.Enabled == *current.Enabled {
+		return clone(current), nil
+	}
+
+	if *update.Enabled {
+		r.ensureRealmManagement()
+		scopes := map[string]string{}
+		for _, scope := range fakePermissionScopes[kind] {
+			name := scope + ".permission." + strings.TrimSuffix(kind, "s")
+			if id != "" {
+				name += "." + id
+			} else {
+				name += "s"
+			}
+			p := &gocloak.PolicyRepresentation{
+				ID:               gocloak.StringP(newID()),
+				Name:             gocloak.StringP(name),
+				Type:             gocloak.StringP("scope"),
+				Logic:            gocloak.POSITIVE,
+				DecisionStrategy: gocloak.UNANIMOUS,
+			}
+			r.Policies = append(r.Policies, p)
+			scopes[scope] = *p.ID
+		}
+		current = &gocloak.ManagementPermissionRepresentation{Enabled: gocloak.BoolP(true), Resource: gocloak.StringP(newID()), ScopePermissions: &scopes}
+		if r.AdminPermissions == nil {
+			r.AdminPermissions = map[string]*gocloak.ManagementPermissionRepresentation{}
+		}
+		r.AdminPermissions[key] = current
+	} else {
+		for _, permID := range *current.ScopePermissions {
+			r.Policies = slices.DeleteFunc(r.Policies, func(p *gocloak.PolicyRepresentation) bool { return gocloak.PString(p.ID) == permID })
+		}
+		delete(r.AdminPermissions, key)
+		current = &gocloak.ManagementPermissionRepresentation{Enabled: gocloak.BoolP(false)}
+	}
+	path := key + "/management/permissions"
+	if kind == "users" {
+		path = "users-management-permissions"
+	}
+	r.adminEvent("UPDATE", strings.ToUpper(strings.TrimSuffix(kind, "s")), path, current)
+	return clone(current), f.save()
+}
+
+// fakeRealmManagementRoles are the admin roles of realm-management.
+var fakeRealmManagementRoles = []string{
+	"create-client", "impersonation", "manage-authorization", "manage-clients", "manage-events",
+	"manage-identity-providers", "manage-realm", "manage-users", "query-clients", "query-groups",
+	"query-realms", "query-users", "realm-admin", "view-authorization", "view-clients", "view-events",
+	"view-identity-providers", "view-realm", "view-users",
+}
+
+// ensureRealmManagement adds the realm-management client and its admin roles,
+// which Keycloak creates with every realm, the first time the fake needs
+// them. It reports whether it changed the realm.
+func (r *fakeRealm) ensureRealmManagement() bool {
+	if r.clientIDTaken("realm-management", "") {
+		return false
+	}
+	c := &gocloak.Client{
+		ID:         gocloak.StringP(newID()),
+		ClientID:   gocloak.StringP("realm-management"),
+		Name:       gocloak.StringP("${client_realm-management}"),
+		Enabled:    gocloak.BoolP(true),
+		BearerOnly: gocloak.BoolP(true),
+	}
+	r.Clients = append(r.Clients, c)
+	if r.ClientRoles == nil {
+		r.ClientRoles = map[string][]*gocloak.Role{}
+	}
+	for _, name := range fakeRealmManagementRoles {
+		r.ClientRoles[*c.ID] = append(r.ClientRoles[*c.ID], &gocloak.Role{
+			ID:          gocloak.StringP(newID()),
+			Name:        gocloak.StringP(name),
+			ClientRole:  gocloak.BoolP(true),
+			ContainerID: c.ID,
+		})
+	}
+	return true
+}
+
+// The fake keeps the policies of realm-management only, whatever idOfClient
+// the calls below name.
+
+func (f *Fake) GetPolicies(ctx context.Context, token, realm, idOfClient string, params gocloak.GetPolicyParams) ([]*gocloak.PolicyRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.PolicyRepresentation
+	for _, p := range r.Policies {
+		isPermission := gocloak.PString(p.Type) == "scope" || gocloak.PString(p.Type) == "resource"
+		switch {
+		case params.Name != nil && !matches(p.Name, *params.Name, false),
+			params.Type != nil && gocloak.PString(p.Type) != *params.Type,
+			params.Permission != nil && *params.Permission != isPermission:
+			continue
+		}
+		out = append(out, p)
+	}
+	return cloneAll(page(out, params.First, params.Max)), nil
+}
+
+func (f *Fake) CreatePolicy(ctx context.Context, token, realm, idOfClient string, policy gocloak.PolicyRepresentation) (*gocloak.PolicyRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	if gocloak.NilOrEmpty(policy.Type) {
+		return nil, errors.New("type of a policy required")
+	}
+	if _, err := r.policy(gocloak.PString(policy.Name)); err == nil {
+		return nil, conflict(fmt.Sprintf("Policy with name [%s] already exists", gocloak.PString(policy.Name)))
+	}
+	p := clone(&policy)
+	p.ID = gocloak.StringP(newID())
+	if p.Logic == nil {
+		p.Logic = gocloak.POSITIVE
+	}
+	if p.DecisionStrategy == nil {
+		p.DecisionStrategy = gocloak.UNANIMOUS
+	}
+	r.Policies = append(r.Policies, p)
+	r.adminEvent("CREATE", "AUTHORIZATION_POLICY", "clients/"+idOfClient+"/authz/resource-server/policy/"+*p.Type, p)
+	return clone(p), f.save()
+}
+
+func (f *Fake) GetAuthorizationPolicyAssociatedPolicies(ctx context.Context, token, realm, idOfClient, policyID string) ([]*gocloak.PolicyRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.policy(policyID)
+	if err != nil {
+		return nil, err
+	}
+	var out []*gocloak.PolicyRepresentation
+	if p.Policies != nil {
+		for _, id := range *p.Policies {
+			if a, err := r.policy(id); err == nil {
+				out = append(out, a)
+			}
+		}
+	}
+	return cloneAll(out), nil
+}
+
+func (f *Fake) GetPermission(ctx context.Context, token, realm, idOfClient, permissionID string) (*gocloak.PermissionRepresentation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.policy(permissionID)
+	if err != nil {
+		return nil, err
+	}
+	// like Keycloak, the associated policies are only listed on their own
+	return &gocloak.PermissionRepresentation{
+		ID:               p.ID,
+		Name:             p.Name,
+		Description:      p.Description,
+		Type:             p.Type,
+		Logic:            p.Logic,
+		DecisionStrategy: p.DecisionStrategy,
+	}, nil
+}
+
+func (f *Fake) UpdatePermission(ctx context.Context, token, realm, idOfClient string, permission gocloak.PermissionRepresentation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(realm)
+	if err != nil {
+		return err
+	}
+	p, err := r.policy(gocloak.PString(permission.ID))
+	if err != nil {
+		return err
+	}
+	if permission.Policies != nil {
+		ids := make([]string, 0, len(*permission.Policies))
+		for _, id := range *permission.Policies {
+			a, err := r.policy(id)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, *a.ID)
+		}
+		p.Policies = &ids
+	}
+	if permission.Description != nil {
+		p.Description = permission.Description
+	}
+	if permission.DecisionStrategy != nil {
+		p.DecisionStrategy = permission.DecisionStrategy
+	}
+	r.adminEvent("UPDATE", "AUTHORIZATION_SCOPE_PERMISSION", "clients/"+idOfClient+"/authz/resource-server/permission/scope/"+*p.ID, permission)
+	return f.save()
+}
+
+// policy finds a policy or permission by ID or, as Keycloak accepts in
+// permission updates, by name.
+func (r *fakeRealm) policy(idOrName string) (*gocloak.PolicyRepresentation, error) {
+	for _, p := range r.Policies {
+		if gocloak.PString(p.ID) == idOrName || gocloak.PString(p.Name) == idOrName {
+			return p, nil
+		}
+	}
+	return nil, notFound("Policy")
+}
+
+// Do serves the raw endpoints kc uses: admin events, group children, role
+// composites and users, client scope mappers, user consents, initial access
+// tokens and realm logout. Anything else fails with
+// 501.
+func (f *Fake) Do(ctx context.Context, token, method, rawURL string, query url.Values, body, result interface{}) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	_, rest, ok := strings.Cut(u.Path, "/admin/realms/")
+	parts := strings.Split(rest, "/")
+	if !ok || len(parts) < 2 {
+		return fakeUnsupported(method, u.Path)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r, err := f.realm(parts[0])
+	if err != nil {
+		return err
+	}
+	var out interface{}
+	switch {
+	case parts[1] == "clients-initial-access":
+		out, err = r.initialAccess(method, parts[2:], body)
+		if err != nil {
+			return err
+		}
+		if method != http.MethodGet {
+			if err := f.save(); err != nil {
+				return err
+			}
+		}
+	case method == http.MethodPost && len(parts) == 2 && parts[1] == "logout-all":
+		r.Sessions = nil
+		r.adminEvent("ACTION", "REALM", "logout-all", nil)
+		out = GlobalRequestResult{}
+		if err := f.save(); err != nil {
+			return err
+		}
+	case method == http.MethodPost && len(parts) == 2 && parts[1] == "push-revocation":
+		r.adminEvent("ACTION", "REALM", "push-revocation", nil)
+		out = GlobalRequestResult{}
+	case method == http.MethodPut && len(parts) == 4 && parts[1] == "users" && parts[3] == "execute-actions-email":
+		if err := r.executeActionsEmail(parts[2], body); err != nil {
+			return err
+		}
+		if err := f.save(); err != nil {
+			return err
+		}
+	case method == http.MethodPost && len(parts) == 2 && parts[1] == "partialImport":
+		if out, err = r.partialImport(body); err != nil {
+			return err
+		}
+		if err := f.save(); err != nil {
+			return err
+		}
+	case method != http.MethodGet:
+		return fakeUnsupported(method, u.Path)
+	case len(parts) == 2 && parts[1] == "admin-events":
+		out = r.adminEvents(query)
+	case len(parts) == 3 && parts[1] == "users" && parts[2] == "count":
+		out = len(r.Users)
+	case len(parts) == 2 && parts[1] == "client-session-stats":
+		out = r.clientSessionStats()
+	case len(parts) == 3 && parts[1] == "identity-provider" && parts[2] == "instances":
+		idps := []*IdentityProvider{}
+		out = append(idps, r.IdentityProviders...)
+	case len(parts) == 5 && parts[1] == "roles" && parts[3] == "composites" && parts[4] == "realm":
+		if out, err = r.realmComposites(parts[2]); err != nil {
+			return err
+		}
+	case len(parts) == 4 && parts[1] == "roles" && parts[3] == "users":
+		if out, err = r.realmRoleUsers(parts[2], query); err != nil {
+			return err
+		}
+	case len(parts) == 5 && parts[1] == "client-scopes" && parts[3] == "protocol-mappers" && parts[4] == "models":
+		sc, err := r.scope(parts[2])
+		if err != nil {
+			return err
+		}
+		out = []gocloak.ProtocolMappers{}
+		if sc.ProtocolMappers != nil {
+			out = *sc.ProtocolMappers
+		}
+	case len(parts) == 4 && parts[1] == "users" && parts[3] == "consents":
+		if out, err = r.consents(parts[2]); err != nil {
+			return err
+		}
+	case len(parts) == 4 && parts[1] == "groups" && parts[3] == "children":
+		g, err := r.group(parts[2])
+		if err != nil {
+			return err
+		}
+		var children []*gocloak.Group
+		for _, c := range r.children(*g.Path) {
+			children = append(children, r.withRoles(c))
+		}
+		first, _ := strconv.Atoi(query.Get("first"))
+		max := -1
+		if v := query.Get("max"); v != "" {
+			max, _ = strconv.Atoi(v)
+		}
+		out = page(children, &first, &max)
+	default:
+		return fakeUnsupported(method, u.Path)
+	}
+	if result == nil {
+		return nil
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// GetServerInfo reports the Keycloak version the fake imitates and the
+// themes a stock server of that version ships.
+func (f *Fake) GetServerInfo(ctx context.Context, token string) (*gocloak.ServerInfoRepresentation, error) {
+	themes := func(names ...string) []gocloak.ThemeRepresentation {
+		out := make([]gocloak.ThemeRepresentation, 0, len(names))
+		for _, n := range names {
+			out = append(out, gocloak.ThemeRepresentation{Name: n, Locales: []string{"en"}})
+		}
+		return out
+	}
+	return &gocloak.ServerInfoRepresentation{
+		SystemInfo: &gocloak.SystemInfoRepresentation{Version: gocloak.StringP(FakeVersion)},
+		Themes: &gocloak.Themes{
+			Login:    themes("base", "keycloak", "keycloak.v2"),
+			Accounts: themes("base", "keycloak.v3"),
+			Admin:    themes("base", "keycloak.v2"),
+			Email:    themes("base", "keycloak"),
+			Common:   themes("base", "keycloak"),
+			Welcome:  themes("keycloak"),
+		},
+	}, nil
+}
+
+// initialAccess serves clients-initial-access; rest is the path after it.
+func (r *fakeRealm) initialAccess(method string, rest []string, body interface{}) (interface{}, error) {
+	switch {
+	case method == http.MethodGet && len(rest) == 0:
+		return r.InitialAccess, nil
+	case method == http.MethodPost && len(rest) == 0:
+		var req initialAccessRequest
+		overlay(&req, body)
+		a := &InitialAccess{ID: newID(), Timestamp: time.Now().Unix(), Expiration: req.Expiration, Count: req.Count, RemainingCount: req.Count}
+		r.InitialAccess = append(r.InitialAccess, a)
+		r.adminEvent("CREATE", "CLIENT_INITIAL_ACCESS_MODEL", "clients-initial-access/"+a.ID, nil)
+		created := *a
+		created.Token = "fake-initial-access-" + a.ID
+		return created, nil
+	case method == http.MethodDelete && len(rest) == 1:
+		if !slices.ContainsFunc(r.InitialAccess, func(a *InitialAccess) bool { return a.ID == rest[0] }) {
+			return nil, notFound("Could not find initial access token")
+		}
+		r.InitialAccess = slices.DeleteFunc(r.InitialAccess, func(a *InitialAccess) bool { return a.ID == rest[0] })
+		r.adminEvent("DELETE", "CLIENT_INITIAL_ACCESS_MODEL", "clients-initial-access/"+rest[0], nil)
+		return nil, nil
+	}
+	return nil, fakeUnsupported(method, "clients-initial-access")
+}
+
+// clientSessionStats counts the regular and offline sessions of each client
+// that has any, like the client-session-stats endpoint.
+func (r *fakeRealm) clientSessionStats() []*ClientSessionStat {
+	var out []*ClientSessionStat
+	for _, c := range r.Clients {
+		st := &ClientSessionStat{ID: gocloak.PString(c.ID), ClientID: gocloak.PString(c.ClientID)}
+		for _, s := range r.Sessions {
+			if s.Clients != nil {
+				if _, ok := (*s.Clients)[st.ID]; ok {
+					st.Active++
+				}
+			}
+		}
+		for _, s := range r.OfflineSessions {
+			if s.Clients != nil {
+				if _, ok := (*s.Clients)[st.ID]; ok {
+					st.Offline++
+				}
+			}
+		}
+		if st.Active > 0 || st.Offline > 0 {
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+func fakeUnsupported(method, path string) error {
+	return &gocloak.APIError{Code: http.StatusNotImplemented, Message: fmt.Sprintf("501 Not Implemented: %s %s is not supported by the fake Keycloak", method, path)}
+}
+
+func (r *fakeRealm) adminEvents(q url.Values) []*AdminEvent {
+	var out []*AdminEvent
+	for i := len(r.AdminEvents) - 1; i >= 0; i-- {
+		ev := r.AdminEvents[i]
+		switch {
+		case len(q["operationTypes"]) > 0 && !slices.Contains(q["operationTypes"], ev.OperationType),
+			len(q["resourceTypes"]) > 0 && !slices.Contains(q["resourceTypes"], ev.ResourceType),
+			q.Get("resourcePath") != "" && !strings.HasPrefix(ev.ResourcePath, strings.TrimSuffix(q.Get("resourcePath"), "*")),
+			q.Get("authUser") != "" && ev.AuthDetails.UserID != q.Get("authUser"),
+			!inDateRange(ev.Time, q.Get("dateFrom"), q.Get("dateTo")):
+			continue
+		}
+		out = append(out, clone(ev))
+	}
+	first, _ := strconv.Atoi(q.Get("first"))
+	max := 100
+	if v := q.Get("max"); v != "" {
+		max, _ = strconv.Atoi(v)
+	}
+	return page(out, &first, &max)
+}
@@ -0,0 +1,320 @@
+package keycloak
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// newTestFake returns a Fake with the realm "test", the user alice, the realm
+// role viewer assigned to her and the confidential client api.
+func newTestFake(t *testing.T) (f *Fake, userID, clientID string) {
+	t.Helper()
+	ctx := context.Background()
+	f = NewFake()
+	if _, err := f.CreateRealm(ctx, "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test")}); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := f.CreateUser(ctx, "", "test", gocloak.User{Username: gocloak.StringP("alice"), Email: gocloak.StringP("alice@example.org"), Enabled: gocloak.BoolP(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.CreateRealmRole(ctx, "", "test", gocloak.Role{Name: gocloak.StringP("viewer")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddRealmRoleToUser(ctx, "", "test", userID, []gocloak.Role{{Name: gocloak.StringP("viewer")}}); err != nil {
+		t.Fatal(err)
+	}
+	if clientID, err = f.CreateClient(ctx, "", "test", gocloak.Client{ClientID: gocloak.StringP("api")}); err != nil {
+		t.Fatal(err)
+	}
+	return f, userID, clientID
+}
+
+// statusOf returns the HTTP status of an error of the Fake, or 0.
+func statusOf(err error) int {
+	var apiErr *gocloak.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return 0
+}
+
+func TestFakeStatusCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(f *Fake, userID, clientID string) error
+		want int
+	}{
+		{
+			name: "duplicate realm",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.CreateRealm(context.Background(), "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test")})
+				return err
+			},
+			want: http.StatusConflict,
+		},
+		{
+			name: "duplicate username in another case",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.CreateUser(context.Background(), "", "test", gocloak.User{Username: gocloak.StringP("Alice")})
+				return err
+			},
+			want: http.StatusConflict,
+		},
+		{
+			name: "duplicate role",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.CreateRealmRole(context.Background(), "", "test", gocloak.Role{Name: gocloak.StringP("viewer")})
+				return err
+			},
+			want: http.StatusConflict,
+		},
+		{
+			name: "duplicate client",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.CreateClient(context.Background(), "", "test", gocloak.Client{ClientID: gocloak.StringP("api")})
+				return err
+			},
+			want: http.StatusConflict,
+		},
+		{
+			name: "client renamed to a taken clientId",
+			call: func(f *Fake, _, _ string) error {
+				id, err := f.CreateClient(context.Background(), "", "test", gocloak.Client{ClientID: gocloak.StringP("web")})
+				if err != nil {
+					return err
+				}
+				return f.UpdateClient(context.Background(), "", "test", gocloak.Client{ID: &id, ClientID: gocloak.StringP("api")})
+			},
+			want: http.StatusConflict,
+		},
+		{
+			name: "missing realm",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.GetUsers(context.Background(), "", "nowhere", gocloak.GetUsersParams{})
+				return err
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "missing user",
+			call: func(f *Fake, _, _ string) error {
+				return f.DeleteUser(context.Background(), "", "test", "no-such-id")
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "missing role",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.GetRealmRole(context.Background(), "", "test", "editor")
+				return err
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "missing client",
+			call: func(f *Fake, _, _ string) error {
+				_, err := f.GetClient(context.Background(), "", "test", "no-such-id")
+				return err
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "assigning a missing role",
+			call: func(f *Fake, userID, _ string) error {
+				return f.AddRealmRoleToUser(context.Background(), "", "test", userID, []gocloak.Role{{Name: gocloak.StringP("editor")}})
+			},
+			want: http.StatusNotFound,
+		},
+		{
+			name: "secret of a public client",
+			call: func(f *Fake, _, _ string) error {
+				id, err := f.CreateClient(context.Background(), "", "test", gocloak.Client{ClientID: gocloak.StringP("spa"), PublicClient: gocloak.BoolP(true)})
+				if err != nil {
+					return err
+				}
+				_, err = f.RegenerateClientSecret(context.Background(), "", "test", id)
+				return err
+			},
+			want: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, userID, clientID := newTestFake(t)
+			err := tt.call(f, userID, clientID)
+			if got := statusOf(err); got != tt.want {
+				t.Fatalf("status = %d (%v), want %d", got, err, tt.want)
+			}
+			if !strings.Contains(err.Error(), http.StatusText(tt.want)) {
+				t.Errorf("error %q does not name %s, which kc matches on", err, http.StatusText(tt.want))
+			}
+		})
+	}
+}
+
+func TestFakeUpdateOverlays(t *testing.T) {
+	ctx := context.Background()
+	f, userID, clientID := newTestFake(t)
+	if err := f.UpdateUser(ctx, "", "test", gocloak.User{ID: &userID, FirstName: gocloak.StringP("Alice")}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := f.GetUserByID(ctx, "", "test", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gocloak.PString(u.FirstName) != "Alice" || gocloak.PString(u.Email) != "alice@example.org" || !gocloak.PBool(u.Enabled) {
+		t.Errorf("user after a partial update = %+v, want the other fields kept", u)
+	}
+
+	attrs := map[string][]string{"team": {"a"}}
+	if err := f.UpdateUser(ctx, "", "test", gocloak.User{ID: &userID, Attributes: &attrs}); err != nil {
+		t.Fatal(err)
+	}
+	attrs = map[string][]string{"site": {"b"}}
+	if err := f.UpdateUser(ctx, "", "test", gocloak.User{ID: &userID, Attributes: &attrs}); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = f.GetUserByID(ctx, "", "test", userID); len(*u.Attributes) != 1 || (*u.Attributes)["site"][0] != "b" {
+		t.Errorf("attributes = %v, want them replaced", *u.Attributes)
+	}
+
+	if err := f.UpdateClient(ctx, "", "test", gocloak.Client{ID: &clientID, Name: gocloak.StringP("API")}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := f.GetClient(ctx, "", "test", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gocloak.PString(c.ClientID) != "api" || gocloak.PString(c.Name) != "API" || !gocloak.PBool(c.Enabled) {
+		t.Errorf("client after a partial update = %+v", c)
+	}
+
+	// returned representations are copies
+	c.Name = gocloak.StringP("changed")
+	if c, _ = f.GetClient(ctx, "", "test", clientID); gocloak.PString(c.Name) != "API" {
+		t.Errorf("changing a returned client changed the Fake: %q", gocloak.PString(c.Name))
+	}
+}
+
+func TestFakeDeleteUnassigns(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete func(f *Fake, clientID string) error
+	}{
+		{
+			name: "realm role",
+			delete: func(f *Fake, _ string) error {
+				return f.DeleteRealmRole(context.Background(), "", "test", "viewer")
+			},
+		},
+		{
+			name: "client with its roles",
+			delete: func(f *Fake, clientID string) error {
+				return f.DeleteClient(context.Background(), "", "test", clientID)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f, userID, clientID := newTestFake(t)
+			if _, err := f.CreateClientRole(ctx, "", "test", clientID, gocloak.Role{Name: gocloak.StringP("reader")}); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.AddClientRoleToUser(ctx, "", "test", clientID, userID, []gocloak.Role{{Name: gocloak.StringP("reader")}}); err != nil {
+				t.Fatal(err)
+			}
+			before := len(f.realms["test"].UserRoles[userID])
+			if err := tt.delete(f, clientID); err != nil {
+				t.Fatal(err)
+			}
+			if after := len(f.realms["test"].UserRoles[userID]); after != before-1 {
+				t.Errorf("user holds %d role(s) after the delete, want %d", after, before-1)
+			}
+		})
+	}
+}
+
+func TestFakeRenameRole(t *testing.T) {
+	ctx := context.Background()
+	f, userID, _ := newTestFake(t)
+	if err := f.UpdateRealmRole(ctx, "", "test", "viewer", gocloak.Role{Name: gocloak.StringP("reader")}); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := f.GetRealmRolesByUserID(ctx, "", "test", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || gocloak.PString(roles[0].Name) != "reader" {
+		t.Errorf("roles of the user = %v, want the renamed role", roles)
+	}
+	if _, err := f.GetRealmRole(ctx, "", "test", "viewer"); statusOf(err) != http.StatusNotFound {
+		t.Errorf("old name: %v, want 404", err)
+	}
+}
+
+func TestFakeRegenerateClientSecret(t *testing.T) {
+	ctx := context.Background()
+	f, _, clientID := newTestFake(t)
+	first, err := f.RegenerateClientSecret(ctx, "", "test", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrs := map[string]string{"client.secret.expiration.time": "4102444800"}
+	if err := f.UpdateClient(ctx, "", "test", gocloak.Client{ID: &clientID, Attributes: &attrs}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := f.RegenerateClientSecret(ctx, "", "test", clientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gocloak.PString(second.Value) == "" || gocloak.PString(second.Value) == gocloak.PString(first.Value) {
+		t.Fatalf("secrets %q then %q, want a new one", gocloak.PString(first.Value), gocloak.PString(second.Value))
+	}
+	c, _ := f.GetClient(ctx, "", "test", clientID)
+	if got := (*c.Attributes)["client.secret.rotated"]; got != gocloak.PString(first.Value) {
+		t.Errorf("rotated secret = %q, want the previous one under a rotation policy", got)
+	}
+}
+
+func TestLoadFake(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	f, err := LoadFake(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.CreateRealm(ctx, "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.CreateUser(ctx, "", "test", gocloak.User{Username: gocloak.StringP("alice")}); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := LoadFake(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := again.GetUsers(ctx, "", "test", gocloak.GetUsersParams{})
+	if err != nil || len(users) != 1 || gocloak.PString(users[0].Username) != "alice" {
+		t.Fatalf("users after reloading = %v (%v)", users, err)
+	}
+	if _, err := os.Stat(path + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFake(path); err == nil || !strings.Contains(err.Error(), "invalid fake state") {
+		t.Errorf("LoadFake of a broken file: %v", err)
+	}
+}
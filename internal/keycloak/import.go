@@ -0,0 +1,44 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// PartialImport is the body of a partial import into a realm. Unlike the
+// users endpoint, it accepts users with their role mappings, groups and
+// credentials, including hashed passwords.
+type PartialImport struct {
+	// IfResourceExists is FAIL, SKIP or OVERWRITE.
+	IfResourceExists string          `json:"ifResourceExists"`
+	Users            []*gocloak.User `json:"users,omitempty"`
+}
+
+// PartialImportResult is what a partial import did, resource by resource.
+type PartialImportResult struct {
+	Added       int                 `json:"added"`
+	Skipped     int                 `json:"skipped"`
+	Overwritten int                 `json:"overwritten"`
+	Results     []PartialImportItem `json:"results"`
+}
+
+// PartialImportItem is the outcome of one resource of a partial import.
+type PartialImportItem struct {
+	// Action is ADDED, SKIPPED or OVERWRITTEN.
+	Action       string `json:"action"`
+	ResourceType string `json:"resourceType"`
+	ResourceName string `json:"resourceName"`
+	ID           string `json:"id"`
+}
+
+// ImportPartial imports req into realm in one transaction: when a resource
+// fails, nothing of req is imported.
+func ImportPartial(ctx context.Context, api API, token, realm string, req PartialImport) (*PartialImportResult, error) {
+	var res PartialImportResult
+	if err := api.Do(ctx, token, http.MethodPost, AdminURL(realm, "partialImport"), nil, req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,113 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// OrganizationsVersion is the first Keycloak major with the Organizations API.
+const OrganizationsVersion = 25
+
+// Organization is the representation of the organizations endpoint.
+type Organization struct {
+	ID          string               `json:"id,omitempty"`
+	Name        string               `json:"name"`
+	Alias       string               `json:"alias,omitempty"`
+	Enabled     bool                 `json:"enabled"`
+	Description string               `json:"description,omitempty"`
+	RedirectURL string               `json:"redirectUrl,omitempty"`
+	Domains     []OrganizationDomain `json:"domains,omitempty"`
+	Attributes  map[string][]string  `json:"attributes,omitempty"`
+}
+
+// OrganizationDomain is an internet domain owned by an organization.
+type OrganizationDomain struct {
+	Name     string `json:"name"`
+	Verified bool   `json:"verified"`
+}
+
+// GetOrganizations lists the organizations of a realm whose name or domain
+// contains search, or equals it with exact.
+func GetOrganizations(ctx context.Context, api API, token, realm, search string, exact bool) ([]*Organization, error) {
+	q := url.Values{}
+	if search != "" {
+		q.Set("search", search)
+		if exact {
+			q.Set("exact", "true")
+		}
+	}
+	q.Set("max", "-1")
+	var out []*Organization
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "organizations"), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FindOrganization returns the organization called name, or nil when the
+// realm has none.
+func FindOrganization(ctx context.Context, api API, token, realm, name string) (*Organization, error) {
+	orgs, err := GetOrganizations(ctx, api, token, realm, name, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orgs {
+		if o.Name == name {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateOrganization creates org in realm.
+func CreateOrganization(ctx context.Context, api API, token, realm string, org Organization) error {
+	return api.Do(ctx, token, http.MethodPost, AdminURL(realm, "organizations"), nil, org, nil)
+}
+
+// UpdateOrganization replaces the organization with the ID of org.
+func UpdateOrganization(ctx context.Context, api API, token, realm string, org Organization) error {
+	return api.Do(ctx, token, http.MethodPut, AdminURL(realm, "organizations", org.ID), nil, org, nil)
+}
+
+// DeleteOrganization deletes an organization; its members stay in the realm.
+func DeleteOrganization(ctx context.Context, api API, token, realm, orgID string) error {
+	return api.Do(ctx, token, http.MethodDelete, AdminURL(realm, "organizations", orgID), nil, nil, nil)
+}
+
+// GetOrganizationMembers lists the users that belong to an organization.
+func GetOrganizationMembers(ctx context.Context, api API, token, realm, orgID string) ([]*gocloak.User, error) {
+	var out []*gocloak.User
+	q := url.Values{"max": {"-1"}}
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "organizations", orgID, "members"), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddOrganizationMember makes an existing realm user a member.
+func AddOrganizationMember(ctx context.Context, api API, token, realm, orgID, userID string) error {
+	return api.Do(ctx, token, http.MethodPost, AdminURL(realm, "organizations", orgID, "members"), nil, jsonString(userID), nil)
+}
+
+// RemoveOrganizationMember removes a member from an organization. Managed
+// members, created through the organization's identity provider, are deleted.
+func RemoveOrganizationMember(ctx context.Context, api API, token, realm, orgID, userID string) error {
+	return api.Do(ctx, token, http.MethodDelete, AdminURL(realm, "organizations", orgID, "members", userID), nil, nil, nil)
+}
+
+// LinkOrganizationIDP links the identity provider with alias to an
+// organization, so its users can be redirected there by email domain.
+func LinkOrganizationIDP(ctx context.Context, api API, token, realm, orgID, alias string) error {
+	return api.Do(ctx, token, http.MethodPost, AdminURL(realm, "organizations", orgID, "identity-providers"), nil, jsonString(alias), nil)
+}
+
+// jsonString is s as a JSON document, for endpoints whose body is a bare
+// string such as a user ID.
+func jsonString(s string) []byte {
+	b, _ := json.Marshal(s)
+	return b
+}
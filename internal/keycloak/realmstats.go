@@ -0,0 +1,50 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+)
+
+// ClientSessionStat is one client of the client-session-stats endpoint: the
+// number of regular and offline sessions that used it.
+type ClientSessionStat struct {
+	ID       string `json:"id"`
+	ClientID string `json:"clientId"`
+	Active   int    `json:"active,string"`
+	Offline  int    `json:"offline,string"`
+}
+
+// IdentityProvider is the part of an identity provider instance kc reads.
+type IdentityProvider struct {
+	Alias       string `json:"alias"`
+	DisplayName string `json:"displayName,omitempty"`
+	ProviderID  string `json:"providerId"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// CountUsers returns the number of users of realm.
+func CountUsers(ctx context.Context, api API, token, realm string) (int, error) {
+	var n int
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "users", "count"), nil, nil, &n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// GetClientSessionStats lists the clients of realm that have sessions.
+func GetClientSessionStats(ctx context.Context, api API, token, realm string) ([]*ClientSessionStat, error) {
+	var out []*ClientSessionStat
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "client-session-stats"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetIdentityProviders lists the identity providers of realm.
+func GetIdentityProviders(ctx context.Context, api API, token, realm string) ([]*IdentityProvider, error) {
+	var out []*IdentityProvider
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "identity-provider", "instances"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,71 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// InitialAccess is an initial access token for dynamic client registration.
+// Token is only returned when the token is created.
+type InitialAccess struct {
+	ID string `json:"id"`
+	// Token is the bearer token clients register with.
+	Token string `json:"token,omitempty"`
+	// Timestamp is the creation time in seconds since the epoch.
+	Timestamp int64 `json:"timestamp"`
+	// Expiration is the lifetime in seconds; 0 never expires.
+	Expiration     int `json:"expiration"`
+	Count          int `json:"count"`
+	RemainingCount int `json:"remainingCount"`
+}
+
+// ExpiresAt returns when the token expires, or the zero time when it does not.
+func (a *InitialAccess) ExpiresAt() time.Time {
+	if a.Expiration == 0 {
+		return time.Time{}
+	}
+	return time.Unix(a.Timestamp+int64(a.Expiration), 0)
+}
+
+// initialAccessRequest is the body of a new initial access token.
+type initialAccessRequest struct {
+	Expiration int `json:"expiration"`
+	Count      int `json:"count"`
+}
+
+// CreateInitialAccess creates an initial access token valid for count client
+// registrations during expiration (0 = no expiry).
+func CreateInitialAccess(ctx context.Context, api API, token, realm string, expiration time.Duration, count int) (*InitialAccess, error) {
+	var out InitialAccess
+	body := initialAccessRequest{Expiration: int(expiration / time.Second), Count: count}
+	if err := api.Do(ctx, token, http.MethodPost, AdminURL(realm, "clients-initial-access"), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetInitialAccess lists the initial access tokens of a realm.
+func GetInitialAccess(ctx context.Context, api API, token, realm string) ([]*InitialAccess, error) {
+	var out []*InitialAccess
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "clients-initial-access"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteInitialAccess revokes an initial access token.
+func DeleteInitialAccess(ctx context.Context, api API, token, realm, id string) error {
+	return api.Do(ctx, token, http.MethodDelete, AdminURL(realm, "clients-initial-access", id), nil, nil, nil)
+}
+
+// RegistrationPolicyType is the component provider type of client
+// registration policies. Their subType is anonymous or authenticated, the
+// kind of registration request they apply to.
+const RegistrationPolicyType = "org.keycloak.services.clientregistration.policy.ClientRegistrationPolicy"
+
+// Registration policy sub types.
+const (
+	RegistrationAnonymous     = "anonymous"
+	RegistrationAuthenticated = "authenticated"
+)
@@ -0,0 +1,43 @@
+package keycloak
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/Nerzal/gocloak/v13"
+	"kc/internal/config"
+)
+
+// AdminURL builds an admin REST API URL for realm, mirroring the layout used by gocloak.
+func AdminURL(realm string, path ...string) string {
+	parts := append([]string{strings.TrimRight(config.Global.ServerURL, "/"), "admin", "realms", realm}, path...)
+	return strings.Join(parts, "/")
+}
+
+// Do performs a raw admin REST request for endpoints gocloak does not wrap.
+// body and result are optional; result receives the decoded JSON response.
+func (s *Server) Do(ctx context.Context, token, method, rawURL string, query url.Values, body, result interface{}) error {
+	req := s.GetRequestWithBearerAuth(ctx, token)
+	if len(query) > 0 {
+		req.SetQueryParamsFromValues(query)
+	}
+	if body != nil {
+		req.SetBody(body)
+	}
+	if result != nil {
+		req.SetResult(result)
+	}
+	resp, err := req.Execute(method, rawURL)
+	if err != nil {
+		return &gocloak.APIError{Message: err.Error()}
+	}
+	if resp.IsError() {
+		msg := resp.Status()
+		if b := strings.TrimSpace(resp.String()); b != "" {
+			msg += ": " + b
+		}
+		return &gocloak.APIError{Code: resp.StatusCode(), Message: msg}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// GetRealmRoleComposites lists the realm roles the composite realm role
+// roleName directly includes. Client roles it includes are not returned.
+func GetRealmRoleComposites(ctx context.Context, api API, token, realm, roleName string) ([]*gocloak.Role, error) {
+	var out []*gocloak.Role
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "roles", url.PathEscape(roleName), "composites", "realm"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRealmRoleUsers lists a page of the users the realm role roleName is
+// mapped to directly.
+func GetRealmRoleUsers(ctx context.Context, api API, token, realm, roleName string, first, max int) ([]*gocloak.User, error) {
+	var out []*gocloak.User
+	query := url.Values{"first": {strconv.Itoa(first)}, "max": {strconv.Itoa(max)}}
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "roles", url.PathEscape(roleName), "users"), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetClientScopeMappers lists the protocol mappers of a client scope with
+// their whole config, which gocloak.ClientScope only keeps in part.
+func GetClientScopeMappers(ctx context.Context, api API, token, realm, scopeID string) ([]gocloak.ProtocolMapperRepresentation, error) {
+	var out []gocloak.ProtocolMapperRepresentation
+	if err := api.Do(ctx, token, http.MethodGet, AdminURL(realm, "client-scopes", scopeID, "protocol-mappers", "models"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
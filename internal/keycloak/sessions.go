@@ -0,0 +1,33 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+)
+
+// GlobalRequestResult lists the client admin URLs that were told, or failed
+// to be told, about a realm-wide logout or revocation.
+type GlobalRequestResult struct {
+	SuccessRequests []string `json:"successRequests,omitempty"`
+	FailedRequests  []string `json:"failedRequests,omitempty"`
+}
+
+// LogoutAll removes every user session of realm and notifies the clients
+// that have an admin URL.
+func LogoutAll(ctx context.Context, api API, token, realm string) (*GlobalRequestResult, error) {
+	var out GlobalRequestResult
+	if err := api.Do(ctx, token, http.MethodPost, AdminURL(realm, "logout-all"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PushRevocation sends the not-before policy of realm to the clients that
+// have an admin URL, so they reject tokens issued earlier.
+func PushRevocation(ctx context.Context, api API, token, realm string) (*GlobalRequestResult, error) {
+	var out GlobalRequestResult
+	if err := api.Do(ctx, token, http.MethodPost, AdminURL(realm, "push-revocation"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,36 @@
+package keycloak
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the admin API traffic of this process, as counted by the
+// requests of a Server. The Fake sends no requests, so its stats stay zero.
+type Stats struct {
+	// Calls counts the HTTP requests sent, retries and the login included.
+	Calls int64
+	// APITime sums the time from sending each request to reading its response.
+	APITime time.Duration
+	// Retries counts the requests repeated after a 429 or 503 response.
+	Retries int64
+	// RateLimitWaits counts the requests delayed by the rate limit, for
+	// RateLimitTime in total.
+	RateLimitWaits int64
+	RateLimitTime  time.Duration
+}
+
+var stats struct {
+	calls, apiTime, retries, rateLimitWaits, rateLimitTime atomic.Int64
+}
+
+// CurrentStats returns the admin API traffic of this process so far.
+func CurrentStats() Stats {
+	return Stats{
+		Calls:          stats.calls.Load(),
+		APITime:        time.Duration(stats.apiTime.Load()),
+		Retries:        stats.retries.Load(),
+		RateLimitWaits: stats.rateLimitWaits.Load(),
+		RateLimitTime:  time.Duration(stats.rateLimitTime.Load()),
+	}
+}
@@ -0,0 +1,128 @@
+package keycloak
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// maxRetries bounds how often a throttled request (429/503) is retried.
+	maxRetries = 3
+	// maxRetryWait caps the delay taken from a Retry-After header.
+	maxRetryWait = 60 * time.Second
+)
+
+var (
+	limiterMu sync.RWMutex
+	limiter   *rate.Limiter
+)
+
+// SetRateLimit limits all admin API requests of this process to rps requests
+// per second with the given burst. rps <= 0 removes the limit.
+func SetRateLimit(rps float64, burst int) {
+	limiterMu.Lock()
+	defer limiterMu.Unlock()
+	if rps <= 0 {
+		limiter = nil
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	limiter = rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+func currentLimiter() *rate.Limiter {
+	limiterMu.RLock()
+	defer limiterMu.RUnlock()
+	return limiter
+}
+
+// installThrottling makes every request of gc wait for the shared limiter and
+// retries responses asking the client to slow down, honoring Retry-After
+// (see retryable).
+func installThrottling(gc *gocloak.GoCloak) {
+	rc := gc.RestyClient()
+	// resty logs every failed attempt once retries are enabled; errors are
+	// already reported to the user by the commands themselves
+	rc.SetLogger(quietLogger{})
+	rc.OnBeforeRequest(func(_ *resty.Client, r *resty.Request) error {
+		if l := currentLimiter(); l != nil {
+			start := time.Now()
+			if err := l.Wait(r.Context()); err != nil {
+				return err
+			}
+			// an available token returns at once; only count real delays
+			if waited := time.Since(start); waited > time.Millisecond {
+				stats.rateLimitWaits.Add(1)
+				stats.rateLimitTime.Add(int64(waited))
+			}
+		}
+		stats.calls.Add(1)
+		if r.Attempt > 1 {
+			stats.retries.Add(1)
+		}
+		return nil
+	})
+	rc.OnAfterResponse(func(_ *resty.Client, r *resty.Response) error {
+		stats.apiTime.Add(int64(r.Time()))
+		return nil
+	})
+	rc.SetRetryCount(maxRetries).
+		SetRetryWaitTime(time.Second).
+		SetRetryMaxWaitTime(maxRetryWait).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			return r != nil && retryable(r.Request.Method, r.StatusCode())
+		}).
+		SetRetryAfter(func(_ *resty.Client, r *resty.Response) (time.Duration, error) {
+			// 0 falls back to resty's exponential backoff
+			if r == nil {
+				return 0, nil
+			}
+			return retryAfter(r.Header().Get("Retry-After"), time.Now()), nil
+		})
+}
+
+// retryable reports whether a request answered with status is sent again. A
+// 429 means the server refused it, so any request is. A 503 may come after
+// the server acted on it, e.g. from a proxy timing out, so only requests
+// that are safe to repeat (GET, HEAD and PUT) are.
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return method == http.MethodGet || method == http.MethodHead || method == http.MethodPut
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header given either in seconds or as an HTTP
+// date. It returns 0 when the header is missing or invalid.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	}
+	if d <= 0 {
+		return 0
+	}
+	return min(d, maxRetryWait)
+}
+
+type quietLogger struct{}
+
+func (quietLogger) Errorf(string, ...interface{}) {}
+func (quietLogger) Warnf(string, ...interface{})  {}
+func (quietLogger) Debugf(string, ...interface{}) {}
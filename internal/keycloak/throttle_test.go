@@ -0,0 +1,128 @@
+package keycloak
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "3", 3 * time.Second},
+		{"http date", now.Add(5 * time.Second).Format(http.TimeFormat), 5 * time.Second},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"negative", "-1", 0},
+		{"capped", "3600", maxRetryWait},
+		{"invalid", "soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.value, now); got != tt.want {
+				t.Errorf("retryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// throttledServer answers the first fails requests with status and a
+// Retry-After of one second, then 200 with an empty realm (or 201 to a POST).
+func throttledServer(t *testing.T, status, fails int) (*gocloak.GoCloak, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= fails {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(status)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	gc := gocloak.NewClient(srv.URL)
+	installThrottling(gc)
+	return gc, &calls
+}
+
+func TestThrottlingRetries(t *testing.T) {
+	get := func(gc *gocloak.GoCloak) error {
+		_, err := gc.GetRealm(context.Background(), "token", "test")
+		return err
+	}
+	put := func(gc *gocloak.GoCloak) error {
+		return gc.UpdateRealm(context.Background(), "token", gocloak.RealmRepresentation{Realm: gocloak.StringP("test")})
+	}
+	post := func(gc *gocloak.GoCloak) error {
+		_, err := gc.CreateRealm(context.Background(), "token", gocloak.RealmRepresentation{Realm: gocloak.StringP("test")})
+		return err
+	}
+	tests := []struct {
+		name      string
+		call      func(gc *gocloak.GoCloak) error
+		status    int
+		fails     int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "429 is retried after Retry-After", call: get, status: http.StatusTooManyRequests, fails: 1, wantCalls: 2},
+		{name: "429 of a POST is retried", call: post, status: http.StatusTooManyRequests, fails: 1, wantCalls: 2},
+		{name: "503 of a GET is retried", call: get, status: http.StatusServiceUnavailable, fails: 1, wantCalls: 2},
+		{name: "503 of a PUT is retried", call: put, status: http.StatusServiceUnavailable, fails: 1, wantCalls: 2},
+		{name: "503 of a POST is not retried", call: post, status: http.StatusServiceUnavailable, fails: 1, wantCalls: 1, wantErr: true},
+		{name: "other errors are not retried", call: get, status: http.StatusInternalServerError, fails: 1, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc, calls := throttledServer(t, tt.status, tt.fails)
+			start := time.Now()
+			if err := tt.call(gc); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("%d request(s), want %d", got, tt.wantCalls)
+			}
+			if tt.wantCalls > 1 && time.Since(start) < time.Second {
+				t.Errorf("retried after %s, before Retry-After", time.Since(start))
+			}
+		})
+	}
+}
+
+func TestSetRateLimit(t *testing.T) {
+	t.Cleanup(func() { SetRateLimit(0, 0) })
+	gc, calls := throttledServer(t, http.StatusOK, 0)
+	// 20 per second with no burst: the 2 requests after the first wait 50ms each
+	SetRateLimit(20, 1)
+	start := time.Now()
+	for range 3 {
+		if _, err := gc.GetRealm(context.Background(), "token", "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %s, want the limit to space them", elapsed)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d request(s), want 3", calls.Load())
+	}
+
+	SetRateLimit(0, 0)
+	if currentLimiter() != nil {
+		t.Error("SetRateLimit(0) kept a limiter")
+	}
+}
@@ -0,0 +1,202 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+	"kc/internal/config"
+)
+
+// ErrOffline is returned by the OpenID Connect helpers in offline mode, which
+// only emulates the admin API.
+var ErrOffline = errors.New("not available in offline mode (KC_FAKE=1)")
+
+// OIDCURL builds an OpenID Connect endpoint URL of realm, e.g. token/introspect.
+func OIDCURL(realm string, path ...string) string {
+	parts := append([]string{strings.TrimRight(config.Global.ServerURL, "/"), "realms", realm, "protocol", "openid-connect"}, path...)
+	return strings.Join(parts, "/")
+}
+
+// JWT is a decoded, unverified JSON Web Token.
+type JWT struct {
+	Header map[string]interface{} `json:"header"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+// DecodeJWT decodes the header and claims of a compact JWT without checking
+// its signature.
+func DecodeJWT(raw string) (*JWT, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a JWT: expected 3 dot-separated parts, got %d", len(parts))
+	}
+	var t JWT
+	if err := decodeSegment(parts[0], &t.Header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	if err := decodeSegment(parts[1], &t.Claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %w", err)
+	}
+	return &t, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Time returns a NumericDate claim such as exp or iat.
+func (t *JWT) Time(claim string) (time.Time, bool) {
+	v, ok := t.Claims[claim].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// Introspect asks the introspection endpoint of realm about token,
+// authenticating as the confidential client clientID. The result holds
+// "active" and, for active tokens, their claims.
+func Introspect(ctx context.Context, realm, clientID, clientSecret, token string) (map[string]interface{}, error) {
+	if FakeMode() {
+		return nil, fmt.Errorf("token introspection is %w", ErrOffline)
+	}
+	gc := gocloak.NewClient(config.Global.ServerURL)
+	installThrottling(gc)
+	installTracing(gc)
+	var out map[string]interface{}
+	resp, err := gc.RestyClient().R().
+		SetContext(ctx).
+		SetFormData(map[string]string{"token": token, "client_id": clientID, "client_secret": clientSecret}).
+		SetResult(&out).
+		Post(OIDCURL(realm, "token", "introspect"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		msg := resp.Status()
+		if b := strings.TrimSpace(resp.String()); b != "" {
+			msg += ": " + b
+		}
+		return nil, &gocloak.APIError{Code: resp.StatusCode(), Message: msg}
+	}
+	return out, nil
+}
+
+// Discovery fetches the OpenID Connect discovery document of realm
+// (.well-known/openid-configuration), which holds the issuer and the
+// endpoints applications are configured with.
+func Discovery(ctx context.Context, realm string) (map[string]interface{}, error) {
+	if FakeMode() {
+		return nil, fmt.Errorf("OpenID Connect discovery is %w", ErrOffline)
+	}
+	gc := gocloak.NewClient(config.Global.ServerURL)
+	installThrottling(gc)
+	installTracing(gc)
+	var out map[string]interface{}
+	resp, err := gc.RestyClient().R().
+		SetContext(ctx).
+		SetResult(&out).
+		Get(strings.TrimRight(config.Global.ServerURL, "/") + "/realms/" + realm + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, &gocloak.APIError{Code: resp.StatusCode(), Message: resp.Status()}
+	}
+	return out, nil
+}
+
+// TokenRequest is a token request to the token endpoint of a realm.
+type TokenRequest struct {
+	Realm        string
+	GrantType    string // password or client_credentials
+	ClientID     string
+	ClientSecret string // empty for public clients
+	Username     string
+	Password     string
+	Scope        string // space-separated, added to openid and the default scopes
+}
+
+// GetToken obtains tokens as a user or client of a realm. Unlike Login it
+// does not target the admin API; the tokens are for testing applications.
+func GetToken(ctx context.Context, req TokenRequest) (*gocloak.JWT, error) {
+	if FakeMode() {
+		return nil, fmt.Errorf("issuing tokens is %w", ErrOffline)
+	}
+	gc := gocloak.NewClient(config.Global.ServerURL)
+	installThrottling(gc)
+	installTracing(gc)
+	opts := gocloak.TokenOptions{
+		GrantType: gocloak.StringP(req.GrantType),
+		ClientID:  gocloak.StringP(req.ClientID),
+	}
+	if req.ClientSecret != "" {
+		opts.ClientSecret = gocloak.StringP(req.ClientSecret)
+	}
+	if req.GrantType == "password" {
+		opts.Username = gocloak.StringP(req.Username)
+		opts.Password = gocloak.StringP(req.Password)
+	}
+	// openid makes Keycloak return an ID token next to the access token
+	scope := "openid"
+	for _, s := range strings.Fields(req.Scope) {
+		if s != "openid" {
+			scope += " " + s
+		}
+	}
+	opts.Scope = gocloak.StringP(scope)
+	return gc.GetToken(ctx, req.Realm, opts)
+}
+
+// ClientRoles returns the roles of client in the resource_access claim.
+// Keycloak expands composite roles into the token, so a realm-admin also
+// carries every manage-* role.
+func (t *JWT) ClientRoles(client string) []string {
+	access, _ := t.Claims["resource_access"].(map[string]interface{})
+	entry, _ := access[client].(map[string]interface{})
+	list, _ := entry["roles"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, r := range list {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// RealmRoles returns the roles of the realm_access claim.
+func (t *JWT) RealmRoles() []string {
+	access, _ := t.Claims["realm_access"].(map[string]interface{})
+	list, _ := access["roles"].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, r := range list {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ManagementClient returns the client whose roles grant admin access to
+// realm for a principal of authRealm: realm-management inside the realm
+// itself, <realm>-realm in master. Principals of other realms cannot manage
+// realm at all.
+func ManagementClient(authRealm, realm string) (string, bool) {
+	switch {
+	case authRealm == realm:
+		return "realm-management", true
+	case authRealm == "master":
+		return realm + "-realm", true
+	}
+	return "", false
+}
@@ -0,0 +1,38 @@
+package keycloak
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion returns the version reported by the server, e.g. "26.0.5".
+func ServerVersion(ctx context.Context, api API, token string) (string, error) {
+	info, err := api.GetServerInfo(ctx, token)
+	if err != nil {
+		return "", fmt.Errorf("failed reading server info: %w", err)
+	}
+	if info == nil || info.SystemInfo == nil || info.SystemInfo.Version == nil {
+		return "", fmt.Errorf("server info has no version")
+	}
+	return *info.SystemInfo.Version, nil
+}
+
+// RequireVersion fails unless the server runs at least Keycloak major, naming
+// feature in the error.
+func RequireVersion(ctx context.Context, api API, token string, major int, feature string) error {
+	v, err := ServerVersion(ctx, api, token)
+	if err != nil {
+		return err
+	}
+	head, _, _ := strings.Cut(v, ".")
+	got, err := strconv.Atoi(head)
+	if err != nil {
+		return fmt.Errorf("unrecognized server version %q", v)
+	}
+	if got < major {
+		return fmt.Errorf("%s require Keycloak %d or later; the server runs %s", feature, major, v)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package lint defines the consistency rules checked by `kc lint`: the naming
+// conventions of roles, clients, client scopes and groups, and the entities
+// every checked realm must contain.
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+
+	"kc/internal/manifest"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// File is the content of a lint rules file.
+type File struct {
+	Naming  Naming  `yaml:"naming"`
+	Require Require `yaml:"require"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// Naming holds the regular expression the names of each kind must match.
+// An empty pattern leaves the kind unchecked. Entities Keycloak creates on its
+// own (offline_access, account, profile...) are never checked.
+type Naming struct {
+	Roles        string `yaml:"roles"`
+	Clients      string `yaml:"clients"`
+	ClientScopes string `yaml:"client_scopes"`
+	// Groups is matched against the name of each group, not its path.
+	Groups string `yaml:"groups"`
+}
+
+// Require lists the entities every realm must contain.
+type Require struct {
+	Roles        []string `yaml:"roles"`
+	ClientScopes []string `yaml:"client_scopes"`
+	// Groups are full paths, e.g. /tenant/admins.
+	Groups  []string        `yaml:"groups"`
+	Clients []RequireClient `yaml:"clients"`
+}
+
+// RequireClient is a client every realm must contain.
+type RequireClient struct {
+	ClientID string `yaml:"client_id"`
+	// Enabled, when set, is the state the client must be in.
+	Enabled *bool `yaml:"enabled"`
+	// Scopes must be assigned to the client, as default or optional scopes.
+	Scopes []string `yaml:"scopes"`
+	// DefaultScopes must be assigned to the client as default scopes.
+	DefaultScopes []string `yaml:"default_scopes"`
+}
+
+// Violation is a rule a realm breaks.
+type Violation struct {
+	// Rule is the key of the rule in the file, e.g. naming.roles.
+	Rule string
+	// Target is the entity at fault, e.g. role "Admin".
+	Target  string
+	Message string
+}
+
+// Load reads and validates a rules file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+func (f *File) validate() error {
+	f.patterns = map[string]*regexp.Regexp{}
+	for rule, expr := range map[string]string{
+		"naming.roles":         f.Naming.Roles,
+		"naming.clients":       f.Naming.Clients,
+		"naming.client_scopes": f.Naming.ClientScopes,
+		"naming.groups":        f.Naming.Groups,
+	} {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", rule, expr, err)
+		}
+		f.patterns[rule] = re
+	}
+	for _, g := range f.Require.Groups {
+		if len(g) < 2 || g[0] != '/' {
+			return fmt.Errorf("require.groups: path %q must start with '/'", g)
+		}
+	}
+	seen := map[string]bool{}
+	for i, c := range f.Require.Clients {
+		if c.ClientID == "" {
+			return fmt.Errorf("require.clients %d: missing client_id", i+1)
+		}
+		if seen[c.ClientID] {
+			return fmt.Errorf("require.clients: client %q listed twice", c.ClientID)
+		}
+		seen[c.ClientID] = true
+	}
+	if len(f.patterns) == 0 && len(f.Require.Roles) == 0 && len(f.Require.ClientScopes) == 0 && len(f.Require.Groups) == 0 && len(f.Require.Clients) == 0 {
+		return errors.New("no rules defined")
+	}
+	return nil
+}
+
+// Check returns the rules realm r breaks. r must hold the roles, client
+// scopes, clients and groups of the realm.
+func (f *File) Check(r *manifest.Realm) []Violation {
+	var out []Violation
+	add := func(rule, target, format string, args ...any) {
+		out = append(out, Violation{Rule: rule, Target: target, Message: fmt.Sprintf(format, args...)})
+	}
+	naming := func(rule, kind, name string) {
+		if re := f.patterns[rule]; re != nil && !re.MatchString(name) {
+			add(rule, fmt.Sprintf("%s %q", kind, name), "name does not match %s", re)
+		}
+	}
+
+	for _, x := range r.Roles {
+		if !manifest.IsBuiltinRole(r.Name, x.Name) {
+			naming("naming.roles", "role", x.Name)
+		}
+	}
+	for _, x := range r.Clients {
+		if !manifest.IsBuiltinClient(r.Name, x.ClientID) {
+			naming("naming.clients", "client", x.ClientID)
+		}
+	}
+	for _, x := range r.ClientScopes {
+		if !manifest.IsBuiltinClientScope(x.Name) {
+			naming("naming.client_scopes", "client scope", x.Name)
+		}
+	}
+	for _, x := range r.Groups {
+		naming("naming.groups", "group", path.Base(x.Path))
+	}
+
+	for _, name := range f.Require.Roles {
+		if !slices.ContainsFunc(r.Roles, func(x manifest.Role) bool { return x.Name == name }) {
+			add("require.roles", fmt.Sprintf("role %q", name), "missing")
+		}
+	}
+	for _, name := range f.Require.ClientScopes {
+		if !slices.ContainsFunc(r.ClientScopes, func(x manifest.ClientScope) bool { return x.Name == name }) {
+			add("require.client_scopes", fmt.Sprintf("client scope %q", name), "missing")
+		}
+	}
+	for _, p := range f.Require.Groups {
+		if !slices.ContainsFunc(r.Groups, func(x manifest.Group) bool { return x.Path == p }) {
+			add("require.groups", fmt.Sprintf("group %q", p), "missing")
+		}
+	}
+	for _, want := range f.Require.Clients {
+		target := fmt.Sprintf("client %q", want.ClientID)
+		i := slices.IndexFunc(r.Clients, func(x manifest.Client) bool { return x.ClientID == want.ClientID })
+		if i < 0 {
+			add("require.clients", target, "missing")
+			continue
+		}
+		c := r.Clients[i]
+		enabled := c.Enabled == nil || *c.Enabled
+		if want.Enabled != nil && *want.Enabled != enabled {
+			add("require.clients", target, "enabled is %t, expected %t", enabled, *want.Enabled)
+		}
+		for _, s := range want.Scopes {
+			if !slices.Contains(c.DefaultClientScopes, s) && !slices.Contains(c.OptionalClientScopes, s) {
+				add("require.clients", target, "client scope %q is not assigned", s)
+			}
+		}
+		for _, s := range want.DefaultScopes {
+			switch {
+			case slices.Contains(c.DefaultClientScopes, s):
+			case slices.Contains(c.OptionalClientScopes, s):
+				add("require.clients", target, "client scope %q is optional, expected default", s)
+			default:
+				add("require.clients", target, "client scope %q is not assigned", s)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,391 @@
+package manifest
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Op is the kind of change an Action performs.
+type Op string
+
+const (
+	OpCreate Op = "create"
+	OpUpdate Op = "update"
+	OpDelete Op = "delete"
+)
+
+// Kind is the entity type an Action targets.
+type Kind string
+
+const (
+	KindRealm       Kind = "realm"
+	KindRole        Kind = "role"
+	KindClientScope Kind = "clientScope"
+	KindClient      Kind = "client"
+	KindGroup       Kind = "group"
+	KindUser        Kind = "user"
+)
+
+// FieldDiff is a single field that differs between actual and desired state.
+type FieldDiff struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new"`
+}
+
+// Action is one step of a plan converging a realm to its desired state.
+type Action struct {
+	Op     Op          `json:"op"`
+	Kind   Kind        `json:"kind"`
+	Realm  string      `json:"realm"`
+	Name   string      `json:"name"`
+	Fields []FieldDiff `json:"fields,omitempty"`
+}
+
+func (a Action) String() string {
+	sym := map[Op]string{OpCreate: "+", OpUpdate: "~", OpDelete: "-"}[a.Op]
+	s := fmt.Sprintf("%s %s %q in realm %q", sym, a.Kind, a.Name, a.Realm)
+	if len(a.Fields) > 0 {
+		parts := make([]string, 0, len(a.Fields))
+		for _, f := range a.Fields {
+			parts = append(parts, fmt.Sprintf("%s %s→%s", f.Field, formatValue(f.Old), formatValue(f.New)))
+		}
+		s += ": " + strings.Join(parts, ", ")
+	}
+	return s
+}
+
+// Values returns the old and new values formatted for display.
+func (f FieldDiff) Values() (string, string) {
+	return formatValue(f.Old), formatValue(f.New)
+}
+
+// Field returns the diff for the named field, if the action changes it.
+func (a Action) Field(name string) (FieldDiff, bool) {
+	for _, f := range a.Fields {
+		if f.Field == name {
+			return f, true
+		}
+	}
+	return FieldDiff{}, false
+}
+
+func formatValue(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return "[" + strings.Join(t, ",") + "]"
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// Options tune how Diff treats entities that exist only on the server.
+type Options struct {
+	// Prune deletes managed entities that are not declared, and removes
+	// undeclared members from role/group/scope lists.
+	Prune bool
+}
+
+// Diff returns the ordered actions that converge actual to desired. actual is
+// nil when the realm does not exist yet.
+func Diff(desired, actual *Realm, opts Options) []Action {
+	realm := desired.Name
+	var creates, deletes []Action
+	if actual == nil {
+		a := Action{Op: OpCreate, Kind: KindRealm, Realm: realm, Name: realm}
+		a.Fields = diffBool(a.Fields, "enabled", nil, desired.Enabled)
+		a.Fields = diffString(a.Fields, "displayName", "", desired.DisplayName)
+		creates = append(creates, a)
+		actual = &Realm{Name: realm}
+	} else {
+		var f []FieldDiff
+		f = diffBool(f, "enabled", actual.Enabled, desired.Enabled)
+		f = diffString(f, "displayName", actual.DisplayName, desired.DisplayName)
+		if len(f) > 0 {
+			creates = append(creates, Action{Op: OpUpdate, Kind: KindRealm, Realm: realm, Name: realm, Fields: f})
+		}
+	}
+
+	// client scopes before clients, roles before groups and users
+	c, d := diffClientScopes(realm, desired.ClientScopes, actual.ClientScopes, opts)
+	creates, deletes = append(creates, c...), append(d, deletes...)
+	c, d = diffRoles(realm, desired.Roles, actual.Roles, opts)
+	creates, deletes = append(creates, c...), append(d, deletes...)
+	c, d = diffClients(realm, desired.Clients, actual.Clients, opts)
+	creates, deletes = append(creates, c...), append(d, deletes...)
+	c, d = diffGroups(realm, desired.Groups, actual.Groups, opts)
+	creates, deletes = append(creates, c...), append(d, deletes...)
+	c, d = diffUsers(realm, desired.Users, actual.Users, opts)
+	creates, deletes = append(creates, c...), append(d, deletes...)
+
+	return append(creates, deletes...)
+}
+
+func diffRoles(realm string, want, have []Role, opts Options) (changes, deletes []Action) {
+	if want == nil {
+		return nil, nil
+	}
+	current := index(have, func(r Role) string { return r.Name })
+	for _, r := range want {
+		cur, ok := current[r.Name]
+		if !ok {
+			a := Action{Op: OpCreate, Kind: KindRole, Realm: realm, Name: r.Name}
+			a.Fields = diffString(a.Fields, "description", "", r.Description)
+			changes = append(changes, a)
+			continue
+		}
+		if f := diffString(nil, "description", cur.Description, r.Description); len(f) > 0 {
+			changes = append(changes, Action{Op: OpUpdate, Kind: KindRole, Realm: realm, Name: r.Name, Fields: f})
+		}
+	}
+	if opts.Prune {
+		declared := index(want, func(r Role) string { return r.Name })
+		for _, r := range have {
+			if _, ok := declared[r.Name]; !ok && !IsBuiltinRole(realm, r.Name) {
+				deletes = append(deletes, Action{Op: OpDelete, Kind: KindRole, Realm: realm, Name: r.Name})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func diffClientScopes(realm string, want, have []ClientScope, opts Options) (changes, deletes []Action) {
+	if want == nil {
+		return nil, nil
+	}
+	current := index(have, func(s ClientScope) string { return s.Name })
+	for _, s := range want {
+		cur, ok := current[s.Name]
+		if !ok {
+			a := Action{Op: OpCreate, Kind: KindClientScope, Realm: realm, Name: s.Name}
+			a.Fields = diffString(a.Fields, "description", "", s.Description)
+			a.Fields = diffString(a.Fields, "protocol", "", s.Protocol)
+			changes = append(changes, a)
+			continue
+		}
+		var f []FieldDiff
+		f = diffString(f, "description", cur.Description, s.Description)
+		f = diffString(f, "protocol", cur.Protocol, s.Protocol)
+		if len(f) > 0 {
+			changes = append(changes, Action{Op: OpUpdate, Kind: KindClientScope, Realm: realm, Name: s.Name, Fields: f})
+		}
+	}
+	if opts.Prune {
+		declared := index(want, func(s ClientScope) string { return s.Name })
+		for _, s := range have {
+			if _, ok := declared[s.Name]; !ok && !IsBuiltinClientScope(s.Name) {
+				deletes = append(deletes, Action{Op: OpDelete, Kind: KindClientScope, Realm: realm, Name: s.Name})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func diffClients(realm string, want, have []Client, opts Options) (changes, deletes []Action) {
+	if want == nil {
+		return nil, nil
+	}
+	current := index(have, func(c Client) string { return c.ClientID })
+	for _, c := range want {
+		cur, ok := current[c.ClientID]
+		op := OpUpdate
+		if !ok {
+			op = OpCreate
+			cur = Client{}
+		}
+		var f []FieldDiff
+		f = diffString(f, "name", cur.Name, c.Name)
+		f = diffBool(f, "enabled", cur.Enabled, c.Enabled)
+		f = diffBool(f, "publicClient", cur.PublicClient, c.PublicClient)
+		f = diffString(f, "protocol", cur.Protocol, c.Protocol)
+		f = diffString(f, "rootUrl", cur.RootURL, c.RootURL)
+		f = diffString(f, "baseUrl", cur.BaseURL, c.BaseURL)
+		f = diffSet(f, "redirectUris", cur.RedirectURIs, c.RedirectURIs, true)
+		f = diffSet(f, "webOrigins", cur.WebOrigins, c.WebOrigins, true)
+		f = diffBool(f, "standardFlowEnabled", cur.StandardFlowEnabled, c.StandardFlowEnabled)
+		f = diffBool(f, "directAccessGrantsEnabled", cur.DirectAccessGrantsEnabled, c.DirectAccessGrantsEnabled)
+		f = diffBool(f, "implicitFlowEnabled", cur.ImplicitFlowEnabled, c.ImplicitFlowEnabled)
+		f = diffBool(f, "serviceAccountsEnabled", cur.ServiceAccountsEnabled, c.ServiceAccountsEnabled)
+		f = diffSet(f, "defaultClientScopes", cur.DefaultClientScopes, c.DefaultClientScopes, opts.Prune)
+		f = diffSet(f, "optionalClientScopes", cur.OptionalClientScopes, c.OptionalClientScopes, opts.Prune)
+		if op == OpCreate || len(f) > 0 {
+			changes = append(changes, Action{Op: op, Kind: KindClient, Realm: realm, Name: c.ClientID, Fields: f})
+		}
+	}
+	if opts.Prune {
+		declared := index(want, func(c Client) string { return c.ClientID })
+		for _, c := range have {
+			if _, ok := declared[c.ClientID]; !ok && !IsBuiltinClient(realm, c.ClientID) {
+				deletes = append(deletes, Action{Op: OpDelete, Kind: KindClient, Realm: realm, Name: c.ClientID})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func diffGroups(realm string, want, have []Group, opts Options) (changes, deletes []Action) {
+	if want == nil {
+		return nil, nil
+	}
+	current := index(have, func(g Group) string { return g.Path })
+	sorted := append([]Group{}, want...)
+	// parents must exist before their children
+	sort.SliceStable(sorted, func(i, j int) bool { return depth(sorted[i].Path) < depth(sorted[j].Path) })
+	for _, g := range sorted {
+		cur, ok := current[g.Path]
+		if !ok {
+			a := Action{Op: OpCreate, Kind: KindGroup, Realm: realm, Name: g.Path}
+			a.Fields = diffSet(a.Fields, "realmRoles", nil, g.RealmRoles, true)
+			changes = append(changes, a)
+			continue
+		}
+		if f := diffSet(nil, "realmRoles", cur.RealmRoles, g.RealmRoles, opts.Prune); len(f) > 0 {
+			changes = append(changes, Action{Op: OpUpdate, Kind: KindGroup, Realm: realm, Name: g.Path, Fields: f})
+		}
+	}
+	if opts.Prune {
+		declared := index(want, func(g Group) string { return g.Path })
+		extra := append([]Group{}, have...)
+		sort.SliceStable(extra, func(i, j int) bool { return depth(extra[i].Path) > depth(extra[j].Path) })
+		for _, g := range extra {
+			if _, ok := declared[g.Path]; !ok && !hasDeclaredDescendant(g.Path, declared) {
+				deletes = append(deletes, Action{Op: OpDelete, Kind: KindGroup, Realm: realm, Name: g.Path})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func diffUsers(realm string, want, have []User, opts Options) (changes, deletes []Action) {
+	if want == nil {
+		return nil, nil
+	}
+	current := index(have, func(u User) string { return u.Username })
+	for _, u := range want {
+		cur, ok := current[u.Username]
+		op := OpUpdate
+		if !ok {
+			op = OpCreate
+			cur = User{}
+		}
+		var f []FieldDiff
+		f = diffString(f, "email", cur.Email, u.Email)
+		f = diffString(f, "firstName", cur.FirstName, u.FirstName)
+		f = diffString(f, "lastName", cur.LastName, u.LastName)
+		f = diffBool(f, "enabled", cur.Enabled, u.Enabled)
+		f = diffSet(f, "realmRoles", cur.RealmRoles, u.RealmRoles, opts.Prune)
+		f = diffSet(f, "groups", cur.Groups, u.Groups, opts.Prune)
+		if op == OpCreate || len(f) > 0 {
+			changes = append(changes, Action{Op: op, Kind: KindUser, Realm: realm, Name: u.Username, Fields: f})
+		}
+	}
+	if opts.Prune {
+		declared := index(want, func(u User) string { return u.Username })
+		for _, u := range have {
+			if _, ok := declared[u.Username]; !ok && !strings.HasPrefix(u.Username, "service-account-") {
+				deletes = append(deletes, Action{Op: OpDelete, Kind: KindUser, Realm: realm, Name: u.Username})
+			}
+		}
+	}
+	return changes, deletes
+}
+
+func diffString(f []FieldDiff, field, old, want string) []FieldDiff {
+	if want == "" || old == want {
+		return f
+	}
+	var o interface{}
+	if old != "" {
+		o = old
+	}
+	return append(f, FieldDiff{Field: field, Old: o, New: want})
+}
+
+func diffBool(f []FieldDiff, field string, old, want *bool) []FieldDiff {
+	if want == nil || (old != nil && *old == *want) {
+		return f
+	}
+	var o interface{}
+	if old != nil {
+		o = *old
+	}
+	return append(f, FieldDiff{Field: field, Old: o, New: *want})
+}
+
+// diffSet compares lists as sets. Without exact, members present only on the
+// server are kept in the target set.
+func diffSet(f []FieldDiff, field string, old, want []string, exact bool) []FieldDiff {
+	if want == nil {
+		return f
+	}
+	target := append([]string{}, want...)
+	if !exact {
+		for _, v := range old {
+			if !slices.Contains(target, v) {
+				target = append(target, v)
+			}
+		}
+	}
+	a := append([]string{}, old...)
+	sort.Strings(a)
+	sort.Strings(target)
+	target = slices.Compact(target)
+	if slices.Equal(a, target) {
+		return f
+	}
+	return append(f, FieldDiff{Field: field, Old: a, New: target})
+}
+
+func index[T any](items []T, key func(T) string) map[string]T {
+	m := make(map[string]T, len(items))
+	for _, it := range items {
+		m[key(it)] = it
+	}
+	return m
+}
+
+func depth(path string) int {
+	return strings.Count(strings.TrimSuffix(path, "/"), "/")
+}
+
+func hasDeclaredDescendant(path string, declared map[string]Group) bool {
+	for p := range declared {
+		if strings.HasPrefix(p, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBuiltinRole reports whether a realm role is created by Keycloak itself.
+func IsBuiltinRole(realm, name string) bool {
+	return name == "offline_access" || name == "uma_authorization" || name == "default-roles-"+realm || (realm == "master" && (name == "admin" || name == "create-realm"))
+}
+
+// IsBuiltinClient reports whether a client is created by Keycloak itself.
+func IsBuiltinClient(realm, clientID string) bool {
+	switch clientID {
+	case "account", "account-console", "admin-cli", "broker", "realm-management", "security-admin-console":
+		return true
+	}
+	return realm == "master" && strings.HasSuffix(clientID, "-realm")
+}
+
+// IsBuiltinClientScope reports whether a client scope ships with new realms.
+func IsBuiltinClientScope(name string) bool {
+	switch name {
+	case "acr", "address", "basic", "email", "microprofile-jwt", "offline_access", "organization", "phone", "profile", "role_list", "roles", "saml_organization", "web-origins":
+		return true
+	}
+	return false
+}
+
+// StringSlice converts a FieldDiff value produced by Diff back into a list.
+func StringSlice(v interface{}) []string {
+	s, _ := v.([]string)
+	return s
+}
@@ -0,0 +1,170 @@
+// Package manifest describes the desired state of Keycloak realms and computes
+// the actions needed to converge a live server towards it.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// State is the root of a desired-state file.
+type State struct {
+	Realms []Realm `yaml:"realms" json:"realms"`
+}
+
+// Realm declares a realm and the entities it must contain. A nil entity list
+// means the kind is not managed; an empty list means "none besides built-ins".
+type Realm struct {
+	Name         string        `yaml:"name" json:"name"`
+	Enabled      *bool         `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	DisplayName  string        `yaml:"displayName,omitempty" json:"displayName,omitempty"`
+	Roles        []Role        `yaml:"roles,omitempty" json:"roles,omitempty"`
+	ClientScopes []ClientScope `yaml:"clientScopes,omitempty" json:"clientScopes,omitempty"`
+	Clients      []Client      `yaml:"clients,omitempty" json:"clients,omitempty"`
+	Groups       []Group       `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Users        []User        `yaml:"users,omitempty" json:"users,omitempty"`
+}
+
+type Role struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+type ClientScope struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Protocol    string `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+}
+
+type Client struct {
+	ClientID                  string   `yaml:"clientId" json:"clientId"`
+	Name                      string   `yaml:"name,omitempty" json:"name,omitempty"`
+	Enabled                   *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	PublicClient              *bool    `yaml:"publicClient,omitempty" json:"publicClient,omitempty"`
+	Protocol                  string   `yaml:"protocol,omitempty" json:"protocol,omitempty"`
+	RootURL                   string   `yaml:"rootUrl,omitempty" json:"rootUrl,omitempty"`
+	BaseURL                   string   `yaml:"baseUrl,omitempty" json:"baseUrl,omitempty"`
+	RedirectURIs              []string `yaml:"redirectUris,omitempty" json:"redirectUris,omitempty"`
+	WebOrigins                []string `yaml:"webOrigins,omitempty" json:"webOrigins,omitempty"`
+	StandardFlowEnabled       *bool    `yaml:"standardFlowEnabled,omitempty" json:"standardFlowEnabled,omitempty"`
+	DirectAccessGrantsEnabled *bool    `yaml:"directAccessGrantsEnabled,omitempty" json:"directAccessGrantsEnabled,omitempty"`
+	ImplicitFlowEnabled       *bool    `yaml:"implicitFlowEnabled,omitempty" json:"implicitFlowEnabled,omitempty"`
+	ServiceAccountsEnabled    *bool    `yaml:"serviceAccountsEnabled,omitempty" json:"serviceAccountsEnabled,omitempty"`
+	DefaultClientScopes       []string `yaml:"defaultClientScopes,omitempty" json:"defaultClientScopes,omitempty"`
+	OptionalClientScopes      []string `yaml:"optionalClientScopes,omitempty" json:"optionalClientScopes,omitempty"`
+}
+
+// Group is addressed by its full path, e.g. /teams/payments.
+type Group struct {
+	Path       string   `yaml:"path" json:"path"`
+	RealmRoles []string `yaml:"realmRoles,omitempty" json:"realmRoles,omitempty"`
+}
+
+type User struct {
+	Username   string   `yaml:"username" json:"username"`
+	Email      string   `yaml:"email,omitempty" json:"email,omitempty"`
+	FirstName  string   `yaml:"firstName,omitempty" json:"firstName,omitempty"`
+	LastName   string   `yaml:"lastName,omitempty" json:"lastName,omitempty"`
+	Enabled    *bool    `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Password   string   `yaml:"password,omitempty" json:"password,omitempty"`
+	RealmRoles []string `yaml:"realmRoles,omitempty" json:"realmRoles,omitempty"`
+	Groups     []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+}
+
+// Load reads a desired-state file. YAML and JSON are both accepted.
+func Load(path string) (*State, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse decodes and validates a desired-state document.
+func Parse(b []byte) (*State, error) {
+	var s State
+	if err := yaml.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Validate checks that every entity has its identifying field set and is declared once.
+func (s *State) Validate() error {
+	if len(s.Realms) == 0 {
+		return errors.New("manifest declares no realms")
+	}
+	seenRealms := map[string]bool{}
+	for _, r := range s.Realms {
+		if r.Name == "" {
+			return errors.New("realm without name")
+		}
+		if seenRealms[r.Name] {
+			return fmt.Errorf("realm %q declared twice", r.Name)
+		}
+		seenRealms[r.Name] = true
+		if err := unique("role", r.Name, r.Roles, func(x Role) string { return x.Name }); err != nil {
+			return err
+		}
+		if err := unique("client scope", r.Name, r.ClientScopes, func(x ClientScope) string { return x.Name }); err != nil {
+			return err
+		}
+		if err := unique("client", r.Name, r.Clients, func(x Client) string { return x.ClientID }); err != nil {
+			return err
+		}
+		if err := unique("group", r.Name, r.Groups, func(x Group) string { return x.Path }); err != nil {
+			return err
+		}
+		for _, g := range r.Groups {
+			if len(g.Path) < 2 || g.Path[0] != '/' {
+				return fmt.Errorf("group %q in realm %q: path must start with '/'", g.Path, r.Name)
+			}
+		}
+		if err := unique("user", r.Name, r.Users, func(x User) string { return x.Username }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unique[T any](kind, realm string, items []T, key func(T) string) error {
+	seen := map[string]bool{}
+	for _, it := range items {
+		k := key(it)
+		if k == "" {
+			return fmt.Errorf("%s without identifier in realm %q", kind, realm)
+		}
+		if seen[k] {
+			return fmt.Errorf("%s %q declared twice in realm %q", kind, k, realm)
+		}
+		seen[k] = true
+	}
+	return nil
+}
+
+// Find returns the declared realm with the given name, or nil.
+func (s *State) Find(name string) *Realm {
+	for i := range s.Realms {
+		if s.Realms[i].Name == name {
+			return &s.Realms[i]
+		}
+	}
+	return nil
+}
+
+// StripBuiltins removes the entities Keycloak creates on its own, so a
+// captured realm can be re-applied to a fresh realm without conflicts.
+func (r *Realm) StripBuiltins() {
+	r.Roles = slices.DeleteFunc(r.Roles, func(x Role) bool { return IsBuiltinRole(r.Name, x.Name) })
+	r.ClientScopes = slices.DeleteFunc(r.ClientScopes, func(x ClientScope) bool { return IsBuiltinClientScope(x.Name) })
+	r.Clients = slices.DeleteFunc(r.Clients, func(x Client) bool { return IsBuiltinClient(r.Name, x.ClientID) })
+	r.Users = slices.DeleteFunc(r.Users, func(x User) bool { return strings.HasPrefix(x.Username, "service-account-") })
+}
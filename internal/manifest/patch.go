@@ -0,0 +1,55 @@
+package manifest
+
+import "strings"
+
+// PatchOp is a single RFC 6902 JSON patch operation. Paths address entities
+// by name under /realms/<realm>, e.g. /realms/prod/clients/web/enabled.
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+var kindCollections = map[Kind]string{
+	KindRole:        "roles",
+	KindClientScope: "clientScopes",
+	KindClient:      "clients",
+	KindGroup:       "groups",
+	KindUser:        "users",
+}
+
+// Patch renders actions as a JSON patch taking the server state to the
+// desired state.
+func Patch(actions []Action) []PatchOp {
+	var ops []PatchOp
+	for _, a := range actions {
+		path := "/realms/" + escapePointer(a.Realm)
+		if a.Kind != KindRealm {
+			path += "/" + kindCollections[a.Kind] + "/" + escapePointer(a.Name)
+		}
+		switch a.Op {
+		case OpDelete:
+			ops = append(ops, PatchOp{Op: "remove", Path: path})
+		case OpCreate:
+			value := map[string]interface{}{}
+			for _, f := range a.Fields {
+				value[f.Field] = f.New
+			}
+			ops = append(ops, PatchOp{Op: "add", Path: path, Value: value})
+		default:
+			for _, f := range a.Fields {
+				op := "replace"
+				if f.Old == nil {
+					op = "add"
+				}
+				ops = append(ops, PatchOp{Op: op, Path: path + "/" + escapePointer(f.Field), Value: f.New})
+			}
+		}
+	}
+	return ops
+}
+
+// escapePointer escapes a JSON pointer reference token (RFC 6901).
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
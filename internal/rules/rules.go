@@ -0,0 +1,253 @@
+// Package rules defines the automation rules run by `kc watch`: which
+// Keycloak events trigger them and which templated actions they perform on the
+// user the event is about.
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Event sources.
+const (
+	SourceLogin = "login"
+	SourceAdmin = "admin"
+)
+
+// File is the content of a rules file.
+type File struct {
+	// Interval between polls, e.g. 30s (default 30s).
+	Interval string `yaml:"interval"`
+	Rules    []Rule `yaml:"rules"`
+}
+
+// Rule runs Actions for every event matching On.
+type Rule struct {
+	Name string `yaml:"name"`
+	// Realms the rule applies to; empty means the realms selected on the
+	// command line.
+	Realms  []string `yaml:"realms"`
+	On      Trigger  `yaml:"on"`
+	Actions []Action `yaml:"actions"`
+}
+
+// Trigger selects events.
+type Trigger struct {
+	// Source is login (user events such as REGISTER) or admin.
+	Source string `yaml:"source"`
+	// Types are login event types (REGISTER, LOGIN...) or admin operation
+	// types (CREATE, UPDATE, DELETE, ACTION). Empty matches any.
+	Types []string `yaml:"types"`
+	// ResourceTypes filters admin events, e.g. USER. Empty matches any.
+	ResourceTypes []string `yaml:"resource_types"`
+	// Client filters login events by client-id.
+	Client string `yaml:"client"`
+}
+
+// Action is one step performed on the event's user. Exactly one field is set.
+// Every string is a text/template evaluated against the Event, e.g.
+// "{{.Details.identity_provider}}-user".
+type Action struct {
+	AssignRealmRoles  []string          `yaml:"assign_realm_roles"`
+	AssignClientRoles *ClientRoles      `yaml:"assign_client_roles"`
+	AddToGroups       []string          `yaml:"add_to_groups"`
+	SetAttributes     map[string]string `yaml:"set_attributes"`
+	RequiredActions   []string          `yaml:"required_actions"`
+}
+
+// ClientRoles are roles of one client.
+type ClientRoles struct {
+	Client string   `yaml:"client"`
+	Roles  []string `yaml:"roles"`
+}
+
+// Event is the data available to triggers and templates.
+type Event struct {
+	Source       string
+	Realm        string
+	Type         string
+	Time         time.Time
+	UserID       string
+	Username     string
+	ClientID     string
+	IPAddress    string
+	ResourceType string
+	ResourcePath string
+	Details      map[string]string
+}
+
+// Load reads and validates a rules file.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// PollInterval returns the configured interval, 30s by default.
+func (f *File) PollInterval() time.Duration {
+	d, err := time.ParseDuration(f.Interval)
+	if err != nil || d <= 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+func (f *File) validate() error {
+	if len(f.Rules) == 0 {
+		return errors.New("no rules defined")
+	}
+	if f.Interval != "" {
+		if d, err := time.ParseDuration(f.Interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid interval %q", f.Interval)
+		}
+	}
+	names := map[string]bool{}
+	for i, r := range f.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule %q: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+		if r.On.Source != SourceLogin && r.On.Source != SourceAdmin {
+			return fmt.Errorf("rule %q: on.source must be %q or %q", r.Name, SourceLogin, SourceAdmin)
+		}
+		if r.On.Source == SourceLogin && len(r.On.ResourceTypes) > 0 {
+			return fmt.Errorf("rule %q: on.resource_types only applies to admin events", r.Name)
+		}
+		if len(r.Actions) == 0 {
+			return fmt.Errorf("rule %q: no actions", r.Name)
+		}
+		for j, a := range r.Actions {
+			if err := a.validate(); err != nil {
+				return fmt.Errorf("rule %q, action %d: %w", r.Name, j+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (a Action) validate() error {
+	set := 0
+	var templates []string
+	if len(a.AssignRealmRoles) > 0 {
+		set++
+		templates = append(templates, a.AssignRealmRoles...)
+	}
+	if a.AssignClientRoles != nil {
+		set++
+		if a.AssignClientRoles.Client == "" || len(a.AssignClientRoles.Roles) == 0 {
+			return errors.New("assign_client_roles needs client and roles")
+		}
+		templates = append(templates, a.AssignClientRoles.Client)
+		templates = append(templates, a.AssignClientRoles.Roles...)
+	}
+	if len(a.AddToGroups) > 0 {
+		set++
+		templates = append(templates, a.AddToGroups...)
+	}
+	if len(a.SetAttributes) > 0 {
+		set++
+		for _, v := range a.SetAttributes {
+			templates = append(templates, v)
+		}
+	}
+	if len(a.RequiredActions) > 0 {
+		set++
+		templates = append(templates, a.RequiredActions...)
+	}
+	if set != 1 {
+		return errors.New("set exactly one of assign_realm_roles, assign_client_roles, add_to_groups, set_attributes, required_actions")
+	}
+	for _, t := range templates {
+		if _, err := parse(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppliesTo reports whether the rule runs in realm, given the realms selected
+// on the command line.
+func (r Rule) AppliesTo(realm string, selected []string) bool {
+	if len(r.Realms) > 0 {
+		return slices.Contains(r.Realms, realm)
+	}
+	return slices.Contains(selected, realm)
+}
+
+// Matches reports whether e triggers t.
+func (t Trigger) Matches(e Event) bool {
+	if e.Source != t.Source {
+		return false
+	}
+	if len(t.Types) > 0 && !containsFold(t.Types, e.Type) {
+		return false
+	}
+	if len(t.ResourceTypes) > 0 && !containsFold(t.ResourceTypes, e.ResourceType) {
+		return false
+	}
+	if t.Client != "" && t.Client != e.ClientID {
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parse(s string) (*template.Template, error) {
+	return template.New("").Option("missingkey=zero").Parse(s)
+}
+
+// Expand evaluates the template s against e.
+func Expand(s string, e Event) (string, error) {
+	t, err := parse(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, e); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ExpandAll is Expand for lists.
+func ExpandAll(list []string, e Event) ([]string, error) {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		v, err := Expand(s, e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
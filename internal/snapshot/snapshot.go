@@ -0,0 +1,161 @@
+// Package snapshot reads and writes realm snapshot archives: a gzipped tar
+// holding the captured files plus a SHA256SUMS manifest used to verify them.
+package snapshot
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatVersion is bumped when the archive layout changes incompatibly.
+const FormatVersion = 1
+
+const (
+	MetaFile     = "meta.json"
+	ManifestFile = "manifest.yaml"
+	RealmFile    = "realm.json"
+	sumsFile     = "SHA256SUMS"
+)
+
+// Meta describes where and when a snapshot was taken.
+type Meta struct {
+	FormatVersion int       `json:"formatVersion"`
+	Realm         string    `json:"realm"`
+	ServerURL     string    `json:"serverUrl"`
+	CreatedAt     time.Time `json:"createdAt"`
+	IncludesUsers bool      `json:"includesUsers"`
+}
+
+// Archive is the in-memory content of a snapshot.
+type Archive struct {
+	Meta  Meta
+	Files map[string][]byte
+}
+
+// Write stores the archive at path with a checksum entry for every file.
+func Write(path string, a *Archive) error {
+	meta, err := json.MarshalIndent(a.Meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{MetaFile: meta}
+	for name, b := range a.Files {
+		files[name] = b
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var sums bytes.Buffer
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	files[sumsFile] = sums.Bytes()
+	names = append(names, sumsFile)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(files[name])), ModTime: a.Meta.CreatedAt}
+		if err = tw.WriteHeader(hdr); err != nil {
+			break
+		}
+		if _, err = tw.Write(files[name]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// Read loads an archive and verifies every file against its checksum.
+func Read(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a snapshot archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupt snapshot archive: %w", err)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("corrupt snapshot archive: %w", err)
+		}
+		files[hdr.Name] = b
+	}
+
+	sums, ok := files[sumsFile]
+	if !ok {
+		return nil, fmt.Errorf("snapshot has no %s", sumsFile)
+	}
+	delete(files, sumsFile)
+	want := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		sum, name, ok := strings.Cut(sc.Text(), "  ")
+		if !ok {
+			return nil, fmt.Errorf("malformed %s line %q", sumsFile, sc.Text())
+		}
+		want[name] = sum
+	}
+	for name, b := range files {
+		sum := sha256.Sum256(b)
+		if want[name] != hex.EncodeToString(sum[:]) {
+			return nil, fmt.Errorf("checksum mismatch for %s: the snapshot is corrupt or was modified", name)
+		}
+		delete(want, name)
+	}
+	for name := range want {
+		return nil, fmt.Errorf("snapshot is missing %s", name)
+	}
+
+	a := &Archive{Files: files}
+	if err := json.Unmarshal(files[MetaFile], &a.Meta); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", MetaFile, err)
+	}
+	if a.Meta.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("snapshot format %d is newer than supported (%d)", a.Meta.FormatVersion, FormatVersion)
+	}
+	delete(a.Files, MetaFile)
+	return a, nil
+}
@@ -0,0 +1,185 @@
+// Package terraform renders the small subset of HCL needed to export realm
+// entities as Terraform/OpenTofu configuration for the Keycloak provider.
+package terraform
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Value is an HCL expression.
+type Value interface {
+	hcl(indent string) string
+}
+
+// String is a quoted string literal; template sequences are escaped.
+type String string
+
+// Ref is an unquoted reference such as keycloak_role.admin.id.
+type Ref string
+
+// Bool is a boolean literal.
+type Bool bool
+
+// List is a tuple of values rendered on one line.
+type List []Value
+
+// Object is a map of attributes rendered one per line.
+type Object map[string]Value
+
+func (s String) hcl(string) string {
+	q := strconv.Quote(string(s))
+	q = strings.ReplaceAll(q, "${", "$${")
+	return strings.ReplaceAll(q, "%{", "%%{")
+}
+
+func (r Ref) hcl(string) string { return string(r) }
+
+func (b Bool) hcl(string) string { return strconv.FormatBool(bool(b)) }
+
+func (l List) hcl(indent string) string {
+	parts := make([]string, len(l))
+	for i, v := range l {
+		parts[i] = v.hcl(indent)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func (o Object) hcl(indent string) string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	width := 0
+	for _, k := range keys {
+		width = max(width, len(k))
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s  %-*s = %s\n", indent, width, k, o[k].hcl(indent+"  "))
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// Strings converts a list of plain strings into a List of literals.
+func Strings(ss []string) List {
+	l := make(List, len(ss))
+	for i, s := range ss {
+		l[i] = String(s)
+	}
+	return l
+}
+
+type attr struct {
+	name  string
+	value Value
+}
+
+// Block is a top-level or nested HCL block, e.g. resource "type" "name" { ... }.
+type Block struct {
+	Type    string
+	Labels  []string
+	attrs   []attr
+	blocks  []*Block
+	comment string
+}
+
+// NewBlock starts a block with the given type and labels.
+func NewBlock(typ string, labels ...string) *Block {
+	return &Block{Type: typ, Labels: labels}
+}
+
+// Set appends an attribute. Nil values are skipped, so optional fields can be
+// set unconditionally.
+func (b *Block) Set(name string, v Value) *Block {
+	if v != nil {
+		b.attrs = append(b.attrs, attr{name, v})
+	}
+	return b
+}
+
+// Nest appends a nested block.
+func (b *Block) Nest(child *Block) *Block {
+	b.blocks = append(b.blocks, child)
+	return b
+}
+
+// Comment sets a line comment rendered above the block.
+func (b *Block) Comment(c string) *Block {
+	b.comment = c
+	return b
+}
+
+func (b *Block) render(buf *bytes.Buffer, indent string) {
+	if b.comment != "" {
+		fmt.Fprintf(buf, "%s# %s\n", indent, b.comment)
+	}
+	buf.WriteString(indent + b.Type)
+	for _, l := range b.Labels {
+		buf.WriteString(" " + strconv.Quote(l))
+	}
+	buf.WriteString(" {\n")
+	width := 0
+	for _, a := range b.attrs {
+		width = max(width, len(a.name))
+	}
+	for _, a := range b.attrs {
+		fmt.Fprintf(buf, "%s  %-*s = %s\n", indent, width, a.name, a.value.hcl(indent+"  "))
+	}
+	for i, c := range b.blocks {
+		if i > 0 || len(b.attrs) > 0 {
+			buf.WriteString("\n")
+		}
+		c.render(buf, indent+"  ")
+	}
+	buf.WriteString(indent + "}\n")
+}
+
+// Render formats blocks as a file, separated by blank lines.
+func Render(header string, blocks []*Block) []byte {
+	var buf bytes.Buffer
+	if header != "" {
+		for _, l := range strings.Split(header, "\n") {
+			buf.WriteString("# " + l + "\n")
+		}
+		buf.WriteString("\n")
+	}
+	for i, b := range blocks {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		b.render(&buf, "")
+	}
+	return buf.Bytes()
+}
+
+// Names hands out unique Terraform identifiers derived from entity names.
+type Names map[string]bool
+
+// For returns a valid, unused identifier for name.
+func (n Names) For(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else if !strings.HasSuffix(b.String(), "_") {
+			b.WriteRune('_')
+		}
+	}
+	id := strings.Trim(b.String(), "_-")
+	if id == "" || (id[0] >= '0' && id[0] <= '9') || id[0] == '-' {
+		id = "r_" + id
+	}
+	candidate := id
+	for i := 2; n[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", id, i)
+	}
+	n[candidate] = true
+	return candidate
+}
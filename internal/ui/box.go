@@ -1,6 +1,11 @@
 package ui
 
-import "strings"
+import (
+	"strings"
+	"unicode/utf8"
+
+	"kc/internal/i18n"
+)
 
 type BoxOptions struct {
 	JiraTicket string
@@ -10,10 +15,10 @@ type BoxOptions struct {
 
 func RenderBox(lines []string, opts BoxOptions) string {
 	headerText := buildHeaderText(opts)
-	contentWidth := len(headerText)
+	contentWidth := utf8.RuneCountInString(headerText)
 	for _, l := range lines {
-		if len(l) > contentWidth {
-			contentWidth = len(l)
+		if n := utf8.RuneCountInString(l); n > contentWidth {
+			contentWidth = n
 		}
 	}
 	if contentWidth < 80 {
@@ -40,10 +45,10 @@ func RenderBox(lines []string, opts BoxOptions) string {
 func buildHeaderText(opts BoxOptions) string {
 	parts := make([]string, 0, 3)
 	if opts.JiraTicket != "" {
-		parts = append(parts, "Jira Ticket: "+opts.JiraTicket)
+		parts = append(parts, i18n.Translate("Jira Ticket: "+opts.JiraTicket))
 	}
 	if opts.Realm != "" {
-		parts = append(parts, "Current realm: "+opts.Realm)
+		parts = append(parts, i18n.Translate("Current realm: "+opts.Realm))
 	}
 	if len(parts) == 0 {
 		if opts.Title != "" {
@@ -55,8 +60,9 @@ func buildHeaderText(opts BoxOptions) string {
 }
 
 func padRight(s string, width int) string {
-	if len(s) >= width {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
 		return s
 	}
-	return s + strings.Repeat(" ", width-len(s))
+	return s + strings.Repeat(" ", width-n)
 }
@@ -0,0 +1,107 @@
+package kcops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// runBatch calls fn for items 0..n-1 using up to workers goroutines and returns
+// the results of the items that completed, in item order, so output does not
+// depend on scheduling. After the first failure no new items are started, and
+// items interrupted by that failure are not reported as its cause. With
+// keepGoing, failed items are reported as Failed results instead and the
+// remaining items still run.
+func runBatch(ctx context.Context, n, workers int, keepGoing bool, fn func(ctx context.Context, i int) (Result, error)) ([]Result, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]Result, n)
+	errs := make([]error, n)
+	started := make([]bool, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				// the send can win the select against ctx ending, e.g. on
+				// a failure; such items are not started
+				if ctx.Err() != nil {
+					continue
+				}
+				started[i] = true
+				results[i], errs[i] = fn(ctx, i)
+				if errs[i] != nil && !keepGoing {
+					cancel()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case next <- i:
+			continue
+		case <-ctx.Done():
+		}
+		break
+	}
+	close(next)
+	wg.Wait()
+	if keepGoing {
+		return collectFailures(ctx, results, errs, started)
+	}
+
+	var err error
+	for i := range errs {
+		if errs[i] != nil && (err == nil || isCanceled(err)) {
+			err = errs[i]
+		}
+	}
+	if err == nil && n > 0 && !started[n-1] {
+		// the context ended between items: the batch is not complete
+		err = ctx.Err()
+	}
+	var out []Result
+	for i := range results {
+		if started[i] && errs[i] == nil {
+			out = append(out, results[i])
+		}
+	}
+	return out, err
+}
+
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || strings.Contains(err.Error(), context.Canceled.Error())
+}
+
+// collectFailures is the end of a keepGoing batch: every started item is
+// reported, the failed ones as Failed results.
+func collectFailures(ctx context.Context, results []Result, errs []error, started []bool) ([]Result, error) {
+	var out []Result
+	failed := 0
+	for i := range results {
+		if !started[i] {
+			continue
+		}
+		r := results[i]
+		if errs[i] != nil {
+			r.Outcome = Failed
+			r.Error = errs[i].Error()
+			failed++
+		}
+		out = append(out, r)
+	}
+	if err := ctx.Err(); err != nil {
+		return out, err
+	}
+	if failed > 0 {
+		return out, fmt.Errorf("%d of %d item(s) failed: %w", failed, len(out), ErrItemsFailed)
+	}
+	return out, nil
+}
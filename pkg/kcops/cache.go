@@ -0,0 +1,132 @@
+package kcops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// lookupCache memoizes realm, client and client scope lookups, so batches fetch
+// each list once per realm instead of once per entity. Operations that create,
+// rename or delete entities keep it in sync.
+type lookupCache struct {
+	mu      sync.Mutex
+	realms  []string
+	clients map[string]map[string]*gocloak.Client      // realm -> clientId -> client
+	scopes  map[string]map[string]*gocloak.ClientScope // realm -> name -> scope
+}
+
+// RealmNames returns the names of all realms.
+func (c *Client) RealmNames(ctx context.Context) ([]string, error) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	if c.cache.realms == nil {
+		realms, err := c.GC.GetRealms(ctx, c.Token)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{}
+		for _, r := range realms {
+			if r.Realm != nil {
+				names = append(names, *r.Realm)
+			}
+		}
+		c.cache.realms = names
+	}
+	return append([]string{}, c.cache.realms...), nil
+}
+
+// ClientByClientID returns the client of realm with the given clientId.
+func (c *Client) ClientByClientID(ctx context.Context, realm, cid string) (*gocloak.Client, error) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	if c.cache.clients[realm] == nil {
+		list, err := c.GC.GetClients(ctx, c.Token, realm, gocloak.GetClientsParams{})
+		if err != nil {
+			return nil, err
+		}
+		byID := map[string]*gocloak.Client{}
+		for _, cl := range list {
+			if cl.ClientID != nil {
+				byID[*cl.ClientID] = cl
+			}
+		}
+		if c.cache.clients == nil {
+			c.cache.clients = map[string]map[string]*gocloak.Client{}
+		}
+		c.cache.clients[realm] = byID
+	}
+	// callers may rename the returned client in place
+	if cl, ok := c.cache.clients[realm][cid]; ok && gocloak.PString(cl.ClientID) == cid {
+		return cl, nil
+	}
+	return nil, fmt.Errorf("client %q not found", cid)
+}
+
+// ClientScopeByName returns the client scope of realm with the given name.
+func (c *Client) ClientScopeByName(ctx context.Context, realm, name string) (*gocloak.ClientScope, error) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	if c.cache.scopes[realm] == nil {
+		list, err := c.GC.GetClientScopes(ctx, c.Token, realm)
+		if err != nil {
+			return nil, err
+		}
+		byName := map[string]*gocloak.ClientScope{}
+		for _, s := range list {
+			if s.Name != nil {
+				byName[*s.Name] = s
+			}
+		}
+		if c.cache.scopes == nil {
+			c.cache.scopes = map[string]map[string]*gocloak.ClientScope{}
+		}
+		c.cache.scopes[realm] = byName
+	}
+	if s, ok := c.cache.scopes[realm][name]; ok && gocloak.PString(s.Name) == name {
+		return s, nil
+	}
+	return nil, fmt.Errorf("client scope %q not found", name)
+}
+
+// Invalidate drops everything cached for realm, and the realm list. Call it
+// after changing realm entities through GC directly.
+func (c *Client) Invalidate(realm string) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	c.cache.realms = nil
+	delete(c.cache.clients, realm)
+	delete(c.cache.scopes, realm)
+}
+
+// putClient stores a created or renamed client under its current clientId.
+func (c *lookupCache) putClient(realm string, cl *gocloak.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clients[realm] != nil && cl.ClientID != nil {
+		c.clients[realm][*cl.ClientID] = cl
+	}
+}
+
+func (c *lookupCache) forgetClient(realm, cid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.clients[realm], cid)
+}
+
+// putScope stores a created or renamed client scope under its current name.
+func (c *lookupCache) putScope(realm string, s *gocloak.ClientScope) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.scopes[realm] != nil && s.Name != nil {
+		c.scopes[realm][*s.Name] = s
+	}
+}
+
+func (c *lookupCache) forgetScope(realm, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.scopes[realm], name)
+}
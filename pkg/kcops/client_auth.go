@@ -0,0 +1,304 @@
+package kcops
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// ClientAuthMethods maps the methods ClientAuth accepts to the client
+// authenticators of Keycloak.
+var ClientAuthMethods = map[string]string{
+	"client-secret":   "client-secret",
+	"private-key-jwt": "client-jwt",
+	"tls-client-auth": "client-x509",
+}
+
+// ClientKeys are the public keys Keycloak verifies the signed JWTs of a
+// private-key-jwt client with: exactly one of JWKSURL, JWKS and Certificate.
+type ClientKeys struct {
+	// JWKSURL is where Keycloak fetches the public keys of the client.
+	JWKSURL string `json:"jwksUrl,omitempty"`
+	// JWKS is a JSON Web Key Set stored in the client.
+	JWKS string `json:"jwks,omitempty"`
+	// Certificate is a PEM certificate stored in the client.
+	Certificate string `json:"certificate,omitempty"`
+}
+
+// ClientAuth is how a confidential client authenticates to Keycloak.
+// private-key-jwt needs the ClientKeys; tls-client-auth needs SubjectDN.
+type ClientAuth struct {
+	Method string `json:"method"`
+	ClientKeys
+	// SubjectDN is the subject DN the client certificate must have, e.g.
+	// CN=billing,O=Acme.
+	SubjectDN string `json:"subjectDn,omitempty"`
+}
+
+// given counts the key sources set.
+func (k ClientKeys) given() int {
+	n := 0
+	for _, v := range []string{k.JWKSURL, k.JWKS, k.Certificate} {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Validate checks that exactly one key source is set and that it parses.
+func (k ClientKeys) Validate() error {
+	if k.given() != 1 {
+		return errors.New("give exactly one of a JWKS URL, a JWKS or a certificate")
+	}
+	if k.JWKS != "" {
+		if _, err := jwksKeyIDs(k.JWKS); err != nil {
+			return err
+		}
+	}
+	if k.Certificate != "" {
+		if _, err := certificateAttribute(k.Certificate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attributes returns the client attributes selecting the keys.
+func (k ClientKeys) attributes() map[string]string {
+	switch {
+	case k.JWKSURL != "":
+		return map[string]string{"use.jwks.url": "true", "use.jwks.string": "false", "jwks.url": k.JWKSURL}
+	case k.JWKS != "":
+		return map[string]string{"use.jwks.url": "false", "use.jwks.string": "true", "jwks.string": k.JWKS}
+	default:
+		cert, _ := certificateAttribute(k.Certificate)
+		return map[string]string{"use.jwks.url": "false", "use.jwks.string": "false", "jwt.credential.certificate": cert}
+	}
+}
+
+// Validate checks the method and that the key material fits it.
+func (a ClientAuth) Validate() error {
+	if _, ok := ClientAuthMethods[a.Method]; !ok {
+		return fmt.Errorf("unknown auth method %q: use %s", a.Method, strings.Join(slices.Sorted(maps.Keys(ClientAuthMethods)), ", "))
+	}
+	keys := a.ClientKeys.given()
+	switch a.Method {
+	case "private-key-jwt":
+		if keys != 1 {
+			return errors.New("private-key-jwt needs exactly one of a JWKS URL, a JWKS or a certificate")
+		}
+		if a.SubjectDN != "" {
+			return errors.New("a subject DN only applies to tls-client-auth")
+		}
+		return a.ClientKeys.Validate()
+	case "tls-client-auth":
+		if a.SubjectDN == "" {
+			return errors.New("tls-client-auth needs the subject DN of the client certificate")
+		}
+		if keys > 0 {
+			return errors.New("a JWKS or certificate only applies to private-key-jwt")
+		}
+	default:
+		if keys > 0 || a.SubjectDN != "" {
+			return fmt.Errorf("%s takes no JWKS, certificate or subject DN", a.Method)
+		}
+	}
+	return nil
+}
+
+// settings returns the client authenticator and the client attributes of a.
+func (a ClientAuth) settings() (string, map[string]string, error) {
+	if err := a.Validate(); err != nil {
+		return "", nil, err
+	}
+	attrs := map[string]string{}
+	switch {
+	case a.ClientKeys.given() > 0:
+		attrs = a.ClientKeys.attributes()
+	case a.SubjectDN != "":
+		attrs["x509.subjectdn"] = a.SubjectDN
+	}
+	return ClientAuthMethods[a.Method], attrs, nil
+}
+
+// certificateAttribute turns a PEM certificate into the base64 DER Keycloak
+// stores. Expired certificates are rejected.
+func certificateAttribute(certPEM string) (string, error) {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("the certificate is not a PEM CERTIFICATE block")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("the certificate does not parse: %w", err)
+	}
+	if time.Now().After(cert.NotAfter) {
+		return "", fmt.Errorf("the certificate expired on %s", cert.NotAfter.UTC().Format(time.DateOnly))
+	}
+	return base64.StdEncoding.EncodeToString(block.Bytes), nil
+}
+
+// jwksKeyIDs returns the kid of each key of a JSON Web Key Set.
+func jwksKeyIDs(jwks string) ([]string, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(jwks), &set); err != nil || len(set.Keys) == 0 {
+		return nil, errors.New("the JWKS is not a JSON Web Key Set with at least one key")
+	}
+	kids := make([]string, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		kids = append(kids, k.Kid)
+	}
+	return kids, nil
+}
+
+// keyAttributes are the attributes whose values are key material, recorded
+// as "(set)" rather than in full.
+var keyAttributes = []string{"jwks.string", "jwt.credential.certificate", "saml.signing.certificate", "saml.encryption.certificate"}
+
+// appendAuthChanges records the authenticator and attribute changes of a
+// client; old may be nil on create.
+func appendAuthChanges(fields []FieldChange, oldType *string, oldAttrs map[string]string, newType string, attrs map[string]string) []FieldChange {
+	fields = appendFieldChange(fields, "clientAuthenticatorType", oldType, &newType)
+	return appendAttributeChanges(fields, oldAttrs, attrs)
+}
+
+// appendAttributeChanges records the attributes of attrs that differ from
+// oldAttrs.
+func appendAttributeChanges(fields []FieldChange, oldAttrs, attrs map[string]string) []FieldChange {
+	for _, k := range slices.Sorted(maps.Keys(attrs)) {
+		v := attrs[k]
+		old, had := oldAttrs[k]
+		if had && old == v {
+			continue
+		}
+		if slices.Contains(keyAttributes, k) {
+			fields = append(fields, FieldChange{Field: "attributes." + k, New: "(set)"})
+			continue
+		}
+		fields = append(fields, FieldChange{Field: "attributes." + k, Old: old, New: v})
+	}
+	return fields
+}
+
+// DescribeClientKeys summarizes the keys a private-key-jwt client is
+// verified with, from its attributes: the JWKS URL, the key IDs of a stored
+// JWKS, or the SHA-256 fingerprint and expiry of a stored certificate. It
+// returns "" when the client has no keys.
+func DescribeClientKeys(attrs map[string]string) string {
+	switch {
+	case attrs["use.jwks.url"] == "true":
+		return "JWKS URL " + attrs["jwks.url"]
+	case attrs["use.jwks.string"] == "true" && attrs["jwks.string"] != "":
+		kids, err := jwksKeyIDs(attrs["jwks.string"])
+		if err != nil {
+			return "JWKS (invalid)"
+		}
+		return fmt.Sprintf("JWKS with %d key(s): %s", len(kids), strings.Join(kids, ", "))
+	case attrs["jwt.credential.certificate"] != "":
+		der, err := base64.StdEncoding.DecodeString(attrs["jwt.credential.certificate"])
+		if err != nil {
+			return "certificate (invalid)"
+		}
+		sum := sha256.Sum256(der)
+		desc := "certificate SHA-256 " + hex.EncodeToString(sum[:8])
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			desc += ", expires " + cert.NotAfter.UTC().Format(time.DateOnly)
+		}
+		return desc
+	}
+	return ""
+}
+
+// SetClientKeysRequest replaces the keys of private-key-jwt clients of one
+// realm.
+type SetClientKeysRequest struct {
+	Realm     string     `json:"realm,omitempty"`
+	ClientIDs []string   `json:"clientIds,omitempty"`
+	Keys      ClientKeys `json:"keys"`
+	// Rotate requires the clients to have keys already and different from
+	// Keys, so that a scheduled rotation never silently re-uploads the same
+	// key or starts from nothing.
+	Rotate bool `json:"rotate,omitempty"`
+	// IgnoreMissing skips clients that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// SetClientKeys sets the keys of the clients of req. Clients whose keys
+// already match are skipped, unless req.Rotate makes that an error.
+func SetClientKeys(ctx context.Context, c *Client, req SetClientKeysRequest) ([]Result, error) {
+	realm := req.Realm
+	if err := req.Keys.Validate(); err != nil {
+		return nil, err
+	}
+	attrs := req.Keys.attributes()
+	return runBatch(ctx, len(req.ClientIDs), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		cid := req.ClientIDs[i]
+		res := Result{Realm: realm, Name: cid, NewName: cid}
+		cl, err := c.ClientByClientID(ctx, realm, cid)
+		if err != nil || cl == nil || cl.ID == nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("client %q not found in realm %s", cid, realm)
+		}
+		if gocloak.PBool(cl.PublicClient) {
+			return res, fmt.Errorf("client %q is public; keys only apply to confidential clients", cid)
+		}
+		current := map[string]string{}
+		if cl.Attributes != nil {
+			current = *cl.Attributes
+		}
+		oldKeys := DescribeClientKeys(current)
+		unchanged := !slices.ContainsFunc(slices.Collect(maps.Keys(attrs)), func(k string) bool { return current[k] != attrs[k] })
+		if req.Rotate && oldKeys == "" {
+			return res, fmt.Errorf("client %q has no keys to rotate; upload them first", cid)
+		}
+		if unchanged {
+			if req.Rotate {
+				return res, fmt.Errorf("client %q already has these keys; rotate needs new ones", cid)
+			}
+			res.ID = *cl.ID
+			res.Outcome = Skipped
+			return res, nil
+		}
+
+		before := *cl
+		merged := maps.Clone(current)
+		maps.Copy(merged, attrs)
+		cl.Attributes = &merged
+		if gocloak.PString(cl.ClientAuthenticatorType) != ClientAuthMethods["private-key-jwt"] {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("client %q authenticates with %s; the keys are only used with the private-key-jwt method", cid, gocloak.PString(cl.ClientAuthenticatorType)))
+		}
+		if err := c.GC.UpdateClient(ctx, c.Token, realm, *cl); err != nil {
+			return res, fmt.Errorf("failed setting the keys of client %q in realm %s: %w", cid, realm, err)
+		}
+		c.cache.putClient(realm, cl)
+		res.ID = *cl.ID
+		res.Outcome = Updated
+		res.Before = before
+		newKeys := DescribeClientKeys(merged)
+		res.Fields = append(res.Fields, FieldChange{Field: "keys", Old: oldKeys, New: newKeys})
+		res.Fields = appendAttributeChanges(res.Fields, current, attrs)
+		return res, nil
+	})
+}
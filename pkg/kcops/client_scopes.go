@@ -0,0 +1,170 @@
+package kcops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// ClientScopeSpec describes a client scope to create.
+type ClientScopeSpec struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Protocol defaults to openid-connect.
+	Protocol string `json:"protocol,omitempty"`
+	// Mappers are created with the scope.
+	Mappers []gocloak.ProtocolMappers `json:"mappers,omitempty"`
+}
+
+// CreateClientScopesRequest creates client scopes in one realm. Scopes that
+// already exist are skipped.
+type CreateClientScopesRequest struct {
+	Realm  string            `json:"realm,omitempty"`
+	Scopes []ClientScopeSpec `json:"scopes,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// CreateClientScopes creates the client scopes of req.
+func CreateClientScopes(ctx context.Context, c *Client, req CreateClientScopesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Scopes), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		spec := req.Scopes[i]
+		n := spec.Name
+		res := Result{Realm: realm, Name: n}
+		if _, err := c.ClientScopeByName(ctx, realm, n); err == nil {
+			res.Outcome = Skipped
+			return res, nil
+		}
+		desc := spec.Description
+		protocol := spec.Protocol
+		if protocol == "" {
+			protocol = "openid-connect"
+		}
+		s := gocloak.ClientScope{Name: &n, Description: &desc, Protocol: &protocol}
+		if len(spec.Mappers) > 0 {
+			s.ProtocolMappers = &spec.Mappers
+		}
+		id, err := c.GC.CreateClientScope(ctx, c.Token, realm, s)
+		if err != nil {
+			if isConflict(err) {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("failed creating client scope %q in realm %s: %w", n, realm, err)
+		}
+		s.ID = &id
+		c.cache.putScope(realm, &s)
+		res.ID = id
+		res.Outcome = Created
+		res.Fields = appendFieldChange(res.Fields, "description", nil, &desc)
+		res.Fields = appendFieldChange(res.Fields, "protocol", nil, &protocol)
+		if len(spec.Mappers) > 0 {
+			names := make([]string, 0, len(spec.Mappers))
+			for _, m := range spec.Mappers {
+				names = append(names, gocloak.PString(m.Name))
+			}
+			res.Fields = appendListChange(res.Fields, "mappers", nil, &names)
+		}
+		return res, nil
+	})
+}
+
+// ClientScopeUpdate describes the changes to one client scope. Nil pointers
+// and an empty NewName leave the attribute unchanged.
+type ClientScopeUpdate struct {
+	Name        string  `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Protocol    *string `json:"protocol,omitempty"`
+	NewName     string  `json:"newName,omitempty"`
+}
+
+// UpdateClientScopesRequest updates client scopes of one realm.
+type UpdateClientScopesRequest struct {
+	Realm  string              `json:"realm,omitempty"`
+	Scopes []ClientScopeUpdate `json:"scopes,omitempty"`
+	// IgnoreMissing skips scopes that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// UpdateClientScopes applies the updates of req.
+func UpdateClientScopes(ctx context.Context, c *Client, req UpdateClientScopesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Scopes), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		upd := req.Scopes[i]
+		n := upd.Name
+		res := Result{Realm: realm, Name: n}
+		scope, err := c.ClientScopeByName(ctx, realm, n)
+		if err != nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("client scope %q not found in realm %s", n, realm)
+		}
+		before := *scope
+		if upd.Description != nil {
+			scope.Description = upd.Description
+		}
+		if upd.Protocol != nil {
+			scope.Protocol = upd.Protocol
+		}
+		if upd.NewName != "" {
+			scope.Name = &upd.NewName
+		}
+		if err := c.GC.UpdateClientScope(ctx, c.Token, realm, *scope); err != nil {
+			return res, fmt.Errorf("failed updating client scope %q in realm %s: %w", n, realm, err)
+		}
+		res.NewName = n
+		if scope.Name != nil {
+			res.NewName = *scope.Name
+		}
+		c.cache.forgetScope(realm, n)
+		c.cache.putScope(realm, scope)
+		res.ID = gocloak.PString(scope.ID)
+		res.Outcome = Updated
+		res.Before = before
+		res.Fields = appendFieldChange(res.Fields, "name", before.Name, scope.Name)
+		res.Fields = appendFieldChange(res.Fields, "description", before.Description, scope.Description)
+		res.Fields = appendFieldChange(res.Fields, "protocol", before.Protocol, scope.Protocol)
+		return res, nil
+	})
+}
+
+// DeleteClientScopesRequest deletes client scopes of one realm by name.
+type DeleteClientScopesRequest struct {
+	Realm string   `json:"realm,omitempty"`
+	Names []string `json:"names,omitempty"`
+	// IgnoreMissing skips scopes that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// DeleteClientScopes deletes the client scopes of req.
+func DeleteClientScopes(ctx context.Context, c *Client, req DeleteClientScopesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Names), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		n := req.Names[i]
+		res := Result{Realm: realm, Name: n}
+		scope, err := c.ClientScopeByName(ctx, realm, n)
+		if err != nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("client scope %q not found in realm %s", n, realm)
+		}
+		if err := c.GC.DeleteClientScope(ctx, c.Token, realm, *scope.ID); err != nil {
+			return res, fmt.Errorf("failed deleting client scope %q in realm %s: %w", n, realm, err)
+		}
+		c.cache.forgetScope(realm, n)
+		res.ID = *scope.ID
+		res.Outcome = Deleted
+		res.Before = *scope
+		return res, nil
+	})
+}
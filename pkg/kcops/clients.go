@@ -0,0 +1,461 @@
+package kcops
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// ClientSpec describes a client to create. Flow booleans are only sent when true.
+type ClientSpec struct {
+	ClientID     string `json:"clientId,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Enabled      bool   `json:"enabled"`
+	PublicClient bool   `json:"publicClient,omitempty"`
+	// Secret cannot be set through the admin API client; a warning is
+	// returned when it is given for a confidential client.
+	Secret                    string   `json:"secret,omitempty"`
+	Protocol                  string   `json:"protocol,omitempty"`
+	RootURL                   string   `json:"rootUrl,omitempty"`
+	BaseURL                   string   `json:"baseUrl,omitempty"`
+	RedirectURIs              []string `json:"redirectUris,omitempty"`
+	WebOrigins                []string `json:"webOrigins,omitempty"`
+	StandardFlowEnabled       bool     `json:"standardFlowEnabled,omitempty"`
+	DirectAccessGrantsEnabled bool     `json:"directAccessGrantsEnabled,omitempty"`
+	ImplicitFlowEnabled       bool     `json:"implicitFlowEnabled,omitempty"`
+	ServiceAccountsEnabled    bool     `json:"serviceAccountsEnabled,omitempty"`
+	// Preset names an entry of ClientPresets whose settings are applied
+	// first; the flow booleans above can only enable more flows on top.
+	Preset string `json:"preset,omitempty"`
+	// Auth sets how the client authenticates; confidential clients only.
+	Auth *ClientAuth `json:"auth,omitempty"`
+	// Attributes are set on top of those of the preset.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// ClientPreset is the configuration of a common kind of application. Unlike
+// the fields of ClientSpec, its flow settings are sent whether true or false.
+type ClientPreset struct {
+	Description        string
+	PublicClient       bool
+	StandardFlow       bool
+	DirectAccessGrants bool
+	ImplicitFlow       bool
+	ServiceAccounts    bool
+	Attributes         map[string]string
+	// WebOrigins are set when the spec lists none.
+	WebOrigins []string
+	// RemoveOptionalScopes are unassigned from the client after it is
+	// created with the default scopes of the realm.
+	RemoveOptionalScopes []string
+}
+
+// ClientPresets are the presets ClientSpec.Preset accepts.
+var ClientPresets = map[string]ClientPreset{
+	"spa": {
+		Description:          "browser app: public client, authorization code flow with PKCE, web origins of its redirect URIs, no offline tokens",
+		PublicClient:         true,
+		StandardFlow:         true,
+		Attributes:           map[string]string{"pkce.code.challenge.method": "S256"},
+		WebOrigins:           []string{"+"},
+		RemoveOptionalScopes: []string{"offline_access"},
+	},
+	"backend": {
+		Description:  "server-side web app: confidential client, authorization code flow with PKCE",
+		StandardFlow: true,
+		Attributes:   map[string]string{"pkce.code.challenge.method": "S256"},
+	},
+	"service": {
+		Description:          "machine-to-machine: confidential client with a service account (client credentials) and no user login",
+		ServiceAccounts:      true,
+		RemoveOptionalScopes: []string{"offline_access"},
+	},
+	"mobile": {
+		Description:  "native app: public client, authorization code flow with PKCE, offline tokens allowed",
+		PublicClient: true,
+		StandardFlow: true,
+		Attributes:   map[string]string{"pkce.code.challenge.method": "S256"},
+	},
+}
+
+// UnmarshalJSON defaults Enabled to true, as the CLI does, and rejects
+// unknown fields.
+func (s *ClientSpec) UnmarshalJSON(b []byte) error {
+	type plain ClientSpec
+	p := plain{Enabled: true}
+	if err := decodeStrict(b, &p); err != nil {
+		return err
+	}
+	*s = ClientSpec(p)
+	return nil
+}
+
+// CreateClientsRequest creates clients in one realm. Clients whose clientId
+// already exists are skipped.
+type CreateClientsRequest struct {
+	Realm   string       `json:"realm,omitempty"`
+	Clients []ClientSpec `json:"clients,omitempty"`
+	// Workers is the number of clients created concurrently (default 1).
+	Workers int `json:"workers,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// CreateClients creates the clients of req.
+func CreateClients(ctx context.Context, c *Client, req CreateClientsRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Clients), req.Workers, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		spec := req.Clients[i]
+		cid := spec.ClientID
+		res := Result{Realm: realm, Name: cid}
+		existing, err := c.ClientByClientID(ctx, realm, cid)
+		if err == nil && existing != nil && existing.ID != nil {
+			res.Outcome = Skipped
+			return res, nil
+		}
+
+		cl := gocloak.Client{ClientID: &cid}
+		var preset ClientPreset
+		if spec.Preset != "" {
+			p, ok := ClientPresets[spec.Preset]
+			if !ok {
+				return res, fmt.Errorf("unknown preset %q for client %q", spec.Preset, cid)
+			}
+			preset = p
+			cl.PublicClient = &preset.PublicClient
+			cl.StandardFlowEnabled = &preset.StandardFlow
+			cl.DirectAccessGrantsEnabled = &preset.DirectAccessGrants
+			cl.ImplicitFlowEnabled = &preset.ImplicitFlow
+			cl.ServiceAccountsEnabled = &preset.ServiceAccounts
+			if len(preset.Attributes) > 0 {
+				attrs := maps.Clone(preset.Attributes)
+				cl.Attributes = &attrs
+			}
+			if len(spec.WebOrigins) == 0 {
+				spec.WebOrigins = preset.WebOrigins
+			}
+		}
+		if len(spec.Attributes) > 0 {
+			attrs := maps.Clone(preset.Attributes)
+			if attrs == nil {
+				attrs = map[string]string{}
+			}
+			maps.Copy(attrs, spec.Attributes)
+			cl.Attributes = &attrs
+		}
+		if spec.Name != "" {
+			cl.Name = &spec.Name
+		}
+		cl.Enabled = &spec.Enabled
+		if spec.Preset == "" || spec.PublicClient {
+			cl.PublicClient = &spec.PublicClient
+		}
+		if spec.Protocol != "" {
+			cl.Protocol = &spec.Protocol
+		}
+		if spec.RootURL != "" {
+			cl.RootURL = &spec.RootURL
+		}
+		if spec.BaseURL != "" {
+			cl.BaseURL = &spec.BaseURL
+		}
+		if spec.StandardFlowEnabled {
+			cl.StandardFlowEnabled = &spec.StandardFlowEnabled
+		}
+		if spec.DirectAccessGrantsEnabled {
+			cl.DirectAccessGrantsEnabled = &spec.DirectAccessGrantsEnabled
+		}
+		if spec.ImplicitFlowEnabled {
+			cl.ImplicitFlowEnabled = &spec.ImplicitFlowEnabled
+		}
+		if spec.ServiceAccountsEnabled {
+			cl.ServiceAccountsEnabled = &spec.ServiceAccountsEnabled
+		}
+		if spec.Auth != nil {
+			if gocloak.PBool(cl.PublicClient) {
+				return res, fmt.Errorf("client %q is public; an auth method only applies to confidential clients", cid)
+			}
+			authType, attrs, err := spec.Auth.settings()
+			if err != nil {
+				return res, fmt.Errorf("client %q: %w", cid, err)
+			}
+			cl.ClientAuthenticatorType = &authType
+			merged := map[string]string{}
+			if cl.Attributes != nil {
+				merged = *cl.Attributes
+			}
+			maps.Copy(merged, attrs)
+			cl.Attributes = &merged
+		}
+
+		id, err := c.GC.CreateClient(ctx, c.Token, realm, cl)
+		if err != nil {
+			// if 409 already exists (rare), treat as skipped
+			if isConflict(err) {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("failed creating client %q in realm %s: %w", cid, realm, err)
+		}
+
+		// explicit secret setting is not supported by gocloak (only regenerate)
+		if spec.Secret != "" && !gocloak.PBool(cl.PublicClient) {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("Secret given for client %q but explicit secret setting is not supported. Skipped setting secret.", cid))
+		}
+		if len(spec.RedirectURIs) > 0 {
+			if err := c.GC.UpdateClient(ctx, c.Token, realm, gocloak.Client{ID: &id, RedirectURIs: &spec.RedirectURIs}); err != nil {
+				return res, fmt.Errorf("failed setting redirect URIs for client %q in realm %s: %w", cid, realm, err)
+			}
+		}
+		if len(spec.WebOrigins) > 0 {
+			if err := c.GC.UpdateClient(ctx, c.Token, realm, gocloak.Client{ID: &id, WebOrigins: &spec.WebOrigins}); err != nil {
+				return res, fmt.Errorf("failed setting web origins for client %q in realm %s: %w", cid, realm, err)
+			}
+		}
+		if len(preset.RemoveOptionalScopes) > 0 {
+			optional, err := c.GC.GetClientsOptionalScopes(ctx, c.Token, realm, id)
+			if err != nil {
+				return res, fmt.Errorf("failed reading optional scopes of client %q in realm %s: %w", cid, realm, err)
+			}
+			for _, s := range optional {
+				if !slices.Contains(preset.RemoveOptionalScopes, gocloak.PString(s.Name)) {
+					continue
+				}
+				if err := c.GC.RemoveOptionalScopeFromClient(ctx, c.Token, realm, id, gocloak.PString(s.ID)); err != nil {
+					return res, fmt.Errorf("failed removing optional scope %q from client %q in realm %s: %w", gocloak.PString(s.Name), cid, realm, err)
+				}
+			}
+		}
+
+		res.Fields = appendFieldChange(res.Fields, "name", nil, cl.Name)
+		res.Fields = appendFieldChange(res.Fields, "enabled", nil, cl.Enabled)
+		res.Fields = appendFieldChange(res.Fields, "publicClient", nil, cl.PublicClient)
+		res.Fields = appendFieldChange(res.Fields, "protocol", nil, cl.Protocol)
+		res.Fields = appendFieldChange(res.Fields, "rootUrl", nil, cl.RootURL)
+		res.Fields = appendFieldChange(res.Fields, "baseUrl", nil, cl.BaseURL)
+		res.Fields = appendFieldChange(res.Fields, "standardFlowEnabled", nil, cl.StandardFlowEnabled)
+		res.Fields = appendFieldChange(res.Fields, "directAccessGrantsEnabled", nil, cl.DirectAccessGrantsEnabled)
+		res.Fields = appendFieldChange(res.Fields, "implicitFlowEnabled", nil, cl.ImplicitFlowEnabled)
+		res.Fields = appendFieldChange(res.Fields, "serviceAccountsEnabled", nil, cl.ServiceAccountsEnabled)
+		res.Fields = appendFieldChange(res.Fields, "clientAuthenticatorType", nil, cl.ClientAuthenticatorType)
+		if cl.Attributes != nil {
+			res.Fields = appendAttributeChanges(res.Fields, nil, *cl.Attributes)
+		}
+		if len(spec.RedirectURIs) > 0 {
+			res.Fields = appendListChange(res.Fields, "redirectUris", nil, &spec.RedirectURIs)
+		}
+		if len(spec.WebOrigins) > 0 {
+			res.Fields = appendListChange(res.Fields, "webOrigins", nil, &spec.WebOrigins)
+		}
+		cl.ID = &id
+		c.cache.putClient(realm, &cl)
+		res.ID = id
+		res.Outcome = Created
+		return res, nil
+	})
+}
+
+// ClientUpdate describes the changes to one client. Nil pointers, nil lists
+// and empty strings leave the attribute unchanged.
+type ClientUpdate struct {
+	ClientID                  string   `json:"clientId,omitempty"`
+	Name                      *string  `json:"name,omitempty"`
+	Enabled                   *bool    `json:"enabled,omitempty"`
+	PublicClient              *bool    `json:"publicClient,omitempty"`
+	Protocol                  *string  `json:"protocol,omitempty"`
+	RootURL                   *string  `json:"rootUrl,omitempty"`
+	BaseURL                   *string  `json:"baseUrl,omitempty"`
+	RedirectURIs              []string `json:"redirectUris,omitempty"`
+	WebOrigins                []string `json:"webOrigins,omitempty"`
+	StandardFlowEnabled       *bool    `json:"standardFlowEnabled,omitempty"`
+	DirectAccessGrantsEnabled *bool    `json:"directAccessGrantsEnabled,omitempty"`
+	ImplicitFlowEnabled       *bool    `json:"implicitFlowEnabled,omitempty"`
+	ServiceAccountsEnabled    *bool    `json:"serviceAccountsEnabled,omitempty"`
+	// Secret cannot be set; see ClientSpec.
+	Secret      string `json:"secret,omitempty"`
+	NewClientID string `json:"newClientId,omitempty"`
+	// Auth replaces how the client authenticates; see ClientSpec.
+	Auth *ClientAuth `json:"auth,omitempty"`
+	// Set sets any other field of the client representation by path, after
+	// the fields above; see FieldSet. id and clientId cannot be set.
+	Set []FieldSet `json:"set,omitempty"`
+}
+
+// UpdateClientsRequest updates clients of one realm.
+type UpdateClientsRequest struct {
+	Realm   string         `json:"realm,omitempty"`
+	Clients []ClientUpdate `json:"clients,omitempty"`
+	// IgnoreMissing skips clients that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// UpdateClients applies the updates of req.
+func UpdateClients(ctx context.Context, c *Client, req UpdateClientsRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Clients), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		upd := req.Clients[i]
+		cid := upd.ClientID
+		res := Result{Realm: realm, Name: cid, NewName: cid}
+		cl, err := c.ClientByClientID(ctx, realm, cid)
+		if err != nil || cl == nil || cl.ID == nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("client %q not found in realm %s", cid, realm)
+		}
+		id := *cl.ID
+		before := *cl
+		if upd.Name != nil {
+			cl.Name = upd.Name
+		}
+		if upd.PublicClient != nil {
+			cl.PublicClient = upd.PublicClient
+		}
+		if upd.Enabled != nil {
+			cl.Enabled = upd.Enabled
+		}
+		if upd.Protocol != nil {
+			cl.Protocol = upd.Protocol
+		}
+		if upd.RootURL != nil {
+			cl.RootURL = upd.RootURL
+		}
+		if upd.BaseURL != nil {
+			cl.BaseURL = upd.BaseURL
+		}
+		if upd.StandardFlowEnabled != nil {
+			cl.StandardFlowEnabled = upd.StandardFlowEnabled
+		}
+		if upd.DirectAccessGrantsEnabled != nil {
+			cl.DirectAccessGrantsEnabled = upd.DirectAccessGrantsEnabled
+		}
+		if upd.ImplicitFlowEnabled != nil {
+			cl.ImplicitFlowEnabled = upd.ImplicitFlowEnabled
+		}
+		if upd.ServiceAccountsEnabled != nil {
+			cl.ServiceAccountsEnabled = upd.ServiceAccountsEnabled
+		}
+		if len(upd.RedirectURIs) > 0 {
+			cl.RedirectURIs = &upd.RedirectURIs
+		}
+		if len(upd.WebOrigins) > 0 {
+			cl.WebOrigins = &upd.WebOrigins
+		}
+		var authAttrs map[string]string
+		if upd.Auth != nil {
+			if gocloak.PBool(cl.PublicClient) {
+				return res, fmt.Errorf("client %q is public; an auth method only applies to confidential clients", cid)
+			}
+			authType, attrs, err := upd.Auth.settings()
+			if err != nil {
+				return res, fmt.Errorf("client %q: %w", cid, err)
+			}
+			cl.ClientAuthenticatorType = &authType
+			merged := map[string]string{}
+			if cl.Attributes != nil {
+				merged = maps.Clone(*cl.Attributes)
+			}
+			maps.Copy(merged, attrs)
+			cl.Attributes = &merged
+			authAttrs = attrs
+		}
+		var setFields []FieldChange
+		if len(upd.Set) > 0 {
+			for _, fs := range upd.Set {
+				if fs.Path == "id" || fs.Path == "clientId" {
+					return res, &FieldSetError{Set: fs.Path, Reason: "rename clients with NewClientID"}
+				}
+			}
+			if setFields, err = ApplyFieldSets(cl, upd.Set); err != nil {
+				return res, fmt.Errorf("client %q: %w", cid, err)
+			}
+		}
+
+		if err := c.GC.UpdateClient(ctx, c.Token, realm, *cl); err != nil {
+			return res, fmt.Errorf("failed updating client %q in realm %s: %w", cid, realm, err)
+		}
+		if upd.Secret != "" && (cl.PublicClient == nil || !*cl.PublicClient) {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("Secret given for client %q but explicit secret setting is not supported. Skipped setting secret.", cid))
+		}
+		if upd.NewClientID != "" {
+			cl.ClientID = &upd.NewClientID
+			if err := c.GC.UpdateClient(ctx, c.Token, realm, *cl); err != nil {
+				return res, fmt.Errorf("failed renaming client %q to %q in realm %s: %w", cid, upd.NewClientID, realm, err)
+			}
+			res.NewName = upd.NewClientID
+		}
+		c.cache.forgetClient(realm, cid)
+		c.cache.putClient(realm, cl)
+		res.ID = id
+		res.Outcome = Updated
+		res.Before = before
+		res.Fields = appendFieldChange(res.Fields, "clientId", before.ClientID, cl.ClientID)
+		res.Fields = appendFieldChange(res.Fields, "name", before.Name, cl.Name)
+		res.Fields = appendFieldChange(res.Fields, "enabled", before.Enabled, cl.Enabled)
+		res.Fields = appendFieldChange(res.Fields, "publicClient", before.PublicClient, cl.PublicClient)
+		res.Fields = appendFieldChange(res.Fields, "protocol", before.Protocol, cl.Protocol)
+		res.Fields = appendFieldChange(res.Fields, "rootUrl", before.RootURL, cl.RootURL)
+		res.Fields = appendFieldChange(res.Fields, "baseUrl", before.BaseURL, cl.BaseURL)
+		res.Fields = appendFieldChange(res.Fields, "standardFlowEnabled", before.StandardFlowEnabled, cl.StandardFlowEnabled)
+		res.Fields = appendFieldChange(res.Fields, "directAccessGrantsEnabled", before.DirectAccessGrantsEnabled, cl.DirectAccessGrantsEnabled)
+		res.Fields = appendFieldChange(res.Fields, "implicitFlowEnabled", before.ImplicitFlowEnabled, cl.ImplicitFlowEnabled)
+		res.Fields = appendFieldChange(res.Fields, "serviceAccountsEnabled", before.ServiceAccountsEnabled, cl.ServiceAccountsEnabled)
+		res.Fields = appendListChange(res.Fields, "redirectUris", before.RedirectURIs, cl.RedirectURIs)
+		res.Fields = appendListChange(res.Fields, "webOrigins", before.WebOrigins, cl.WebOrigins)
+		if upd.Auth != nil {
+			var oldAttrs map[string]string
+			if before.Attributes != nil {
+				oldAttrs = *before.Attributes
+			}
+			res.Fields = appendAuthChanges(res.Fields, before.ClientAuthenticatorType, oldAttrs, *cl.ClientAuthenticatorType, authAttrs)
+		}
+		// fields with a flag of their own are already listed
+		for _, f := range setFields {
+			if !slices.ContainsFunc(res.Fields, func(g FieldChange) bool { return g.Field == f.Field }) {
+				res.Fields = append(res.Fields, f)
+			}
+		}
+		return res, nil
+	})
+}
+
+// DeleteClientsRequest deletes clients of one realm by clientId.
+type DeleteClientsRequest struct {
+	Realm     string   `json:"realm,omitempty"`
+	ClientIDs []string `json:"clientIds,omitempty"`
+	// IgnoreMissing skips clients that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// DeleteClients deletes the clients of req.
+func DeleteClients(ctx context.Context, c *Client, req DeleteClientsRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.ClientIDs), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		cid := req.ClientIDs[i]
+		res := Result{Realm: realm, Name: cid}
+		cl, err := c.ClientByClientID(ctx, realm, cid)
+		if err != nil || cl == nil || cl.ID == nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("client %q not found in realm %s", cid, realm)
+		}
+		if err := c.GC.DeleteClient(ctx, c.Token, realm, *cl.ID); err != nil {
+			return res, fmt.Errorf("failed deleting client %q in realm %s: %w", cid, realm, err)
+		}
+		c.cache.forgetClient(realm, cid)
+		res.ID = *cl.ID
+		res.Outcome = Deleted
+		res.Before = *cl
+		return res, nil
+	})
+}
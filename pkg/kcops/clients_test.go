@@ -0,0 +1,216 @@
+package kcops_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestCreateClients(t *testing.T) {
+	tests := []struct {
+		name       string
+		failing    []string
+		req        kcops.CreateClientsRequest
+		want       []kcops.Outcome
+		wantErr    string
+		wantFields []kcops.FieldChange
+		wantWarn   bool
+	}{
+		{
+			name:       "creates with a preset",
+			req:        kcops.CreateClientsRequest{Clients: []kcops.ClientSpec{{ClientID: "web", Preset: "spa", Enabled: true, RedirectURIs: []string{"https://app.example.org/*"}}}},
+			want:       []kcops.Outcome{kcops.Created},
+			wantFields: []kcops.FieldChange{{Field: "publicClient", New: "true"}, {Field: "attributes.pkce.code.challenge.method", New: "S256"}, {Field: "webOrigins", New: "+"}},
+		},
+		{
+			name:     "warns about a secret",
+			req:      kcops.CreateClientsRequest{Clients: []kcops.ClientSpec{{ClientID: "api", Secret: "s3cr3t", Enabled: true}}},
+			want:     []kcops.Outcome{kcops.Created},
+			wantWarn: true,
+		},
+		{
+			name: "skips existing clients",
+			req:  kcops.CreateClientsRequest{Clients: []kcops.ClientSpec{{ClientID: "existing", Enabled: true}}},
+			want: []kcops.Outcome{kcops.Skipped},
+		},
+		{
+			name:    "rejects an unknown preset",
+			req:     kcops.CreateClientsRequest{Clients: []kcops.ClientSpec{{ClientID: "web", Preset: "desktop"}}},
+			want:    []kcops.Outcome{},
+			wantErr: `unknown preset "desktop"`,
+		},
+		{
+			name:    "reports failures with ContinueOnError",
+			failing: []string{"bad"},
+			req:     kcops.CreateClientsRequest{ContinueOnError: true, Clients: []kcops.ClientSpec{{ClientID: "bad"}, {ClientID: "good"}}},
+			want:    []kcops.Outcome{kcops.Failed, kcops.Created},
+			wantErr: kcops.ErrItemsFailed.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			if _, err := c.GC.CreateClient(ctx, c.Token, "test", gocloak.Client{ClientID: gocloak.StringP("existing")}); err != nil {
+				t.Fatal(err)
+			}
+			m.failCreate = map[string]bool{}
+			for _, n := range tt.failing {
+				m.failCreate[n] = true
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.CreateClients(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			for _, want := range tt.wantFields {
+				if got, _ := field(results[0], want.Field); got != want {
+					t.Errorf("change of %s = %+v, want %+v", want.Field, got, want)
+				}
+			}
+			if len(results) > 0 && (len(results[0].Warnings) > 0) != tt.wantWarn {
+				t.Errorf("Warnings = %v", results[0].Warnings)
+			}
+			for _, r := range results {
+				if r.Outcome != kcops.Created {
+					continue
+				}
+				cl, err := c.ClientByClientID(ctx, "test", r.Name)
+				if err != nil || gocloak.PString(cl.ID) != r.ID {
+					t.Errorf("client %q was not created with ID %s: %v", r.Name, r.ID, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateClients(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         kcops.UpdateClientsRequest
+		want        []kcops.Outcome
+		wantErr     string
+		wantNewName string
+		wantFields  []kcops.FieldChange
+	}{
+		{
+			name:        "sets fields by path",
+			req:         kcops.UpdateClientsRequest{Clients: []kcops.ClientUpdate{{ClientID: "api", Set: []kcops.FieldSet{{Path: "attributes.frontchannel.logout", Value: "true"}, {Path: "bearerOnly", Value: "true"}}}}},
+			want:        []kcops.Outcome{kcops.Updated},
+			wantNewName: "api",
+			wantFields:  []kcops.FieldChange{{Field: "attributes.frontchannel.logout", New: "true"}, {Field: "bearerOnly", New: "true"}},
+		},
+		{
+			name:        "renames",
+			req:         kcops.UpdateClientsRequest{Clients: []kcops.ClientUpdate{{ClientID: "api", NewClientID: "backend", Enabled: gocloak.BoolP(false)}}},
+			want:        []kcops.Outcome{kcops.Updated},
+			wantNewName: "backend",
+			wantFields:  []kcops.FieldChange{{Field: "clientId", Old: "api", New: "backend"}, {Field: "enabled", Old: "true", New: "false"}},
+		},
+		{
+			name:    "rejects setting the clientId",
+			req:     kcops.UpdateClientsRequest{Clients: []kcops.ClientUpdate{{ClientID: "api", Set: []kcops.FieldSet{{Path: "clientId", Value: "other"}}}}},
+			want:    []kcops.Outcome{},
+			wantErr: "rename clients with NewClientID",
+		},
+		{
+			name:    "rejects an unknown field",
+			req:     kcops.UpdateClientsRequest{Clients: []kcops.ClientUpdate{{ClientID: "api", Set: []kcops.FieldSet{{Path: "frontchannelLogoutt", Value: "true"}}}}},
+			want:    []kcops.Outcome{},
+			wantErr: "no such field",
+		},
+		{
+			name: "skips a missing client with IgnoreMissing",
+			req:  kcops.UpdateClientsRequest{IgnoreMissing: true, Clients: []kcops.ClientUpdate{{ClientID: "nobody", Enabled: gocloak.BoolP(false)}}},
+			want: []kcops.Outcome{kcops.Skipped},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := newClient(t)
+			if _, err := c.GC.CreateClient(ctx, c.Token, "test", gocloak.Client{ClientID: gocloak.StringP("api"), Enabled: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.UpdateClients(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			if tt.wantNewName == "" {
+				return
+			}
+			r := results[0]
+			if r.NewName != tt.wantNewName {
+				t.Errorf("NewName = %q, want %q", r.NewName, tt.wantNewName)
+			}
+			if !slices.Equal(r.Fields, tt.wantFields) {
+				t.Errorf("Fields = %+v, want %+v", r.Fields, tt.wantFields)
+			}
+			clients, err := c.GC.GetClients(ctx, c.Token, "test", gocloak.GetClientsParams{ClientID: &tt.wantNewName})
+			if err != nil || len(clients) != 1 {
+				t.Fatalf("client %q: %v %v", tt.wantNewName, clients, err)
+			}
+		})
+	}
+}
+
+func TestDeleteClients(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        kcops.DeleteClientsRequest
+		failDelete bool
+		want       []kcops.Outcome
+		wantErr    string
+	}{
+		{
+			name: "deletes",
+			req:  kcops.DeleteClientsRequest{ClientIDs: []string{"api"}},
+			want: []kcops.Outcome{kcops.Deleted},
+		},
+		{
+			name:    "fails on a missing client",
+			req:     kcops.DeleteClientsRequest{ClientIDs: []string{"nobody"}},
+			want:    []kcops.Outcome{},
+			wantErr: `client "nobody" not found in realm test`,
+		},
+		{
+			name: "skips a missing client with IgnoreMissing",
+			req:  kcops.DeleteClientsRequest{IgnoreMissing: true, ClientIDs: []string{"nobody", "api"}},
+			want: []kcops.Outcome{kcops.Skipped, kcops.Deleted},
+		},
+		{
+			name:       "reports failures with ContinueOnError",
+			req:        kcops.DeleteClientsRequest{ContinueOnError: true, ClientIDs: []string{"api"}},
+			failDelete: true,
+			want:       []kcops.Outcome{kcops.Failed},
+			wantErr:    kcops.ErrItemsFailed.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			if _, err := c.GC.CreateClient(ctx, c.Token, "test", gocloak.Client{ClientID: gocloak.StringP("api")}); err != nil {
+				t.Fatal(err)
+			}
+			m.failDelete = tt.failDelete
+			tt.req.Realm = "test"
+			results, err := kcops.DeleteClients(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			clients, _ := c.GC.GetClients(ctx, c.Token, "test", gocloak.GetClientsParams{ClientID: gocloak.StringP("api")})
+			if deleted := len(clients) == 0; deleted != slices.Contains(outcomes(results), kcops.Deleted) {
+				t.Errorf("client api deleted = %v, results %v", deleted, outcomes(results))
+			}
+		})
+	}
+}
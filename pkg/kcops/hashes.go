@@ -0,0 +1,95 @@
+package kcops
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// pbkdf2Algorithms are the PBKDF2 hash providers built into Keycloak.
+var pbkdf2Algorithms = []string{"pbkdf2", "pbkdf2-sha256", "pbkdf2-sha512"}
+
+// hashedCredential returns the password credential of a user whose password
+// was hashed elsewhere, e.g. by a legacy system, and the hash algorithm.
+// Keycloak stores it as is, so the user keeps the password. It returns nil
+// when spec holds no hash.
+func hashedCredential(spec UserSpec) (*gocloak.CredentialRepresentation, string, error) {
+	credData, secretData := spec.CredentialData, spec.SecretData
+	switch {
+	case spec.PasswordHash != "" && (credData != "" || secretData != ""):
+		return nil, "", errors.New("passwordHash cannot be combined with credentialData and secretData")
+	case spec.PasswordHash != "":
+		var err error
+		if credData, secretData, err = parsePasswordHash(spec.PasswordHash); err != nil {
+			return nil, "", fmt.Errorf("invalid password hash: %w", err)
+		}
+	case credData == "" && secretData == "":
+		return nil, "", nil
+	case credData == "" || secretData == "":
+		return nil, "", errors.New("credentialData and secretData must be given together")
+	}
+	var cd struct {
+		Algorithm      string `json:"algorithm"`
+		HashIterations *int   `json:"hashIterations"`
+	}
+	if err := json.Unmarshal([]byte(credData), &cd); err != nil || cd.Algorithm == "" || cd.HashIterations == nil {
+		return nil, "", errors.New(`invalid credentialData: expected a JSON object with "algorithm" and "hashIterations"`)
+	}
+	var sd struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(secretData), &sd); err != nil || sd.Value == "" {
+		return nil, "", errors.New(`invalid secretData: expected a JSON object with "value" and "salt"`)
+	}
+	return &gocloak.CredentialRepresentation{
+		Type:           gocloak.StringP("password"),
+		Temporary:      gocloak.BoolP(false),
+		CredentialData: &credData,
+		SecretData:     &secretData,
+	}, cd.Algorithm, nil
+}
+
+// parsePasswordHash converts a bcrypt hash ($2a$, $2b$ or $2y$) or a PBKDF2
+// hash written algorithm:iterations:salt:hash, with a base64 salt and hash,
+// into the credentialData and secretData of Keycloak.
+func parsePasswordHash(s string) (credData, secretData string, err error) {
+	algorithm, iterations, salt, value := "", 0, "", ""
+	if strings.HasPrefix(s, "$2") {
+		// $2b$<cost>$<22 chars of salt><31 chars of hash>
+		parts := strings.Split(s, "$")
+		if len(parts) != 4 || !slices.Contains([]string{"2a", "2b", "2y"}, parts[1]) || len(parts[3]) != 53 {
+			return "", "", errors.New("expected a bcrypt hash like $2b$10$ followed by 53 characters")
+		}
+		if iterations, err = strconv.Atoi(parts[2]); err != nil || iterations < 4 || iterations > 31 {
+			return "", "", fmt.Errorf("invalid bcrypt cost %q", parts[2])
+		}
+		// the bcrypt provider reads the salt from the hash itself
+		algorithm, value = "bcrypt", s
+	} else {
+		parts := strings.Split(s, ":")
+		if len(parts) != 4 {
+			return "", "", errors.New("expected a bcrypt hash or algorithm:iterations:salt:hash")
+		}
+		algorithm, salt, value = parts[0], parts[2], parts[3]
+		if !slices.Contains(pbkdf2Algorithms, algorithm) {
+			return "", "", fmt.Errorf("unknown algorithm %q (valid: bcrypt, %s)", algorithm, strings.Join(pbkdf2Algorithms, ", "))
+		}
+		if iterations, err = strconv.Atoi(parts[1]); err != nil || iterations < 1 {
+			return "", "", fmt.Errorf("invalid iterations %q", parts[1])
+		}
+		for _, v := range []string{salt, value} {
+			if _, err := base64.StdEncoding.DecodeString(v); err != nil || v == "" {
+				return "", "", errors.New("salt and hash must be standard base64")
+			}
+		}
+	}
+	cd, _ := json.Marshal(map[string]any{"algorithm": algorithm, "hashIterations": iterations, "additionalParameters": map[string]any{}})
+	sd, _ := json.Marshal(map[string]any{"value": value, "salt": salt, "additionalParameters": map[string]any{}})
+	return string(cd), string(sd), nil
+}
@@ -0,0 +1,168 @@
+// Package kcops exposes the provisioning logic of kc as a Go API, so other
+// tools can create, update and delete Keycloak users, roles, clients and client
+// scopes without shelling out to the binary.
+//
+// Every operation takes a typed request for one realm and returns one Result per
+// item, in request order. When an item fails, the results of the items that
+// completed are returned together with the error. Requests with
+// ContinueOnError go on with the remaining items instead, report the failures
+// as results with Outcome Failed and return an error wrapping ErrItemsFailed.
+package kcops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// API is the part of the Keycloak admin API used by kcops. *gocloak.GoCloak
+// implements it, and so can an in-memory fake in tests.
+type API interface {
+	GetRealms(ctx context.Context, token string) ([]*gocloak.RealmRepresentation, error)
+	GetRealm(ctx context.Context, token, realm string) (*gocloak.RealmRepresentation, error)
+
+	GetUsers(ctx context.Context, token, realm string, params gocloak.GetUsersParams) ([]*gocloak.User, error)
+	CreateUser(ctx context.Context, token, realm string, user gocloak.User) (string, error)
+	UpdateUser(ctx context.Context, token, realm string, user gocloak.User) error
+	DeleteUser(ctx context.Context, token, realm, userID string) error
+	SetPassword(ctx context.Context, token, userID, realm, password string, temporary bool) error
+
+	GetRealmRole(ctx context.Context, token, realm, roleName string) (*gocloak.Role, error)
+	CreateRealmRole(ctx context.Context, token, realm string, role gocloak.Role) (string, error)
+	UpdateRealmRole(ctx context.Context, token, realm, roleName string, role gocloak.Role) error
+	DeleteRealmRole(ctx context.Context, token, realm, roleName string) error
+	AddRealmRoleToUser(ctx context.Context, token, realm, userID string, roles []gocloak.Role) error
+
+	GetClients(ctx context.Context, token, realm string, params gocloak.GetClientsParams) ([]*gocloak.Client, error)
+	CreateClient(ctx context.Context, token, realm string, client gocloak.Client) (string, error)
+	UpdateClient(ctx context.Context, token, realm string, client gocloak.Client) error
+	DeleteClient(ctx context.Context, token, realm, idOfClient string) error
+	GetClientRole(ctx context.Context, token, realm, idOfClient, roleName string) (*gocloak.Role, error)
+	AddClientRoleToUser(ctx context.Context, token, realm, idOfClient, userID string, roles []gocloak.Role) error
+	GetClientsOptionalScopes(ctx context.Context, token, realm, idOfClient string) ([]*gocloak.ClientScope, error)
+	RemoveOptionalScopeFromClient(ctx context.Context, token, realm, idOfClient, scopeID string) error
+
+	GetClientScopes(ctx context.Context, token, realm string) ([]*gocloak.ClientScope, error)
+	CreateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) (string, error)
+	UpdateClientScope(ctx context.Context, token, realm string, scope gocloak.ClientScope) error
+	DeleteClientScope(ctx context.Context, token, realm, scopeID string) error
+}
+
+// Client is an authenticated admin API session. Realm, client and client
+// scope lookups are cached for its lifetime, so create one per unit of work.
+type Client struct {
+	GC    API
+	Token string
+
+	cache lookupCache
+}
+
+// NewClient wraps an authenticated admin API client and its admin token.
+func NewClient(gc API, token string) *Client {
+	return &Client{GC: gc, Token: token}
+}
+
+// Outcome is what an operation did with one item.
+type Outcome string
+
+const (
+	Created Outcome = "created"
+	Updated Outcome = "updated"
+	Deleted Outcome = "deleted"
+	// Skipped items already existed (create) or were missing (update and
+	// delete with IgnoreMissing).
+	Skipped Outcome = "skipped"
+	// Failed items could not be processed; Result.Error says why. Only
+	// reported for requests with ContinueOnError.
+	Failed Outcome = "failed"
+)
+
+// ErrItemsFailed is wrapped by the error of a ContinueOnError request in
+// which some items failed.
+var ErrItemsFailed = errors.New("some items failed")
+
+// FieldChange is an attribute changed by an operation.
+type FieldChange struct {
+	Field string `json:"field,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// Result describes what happened to one item of a request.
+type Result struct {
+	Realm string `json:"realm,omitempty"`
+	// Name is the username, role name, clientId or client scope name as given
+	// in the request.
+	Name    string  `json:"name,omitempty"`
+	ID      string  `json:"id,omitempty"`
+	Outcome Outcome `json:"outcome,omitempty"`
+	// Fields lists the attributes set or changed. Passwords are included as
+	// given; redact them before logging.
+	Fields []FieldChange `json:"fields,omitempty"`
+	// Before is the representation read before an update or delete
+	// (gocloak.User, gocloak.Role, gocloak.Client or gocloak.ClientScope).
+	Before interface{} `json:"before,omitempty"`
+	// NewName is the name after an update; equal to Name unless renamed.
+	NewName string `json:"newName,omitempty"`
+	// Password is the password set on a user, and PasswordGenerated tells
+	// whether it was generated because none was given.
+	Password          string `json:"password,omitempty"`
+	PasswordGenerated bool   `json:"passwordGenerated,omitempty"`
+	// Warnings are non-fatal problems, e.g. a client secret that was not set.
+	Warnings []string `json:"warnings,omitempty"`
+	// Error is why a Failed item failed.
+	Error string `json:"error,omitempty"`
+}
+
+// decodeStrict is json.Unmarshal rejecting unknown fields. Custom
+// unmarshalers need it because a decoder's DisallowUnknownFields does not
+// reach into them.
+func decodeStrict(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "404")
+}
+
+func isConflict(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "409")
+}
+
+// appendFieldChange appends a field change when newVal is set and differs from oldVal.
+func appendFieldChange[T comparable](fields []FieldChange, field string, oldVal, newVal *T) []FieldChange {
+	if newVal == nil {
+		return fields
+	}
+	old := ""
+	if oldVal != nil {
+		if *oldVal == *newVal {
+			return fields
+		}
+		old = fmt.Sprint(*oldVal)
+	}
+	return append(fields, FieldChange{Field: field, Old: old, New: fmt.Sprint(*newVal)})
+}
+
+// appendListChange is appendFieldChange for string lists such as redirect URIs.
+func appendListChange(fields []FieldChange, field string, oldVal, newVal *[]string) []FieldChange {
+	if newVal == nil {
+		return fields
+	}
+	old := ""
+	if oldVal != nil {
+		old = strings.Join(*oldVal, ",")
+	}
+	nv := strings.Join(*newVal, ",")
+	if oldVal != nil && old == nv {
+		return fields
+	}
+	return append(fields, FieldChange{Field: field, Old: old, New: nv})
+}
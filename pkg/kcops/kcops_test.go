@@ -0,0 +1,112 @@
+package kcops_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"kc/internal/keycloak"
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// mockAPI is the in-memory Keycloak with calls that fail on demand, for the
+// error paths of the operations.
+type mockAPI struct {
+	kcops.API
+	// failCreate fails CreateUser, CreateRealmRole and CreateClient for
+	// these names.
+	failCreate map[string]bool
+	// failDelete fails DeleteUser, DeleteRealmRole and DeleteClient.
+	failDelete bool
+}
+
+var errMock = errors.New("500 Internal Server Error: mock failure")
+
+func (m *mockAPI) CreateUser(ctx context.Context, token, realm string, user gocloak.User) (string, error) {
+	if m.failCreate[gocloak.PString(user.Username)] {
+		return "", errMock
+	}
+	return m.API.CreateUser(ctx, token, realm, user)
+}
+
+func (m *mockAPI) DeleteUser(ctx context.Context, token, realm, userID string) error {
+	if m.failDelete {
+		return errMock
+	}
+	return m.API.DeleteUser(ctx, token, realm, userID)
+}
+
+func (m *mockAPI) CreateRealmRole(ctx context.Context, token, realm string, role gocloak.Role) (string, error) {
+	if m.failCreate[gocloak.PString(role.Name)] {
+		return "", errMock
+	}
+	return m.API.CreateRealmRole(ctx, token, realm, role)
+}
+
+func (m *mockAPI) DeleteRealmRole(ctx context.Context, token, realm, roleName string) error {
+	if m.failDelete {
+		return errMock
+	}
+	return m.API.DeleteRealmRole(ctx, token, realm, roleName)
+}
+
+func (m *mockAPI) CreateClient(ctx context.Context, token, realm string, client gocloak.Client) (string, error) {
+	if m.failCreate[gocloak.PString(client.ClientID)] {
+		return "", errMock
+	}
+	return m.API.CreateClient(ctx, token, realm, client)
+}
+
+func (m *mockAPI) DeleteClient(ctx context.Context, token, realm, idOfClient string) error {
+	if m.failDelete {
+		return errMock
+	}
+	return m.API.DeleteClient(ctx, token, realm, idOfClient)
+}
+
+// newClient returns a session on a fresh Fake with the realm "test", and the
+// mock in front of it.
+func newClient(t *testing.T) (*kcops.Client, *mockAPI) {
+	t.Helper()
+	f := keycloak.NewFake()
+	if _, err := f.CreateRealm(context.Background(), "", gocloak.RealmRepresentation{Realm: gocloak.StringP("test"), Enabled: gocloak.BoolP(true)}); err != nil {
+		t.Fatal(err)
+	}
+	m := &mockAPI{API: f}
+	return kcops.NewClient(m, "token"), m
+}
+
+// outcomes lists the outcome of every result, in order.
+func outcomes(results []kcops.Result) []kcops.Outcome {
+	out := make([]kcops.Outcome, len(results))
+	for i, r := range results {
+		out[i] = r.Outcome
+	}
+	return out
+}
+
+// checkErr fails t unless err contains want, or is nil when want is empty.
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Fatalf("expected an error containing %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Fatalf("error %q does not contain %q", err, want)
+	}
+}
+
+// field returns the change of name in r, if any.
+func field(r kcops.Result, name string) (kcops.FieldChange, bool) {
+	for _, f := range r.Fields {
+		if f.Field == name {
+			return f, true
+		}
+	}
+	return kcops.FieldChange{}, false
+}
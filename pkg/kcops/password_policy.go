@@ -0,0 +1,202 @@
+package kcops
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PasswordPolicy holds the rules of the password policy of a realm that kc
+// can check before sending a password, so a rejected password gets a precise
+// error instead of the bare 400 of Keycloak. Rules that need the server, such
+// as passwordHistory or passwordBlacklist, are left to Keycloak. Zero values
+// are rules the policy does not set.
+type PasswordPolicy struct {
+	Length              int
+	MaxLength           int
+	Digits              int
+	LowerCase           int
+	UpperCase           int
+	SpecialChars        int
+	NotUsername         bool
+	NotEmail            bool
+	NotContainsUsername bool
+	// Pattern must match the whole password (regexPattern). Patterns that
+	// are not valid Go regular expressions are left to Keycloak.
+	Pattern *regexp.Regexp
+}
+
+// ParsePasswordPolicy parses the passwordPolicy of a realm representation,
+// e.g. "length(12) and digits(2) and notUsername(undefined)". Rules without a
+// number take the default of Keycloak.
+func ParsePasswordPolicy(s string) PasswordPolicy {
+	var p PasswordPolicy
+	for _, rule := range strings.Split(s, " and ") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "(")
+		arg = strings.TrimSuffix(arg, ")")
+		n := func(def int) int {
+			if v, err := strconv.Atoi(arg); err == nil {
+				return v
+			}
+			return def
+		}
+		switch name {
+		case "length":
+			p.Length = n(8)
+		case "maxLength":
+			p.MaxLength = n(64)
+		case "digits":
+			p.Digits = n(1)
+		case "lowerCase":
+			p.LowerCase = n(1)
+		case "upperCase":
+			p.UpperCase = n(1)
+		case "specialChars":
+			p.SpecialChars = n(1)
+		case "notUsername":
+			p.NotUsername = true
+		case "notEmail":
+			p.NotEmail = true
+		case "notContainsUsername":
+			p.NotContainsUsername = true
+		case "regexPattern":
+			if re, err := regexp.Compile("^(?:" + arg + ")$"); err == nil {
+				p.Pattern = re
+			}
+		}
+	}
+	return p
+}
+
+// RealmPasswordPolicy reads the password policy of realm.
+func RealmPasswordPolicy(ctx context.Context, c *Client, realm string) (PasswordPolicy, error) {
+	rep, err := c.GC.GetRealm(ctx, c.Token, realm)
+	if err != nil {
+		return PasswordPolicy{}, fmt.Errorf("failed reading the password policy of realm %s: %w", realm, err)
+	}
+	if rep.PasswordPolicy == nil {
+		return PasswordPolicy{}, nil
+	}
+	return ParsePasswordPolicy(*rep.PasswordPolicy), nil
+}
+
+// Validate checks pw, the password of the user with username and email,
+// against the policy. The error lists every rule pw breaks.
+func (p PasswordPolicy) Validate(pw, username, email string) error {
+	var lower, upper, digits, special int
+	for _, r := range pw {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.IsLower(r):
+			lower++
+		case unicode.IsUpper(r):
+			upper++
+		case !unicode.IsLetter(r):
+			special++
+		}
+	}
+	var broken []string
+	if n := utf8.RuneCountInString(pw); p.Length > 0 && n < p.Length {
+		broken = append(broken, fmt.Sprintf("at least %d characters (has %d)", p.Length, n))
+	} else if p.MaxLength > 0 && n > p.MaxLength {
+		broken = append(broken, fmt.Sprintf("at most %d characters (has %d)", p.MaxLength, n))
+	}
+	for _, c := range []struct {
+		want, has int
+		what      string
+	}{{p.Digits, digits, "digit(s)"}, {p.LowerCase, lower, "lowercase letter(s)"}, {p.UpperCase, upper, "uppercase letter(s)"}, {p.SpecialChars, special, "special character(s)"}} {
+		if c.has < c.want {
+			broken = append(broken, fmt.Sprintf("at least %d %s (has %d)", c.want, c.what, c.has))
+		}
+	}
+	if p.NotUsername && username != "" && strings.EqualFold(pw, username) {
+		broken = append(broken, "not the username")
+	}
+	if p.NotContainsUsername && username != "" && strings.Contains(strings.ToLower(pw), strings.ToLower(username)) {
+		broken = append(broken, "not containing the username")
+	}
+	if p.NotEmail && email != "" && strings.EqualFold(pw, email) {
+		broken = append(broken, "not the email")
+	}
+	if p.Pattern != nil && !p.Pattern.MatchString(pw) {
+		broken = append(broken, fmt.Sprintf("matching %s", strings.TrimSuffix(strings.TrimPrefix(p.Pattern.String(), "^(?:"), ")$")))
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("the password policy of the realm requires %s", strings.Join(broken, ", "))
+	}
+	return nil
+}
+
+// ErrPasswordNotGenerated is returned by Generate when no random password
+// passes the policy, e.g. one that forbids every character class; the
+// password has to be given instead.
+var ErrPasswordNotGenerated = errors.New("cannot generate a password passing the password policy of the realm")
+
+// Generate returns a random password of at least 12 characters that passes
+// ValidatePassword and the policy, or ErrPasswordNotGenerated.
+func (p PasswordPolicy) Generate(username, email string) (string, error) {
+	const lower = "abcdefghijklmnopqrstuvwxyz"
+	const upper = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	const digits = "0123456789"
+	const specials = "!@#$%^&*()-_=+[]{}|;:,.<>/?"
+	const all = lower + upper + digits + specials
+
+	// at least one of each kind, for ValidatePassword
+	required := []struct {
+		pool string
+		n    int
+	}{{lower, max(p.LowerCase, 1)}, {upper, max(p.UpperCase, 1)}, {digits, max(p.Digits, 1)}, {specials, max(p.SpecialChars, 1)}}
+	n := max(12, p.Length)
+	for _, r := range required {
+		n -= r.n
+	}
+	n = max(n, 0)
+	pick := func(pool string) (byte, error) {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(pool))))
+		if err != nil {
+			return 0, err
+		}
+		return pool[idx.Int64()], nil
+	}
+	// a username inside the password or a pattern may still fail it by
+	// chance; try again a few times
+	for range 20 {
+		var b []byte
+		for _, r := range required {
+			for range r.n {
+				c, err := pick(r.pool)
+				if err != nil {
+					return "", err
+				}
+				b = append(b, c)
+			}
+		}
+		for range n {
+			c, err := pick(all)
+			if err != nil {
+				return "", err
+			}
+			b = append(b, c)
+		}
+		for i := len(b) - 1; i > 0; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+			b[i], b[j.Int64()] = b[j.Int64()], b[i]
+		}
+		pw := string(b)
+		if ValidatePassword(pw) == nil && p.Validate(pw, username, email) == nil {
+			return pw, nil
+		}
+	}
+	return "", ErrPasswordNotGenerated
+}
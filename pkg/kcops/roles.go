@@ -0,0 +1,144 @@
+package kcops
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// RoleSpec describes a realm role to create.
+type RoleSpec struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// CreateRolesRequest creates realm roles in one realm. Roles that already
+// exist are skipped.
+type CreateRolesRequest struct {
+	Realm string     `json:"realm,omitempty"`
+	Roles []RoleSpec `json:"roles,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// CreateRoles creates the realm roles of req.
+func CreateRoles(ctx context.Context, c *Client, req CreateRolesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Roles), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		spec := req.Roles[i]
+		rn := spec.Name
+		res := Result{Realm: realm, Name: rn}
+		_, err := c.GC.GetRealmRole(ctx, c.Token, realm, rn)
+		if err == nil {
+			res.Outcome = Skipped
+			return res, nil
+		}
+		if !isNotFound(err) {
+			return res, fmt.Errorf("failed checking role in realm %s: %w", realm, err)
+		}
+		desc := spec.Description
+		if _, err := c.GC.CreateRealmRole(ctx, c.Token, realm, gocloak.Role{Name: &rn, Description: &desc}); err != nil {
+			return res, fmt.Errorf("failed creating role %q in realm %s: %w", rn, realm, err)
+		}
+		res.Outcome = Created
+		res.Fields = appendFieldChange(nil, "description", nil, &desc)
+		return res, nil
+	})
+}
+
+// RoleUpdate describes the changes to one realm role. A nil Description and
+// an empty NewName leave the attribute unchanged.
+type RoleUpdate struct {
+	Name        string  `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	NewName     string  `json:"newName,omitempty"`
+}
+
+// UpdateRolesRequest updates realm roles of one realm.
+type UpdateRolesRequest struct {
+	Realm string       `json:"realm,omitempty"`
+	Roles []RoleUpdate `json:"roles,omitempty"`
+	// IgnoreMissing skips roles that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// UpdateRoles applies the updates of req.
+func UpdateRoles(ctx context.Context, c *Client, req UpdateRolesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Roles), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		upd := req.Roles[i]
+		rn := upd.Name
+		res := Result{Realm: realm, Name: rn}
+		role, err := c.GC.GetRealmRole(ctx, c.Token, realm, rn)
+		if err != nil {
+			if isNotFound(err) {
+				if req.IgnoreMissing {
+					res.Outcome = Skipped
+					return res, nil
+				}
+				return res, fmt.Errorf("role %q not found in realm %s", rn, realm)
+			}
+			return res, fmt.Errorf("failed fetching role %q in realm %s: %w", rn, realm, err)
+		}
+		before := *role
+		if upd.Description != nil {
+			role.Description = upd.Description
+		}
+		if upd.NewName != "" {
+			role.Name = &upd.NewName
+		}
+		if err := c.GC.UpdateRealmRole(ctx, c.Token, realm, rn, *role); err != nil {
+			return res, fmt.Errorf("failed updating role %q in realm %s: %w", rn, realm, err)
+		}
+		res.NewName = rn
+		if role.Name != nil {
+			res.NewName = *role.Name
+		}
+		res.ID = gocloak.PString(role.ID)
+		res.Outcome = Updated
+		res.Before = before
+		res.Fields = appendFieldChange(res.Fields, "name", before.Name, role.Name)
+		res.Fields = appendFieldChange(res.Fields, "description", before.Description, role.Description)
+		return res, nil
+	})
+}
+
+// DeleteRolesRequest deletes realm roles of one realm by name.
+type DeleteRolesRequest struct {
+	Realm string   `json:"realm,omitempty"`
+	Names []string `json:"names,omitempty"`
+	// IgnoreMissing skips roles that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// DeleteRoles deletes the realm roles of req.
+func DeleteRoles(ctx context.Context, c *Client, req DeleteRolesRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Names), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		rn := req.Names[i]
+		res := Result{Realm: realm, Name: rn}
+		// kept as Before; a missing role is reported by the delete below
+		prev, _ := c.GC.GetRealmRole(ctx, c.Token, realm, rn)
+		if err := c.GC.DeleteRealmRole(ctx, c.Token, realm, rn); err != nil {
+			if isNotFound(err) {
+				if req.IgnoreMissing {
+					res.Outcome = Skipped
+					return res, nil
+				}
+				return res, fmt.Errorf("role %q not found in realm %s", rn, realm)
+			}
+			return res, fmt.Errorf("failed deleting role %q in realm %s: %w", rn, realm, err)
+		}
+		if prev != nil {
+			res.ID = gocloak.PString(prev.ID)
+			res.Before = *prev
+		}
+		res.Outcome = Deleted
+		return res, nil
+	})
+}
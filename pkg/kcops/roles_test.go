@@ -0,0 +1,193 @@
+package kcops_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestCreateRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing []string
+		req     kcops.CreateRolesRequest
+		want    []kcops.Outcome
+		wantErr string
+	}{
+		{
+			name: "creates and skips existing",
+			req:  kcops.CreateRolesRequest{Roles: []kcops.RoleSpec{{Name: "viewer", Description: "Read only"}, {Name: "admin"}}},
+			want: []kcops.Outcome{kcops.Created, kcops.Skipped},
+		},
+		{
+			name:    "stops at the first failure",
+			failing: []string{"editor"},
+			req:     kcops.CreateRolesRequest{Roles: []kcops.RoleSpec{{Name: "editor"}, {Name: "viewer"}}},
+			want:    []kcops.Outcome{},
+			wantErr: `failed creating role "editor"`,
+		},
+		{
+			name:    "reports failures with ContinueOnError",
+			failing: []string{"editor"},
+			req:     kcops.CreateRolesRequest{ContinueOnError: true, Roles: []kcops.RoleSpec{{Name: "editor"}, {Name: "viewer"}}},
+			want:    []kcops.Outcome{kcops.Failed, kcops.Created},
+			wantErr: "1 of 2 item(s) failed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			if _, err := c.GC.CreateRealmRole(ctx, c.Token, "test", gocloak.Role{Name: gocloak.StringP("admin")}); err != nil {
+				t.Fatal(err)
+			}
+			m.failCreate = map[string]bool{}
+			for _, n := range tt.failing {
+				m.failCreate[n] = true
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.CreateRoles(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			for _, r := range results {
+				_, err := c.GC.GetRealmRole(ctx, c.Token, "test", r.Name)
+				if exists := err == nil; exists != (r.Outcome != kcops.Failed) {
+					t.Errorf("role %q: exists = %v after %s", r.Name, exists, r.Outcome)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRoles(t *testing.T) {
+	tests := []struct {
+		name        string
+		req         kcops.UpdateRolesRequest
+		want        []kcops.Outcome
+		wantErr     string
+		wantNewName string
+		wantFields  []kcops.FieldChange
+	}{
+		{
+			name:        "changes the description",
+			req:         kcops.UpdateRolesRequest{Roles: []kcops.RoleUpdate{{Name: "viewer", Description: gocloak.StringP("Read everything")}}},
+			want:        []kcops.Outcome{kcops.Updated},
+			wantNewName: "viewer",
+			wantFields:  []kcops.FieldChange{{Field: "description", Old: "Read only", New: "Read everything"}},
+		},
+		{
+			name:        "renames",
+			req:         kcops.UpdateRolesRequest{Roles: []kcops.RoleUpdate{{Name: "viewer", NewName: "reader"}}},
+			want:        []kcops.Outcome{kcops.Updated},
+			wantNewName: "reader",
+			wantFields:  []kcops.FieldChange{{Field: "name", Old: "viewer", New: "reader"}},
+		},
+		{
+			name:    "fails on a missing role",
+			req:     kcops.UpdateRolesRequest{Roles: []kcops.RoleUpdate{{Name: "nobody", NewName: "x"}}},
+			want:    []kcops.Outcome{},
+			wantErr: `role "nobody" not found in realm test`,
+		},
+		{
+			name: "skips a missing role with IgnoreMissing",
+			req:  kcops.UpdateRolesRequest{IgnoreMissing: true, Roles: []kcops.RoleUpdate{{Name: "nobody", NewName: "x"}}},
+			want: []kcops.Outcome{kcops.Skipped},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := newClient(t)
+			if _, err := c.GC.CreateRealmRole(ctx, c.Token, "test", gocloak.Role{Name: gocloak.StringP("viewer"), Description: gocloak.StringP("Read only")}); err != nil {
+				t.Fatal(err)
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.UpdateRoles(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			if tt.wantNewName == "" {
+				return
+			}
+			r := results[0]
+			if r.NewName != tt.wantNewName {
+				t.Errorf("NewName = %q, want %q", r.NewName, tt.wantNewName)
+			}
+			if !slices.Equal(r.Fields, tt.wantFields) {
+				t.Errorf("Fields = %+v, want %+v", r.Fields, tt.wantFields)
+			}
+			if _, err := c.GC.GetRealmRole(ctx, c.Token, "test", tt.wantNewName); err != nil {
+				t.Errorf("role %q: %v", tt.wantNewName, err)
+			}
+		})
+	}
+}
+
+func TestDeleteRoles(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        kcops.DeleteRolesRequest
+		failDelete bool
+		want       []kcops.Outcome
+		wantErr    string
+	}{
+		{
+			name: "deletes",
+			req:  kcops.DeleteRolesRequest{Names: []string{"viewer"}},
+			want: []kcops.Outcome{kcops.Deleted},
+		},
+		{
+			name:    "fails on a missing role",
+			req:     kcops.DeleteRolesRequest{Names: []string{"nobody", "viewer"}},
+			want:    []kcops.Outcome{},
+			wantErr: `role "nobody" not found in realm test`,
+		},
+		{
+			name: "skips a missing role with IgnoreMissing",
+			req:  kcops.DeleteRolesRequest{IgnoreMissing: true, Names: []string{"nobody", "viewer"}},
+			want: []kcops.Outcome{kcops.Skipped, kcops.Deleted},
+		},
+		{
+			name:       "reports a failed delete",
+			req:        kcops.DeleteRolesRequest{Names: []string{"viewer"}},
+			failDelete: true,
+			want:       []kcops.Outcome{},
+			wantErr:    `failed deleting role "viewer"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			if _, err := c.GC.CreateRealmRole(ctx, c.Token, "test", gocloak.Role{Name: gocloak.StringP("viewer")}); err != nil {
+				t.Fatal(err)
+			}
+			m.failDelete = tt.failDelete
+			tt.req.Realm = "test"
+			results, err := kcops.DeleteRoles(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			for _, r := range results {
+				if r.Outcome != kcops.Deleted {
+					continue
+				}
+				if before, ok := r.Before.(gocloak.Role); !ok || gocloak.PString(before.Name) != r.Name {
+					t.Errorf("Before = %+v, want the role %q", r.Before, r.Name)
+				}
+			}
+			_, err = c.GC.GetRealmRole(ctx, c.Token, "test", "viewer")
+			if deleted := err != nil; deleted != slices.Contains(outcomes(results), kcops.Deleted) {
+				t.Errorf("role viewer deleted = %v, results %v", deleted, outcomes(results))
+			}
+		})
+	}
+}
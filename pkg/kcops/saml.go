@@ -0,0 +1,147 @@
+package kcops
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	samlBindingPost     = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlBindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	samlBindingArtifact = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Artifact"
+)
+
+// samlNameIDFormats maps SAML NameID formats to the saml_name_id_format
+// values of Keycloak.
+var samlNameIDFormats = map[string]string{
+	"urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified":  "username",
+	"urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress": "email",
+	"urn:oasis:names:tc:SAML:2.0:nameid-format:persistent":   "persistent",
+	"urn:oasis:names:tc:SAML:2.0:nameid-format:transient":    "transient",
+}
+
+// samlEntityDescriptor is the part of SAML 2.0 SP metadata kc reads.
+type samlEntityDescriptor struct {
+	XMLName  xml.Name `xml:"EntityDescriptor"`
+	EntityID string   `xml:"entityID,attr"`
+	SP       *struct {
+		AuthnRequestsSigned  string `xml:"AuthnRequestsSigned,attr"`
+		WantAssertionsSigned string `xml:"WantAssertionsSigned,attr"`
+		KeyDescriptors       []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		NameIDFormats        []string       `xml:"NameIDFormat"`
+		AssertionConsumers   []samlEndpoint `xml:"AssertionConsumerService"`
+		SingleLogoutServices []samlEndpoint `xml:"SingleLogoutService"`
+	} `xml:"SPSSODescriptor"`
+}
+
+type samlEndpoint struct {
+	Binding   string `xml:"Binding,attr"`
+	Location  string `xml:"Location,attr"`
+	Index     string `xml:"index,attr"`
+	IsDefault string `xml:"isDefault,attr"`
+}
+
+// SAMLClientFromMetadata builds the spec of a SAML client from the metadata
+// of a service provider: the entity ID becomes the client ID, the assertion
+// consumer services the redirect URIs and ACS URLs, and the signing and
+// encryption certificates, NameID format and single logout URLs the
+// attributes Keycloak stores them in.
+func SAMLClientFromMetadata(data []byte) (ClientSpec, error) {
+	var ed samlEntityDescriptor
+	if err := xml.Unmarshal(data, &ed); err != nil {
+		return ClientSpec{}, fmt.Errorf("not SAML metadata with an EntityDescriptor: %w", err)
+	}
+	if ed.EntityID == "" {
+		return ClientSpec{}, errors.New("the EntityDescriptor has no entityID")
+	}
+	sp := ed.SP
+	if sp == nil {
+		return ClientSpec{}, fmt.Errorf("entity %q has no SPSSODescriptor; is it service provider metadata?", ed.EntityID)
+	}
+	if len(sp.AssertionConsumers) == 0 {
+		return ClientSpec{}, fmt.Errorf("entity %q has no AssertionConsumerService", ed.EntityID)
+	}
+
+	spec := ClientSpec{ClientID: ed.EntityID, Enabled: true, Protocol: "saml", Attributes: map[string]string{}}
+	acs := samlOrdered(sp.AssertionConsumers)
+	for _, e := range acs {
+		if e.Location != "" && !slices.Contains(spec.RedirectURIs, e.Location) {
+			spec.RedirectURIs = append(spec.RedirectURIs, e.Location)
+		}
+	}
+	setEndpoint := func(endpoints []samlEndpoint, prefix string) {
+		for binding, suffix := range map[string]string{samlBindingPost: "post", samlBindingRedirect: "redirect", samlBindingArtifact: "artifact"} {
+			key := prefix + suffix
+			for _, e := range endpoints {
+				if e.Binding == binding && spec.Attributes[key] == "" {
+					spec.Attributes[key] = e.Location
+				}
+			}
+		}
+	}
+	setEndpoint(acs, "saml_assertion_consumer_url_")
+	setEndpoint(samlOrdered(sp.SingleLogoutServices), "saml_single_logout_service_url_")
+
+	var signing, encryption string
+	for _, kd := range sp.KeyDescriptors {
+		if len(kd.Certificates) == 0 {
+			continue
+		}
+		cert := strings.Join(strings.Fields(kd.Certificates[0]), "")
+		// without use, a key is for both; encryption stays opt-in
+		if (kd.Use == "" || kd.Use == "signing") && signing == "" {
+			signing = cert
+		}
+		if kd.Use == "encryption" && encryption == "" {
+			encryption = cert
+		}
+	}
+	// Keycloak requires signed requests by default, which would reject
+	// every request of an SP that does not sign them
+	clientSignature := signing != "" && sp.AuthnRequestsSigned == "true"
+	spec.Attributes["saml.client.signature"] = strconv.FormatBool(clientSignature)
+	if clientSignature {
+		spec.Attributes["saml.signing.certificate"] = signing
+	}
+	if encryption != "" {
+		spec.Attributes["saml.encrypt"] = "true"
+		spec.Attributes["saml.encryption.certificate"] = encryption
+	}
+	if sp.WantAssertionsSigned == "true" {
+		spec.Attributes["saml.assertion.signature"] = "true"
+	}
+	for _, f := range sp.NameIDFormats {
+		if v, ok := samlNameIDFormats[strings.TrimSpace(f)]; ok {
+			spec.Attributes["saml_name_id_format"] = v
+			break
+		}
+	}
+	return spec, nil
+}
+
+// samlOrdered puts the default endpoint first, then the others by index.
+func samlOrdered(endpoints []samlEndpoint) []samlEndpoint {
+	out := slices.Clone(endpoints)
+	slices.SortStableFunc(out, func(a, b samlEndpoint) int {
+		if (a.IsDefault == "true") != (b.IsDefault == "true") {
+			if a.IsDefault == "true" {
+				return -1
+			}
+			return 1
+		}
+		ai, aErr := strconv.Atoi(a.Index)
+		bi, bErr := strconv.Atoi(b.Index)
+		if aErr != nil || bErr != nil {
+			return 0
+		}
+		return ai - bi
+	})
+	return out
+}
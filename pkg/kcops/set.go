@@ -0,0 +1,158 @@
+package kcops
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldSet sets one field of a representation by its JSON path, e.g.
+// attributes.frontendUrl=https://login.example.org, for settings without a
+// dedicated flag or request field.
+type FieldSet struct {
+	// Path is the dotted path of the field, e.g. "attributes.frontendUrl".
+	// Under attributes the rest of the path is the attribute name, dots
+	// included: attributes.pkce.code.challenge.method.
+	Path string `json:"path"`
+	// Value is JSON when the field is not a string (true, 300, ["a","b"]);
+	// otherwise the string itself.
+	Value string `json:"value"`
+}
+
+// FieldSetError is the error of a FieldSet that cannot be parsed or applied.
+type FieldSetError struct {
+	// Set is the path=value pair, or the path, in question.
+	Set string
+	// Reason says what is wrong with it.
+	Reason string
+}
+
+func (e *FieldSetError) Error() string {
+	return fmt.Sprintf("invalid field set %s: %s", e.Set, e.Reason)
+}
+
+// ParseFieldSets parses path=value pairs, e.g. as given on a command line.
+func ParseFieldSets(args []string) ([]FieldSet, error) {
+	sets := make([]FieldSet, 0, len(args))
+	for _, a := range args {
+		path, value, ok := strings.Cut(a, "=")
+		path = strings.TrimSpace(path)
+		if !ok || path == "" || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") || strings.Contains(path, "..") {
+			return nil, &FieldSetError{Set: fmt.Sprintf("%q", a), Reason: "use path=value, e.g. attributes.frontendUrl=https://login.example.org"}
+		}
+		sets = append(sets, FieldSet{Path: path, Value: value})
+	}
+	return sets, nil
+}
+
+// ApplyFieldSets applies sets to rep, a gocloak representation such as
+// gocloak.Client, and returns the fields that changed. A value is taken as
+// JSON when the field accepts it, else as a string. Paths that rep has no
+// field for fail, as Keycloak would never receive them; attributes take any
+// name.
+func ApplyFieldSets[T any](rep *T, sets []FieldSet) ([]FieldChange, error) {
+	var fields []FieldChange
+	for _, s := range sets {
+		b, err := json.Marshal(rep)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, err
+		}
+		parent, key, err := walkPath(doc, s.Path)
+		if err != nil {
+			return nil, err
+		}
+		old, had := parent[key]
+
+		// the typed value first, e.g. a boolean, then the plain string
+		var candidates []interface{}
+		var typed interface{}
+		if json.Unmarshal([]byte(s.Value), &typed) == nil {
+			if _, isString := typed.(string); !isString {
+				candidates = append(candidates, typed)
+			}
+		}
+		candidates = append(candidates, s.Value)
+		var next T
+		var lastErr error
+		for _, v := range candidates {
+			parent[key] = v
+			b, err := json.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+			next = *new(T)
+			if lastErr = decodeStrict(b, &next); lastErr == nil {
+				break
+			}
+		}
+		if lastErr != nil {
+			if strings.Contains(lastErr.Error(), "unknown field") {
+				return nil, &FieldSetError{Set: s.Path, Reason: "no such field; settings without a field of their own usually go under attributes.<name>"}
+			}
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(lastErr, &typeErr) {
+				return nil, &FieldSetError{Set: s.Path + "=" + s.Value, Reason: fmt.Sprintf("the field takes a %s value", typeErr.Type.Kind())}
+			}
+			return nil, &FieldSetError{Set: s.Path + "=" + s.Value, Reason: lastErr.Error()}
+		}
+		*rep = next
+		oldText := ""
+		if had {
+			oldText = jsonText(old)
+		}
+		if newText := jsonText(parent[key]); !had || oldText != newText {
+			fields = append(fields, FieldChange{Field: s.Path, Old: oldText, New: newText})
+		}
+	}
+	return fields, nil
+}
+
+// walkPath returns the object holding the last segment of path in doc and
+// that segment, creating the objects on the way.
+func walkPath(doc map[string]interface{}, path string) (map[string]interface{}, string, error) {
+	node := doc
+	rest := path
+	for {
+		seg, tail, more := strings.Cut(rest, ".")
+		if !more {
+			return node, seg, nil
+		}
+		child, err := childObject(node, seg, path)
+		if err != nil {
+			return nil, "", err
+		}
+		if seg == "attributes" {
+			return child, tail, nil
+		}
+		node, rest = child, tail
+	}
+}
+
+// childObject returns the object under key in node, creating it when missing.
+func childObject(node map[string]interface{}, key, path string) (map[string]interface{}, error) {
+	switch v := node[key].(type) {
+	case nil:
+		child := map[string]interface{}{}
+		node[key] = child
+		return child, nil
+	case map[string]interface{}:
+		return v, nil
+	default:
+		return nil, &FieldSetError{Set: path, Reason: key + " is not an object"}
+	}
+}
+
+// jsonText renders a value of a representation for a FieldChange: strings
+// as they are, anything else as JSON.
+func jsonText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
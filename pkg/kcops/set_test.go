@@ -0,0 +1,108 @@
+package kcops_test
+
+import (
+	"slices"
+	"testing"
+
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestParseFieldSets(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    []kcops.FieldSet
+		wantErr string
+	}{
+		{args: []string{"attributes.frontendUrl=https://login.example.org"}, want: []kcops.FieldSet{{Path: "attributes.frontendUrl", Value: "https://login.example.org"}}},
+		{args: []string{"displayName=a=b"}, want: []kcops.FieldSet{{Path: "displayName", Value: "a=b"}}},
+		{args: []string{"displayName="}, want: []kcops.FieldSet{{Path: "displayName", Value: ""}}},
+		{args: []string{"displayName"}, wantErr: `invalid field set "displayName"`},
+		{args: []string{"=x"}, wantErr: "use path=value"},
+		{args: []string{"attributes..x=1"}, wantErr: "use path=value"},
+		{args: []string{".x=1"}, wantErr: "use path=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			got, err := kcops.ParseFieldSets(tt.args)
+			checkErr(t, err, tt.wantErr)
+			if err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyFieldSets(t *testing.T) {
+	tests := []struct {
+		name    string
+		sets    []kcops.FieldSet
+		check   func(r gocloak.RealmRepresentation) bool
+		want    []kcops.FieldChange
+		wantErr string
+	}{
+		{
+			name:  "string",
+			sets:  []kcops.FieldSet{{Path: "displayName", Value: "Test"}},
+			check: func(r gocloak.RealmRepresentation) bool { return gocloak.PString(r.DisplayName) == "Test" },
+			want:  []kcops.FieldChange{{Field: "displayName", Old: "Old", New: "Test"}},
+		},
+		{
+			name:  "boolean",
+			sets:  []kcops.FieldSet{{Path: "registrationAllowed", Value: "true"}},
+			check: func(r gocloak.RealmRepresentation) bool { return gocloak.PBool(r.RegistrationAllowed) },
+			want:  []kcops.FieldChange{{Field: "registrationAllowed", New: "true"}},
+		},
+		{
+			name:  "number",
+			sets:  []kcops.FieldSet{{Path: "accessTokenLifespan", Value: "600"}},
+			check: func(r gocloak.RealmRepresentation) bool { return gocloak.PInt(r.AccessTokenLifespan) == 600 },
+			want:  []kcops.FieldChange{{Field: "accessTokenLifespan", Old: "300", New: "600"}},
+		},
+		{
+			name: "attribute with dots",
+			sets: []kcops.FieldSet{{Path: "attributes.frontend.url", Value: "https://login.example.org"}},
+			check: func(r gocloak.RealmRepresentation) bool {
+				return r.Attributes != nil && (*r.Attributes)["frontend.url"] == "https://login.example.org"
+			},
+			want: []kcops.FieldChange{{Field: "attributes.frontend.url", New: "https://login.example.org"}},
+		},
+		{
+			name:  "unchanged",
+			sets:  []kcops.FieldSet{{Path: "displayName", Value: "Old"}},
+			check: func(r gocloak.RealmRepresentation) bool { return gocloak.PString(r.DisplayName) == "Old" },
+		},
+		{
+			name:    "unknown field",
+			sets:    []kcops.FieldSet{{Path: "displayNam", Value: "x"}},
+			wantErr: "no such field",
+		},
+		{
+			name:    "wrong type",
+			sets:    []kcops.FieldSet{{Path: "accessTokenLifespan", Value: "soon"}},
+			wantErr: "takes a int value",
+		},
+		{
+			name:    "not an object",
+			sets:    []kcops.FieldSet{{Path: "displayName.x", Value: "1"}},
+			wantErr: "displayName is not an object",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gocloak.RealmRepresentation{DisplayName: gocloak.StringP("Old"), AccessTokenLifespan: gocloak.IntP(300)}
+			got, err := kcops.ApplyFieldSets(&r, tt.sets)
+			checkErr(t, err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("changes = %+v, want %+v", got, tt.want)
+			}
+			if !tt.check(r) {
+				t.Errorf("representation not updated: %+v", r)
+			}
+		})
+	}
+}
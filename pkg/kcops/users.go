@@ -0,0 +1,511 @@
+package kcops
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+// UserSpec describes a user to create.
+type UserSpec struct {
+	Username  string `json:"username,omitempty"`
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	// Password is generated when empty.
+	Password string `json:"password,omitempty"`
+	// PasswordHash is a password hashed elsewhere, imported instead of
+	// Password: a bcrypt hash or a PBKDF2 one written
+	// algorithm:iterations:salt:hash. CredentialData and SecretData give it
+	// in the credential format of Keycloak instead.
+	PasswordHash   string `json:"passwordHash,omitempty"`
+	CredentialData string `json:"credentialData,omitempty"`
+	SecretData     string `json:"secretData,omitempty"`
+	Enabled        bool   `json:"enabled"`
+}
+
+// UnmarshalJSON defaults Enabled to true, as the CLI does, and rejects
+// unknown fields.
+func (s *UserSpec) UnmarshalJSON(b []byte) error {
+	type plain UserSpec
+	p := plain{Enabled: true}
+	if err := decodeStrict(b, &p); err != nil {
+		return err
+	}
+	*s = UserSpec(p)
+	return nil
+}
+
+// CreateUsersRequest creates users in one realm. Users that already exist are skipped.
+type CreateUsersRequest struct {
+	Realm string     `json:"realm,omitempty"`
+	Users []UserSpec `json:"users,omitempty"`
+	// RealmRoles are assigned to every created user.
+	RealmRoles []string `json:"realmRoles,omitempty"`
+	// ClientRoles of the client with clientId ClientID are assigned to every
+	// created user.
+	ClientID    string   `json:"clientId,omitempty"`
+	ClientRoles []string `json:"clientRoles,omitempty"`
+	// NoPassword creates the users without a password, e.g. to have them set
+	// their own through the execute-actions email. Users must not give one.
+	NoPassword bool `json:"noPassword,omitempty"`
+	// Workers is the number of users created concurrently (default 1).
+	Workers int `json:"workers,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// ErrClientIDRequired is returned for a CreateUsersRequest with ClientRoles
+// but no ClientID.
+var ErrClientIDRequired = errors.New("ClientRoles given without a ClientID")
+
+// CreateUsers creates the users of req.
+func CreateUsers(ctx context.Context, c *Client, req CreateUsersRequest) ([]Result, error) {
+	if len(req.ClientRoles) > 0 && req.ClientID == "" {
+		return nil, ErrClientIDRequired
+	}
+	realm := req.Realm
+	var policy PasswordPolicy
+	if !req.NoPassword {
+		var err error
+		if policy, err = RealmPasswordPolicy(ctx, c, realm); err != nil {
+			return nil, err
+		}
+		// check every given password before creating anyone, unless failed
+		// users are only reported
+		for _, spec := range req.Users {
+			if spec.Password == "" || req.ContinueOnError {
+				continue
+			}
+			if err := checkPassword(policy, spec); err != nil {
+				return nil, fmt.Errorf("invalid password for user %q in realm %s: %w", spec.Username, realm, err)
+			}
+		}
+	}
+	return runBatch(ctx, len(req.Users), req.Workers, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		spec := req.Users[i]
+		un := spec.Username
+		res := Result{Realm: realm, Name: un}
+		// Lookup existence by username
+		existing, err := c.GC.GetUsers(ctx, c.Token, realm, gocloak.GetUsersParams{Username: &un})
+		if err != nil {
+			return res, fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
+		}
+		if len(existing) > 0 {
+			res.Outcome = Skipped
+			return res, nil
+		}
+
+		pw := spec.Password
+		hashed, algorithm, err := hashedCredential(spec)
+		if err != nil {
+			return res, fmt.Errorf("user %q in realm %s: %w", un, realm, err)
+		}
+		switch {
+		case req.NoPassword && (pw != "" || hashed != nil):
+			return res, fmt.Errorf("user %q in realm %s: no password can be given when users set their own", un, realm)
+		case hashed != nil && pw != "":
+			return res, fmt.Errorf("user %q in realm %s: a password and a password hash cannot be given together", un, realm)
+		case req.NoPassword, hashed != nil:
+		default:
+			// If no password provided, generate one passing the policy of the realm
+			if pw == "" {
+				generated, err := policy.Generate(un, spec.Email)
+				if err != nil {
+					return res, fmt.Errorf("failed generating password for user %q in realm %s: %w", un, realm, err)
+				}
+				pw = generated
+				res.PasswordGenerated = true
+			}
+			if err := checkPassword(policy, UserSpec{Username: un, Email: spec.Email, Password: pw}); err != nil {
+				return res, fmt.Errorf("invalid password for user %q in realm %s: %w", un, realm, err)
+			}
+		}
+
+		enabled := spec.Enabled
+		emailVerified := spec.Email != ""
+		user := gocloak.User{
+			Username:      &un,
+			Enabled:       &enabled,
+			EmailVerified: &emailVerified,
+		}
+		if spec.Email != "" {
+			user.Email = &spec.Email
+		}
+		if spec.FirstName != "" {
+			user.FirstName = &spec.FirstName
+		}
+		if spec.LastName != "" {
+			user.LastName = &spec.LastName
+		}
+		if hashed != nil {
+			user.Credentials = &[]gocloak.CredentialRepresentation{*hashed}
+		} else if !req.NoPassword {
+			creds := []gocloak.CredentialRepresentation{{
+				Type:      gocloak.StringP("password"),
+				Value:     gocloak.StringP(pw),
+				Temporary: gocloak.BoolP(false),
+			}}
+			user.Credentials = &creds
+		}
+
+		userID, err := c.GC.CreateUser(ctx, c.Token, realm, user)
+		if err != nil {
+			// Surfacing 409 conflicts more nicely
+			if isConflict(err) {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("failed creating user %q in realm %s: %w", un, realm, err)
+		}
+
+		if len(req.RealmRoles) > 0 {
+			var roles []gocloak.Role
+			for _, rn := range req.RealmRoles {
+				role, err := c.GC.GetRealmRole(ctx, c.Token, realm, rn)
+				if err != nil {
+					return res, fmt.Errorf("failed fetching realm role %q in realm %s: %w", rn, realm, err)
+				}
+				roles = append(roles, *role)
+			}
+			if err := c.GC.AddRealmRoleToUser(ctx, c.Token, realm, userID, roles); err != nil {
+				return res, fmt.Errorf("failed assigning roles to user %q in realm %s: %w", un, realm, err)
+			}
+		}
+		if len(req.ClientRoles) > 0 {
+			kcClient, err := c.ClientByClientID(ctx, realm, req.ClientID)
+			if err != nil || kcClient == nil || kcClient.ID == nil {
+				return res, fmt.Errorf("client %q not found in realm %s", req.ClientID, realm)
+			}
+			idOfClient := *kcClient.ID
+			var roles []gocloak.Role
+			for _, rn := range req.ClientRoles {
+				role, err := c.GC.GetClientRole(ctx, c.Token, realm, idOfClient, rn)
+				if err != nil {
+					return res, fmt.Errorf("failed fetching client role %q for client %s in realm %s: %w", rn, req.ClientID, realm, err)
+				}
+				roles = append(roles, *role)
+			}
+			if err := c.GC.AddClientRoleToUser(ctx, c.Token, realm, idOfClient, userID, roles); err != nil {
+				return res, fmt.Errorf("failed assigning client roles to user %q in realm %s: %w", un, realm, err)
+			}
+		}
+
+		res.ID = userID
+		res.Outcome = Created
+		res.Password = pw
+		res.Fields = appendFieldChange(res.Fields, "email", nil, user.Email)
+		res.Fields = appendFieldChange(res.Fields, "firstName", nil, user.FirstName)
+		res.Fields = appendFieldChange(res.Fields, "lastName", nil, user.LastName)
+		res.Fields = appendFieldChange(res.Fields, "enabled", nil, user.Enabled)
+		if hashed != nil {
+			res.Fields = appendFieldChange(res.Fields, "passwordHash", nil, &algorithm)
+		} else if !req.NoPassword {
+			res.Fields = appendFieldChange(res.Fields, "password", nil, &pw)
+		}
+		if len(req.RealmRoles) > 0 {
+			res.Fields = appendListChange(res.Fields, "realmRoles", nil, &req.RealmRoles)
+		}
+		if len(req.ClientRoles) > 0 {
+			res.Fields = appendListChange(res.Fields, "clientRoles", nil, &req.ClientRoles)
+		}
+		return res, nil
+	})
+}
+
+// UserUpdate describes the changes to one user. Empty strings and a nil
+// Enabled leave the attribute unchanged.
+type UserUpdate struct {
+	Username  string `json:"username,omitempty"`
+	Email     string `json:"email,omitempty"`
+	FirstName string `json:"firstName,omitempty"`
+	LastName  string `json:"lastName,omitempty"`
+	Password  string `json:"password,omitempty"`
+	Enabled   *bool  `json:"enabled,omitempty"`
+}
+
+// UpdateUsersRequest updates users of one realm.
+type UpdateUsersRequest struct {
+	Realm string       `json:"realm,omitempty"`
+	Users []UserUpdate `json:"users,omitempty"`
+	// IgnoreMissing skips users that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// UpdateUsers applies the updates of req. Setting an email marks it verified.
+func UpdateUsers(ctx context.Context, c *Client, req UpdateUsersRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Users), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		upd := req.Users[i]
+		un := upd.Username
+		res := Result{Realm: realm, Name: un, NewName: un}
+		existing, err := c.GC.GetUsers(ctx, c.Token, realm, gocloak.GetUsersParams{Username: &un})
+		if err != nil {
+			return res, fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
+		}
+		if len(existing) == 0 {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("user %q not found in realm %s", un, realm)
+		}
+		userID := *existing[0].ID
+		if upd.Password != "" {
+			if err := ValidatePassword(upd.Password); err != nil {
+				return res, fmt.Errorf("invalid password for user %q in realm %s: %w", un, realm, err)
+			}
+		}
+
+		before := *existing[0]
+		u := gocloak.User{ID: &userID}
+		if upd.Email != "" {
+			u.Email = &upd.Email
+			ev := true
+			u.EmailVerified = &ev
+		}
+		if upd.FirstName != "" {
+			u.FirstName = &upd.FirstName
+		}
+		if upd.LastName != "" {
+			u.LastName = &upd.LastName
+		}
+		u.Enabled = upd.Enabled
+
+		if err := c.GC.UpdateUser(ctx, c.Token, realm, u); err != nil {
+			return res, fmt.Errorf("failed updating user %q in realm %s: %w", un, realm, err)
+		}
+		if upd.Password != "" {
+			if err := c.GC.SetPassword(ctx, c.Token, userID, realm, upd.Password, false); err != nil {
+				return res, fmt.Errorf("failed setting password for user %q in realm %s: %w", un, realm, err)
+			}
+			res.Password = upd.Password
+		}
+		res.ID = userID
+		res.Outcome = Updated
+		res.Before = before
+		res.Fields = appendFieldChange(res.Fields, "email", before.Email, u.Email)
+		res.Fields = appendFieldChange(res.Fields, "firstName", before.FirstName, u.FirstName)
+		res.Fields = appendFieldChange(res.Fields, "lastName", before.LastName, u.LastName)
+		res.Fields = appendFieldChange(res.Fields, "enabled", before.Enabled, u.Enabled)
+		if upd.Password != "" {
+			res.Fields = appendFieldChange(res.Fields, "password", nil, &upd.Password)
+		}
+		return res, nil
+	})
+}
+
+// DeleteUsersRequest deletes users of one realm by username.
+type DeleteUsersRequest struct {
+	Realm     string   `json:"realm,omitempty"`
+	Usernames []string `json:"usernames,omitempty"`
+	// IgnoreMissing skips users that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// DeleteUsers deletes the users of req.
+func DeleteUsers(ctx context.Context, c *Client, req DeleteUsersRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Usernames), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		un := req.Usernames[i]
+		res := Result{Realm: realm, Name: un}
+		existing, err := c.GC.GetUsers(ctx, c.Token, realm, gocloak.GetUsersParams{Username: &un})
+		if err != nil {
+			return res, fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
+		}
+		if len(existing) == 0 {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("user %q not found in realm %s", un, realm)
+		}
+		userID := *existing[0].ID
+		if err := c.GC.DeleteUser(ctx, c.Token, realm, userID); err != nil {
+			return res, fmt.Errorf("failed deleting user %q in realm %s: %w", un, realm, err)
+		}
+		res.ID = userID
+		res.Outcome = Deleted
+		res.Before = *existing[0]
+		return res, nil
+	})
+}
+
+// AnonymizeUsersRequest scrubs the personal data of users of one realm.
+type AnonymizeUsersRequest struct {
+	Realm     string   `json:"realm,omitempty"`
+	Usernames []string `json:"usernames,omitempty"`
+	// KeepAttributes are attributes that hold no personal data and survive,
+	// e.g. an employee number other systems join on.
+	KeepAttributes []string `json:"keepAttributes,omitempty"`
+	// Rename replaces the username with "anonymized-" and the start of the
+	// user ID, for realms where usernames are names or emails.
+	Rename bool `json:"rename,omitempty"`
+	// IgnoreMissing skips users that do not exist instead of failing.
+	IgnoreMissing bool `json:"ignoreMissing,omitempty"`
+	// ContinueOnError reports failed items instead of stopping; see Failed.
+	ContinueOnError bool `json:"continueOnError,omitempty"`
+}
+
+// AnonymizedUsername is the username AnonymizeUsers gives the user with ID id
+// when renaming.
+func AnonymizedUsername(id string) string {
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "anonymized-" + id
+}
+
+// AnonymizeUsers clears the email, names and attributes of the users of req,
+// keeping the accounts and their IDs. The old values are not kept anywhere:
+// the fields record "(scrubbed)" and Before stays empty, so the change cannot
+// be undone. Users with nothing left to scrub are skipped.
+func AnonymizeUsers(ctx context.Context, c *Client, req AnonymizeUsersRequest) ([]Result, error) {
+	realm := req.Realm
+	return runBatch(ctx, len(req.Usernames), 1, req.ContinueOnError, func(ctx context.Context, i int) (Result, error) {
+		un := req.Usernames[i]
+		res := Result{Realm: realm, Name: un, NewName: un}
+		existing, err := c.GC.GetUsers(ctx, c.Token, realm, gocloak.GetUsersParams{Username: &un, Exact: gocloak.BoolP(true)})
+		if err != nil {
+			return res, fmt.Errorf("failed searching user %q in realm %s: %w", un, realm, err)
+		}
+		if len(existing) == 0 || existing[0].ID == nil {
+			if req.IgnoreMissing {
+				res.Outcome = Skipped
+				return res, nil
+			}
+			return res, fmt.Errorf("user %q not found in realm %s", un, realm)
+		}
+		current := existing[0]
+		res.ID = *current.ID
+
+		u := gocloak.User{ID: current.ID}
+		scrub := func(field string, have *string, set **string) {
+			if gocloak.PString(have) != "" {
+				*set = gocloak.StringP("")
+				res.Fields = append(res.Fields, FieldChange{Field: field, Old: "(scrubbed)"})
+			}
+		}
+		scrub("email", current.Email, &u.Email)
+		scrub("firstName", current.FirstName, &u.FirstName)
+		scrub("lastName", current.LastName, &u.LastName)
+		if u.Email != nil {
+			u.EmailVerified = gocloak.BoolP(false)
+		}
+		if current.Attributes != nil {
+			kept := map[string][]string{}
+			for _, k := range slices.Sorted(maps.Keys(*current.Attributes)) {
+				if slices.Contains(req.KeepAttributes, k) {
+					kept[k] = (*current.Attributes)[k]
+					continue
+				}
+				res.Fields = append(res.Fields, FieldChange{Field: "attributes." + k, Old: "(scrubbed)"})
+			}
+			if len(kept) < len(*current.Attributes) {
+				u.Attributes = &kept
+			}
+		}
+		if req.Rename && un != AnonymizedUsername(res.ID) {
+			res.NewName = AnonymizedUsername(res.ID)
+			u.Username = &res.NewName
+			res.Fields = append(res.Fields, FieldChange{Field: "username", Old: un, New: res.NewName})
+		} else if !req.Rename && strings.Contains(un, "@") {
+			res.Warnings = append(res.Warnings, fmt.Sprintf("the username of user %q in realm %s looks like an email and is kept; set Rename to replace it", un, realm))
+		}
+		if len(res.Fields) == 0 {
+			res.Outcome = Skipped
+			return res, nil
+		}
+
+		if err := c.GC.UpdateUser(ctx, c.Token, realm, u); err != nil {
+			return res, fmt.Errorf("failed anonymizing user %q in realm %s: %w", un, realm, err)
+		}
+		res.Outcome = Updated
+		return res, nil
+	})
+}
+
+// ValidatePassword checks the password policy applied by kc: at least 6
+// characters with a lowercase letter, an uppercase letter, a digit and a
+// special character.
+func ValidatePassword(pw string) error {
+	if len(pw) < 6 {
+		return fmt.Errorf("password must be at least 6 characters long")
+	}
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, r := range pw {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			// Anything that is not a letter or digit is considered special
+			hasSpecial = true
+		}
+	}
+	if !hasLower || !hasUpper || !hasDigit || !hasSpecial {
+		return errors.New("password must contain at least one lowercase letter, one uppercase letter, one digit, and one special character")
+	}
+	return nil
+}
+
+// checkPassword checks the password of spec against ValidatePassword and
+// the password policy of the realm.
+func checkPassword(policy PasswordPolicy, spec UserSpec) error {
+	if err := ValidatePassword(spec.Password); err != nil {
+		return err
+	}
+	return policy.Validate(spec.Password, spec.Username, spec.Email)
+}
+
+// GeneratePassword returns a random password of length n that passes
+// ValidatePassword.
+func GeneratePassword(n int) (string, error) {
+	const lower = "abcdefghijklmnopqrstuvwxyz"
+	const upper = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	const digits = "0123456789"
+	const specials = "!@#$%^&*()-_=+[]{}|;:,.<>/?"
+	const all = lower + upper + digits + specials
+
+	// We need at least one of each type: lower, upper, digit, special
+	if n < 4 {
+		return "", errors.New("password length must be at least 4")
+	}
+
+	b := make([]byte, n)
+
+	// ensure at least one of each required type
+	pools := []string{lower, upper, digits, specials}
+	for i, pool := range pools {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(pool))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = pool[idx.Int64()]
+	}
+
+	for i := len(pools); i < n; i++ {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(all))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = all[idx.Int64()]
+	}
+
+	return string(b), nil
+}
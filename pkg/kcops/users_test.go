@@ -0,0 +1,226 @@
+package kcops_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"kc/pkg/kcops"
+
+	"github.com/Nerzal/gocloak/v13"
+)
+
+func TestCreateUsers(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		failing  []string
+		req      kcops.CreateUsersRequest
+		want     []kcops.Outcome
+		wantErr  string
+	}{
+		{
+			name: "creates with the given password",
+			req:  kcops.CreateUsersRequest{Users: []kcops.UserSpec{{Username: "alice", Email: "alice@example.org", Password: "Secr3t-Pass", Enabled: true}}},
+			want: []kcops.Outcome{kcops.Created},
+		},
+		{
+			name:     "skips existing users",
+			existing: []string{"bob"},
+			req:      kcops.CreateUsersRequest{Users: []kcops.UserSpec{{Username: "bob", Enabled: true}, {Username: "carol", Enabled: true}}},
+			want:     []kcops.Outcome{kcops.Skipped, kcops.Created},
+		},
+		{
+			name:    "stops at the first failure",
+			failing: []string{"bad"},
+			req:     kcops.CreateUsersRequest{Users: []kcops.UserSpec{{Username: "ok1", Enabled: true}, {Username: "bad", Enabled: true}, {Username: "ok2", Enabled: true}}},
+			want:    []kcops.Outcome{kcops.Created},
+			wantErr: `failed creating user "bad" in realm test`,
+		},
+		{
+			name:    "reports failures with ContinueOnError",
+			failing: []string{"bad"},
+			req:     kcops.CreateUsersRequest{ContinueOnError: true, Users: []kcops.UserSpec{{Username: "ok1", Enabled: true}, {Username: "bad", Enabled: true}, {Username: "ok2", Enabled: true}}},
+			want:    []kcops.Outcome{kcops.Created, kcops.Failed, kcops.Created},
+			wantErr: kcops.ErrItemsFailed.Error(),
+		},
+		{
+			name:    "needs a client for client roles",
+			req:     kcops.CreateUsersRequest{ClientRoles: []string{"viewer"}, Users: []kcops.UserSpec{{Username: "dave"}}},
+			wantErr: kcops.ErrClientIDRequired.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			for _, un := range tt.existing {
+				if _, err := c.GC.CreateUser(ctx, c.Token, "test", gocloak.User{Username: gocloak.StringP(un)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m.failCreate = map[string]bool{}
+			for _, un := range tt.failing {
+				m.failCreate[un] = true
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.CreateUsers(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			if tt.wantErr != "" && !errors.Is(err, kcops.ErrItemsFailed) {
+				return
+			}
+			for _, r := range results {
+				if r.Outcome != kcops.Created {
+					continue
+				}
+				users, err := c.GC.GetUsers(ctx, c.Token, "test", gocloak.GetUsersParams{Username: &r.Name, Exact: gocloak.BoolP(true)})
+				if err != nil || len(users) != 1 || gocloak.PString(users[0].ID) != r.ID {
+					t.Fatalf("user %q was not created with ID %s: %v", r.Name, r.ID, users)
+				}
+				if r.Password == "" {
+					t.Errorf("user %q: no password reported", r.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUsersGeneratesPassword(t *testing.T) {
+	c, _ := newClient(t)
+	results, err := kcops.CreateUsers(context.Background(), c, kcops.CreateUsersRequest{Realm: "test", Users: []kcops.UserSpec{{Username: "erin", Email: "erin@example.org", Enabled: true}}})
+	checkErr(t, err, "")
+	r := results[0]
+	if !r.PasswordGenerated || r.Password == "" {
+		t.Fatalf("expected a generated password, got %+v", r)
+	}
+	if f, ok := field(r, "email"); !ok || f.New != "erin@example.org" {
+		t.Errorf("email change = %+v", f)
+	}
+}
+
+func TestUpdateUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        kcops.UpdateUsersRequest
+		want       []kcops.Outcome
+		wantErr    string
+		wantFields []kcops.FieldChange
+	}{
+		{
+			name:       "changes the email",
+			req:        kcops.UpdateUsersRequest{Users: []kcops.UserUpdate{{Username: "alice", Email: "new@example.org"}}},
+			want:       []kcops.Outcome{kcops.Updated},
+			wantFields: []kcops.FieldChange{{Field: "email", Old: "old@example.org", New: "new@example.org"}},
+		},
+		{
+			name:       "disables",
+			req:        kcops.UpdateUsersRequest{Users: []kcops.UserUpdate{{Username: "alice", Enabled: gocloak.BoolP(false)}}},
+			want:       []kcops.Outcome{kcops.Updated},
+			wantFields: []kcops.FieldChange{{Field: "enabled", Old: "true", New: "false"}},
+		},
+		{
+			name:    "fails on a missing user",
+			req:     kcops.UpdateUsersRequest{Users: []kcops.UserUpdate{{Username: "nobody", FirstName: "X"}}},
+			want:    []kcops.Outcome{},
+			wantErr: `user "nobody" not found in realm test`,
+		},
+		{
+			name: "skips a missing user with IgnoreMissing",
+			req:  kcops.UpdateUsersRequest{IgnoreMissing: true, Users: []kcops.UserUpdate{{Username: "nobody", FirstName: "X"}}},
+			want: []kcops.Outcome{kcops.Skipped},
+		},
+		{
+			name:    "rejects a weak password",
+			req:     kcops.UpdateUsersRequest{Users: []kcops.UserUpdate{{Username: "alice", Password: "short"}}},
+			want:    []kcops.Outcome{},
+			wantErr: "invalid password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, _ := newClient(t)
+			if _, err := c.GC.CreateUser(ctx, c.Token, "test", gocloak.User{Username: gocloak.StringP("alice"), Email: gocloak.StringP("old@example.org"), Enabled: gocloak.BoolP(true)}); err != nil {
+				t.Fatal(err)
+			}
+			tt.req.Realm = "test"
+			results, err := kcops.UpdateUsers(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			for _, want := range tt.wantFields {
+				if got, _ := field(results[0], want.Field); got != want {
+					t.Errorf("change of %s = %+v, want %+v", want.Field, got, want)
+				}
+			}
+			if len(tt.wantFields) > 0 {
+				if _, ok := results[0].Before.(gocloak.User); !ok {
+					t.Errorf("Before = %T, want gocloak.User", results[0].Before)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteUsers(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        kcops.DeleteUsersRequest
+		failDelete bool
+		want       []kcops.Outcome
+		wantErr    string
+		wantLeft   int
+	}{
+		{
+			name: "deletes",
+			req:  kcops.DeleteUsersRequest{Usernames: []string{"alice"}},
+			want: []kcops.Outcome{kcops.Deleted},
+		},
+		{
+			name:     "fails on a missing user",
+			req:      kcops.DeleteUsersRequest{Usernames: []string{"nobody"}},
+			want:     []kcops.Outcome{},
+			wantErr:  `user "nobody" not found`,
+			wantLeft: 1,
+		},
+		{
+			name:     "skips a missing user with IgnoreMissing",
+			req:      kcops.DeleteUsersRequest{IgnoreMissing: true, Usernames: []string{"nobody", "alice"}},
+			want:     []kcops.Outcome{kcops.Skipped, kcops.Deleted},
+			wantLeft: 0,
+		},
+		{
+			name:       "reports a failed delete",
+			req:        kcops.DeleteUsersRequest{Usernames: []string{"alice"}},
+			failDelete: true,
+			want:       []kcops.Outcome{},
+			wantErr:    `failed deleting user "alice"`,
+			wantLeft:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c, m := newClient(t)
+			if _, err := c.GC.CreateUser(ctx, c.Token, "test", gocloak.User{Username: gocloak.StringP("alice")}); err != nil {
+				t.Fatal(err)
+			}
+			m.failDelete = tt.failDelete
+			tt.req.Realm = "test"
+			results, err := kcops.DeleteUsers(ctx, c, tt.req)
+			checkErr(t, err, tt.wantErr)
+			if got := outcomes(results); !slices.Equal(got, tt.want) {
+				t.Fatalf("outcomes = %v, want %v", got, tt.want)
+			}
+			users, _ := c.GC.GetUsers(ctx, c.Token, "test", gocloak.GetUsersParams{})
+			if len(users) != tt.wantLeft {
+				t.Errorf("%d user(s) left, want %d", len(users), tt.wantLeft)
+			}
+		})
+	}
+}